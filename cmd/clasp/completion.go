@@ -0,0 +1,327 @@
+// CLASP - Claude Language Agent Super Proxy
+// Shell completion script generation
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/jedarden/clasp/internal/config"
+)
+
+// completionCommand is a subcommand offered by shell completion.
+type completionCommand struct {
+	name string
+	desc string
+}
+
+// completionCommands lists the top-level subcommands.
+var completionCommands = []completionCommand{
+	{"profile", "Manage profiles"},
+	{"status", "Show current configuration status"},
+	{"statusline", "Print a status line for Claude Code"},
+	{"stop", "Stop a running proxy"},
+	{"restart", "Restart a running proxy"},
+	{"costs", "Show costs of a running proxy"},
+	{"logs", "View CLASP logs"},
+	{"use", "Switch to a different profile"},
+	{"setup", "Create the configuration file"},
+	{"config", "Validate the configuration"},
+	{"doctor", "Run diagnostics and troubleshooting"},
+	{"mcp", "Start as MCP server"},
+	{"update", "Update CLASP to the latest version"},
+	{"completion", "Generate shell completion script"},
+	{"version", "Show version information"},
+	{"help", "Show help"},
+}
+
+// completionProfileCommands lists the profile subcommands.
+var completionProfileCommands = []completionCommand{
+	{"create", "Create new profile interactively"},
+	{"list", "List all profiles"},
+	{"show", "Show profile details"},
+	{"use", "Switch to a profile"},
+	{"edit", "Edit a profile"},
+	{"delete", "Delete a profile"},
+	{"export", "Export profile to file"},
+	{"import", "Import profile from file"},
+}
+
+// completionShells lists the shells with completion support.
+var completionShells = []string{"bash", "zsh", "fish"}
+
+// completionFlag describes a main command flag.
+type completionFlag struct {
+	name     string
+	desc     string
+	takesArg bool
+}
+
+// completionFlags returns the main command flags, taken from their definitions.
+func completionFlags() []completionFlag {
+	fs := flag.NewFlagSet("clasp", flag.ContinueOnError)
+	defineFlags(fs, &Flags{})
+
+	var flags []completionFlag
+	fs.VisitAll(func(fl *flag.Flag) {
+		boolFlag, ok := fl.Value.(interface{ IsBoolFlag() bool })
+		// Keep descriptions short: drop parenthesized details
+		desc, _, _ := strings.Cut(fl.Usage, " (")
+		flags = append(flags, completionFlag{
+			name:     fl.Name,
+			desc:     desc,
+			takesArg: !ok || !boolFlag.IsBoolFlag(),
+		})
+	})
+	return flags
+}
+
+// completionProviders returns the provider names for -provider.
+func completionProviders() []string {
+	providers := make([]string, len(config.AllProviders))
+	for i, p := range config.AllProviders {
+		providers[i] = string(p)
+	}
+	return providers
+}
+
+// handleCompletionCommand handles the completion subcommand.
+func handleCompletionCommand(args []string) {
+	if len(args) == 0 {
+		fmt.Println("Usage: clasp completion bash|zsh|fish")
+		os.Exit(1)
+	}
+	if err := writeCompletion(os.Stdout, args[0]); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// writeCompletion writes the completion script for shell to w.
+func writeCompletion(w io.Writer, shell string) error {
+	switch shell {
+	case "bash":
+		writeBashCompletion(w)
+	case "zsh":
+		writeZshCompletion(w)
+	case "fish":
+		writeFishCompletion(w)
+	default:
+		return fmt.Errorf("unsupported shell %q (supported: %s)", shell, strings.Join(completionShells, ", "))
+	}
+	return nil
+}
+
+func completionNames(commands []completionCommand) string {
+	names := make([]string, len(commands))
+	for i, c := range commands {
+		names[i] = c.name
+	}
+	return strings.Join(names, " ")
+}
+
+func writeBashCompletion(w io.Writer) {
+	var flagNames []string
+	for _, f := range completionFlags() {
+		flagNames = append(flagNames, "-"+f.name)
+	}
+
+	fmt.Fprintf(w, `# bash completion for clasp
+# Install: clasp completion bash > /etc/bash_completion.d/clasp
+#      or: source <(clasp completion bash)
+
+_clasp_profiles() {
+    local dir="${HOME}/.clasp/profiles" f
+    [[ -d "${dir}" ]] || return
+    for f in "${dir}"/*.json; do
+        [[ -e "${f}" ]] && basename "${f}" .json
+    done
+}
+
+_clasp() {
+    local cur="${COMP_WORDS[COMP_CWORD]}"
+    local prev="${COMP_WORDS[COMP_CWORD-1]}"
+    local commands="%s"
+    local profile_commands="%s"
+    local flags="%s"
+    local providers="%s"
+
+    if [[ ${COMP_CWORD} -ge 2 && "${COMP_WORDS[1]}" == "profile" ]]; then
+        if [[ ${COMP_CWORD} -eq 2 ]]; then
+            COMPREPLY=( $(compgen -W "${profile_commands}" -- "${cur}") )
+        elif [[ ${COMP_CWORD} -eq 3 ]]; then
+            case "${COMP_WORDS[2]}" in
+                use|show|edit|delete|export)
+                    COMPREPLY=( $(compgen -W "$(_clasp_profiles)" -- "${cur}") ) ;;
+                import)
+                    COMPREPLY=( $(compgen -f -- "${cur}") ) ;;
+            esac
+        fi
+        return
+    fi
+
+    case "${prev}" in
+        use|-profile|--profile)
+            COMPREPLY=( $(compgen -W "$(_clasp_profiles)" -- "${cur}") )
+            return ;;
+        -provider|--provider)
+            COMPREPLY=( $(compgen -W "${providers}" -- "${cur}") )
+            return ;;
+        completion)
+            COMPREPLY=( $(compgen -W "%s" -- "${cur}") )
+            return ;;
+    esac
+
+    if [[ "${cur}" == -* ]]; then
+        COMPREPLY=( $(compgen -W "${flags}" -- "${cur}") )
+    elif [[ ${COMP_CWORD} -eq 1 ]]; then
+        COMPREPLY=( $(compgen -W "${commands}" -- "${cur}") )
+    fi
+}
+
+complete -F _clasp clasp
+`,
+		completionNames(completionCommands),
+		completionNames(completionProfileCommands),
+		strings.Join(flagNames, " "),
+		strings.Join(completionProviders(), " "),
+		strings.Join(completionShells, " "),
+	)
+}
+
+// zshQuote escapes a description for use inside a single-quoted zsh spec.
+func zshQuote(s string) string {
+	s = strings.ReplaceAll(s, "'", `'\''`)
+	s = strings.ReplaceAll(s, "[", `\[`)
+	s = strings.ReplaceAll(s, "]", `\]`)
+	return strings.ReplaceAll(s, ":", `\:`)
+}
+
+func writeZshCompletion(w io.Writer) {
+	fmt.Fprint(w, `#compdef clasp
+# zsh completion for clasp
+# Install: clasp completion zsh > "${fpath[1]}/_clasp"
+#      or: source <(clasp completion zsh)
+
+_clasp_profiles() {
+  local -a profiles
+  profiles=(${HOME}/.clasp/profiles/*.json(N:t:r))
+  _describe 'profile' profiles
+}
+
+_clasp() {
+  local -a commands profile_commands
+  commands=(
+`)
+	for _, c := range completionCommands {
+		fmt.Fprintf(w, "    '%s:%s'\n", c.name, zshQuote(c.desc))
+	}
+	fmt.Fprint(w, `  )
+  profile_commands=(
+`)
+	for _, c := range completionProfileCommands {
+		fmt.Fprintf(w, "    '%s:%s'\n", c.name, zshQuote(c.desc))
+	}
+	fmt.Fprintf(w, `  )
+
+  if (( CURRENT == 2 )) && [[ ${words[CURRENT]} != -* ]]; then
+    _describe 'command' commands
+    return
+  fi
+
+  case ${words[2]} in
+    profile)
+      if (( CURRENT == 3 )); then
+        _describe 'profile command' profile_commands
+      elif (( CURRENT == 4 )); then
+        case ${words[3]} in
+          use|show|edit|delete|export) _clasp_profiles ;;
+          import) _files ;;
+        esac
+      fi
+      return ;;
+    use)
+      (( CURRENT == 3 )) && _clasp_profiles
+      return ;;
+    completion)
+      (( CURRENT == 3 )) && _values 'shell' %s
+      return ;;
+    -*) ;;
+    *) return ;;
+  esac
+
+  _arguments \
+`, strings.Join(completionShells, " "))
+
+	providers := strings.Join(completionProviders(), " ")
+	for _, f := range completionFlags() {
+		spec := fmt.Sprintf("-%s[%s]", f.name, zshQuote(f.desc))
+		switch {
+		case f.name == "provider":
+			spec += fmt.Sprintf(":provider:(%s)", providers)
+		case f.name == "profile":
+			spec += ":profile:_clasp_profiles"
+		case f.takesArg:
+			spec += fmt.Sprintf(":%s:", f.name)
+		}
+		fmt.Fprintf(w, "    '%s' \\\n", spec)
+	}
+	fmt.Fprint(w, `    '*::claude arguments:_default'
+}
+
+_clasp "$@"
+`)
+}
+
+// fishQuote escapes a string for use inside single quotes in fish.
+func fishQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return strings.ReplaceAll(s, "'", `\'`)
+}
+
+func writeFishCompletion(w io.Writer) {
+	fmt.Fprint(w, `# fish completion for clasp
+# Install: clasp completion fish > ~/.config/fish/completions/clasp.fish
+
+function __clasp_profiles
+    for f in $HOME/.clasp/profiles/*.json
+        basename $f .json
+    end
+end
+
+complete -c clasp -f
+
+# Subcommands
+`)
+	for _, c := range completionCommands {
+		fmt.Fprintf(w, "complete -c clasp -n __fish_use_subcommand -a %s -d '%s'\n", c.name, fishQuote(c.desc))
+	}
+
+	profileNames := completionNames(completionProfileCommands)
+	fmt.Fprint(w, "\n# Profile subcommands\n")
+	for _, c := range completionProfileCommands {
+		fmt.Fprintf(w, "complete -c clasp -n '__fish_seen_subcommand_from profile; and not __fish_seen_subcommand_from %s' -a %s -d '%s'\n",
+			profileNames, c.name, fishQuote(c.desc))
+	}
+	fmt.Fprint(w, "complete -c clasp -n '__fish_seen_subcommand_from use show edit delete export' -a '(__clasp_profiles)'\n")
+	fmt.Fprint(w, "complete -c clasp -n '__fish_seen_subcommand_from import' -F\n")
+	fmt.Fprintf(w, "complete -c clasp -n '__fish_seen_subcommand_from completion' -a '%s'\n", strings.Join(completionShells, " "))
+
+	providers := strings.Join(completionProviders(), " ")
+	fmt.Fprint(w, "\n# Flags\n")
+	for _, f := range completionFlags() {
+		line := fmt.Sprintf("complete -c clasp -o %s -d '%s'", f.name, fishQuote(f.desc))
+		switch {
+		case f.name == "provider":
+			line += fmt.Sprintf(" -x -a '%s'", providers)
+		case f.name == "profile":
+			line += " -x -a '(__clasp_profiles)'"
+		case f.takesArg:
+			line += " -x"
+		}
+		fmt.Fprintln(w, line)
+	}
+}
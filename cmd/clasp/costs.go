@@ -0,0 +1,131 @@
+// CLASP - Claude Language Agent Super Proxy
+// Cost reports from a running proxy instance
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/jedarden/clasp/internal/proxy"
+	"github.com/jedarden/clasp/internal/statusline"
+)
+
+// costsRequestTimeout bounds a request to a running instance's cost endpoints.
+const costsRequestTimeout = 5 * time.Second
+
+// handleCostsCommand handles the costs subcommand.
+func handleCostsCommand(args []string) {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "--help" {
+		printCostsHelp()
+		return
+	}
+
+	switch args[0] {
+	case "top":
+		handleCostsTopCommand(args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown costs command: %s\n", args[0])
+		printCostsHelp()
+		os.Exit(1)
+	}
+}
+
+func printCostsHelp() {
+	fmt.Println("Usage: clasp costs top [-p <port>] [--sort cost|tokens|requests] [--json]")
+	fmt.Println("")
+	fmt.Println("Shows the models of a running CLASP proxy ranked by spend, with their")
+	fmt.Println("request counts, token totals, and share of the total cost. The port is")
+	fmt.Println("required when more than one instance is running.")
+}
+
+// handleCostsTopCommand prints the model leaderboard of a running instance.
+func handleCostsTopCommand(args []string) {
+	port, err := parsePortArg(args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	sortBy := proxy.CostSortCost
+	asJSON := false
+	for i, arg := range args {
+		switch arg {
+		case "--sort", "-sort":
+			if i+1 >= len(args) {
+				fmt.Fprintf(os.Stderr, "Error: %s requires cost, tokens, or requests\n", arg)
+				os.Exit(1)
+			}
+			sortBy = args[i+1]
+		case "--json", "-json":
+			asJSON = true
+		}
+	}
+
+	inst, err := statusline.FindInstance(port)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	board, err := fetchModelLeaderboard(inst.Port, sortBy)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if asJSON {
+		printJSON(board)
+		return
+	}
+	if len(board.Models) == 0 {
+		fmt.Printf("No model usage recorded on port %d yet\n", inst.Port)
+		return
+	}
+	fmt.Printf("%-40s %10s %12s %12s %7s\n", "MODEL", "REQUESTS", "TOKENS", "COST (USD)", "SHARE")
+	for _, m := range board.Models {
+		fmt.Printf("%-40s %10d %12d %12.4f %6.1f%%\n", m.Model, m.Requests, m.TotalTokens, m.TotalCostUSD, m.CostSharePct)
+	}
+	fmt.Printf("%-40s %10s %12s %12.4f\n", "TOTAL", "", "", board.TotalCostUSD)
+}
+
+// fetchModelLeaderboard reads /costs/models from the instance on port. The
+// request carries CLASP_AUTH_API_KEY when the proxy requires authentication.
+func fetchModelLeaderboard(port int, sortBy string) (*proxy.ModelLeaderboard, error) {
+	endpoint := fmt.Sprintf("http://localhost:%d/costs/models?sort=%s", port, url.QueryEscape(sortBy))
+
+	ctx, cancel := context.WithTimeout(context.Background(), costsRequestTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+	if key := os.Getenv("CLASP_AUTH_API_KEY"); key != "" {
+		req.Header.Set("x-api-key", key)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("contacting CLASP on port %d: %w", port, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("CLASP on port %d returned %d: %s", port, resp.StatusCode, body)
+	}
+
+	var board proxy.ModelLeaderboard
+	if err := json.Unmarshal(body, &board); err != nil {
+		return nil, fmt.Errorf("decoding cost report: %w", err)
+	}
+	if board.Sort == "" {
+		return nil, fmt.Errorf("cost tracking is not enabled on port %d", port)
+	}
+	return &board, nil
+}
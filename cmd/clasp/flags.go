@@ -12,15 +12,17 @@ import (
 // Flags holds all command line flag values
 type Flags struct {
 	// Basic options
-	Port         int
-	Provider     string
-	Model        string
-	Debug        bool
-	ShowVersion  bool
-	Help         bool
-	RunSetup     bool
-	Configure    bool
-	ListModels   bool
+	Port          int
+	Provider      string
+	Model         string
+	Debug         bool
+	ShowVersion   bool
+	Help          bool
+	RunSetup      bool
+	Configure     bool
+	ListModels    bool
+	ModelsTimeout int
+	ModelsJSON    bool
 
 	// Rate limiting
 	RateLimit       bool
@@ -45,12 +47,14 @@ type Flags struct {
 	QueueEnabled bool
 	QueueMaxSize int
 	QueueMaxWait int
+	QueuePersist bool
 
 	// Circuit breaker
 	CircuitBreaker bool
 	CBThreshold    int
 	CBRecovery     int
 	CBTimeout      int
+	CBHalfOpenMax  int
 
 	// HTTP client
 	HTTPTimeout int
@@ -74,65 +78,73 @@ type Flags struct {
 // ParseFlags parses command line flags and returns a Flags struct
 func ParseFlags() *Flags {
 	f := &Flags{}
+	defineFlags(flag.CommandLine, f)
+	flag.Parse()
 
-	flag.IntVar(&f.Port, "port", 0, "Port to listen on (overrides CLASP_PORT)")
-	flag.StringVar(&f.Provider, "provider", "", "LLM provider (openai, azure, openrouter, custom)")
-	flag.StringVar(&f.Model, "model", "", "Default model to use")
-	flag.BoolVar(&f.Debug, "debug", false, "Enable debug logging (requests and responses)")
-
-	flag.BoolVar(&f.RateLimit, "rate-limit", false, "Enable rate limiting")
-	flag.IntVar(&f.RateLimitReqs, "rate-limit-requests", 0, "Requests per window (default: 60)")
-	flag.IntVar(&f.RateLimitWindow, "rate-limit-window", 0, "Window in seconds (default: 60)")
-	flag.IntVar(&f.RateLimitBurst, "rate-limit-burst", 0, "Burst allowance (default: 10)")
-
-	flag.BoolVar(&f.Cache, "cache", false, "Enable response caching")
-	flag.IntVar(&f.CacheMaxSize, "cache-max-size", 0, "Maximum cache entries (default: 1000)")
-	flag.IntVar(&f.CacheTTL, "cache-ttl", 0, "Cache TTL in seconds (default: 3600)")
-
-	flag.BoolVar(&f.MultiProvider, "multi-provider", false, "Enable multi-provider tier routing")
-	flag.BoolVar(&f.Fallback, "fallback", false, "Enable fallback routing")
-
-	flag.BoolVar(&f.Auth, "auth", false, "Enable API key authentication")
-	flag.StringVar(&f.AuthAPIKey, "auth-api-key", "", "API key for authentication (required with -auth)")
-
-	flag.BoolVar(&f.QueueEnabled, "queue", false, "Enable request queuing during outages")
-	flag.IntVar(&f.QueueMaxSize, "queue-max-size", 0, "Maximum queued requests (default: 100)")
-	flag.IntVar(&f.QueueMaxWait, "queue-max-wait", 0, "Queue timeout in seconds (default: 30)")
-
-	flag.BoolVar(&f.CircuitBreaker, "circuit-breaker", false, "Enable circuit breaker pattern")
-	flag.IntVar(&f.CBThreshold, "cb-threshold", 0, "Circuit breaker failure threshold (default: 5)")
-	flag.IntVar(&f.CBRecovery, "cb-recovery", 0, "Circuit breaker success recovery threshold (default: 2)")
-	flag.IntVar(&f.CBTimeout, "cb-timeout", 0, "Circuit breaker timeout in seconds (default: 30)")
+	// Warn about API key in command line (security risk)
+	if f.DirectAPIKey != "" {
+		fmt.Print(setup.WarnCLIAPIKey())
+	}
 
-	flag.IntVar(&f.HTTPTimeout, "http-timeout", 0, "HTTP client timeout in seconds for upstream requests (default: 300)")
+	return f
+}
 
-	flag.BoolVar(&f.ShowVersion, "version", false, "Show version information")
-	flag.BoolVar(&f.Help, "help", false, "Show help message")
-	flag.BoolVar(&f.RunSetup, "setup", false, "Run interactive setup wizard")
-	flag.BoolVar(&f.Configure, "configure", false, "Run interactive setup wizard (alias for -setup)")
-	flag.BoolVar(&f.ListModels, "models", false, "List available models from provider")
+// defineFlags registers all command line flags on fs, storing values in f.
+// Shell completion also uses it to enumerate the known flags.
+func defineFlags(fs *flag.FlagSet, f *Flags) {
+	fs.IntVar(&f.Port, "port", 0, "Port to listen on (overrides CLASP_PORT)")
+	fs.StringVar(&f.Provider, "provider", "", "LLM provider (openai, azure, openrouter, custom)")
+	fs.StringVar(&f.Model, "model", "", "Default model to use")
+	fs.BoolVar(&f.Debug, "debug", false, "Enable debug logging (requests and responses)")
+
+	fs.BoolVar(&f.RateLimit, "rate-limit", false, "Enable rate limiting")
+	fs.IntVar(&f.RateLimitReqs, "rate-limit-requests", 0, "Requests per window (default: 60)")
+	fs.IntVar(&f.RateLimitWindow, "rate-limit-window", 0, "Window in seconds (default: 60)")
+	fs.IntVar(&f.RateLimitBurst, "rate-limit-burst", 0, "Burst allowance (default: 10)")
+
+	fs.BoolVar(&f.Cache, "cache", false, "Enable response caching")
+	fs.IntVar(&f.CacheMaxSize, "cache-max-size", 0, "Maximum cache entries (default: 1000)")
+	fs.IntVar(&f.CacheTTL, "cache-ttl", 0, "Cache TTL in seconds (default: 3600)")
+
+	fs.BoolVar(&f.MultiProvider, "multi-provider", false, "Enable multi-provider tier routing")
+	fs.BoolVar(&f.Fallback, "fallback", false, "Enable fallback routing")
+
+	fs.BoolVar(&f.Auth, "auth", false, "Enable API key authentication")
+	fs.StringVar(&f.AuthAPIKey, "auth-api-key", "", "API key for authentication (required with -auth)")
+
+	fs.BoolVar(&f.QueueEnabled, "queue", false, "Enable request queuing during outages")
+	fs.IntVar(&f.QueueMaxSize, "queue-max-size", 0, "Maximum queued requests (default: 100)")
+	fs.IntVar(&f.QueueMaxWait, "queue-max-wait", 0, "Queue timeout in seconds (default: 30)")
+	fs.BoolVar(&f.QueuePersist, "queue-persist", false, "Persist queued requests to disk and replay them on restart")
+
+	fs.BoolVar(&f.CircuitBreaker, "circuit-breaker", false, "Enable circuit breaker pattern")
+	fs.IntVar(&f.CBThreshold, "cb-threshold", 0, "Circuit breaker failure threshold (default: 5)")
+	fs.IntVar(&f.CBRecovery, "cb-recovery", 0, "Circuit breaker success recovery threshold (default: 2)")
+	fs.IntVar(&f.CBTimeout, "cb-timeout", 0, "Circuit breaker timeout in seconds (default: 30)")
+	fs.IntVar(&f.CBHalfOpenMax, "cb-half-open-max", 0, "Circuit breaker probe requests admitted at once while half-open (default: 1)")
+
+	fs.IntVar(&f.HTTPTimeout, "http-timeout", 0, "HTTP client timeout in seconds for upstream requests (default: 300)")
+
+	fs.BoolVar(&f.ShowVersion, "version", false, "Show version information")
+	fs.BoolVar(&f.Help, "help", false, "Show help message")
+	fs.BoolVar(&f.RunSetup, "setup", false, "Run interactive setup wizard")
+	fs.BoolVar(&f.Configure, "configure", false, "Run interactive setup wizard (alias for -setup)")
+	fs.BoolVar(&f.ListModels, "models", false, "List available models from provider")
+	fs.IntVar(&f.ModelsTimeout, "timeout", 0, "Seconds to wait for the model list with -models (default: CLASP_MODELS_TIMEOUT or 15)")
+	fs.BoolVar(&f.ModelsJSON, "json", false, "Print the model list as JSON with -models")
 
 	// Claude Code management flags
-	flag.BoolVar(&f.LaunchClaude, "launch", false, "Start proxy and launch Claude Code (default behavior)")
-	flag.BoolVar(&f.UpdateClaude, "update-claude", false, "Update Claude Code to latest version")
-	flag.BoolVar(&f.ClaudeStatus, "claude-status", false, "Check Claude Code installation status")
-	flag.BoolVar(&f.ProxyOnly, "proxy-only", false, "Run proxy only without launching Claude Code")
-	flag.BoolVar(&f.Verbose, "verbose", false, "Enable verbose output")
-	flag.BoolVar(&f.SkipPermissions, "skip-permissions", false, "Auto-approve all Claude Code operations (--dangerously-skip-permissions)")
-	flag.BoolVar(&f.WithPrompts, "with-prompts", false, "Force standard mode with confirmation prompts (overrides profile setting)")
+	fs.BoolVar(&f.LaunchClaude, "launch", false, "Start proxy and launch Claude Code (default behavior)")
+	fs.BoolVar(&f.UpdateClaude, "update-claude", false, "Update Claude Code to latest version")
+	fs.BoolVar(&f.ClaudeStatus, "claude-status", false, "Check Claude Code installation status")
+	fs.BoolVar(&f.ProxyOnly, "proxy-only", false, "Run proxy only without launching Claude Code")
+	fs.BoolVar(&f.Verbose, "verbose", false, "Enable verbose output")
+	fs.BoolVar(&f.SkipPermissions, "skip-permissions", false, "Auto-approve all Claude Code operations (--dangerously-skip-permissions)")
+	fs.BoolVar(&f.WithPrompts, "with-prompts", false, "Force standard mode with confirmation prompts (overrides profile setting)")
 
 	// Profile management flags
-	flag.StringVar(&f.ProfileName, "profile", "", "Use a specific profile")
+	fs.StringVar(&f.ProfileName, "profile", "", "Use a specific profile")
 
 	// Direct API key flag (with security warning)
-	flag.StringVar(&f.DirectAPIKey, "api-key", "", "API key for provider (visible in shell history)")
-
-	flag.Parse()
-
-	// Warn about API key in command line (security risk)
-	if f.DirectAPIKey != "" {
-		fmt.Print(setup.WarnCLIAPIKey())
-	}
-
-	return f
+	fs.StringVar(&f.DirectAPIKey, "api-key", "", "API key for provider (visible in shell history)")
 }
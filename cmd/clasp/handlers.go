@@ -4,10 +4,12 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 	"os/exec"
 	"os/signal"
@@ -15,6 +17,7 @@ import (
 	"strconv"
 	"strings"
 	"syscall"
+	"text/tabwriter"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -26,6 +29,7 @@ import (
 	"github.com/jedarden/clasp/internal/proxy"
 	"github.com/jedarden/clasp/internal/setup"
 	"github.com/jedarden/clasp/internal/statusline"
+	"github.com/jedarden/clasp/internal/translator"
 )
 
 // printBanner displays the CLASP banner.
@@ -49,10 +53,15 @@ Quick Start:
   clasp -profile <name>     Start with a specific profile (skip selector)
   clasp -proxy-only         Start proxy only (no Claude Code, no selector)
   clasp status              Show current configuration status
+  clasp statusline          Print a one-line summary for Claude Code's status line
+  clasp stop [-p <port>]    Stop a running proxy
+  clasp restart [-p <port>] Restart a running -proxy-only proxy
+  clasp costs top           Rank a running proxy's models by spend
   clasp use <profile>       Switch to a different profile
   clasp doctor              Run diagnostics and troubleshooting
   clasp mcp                 Start as MCP server (for tool integration)
   clasp update              Update CLASP to the latest version
+  clasp completion <shell>  Print shell completion script (bash, zsh, fish)
 
 Profile Management:
   clasp profile create      Create new profile interactively
@@ -67,7 +76,18 @@ Profile Management:
 Setup & Configuration:
   -setup                    Run interactive setup wizard
   -configure                Alias for -setup
+  clasp setup --non-interactive --provider <p> --api-key <k>
+                            Write the config without prompts (CI, Docker)
+  clasp setup --from-json <file>
+                            Write the config from a JSON file
+  clasp config validate [--file <path>]
+                            Check a config for errors before launching
   -models                   List available models from provider
+  -models -timeout <sec>    Wait up to <sec> seconds for the model list
+                            (default: CLASP_MODELS_TIMEOUT or 15)
+  -models -json             Print the model list as JSON with known metadata
+  clasp models --capabilities
+                            Show which features each model family supports
   -profile <name>           Use a specific profile for this session
 
 Claude Code Management:
@@ -98,10 +118,12 @@ Options:
   -queue                    Enable request queuing during provider outages
   -queue-max-size <n>       Maximum queued requests (default: 100)
   -queue-max-wait <n>       Queue timeout in seconds (default: 30)
+  -queue-persist            Persist queued requests and replay them on restart
   -circuit-breaker          Enable circuit breaker pattern
   -cb-threshold <n>         Failures before opening circuit (default: 5)
   -cb-recovery <n>          Successes to close circuit (default: 2)
   -cb-timeout <n>           Circuit breaker timeout in seconds (default: 30)
+  -cb-half-open-max <n>     Probe requests admitted at once while half-open (default: 1)
   -version                  Show version information
   -help                     Show this help message
 
@@ -152,6 +174,8 @@ Environment Variables:
   Server:
     CLASP_PORT           Port to listen on (default: 8080)
     CLASP_LOG_LEVEL      Logging level (debug, info, minimal)
+    CLASP_API_PATH_PREFIX      Prefix of the messages and embeddings routes (default: /v1, "/" for none)
+    CLASP_API_PATH_UNPREFIXED  Also serve those routes without the prefix (true/1)
 
   Debug:
     CLASP_DEBUG            Enable all debug logging (true/1)
@@ -196,6 +220,9 @@ Environment Variables:
     CLASP_QUEUE_MAX_WAIT       Queue timeout in seconds (default: 30)
     CLASP_QUEUE_RETRY_DELAY    Retry delay in milliseconds (default: 1000)
     CLASP_QUEUE_MAX_RETRIES    Maximum retries per request (default: 3)
+    CLASP_QUEUE_PERSIST        Persist queued requests across restarts (true/1)
+    CLASP_QUEUE_PERSIST_DIR    Directory for persisted requests (default: ~/.clasp/queue)
+    CLASP_QUEUE_DLQ_DIR        Directory for failed queued requests (default: ~/.clasp/dlq)
 
   Circuit Breaker (prevent cascade failures):
     CLASP_CIRCUIT_BREAKER          Enable circuit breaker (true/1)
@@ -216,6 +243,7 @@ Endpoints:
   /metrics             - JSON metrics endpoint
   /metrics/prometheus  - Prometheus format metrics
   /costs               - Cost tracking summary (GET=summary, POST?action=reset=reset)
+  /costs/models        - Models ranked by spend (?sort=cost|tokens|requests)
 
 Cost Tracking:
   CLASP automatically tracks API costs based on token usage.
@@ -325,6 +353,7 @@ func handleStatusCommand(args []string) {
 	verbose := false
 	showAll := false
 	cleanup := false
+	jsonOutput := false
 	var port int
 
 	for i, arg := range args {
@@ -335,6 +364,8 @@ func handleStatusCommand(args []string) {
 			showAll = true
 		case "--cleanup":
 			cleanup = true
+		case "--json":
+			jsonOutput = true
 		case "-p", "--port":
 			if i+1 < len(args) {
 				if p, err := strconv.Atoi(args[i+1]); err == nil {
@@ -366,10 +397,23 @@ func handleStatusCommand(args []string) {
 			fmt.Printf("Error listing instances: %v\n", err)
 			os.Exit(1)
 		}
+		if jsonOutput {
+			// The recorded command line can hold keys from instances that predate masking
+			for i := range instances {
+				instances[i].Args = nil
+			}
+			printJSON(instances)
+			return
+		}
 		fmt.Print(statusline.FormatAllInstancesTable(instances))
 		return
 	}
 
+	if jsonOutput {
+		printJSON(buildStatusReport(port))
+		return
+	}
+
 	pm := setup.NewProfileManager()
 
 	// Get active profile (may be nil if not configured)
@@ -452,17 +496,7 @@ func handleStatusCommand(args []string) {
 		}
 
 		// Show features
-		var features []string
-		if activeProfile.RateLimitEnabled {
-			features = append(features, "rate-limit")
-		}
-		if activeProfile.CacheEnabled {
-			features = append(features, "cache")
-		}
-		if activeProfile.CircuitBreakerEnabled {
-			features = append(features, "circuit-breaker")
-		}
-		if len(features) > 0 {
+		if features := profileFeatures(activeProfile); len(features) > 0 {
 			fmt.Printf("\n  Features:   %s\n", strings.Join(features, ", "))
 		}
 	} else {
@@ -480,9 +514,118 @@ func handleStatusCommand(args []string) {
 	fmt.Println("  clasp status --all       Show all running CLASP instances")
 	fmt.Println("  clasp status -p <port>   Show status for specific port")
 	fmt.Println("  clasp status --cleanup   Remove stale status files")
+	fmt.Println("  clasp status --json      Print status as JSON")
+	fmt.Println("  clasp stop -p <port>     Stop the proxy on a port")
 	fmt.Println("")
 }
 
+// profileFeatures returns the names of the features enabled in a profile.
+func profileFeatures(p *setup.Profile) []string {
+	features := []string{}
+	if p.RateLimitEnabled {
+		features = append(features, "rate-limit")
+	}
+	if p.CacheEnabled {
+		features = append(features, "cache")
+	}
+	if p.CircuitBreakerEnabled {
+		features = append(features, "circuit-breaker")
+	}
+	return features
+}
+
+// statusReport is the output of 'clasp status --json'.
+type statusReport struct {
+	Version  string             `json:"version"`
+	Running  bool               `json:"running"`
+	Stale    bool               `json:"stale,omitempty"`
+	PID      int                `json:"pid,omitempty"`
+	Uptime   string             `json:"uptime,omitempty"`
+	Provider string             `json:"provider,omitempty"`
+	Model    string             `json:"model,omitempty"`
+	Profile  *statusProfile     `json:"profile"`
+	Proxy    *statusline.Status `json:"proxy,omitempty"`
+}
+
+// statusProfile summarizes the active profile in a statusReport.
+type statusProfile struct {
+	Name         string            `json:"name"`
+	Provider     string            `json:"provider"`
+	Model        string            `json:"model,omitempty"`
+	TierMappings map[string]string `json:"tier_mappings,omitempty"`
+	Port         int               `json:"port,omitempty"`
+	Features     []string          `json:"features"`
+}
+
+// buildStatusReport collects the proxy and profile state shown by 'clasp status'.
+// Provider and model come from the running proxy, or the active profile otherwise.
+func buildStatusReport(port int) statusReport {
+	report := statusReport{Version: version}
+
+	if activeProfile, err := setup.NewProfileManager().GetActiveProfile(); err == nil && activeProfile != nil {
+		profile := &statusProfile{
+			Name:     activeProfile.Name,
+			Provider: activeProfile.Provider,
+			Model:    activeProfile.DefaultModel,
+			Port:     activeProfile.Port,
+			Features: profileFeatures(activeProfile),
+		}
+		if len(activeProfile.TierMappings) > 0 {
+			profile.TierMappings = make(map[string]string, len(activeProfile.TierMappings))
+			for tier, mapping := range activeProfile.TierMappings {
+				profile.TierMappings[tier] = mapping.Model
+			}
+		}
+		report.Profile = profile
+		report.Provider = profile.Provider
+		report.Model = profile.Model
+	}
+
+	var proxyStatus *statusline.Status
+	var err error
+	if port > 0 {
+		proxyStatus, err = statusline.ReadStatusFromPort(port)
+	} else {
+		proxyStatus, err = statusline.ReadStatusFromFile()
+	}
+	if err != nil || proxyStatus == nil || !proxyStatus.Running {
+		return report
+	}
+
+	isRunning := false
+	if proxyStatus.PID > 0 {
+		if process, err := os.FindProcess(proxyStatus.PID); err == nil {
+			isRunning = process.Signal(syscall.Signal(0)) == nil
+		}
+	}
+	if !isRunning {
+		report.Stale = true
+		return report
+	}
+
+	report.Running = true
+	report.PID = proxyStatus.PID
+	if !proxyStatus.StartTime.IsZero() {
+		report.Uptime = time.Since(proxyStatus.StartTime).Round(time.Second).String()
+	}
+	report.Provider = proxyStatus.Provider
+	report.Model = proxyStatus.Model
+	// The recorded command line can hold keys from instances that predate masking
+	proxyStatus.Args = nil
+	report.Proxy = proxyStatus
+	return report
+}
+
+// printJSON writes v to stdout as indented JSON.
+func printJSON(v interface{}) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println(string(data))
+}
+
 // handleLogsCommand handles the logs subcommand.
 func handleLogsCommand(args []string) {
 	logPath := logging.GetLogPath()
@@ -678,8 +821,50 @@ func tailLogFile(logPath, logType string) {
 	}
 }
 
-// listAvailableModels lists models from the configured provider.
-func listAvailableModels() error {
+// modelListing is the output of 'clasp -models -json'.
+type modelListing struct {
+	Provider string             `json:"provider"`
+	Count    int                `json:"count"`
+	Models   []modelListingItem `json:"models"`
+}
+
+// modelListingItem is one model of a modelListing, with whatever metadata
+// CLASP knows for it.
+type modelListingItem struct {
+	ID                    string  `json:"id"`
+	Name                  string  `json:"name,omitempty"`
+	Description           string  `json:"description,omitempty"`
+	Owner                 string  `json:"owner,omitempty"`
+	ContextWindow         int     `json:"context_window,omitempty"`
+	InputPricePerMillion  float64 `json:"input_price_per_million,omitempty"`
+	OutputPricePerMillion float64 `json:"output_price_per_million,omitempty"`
+	Recommended           bool    `json:"recommended,omitempty"`
+}
+
+// newModelListing merges the fetched models with the known metadata for the
+// provider.
+func newModelListing(provider string, fetched []string) modelListing {
+	merged := setup.MergeModelLists(fetched, setup.GetKnownModels(provider))
+	listing := modelListing{Provider: provider, Count: len(merged), Models: make([]modelListingItem, 0, len(merged))}
+	for _, m := range merged {
+		listing.Models = append(listing.Models, modelListingItem{
+			ID:                    m.ID,
+			Name:                  m.Name,
+			Description:           m.Desc,
+			Owner:                 m.Provider,
+			ContextWindow:         m.ContextSize,
+			InputPricePerMillion:  m.InputPrice,
+			OutputPricePerMillion: m.OutputPrice,
+			Recommended:           m.IsRecommended,
+		})
+	}
+	return listing
+}
+
+// listAvailableModels lists models from the configured provider, waiting up
+// to timeout for them, or CLASP_MODELS_TIMEOUT when timeout is 0. With asJSON
+// the list is printed as a modelListing.
+func listAvailableModels(timeout time.Duration, asJSON bool) error {
 	// Load .env file if it exists
 	envPaths := []string{
 		".env",
@@ -700,16 +885,29 @@ func listAvailableModels() error {
 		return fmt.Errorf("no configuration found. Run 'clasp -setup' first")
 	}
 
-	fmt.Println("")
-	fmt.Printf("Fetching models from %s...\n", cfg.Provider)
-	fmt.Println("")
+	if !asJSON {
+		fmt.Println("")
+		fmt.Printf("Fetching models from %s...\n", cfg.Provider)
+		fmt.Println("")
+	}
 
+	if timeout == 0 {
+		if timeout, err = setup.ModelsTimeoutFromEnv(); err != nil {
+			return err
+		}
+	}
 	wizard := setup.NewWizard()
+	wizard.SetModelsTimeout(timeout)
 	models, err := wizard.FetchModelsPublic(string(cfg.Provider), cfg.GetAPIKey(), cfg.CustomBaseURL, cfg.AzureEndpoint)
 	if err != nil {
 		return fmt.Errorf("failed to fetch models: %w", err)
 	}
 
+	if asJSON {
+		printJSON(newModelListing(string(cfg.Provider), models))
+		return nil
+	}
+
 	if len(models) == 0 {
 		fmt.Println("No models found.")
 		return nil
@@ -725,6 +923,141 @@ func listAvailableModels() error {
 	return nil
 }
 
+// handleModelsCommand handles the models subcommand.
+func handleModelsCommand(args []string) {
+	var timeout time.Duration
+	asJSON := false
+	for i, arg := range args {
+		switch arg {
+		case "--json", "-json":
+			asJSON = true
+		case "--capabilities", "-capabilities":
+			printModelCapabilities()
+			return
+		case "--timeout", "-timeout":
+			seconds := 0
+			if i+1 < len(args) {
+				seconds, _ = strconv.Atoi(args[i+1])
+			}
+			if seconds <= 0 {
+				fmt.Fprintf(os.Stderr, "Error: %s requires a positive number of seconds\n", arg)
+				os.Exit(1)
+			}
+			timeout = time.Duration(seconds) * time.Second
+		}
+	}
+	if err := listAvailableModels(timeout, asJSON); err != nil {
+		log.Fatalf("[CLASP] Failed to list models: %v", err)
+	}
+}
+
+// handleStatuslineCommand prints a one-line summary of the running proxy for
+// Claude Code's statusLine setting. The instance is picked with -p/--port,
+// or from the port in ANTHROPIC_BASE_URL.
+func handleStatuslineCommand(args []string) {
+	var port int
+	install := false
+	for i, arg := range args {
+		switch arg {
+		case "-p", "--port":
+			if i+1 < len(args) {
+				if p, err := strconv.Atoi(args[i+1]); err == nil {
+					port = p
+				}
+			}
+		case "--install":
+			install = true
+		}
+	}
+
+	if install {
+		manager, err := statusline.NewManager()
+		if err != nil {
+			log.Fatalf("[CLASP] %v", err)
+		}
+		command := "clasp statusline"
+		if port > 0 {
+			command = fmt.Sprintf("clasp statusline -p %d", port)
+		}
+		if err := manager.ConfigureClaudeCodeCommand(command); err != nil {
+			log.Fatalf("[CLASP] %v", err)
+		}
+		fmt.Printf("Claude Code status line set to '%s'\n", command)
+		return
+	}
+
+	if port == 0 {
+		port = portFromBaseURL(os.Getenv("ANTHROPIC_BASE_URL"))
+	}
+	status, err := statusline.ReadStatusFromPort(port)
+	if err != nil || status == nil || !status.Running || !processRunning(status.PID) {
+		fmt.Println(claudeStatusFallback())
+		return
+	}
+	fmt.Println(statusline.FormatClaudeStatusLine(status, time.Now()))
+}
+
+// portFromBaseURL returns the port of a base URL like http://localhost:8080,
+// or 0 if there is none.
+func portFromBaseURL(baseURL string) int {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return 0
+	}
+	port, _ := strconv.Atoi(u.Port())
+	return port
+}
+
+// processRunning reports whether a process with the given PID exists.
+func processRunning(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	return process.Signal(syscall.Signal(0)) == nil
+}
+
+// claudeStatusFallback returns the model name Claude Code passes on stdin,
+// shown when no CLASP instance is running.
+func claudeStatusFallback() string {
+	if info, err := os.Stdin.Stat(); err == nil && info.Mode()&os.ModeCharDevice == 0 {
+		var input struct {
+			Model struct {
+				DisplayName string `json:"display_name"`
+			} `json:"model"`
+		}
+		if json.NewDecoder(os.Stdin).Decode(&input) == nil && input.Model.DisplayName != "" {
+			return input.Model.DisplayName
+		}
+	}
+	return "Claude"
+}
+
+// printModelCapabilities prints the model capability registry. Features a
+// model lacks are stripped from requests before they are forwarded.
+func printModelCapabilities() {
+	mark := func(ok bool) string {
+		if ok {
+			return "yes"
+		}
+		return "-"
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "FAMILY\tTOOLS\tVISION\tTHINKING\tJSON MODE\tMODELS")
+	for _, family := range translator.ModelFamilies() {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s*\n", family.Name,
+			mark(family.Tools), mark(family.Vision), mark(family.Thinking), mark(family.JSONMode),
+			strings.Join(family.Prefixes, "*, "))
+	}
+	w.Flush()
+	fmt.Println("")
+	fmt.Println("Models not listed are assumed to support every feature.")
+}
+
 // handleMCPCommand starts the MCP server mode.
 func handleMCPCommand(args []string) {
 	// Parse MCP-specific flags
@@ -1019,6 +1352,147 @@ For more information: https://github.com/jedarden/CLASP
 `, version)
 }
 
+// handleSetupCommand handles the setup subcommand.
+// Without options it runs the interactive wizard; --non-interactive and
+// --from-json write the config file without prompts.
+func handleSetupCommand(args []string) {
+	fs := flag.NewFlagSet("setup", flag.ExitOnError)
+	fs.Usage = printSetupHelp
+	nonInteractive := fs.Bool("non-interactive", false, "")
+	fromJSON := fs.String("from-json", "", "")
+	provider := fs.String("provider", "", "")
+	apiKey := fs.String("api-key", "", "")
+	model := fs.String("model", "", "")
+	baseURL := fs.String("base-url", "", "")
+	azureEndpoint := fs.String("azure-endpoint", "", "")
+	azureDeployment := fs.String("azure-deployment", "", "")
+	_ = fs.Parse(args)
+
+	wizard := setup.NewWizard()
+
+	if !*nonInteractive && *fromJSON == "" {
+		if _, err := wizard.Run(); err != nil {
+			log.Fatalf("[CLASP] Setup failed: %v", err)
+		}
+		return
+	}
+
+	var cfg *setup.ConfigFile
+	if *fromJSON != "" {
+		loaded, err := setup.LoadConfigFileJSON(*fromJSON)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		cfg = loaded
+	} else {
+		cfg = &setup.ConfigFile{
+			Provider:        *provider,
+			APIKey:          *apiKey,
+			Model:           *model,
+			BaseURL:         *baseURL,
+			AzureEndpoint:   *azureEndpoint,
+			AzureDeployment: *azureDeployment,
+		}
+		// Allow the key to come from the environment so it stays out of shell history
+		if cfg.APIKey == "" {
+			cfg.APIKey = os.Getenv("CLASP_SETUP_API_KEY")
+		}
+	}
+
+	if err := wizard.RunNonInteractive(cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// printSetupHelp prints help for the setup command.
+func printSetupHelp() {
+	fmt.Printf(`CLASP Setup %s
+
+Create the CLASP configuration file (~/.clasp/config.json).
+
+Usage: clasp setup [options]
+
+Options:
+  --non-interactive           Write the config from flags without prompting
+  --from-json <file>          Write the config from a JSON file without prompting
+  --provider <name>           openai, azure, openrouter, anthropic, ollama,
+                              gemini, deepseek, litellm, custom
+  --api-key <key>             Provider API key (or set CLASP_SETUP_API_KEY)
+  --model <model>             Default model (default: provider's recommended model)
+  --base-url <url>            Base URL (required for custom; ollama, litellm)
+  --azure-endpoint <url>      Azure OpenAI endpoint (required for azure)
+  --azure-deployment <name>   Azure deployment name (required for azure)
+
+Without options, the interactive setup wizard is started.
+
+Examples:
+  clasp setup
+  clasp setup --non-interactive --provider openai --api-key sk-... --model gpt-4o
+  clasp setup --from-json config.json
+`, version)
+}
+
+// handleConfigCommand handles the config subcommand.
+func handleConfigCommand(args []string) {
+	if len(args) == 0 || args[0] != "validate" {
+		printConfigHelp()
+		if len(args) > 0 && args[0] != "help" && args[0] != "-h" && args[0] != "--help" {
+			os.Exit(1)
+		}
+		return
+	}
+
+	fs := flag.NewFlagSet("config validate", flag.ExitOnError)
+	fs.Usage = printConfigHelp
+	file := fs.String("file", "", "")
+	_ = fs.Parse(args[1:])
+
+	report := setup.ValidateConfig(*file)
+	fmt.Printf("Validating %s\n\n", report.Source)
+	for _, msg := range report.Errors {
+		fmt.Printf("✗ %s\n", msg)
+	}
+	for _, msg := range report.Warnings {
+		fmt.Printf("⚠ %s\n", msg)
+	}
+	if len(report.Errors) > 0 || len(report.Warnings) > 0 {
+		fmt.Println("")
+	}
+
+	if !report.OK() {
+		fmt.Printf("Configuration is invalid: %d error(s), %d warning(s)\n", len(report.Errors), len(report.Warnings))
+		os.Exit(1)
+	}
+	if len(report.Warnings) > 0 {
+		fmt.Printf("Configuration is valid with %d warning(s)\n", len(report.Warnings))
+		return
+	}
+	fmt.Println("Configuration is valid")
+}
+
+// printConfigHelp prints help for the config command.
+func printConfigHelp() {
+	fmt.Printf(`CLASP Config %s
+
+Usage: clasp config validate [--file <path>]
+
+Check the configuration CLASP would start with, without contacting the
+provider. Errors (missing credentials, unknown providers, malformed aliases)
+exit with status 1; warnings (unknown models, conflicting settings) don't.
+
+Options:
+  --file <path>   Config file to check (.json or .yaml). Default: the file
+                  CLASP would load (~/.clasp/config.json, CLASP_CONFIG_FILE,
+                  or a YAML config file), plus environment variables
+
+Examples:
+  clasp config validate
+  clasp config validate --file ./ci/clasp.yaml
+`, version)
+}
+
 // handleClaudeStatus handles the Claude Code status check.
 func handleClaudeStatus(verbose bool) {
 	manager := claudecode.NewManager("", verbose)
@@ -1117,6 +1591,9 @@ func applyFlagOverrides(cfg *config.Config, flags *Flags) {
 	if flags.QueueMaxWait > 0 {
 		cfg.QueueMaxWaitSeconds = flags.QueueMaxWait
 	}
+	if flags.QueuePersist {
+		cfg.QueuePersist = true
+	}
 	if flags.CircuitBreaker {
 		cfg.CircuitBreakerEnabled = true
 	}
@@ -1129,6 +1606,9 @@ func applyFlagOverrides(cfg *config.Config, flags *Flags) {
 	if flags.CBTimeout > 0 {
 		cfg.CircuitBreakerTimeoutSec = flags.CBTimeout
 	}
+	if flags.CBHalfOpenMax > 0 {
+		cfg.CircuitBreakerHalfOpenMax = flags.CBHalfOpenMax
+	}
 	if flags.HTTPTimeout > 0 {
 		cfg.HTTPClientTimeoutSec = flags.HTTPTimeout
 	}
@@ -1178,6 +1658,28 @@ func loadEnvFiles() {
 	}
 }
 
+// configLoader returns a function that re-reads the configuration the way
+// startup does, for reloading on SIGHUP. Command line flags still take
+// precedence. .env files aren't re-read since they never override variables
+// that are already set.
+func configLoader(flags *Flags) func() (*config.Config, error) {
+	return func() (*config.Config, error) {
+		if os.Getenv("CLASP_CONFIG_FILE") == "" {
+			if err := setup.ApplyConfigToEnv(); err != nil && !os.IsNotExist(err) {
+				return nil, err
+			}
+		}
+		applyDirectAPIKey(flags)
+
+		cfg, err := config.LoadWithFile()
+		if err != nil {
+			return nil, err
+		}
+		applyFlagOverrides(cfg, flags)
+		return cfg, nil
+	}
+}
+
 // runProxyWithClaude starts the proxy and launches Claude Code.
 func runProxyWithClaude(cfg *config.Config, flags *Flags) {
 	// Configure logging to file to prevent TUI corruption
@@ -1198,6 +1700,7 @@ func runProxyWithClaude(cfg *config.Config, flags *Flags) {
 	if err != nil {
 		log.Fatalf("[CLASP] Failed to create server: %v", err)
 	}
+	server.SetConfigLoader(configLoader(flags))
 
 	serverErrCh := make(chan error, 1)
 	go func() {
@@ -1294,13 +1797,14 @@ func runProxyWithClaude(cfg *config.Config, flags *Flags) {
 }
 
 // runProxyOnly starts the proxy in standalone mode.
-func runProxyOnly(cfg *config.Config) {
+func runProxyOnly(cfg *config.Config, flags *Flags) {
 	printBanner()
 
 	server, err := proxy.NewServerWithVersion(cfg, version)
 	if err != nil {
 		log.Fatalf("[CLASP] Failed to create server: %v", err)
 	}
+	server.SetConfigLoader(configLoader(flags))
 
 	if err := server.Start(); err != nil {
 		log.Fatalf("[CLASP] Server error: %v", err)
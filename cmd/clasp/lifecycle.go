@@ -0,0 +1,184 @@
+// CLASP - Claude Language Agent Super Proxy
+// Stop and restart commands for running proxy instances
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/jedarden/clasp/internal/logging"
+	"github.com/jedarden/clasp/internal/statusline"
+)
+
+const (
+	// stopTimeout is how long to wait for a proxy to shut down after SIGTERM.
+	stopTimeout = 15 * time.Second
+	// restartTimeout is how long to wait for a relaunched proxy to report running.
+	restartTimeout = 15 * time.Second
+)
+
+// parsePortArg returns the value of -p/--port in args, or 0 if absent.
+func parsePortArg(args []string) (int, error) {
+	for i, arg := range args {
+		if arg == "-p" || arg == "--port" {
+			if i+1 >= len(args) {
+				return 0, fmt.Errorf("%s requires a port number", arg)
+			}
+			port, err := strconv.Atoi(args[i+1])
+			if err != nil {
+				return 0, fmt.Errorf("invalid port %q", args[i+1])
+			}
+			return port, nil
+		}
+	}
+	return 0, nil
+}
+
+// stopInstance stops the selected instance, reporting stale status files.
+// It returns the stopped instance, or nil if nothing was running.
+func stopInstance(port int) (*statusline.InstanceInfo, error) {
+	inst, err := statusline.FindInstance(port)
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Printf("Stopping CLASP on port %d (PID %d)...\n", inst.Port, inst.PID)
+	if err := statusline.StopInstance(inst, stopTimeout); err != nil {
+		if errors.Is(err, statusline.ErrStaleInstance) {
+			fmt.Printf("Removed stale status file for port %d (PID %d is not running CLASP)\n", inst.Port, inst.PID)
+			return nil, nil
+		}
+		return nil, err
+	}
+	fmt.Printf("CLASP on port %d stopped\n", inst.Port)
+	return inst, nil
+}
+
+// handleStopCommand handles the stop subcommand.
+func handleStopCommand(args []string) {
+	if len(args) > 0 && (args[0] == "-h" || args[0] == "--help") {
+		fmt.Println("Usage: clasp stop [-p <port>]")
+		fmt.Println("")
+		fmt.Println("Gracefully stops a running CLASP proxy. The port is required")
+		fmt.Println("when more than one instance is running.")
+		return
+	}
+
+	port, err := parsePortArg(args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if _, err := stopInstance(port); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// handleRestartCommand handles the restart subcommand.
+func handleRestartCommand(args []string) {
+	if len(args) > 0 && (args[0] == "-h" || args[0] == "--help") {
+		fmt.Println("Usage: clasp restart [-p <port>]")
+		fmt.Println("")
+		fmt.Println("Stops a running CLASP proxy and starts it again in the background")
+		fmt.Println("with the same arguments. Only proxies started with -proxy-only can")
+		fmt.Println("be restarted; output goes to the proxy's log file.")
+		return
+	}
+
+	port, err := parsePortArg(args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Check the instance can be relaunched before stopping it
+	inst, err := statusline.FindInstance(port)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if inst.IsRunning && statusline.IsCLASPProcess(inst.PID) {
+		if inst.Executable == "" {
+			fmt.Fprintf(os.Stderr, "Error: instance on port %d did not record its command line; stop it and start it again manually\n", inst.Port)
+			os.Exit(1)
+		}
+		if !hasProxyOnlyArg(inst.Args) {
+			fmt.Fprintf(os.Stderr, "Error: instance on port %d is attached to Claude Code; only -proxy-only instances can be restarted\n", inst.Port)
+			os.Exit(1)
+		}
+		if statusline.HasMaskedArgs(inst.Args) {
+			fmt.Fprintf(os.Stderr, "Error: instance on port %d was started with -api-key or -auth-api-key, which are not recorded; stop it and start it again manually, or set the keys in the environment\n", inst.Port)
+			os.Exit(1)
+		}
+	}
+
+	stopped, err := stopInstance(inst.Port)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if stopped == nil {
+		fmt.Fprintln(os.Stderr, "Error: no running instance to restart")
+		os.Exit(1)
+	}
+
+	pid, err := relaunchInstance(stopped)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to restart: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("CLASP restarted on port %d (PID %d)\n", stopped.Port, pid)
+	fmt.Printf("Logs: %s\n", logging.GetLogPathForPort(stopped.Port))
+}
+
+// hasProxyOnlyArg reports whether args include the -proxy-only flag.
+func hasProxyOnlyArg(args []string) bool {
+	for _, arg := range args {
+		switch arg {
+		case "-proxy-only", "--proxy-only", "-proxy-only=true", "--proxy-only=true":
+			return true
+		}
+	}
+	return false
+}
+
+// relaunchInstance starts the stopped instance's command line in the
+// background and waits for it to report running on the same port.
+func relaunchInstance(inst *statusline.InstanceInfo) (int, error) {
+	logPath := logging.GetLogPathForPort(inst.Port)
+	if err := os.MkdirAll(filepath.Dir(logPath), 0o700); err != nil {
+		return 0, fmt.Errorf("creating log directory: %w", err)
+	}
+	logFile, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return 0, fmt.Errorf("opening log file: %w", err)
+	}
+	defer logFile.Close()
+
+	cmd := exec.Command(inst.Executable, inst.Args...)
+	cmd.Dir = inst.WorkDir
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	if err := cmd.Start(); err != nil {
+		return 0, err
+	}
+	pid := cmd.Process.Pid
+	if err := cmd.Process.Release(); err != nil {
+		return 0, err
+	}
+
+	deadline := time.Now().Add(restartTimeout)
+	for time.Now().Before(deadline) {
+		if status, err := statusline.ReadStatusFromPort(inst.Port); err == nil && status != nil && status.Running && status.PID == pid {
+			return pid, nil
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+	return pid, fmt.Errorf("process %d started but did not report running on port %d within %v", pid, inst.Port, restartTimeout)
+}
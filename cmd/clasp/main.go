@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"time"
 
 	"github.com/jedarden/clasp/internal/config"
 	"github.com/jedarden/clasp/internal/logging"
@@ -23,12 +24,36 @@ func main() {
 		case "profile":
 			handleProfileCommand(os.Args[2:])
 			return
+		case "setup":
+			handleSetupCommand(os.Args[2:])
+			return
+		case "config":
+			handleConfigCommand(os.Args[2:])
+			return
+		case "completion":
+			handleCompletionCommand(os.Args[2:])
+			return
 		case "status":
 			handleStatusCommand(os.Args[2:])
 			return
+		case "statusline":
+			handleStatuslineCommand(os.Args[2:])
+			return
+		case "stop":
+			handleStopCommand(os.Args[2:])
+			return
+		case "restart":
+			handleRestartCommand(os.Args[2:])
+			return
+		case "costs":
+			handleCostsCommand(os.Args[2:])
+			return
 		case "logs":
 			handleLogsCommand(os.Args[2:])
 			return
+		case "models":
+			handleModelsCommand(os.Args[2:])
+			return
 		case "use":
 			// Quick alias: clasp use <profile>
 			if len(os.Args) > 2 {
@@ -75,13 +100,16 @@ func main() {
 	// Profile selection logic:
 	// In proxy-only mode, skip profile selection entirely - config will come from env/flags
 	// 1. If --profile flag is set, use that profile
-	// 2. If TTY available, show profile selector TUI (with option to create new)
-	// 3. Non-TTY: use active profile or first available
-	selectedProfileName := selectProfile(flags.ProfileName, flags.ProxyOnly)
-
-	// Apply selected profile if we have one
-	// In proxy-only mode with no profile, skip this - config from env/flags is sufficient
-	applyProfile(selectedProfileName)
+	// 2. If the working directory (or a parent) has .clasp/profile or .clasp.json, use that
+	// 3. If TTY available, show profile selector TUI (with option to create new)
+	// 4. Non-TTY: use active profile or first available
+	if flags.ProfileName != "" || !applyProjectProfile() {
+		selectedProfileName := selectProfile(flags.ProfileName, flags.ProxyOnly)
+
+		// Apply selected profile if we have one
+		// In proxy-only mode with no profile, skip this - config from env/flags is sufficient
+		applyProfile(selectedProfileName)
+	}
 
 	if flags.ShowVersion {
 		fmt.Printf("CLASP %s\n", version)
@@ -104,7 +132,7 @@ func main() {
 
 	// Handle models command
 	if flags.ListModels {
-		if err := listAvailableModels(); err != nil {
+		if err := listAvailableModels(time.Duration(flags.ModelsTimeout)*time.Second, flags.ModelsJSON); err != nil {
 			log.Fatalf("[CLASP] Failed to list models: %v", err)
 		}
 		os.Exit(0)
@@ -125,9 +153,12 @@ func main() {
 	// Load .env file if it exists
 	loadEnvFiles()
 
-	// Try to load saved config from ~/.clasp/config.json
-	if err := setup.ApplyConfigToEnv(); err == nil {
-		log.Printf("[CLASP] Loaded configuration from %s", setup.GetConfigPath())
+	// Try to load saved config from ~/.clasp/config.json. It takes precedence
+	// over a YAML config file unless CLASP_CONFIG_FILE selects one explicitly.
+	if os.Getenv("CLASP_CONFIG_FILE") == "" {
+		if err := setup.ApplyConfigToEnv(); err == nil {
+			log.Printf("[CLASP] Loaded configuration from %s", setup.GetConfigPath())
+		}
 	}
 
 	// Check if setup is needed (no API keys configured)
@@ -190,6 +221,12 @@ func main() {
 		} else {
 			log.Printf("[CLASP] Debug logging enabled: %s", logging.GetDebugLogPath())
 		}
+	} else if cfg.DebugSampleRate > 0 {
+		if debugErr := logging.EnableDebugSampling(); debugErr != nil {
+			log.Printf("[CLASP] Warning: Could not enable debug sampling: %v", debugErr)
+		} else {
+			log.Printf("[CLASP] Debug sampling %.1f%% of requests to %s", cfg.DebugSampleRate*100, logging.GetDebugLogPath())
+		}
 	}
 
 	// Validate authentication configuration
@@ -210,5 +247,5 @@ func main() {
 	}
 
 	// Standard proxy-only mode
-	runProxyOnly(cfg)
+	runProxyOnly(cfg, flags)
 }
@@ -182,6 +182,38 @@ func selectProfile(profileFlag string, proxyOnly bool) string {
 	return selectedProfileName
 }
 
+// applyProjectProfile applies the profile selected by .clasp/profile or
+// .clasp.json in the working directory or its nearest parent that has one.
+// It reports whether a project profile was applied.
+func applyProjectProfile() bool {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return false
+	}
+	project, err := setup.FindProjectProfile(cwd)
+	if err != nil {
+		log.Fatalf("[CLASP] Invalid project profile: %v", err)
+	}
+	if project == nil {
+		return false
+	}
+
+	pm := setup.NewProfileManager()
+	profile, err := pm.ResolveProjectProfile(project)
+	if err != nil {
+		if project.Profile != "" {
+			log.Fatalf("[CLASP] Project profile in %s: %v", project.Dir, err)
+		}
+		log.Printf("[CLASP] Ignoring project settings: %v", err)
+		return false
+	}
+	if err := pm.ApplyProfileToEnv(profile); err != nil {
+		log.Fatalf("[CLASP] Failed to apply profile: %v", err)
+	}
+	log.Printf("[CLASP] Using project profile: %s (from %s)", profile.Name, project.Dir)
+	return true
+}
+
 // applyProfile applies the selected profile to the environment.
 func applyProfile(profileName string) {
 	if profileName == "" {
@@ -249,6 +249,13 @@ func (pc *PromptCache) removeOldest() {
 	}
 }
 
+// ResetStats zeroes the hit, miss and savings counters without evicting entries.
+func (pc *PromptCache) ResetStats() {
+	atomic.StoreInt64(&pc.hits, 0)
+	atomic.StoreInt64(&pc.misses, 0)
+	atomic.StoreInt64(&pc.savingsTokens, 0)
+}
+
 // Stats returns cache statistics.
 func (pc *PromptCache) Stats() PromptCacheStats {
 	pc.mu.RLock()
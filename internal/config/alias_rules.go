@@ -0,0 +1,100 @@
+// Package config provides configuration management for CLASP.
+package config
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// AliasRule maps every model name matching a pattern to a target model.
+// Rules are evaluated after exact aliases, in the order they were configured.
+type AliasRule struct {
+	Pattern string // Regular expression, or the glob it was built from
+	Target  string
+	re      *regexp.Regexp
+}
+
+// NewAliasRule compiles an alias rule. A glob pattern supports * (any run of
+// characters) and ? (one character). Matching is case-insensitive, like
+// exact aliases, and a glob must match the whole model name.
+func NewAliasRule(pattern, target string, glob bool) (AliasRule, error) {
+	if pattern == "" || target == "" {
+		return AliasRule{}, fmt.Errorf("alias rule needs a pattern and a target model")
+	}
+	expr := pattern
+	if glob {
+		expr = "^" + strings.NewReplacer(`\*`, ".*", `\?`, ".").Replace(regexp.QuoteMeta(pattern)) + "$"
+	}
+	re, err := regexp.Compile("(?i)" + expr)
+	if err != nil {
+		return AliasRule{}, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+	}
+	return AliasRule{Pattern: pattern, Target: target, re: re}, nil
+}
+
+// Match reports whether model matches the rule's pattern.
+func (r AliasRule) Match(model string) bool {
+	return r.re != nil && r.re.MatchString(model)
+}
+
+// parseAliasRules parses pattern=target rules separated by semicolons, e.g.
+// "^claude-3-.*haiku.*=gpt-4o-mini". The target follows the last "=", so
+// patterns may contain "=".
+func parseAliasRules(raw string, glob bool) ([]AliasRule, error) {
+	var rules []AliasRule
+	for _, entry := range strings.Split(raw, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		i := strings.LastIndex(entry, "=")
+		if i < 0 {
+			return nil, fmt.Errorf("%q is not a pattern=model rule", entry)
+		}
+		rule, err := NewAliasRule(strings.TrimSpace(entry[:i]), strings.TrimSpace(entry[i+1:]), glob)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// loadAliasRules loads pattern aliases from CLASP_ALIAS_REGEX and then
+// CLASP_ALIAS_GLOB.
+func loadAliasRules() ([]AliasRule, error) {
+	var rules []AliasRule
+	for _, source := range []struct {
+		envVar string
+		glob   bool
+	}{{"CLASP_ALIAS_REGEX", false}, {"CLASP_ALIAS_GLOB", true}} {
+		raw := os.Getenv(source.envVar)
+		if raw == "" {
+			continue
+		}
+		parsed, err := parseAliasRules(raw, source.glob)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", source.envVar, err)
+		}
+		rules = append(rules, parsed...)
+	}
+	return rules, nil
+}
+
+// isAliasTarget reports whether model is the target of an exact alias or an
+// alias rule.
+func (c *Config) isAliasTarget(model string) bool {
+	for _, target := range c.ModelAliases {
+		if target == model {
+			return true
+		}
+	}
+	for _, rule := range c.AliasRules {
+		if rule.Target == model {
+			return true
+		}
+	}
+	return false
+}
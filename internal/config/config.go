@@ -2,7 +2,10 @@
 package config
 
 import (
+	"encoding/json"
 	"fmt"
+	"net"
+	"net/url"
 	"os"
 	"strconv"
 	"strings"
@@ -26,12 +29,22 @@ const (
 	ProviderCustom     ProviderType = "custom"
 )
 
+// AllProviders lists every supported provider type.
+var AllProviders = []ProviderType{
+	ProviderOpenAI, ProviderAzure, ProviderOpenRouter, ProviderAnthropic, ProviderOllama, ProviderGemini,
+	ProviderDeepSeek, ProviderGrok, ProviderQwen, ProviderMiniMax, ProviderLiteLLM, ProviderCustom,
+}
+
 // TierConfig holds configuration for a specific model tier.
 type TierConfig struct {
 	Provider ProviderType
 	Model    string
 	APIKey   string
 	BaseURL  string
+	// HTTPTimeoutSec overrides HTTPClientTimeoutSec for this tier (0 = use the global timeout)
+	HTTPTimeoutSec int
+	// ExtraHeaders are added to this tier's upstream requests, overriding CLASP_EXTRA_HEADERS
+	ExtraHeaders map[string]string
 	// Fallback configuration
 	FallbackProvider ProviderType
 	FallbackModel    string
@@ -92,14 +105,48 @@ type Config struct {
 	FallbackAPIKey   string
 	FallbackBaseURL  string
 
+	// Fallback chain: providers tried in order after the fallback above (CLASP_FALLBACK_CHAIN).
+	// Only Provider and Model are set; see GetFallbackChainConfigs.
+	FallbackChain []TierConfig
+
+	// Latency-based failover: race the fallback when the primary has not responded in time
+	FallbackOnSlow bool
+	FallbackSlowMs int // Milliseconds to wait for the primary before racing the fallback (default: 10000)
+
+	// Sticky routing (pin a conversation to the provider that first served it)
+	StickyRoutingEnabled    bool
+	StickyRoutingHeader     string // Header carrying the conversation ID (default: X-CLASP-Conversation-ID)
+	StickyRoutingTTLSec     int    // Seconds a pin lasts after the conversation's last turn (default: 3600)
+	StickyRoutingMaxEntries int    // Maximum pinned conversations (default: 10000)
+
+	// Endpoint fallback (retry a model on the other OpenAI endpoint when its usual one is unsupported)
+	EndpointFallback bool
+
+	// Multi-region endpoints: alternative base URLs per provider (CLASP_<PROVIDER>_ENDPOINTS).
+	// Each request goes to the healthy endpoint with the lowest observed latency.
+	ProviderEndpoints map[ProviderType][]string
+
+	// Extra headers added to every upstream request (CLASP_EXTRA_HEADERS).
+	// Tiers can override them with CLASP_<TIER>_EXTRA_HEADERS.
+	ExtraHeaders map[string]string
+
 	// Server settings
 	Port     int
 	LogLevel string
 
+	// API routes
+	APIPathPrefix     string // Prefix of the messages and embeddings routes (default: /v1; "" mounts them at the root)
+	APIPathUnprefixed bool   // Also serve the routes without the prefix, e.g. /messages
+
 	// Debug settings
-	Debug          bool
-	DebugRequests  bool
-	DebugResponses bool
+	Debug           bool
+	DebugRequests   bool
+	DebugResponses  bool
+	DebugSampleRate float64 // Fraction of requests captured in full to debug.log (0.0-1.0)
+
+	// Audit log settings
+	AuditLog            string // JSONL file with one entry per completed request
+	AuditIncludeContent bool   // Also store prompts and responses, with secrets masked
 
 	// Rate limiting settings
 	RateLimitEnabled  bool
@@ -108,9 +155,20 @@ type Config struct {
 	RateLimitBurst    int // Burst allowance
 
 	// Cache settings
-	CacheEnabled bool
-	CacheMaxSize int // Maximum number of entries
-	CacheTTL     int // Time-to-live in seconds (0 = no expiry)
+	CacheEnabled   bool
+	CacheMaxSize   int    // Maximum number of entries
+	CacheTTL       int    // Time-to-live in seconds (0 = no expiry)
+	CacheStreaming bool   // Also cache streamed responses and replay them as SSE
+	CacheKey       string // Cache key strategy: "full" (default), "messages_only" or "normalized"
+
+	// Negative cache settings (upstream errors for requests that cannot succeed)
+	NegativeCacheEnabled bool
+	NegativeCacheTTL     int // Time-to-live in seconds
+
+	// Semantic cache settings (CacheMode "semantic")
+	CacheMode              string  // "exact" (default) or "semantic"
+	SemanticCacheThreshold float64 // Minimum cosine similarity for a semantic hit
+	SemanticCacheModel     string  // Embedding model used to embed prompts
 
 	// Prompt cache settings (simulates Anthropic cache_control for non-Anthropic backends)
 	PromptCacheEnabled bool
@@ -121,34 +179,125 @@ type Config struct {
 	AuthAPIKey                string
 	AuthAllowAnonymousHealth  bool
 	AuthAllowAnonymousMetrics bool
+	AuthAnonymousEndpoints    []string // Paths readable without an API key (nil keeps / anonymous)
+	AllowClientKeys           bool     // Accept per-request provider keys via X-CLASP-Provider-Key
+
+	// IP filtering
+	IPAllowlist       []string // CIDRs allowed to reach the proxy (empty allows all)
+	IPDenylist        []string // CIDRs rejected with 403
+	TrustProxyHeaders bool     // Use X-Forwarded-For / X-Real-IP for the client IP
+
+	// CORS
+	CORSOrigins []string // Origins allowed for browser clients ("*" allows any); empty disables CORS
 
 	// Queue settings
 	QueueEnabled        bool
-	QueueMaxSize        int // Maximum requests to queue
-	QueueMaxWaitSeconds int // Maximum time a request can wait
-	QueueRetryDelayMs   int // Delay between retries in milliseconds
-	QueueMaxRetries     int // Maximum retries per request
+	QueueMaxSize        int    // Maximum requests to queue
+	QueueMaxWaitSeconds int    // Maximum time a request can wait
+	QueueRetryDelayMs   int    // Delay between retries in milliseconds
+	QueueMaxRetries     int    // Maximum retries per request
+	QueuePersist        bool   // Persist queued requests to disk and replay them on startup
+	QueuePersistDir     string // Directory for persisted requests (default: ~/.clasp/queue)
+	QueueDLQDir         string // Directory for requests that exhausted their retries (default: ~/.clasp/dlq)
+
+	// Concurrency limit for in-flight /v1/messages requests (0 = unlimited)
+	MaxConcurrent int
 
 	// Circuit breaker settings
-	CircuitBreakerEnabled    bool
-	CircuitBreakerThreshold  int // Failures before opening
-	CircuitBreakerRecovery   int // Successes to close
-	CircuitBreakerTimeoutSec int // Timeout before half-open
+	CircuitBreakerEnabled     bool
+	CircuitBreakerThreshold   int // Failures before opening
+	CircuitBreakerRecovery    int // Successes to close
+	CircuitBreakerTimeoutSec  int // Timeout before half-open
+	CircuitBreakerHalfOpenMax int // Probe requests admitted at once while half-open
+
+	// Upstream retry settings
+	RetryMax         int // Maximum upstream attempts per request, including the first
+	RetryBaseDelayMs int // Backoff before the first retry, doubled on each retry
+	RetryMaxDelayMs  int // Ceiling for the backoff delay
+	RetryJitter      bool // Randomize each delay between 0 and the backoff (full jitter)
+	RetryBudgetRatio float64 // Retries allowed per upstream request across all clients (0 = unlimited)
 
 	// Health checker settings
-	HealthCheckEnabled       bool
-	HealthCheckIntervalSec   int // Interval between health checks (default: 30)
-	HealthCheckTimeoutSec    int // Timeout for each health check (default: 10)
+	HealthCheckEnabled        bool
+	HealthCheckIntervalSec    int  // Interval between health checks (default: 30)
+	HealthCheckTimeoutSec     int  // Timeout for each health check (default: 10)
+	HealthCheckUpstream       bool // Verify upstream connectivity in /health
+	HealthCheckUpstreamTTLSec int  // How long an upstream check result is reused (default: 30)
+
+	// Startup warmup
+	Warmup bool // Probe each configured provider at startup to open connections and check API keys
+
+	// Alerting
+	AlertWebhookURL string  // Receives JSON alerts for circuit breaker and budget events
+	AlertBudgetUSD  float64 // Spend that triggers a budget alert (0 = disabled)
 
 	// HTTP client settings
-	HTTPClientTimeoutSec int // Timeout for upstream requests (default: 300 = 5 minutes)
+	HTTPClientTimeoutSec int    // Timeout for upstream requests (default: 300 = 5 minutes)
+	RequestTimeoutMinSec int    // Lower bound for X-CLASP-Timeout overrides (default: 1)
+	RequestTimeoutMaxSec int    // Upper bound for X-CLASP-Timeout overrides (default: 3600)
+	SSEHeartbeatSeconds  int    // Idle seconds before an SSE keepalive comment is sent (0 = disabled)
+	UpstreamHTTPVersion  string // Upstream HTTP version: "auto" (default), "h1" or "h2"
+	StreamIncludeUsage   string // stream_options.include_usage: "auto" (default, per provider), "on" or "off"
+
+	// Body size limits (0 = unlimited)
+	MaxRequestBytes  int64 // Largest accepted /v1/messages request body (default: 32MB)
+	MaxResponseBytes int64 // Largest buffered non-streaming upstream response (default: 64MB)
 
 	// Model aliasing - map custom model names to provider models
 	ModelAliases map[string]string
+	AliasRules   []AliasRule // CLASP_ALIAS_REGEX / CLASP_ALIAS_GLOB, tried after exact aliases
 
 	// Compaction settings (Responses API previous_response_id chaining)
 	CompactionEnabled bool
-	SessionTimeoutSec int // Session TTL in seconds (default: 3600)
+	SessionTimeoutSec int    // Session TTL in seconds (default: 3600)
+	SessionFile       string // File the sessions are saved to across restarts ("" = in memory only)
+
+	// Responses API options
+	ResponsesStore            *bool  // store sent with Responses requests (nil = provider default)
+	ResponsesReasoningSummary string // reasoning.summary for reasoning models: "auto", "concise" or "detailed" ("" = not requested)
+
+	// Document handling for providers without file input support
+	DocumentFallback string // "text" (extract text) or "error" (reject request)
+
+	// Identity filtering of system prompts for non-Claude models
+	IdentityFilter string // "full" (default), "minimal" or "off"
+	IdentityFile   string // Custom identity rules (default: ~/.clasp/identity.json)
+
+	// anthropic-version handling
+	AnthropicVersion string // Default anthropic-version when the client sends none (default: 2023-06-01)
+	StrictVersion    bool   // Reject unsupported anthropic-version values
+
+	// Client headers in Anthropic passthrough mode
+	PassthroughForwardHeaders []string // Client headers forwarded upstream (default: anthropic-version, anthropic-beta)
+	PassthroughStripHeaders   []string // Headers never sent upstream, overriding the forward list (default: hop-by-hop headers)
+
+	// Request transformation hooks
+	HooksFile string // Hook chain applied to every request (default: ~/.clasp/hooks.json)
+
+	// Request parameters applied to every request
+	LogitBias map[string]float64 // Token ID -> bias (-100 to 100), OpenAI-family providers only
+	ForceJSON bool               // Request JSON output (response_format json_object) for every request
+	Seed      *int               // Default sampling seed when a request has none (nil = unset)
+
+	PresencePenalty  *float64 // Default presence_penalty (-2.0 to 2.0) when a request has none (nil = unset)
+	FrequencyPenalty *float64 // Default frequency_penalty (-2.0 to 2.0) when a request has none (nil = unset)
+
+	CustomTopK bool // Forward top_k to custom endpoints (default: false)
+
+	CustomParallelToolCalls bool // Forward parallel_tool_calls to custom endpoints (default: false)
+
+	// max_tokens capping to per-model output limits
+	DisableMaxTokensCap bool           // Forward max_tokens unchanged
+	MaxTokensLimits     map[string]int // Lowercased model name (CLASP_MAX_TOKENS_<MODEL>) -> output token limit
+
+	// Tool calling
+	ParallelToolCalls bool // Default parallel_tool_calls for requests with tools (default: true)
+	RepairToolJSON    bool // Hold streamed tool arguments and repair malformed JSON before emitting
+
+	// Response mapping
+	FinishReasonMap  map[string]string // Upstream finish_reason -> Anthropic stop_reason overrides
+	MultiChoice      string            // n>1 handling: "reject" (default), "warn" or "return"
+	ReasoningContent bool              // Emit reasoning_content as thinking for every model, not just reasoning models
 }
 
 // DefaultConfig returns the default configuration.
@@ -164,6 +313,7 @@ func DefaultConfig() *Config {
 		AzureAPIVersion:           "2024-02-15-preview",
 		Port:                      8080,
 		LogLevel:                  "info",
+		APIPathPrefix:             "/v1",
 		DefaultModel:              "gpt-4o",
 		RateLimitEnabled:          false,
 		RateLimitRequests:         60, // 60 requests per window (default)
@@ -172,6 +322,11 @@ func DefaultConfig() *Config {
 		CacheEnabled:              false,
 		CacheMaxSize:              1000, // Default 1000 entries
 		CacheTTL:                  3600, // Default 1 hour TTL
+		CacheMode:                 "exact",
+		CacheKey:                  "full",
+		NegativeCacheTTL:          30,
+		SemanticCacheThreshold:    0.95,
+		SemanticCacheModel:        "text-embedding-3-small",
 		PromptCacheEnabled:        false,
 		PromptCacheMaxSize:        100, // Default 100 cached prefixes
 		AuthEnabled:               false,
@@ -184,21 +339,55 @@ func DefaultConfig() *Config {
 		QueueRetryDelayMs:   1000,
 		QueueMaxRetries:     3,
 		// Circuit breaker defaults
-		CircuitBreakerEnabled:    false,
-		CircuitBreakerThreshold:  5,  // Open after 5 failures
-		CircuitBreakerRecovery:   2,  // Close after 2 successes
-		CircuitBreakerTimeoutSec: 30, // Try again after 30 seconds
+		CircuitBreakerEnabled:     false,
+		CircuitBreakerThreshold:   5,  // Open after 5 failures
+		CircuitBreakerRecovery:    2,  // Close after 2 successes
+		CircuitBreakerTimeoutSec:  30, // Try again after 30 seconds
+		CircuitBreakerHalfOpenMax: 1,  // One probe at a time while half-open
+		// Retry defaults
+		RetryMax:         3,
+		RetryBaseDelayMs: 500,
+		RetryMaxDelayMs:  10000,
+		RetryJitter:      true,
 		// Health checker defaults
 		HealthCheckEnabled:       true,
 		HealthCheckIntervalSec:   30, // Check every 30 seconds
 		HealthCheckTimeoutSec:    10, // 10 second timeout for checks
+		HealthCheckUpstreamTTLSec: 30, // Reuse upstream checks in /health for 30 seconds
 		// HTTP client defaults
 		HTTPClientTimeoutSec: 300, // 5 minutes for reasoning models
+		RequestTimeoutMinSec: 1,
+		RequestTimeoutMaxSec: 3600,
+		UpstreamHTTPVersion:  "auto",
+		StreamIncludeUsage:   "auto",
+		MaxRequestBytes:      32 << 20,
+		MaxResponseBytes:     64 << 20,
 		// Model aliases (empty by default)
 		ModelAliases: make(map[string]string),
 		// Compaction defaults
 		CompactionEnabled: false,
 		SessionTimeoutSec: 3600, // 1 hour
+		// Document defaults
+		DocumentFallback: "text",
+		IdentityFilter:   "full",
+		AnthropicVersion: "2023-06-01",
+		// Passthrough header defaults
+		PassthroughForwardHeaders: []string{"anthropic-version", "anthropic-beta"},
+		PassthroughStripHeaders: []string{
+			"Connection", "Keep-Alive", "Proxy-Authenticate", "Proxy-Authorization",
+			"TE", "Trailer", "Transfer-Encoding", "Upgrade",
+		},
+		// Tool calling defaults
+		ParallelToolCalls: true,
+		// Response mapping defaults
+		MultiChoice: "reject",
+		// Sticky routing defaults
+		StickyRoutingEnabled:    false,
+		StickyRoutingHeader:     "X-CLASP-Conversation-ID",
+		StickyRoutingTTLSec:     3600,  // 1 hour
+		StickyRoutingMaxEntries: 10000,
+		// Latency-based failover defaults
+		FallbackSlowMs: 10000, // 10 seconds
 	}
 }
 
@@ -280,10 +469,34 @@ func LoadFromEnv() (*Config, error) {
 		cfg.LogLevel = logLevel
 	}
 
+	// API routes
+	if prefix := os.Getenv("CLASP_API_PATH_PREFIX"); prefix != "" {
+		p, err := parseAPIPathPrefix(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CLASP_API_PATH_PREFIX: %w", err)
+		}
+		cfg.APIPathPrefix = p
+	}
+	cfg.APIPathUnprefixed = os.Getenv("CLASP_API_PATH_UNPREFIXED") == "true" || os.Getenv("CLASP_API_PATH_UNPREFIXED") == "1"
+
 	// Debug settings
 	cfg.Debug = os.Getenv("CLASP_DEBUG") == "true" || os.Getenv("CLASP_DEBUG") == "1"
 	cfg.DebugRequests = cfg.Debug || os.Getenv("CLASP_DEBUG_REQUESTS") == "true"
 	cfg.DebugResponses = cfg.Debug || os.Getenv("CLASP_DEBUG_RESPONSES") == "true"
+	if rate := os.Getenv("CLASP_DEBUG_SAMPLE_RATE"); rate != "" {
+		f, err := strconv.ParseFloat(rate, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CLASP_DEBUG_SAMPLE_RATE: %w", err)
+		}
+		if f < 0 || f > 1 {
+			return nil, fmt.Errorf("invalid CLASP_DEBUG_SAMPLE_RATE: %g (must be between 0.0 and 1.0)", f)
+		}
+		cfg.DebugSampleRate = f
+	}
+
+	// Audit log settings
+	cfg.AuditLog = os.Getenv("CLASP_AUDIT_LOG")
+	cfg.AuditIncludeContent = os.Getenv("CLASP_AUDIT_INCLUDE_CONTENT") == "true" || os.Getenv("CLASP_AUDIT_INCLUDE_CONTENT") == "1"
 
 	// Rate limiting settings
 	cfg.RateLimitEnabled = os.Getenv("CLASP_RATE_LIMIT") == "true" || os.Getenv("CLASP_RATE_LIMIT") == "1"
@@ -311,6 +524,43 @@ func LoadFromEnv() (*Config, error) {
 
 	// Cache settings
 	cfg.CacheEnabled = os.Getenv("CLASP_CACHE") == "true" || os.Getenv("CLASP_CACHE") == "1"
+	cfg.CacheStreaming = os.Getenv("CLASP_CACHE_STREAMING") == "true" || os.Getenv("CLASP_CACHE_STREAMING") == "1"
+	if mode := os.Getenv("CLASP_CACHE_MODE"); mode != "" {
+		if mode != "exact" && mode != "semantic" {
+			return nil, fmt.Errorf("invalid CLASP_CACHE_MODE: %s (must be exact or semantic)", mode)
+		}
+		cfg.CacheMode = mode
+	}
+	if key := os.Getenv("CLASP_CACHE_KEY"); key != "" {
+		if key != "full" && key != "messages_only" && key != "normalized" {
+			return nil, fmt.Errorf("invalid CLASP_CACHE_KEY: %s (must be full, messages_only or normalized)", key)
+		}
+		cfg.CacheKey = key
+	}
+	cfg.NegativeCacheEnabled = os.Getenv("CLASP_NEGATIVE_CACHE") == "true" || os.Getenv("CLASP_NEGATIVE_CACHE") == "1"
+	if ttl := os.Getenv("CLASP_NEGATIVE_CACHE_TTL"); ttl != "" {
+		t, err := strconv.Atoi(ttl)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CLASP_NEGATIVE_CACHE_TTL: %w", err)
+		}
+		if t <= 0 {
+			return nil, fmt.Errorf("invalid CLASP_NEGATIVE_CACHE_TTL: %d (must be positive)", t)
+		}
+		cfg.NegativeCacheTTL = t
+	}
+	if threshold := os.Getenv("CLASP_SEMANTIC_CACHE_THRESHOLD"); threshold != "" {
+		f, err := strconv.ParseFloat(threshold, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CLASP_SEMANTIC_CACHE_THRESHOLD: %w", err)
+		}
+		if f <= 0 || f > 1 {
+			return nil, fmt.Errorf("invalid CLASP_SEMANTIC_CACHE_THRESHOLD: %g (must be greater than 0.0 and at most 1.0)", f)
+		}
+		cfg.SemanticCacheThreshold = f
+	}
+	if model := os.Getenv("CLASP_SEMANTIC_CACHE_MODEL"); model != "" {
+		cfg.SemanticCacheModel = model
+	}
 	if maxSize := os.Getenv("CLASP_CACHE_MAX_SIZE"); maxSize != "" {
 		m, err := strconv.Atoi(maxSize)
 		if err != nil {
@@ -345,6 +595,40 @@ func LoadFromEnv() (*Config, error) {
 	if os.Getenv("CLASP_AUTH_ALLOW_ANONYMOUS_METRICS") == "true" || os.Getenv("CLASP_AUTH_ALLOW_ANONYMOUS_METRICS") == "1" {
 		cfg.AuthAllowAnonymousMetrics = true
 	}
+	if raw := os.Getenv("CLASP_AUTH_ANONYMOUS_ENDPOINTS"); raw != "" {
+		paths, err := parsePathList(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CLASP_AUTH_ANONYMOUS_ENDPOINTS: %w", err)
+		}
+		cfg.AuthAnonymousEndpoints = paths
+	}
+	cfg.AllowClientKeys = os.Getenv("CLASP_ALLOW_CLIENT_KEYS") == "true" || os.Getenv("CLASP_ALLOW_CLIENT_KEYS") == "1"
+
+	// IP filtering
+	if raw := os.Getenv("CLASP_IP_ALLOWLIST"); raw != "" {
+		cidrs, err := parseCIDRList(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CLASP_IP_ALLOWLIST: %w", err)
+		}
+		cfg.IPAllowlist = cidrs
+	}
+	if raw := os.Getenv("CLASP_IP_DENYLIST"); raw != "" {
+		cidrs, err := parseCIDRList(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CLASP_IP_DENYLIST: %w", err)
+		}
+		cfg.IPDenylist = cidrs
+	}
+	cfg.TrustProxyHeaders = os.Getenv("CLASP_TRUST_PROXY_HEADERS") == "true" || os.Getenv("CLASP_TRUST_PROXY_HEADERS") == "1"
+
+	// CORS
+	if raw := os.Getenv("CLASP_CORS_ORIGINS"); raw != "" {
+		for _, origin := range strings.Split(raw, ",") {
+			if origin = strings.TrimSuffix(strings.TrimSpace(origin), "/"); origin != "" {
+				cfg.CORSOrigins = append(cfg.CORSOrigins, origin)
+			}
+		}
+	}
 
 	// Queue settings
 	cfg.QueueEnabled = os.Getenv("CLASP_QUEUE") == "true" || os.Getenv("CLASP_QUEUE") == "1"
@@ -376,11 +660,35 @@ func LoadFromEnv() (*Config, error) {
 		}
 		cfg.QueueMaxRetries = m
 	}
+	cfg.QueuePersist = os.Getenv("CLASP_QUEUE_PERSIST") == "true" || os.Getenv("CLASP_QUEUE_PERSIST") == "1"
+	if dir := os.Getenv("CLASP_QUEUE_PERSIST_DIR"); dir != "" {
+		cfg.QueuePersistDir = dir
+	}
+	if dir := os.Getenv("CLASP_QUEUE_DLQ_DIR"); dir != "" {
+		cfg.QueueDLQDir = dir
+	}
+
+	// Concurrency limit
+	if maxConcurrent := os.Getenv("CLASP_MAX_CONCURRENT"); maxConcurrent != "" {
+		m, err := strconv.Atoi(maxConcurrent)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CLASP_MAX_CONCURRENT: %w", err)
+		}
+		if m < 0 {
+			return nil, fmt.Errorf("invalid CLASP_MAX_CONCURRENT: %d (must be 0 or greater)", m)
+		}
+		cfg.MaxConcurrent = m
+	}
 
 	// Model aliasing - load aliases from environment
 	// Pattern: CLASP_ALIAS_<alias>=<target_model>
 	// Also supports: CLASP_MODEL_ALIASES=alias1:model1,alias2:model2
 	cfg.ModelAliases = loadModelAliases()
+	rules, err := loadAliasRules()
+	if err != nil {
+		return nil, err
+	}
+	cfg.AliasRules = rules
 
 	// Circuit breaker settings
 	cfg.CircuitBreakerEnabled = os.Getenv("CLASP_CIRCUIT_BREAKER") == "true" || os.Getenv("CLASP_CIRCUIT_BREAKER") == "1"
@@ -405,6 +713,55 @@ func LoadFromEnv() (*Config, error) {
 		}
 		cfg.CircuitBreakerTimeoutSec = t
 	}
+	if halfOpenMax := os.Getenv("CLASP_CB_HALF_OPEN_MAX"); halfOpenMax != "" {
+		n, err := strconv.Atoi(halfOpenMax)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CLASP_CB_HALF_OPEN_MAX: %w", err)
+		}
+		if n < 1 {
+			return nil, fmt.Errorf("invalid CLASP_CB_HALF_OPEN_MAX: %d (must be at least 1)", n)
+		}
+		cfg.CircuitBreakerHalfOpenMax = n
+	}
+
+	// Retry settings
+	if retryMax := os.Getenv("CLASP_RETRY_MAX"); retryMax != "" {
+		n, err := strconv.Atoi(retryMax)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CLASP_RETRY_MAX: %w", err)
+		}
+		if n < 1 {
+			return nil, fmt.Errorf("invalid CLASP_RETRY_MAX: %d (must be at least 1)", n)
+		}
+		cfg.RetryMax = n
+	}
+	if baseDelay := os.Getenv("CLASP_RETRY_BASE_DELAY_MS"); baseDelay != "" {
+		d, err := strconv.Atoi(baseDelay)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CLASP_RETRY_BASE_DELAY_MS: %w", err)
+		}
+		cfg.RetryBaseDelayMs = d
+	}
+	if maxDelay := os.Getenv("CLASP_RETRY_MAX_DELAY_MS"); maxDelay != "" {
+		d, err := strconv.Atoi(maxDelay)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CLASP_RETRY_MAX_DELAY_MS: %w", err)
+		}
+		cfg.RetryMaxDelayMs = d
+	}
+	if os.Getenv("CLASP_RETRY_JITTER") == "false" || os.Getenv("CLASP_RETRY_JITTER") == "0" {
+		cfg.RetryJitter = false
+	}
+	if ratio := os.Getenv("CLASP_RETRY_BUDGET_RATIO"); ratio != "" {
+		r, err := strconv.ParseFloat(ratio, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CLASP_RETRY_BUDGET_RATIO: %w", err)
+		}
+		if r < 0 {
+			return nil, fmt.Errorf("invalid CLASP_RETRY_BUDGET_RATIO: %g (must be 0 or greater)", r)
+		}
+		cfg.RetryBudgetRatio = r
+	}
 
 	// Health checker settings
 	if healthCheck := os.Getenv("CLASP_HEALTH_CHECK"); healthCheck != "" {
@@ -424,6 +781,30 @@ func LoadFromEnv() (*Config, error) {
 		}
 		cfg.HealthCheckTimeoutSec = t
 	}
+	cfg.Warmup = os.Getenv("CLASP_WARMUP") == "true" || os.Getenv("CLASP_WARMUP") == "1"
+	if upstream := os.Getenv("CLASP_HEALTH_CHECK_UPSTREAM"); upstream != "" {
+		cfg.HealthCheckUpstream = upstream == "true" || upstream == "1"
+	}
+	if ttl := os.Getenv("CLASP_HEALTH_CHECK_UPSTREAM_TTL"); ttl != "" {
+		t, err := strconv.Atoi(ttl)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CLASP_HEALTH_CHECK_UPSTREAM_TTL: %w", err)
+		}
+		cfg.HealthCheckUpstreamTTLSec = t
+	}
+
+	// Alerting settings
+	cfg.AlertWebhookURL = os.Getenv("CLASP_ALERT_WEBHOOK_URL")
+	if budget := os.Getenv("CLASP_ALERT_BUDGET_USD"); budget != "" {
+		b, err := strconv.ParseFloat(budget, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CLASP_ALERT_BUDGET_USD: %w", err)
+		}
+		if b < 0 {
+			return nil, fmt.Errorf("invalid CLASP_ALERT_BUDGET_USD: %s (must be 0 or greater)", budget)
+		}
+		cfg.AlertBudgetUSD = b
+	}
 
 	// HTTP client settings
 	if httpTimeout := os.Getenv("CLASP_HTTP_TIMEOUT"); httpTimeout != "" {
@@ -433,9 +814,65 @@ func LoadFromEnv() (*Config, error) {
 		}
 		cfg.HTTPClientTimeoutSec = t
 	}
+	if version := os.Getenv("CLASP_UPSTREAM_HTTP_VERSION"); version != "" {
+		switch version {
+		case "auto", "h1", "h2":
+			cfg.UpstreamHTTPVersion = version
+		default:
+			return nil, fmt.Errorf("invalid CLASP_UPSTREAM_HTTP_VERSION: %q (expected \"auto\", \"h1\" or \"h2\")", version)
+		}
+	}
+	if minTimeout := os.Getenv("CLASP_REQUEST_TIMEOUT_MIN"); minTimeout != "" {
+		t, err := strconv.Atoi(minTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CLASP_REQUEST_TIMEOUT_MIN: %w", err)
+		}
+		cfg.RequestTimeoutMinSec = t
+	}
+	if maxTimeout := os.Getenv("CLASP_REQUEST_TIMEOUT_MAX"); maxTimeout != "" {
+		t, err := strconv.Atoi(maxTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CLASP_REQUEST_TIMEOUT_MAX: %w", err)
+		}
+		cfg.RequestTimeoutMaxSec = t
+	}
+	if heartbeat := os.Getenv("CLASP_SSE_HEARTBEAT_SECONDS"); heartbeat != "" {
+		t, err := strconv.Atoi(heartbeat)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CLASP_SSE_HEARTBEAT_SECONDS: %w", err)
+		}
+		cfg.SSEHeartbeatSeconds = t
+	}
+	if includeUsage := os.Getenv("CLASP_STREAM_INCLUDE_USAGE"); includeUsage != "" {
+		if includeUsage != "auto" && includeUsage != "on" && includeUsage != "off" {
+			return nil, fmt.Errorf("invalid CLASP_STREAM_INCLUDE_USAGE: %s (must be auto, on or off)", includeUsage)
+		}
+		cfg.StreamIncludeUsage = includeUsage
+	}
+	if maxRequest := os.Getenv("CLASP_MAX_REQUEST_BYTES"); maxRequest != "" {
+		n, err := strconv.ParseInt(maxRequest, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CLASP_MAX_REQUEST_BYTES: %w", err)
+		}
+		cfg.MaxRequestBytes = n
+	}
+	if maxResponse := os.Getenv("CLASP_MAX_RESPONSE_BYTES"); maxResponse != "" {
+		n, err := strconv.ParseInt(maxResponse, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CLASP_MAX_RESPONSE_BYTES: %w", err)
+		}
+		cfg.MaxResponseBytes = n
+	}
+	if cfg.RequestTimeoutMinSec > cfg.RequestTimeoutMaxSec {
+		return nil, fmt.Errorf("invalid request timeout bounds: CLASP_REQUEST_TIMEOUT_MIN (%d) exceeds CLASP_REQUEST_TIMEOUT_MAX (%d)", cfg.RequestTimeoutMinSec, cfg.RequestTimeoutMaxSec)
+	}
 
 	// Compaction settings
 	cfg.CompactionEnabled = os.Getenv("CLASP_COMPACTION") == "true" || os.Getenv("CLASP_COMPACTION") == "1"
+	if continuity := os.Getenv("CLASP_RESPONSES_CONTINUITY"); continuity == "true" || continuity == "1" {
+		cfg.CompactionEnabled = true
+	}
+	cfg.SessionFile = os.Getenv("CLASP_RESPONSES_CONTINUITY_FILE")
 	if sessionTimeout := os.Getenv("CLASP_SESSION_TIMEOUT"); sessionTimeout != "" {
 		t, err := strconv.Atoi(sessionTimeout)
 		if err != nil {
@@ -444,11 +881,149 @@ func LoadFromEnv() (*Config, error) {
 		cfg.SessionTimeoutSec = t
 	}
 
+	// Responses API options
+	if store := os.Getenv("CLASP_RESPONSES_STORE"); store != "" {
+		b := store == "true" || store == "1"
+		if !b && cfg.CompactionEnabled {
+			return nil, fmt.Errorf("invalid CLASP_RESPONSES_STORE: false (CLASP_COMPACTION and CLASP_RESPONSES_CONTINUITY continue from stored responses)")
+		}
+		cfg.ResponsesStore = &b
+	}
+	if summary := os.Getenv("CLASP_RESPONSES_REASONING_SUMMARY"); summary != "" {
+		switch summary {
+		case "auto", "concise", "detailed":
+			cfg.ResponsesReasoningSummary = summary
+		default:
+			return nil, fmt.Errorf("invalid CLASP_RESPONSES_REASONING_SUMMARY: %q (expected \"auto\", \"concise\" or \"detailed\")", summary)
+		}
+	}
+
+	// Document handling settings
+	if fallback := os.Getenv("CLASP_DOCUMENT_FALLBACK"); fallback != "" {
+		switch fallback {
+		case "text", "error":
+			cfg.DocumentFallback = fallback
+		default:
+			return nil, fmt.Errorf("invalid CLASP_DOCUMENT_FALLBACK: %q (expected \"text\" or \"error\")", fallback)
+		}
+	}
+
+	// Identity filtering settings
+	if mode := os.Getenv("CLASP_IDENTITY_FILTER"); mode != "" {
+		switch mode {
+		case "off", "minimal", "full":
+			cfg.IdentityFilter = mode
+		default:
+			return nil, fmt.Errorf("invalid CLASP_IDENTITY_FILTER: %q (expected \"off\", \"minimal\" or \"full\")", mode)
+		}
+	}
+	if path := os.Getenv("CLASP_IDENTITY_FILE"); path != "" {
+		cfg.IdentityFile = path
+	}
+	if path := os.Getenv("CLASP_HOOKS_FILE"); path != "" {
+		cfg.HooksFile = path
+	}
+
+	// anthropic-version settings
+	if version := os.Getenv("CLASP_ANTHROPIC_VERSION"); version != "" {
+		cfg.AnthropicVersion = version
+	}
+	cfg.StrictVersion = os.Getenv("CLASP_STRICT_VERSION") == "true" || os.Getenv("CLASP_STRICT_VERSION") == "1"
+
+	// Passthrough header forwarding: comma-separated header names
+	if raw := os.Getenv("CLASP_PASSTHROUGH_FORWARD_HEADERS"); raw != "" {
+		cfg.PassthroughForwardHeaders = parseHeaderNames(raw)
+	}
+	if raw := os.Getenv("CLASP_PASSTHROUGH_STRIP_HEADERS"); raw != "" {
+		cfg.PassthroughStripHeaders = parseHeaderNames(raw)
+	}
+
+	// Logit bias: JSON object mapping token IDs to bias values
+	if raw := os.Getenv("CLASP_LOGIT_BIAS"); raw != "" {
+		bias, err := parseLogitBias(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CLASP_LOGIT_BIAS: %w", err)
+		}
+		cfg.LogitBias = bias
+	}
+
+	cfg.ForceJSON = os.Getenv("CLASP_FORCE_JSON") == "true" || os.Getenv("CLASP_FORCE_JSON") == "1"
+	if seed := os.Getenv("CLASP_SEED"); seed != "" {
+		n, err := strconv.Atoi(seed)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CLASP_SEED: %w", err)
+		}
+		cfg.Seed = &n
+	}
+	if presence := os.Getenv("CLASP_PRESENCE_PENALTY"); presence != "" {
+		f, err := strconv.ParseFloat(presence, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CLASP_PRESENCE_PENALTY: %w", err)
+		}
+		if f < -2 || f > 2 {
+			return nil, fmt.Errorf("invalid CLASP_PRESENCE_PENALTY: %g (must be between -2.0 and 2.0)", f)
+		}
+		cfg.PresencePenalty = &f
+	}
+	if frequency := os.Getenv("CLASP_FREQUENCY_PENALTY"); frequency != "" {
+		f, err := strconv.ParseFloat(frequency, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CLASP_FREQUENCY_PENALTY: %w", err)
+		}
+		if f < -2 || f > 2 {
+			return nil, fmt.Errorf("invalid CLASP_FREQUENCY_PENALTY: %g (must be between -2.0 and 2.0)", f)
+		}
+		cfg.FrequencyPenalty = &f
+	}
+	cfg.CustomTopK = os.Getenv("CLASP_CUSTOM_TOP_K") == "true" || os.Getenv("CLASP_CUSTOM_TOP_K") == "1"
+	cfg.CustomParallelToolCalls = os.Getenv("CLASP_CUSTOM_PARALLEL_TOOL_CALLS") == "true" || os.Getenv("CLASP_CUSTOM_PARALLEL_TOOL_CALLS") == "1"
+
+	// max_tokens capping: CLASP_MAX_TOKENS_<MODEL>=N extends the limit table
+	cfg.DisableMaxTokensCap = os.Getenv("CLASP_DISABLE_MAX_TOKENS_CAP") == "true" || os.Getenv("CLASP_DISABLE_MAX_TOKENS_CAP") == "1"
+	limits, err := loadMaxTokensLimits()
+	if err != nil {
+		return nil, err
+	}
+	cfg.MaxTokensLimits = limits
+
+	// Finish reason overrides: CLASP_FINISH_REASON_MAP=eos:end_turn,safety:refusal
+	if raw := os.Getenv("CLASP_FINISH_REASON_MAP"); raw != "" {
+		m, err := parseKeyValueList(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CLASP_FINISH_REASON_MAP: %w", err)
+		}
+		cfg.FinishReasonMap = m
+	}
+
+	if mode := os.Getenv("CLASP_MULTI_CHOICE"); mode != "" {
+		switch mode {
+		case "reject", "warn", "return":
+			cfg.MultiChoice = mode
+		default:
+			return nil, fmt.Errorf("invalid CLASP_MULTI_CHOICE: %q (expected \"reject\", \"warn\" or \"return\")", mode)
+		}
+	}
+
+	cfg.ReasoningContent = os.Getenv("CLASP_REASONING_CONTENT") == "true" || os.Getenv("CLASP_REASONING_CONTENT") == "1"
+
+	// Parallel tool calls (enabled unless explicitly disabled)
+	if parallel := os.Getenv("CLASP_PARALLEL_TOOL_CALLS"); parallel != "" {
+		cfg.ParallelToolCalls = parallel == "true" || parallel == "1"
+	}
+	cfg.RepairToolJSON = os.Getenv("CLASP_REPAIR_TOOL_JSON") == "true" || os.Getenv("CLASP_REPAIR_TOOL_JSON") == "1"
+
 	// Multi-provider routing settings
 	cfg.MultiProviderEnabled = os.Getenv("CLASP_MULTI_PROVIDER") == "true" || os.Getenv("CLASP_MULTI_PROVIDER") == "1"
-	cfg.TierOpus = loadTierConfig("OPUS", cfg)
-	cfg.TierSonnet = loadTierConfig("SONNET", cfg)
-	cfg.TierHaiku = loadTierConfig("HAIKU", cfg)
+	for _, tier := range []struct {
+		name string
+		dest **TierConfig
+	}{{"OPUS", &cfg.TierOpus}, {"SONNET", &cfg.TierSonnet}, {"HAIKU", &cfg.TierHaiku}} {
+		tierCfg, err := loadTierConfig(tier.name, cfg)
+		if err != nil {
+			return nil, err
+		}
+		*tier.dest = tierCfg
+	}
 
 	// Fallback routing settings
 	cfg.FallbackEnabled = os.Getenv("CLASP_FALLBACK") == "true" || os.Getenv("CLASP_FALLBACK") == "1"
@@ -475,6 +1050,77 @@ func LoadFromEnv() (*Config, error) {
 		}
 	}
 
+	// Fallback chain: CLASP_FALLBACK_CHAIN=openrouter:gpt-4o,custom:llama3.1
+	if raw := os.Getenv("CLASP_FALLBACK_CHAIN"); raw != "" {
+		chain, err := parseFallbackChain(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CLASP_FALLBACK_CHAIN: %w", err)
+		}
+		cfg.FallbackChain = chain
+	}
+
+	// Latency-based failover settings
+	cfg.FallbackOnSlow = os.Getenv("CLASP_FALLBACK_ON_SLOW") == "true" || os.Getenv("CLASP_FALLBACK_ON_SLOW") == "1"
+	if slowMs := os.Getenv("CLASP_FALLBACK_SLOW_MS"); slowMs != "" {
+		ms, err := strconv.Atoi(slowMs)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CLASP_FALLBACK_SLOW_MS: %w", err)
+		}
+		if ms <= 0 {
+			return nil, fmt.Errorf("invalid CLASP_FALLBACK_SLOW_MS: %d (must be at least 1)", ms)
+		}
+		cfg.FallbackSlowMs = ms
+	}
+
+	// Sticky routing settings
+	cfg.StickyRoutingEnabled = os.Getenv("CLASP_STICKY_ROUTING") == "true" || os.Getenv("CLASP_STICKY_ROUTING") == "1"
+	if header := os.Getenv("CLASP_STICKY_ROUTING_HEADER"); header != "" {
+		cfg.StickyRoutingHeader = header
+	}
+	if ttl := os.Getenv("CLASP_STICKY_ROUTING_TTL"); ttl != "" {
+		t, err := strconv.Atoi(ttl)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CLASP_STICKY_ROUTING_TTL: %w", err)
+		}
+		cfg.StickyRoutingTTLSec = t
+	}
+	if maxEntries := os.Getenv("CLASP_STICKY_ROUTING_MAX_ENTRIES"); maxEntries != "" {
+		n, err := strconv.Atoi(maxEntries)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CLASP_STICKY_ROUTING_MAX_ENTRIES: %w", err)
+		}
+		cfg.StickyRoutingMaxEntries = n
+	}
+
+	// Endpoint fallback between Chat Completions and the Responses API
+	cfg.EndpointFallback = os.Getenv("CLASP_ENDPOINT_FALLBACK") == "true" || os.Getenv("CLASP_ENDPOINT_FALLBACK") == "1"
+
+	// Multi-region endpoints
+	for _, p := range AllProviders {
+		envVar := "CLASP_" + strings.ToUpper(string(p)) + "_ENDPOINTS"
+		raw := os.Getenv(envVar)
+		if raw == "" {
+			continue
+		}
+		endpoints, err := parseEndpointList(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", envVar, err)
+		}
+		if cfg.ProviderEndpoints == nil {
+			cfg.ProviderEndpoints = make(map[ProviderType][]string)
+		}
+		cfg.ProviderEndpoints[p] = endpoints
+	}
+
+	// Extra upstream headers
+	if raw := os.Getenv("CLASP_EXTRA_HEADERS"); raw != "" {
+		headers, err := parseExtraHeaders(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CLASP_EXTRA_HEADERS: %w", err)
+		}
+		cfg.ExtraHeaders = headers
+	}
+
 	// Auto-detect provider from available API keys if not explicitly set
 	if os.Getenv("PROVIDER") == "" {
 		cfg.Provider = detectProvider(cfg)
@@ -531,25 +1177,233 @@ func detectProvider(cfg *Config) ProviderType {
 	return ProviderOpenAI // Default
 }
 
+// parseEndpointList parses a comma-separated list of http(s) base URLs.
+func parseEndpointList(raw string) ([]string, error) {
+	var endpoints []string
+	for _, endpoint := range strings.Split(raw, ",") {
+		endpoint = strings.TrimSuffix(strings.TrimSpace(endpoint), "/")
+		if endpoint == "" {
+			continue
+		}
+		u, err := url.Parse(endpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("%q is not an http(s) URL", endpoint)
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+	if len(endpoints) == 0 {
+		return nil, fmt.Errorf("no endpoints given")
+	}
+	return endpoints, nil
+}
+
+// parseFallbackChain parses a comma-separated list of provider:model hops.
+// The model is everything after the first colon, so Ollama tags such as
+// "ollama:llama3.1:8b" are kept intact. The model may be empty.
+func parseFallbackChain(raw string) ([]TierConfig, error) {
+	var chain []TierConfig
+	for _, entry := range strings.Split(raw, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		providerName, model, _ := strings.Cut(entry, ":")
+		p := ProviderType(strings.TrimSpace(providerName))
+		if !isKnownProvider(p) {
+			return nil, fmt.Errorf("unknown provider %q", providerName)
+		}
+		chain = append(chain, TierConfig{Provider: p, Model: strings.TrimSpace(model)})
+	}
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("no providers in %q", raw)
+	}
+	return chain, nil
+}
+
+func isKnownProvider(p ProviderType) bool {
+	for _, known := range AllProviders {
+		if p == known {
+			return true
+		}
+	}
+	return false
+}
+
+// parseCIDRList parses a comma-separated list of CIDRs. Bare IP addresses are
+// accepted and converted to single-host CIDRs.
+// parsePathList parses a comma-separated list of URL paths.
+func parsePathList(raw string) ([]string, error) {
+	paths := []string{}
+	for _, entry := range strings.Split(raw, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.HasPrefix(entry, "/") {
+			return nil, fmt.Errorf("%q is not a path starting with /", entry)
+		}
+		paths = append(paths, entry)
+	}
+	return paths, nil
+}
+
+func parseCIDRList(raw string) ([]string, error) {
+	var cidrs []string
+	for _, entry := range strings.Split(raw, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("%q is not an IP address or CIDR", entry)
+			}
+			if ip.To4() != nil {
+				entry += "/32"
+			} else {
+				entry += "/128"
+			}
+		}
+		if _, _, err := net.ParseCIDR(entry); err != nil {
+			return nil, fmt.Errorf("%q is not an IP address or CIDR", entry)
+		}
+		cidrs = append(cidrs, entry)
+	}
+	return cidrs, nil
+}
+
+// parseKeyValueList parses a comma-separated list of key:value pairs.
+func parseKeyValueList(raw string) (map[string]string, error) {
+	result := make(map[string]string)
+	for _, pair := range strings.Split(raw, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(pair, ":")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if !ok || key == "" || value == "" {
+			return nil, fmt.Errorf("expected key:value, got %q", pair)
+		}
+		result[key] = value
+	}
+	return result, nil
+}
+
+// parseLogitBias parses a JSON object of token ID to bias, e.g. {"50256": -100}.
+func parseLogitBias(raw string) (map[string]float64, error) {
+	var bias map[string]float64
+	if err := json.Unmarshal([]byte(raw), &bias); err != nil {
+		return nil, err
+	}
+	for token, value := range bias {
+		if _, err := strconv.Atoi(token); err != nil {
+			return nil, fmt.Errorf("token ID %q is not an integer", token)
+		}
+		if value < -100 || value > 100 {
+			return nil, fmt.Errorf("bias %v for token %s is outside [-100, 100]", value, token)
+		}
+	}
+	return bias, nil
+}
+
+// parseAPIPathPrefix normalizes a route prefix to a leading slash and no
+// trailing slash. "/" means no prefix.
+func parseAPIPathPrefix(raw string) (string, error) {
+	prefix := strings.Trim(strings.TrimSpace(raw), "/")
+	if strings.ContainsAny(prefix, " ?#") {
+		return "", fmt.Errorf("%q is not a URL path", raw)
+	}
+	if prefix == "" {
+		return "", nil
+	}
+	return "/" + prefix, nil
+}
+
+// parseHeaderNames parses a comma-separated list of header names.
+func parseHeaderNames(raw string) []string {
+	var names []string
+	for _, name := range strings.Split(raw, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// parseExtraHeaders parses upstream headers given as a JSON object, e.g.
+// {"X-Title": "My App"}, or as key=value pairs separated by semicolons.
+func parseExtraHeaders(raw string) (map[string]string, error) {
+	headers := make(map[string]string)
+	if strings.HasPrefix(strings.TrimSpace(raw), "{") {
+		if err := json.Unmarshal([]byte(raw), &headers); err != nil {
+			return nil, err
+		}
+	} else {
+		for _, pair := range strings.Split(raw, ";") {
+			if strings.TrimSpace(pair) == "" {
+				continue
+			}
+			name, value, ok := strings.Cut(pair, "=")
+			if !ok {
+				return nil, fmt.Errorf("%q is not a key=value pair", strings.TrimSpace(pair))
+			}
+			headers[strings.TrimSpace(name)] = strings.TrimSpace(value)
+		}
+	}
+	for name := range headers {
+		if name == "" || strings.ContainsAny(name, " \t:") {
+			return nil, fmt.Errorf("%q is not a valid header name", name)
+		}
+	}
+	return headers, nil
+}
+
 // loadTierConfig loads tier-specific configuration from environment variables.
 // Pattern: CLASP_<TIER>_PROVIDER, CLASP_<TIER>_MODEL, CLASP_<TIER>_API_KEY, CLASP_<TIER>_BASE_URL
+// Timeout: CLASP_<TIER>_HTTP_TIMEOUT (seconds, overrides CLASP_HTTP_TIMEOUT for the tier)
+// Headers: CLASP_<TIER>_EXTRA_HEADERS (same format as CLASP_EXTRA_HEADERS)
 // Fallback: CLASP_<TIER>_FALLBACK_PROVIDER, CLASP_<TIER>_FALLBACK_MODEL, etc.
-func loadTierConfig(tier string, cfg *Config) *TierConfig {
+func loadTierConfig(tier string, cfg *Config) (*TierConfig, error) {
 	provider := os.Getenv("CLASP_" + tier + "_PROVIDER")
 	model := os.Getenv("CLASP_" + tier + "_MODEL")
 	apiKey := os.Getenv("CLASP_" + tier + "_API_KEY")
 	baseURL := os.Getenv("CLASP_" + tier + "_BASE_URL")
 
-	// If no provider specified for this tier, return nil
-	if provider == "" && model == "" {
-		return nil
+	var httpTimeout int
+	if timeout := os.Getenv("CLASP_" + tier + "_HTTP_TIMEOUT"); timeout != "" {
+		t, err := strconv.Atoi(timeout)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CLASP_%s_HTTP_TIMEOUT: %w", tier, err)
+		}
+		if t < 0 {
+			return nil, fmt.Errorf("invalid CLASP_%s_HTTP_TIMEOUT: %d (must be 0 or greater)", tier, t)
+		}
+		httpTimeout = t
+	}
+
+	var extraHeaders map[string]string
+	if raw := os.Getenv("CLASP_" + tier + "_EXTRA_HEADERS"); raw != "" {
+		headers, err := parseExtraHeaders(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CLASP_%s_EXTRA_HEADERS: %w", tier, err)
+		}
+		extraHeaders = headers
+	}
+
+	// If nothing is configured for this tier, return nil
+	if provider == "" && model == "" && httpTimeout == 0 {
+		return nil, nil
 	}
 
 	tierCfg := &TierConfig{
-		Provider: ProviderType(provider),
-		Model:    model,
-		APIKey:   apiKey,
-		BaseURL:  baseURL,
+		Provider:       ProviderType(provider),
+		Model:          model,
+		APIKey:         apiKey,
+		BaseURL:        baseURL,
+		HTTPTimeoutSec: httpTimeout,
+		ExtraHeaders:   extraHeaders,
 	}
 
 	// If no explicit API key, inherit from main config based on provider
@@ -645,7 +1499,7 @@ func loadTierConfig(tier string, cfg *Config) *TierConfig {
 		}
 	}
 
-	return tierCfg
+	return tierCfg, nil
 }
 
 // Validate checks that the configuration is valid.
@@ -714,34 +1568,7 @@ func (c *Config) Validate() error {
 
 // GetAPIKey returns the API key for the configured provider.
 func (c *Config) GetAPIKey() string {
-	switch c.Provider {
-	case ProviderOpenAI:
-		return c.OpenAIAPIKey
-	case ProviderAzure:
-		return c.AzureAPIKey
-	case ProviderOpenRouter:
-		return c.OpenRouterAPIKey
-	case ProviderAnthropic:
-		return c.AnthropicAPIKey
-	case ProviderOllama:
-		return c.OllamaAPIKey // Usually empty for local Ollama
-	case ProviderGemini:
-		return c.GeminiAPIKey
-	case ProviderDeepSeek:
-		return c.DeepSeekAPIKey
-	case ProviderGrok:
-		return c.GrokAPIKey
-	case ProviderQwen:
-		return c.QwenAPIKey
-	case ProviderMiniMax:
-		return c.MiniMaxAPIKey
-	case ProviderLiteLLM:
-		return c.LiteLLMAPIKey
-	case ProviderCustom:
-		return c.CustomAPIKey
-	default:
-		return ""
-	}
+	return c.providerAPIKey(c.Provider)
 }
 
 // GetBaseURL returns the base URL for the configured provider.
@@ -794,6 +1621,8 @@ func (c *Config) GetChatCompletionsURL() string {
 }
 
 // MapModel applies model mapping based on the requested model.
+// A model an alias resolved to is used as is unless it names a Claude tier,
+// so aliases take precedence over tier mapping and the default model.
 func (c *Config) MapModel(requestedModel string) string {
 	// Check for tier-based mapping
 	switch {
@@ -805,6 +1634,10 @@ func (c *Config) MapModel(requestedModel string) string {
 		return c.ModelHaiku
 	}
 
+	if c.isAliasTarget(requestedModel) {
+		return requestedModel
+	}
+
 	// Return default model if set, otherwise use requested model
 	if c.DefaultModel != "" {
 		return c.DefaultModel
@@ -879,6 +1712,84 @@ func (c *Config) GetGlobalFallbackConfig() *TierConfig {
 	}
 }
 
+// GetFallbackChainConfigs returns the CLASP_FALLBACK_CHAIN hops with the
+// API key and base URL of each hop's provider filled in.
+func (c *Config) GetFallbackChainConfigs() []*TierConfig {
+	configs := make([]*TierConfig, 0, len(c.FallbackChain))
+	for _, hop := range c.FallbackChain {
+		configs = append(configs, &TierConfig{
+			Provider: hop.Provider,
+			Model:    hop.Model,
+			APIKey:   c.providerAPIKey(hop.Provider),
+			BaseURL:  c.providerBaseURL(hop.Provider),
+		})
+	}
+	return configs
+}
+
+// providerAPIKey returns the configured API key of a provider.
+func (c *Config) providerAPIKey(p ProviderType) string {
+	switch p {
+	case ProviderOpenAI:
+		return c.OpenAIAPIKey
+	case ProviderAzure:
+		return c.AzureAPIKey
+	case ProviderOpenRouter:
+		return c.OpenRouterAPIKey
+	case ProviderAnthropic:
+		return c.AnthropicAPIKey
+	case ProviderOllama:
+		return c.OllamaAPIKey
+	case ProviderGemini:
+		return c.GeminiAPIKey
+	case ProviderDeepSeek:
+		return c.DeepSeekAPIKey
+	case ProviderGrok:
+		return c.GrokAPIKey
+	case ProviderQwen:
+		return c.QwenAPIKey
+	case ProviderMiniMax:
+		return c.MiniMaxAPIKey
+	case ProviderLiteLLM:
+		return c.LiteLLMAPIKey
+	case ProviderCustom:
+		return c.CustomAPIKey
+	default:
+		return ""
+	}
+}
+
+// providerBaseURL returns the configured base URL of a provider as a tier
+// provider expects it, or "" to use the provider's default.
+func (c *Config) providerBaseURL(p ProviderType) string {
+	switch p {
+	case ProviderOpenAI:
+		return c.OpenAIBaseURL
+	case ProviderAzure:
+		return c.AzureEndpoint
+	case ProviderOpenRouter:
+		return c.OpenRouterBaseURL
+	case ProviderOllama:
+		return c.OllamaBaseURL
+	case ProviderGemini:
+		return c.GeminiBaseURL
+	case ProviderDeepSeek:
+		return c.DeepSeekBaseURL
+	case ProviderGrok:
+		return c.GrokBaseURL
+	case ProviderQwen:
+		return c.QwenBaseURL
+	case ProviderMiniMax:
+		return c.MiniMaxBaseURL
+	case ProviderLiteLLM:
+		return c.LiteLLMBaseURL
+	case ProviderCustom:
+		return c.CustomBaseURL
+	default:
+		return ""
+	}
+}
+
 // ModelTier represents a Claude model tier.
 type ModelTier string
 
@@ -931,6 +1842,10 @@ func loadModelAliases() map[string]string {
 	// Load from CLASP_ALIAS_* environment variables
 	const aliasPrefix = "CLASP_ALIAS_"
 	for _, env := range os.Environ() {
+		// Pattern rules are loaded by loadAliasRules
+		if strings.HasPrefix(env, "CLASP_ALIAS_REGEX=") || strings.HasPrefix(env, "CLASP_ALIAS_GLOB=") {
+			continue
+		}
 		if strings.HasPrefix(env, aliasPrefix) {
 			parts := strings.SplitN(env, "=", 2)
 			if len(parts) == 2 {
@@ -960,7 +1875,40 @@ func loadModelAliases() map[string]string {
 	return aliases
 }
 
-// ResolveAlias resolves a model alias to its target model.
+// loadMaxTokensLimits loads per-model output token limits from
+// CLASP_MAX_TOKENS_<MODEL>=N environment variables (e.g.,
+// CLASP_MAX_TOKENS_GPT_4O=32768). Model names are lowercased.
+func loadMaxTokensLimits() (map[string]int, error) {
+	var limits map[string]int
+
+	const limitPrefix = "CLASP_MAX_TOKENS_"
+	for _, env := range os.Environ() {
+		if !strings.HasPrefix(env, limitPrefix) {
+			continue
+		}
+		parts := strings.SplitN(env, "=", 2)
+		model := strings.ToLower(strings.TrimPrefix(parts[0], limitPrefix))
+		if model == "" || len(parts) != 2 {
+			continue
+		}
+		limit, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", parts[0], err)
+		}
+		if limit <= 0 {
+			return nil, fmt.Errorf("invalid %s: %d (must be positive)", parts[0], limit)
+		}
+		if limits == nil {
+			limits = make(map[string]int)
+		}
+		limits[model] = limit
+	}
+
+	return limits, nil
+}
+
+// ResolveAlias resolves a model alias to its target model. Exact aliases take
+// precedence over alias rules, which are tried in order.
 // If the model is not an alias, returns the original model unchanged.
 func (c *Config) ResolveAlias(model string) string {
 	// Check if this model is an alias (case-insensitive lookup)
@@ -968,6 +1916,11 @@ func (c *Config) ResolveAlias(model string) string {
 	if target, ok := c.ModelAliases[modelLower]; ok {
 		return target
 	}
+	for _, rule := range c.AliasRules {
+		if rule.Match(model) {
+			return rule.Target
+		}
+	}
 	return model
 }
 
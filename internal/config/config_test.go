@@ -3,6 +3,7 @@ package config
 
 import (
 	"os"
+	"reflect"
 	"testing"
 )
 
@@ -18,13 +19,36 @@ func clearEnv() {
 		"CLASP_PORT", "CLASP_LOG_LEVEL",
 		"CLASP_DEBUG", "CLASP_DEBUG_REQUESTS", "CLASP_DEBUG_RESPONSES",
 		"CLASP_RATE_LIMIT", "CLASP_RATE_LIMIT_REQUESTS", "CLASP_RATE_LIMIT_WINDOW", "CLASP_RATE_LIMIT_BURST",
-		"CLASP_CACHE", "CLASP_CACHE_MAX_SIZE", "CLASP_CACHE_TTL",
-		"CLASP_AUTH", "CLASP_AUTH_API_KEY",
+		"CLASP_CACHE", "CLASP_CACHE_MAX_SIZE", "CLASP_CACHE_TTL", "CLASP_CACHE_STREAMING", "CLASP_CACHE_KEY",
+		"CLASP_NEGATIVE_CACHE", "CLASP_NEGATIVE_CACHE_TTL", "CLASP_STREAM_INCLUDE_USAGE",
+		"CLASP_CACHE_MODE", "CLASP_SEMANTIC_CACHE_THRESHOLD", "CLASP_SEMANTIC_CACHE_MODEL",
+		"CLASP_AUTH", "CLASP_AUTH_API_KEY", "CLASP_AUTH_ANONYMOUS_ENDPOINTS",
 		"CLASP_MULTI_PROVIDER",
-		"CLASP_FALLBACK", "CLASP_FALLBACK_PROVIDER", "CLASP_FALLBACK_MODEL",
+		"CLASP_FALLBACK", "CLASP_FALLBACK_PROVIDER", "CLASP_FALLBACK_MODEL", "CLASP_FALLBACK_ON_SLOW", "CLASP_FALLBACK_SLOW_MS", "CLASP_FALLBACK_CHAIN",
 		"CLASP_CIRCUIT_BREAKER",
 		"CLASP_QUEUE",
 		"CLASP_MODEL_ALIASES",
+		"CLASP_DOCUMENT_FALLBACK", "CLASP_IDENTITY_FILTER", "CLASP_IDENTITY_FILE", "CLASP_HOOKS_FILE", "CLASP_ANTHROPIC_VERSION", "CLASP_STRICT_VERSION",
+		"CLASP_PASSTHROUGH_FORWARD_HEADERS", "CLASP_PASSTHROUGH_STRIP_HEADERS", "CLASP_API_PATH_PREFIX", "CLASP_API_PATH_UNPREFIXED", "CLASP_WARMUP",
+		"CLASP_LOGIT_BIAS", "CLASP_EXTRA_HEADERS", "CLASP_OPUS_PROVIDER", "CLASP_OPUS_EXTRA_HEADERS",
+		"CLASP_FORCE_JSON", "CLASP_SEED", "CLASP_CUSTOM_TOP_K",
+		"CLASP_PARALLEL_TOOL_CALLS", "CLASP_CUSTOM_PARALLEL_TOOL_CALLS", "CLASP_REPAIR_TOOL_JSON", "CLASP_DISABLE_MAX_TOKENS_CAP", "CLASP_MAX_TOKENS_GPT_4O",
+		"CLASP_FINISH_REASON_MAP", "CLASP_MULTI_CHOICE", "CLASP_ALLOW_CLIENT_KEYS", "CLASP_ENDPOINT_FALLBACK",
+		"CLASP_RETRY_MAX", "CLASP_RETRY_BASE_DELAY_MS", "CLASP_RETRY_MAX_DELAY_MS", "CLASP_RETRY_JITTER", "CLASP_RETRY_BUDGET_RATIO",
+		"CLASP_HEALTH_CHECK_UPSTREAM", "CLASP_HEALTH_CHECK_UPSTREAM_TTL", "CLASP_ALERT_WEBHOOK_URL", "CLASP_ALERT_BUDGET_USD",
+		"CLASP_CB_HALF_OPEN_MAX", "CLASP_QUEUE_PERSIST", "CLASP_QUEUE_PERSIST_DIR",
+		"CLASP_QUEUE_DLQ_DIR", "CLASP_PRESENCE_PENALTY", "CLASP_FREQUENCY_PENALTY",
+		"CLASP_REASONING_CONTENT",
+		"CLASP_AUDIT_LOG",
+		"CLASP_DEBUG_SAMPLE_RATE",
+		"CLASP_AUDIT_INCLUDE_CONTENT",
+		"CLASP_OPENAI_ENDPOINTS", "CLASP_CUSTOM_ENDPOINTS",
+		"CLASP_REQUEST_TIMEOUT_MIN", "CLASP_REQUEST_TIMEOUT_MAX", "CLASP_UPSTREAM_HTTP_VERSION",
+		"CLASP_COMPACTION", "CLASP_RESPONSES_STORE", "CLASP_RESPONSES_REASONING_SUMMARY",
+		"CLASP_RESPONSES_CONTINUITY", "CLASP_RESPONSES_CONTINUITY_FILE",
+		"CLASP_MAX_REQUEST_BYTES", "CLASP_MAX_RESPONSE_BYTES", "CLASP_MAX_CONCURRENT", "CLASP_SSE_HEARTBEAT_SECONDS",
+		"CLASP_STICKY_ROUTING", "CLASP_STICKY_ROUTING_HEADER", "CLASP_STICKY_ROUTING_TTL", "CLASP_STICKY_ROUTING_MAX_ENTRIES",
+		"CLASP_IP_ALLOWLIST", "CLASP_IP_DENYLIST", "CLASP_TRUST_PROXY_HEADERS", "CLASP_CORS_ORIGINS",
 	}
 	for _, v := range envVars {
 		os.Unsetenv(v)
@@ -203,6 +227,7 @@ func TestLoadFromEnv_Cache(t *testing.T) {
 	os.Setenv("CLASP_CACHE", "1")
 	os.Setenv("CLASP_CACHE_MAX_SIZE", "500")
 	os.Setenv("CLASP_CACHE_TTL", "7200")
+	os.Setenv("CLASP_CACHE_STREAMING", "true")
 	defer clearEnv()
 
 	cfg, err := LoadFromEnv()
@@ -219,6 +244,153 @@ func TestLoadFromEnv_Cache(t *testing.T) {
 	if cfg.CacheTTL != 7200 {
 		t.Errorf("CacheTTL = %d, want %d", cfg.CacheTTL, 7200)
 	}
+	if !cfg.CacheStreaming {
+		t.Error("CacheStreaming should be true")
+	}
+}
+
+func TestLoadFromEnv_SemanticCache(t *testing.T) {
+	clearEnv()
+	os.Setenv("OPENAI_API_KEY", "sk-test")
+	defer clearEnv()
+
+	cfg, err := LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv failed: %v", err)
+	}
+	if cfg.CacheMode != "exact" {
+		t.Errorf("CacheMode = %q, want %q", cfg.CacheMode, "exact")
+	}
+	if cfg.SemanticCacheThreshold != 0.95 {
+		t.Errorf("SemanticCacheThreshold = %g, want %g", cfg.SemanticCacheThreshold, 0.95)
+	}
+	if cfg.SemanticCacheModel != "text-embedding-3-small" {
+		t.Errorf("SemanticCacheModel = %q, want %q", cfg.SemanticCacheModel, "text-embedding-3-small")
+	}
+
+	os.Setenv("CLASP_CACHE_MODE", "semantic")
+	os.Setenv("CLASP_SEMANTIC_CACHE_THRESHOLD", "0.9")
+	os.Setenv("CLASP_SEMANTIC_CACHE_MODEL", "text-embedding-3-large")
+	cfg, err = LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv failed: %v", err)
+	}
+	if cfg.CacheMode != "semantic" {
+		t.Errorf("CacheMode = %q, want %q", cfg.CacheMode, "semantic")
+	}
+	if cfg.SemanticCacheThreshold != 0.9 {
+		t.Errorf("SemanticCacheThreshold = %g, want %g", cfg.SemanticCacheThreshold, 0.9)
+	}
+	if cfg.SemanticCacheModel != "text-embedding-3-large" {
+		t.Errorf("SemanticCacheModel = %q, want %q", cfg.SemanticCacheModel, "text-embedding-3-large")
+	}
+
+	os.Setenv("CLASP_CACHE_MODE", "fuzzy")
+	if _, err := LoadFromEnv(); err == nil {
+		t.Error("Expected error for invalid CLASP_CACHE_MODE")
+	}
+	os.Setenv("CLASP_CACHE_MODE", "semantic")
+
+	for _, threshold := range []string{"0", "1.5", "abc"} {
+		os.Setenv("CLASP_SEMANTIC_CACHE_THRESHOLD", threshold)
+		if _, err := LoadFromEnv(); err == nil {
+			t.Errorf("Expected error for CLASP_SEMANTIC_CACHE_THRESHOLD=%s", threshold)
+		}
+	}
+}
+
+func TestLoadFromEnv_CacheKey(t *testing.T) {
+	clearEnv()
+	os.Setenv("OPENAI_API_KEY", "sk-test")
+	defer clearEnv()
+
+	cfg, err := LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv failed: %v", err)
+	}
+	if cfg.CacheKey != "full" {
+		t.Errorf("CacheKey = %q, want %q", cfg.CacheKey, "full")
+	}
+
+	for _, key := range []string{"full", "messages_only", "normalized"} {
+		os.Setenv("CLASP_CACHE_KEY", key)
+		cfg, err = LoadFromEnv()
+		if err != nil {
+			t.Fatalf("LoadFromEnv failed for CLASP_CACHE_KEY=%s: %v", key, err)
+		}
+		if cfg.CacheKey != key {
+			t.Errorf("CacheKey = %q, want %q", cfg.CacheKey, key)
+		}
+	}
+
+	os.Setenv("CLASP_CACHE_KEY", "prompt")
+	if _, err := LoadFromEnv(); err == nil {
+		t.Error("Expected error for invalid CLASP_CACHE_KEY")
+	}
+}
+
+func TestLoadFromEnv_NegativeCache(t *testing.T) {
+	clearEnv()
+	os.Setenv("OPENAI_API_KEY", "sk-test")
+	defer clearEnv()
+
+	cfg, err := LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv failed: %v", err)
+	}
+	if cfg.NegativeCacheEnabled {
+		t.Error("Expected negative caching to be disabled by default")
+	}
+	if cfg.NegativeCacheTTL != 30 {
+		t.Errorf("NegativeCacheTTL = %d, want 30", cfg.NegativeCacheTTL)
+	}
+
+	os.Setenv("CLASP_NEGATIVE_CACHE", "true")
+	os.Setenv("CLASP_NEGATIVE_CACHE_TTL", "5")
+	cfg, err = LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv failed: %v", err)
+	}
+	if !cfg.NegativeCacheEnabled || cfg.NegativeCacheTTL != 5 {
+		t.Errorf("Expected negative caching with a 5s TTL, got %v and %d", cfg.NegativeCacheEnabled, cfg.NegativeCacheTTL)
+	}
+
+	for _, ttl := range []string{"0", "-1", "soon"} {
+		os.Setenv("CLASP_NEGATIVE_CACHE_TTL", ttl)
+		if _, err := LoadFromEnv(); err == nil {
+			t.Errorf("Expected error for CLASP_NEGATIVE_CACHE_TTL=%s", ttl)
+		}
+	}
+}
+
+func TestLoadFromEnv_StreamIncludeUsage(t *testing.T) {
+	clearEnv()
+	os.Setenv("OPENAI_API_KEY", "sk-test")
+	defer clearEnv()
+
+	cfg, err := LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv failed: %v", err)
+	}
+	if cfg.StreamIncludeUsage != "auto" {
+		t.Errorf("StreamIncludeUsage = %q, want %q", cfg.StreamIncludeUsage, "auto")
+	}
+
+	for _, mode := range []string{"auto", "on", "off"} {
+		os.Setenv("CLASP_STREAM_INCLUDE_USAGE", mode)
+		cfg, err = LoadFromEnv()
+		if err != nil {
+			t.Fatalf("LoadFromEnv failed for CLASP_STREAM_INCLUDE_USAGE=%s: %v", mode, err)
+		}
+		if cfg.StreamIncludeUsage != mode {
+			t.Errorf("StreamIncludeUsage = %q, want %q", cfg.StreamIncludeUsage, mode)
+		}
+	}
+
+	os.Setenv("CLASP_STREAM_INCLUDE_USAGE", "true")
+	if _, err := LoadFromEnv(); err == nil {
+		t.Error("Expected error for invalid CLASP_STREAM_INCLUDE_USAGE")
+	}
 }
 
 func TestLoadFromEnv_InvalidPort(t *testing.T) {
@@ -632,3 +804,1168 @@ func TestDetectProvider(t *testing.T) {
 		})
 	}
 }
+
+func TestLoadFromEnv_DocumentFallback(t *testing.T) {
+	clearEnv()
+	os.Setenv("OPENAI_API_KEY", "sk-test-key")
+	defer clearEnv()
+
+	cfg, err := LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv failed: %v", err)
+	}
+	if cfg.DocumentFallback != "text" {
+		t.Errorf("DocumentFallback = %q, want %q", cfg.DocumentFallback, "text")
+	}
+
+	os.Setenv("CLASP_DOCUMENT_FALLBACK", "error")
+	cfg, err = LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv failed: %v", err)
+	}
+	if cfg.DocumentFallback != "error" {
+		t.Errorf("DocumentFallback = %q, want %q", cfg.DocumentFallback, "error")
+	}
+
+	os.Setenv("CLASP_DOCUMENT_FALLBACK", "drop")
+	if _, err := LoadFromEnv(); err == nil {
+		t.Error("expected error for invalid CLASP_DOCUMENT_FALLBACK")
+	}
+}
+
+func TestLoadFromEnv_IdentityFilter(t *testing.T) {
+	clearEnv()
+	os.Setenv("OPENAI_API_KEY", "sk-test-key")
+	defer clearEnv()
+
+	cfg, err := LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv failed: %v", err)
+	}
+	if cfg.IdentityFilter != "full" {
+		t.Errorf("IdentityFilter = %q, want %q", cfg.IdentityFilter, "full")
+	}
+
+	os.Setenv("CLASP_IDENTITY_FILTER", "off")
+	os.Setenv("CLASP_IDENTITY_FILE", "/tmp/identity.json")
+	cfg, err = LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv failed: %v", err)
+	}
+	if cfg.IdentityFilter != "off" {
+		t.Errorf("IdentityFilter = %q, want %q", cfg.IdentityFilter, "off")
+	}
+	if cfg.IdentityFile != "/tmp/identity.json" {
+		t.Errorf("IdentityFile = %q, want %q", cfg.IdentityFile, "/tmp/identity.json")
+	}
+
+	os.Setenv("CLASP_IDENTITY_FILTER", "strict")
+	if _, err := LoadFromEnv(); err == nil {
+		t.Error("expected error for invalid CLASP_IDENTITY_FILTER")
+	}
+}
+
+func TestLoadFromEnv_MaxTokensCap(t *testing.T) {
+	clearEnv()
+	os.Setenv("OPENAI_API_KEY", "sk-test-key")
+	defer clearEnv()
+
+	cfg, err := LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv failed: %v", err)
+	}
+	if cfg.DisableMaxTokensCap || len(cfg.MaxTokensLimits) != 0 {
+		t.Errorf("expected default capping, got disabled=%v limits=%v", cfg.DisableMaxTokensCap, cfg.MaxTokensLimits)
+	}
+
+	os.Setenv("CLASP_DISABLE_MAX_TOKENS_CAP", "true")
+	os.Setenv("CLASP_MAX_TOKENS_GPT_4O", "32768")
+	cfg, err = LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv failed: %v", err)
+	}
+	if !cfg.DisableMaxTokensCap {
+		t.Error("DisableMaxTokensCap = false, want true")
+	}
+	if cfg.MaxTokensLimits["gpt_4o"] != 32768 {
+		t.Errorf("MaxTokensLimits = %v, want gpt_4o=32768", cfg.MaxTokensLimits)
+	}
+
+	os.Setenv("CLASP_MAX_TOKENS_GPT_4O", "lots")
+	if _, err := LoadFromEnv(); err == nil {
+		t.Error("expected error for non-numeric CLASP_MAX_TOKENS_GPT_4O")
+	}
+}
+
+func TestLoadFromEnv_AnthropicVersion(t *testing.T) {
+	clearEnv()
+	os.Setenv("OPENAI_API_KEY", "sk-test-key")
+	defer clearEnv()
+
+	cfg, err := LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv failed: %v", err)
+	}
+	if cfg.AnthropicVersion != "2023-06-01" || cfg.StrictVersion {
+		t.Errorf("got AnthropicVersion=%q StrictVersion=%v, want 2023-06-01 and false", cfg.AnthropicVersion, cfg.StrictVersion)
+	}
+
+	os.Setenv("CLASP_ANTHROPIC_VERSION", "2023-01-01")
+	os.Setenv("CLASP_STRICT_VERSION", "true")
+	cfg, err = LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv failed: %v", err)
+	}
+	if cfg.AnthropicVersion != "2023-01-01" || !cfg.StrictVersion {
+		t.Errorf("got AnthropicVersion=%q StrictVersion=%v, want 2023-01-01 and true", cfg.AnthropicVersion, cfg.StrictVersion)
+	}
+}
+
+func TestLoadFromEnv_Warmup(t *testing.T) {
+	clearEnv()
+	os.Setenv("OPENAI_API_KEY", "sk-test-key")
+	defer clearEnv()
+
+	cfg, err := LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv failed: %v", err)
+	}
+	if cfg.Warmup {
+		t.Error("Warmup should be disabled by default")
+	}
+
+	os.Setenv("CLASP_WARMUP", "true")
+	if cfg, err = LoadFromEnv(); err != nil || !cfg.Warmup {
+		t.Errorf("expected CLASP_WARMUP=true to enable warmup (err: %v)", err)
+	}
+}
+
+func TestLoadFromEnv_APIPathPrefix(t *testing.T) {
+	clearEnv()
+	os.Setenv("OPENAI_API_KEY", "sk-test-key")
+	defer clearEnv()
+
+	cfg, err := LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv failed: %v", err)
+	}
+	if cfg.APIPathPrefix != "/v1" || cfg.APIPathUnprefixed {
+		t.Errorf("got APIPathPrefix=%q APIPathUnprefixed=%v, want /v1 and false", cfg.APIPathPrefix, cfg.APIPathUnprefixed)
+	}
+
+	tests := map[string]string{
+		"/api/v1/":  "/api/v1",
+		"anthropic": "/anthropic",
+		"/":         "",
+	}
+	for raw, want := range tests {
+		os.Setenv("CLASP_API_PATH_PREFIX", raw)
+		cfg, err := LoadFromEnv()
+		if err != nil {
+			t.Fatalf("LoadFromEnv(%q) failed: %v", raw, err)
+		}
+		if cfg.APIPathPrefix != want {
+			t.Errorf("CLASP_API_PATH_PREFIX=%q: got %q, want %q", raw, cfg.APIPathPrefix, want)
+		}
+	}
+
+	os.Setenv("CLASP_API_PATH_UNPREFIXED", "true")
+	if cfg, err = LoadFromEnv(); err != nil || !cfg.APIPathUnprefixed {
+		t.Errorf("expected CLASP_API_PATH_UNPREFIXED to be enabled (err: %v)", err)
+	}
+
+	os.Setenv("CLASP_API_PATH_PREFIX", "/v1?x=1")
+	if _, err := LoadFromEnv(); err == nil {
+		t.Error("expected error for a prefix with a query")
+	}
+}
+
+func TestLoadFromEnv_PassthroughHeaders(t *testing.T) {
+	clearEnv()
+	os.Setenv("OPENAI_API_KEY", "sk-test-key")
+	defer clearEnv()
+
+	cfg, err := LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv failed: %v", err)
+	}
+	if !reflect.DeepEqual(cfg.PassthroughForwardHeaders, []string{"anthropic-version", "anthropic-beta"}) {
+		t.Errorf("PassthroughForwardHeaders = %v, want anthropic-version and anthropic-beta", cfg.PassthroughForwardHeaders)
+	}
+	if len(cfg.PassthroughStripHeaders) == 0 {
+		t.Error("PassthroughStripHeaders should default to the hop-by-hop headers")
+	}
+
+	os.Setenv("CLASP_PASSTHROUGH_FORWARD_HEADERS", " anthropic-version, x-request-id ,,")
+	os.Setenv("CLASP_PASSTHROUGH_STRIP_HEADERS", "Connection,x-internal")
+	cfg, err = LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv failed: %v", err)
+	}
+	if !reflect.DeepEqual(cfg.PassthroughForwardHeaders, []string{"anthropic-version", "x-request-id"}) {
+		t.Errorf("PassthroughForwardHeaders = %v", cfg.PassthroughForwardHeaders)
+	}
+	if !reflect.DeepEqual(cfg.PassthroughStripHeaders, []string{"Connection", "x-internal"}) {
+		t.Errorf("PassthroughStripHeaders = %v", cfg.PassthroughStripHeaders)
+	}
+}
+
+func TestLoadFromEnv_FallbackChain(t *testing.T) {
+	clearEnv()
+	os.Setenv("OPENAI_API_KEY", "sk-test-key")
+	os.Setenv("OPENROUTER_API_KEY", "sk-or-test")
+	defer clearEnv()
+
+	os.Setenv("CLASP_FALLBACK_CHAIN", "openrouter:openai/gpt-4o, ollama:llama3.1:8b,custom")
+	cfg, err := LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv failed: %v", err)
+	}
+	want := []TierConfig{
+		{Provider: ProviderOpenRouter, Model: "openai/gpt-4o"},
+		{Provider: ProviderOllama, Model: "llama3.1:8b"},
+		{Provider: ProviderCustom},
+	}
+	if len(cfg.FallbackChain) != len(want) {
+		t.Fatalf("FallbackChain = %+v, want %+v", cfg.FallbackChain, want)
+	}
+	for i := range want {
+		if !reflect.DeepEqual(cfg.FallbackChain[i], want[i]) {
+			t.Errorf("FallbackChain[%d] = %+v, want %+v", i, cfg.FallbackChain[i], want[i])
+		}
+	}
+
+	hops := cfg.GetFallbackChainConfigs()
+	if hops[0].APIKey != "sk-or-test" || hops[0].BaseURL != cfg.OpenRouterBaseURL {
+		t.Errorf("expected the OpenRouter key and base URL for hop 1, got %+v", hops[0])
+	}
+
+	os.Setenv("CLASP_FALLBACK_CHAIN", "openrouter:gpt-4o,nosuchprovider:model")
+	if _, err := LoadFromEnv(); err == nil {
+		t.Error("expected error for an unknown provider")
+	}
+}
+
+func TestLoadFromEnv_FallbackOnSlow(t *testing.T) {
+	clearEnv()
+	os.Setenv("OPENAI_API_KEY", "sk-test-key")
+	defer clearEnv()
+
+	cfg, err := LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv failed: %v", err)
+	}
+	if cfg.FallbackOnSlow || cfg.FallbackSlowMs != 10000 {
+		t.Errorf("got FallbackOnSlow=%v FallbackSlowMs=%d, want false and 10000", cfg.FallbackOnSlow, cfg.FallbackSlowMs)
+	}
+
+	os.Setenv("CLASP_FALLBACK_ON_SLOW", "true")
+	os.Setenv("CLASP_FALLBACK_SLOW_MS", "2500")
+	cfg, err = LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv failed: %v", err)
+	}
+	if !cfg.FallbackOnSlow || cfg.FallbackSlowMs != 2500 {
+		t.Errorf("got FallbackOnSlow=%v FallbackSlowMs=%d, want true and 2500", cfg.FallbackOnSlow, cfg.FallbackSlowMs)
+	}
+
+	os.Setenv("CLASP_FALLBACK_SLOW_MS", "0")
+	if _, err := LoadFromEnv(); err == nil {
+		t.Error("expected error for CLASP_FALLBACK_SLOW_MS=0")
+	}
+}
+
+func TestLoadFromEnv_LogitBias(t *testing.T) {
+	clearEnv()
+	os.Setenv("OPENAI_API_KEY", "sk-test-key")
+	defer clearEnv()
+
+	os.Setenv("CLASP_LOGIT_BIAS", `{"50256": -100, "1234": 5.5}`)
+	cfg, err := LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv failed: %v", err)
+	}
+	if cfg.LogitBias["50256"] != -100 || cfg.LogitBias["1234"] != 5.5 {
+		t.Errorf("LogitBias = %v", cfg.LogitBias)
+	}
+
+	for _, invalid := range []string{`not json`, `{"abc": 1}`, `{"1": 101}`} {
+		os.Setenv("CLASP_LOGIT_BIAS", invalid)
+		if _, err := LoadFromEnv(); err == nil {
+			t.Errorf("expected error for CLASP_LOGIT_BIAS=%s", invalid)
+		}
+	}
+}
+
+func TestLoadFromEnv_ForceJSON(t *testing.T) {
+	clearEnv()
+	os.Setenv("OPENAI_API_KEY", "sk-test-key")
+	defer clearEnv()
+
+	cfg, err := LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv failed: %v", err)
+	}
+	if cfg.ForceJSON {
+		t.Error("ForceJSON should default to false")
+	}
+
+	os.Setenv("CLASP_FORCE_JSON", "true")
+	cfg, err = LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv failed: %v", err)
+	}
+	if !cfg.ForceJSON {
+		t.Error("ForceJSON should be true when CLASP_FORCE_JSON=true")
+	}
+}
+
+func TestLoadFromEnv_CustomTopK(t *testing.T) {
+	clearEnv()
+	os.Setenv("OPENAI_API_KEY", "sk-test-key")
+	defer clearEnv()
+
+	cfg, err := LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv failed: %v", err)
+	}
+	if cfg.CustomTopK {
+		t.Error("CustomTopK should default to false")
+	}
+
+	os.Setenv("CLASP_CUSTOM_TOP_K", "1")
+	cfg, err = LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv failed: %v", err)
+	}
+	if !cfg.CustomTopK {
+		t.Error("CustomTopK should be true when CLASP_CUSTOM_TOP_K=1")
+	}
+}
+
+func TestLoadFromEnv_CustomParallelToolCalls(t *testing.T) {
+	clearEnv()
+	os.Setenv("OPENAI_API_KEY", "sk-test-key")
+	defer clearEnv()
+
+	cfg, err := LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv failed: %v", err)
+	}
+	if cfg.CustomParallelToolCalls {
+		t.Error("CustomParallelToolCalls should default to false")
+	}
+
+	os.Setenv("CLASP_CUSTOM_PARALLEL_TOOL_CALLS", "1")
+	cfg, err = LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv failed: %v", err)
+	}
+	if !cfg.CustomParallelToolCalls {
+		t.Error("CustomParallelToolCalls should be true when CLASP_CUSTOM_PARALLEL_TOOL_CALLS=1")
+	}
+}
+
+func TestLoadFromEnv_StickyRouting(t *testing.T) {
+	clearEnv()
+	os.Setenv("OPENAI_API_KEY", "sk-test-key")
+	defer clearEnv()
+
+	cfg, err := LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv failed: %v", err)
+	}
+	if cfg.StickyRoutingEnabled {
+		t.Error("StickyRoutingEnabled should default to false")
+	}
+	if cfg.StickyRoutingHeader != "X-CLASP-Conversation-ID" {
+		t.Errorf("StickyRoutingHeader = %q", cfg.StickyRoutingHeader)
+	}
+
+	os.Setenv("CLASP_STICKY_ROUTING", "true")
+	os.Setenv("CLASP_STICKY_ROUTING_HEADER", "X-Session")
+	os.Setenv("CLASP_STICKY_ROUTING_TTL", "600")
+	os.Setenv("CLASP_STICKY_ROUTING_MAX_ENTRIES", "50")
+	cfg, err = LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv failed: %v", err)
+	}
+	if !cfg.StickyRoutingEnabled || cfg.StickyRoutingHeader != "X-Session" ||
+		cfg.StickyRoutingTTLSec != 600 || cfg.StickyRoutingMaxEntries != 50 {
+		t.Errorf("unexpected sticky routing config: %+v", cfg)
+	}
+
+	os.Setenv("CLASP_STICKY_ROUTING_TTL", "soon")
+	if _, err := LoadFromEnv(); err == nil {
+		t.Error("expected error for invalid CLASP_STICKY_ROUTING_TTL")
+	}
+}
+
+func TestLoadFromEnv_ParallelToolCalls(t *testing.T) {
+	clearEnv()
+	os.Setenv("OPENAI_API_KEY", "sk-test-key")
+	defer clearEnv()
+
+	cfg, err := LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv failed: %v", err)
+	}
+	if !cfg.ParallelToolCalls {
+		t.Error("ParallelToolCalls should default to true")
+	}
+
+	os.Setenv("CLASP_PARALLEL_TOOL_CALLS", "false")
+	cfg, err = LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv failed: %v", err)
+	}
+	if cfg.ParallelToolCalls {
+		t.Error("ParallelToolCalls should be false when CLASP_PARALLEL_TOOL_CALLS=false")
+	}
+}
+
+func TestLoadFromEnv_RepairToolJSON(t *testing.T) {
+	clearEnv()
+	os.Setenv("OPENAI_API_KEY", "sk-test-key")
+	defer clearEnv()
+
+	cfg, err := LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv failed: %v", err)
+	}
+	if cfg.RepairToolJSON {
+		t.Error("RepairToolJSON should default to false")
+	}
+
+	os.Setenv("CLASP_REPAIR_TOOL_JSON", "true")
+	cfg, err = LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv failed: %v", err)
+	}
+	if !cfg.RepairToolJSON {
+		t.Error("RepairToolJSON should be true when CLASP_REPAIR_TOOL_JSON=true")
+	}
+}
+
+func TestLoadFromEnv_Seed(t *testing.T) {
+	clearEnv()
+	os.Setenv("OPENAI_API_KEY", "sk-test-key")
+	defer clearEnv()
+
+	cfg, err := LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv failed: %v", err)
+	}
+	if cfg.Seed != nil {
+		t.Errorf("Seed should be unset by default, got %d", *cfg.Seed)
+	}
+
+	os.Setenv("CLASP_SEED", "42")
+	cfg, err = LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv failed: %v", err)
+	}
+	if cfg.Seed == nil || *cfg.Seed != 42 {
+		t.Errorf("Seed = %v, want 42", cfg.Seed)
+	}
+
+	os.Setenv("CLASP_SEED", "random")
+	if _, err := LoadFromEnv(); err == nil {
+		t.Error("expected error for invalid CLASP_SEED")
+	}
+}
+
+func TestLoadFromEnv_ReasoningContent(t *testing.T) {
+	clearEnv()
+	os.Setenv("OPENAI_API_KEY", "sk-test-key")
+	defer clearEnv()
+
+	cfg, err := LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv failed: %v", err)
+	}
+	if cfg.ReasoningContent {
+		t.Error("ReasoningContent should be disabled by default")
+	}
+
+	os.Setenv("CLASP_REASONING_CONTENT", "true")
+	cfg, err = LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv failed: %v", err)
+	}
+	if !cfg.ReasoningContent {
+		t.Error("ReasoningContent should be enabled by CLASP_REASONING_CONTENT=true")
+	}
+}
+
+func TestLoadFromEnv_AuditLog(t *testing.T) {
+	clearEnv()
+	os.Setenv("OPENAI_API_KEY", "sk-test-key")
+	defer clearEnv()
+
+	cfg, err := LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv failed: %v", err)
+	}
+	if cfg.AuditLog != "" || cfg.AuditIncludeContent {
+		t.Errorf("Audit logging should be disabled by default, got %q (content: %v)", cfg.AuditLog, cfg.AuditIncludeContent)
+	}
+
+	os.Setenv("CLASP_AUDIT_LOG", "/var/log/clasp/audit.jsonl")
+	os.Setenv("CLASP_AUDIT_INCLUDE_CONTENT", "1")
+	cfg, err = LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv failed: %v", err)
+	}
+	if cfg.AuditLog != "/var/log/clasp/audit.jsonl" {
+		t.Errorf("Expected AuditLog from CLASP_AUDIT_LOG, got %q", cfg.AuditLog)
+	}
+	if !cfg.AuditIncludeContent {
+		t.Error("AuditIncludeContent should be enabled by CLASP_AUDIT_INCLUDE_CONTENT=1")
+	}
+}
+
+func TestLoadFromEnv_DebugSampleRate(t *testing.T) {
+	clearEnv()
+	os.Setenv("OPENAI_API_KEY", "sk-test-key")
+	defer clearEnv()
+
+	cfg, err := LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv failed: %v", err)
+	}
+	if cfg.DebugSampleRate != 0 {
+		t.Errorf("Expected no debug sampling by default, got %v", cfg.DebugSampleRate)
+	}
+
+	os.Setenv("CLASP_DEBUG_SAMPLE_RATE", "0.05")
+	cfg, err = LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv failed: %v", err)
+	}
+	if cfg.DebugSampleRate != 0.05 {
+		t.Errorf("Expected DebugSampleRate 0.05, got %v", cfg.DebugSampleRate)
+	}
+
+	for _, invalid := range []string{"1.5", "-0.1", "often"} {
+		os.Setenv("CLASP_DEBUG_SAMPLE_RATE", invalid)
+		if _, err := LoadFromEnv(); err == nil {
+			t.Errorf("Expected an error for CLASP_DEBUG_SAMPLE_RATE=%s", invalid)
+		}
+	}
+}
+
+func TestLoadFromEnv_Penalties(t *testing.T) {
+	clearEnv()
+	os.Setenv("OPENAI_API_KEY", "sk-test-key")
+	defer clearEnv()
+
+	cfg, err := LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv failed: %v", err)
+	}
+	if cfg.PresencePenalty != nil || cfg.FrequencyPenalty != nil {
+		t.Errorf("Penalties should be unset by default, got %v and %v", cfg.PresencePenalty, cfg.FrequencyPenalty)
+	}
+
+	os.Setenv("CLASP_PRESENCE_PENALTY", "0.6")
+	os.Setenv("CLASP_FREQUENCY_PENALTY", "-1.5")
+	cfg, err = LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv failed: %v", err)
+	}
+	if cfg.PresencePenalty == nil || *cfg.PresencePenalty != 0.6 {
+		t.Errorf("PresencePenalty = %v, want 0.6", cfg.PresencePenalty)
+	}
+	if cfg.FrequencyPenalty == nil || *cfg.FrequencyPenalty != -1.5 {
+		t.Errorf("FrequencyPenalty = %v, want -1.5", cfg.FrequencyPenalty)
+	}
+
+	os.Setenv("CLASP_FREQUENCY_PENALTY", "2.5")
+	if _, err := LoadFromEnv(); err == nil {
+		t.Error("expected error for CLASP_FREQUENCY_PENALTY out of range")
+	}
+	os.Setenv("CLASP_FREQUENCY_PENALTY", "")
+	os.Setenv("CLASP_PRESENCE_PENALTY", "high")
+	if _, err := LoadFromEnv(); err == nil {
+		t.Error("expected error for invalid CLASP_PRESENCE_PENALTY")
+	}
+}
+
+func TestLoadFromEnv_FinishReasonMap(t *testing.T) {
+	clearEnv()
+	os.Setenv("OPENAI_API_KEY", "sk-test-key")
+	defer clearEnv()
+
+	os.Setenv("CLASP_FINISH_REASON_MAP", "eos:end_turn, safety:refusal")
+	cfg, err := LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv failed: %v", err)
+	}
+	if cfg.FinishReasonMap["eos"] != "end_turn" || cfg.FinishReasonMap["safety"] != "refusal" {
+		t.Errorf("FinishReasonMap = %v", cfg.FinishReasonMap)
+	}
+
+	os.Setenv("CLASP_FINISH_REASON_MAP", "safety")
+	if _, err := LoadFromEnv(); err == nil {
+		t.Error("expected error for malformed CLASP_FINISH_REASON_MAP")
+	}
+}
+
+func TestLoadFromEnv_MultiChoice(t *testing.T) {
+	clearEnv()
+	os.Setenv("OPENAI_API_KEY", "sk-test-key")
+	defer clearEnv()
+
+	cfg, err := LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv failed: %v", err)
+	}
+	if cfg.MultiChoice != "reject" {
+		t.Errorf("MultiChoice = %q, want reject", cfg.MultiChoice)
+	}
+
+	os.Setenv("CLASP_MULTI_CHOICE", "return")
+	cfg, err = LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv failed: %v", err)
+	}
+	if cfg.MultiChoice != "return" {
+		t.Errorf("MultiChoice = %q, want return", cfg.MultiChoice)
+	}
+
+	os.Setenv("CLASP_MULTI_CHOICE", "all")
+	if _, err := LoadFromEnv(); err == nil {
+		t.Error("expected error for invalid CLASP_MULTI_CHOICE")
+	}
+}
+
+func TestLoadFromEnv_Retry(t *testing.T) {
+	clearEnv()
+	os.Setenv("OPENAI_API_KEY", "sk-test-key")
+	defer clearEnv()
+
+	cfg, err := LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv failed: %v", err)
+	}
+	if cfg.RetryMax != 3 || cfg.RetryBaseDelayMs != 500 || cfg.RetryMaxDelayMs != 10000 {
+		t.Errorf("unexpected retry defaults: max=%d base=%d maxDelay=%d", cfg.RetryMax, cfg.RetryBaseDelayMs, cfg.RetryMaxDelayMs)
+	}
+	if !cfg.RetryJitter {
+		t.Error("RetryJitter should default to true")
+	}
+
+	os.Setenv("CLASP_RETRY_MAX", "5")
+	os.Setenv("CLASP_RETRY_BASE_DELAY_MS", "100")
+	os.Setenv("CLASP_RETRY_MAX_DELAY_MS", "2000")
+	cfg, err = LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv failed: %v", err)
+	}
+	if cfg.RetryMax != 5 || cfg.RetryBaseDelayMs != 100 || cfg.RetryMaxDelayMs != 2000 {
+		t.Errorf("unexpected retry settings: max=%d base=%d maxDelay=%d", cfg.RetryMax, cfg.RetryBaseDelayMs, cfg.RetryMaxDelayMs)
+	}
+
+	os.Setenv("CLASP_RETRY_JITTER", "false")
+	cfg, err = LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv failed: %v", err)
+	}
+	if cfg.RetryJitter {
+		t.Error("RetryJitter should be disabled by CLASP_RETRY_JITTER=false")
+	}
+
+	os.Setenv("CLASP_RETRY_MAX", "0")
+	if _, err := LoadFromEnv(); err == nil {
+		t.Error("expected error for CLASP_RETRY_MAX=0")
+	}
+}
+
+func TestLoadFromEnv_RetryBudgetRatio(t *testing.T) {
+	clearEnv()
+	os.Setenv("OPENAI_API_KEY", "sk-test-key")
+	defer clearEnv()
+
+	cfg, err := LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv failed: %v", err)
+	}
+	if cfg.RetryBudgetRatio != 0 {
+		t.Errorf("RetryBudgetRatio should default to 0 (unlimited), got %g", cfg.RetryBudgetRatio)
+	}
+
+	os.Setenv("CLASP_RETRY_BUDGET_RATIO", "0.1")
+	cfg, err = LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv failed: %v", err)
+	}
+	if cfg.RetryBudgetRatio != 0.1 {
+		t.Errorf("expected RetryBudgetRatio 0.1, got %g", cfg.RetryBudgetRatio)
+	}
+
+	for _, value := range []string{"-0.5", "ten percent"} {
+		os.Setenv("CLASP_RETRY_BUDGET_RATIO", value)
+		if _, err := LoadFromEnv(); err == nil {
+			t.Errorf("expected error for CLASP_RETRY_BUDGET_RATIO=%s", value)
+		}
+	}
+}
+
+func TestLoadFromEnv_HealthCheckUpstream(t *testing.T) {
+	clearEnv()
+	os.Setenv("OPENAI_API_KEY", "sk-test-key")
+	defer clearEnv()
+
+	cfg, err := LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv failed: %v", err)
+	}
+	if cfg.HealthCheckUpstream || cfg.HealthCheckUpstreamTTLSec != 30 {
+		t.Errorf("unexpected defaults: upstream=%v ttl=%d", cfg.HealthCheckUpstream, cfg.HealthCheckUpstreamTTLSec)
+	}
+
+	os.Setenv("CLASP_HEALTH_CHECK_UPSTREAM", "true")
+	os.Setenv("CLASP_HEALTH_CHECK_UPSTREAM_TTL", "5")
+	cfg, err = LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv failed: %v", err)
+	}
+	if !cfg.HealthCheckUpstream || cfg.HealthCheckUpstreamTTLSec != 5 {
+		t.Errorf("unexpected settings: upstream=%v ttl=%d", cfg.HealthCheckUpstream, cfg.HealthCheckUpstreamTTLSec)
+	}
+
+	os.Setenv("CLASP_HEALTH_CHECK_UPSTREAM_TTL", "soon")
+	if _, err := LoadFromEnv(); err == nil {
+		t.Error("expected error for invalid CLASP_HEALTH_CHECK_UPSTREAM_TTL")
+	}
+}
+
+func TestLoadFromEnv_QueuePersist(t *testing.T) {
+	clearEnv()
+	os.Setenv("OPENAI_API_KEY", "sk-test-key")
+	os.Setenv("CLASP_QUEUE_PERSIST", "true")
+	os.Setenv("CLASP_QUEUE_PERSIST_DIR", "/var/lib/clasp/queue")
+	os.Setenv("CLASP_QUEUE_DLQ_DIR", "/var/lib/clasp/dlq")
+	defer clearEnv()
+
+	cfg, err := LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv failed: %v", err)
+	}
+	if !cfg.QueuePersist {
+		t.Error("QueuePersist should be true")
+	}
+	if cfg.QueuePersistDir != "/var/lib/clasp/queue" {
+		t.Errorf("QueuePersistDir = %q, want /var/lib/clasp/queue", cfg.QueuePersistDir)
+	}
+	if cfg.QueueDLQDir != "/var/lib/clasp/dlq" {
+		t.Errorf("QueueDLQDir = %q, want /var/lib/clasp/dlq", cfg.QueueDLQDir)
+	}
+}
+
+func TestLoadFromEnv_CircuitBreakerHalfOpenMax(t *testing.T) {
+	clearEnv()
+	os.Setenv("OPENAI_API_KEY", "sk-test-key")
+	defer clearEnv()
+
+	cfg, err := LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv failed: %v", err)
+	}
+	if cfg.CircuitBreakerHalfOpenMax != 1 {
+		t.Errorf("CircuitBreakerHalfOpenMax = %d, want 1", cfg.CircuitBreakerHalfOpenMax)
+	}
+
+	os.Setenv("CLASP_CB_HALF_OPEN_MAX", "3")
+	cfg, err = LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv failed: %v", err)
+	}
+	if cfg.CircuitBreakerHalfOpenMax != 3 {
+		t.Errorf("CircuitBreakerHalfOpenMax = %d, want 3", cfg.CircuitBreakerHalfOpenMax)
+	}
+
+	os.Setenv("CLASP_CB_HALF_OPEN_MAX", "0")
+	if _, err := LoadFromEnv(); err == nil {
+		t.Error("expected error for CLASP_CB_HALF_OPEN_MAX=0")
+	}
+}
+
+func TestLoadFromEnv_Alerts(t *testing.T) {
+	clearEnv()
+	os.Setenv("OPENAI_API_KEY", "sk-test-key")
+	os.Setenv("CLASP_ALERT_WEBHOOK_URL", "https://hooks.example.com/clasp")
+	os.Setenv("CLASP_ALERT_BUDGET_USD", "25.5")
+	defer clearEnv()
+
+	cfg, err := LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv failed: %v", err)
+	}
+	if cfg.AlertWebhookURL != "https://hooks.example.com/clasp" || cfg.AlertBudgetUSD != 25.5 {
+		t.Errorf("unexpected alert settings: url=%q budget=%v", cfg.AlertWebhookURL, cfg.AlertBudgetUSD)
+	}
+
+	for _, invalid := range []string{"lots", "-1"} {
+		os.Setenv("CLASP_ALERT_BUDGET_USD", invalid)
+		if _, err := LoadFromEnv(); err == nil {
+			t.Errorf("expected error for CLASP_ALERT_BUDGET_USD=%s", invalid)
+		}
+	}
+}
+
+func TestLoadFromEnv_ProviderEndpoints(t *testing.T) {
+	clearEnv()
+	os.Setenv("OPENAI_API_KEY", "sk-test-key")
+	os.Setenv("CLASP_OPENAI_ENDPOINTS", "https://us.example.com/v1, https://eu.example.com/v1/")
+	defer clearEnv()
+
+	cfg, err := LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv failed: %v", err)
+	}
+	want := []string{"https://us.example.com/v1", "https://eu.example.com/v1"}
+	got := cfg.ProviderEndpoints[ProviderOpenAI]
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("ProviderEndpoints[openai] = %v, want %v", got, want)
+	}
+	if _, ok := cfg.ProviderEndpoints[ProviderCustom]; ok {
+		t.Error("no endpoints should be set for unconfigured providers")
+	}
+
+	os.Setenv("CLASP_CUSTOM_ENDPOINTS", "not-a-url")
+	if _, err := LoadFromEnv(); err == nil {
+		t.Error("expected error for invalid CLASP_CUSTOM_ENDPOINTS")
+	}
+}
+
+func TestLoadFromEnv_RequestTimeoutBounds(t *testing.T) {
+	clearEnv()
+	os.Setenv("OPENAI_API_KEY", "sk-test-key")
+	defer clearEnv()
+
+	cfg, err := LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv failed: %v", err)
+	}
+	if cfg.RequestTimeoutMinSec != 1 || cfg.RequestTimeoutMaxSec != 3600 {
+		t.Errorf("unexpected timeout bounds: min=%d max=%d", cfg.RequestTimeoutMinSec, cfg.RequestTimeoutMaxSec)
+	}
+
+	os.Setenv("CLASP_REQUEST_TIMEOUT_MIN", "5")
+	os.Setenv("CLASP_REQUEST_TIMEOUT_MAX", "900")
+	cfg, err = LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv failed: %v", err)
+	}
+	if cfg.RequestTimeoutMinSec != 5 || cfg.RequestTimeoutMaxSec != 900 {
+		t.Errorf("unexpected timeout bounds: min=%d max=%d", cfg.RequestTimeoutMinSec, cfg.RequestTimeoutMaxSec)
+	}
+
+	os.Setenv("CLASP_REQUEST_TIMEOUT_MIN", "1000")
+	if _, err := LoadFromEnv(); err == nil {
+		t.Error("expected error when min exceeds max")
+	}
+}
+
+func TestLoadFromEnv_BodyLimits(t *testing.T) {
+	clearEnv()
+	os.Setenv("OPENAI_API_KEY", "sk-test-key")
+	defer clearEnv()
+
+	cfg, err := LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv failed: %v", err)
+	}
+	if cfg.MaxRequestBytes != 32<<20 || cfg.MaxResponseBytes != 64<<20 {
+		t.Errorf("unexpected body limits: request=%d response=%d", cfg.MaxRequestBytes, cfg.MaxResponseBytes)
+	}
+
+	os.Setenv("CLASP_MAX_REQUEST_BYTES", "1024")
+	os.Setenv("CLASP_MAX_RESPONSE_BYTES", "0")
+	cfg, err = LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv failed: %v", err)
+	}
+	if cfg.MaxRequestBytes != 1024 || cfg.MaxResponseBytes != 0 {
+		t.Errorf("unexpected body limits: request=%d response=%d", cfg.MaxRequestBytes, cfg.MaxResponseBytes)
+	}
+
+	os.Setenv("CLASP_MAX_REQUEST_BYTES", "32MB")
+	if _, err := LoadFromEnv(); err == nil {
+		t.Error("expected error for invalid CLASP_MAX_REQUEST_BYTES")
+	}
+}
+
+func TestLoadFromEnv_MaxConcurrent(t *testing.T) {
+	clearEnv()
+	os.Setenv("OPENAI_API_KEY", "sk-test-key")
+	defer clearEnv()
+
+	cfg, err := LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv failed: %v", err)
+	}
+	if cfg.MaxConcurrent != 0 {
+		t.Errorf("MaxConcurrent = %d, want 0 (unlimited)", cfg.MaxConcurrent)
+	}
+
+	os.Setenv("CLASP_MAX_CONCURRENT", "16")
+	cfg, err = LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv failed: %v", err)
+	}
+	if cfg.MaxConcurrent != 16 {
+		t.Errorf("MaxConcurrent = %d, want 16", cfg.MaxConcurrent)
+	}
+
+	os.Setenv("CLASP_MAX_CONCURRENT", "-1")
+	if _, err := LoadFromEnv(); err == nil {
+		t.Error("expected error for negative CLASP_MAX_CONCURRENT")
+	}
+}
+
+func TestLoadFromEnv_IPFilter(t *testing.T) {
+	clearEnv()
+	os.Setenv("OPENAI_API_KEY", "sk-test-key")
+	defer clearEnv()
+
+	os.Setenv("CLASP_IP_ALLOWLIST", "10.0.0.0/8, 192.168.1.5,::1")
+	os.Setenv("CLASP_IP_DENYLIST", "10.1.0.0/16")
+	os.Setenv("CLASP_TRUST_PROXY_HEADERS", "true")
+	cfg, err := LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv failed: %v", err)
+	}
+	wantAllow := []string{"10.0.0.0/8", "192.168.1.5/32", "::1/128"}
+	if len(cfg.IPAllowlist) != len(wantAllow) {
+		t.Fatalf("IPAllowlist = %v, want %v", cfg.IPAllowlist, wantAllow)
+	}
+	for i, cidr := range wantAllow {
+		if cfg.IPAllowlist[i] != cidr {
+			t.Errorf("IPAllowlist[%d] = %q, want %q", i, cfg.IPAllowlist[i], cidr)
+		}
+	}
+	if len(cfg.IPDenylist) != 1 || cfg.IPDenylist[0] != "10.1.0.0/16" {
+		t.Errorf("IPDenylist = %v, want [10.1.0.0/16]", cfg.IPDenylist)
+	}
+	if !cfg.TrustProxyHeaders {
+		t.Error("TrustProxyHeaders should be true")
+	}
+
+	os.Setenv("CLASP_IP_DENYLIST", "10.1.0.0/33")
+	if _, err := LoadFromEnv(); err == nil {
+		t.Error("expected error for invalid CLASP_IP_DENYLIST")
+	}
+}
+
+func TestLoadFromEnv_CORSOrigins(t *testing.T) {
+	clearEnv()
+	os.Setenv("OPENAI_API_KEY", "sk-test-key")
+	defer clearEnv()
+
+	cfg, err := LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv failed: %v", err)
+	}
+	if len(cfg.CORSOrigins) != 0 {
+		t.Errorf("CORSOrigins = %v, want none (disabled)", cfg.CORSOrigins)
+	}
+
+	os.Setenv("CLASP_CORS_ORIGINS", "https://app.example.com/, http://localhost:3000")
+	cfg, err = LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv failed: %v", err)
+	}
+	if len(cfg.CORSOrigins) != 2 || cfg.CORSOrigins[0] != "https://app.example.com" || cfg.CORSOrigins[1] != "http://localhost:3000" {
+		t.Errorf("CORSOrigins = %v", cfg.CORSOrigins)
+	}
+}
+
+func TestLoadFromEnv_AuthAnonymousEndpoints(t *testing.T) {
+	clearEnv()
+	os.Setenv("OPENAI_API_KEY", "sk-test-key")
+	defer clearEnv()
+
+	cfg, err := LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv failed: %v", err)
+	}
+	if cfg.AuthAnonymousEndpoints != nil {
+		t.Errorf("AuthAnonymousEndpoints = %v, want nil by default", cfg.AuthAnonymousEndpoints)
+	}
+
+	os.Setenv("CLASP_AUTH_ANONYMOUS_ENDPOINTS", "/health, /metrics/prometheus")
+	cfg, err = LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv failed: %v", err)
+	}
+	if len(cfg.AuthAnonymousEndpoints) != 2 || cfg.AuthAnonymousEndpoints[0] != "/health" || cfg.AuthAnonymousEndpoints[1] != "/metrics/prometheus" {
+		t.Errorf("AuthAnonymousEndpoints = %v", cfg.AuthAnonymousEndpoints)
+	}
+
+	os.Setenv("CLASP_AUTH_ANONYMOUS_ENDPOINTS", "health")
+	if _, err := LoadFromEnv(); err == nil {
+		t.Error("Expected an error for a path without a leading /")
+	}
+}
+
+func TestLoadFromEnv_SSEHeartbeat(t *testing.T) {
+	clearEnv()
+	os.Setenv("OPENAI_API_KEY", "sk-test-key")
+	defer clearEnv()
+
+	cfg, err := LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv failed: %v", err)
+	}
+	if cfg.SSEHeartbeatSeconds != 0 {
+		t.Errorf("SSEHeartbeatSeconds = %d, want 0 (disabled)", cfg.SSEHeartbeatSeconds)
+	}
+
+	os.Setenv("CLASP_SSE_HEARTBEAT_SECONDS", "15")
+	cfg, err = LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv failed: %v", err)
+	}
+	if cfg.SSEHeartbeatSeconds != 15 {
+		t.Errorf("SSEHeartbeatSeconds = %d, want 15", cfg.SSEHeartbeatSeconds)
+	}
+}
+
+func TestLoadFromEnv_UpstreamHTTPVersion(t *testing.T) {
+	clearEnv()
+	os.Setenv("OPENAI_API_KEY", "sk-test-key")
+	defer clearEnv()
+
+	cfg, err := LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv failed: %v", err)
+	}
+	if cfg.UpstreamHTTPVersion != "auto" {
+		t.Errorf("UpstreamHTTPVersion should default to auto, got %q", cfg.UpstreamHTTPVersion)
+	}
+
+	os.Setenv("CLASP_UPSTREAM_HTTP_VERSION", "h1")
+	cfg, err = LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv failed: %v", err)
+	}
+	if cfg.UpstreamHTTPVersion != "h1" {
+		t.Errorf("Expected h1, got %q", cfg.UpstreamHTTPVersion)
+	}
+
+	os.Setenv("CLASP_UPSTREAM_HTTP_VERSION", "http3")
+	if _, err := LoadFromEnv(); err == nil {
+		t.Error("Expected an error for an unknown HTTP version")
+	}
+}
+
+func TestLoadFromEnv_ResponsesOptions(t *testing.T) {
+	clearEnv()
+	os.Setenv("OPENAI_API_KEY", "sk-test-key")
+	defer clearEnv()
+
+	cfg, err := LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv failed: %v", err)
+	}
+	if cfg.ResponsesStore != nil || cfg.ResponsesReasoningSummary != "" {
+		t.Errorf("Responses options should be unset by default, got store=%v summary=%q", cfg.ResponsesStore, cfg.ResponsesReasoningSummary)
+	}
+
+	os.Setenv("CLASP_RESPONSES_STORE", "false")
+	os.Setenv("CLASP_RESPONSES_REASONING_SUMMARY", "concise")
+	cfg, err = LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv failed: %v", err)
+	}
+	if cfg.ResponsesStore == nil || *cfg.ResponsesStore {
+		t.Errorf("Expected ResponsesStore=false, got %v", cfg.ResponsesStore)
+	}
+	if cfg.ResponsesReasoningSummary != "concise" {
+		t.Errorf("Expected ResponsesReasoningSummary=concise, got %q", cfg.ResponsesReasoningSummary)
+	}
+
+	// Compaction continues from stored responses
+	os.Setenv("CLASP_COMPACTION", "true")
+	if _, err := LoadFromEnv(); err == nil {
+		t.Error("Expected an error for CLASP_RESPONSES_STORE=false with CLASP_COMPACTION")
+	}
+	os.Unsetenv("CLASP_COMPACTION")
+
+	os.Setenv("CLASP_RESPONSES_REASONING_SUMMARY", "verbose")
+	if _, err := LoadFromEnv(); err == nil {
+		t.Error("Expected an error for an unknown reasoning summary")
+	}
+}
+
+func TestLoadFromEnv_ResponsesContinuity(t *testing.T) {
+	clearEnv()
+	os.Setenv("OPENAI_API_KEY", "sk-test-key")
+	defer clearEnv()
+
+	cfg, err := LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv failed: %v", err)
+	}
+	if cfg.CompactionEnabled || cfg.SessionFile != "" {
+		t.Errorf("Continuity should be off by default, got compaction=%v file=%q", cfg.CompactionEnabled, cfg.SessionFile)
+	}
+
+	os.Setenv("CLASP_RESPONSES_CONTINUITY", "true")
+	os.Setenv("CLASP_RESPONSES_CONTINUITY_FILE", "/tmp/clasp-sessions.json")
+	cfg, err = LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv failed: %v", err)
+	}
+	if !cfg.CompactionEnabled {
+		t.Error("Expected CLASP_RESPONSES_CONTINUITY to enable compaction")
+	}
+	if cfg.SessionFile != "/tmp/clasp-sessions.json" {
+		t.Errorf("Expected SessionFile=/tmp/clasp-sessions.json, got %q", cfg.SessionFile)
+	}
+
+	// Continuity needs the upstream to store responses
+	os.Setenv("CLASP_RESPONSES_STORE", "false")
+	if _, err := LoadFromEnv(); err == nil {
+		t.Error("Expected an error for CLASP_RESPONSES_STORE=false with CLASP_RESPONSES_CONTINUITY")
+	}
+}
+
+func TestLoadFromEnv_ExtraHeaders(t *testing.T) {
+	clearEnv()
+	os.Setenv("OPENAI_API_KEY", "sk-test-key")
+	defer clearEnv()
+
+	os.Setenv("CLASP_EXTRA_HEADERS", `{"HTTP-Referer": "https://example.com", "X-Title": "My App"}`)
+	cfg, err := LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv failed: %v", err)
+	}
+	if cfg.ExtraHeaders["HTTP-Referer"] != "https://example.com" || cfg.ExtraHeaders["X-Title"] != "My App" {
+		t.Errorf("ExtraHeaders = %v", cfg.ExtraHeaders)
+	}
+
+	os.Setenv("CLASP_EXTRA_HEADERS", "X-Team=platform; X-Env = prod;")
+	os.Setenv("CLASP_OPUS_PROVIDER", "openai")
+	os.Setenv("CLASP_OPUS_EXTRA_HEADERS", "X-Env=opus")
+	cfg, err = LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv failed: %v", err)
+	}
+	if len(cfg.ExtraHeaders) != 2 || cfg.ExtraHeaders["X-Team"] != "platform" || cfg.ExtraHeaders["X-Env"] != "prod" {
+		t.Errorf("ExtraHeaders = %v", cfg.ExtraHeaders)
+	}
+	if cfg.TierOpus == nil || cfg.TierOpus.ExtraHeaders["X-Env"] != "opus" {
+		t.Errorf("Expected the Opus tier to carry its extra headers, got %+v", cfg.TierOpus)
+	}
+
+	for _, raw := range []string{"X-Team", "Bad Name=1", `{"X-Team": 1}`} {
+		os.Setenv("CLASP_EXTRA_HEADERS", raw)
+		if _, err := LoadFromEnv(); err == nil {
+			t.Errorf("Expected an error for CLASP_EXTRA_HEADERS=%q", raw)
+		}
+	}
+}
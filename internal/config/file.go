@@ -112,6 +112,8 @@ type TierFileConfig struct {
 	Model    string `yaml:"model,omitempty"`
 	APIKey   string `yaml:"api_key,omitempty"`
 	BaseURL  string `yaml:"base_url,omitempty"`
+	// HTTPTimeoutSec overrides http_client.timeout_sec for this tier
+	HTTPTimeoutSec int `yaml:"http_timeout_sec,omitempty"`
 	// Fallback configuration
 	Fallback TierFallbackConfig `yaml:"fallback,omitempty"`
 }
@@ -181,6 +183,8 @@ type AuthConfig struct {
 	APIKey                string `yaml:"api_key,omitempty"`
 	AllowAnonymousHealth  *bool  `yaml:"allow_anonymous_health,omitempty"`
 	AllowAnonymousMetrics *bool  `yaml:"allow_anonymous_metrics,omitempty"`
+
+	AnonymousEndpoints []string `yaml:"anonymous_endpoints,omitempty"`
 }
 
 // QueueConfig holds queue settings.
@@ -256,46 +260,52 @@ func DefaultFileConfig() *FileConfig {
 	}
 }
 
-// LoadFromFile loads configuration from a YAML file.
-// The path can be specified via CLASP_CONFIG_FILE environment variable.
-// If no path is specified, it looks for clasp.yaml in the current directory.
-func LoadFromFile(path string) (*FileConfig, error) {
-	// If no path specified, check environment variable
-	if path == "" {
-		path = os.Getenv("CLASP_CONFIG_FILE")
+// FindConfigFile returns the path of the YAML config file LoadFromFile uses:
+// CLASP_CONFIG_FILE if set, otherwise the first file found in the standard
+// locations. It returns "" if there is none.
+func FindConfigFile() string {
+	if path := os.Getenv("CLASP_CONFIG_FILE"); path != "" {
+		return path
 	}
 
-	// If still no path, look for default locations
-	if path == "" {
-		// Check for config files in standard locations
-		candidates := []string{
-			"clasp.yaml",
-			"clasp.yml",
-			".clasp.yaml",
-			".clasp.yml",
-		}
+	// Check for config files in standard locations
+	candidates := []string{
+		"clasp.yaml",
+		"clasp.yml",
+		".clasp.yaml",
+		".clasp.yml",
+	}
 
-		// Also check home directory
-		homeDir, err := os.UserHomeDir()
-		if err == nil {
-			candidates = append(candidates,
-				filepath.Join(homeDir, ".clasp", "config.yaml"),
-				filepath.Join(homeDir, ".clasp", "config.yml"),
-				filepath.Join(homeDir, ".config", "clasp", "config.yaml"),
-				filepath.Join(homeDir, ".config", "clasp", "config.yml"),
-			)
-		}
+	// Also check home directory
+	homeDir, err := os.UserHomeDir()
+	if err == nil {
+		candidates = append(candidates,
+			filepath.Join(homeDir, ".clasp", "config.yaml"),
+			filepath.Join(homeDir, ".clasp", "config.yml"),
+			filepath.Join(homeDir, ".config", "clasp", "config.yaml"),
+			filepath.Join(homeDir, ".config", "clasp", "config.yml"),
+		)
+	}
 
-		// Also check /etc for system-wide config
-		candidates = append(candidates, "/etc/clasp/config.yaml", "/etc/clasp/config.yml")
+	// Also check /etc for system-wide config
+	candidates = append(candidates, "/etc/clasp/config.yaml", "/etc/clasp/config.yml")
 
-		for _, candidate := range candidates {
-			if _, err := os.Stat(candidate); err == nil {
-				path = candidate
-				break
-			}
+	for _, candidate := range candidates {
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate
 		}
 	}
+	return ""
+}
+
+// LoadFromFile loads configuration from a YAML file.
+// The path can be specified via CLASP_CONFIG_FILE environment variable.
+// If no path is specified, it looks for clasp.yaml in the current directory
+// and the other locations checked by FindConfigFile.
+func LoadFromFile(path string) (*FileConfig, error) {
+	if path == "" {
+		path = FindConfigFile()
+	}
 
 	// If no config file found, return nil (not an error - use env vars)
 	if path == "" {
@@ -303,6 +313,9 @@ func LoadFromFile(path string) (*FileConfig, error) {
 	}
 
 	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to read config file %s: %w", path, err)
 	}
@@ -556,6 +569,9 @@ func MergeWithEnv(fileCfg *FileConfig, envCfg *Config) *Config {
 	if fileCfg.Auth.AllowAnonymousMetrics != nil {
 		cfg.AuthAllowAnonymousMetrics = *fileCfg.Auth.AllowAnonymousMetrics
 	}
+	if fileCfg.Auth.AnonymousEndpoints != nil {
+		cfg.AuthAnonymousEndpoints = fileCfg.Auth.AnonymousEndpoints
+	}
 
 	// Queue
 	cfg.QueueEnabled = fileCfg.Queue.Enabled
@@ -608,15 +624,16 @@ func MergeWithEnv(fileCfg *FileConfig, envCfg *Config) *Config {
 
 // convertTierFileConfig converts a TierFileConfig to TierConfig.
 func convertTierFileConfig(tier *TierFileConfig, cfg *Config) *TierConfig {
-	if tier == nil || (tier.Provider == "" && tier.Model == "") {
+	if tier == nil || (tier.Provider == "" && tier.Model == "" && tier.HTTPTimeoutSec == 0) {
 		return nil
 	}
 
 	tc := &TierConfig{
-		Provider: ProviderType(tier.Provider),
-		Model:    tier.Model,
-		APIKey:   tier.APIKey,
-		BaseURL:  tier.BaseURL,
+		Provider:       ProviderType(tier.Provider),
+		Model:          tier.Model,
+		APIKey:         tier.APIKey,
+		BaseURL:        tier.BaseURL,
+		HTTPTimeoutSec: tier.HTTPTimeoutSec,
 	}
 
 	// Inherit API key if not specified
@@ -884,6 +901,11 @@ func overlayEnvVars(cfg *Config) {
 	if os.Getenv("CLASP_AUTH_ALLOW_ANONYMOUS_METRICS") == "true" || os.Getenv("CLASP_AUTH_ALLOW_ANONYMOUS_METRICS") == "1" {
 		cfg.AuthAllowAnonymousMetrics = true
 	}
+	if raw := os.Getenv("CLASP_AUTH_ANONYMOUS_ENDPOINTS"); raw != "" {
+		if paths, err := parsePathList(raw); err == nil {
+			cfg.AuthAnonymousEndpoints = paths
+		}
+	}
 
 	// Queue
 	if os.Getenv("CLASP_QUEUE") == "true" || os.Getenv("CLASP_QUEUE") == "1" {
@@ -964,6 +986,9 @@ func overlayEnvVars(cfg *Config) {
 	for k, v := range envAliases {
 		cfg.ModelAliases[k] = v
 	}
+	if rules, err := loadAliasRules(); err == nil && len(rules) > 0 {
+		cfg.AliasRules = rules
+	}
 }
 
 // parseInt is a helper to parse integers.
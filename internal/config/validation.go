@@ -3,6 +3,7 @@ package config
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 )
 
@@ -251,8 +252,12 @@ func validateAuthConfig(cfg *AuthConfig) error {
 
 // validateTierFileConfig validates a tier configuration.
 func validateTierFileConfig(cfg *TierFileConfig, tierName string) error {
-	if cfg.Provider == "" && cfg.Model == "" {
-		return fmt.Errorf("multi_provider.%s: at least provider or model must be specified", tierName)
+	if cfg.Provider == "" && cfg.Model == "" && cfg.HTTPTimeoutSec == 0 {
+		return fmt.Errorf("multi_provider.%s: at least provider, model or http_timeout_sec must be specified", tierName)
+	}
+
+	if cfg.HTTPTimeoutSec < 0 {
+		return fmt.Errorf("multi_provider.%s.http_timeout_sec must be 0 or greater", tierName)
 	}
 
 	// Validate provider if specified
@@ -285,3 +290,58 @@ func ValidateConfigFile(path string) error {
 
 	return ValidateFileConfig(cfg)
 }
+
+// ValidateModelAliases checks alias names and targets, returning an error for
+// each invalid entry. Names can't contain commas, colons or whitespace since
+// aliases are also passed around as CLASP_MODEL_ALIASES lists.
+func ValidateModelAliases(aliases map[string]string) []error {
+	names := make([]string, 0, len(aliases))
+	for name := range aliases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []error
+	for _, name := range names {
+		if err := validateAlias(name, aliases[name]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// ValidateModelAliasList checks a CLASP_MODEL_ALIASES value
+// (alias1:model1,alias2:model2). Malformed entries are skipped when the
+// configuration loads, so this reports them instead.
+func ValidateModelAliasList(list string) []error {
+	var errs []error
+	for _, pair := range strings.Split(list, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		parts := strings.SplitN(pair, ":", 2)
+		if len(parts) != 2 {
+			errs = append(errs, fmt.Errorf("alias %q must be in the form alias:model", pair))
+			continue
+		}
+		if err := validateAlias(strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// validateAlias validates a single alias definition.
+func validateAlias(name, target string) error {
+	if name == "" {
+		return fmt.Errorf("alias for %q has an empty name", target)
+	}
+	if strings.ContainsAny(name, ",: \t") {
+		return fmt.Errorf("alias %q must not contain commas, colons or spaces", name)
+	}
+	if strings.TrimSpace(target) == "" {
+		return fmt.Errorf("alias %q has no target model", name)
+	}
+	return nil
+}
@@ -271,6 +271,29 @@ func EnableDebugLogging() error {
 	mu.Lock()
 	defer mu.Unlock()
 
+	if debugLogger == nil {
+		if err := openDebugLogLocked(); err != nil {
+			return err
+		}
+	}
+	debugEnabled = true
+	return nil
+}
+
+// EnableDebugSampling opens debug.log for sampled request captures without
+// enabling full debug logging. Only LogDebugRequestRaw writes to it.
+func EnableDebugSampling() error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if debugLogger != nil {
+		return nil
+	}
+	return openDebugLogLocked()
+}
+
+// openDebugLogLocked opens the debug log file. The caller holds mu.
+func openDebugLogLocked() error {
 	// Ensure session ID is set
 	if sessionID == "" {
 		sessionID = GenerateSessionID()
@@ -304,7 +327,6 @@ func EnableDebugLogging() error {
 	}
 
 	debugFile = f
-	debugEnabled = true
 	debugLogger = log.New(f, "", log.LstdFlags|log.Lmicroseconds)
 
 	// Log debug session start with session ID
@@ -360,7 +382,8 @@ func LogDebugRequestRaw(direction, endpoint string, data []byte) {
 	mu.Lock()
 	defer mu.Unlock()
 
-	if !debugEnabled || debugLogger == nil {
+	// Also written for sampled requests when only debug sampling is enabled
+	if debugLogger == nil {
 		return
 	}
 
@@ -453,7 +476,7 @@ func cleanOldDebugLogs() {
 func GetDebugLogFilePath() string {
 	mu.Lock()
 	defer mu.Unlock()
-	if debugEnabled {
+	if debugLogger != nil {
 		return debugFilePath
 	}
 	return ""
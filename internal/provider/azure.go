@@ -44,6 +44,13 @@ func (p *AzureProvider) GetEndpointURL() string {
 		p.Endpoint, p.DeploymentName, p.APIVersion)
 }
 
+// GetEmbeddingsURL returns the embeddings URL for a deployment. Azure serves
+// embedding models from their own deployments, so the model names the deployment.
+func (p *AzureProvider) GetEmbeddingsURL(model string) string {
+	return fmt.Sprintf("%s/openai/deployments/%s/embeddings?api-version=%s",
+		p.Endpoint, model, p.APIVersion)
+}
+
 // TransformModelID returns the deployment name for Azure.
 func (p *AzureProvider) TransformModelID(modelID string) string {
 	// Azure uses deployment names, not model IDs
@@ -47,6 +47,11 @@ func (p *CustomProvider) GetEndpointURL() string {
 	return p.BaseURL + "/chat/completions"
 }
 
+// GetEmbeddingsURL returns the embeddings endpoint URL.
+func (p *CustomProvider) GetEmbeddingsURL(model string) string {
+	return p.BaseURL + "/embeddings"
+}
+
 // TransformModelID returns the model ID as-is for custom providers.
 func (p *CustomProvider) TransformModelID(modelID string) string {
 	return modelID
@@ -47,10 +47,18 @@ func (p *GeminiProvider) Name() string {
 }
 
 // GetHeaders returns the HTTP headers for Gemini API requests.
-// Gemini uses an API key in the URL query parameter, not headers.
+// The API key is sent in the x-goog-api-key header rather than the URL, so it
+// stays out of logs.
 func (p *GeminiProvider) GetHeaders(apiKey string) http.Header {
 	headers := http.Header{}
 	headers.Set("Content-Type", "application/json")
+	key := p.apiKey
+	if key == "" {
+		key = apiKey
+	}
+	if key != "" {
+		headers.Set("x-goog-api-key", key)
+	}
 	return headers
 }
 
@@ -61,6 +69,23 @@ func (p *GeminiProvider) GetEndpointURL() string {
 	return p.BaseURL + "/openai/chat/completions"
 }
 
+// GetEmbeddingsURL returns the OpenAI-compatible embeddings endpoint URL.
+func (p *GeminiProvider) GetEmbeddingsURL(model string) string {
+	return p.BaseURL + "/openai/embeddings"
+}
+
+// GetNativeEndpointURL returns the native generateContent endpoint URL for a
+// model, or the streamGenerateContent endpoint with SSE output when stream is set.
+func (p *GeminiProvider) GetNativeEndpointURL(model string, stream bool) string {
+	// A base URL pointing at the OpenAI-compatible endpoint serves the native API one level up
+	baseURL := strings.TrimSuffix(p.BaseURL, "/openai")
+	model = strings.TrimPrefix(model, "models/")
+	if stream {
+		return fmt.Sprintf("%s/models/%s:streamGenerateContent?alt=sse", baseURL, model)
+	}
+	return fmt.Sprintf("%s/models/%s:generateContent", baseURL, model)
+}
+
 // GetEndpointURLWithKey returns the endpoint URL with the API key as query param.
 // This is used for the native Gemini endpoint.
 func (p *GeminiProvider) GetEndpointURLWithKey(model string) string {
@@ -25,3 +25,18 @@ type Provider interface {
 	// RequiresTransformation indicates if the provider needs Anthropic->OpenAI translation.
 	RequiresTransformation() bool
 }
+
+// EmbeddingsProvider is implemented by providers with an OpenAI-compatible
+// embeddings endpoint.
+type EmbeddingsProvider interface {
+	// GetEmbeddingsURL returns the full URL for embeddings with the given model.
+	GetEmbeddingsURL(model string) string
+}
+
+// StreamUsageProvider is implemented by providers that declare whether they
+// accept stream_options.include_usage on streaming Chat Completions requests.
+// Providers that don't implement it are assumed to accept it.
+type StreamUsageProvider interface {
+	// SupportsStreamUsage reports whether stream_options.include_usage is accepted.
+	SupportsStreamUsage() bool
+}
@@ -66,6 +66,11 @@ func (p *LiteLLMProvider) GetEndpointURL() string {
 	return p.BaseURL + "/v1/chat/completions"
 }
 
+// GetEmbeddingsURL returns the embeddings endpoint URL.
+func (p *LiteLLMProvider) GetEmbeddingsURL(model string) string {
+	return p.BaseURL + "/v1/embeddings"
+}
+
 // TransformModelID returns the model ID as-is for LiteLLM.
 // LiteLLM accepts model IDs in provider/model format (e.g., "openai/gpt-4o")
 // or direct model names depending on configuration.
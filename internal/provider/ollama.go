@@ -48,6 +48,12 @@ func (p *OllamaProvider) Name() string {
 	return "ollama"
 }
 
+// SupportsStreamUsage reports that stream_options is not sent to Ollama:
+// older versions reject it, and many models report no usage regardless.
+func (p *OllamaProvider) SupportsStreamUsage() bool {
+	return false
+}
+
 // GetHeaders returns the HTTP headers for Ollama API requests.
 // Ollama typically doesn't require authentication for local use.
 func (p *OllamaProvider) GetHeaders(apiKey string) http.Header {
@@ -71,6 +77,11 @@ func (p *OllamaProvider) GetEndpointURL() string {
 	return p.BaseURL + "/v1/chat/completions"
 }
 
+// GetEmbeddingsURL returns Ollama's OpenAI-compatible embeddings endpoint URL.
+func (p *OllamaProvider) GetEmbeddingsURL(model string) string {
+	return p.BaseURL + "/v1/embeddings"
+}
+
 // TransformModelID returns the model ID as-is for Ollama.
 // Ollama model names are used directly (e.g., "llama3.2", "codellama", "mistral").
 func (p *OllamaProvider) TransformModelID(modelID string) string {
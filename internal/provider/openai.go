@@ -70,6 +70,11 @@ func (p *OpenAIProvider) GetEndpointURL() string {
 	return p.BaseURL + "/chat/completions"
 }
 
+// GetEmbeddingsURL returns the embeddings endpoint URL.
+func (p *OpenAIProvider) GetEmbeddingsURL(model string) string {
+	return p.BaseURL + "/embeddings"
+}
+
 // GetEndpointURLForModel returns the endpoint URL for a specific model.
 // This allows dynamic endpoint selection based on the model being used.
 func (p *OpenAIProvider) GetEndpointURLForModel(model string) string {
@@ -87,6 +92,12 @@ func (p *OpenAIProvider) SetTargetModel(model string) {
 	p.endpointType = translator.GetEndpointType(model)
 }
 
+// SetEndpointType overrides the endpoint chosen by SetTargetModel.
+// Used to retry a model on the other endpoint when its usual one is unavailable.
+func (p *OpenAIProvider) SetEndpointType(endpointType translator.EndpointType) {
+	p.endpointType = endpointType
+}
+
 // GetEndpointType returns the current endpoint type.
 func (p *OpenAIProvider) GetEndpointType() translator.EndpointType {
 	return p.endpointType
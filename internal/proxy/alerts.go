@@ -0,0 +1,130 @@
+// Package proxy implements the HTTP proxy server.
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// Alert event types.
+const (
+	AlertCircuitBreaker = "circuit_breaker"
+	AlertBudgetExceeded = "budget_exceeded"
+)
+
+const (
+	// alertMaxAttempts bounds delivery attempts per alert, including the first.
+	alertMaxAttempts = 3
+	// alertRetryDelay is the wait before the first retry, doubled on each retry.
+	alertRetryDelay = time.Second
+)
+
+// AlertEvent is the JSON payload posted to the alert webhook.
+type AlertEvent struct {
+	Event         string    `json:"event"`
+	Timestamp     time.Time `json:"timestamp"`
+	Provider      string    `json:"provider,omitempty"`
+	State         string    `json:"state,omitempty"`
+	PreviousState string    `json:"previous_state,omitempty"`
+	SpendUSD      float64   `json:"spend_usd,omitempty"`
+	BudgetUSD     float64   `json:"budget_usd,omitempty"`
+}
+
+// Alerter posts alert events to a webhook. Delivery happens in the
+// background, so sending an alert never blocks request handling.
+type Alerter struct {
+	url            string
+	client         *http.Client
+	budgetUSD      float64
+	budgetExceeded int32
+}
+
+// NewAlerter creates an alerter for the webhook URL. A budgetUSD above zero
+// enables budget alerts.
+func NewAlerter(url string, budgetUSD float64) *Alerter {
+	return &Alerter{
+		url:       url,
+		client:    &http.Client{Timeout: 10 * time.Second},
+		budgetUSD: budgetUSD,
+	}
+}
+
+// Send delivers an event in the background, retrying failed deliveries.
+func (a *Alerter) Send(event AlertEvent) {
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now().UTC()
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("[CLASP] Error encoding %s alert: %v", event.Event, err)
+		return
+	}
+	go a.deliver(event.Event, body)
+}
+
+// deliver posts an alert, retrying with backoff up to alertMaxAttempts times.
+func (a *Alerter) deliver(event string, body []byte) {
+	delay := alertRetryDelay
+	var err error
+	for attempt := 1; attempt <= alertMaxAttempts; attempt++ {
+		if err = a.post(body); err == nil {
+			return
+		}
+		if attempt < alertMaxAttempts {
+			time.Sleep(delay)
+			delay *= 2
+		}
+	}
+	log.Printf("[CLASP] Failed to deliver %s alert after %d attempts: %v", event, alertMaxAttempts, err)
+}
+
+func (a *Alerter) post(body []byte) error {
+	resp, err := a.client.Post(a.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// CircuitBreakerHook returns a state change hook for a provider's circuit breaker.
+func (a *Alerter) CircuitBreakerHook(provider string) func(from, to string) {
+	return func(from, to string) {
+		log.Printf("[CLASP] Circuit breaker for %s: %s -> %s", provider, from, to)
+		a.Send(AlertEvent{
+			Event:         AlertCircuitBreaker,
+			Provider:      provider,
+			State:         to,
+			PreviousState: from,
+		})
+	}
+}
+
+// CheckBudget sends a budget alert the first time spend reaches the budget.
+// The alert fires again if spend drops below the budget (after a cost reset)
+// and then reaches it again.
+func (a *Alerter) CheckBudget(spendUSD float64) {
+	if a.budgetUSD <= 0 {
+		return
+	}
+	if spendUSD < a.budgetUSD {
+		atomic.StoreInt32(&a.budgetExceeded, 0)
+		return
+	}
+	if atomic.CompareAndSwapInt32(&a.budgetExceeded, 0, 1) {
+		log.Printf("[CLASP] Spend $%.4f reached the budget of $%.2f", spendUSD, a.budgetUSD)
+		a.Send(AlertEvent{
+			Event:     AlertBudgetExceeded,
+			SpendUSD:  spendUSD,
+			BudgetUSD: a.budgetUSD,
+		})
+	}
+}
@@ -0,0 +1,41 @@
+// Package proxy implements the HTTP proxy server.
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/jedarden/clasp/internal/translator"
+)
+
+// anthropicBetaContextKey is the request context key for the request's
+// anthropic-beta flags.
+type anthropicBetaContextKey struct{}
+
+// withAnthropicBeta attaches the flags of the client's anthropic-beta headers
+// to the request context.
+func withAnthropicBeta(r *http.Request) *http.Request {
+	betas := translator.ParseBetaHeader(r.Header.Values("anthropic-beta"))
+	if len(betas) == 0 {
+		return r
+	}
+	return r.WithContext(context.WithValue(r.Context(), anthropicBetaContextKey{}, betas))
+}
+
+// anthropicBetasFromContext returns the request's anthropic-beta flags, if any.
+func anthropicBetasFromContext(ctx context.Context) []string {
+	betas, _ := ctx.Value(anthropicBetaContextKey{}).([]string)
+	return betas
+}
+
+// applyAnthropicBeta forwards the request's anthropic-beta flags to providers
+// that send anthropic-version, i.e. Anthropic passthrough.
+func applyAnthropicBeta(ctx context.Context, headers http.Header) {
+	if headers.Get("anthropic-version") == "" {
+		return
+	}
+	if betas := anthropicBetasFromContext(ctx); len(betas) > 0 {
+		headers.Set("anthropic-beta", strings.Join(betas, ","))
+	}
+}
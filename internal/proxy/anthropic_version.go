@@ -0,0 +1,65 @@
+// Package proxy implements the HTTP proxy server.
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// supportedAnthropicVersions lists the anthropic-version values accepted when
+// CLASP_STRICT_VERSION is enabled.
+var supportedAnthropicVersions = []string{"2023-06-01", "2023-01-01"}
+
+// anthropicVersionContextKey is the request context key for the request's
+// anthropic-version.
+type anthropicVersionContextKey struct{}
+
+// withAnthropicVersion attaches the client's anthropic-version header, or the
+// configured default when it is missing, to the request context. With
+// CLASP_STRICT_VERSION, unsupported versions are rejected.
+func (h *Handler) withAnthropicVersion(r *http.Request) (*http.Request, *requestError) {
+	version := strings.TrimSpace(r.Header.Get("anthropic-version"))
+	if version == "" {
+		version = h.cfg.AnthropicVersion
+	}
+	if version == "" {
+		return r, nil
+	}
+
+	if h.cfg.StrictVersion && !isSupportedAnthropicVersion(version) {
+		return nil, &requestError{
+			statusCode: http.StatusBadRequest,
+			errType:    "invalid_request_error",
+			message:    fmt.Sprintf("Unsupported anthropic-version %q (supported: %s)", version, strings.Join(supportedAnthropicVersions, ", ")),
+		}
+	}
+	return r.WithContext(context.WithValue(r.Context(), anthropicVersionContextKey{}, version)), nil
+}
+
+// anthropicVersionFromContext returns the request's anthropic-version, if any.
+func anthropicVersionFromContext(ctx context.Context) string {
+	version, _ := ctx.Value(anthropicVersionContextKey{}).(string)
+	return version
+}
+
+func isSupportedAnthropicVersion(version string) bool {
+	for _, v := range supportedAnthropicVersions {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
+
+// applyAnthropicVersion forwards the request's anthropic-version to providers
+// that send the header, i.e. Anthropic passthrough.
+func applyAnthropicVersion(ctx context.Context, headers http.Header) {
+	if headers.Get("anthropic-version") == "" {
+		return
+	}
+	if version := anthropicVersionFromContext(ctx); version != "" {
+		headers.Set("anthropic-version", version)
+	}
+}
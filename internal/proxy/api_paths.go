@@ -0,0 +1,14 @@
+// Package proxy implements the HTTP proxy server.
+package proxy
+
+import "github.com/jedarden/clasp/internal/config"
+
+// apiPaths returns the paths an API endpoint such as "/messages" is served at:
+// under CLASP_API_PATH_PREFIX and, with CLASP_API_PATH_UNPREFIXED, without it.
+func apiPaths(cfg *config.Config, endpoint string) []string {
+	paths := []string{cfg.APIPathPrefix + endpoint}
+	if cfg.APIPathUnprefixed && cfg.APIPathPrefix != "" {
+		paths = append(paths, endpoint)
+	}
+	return paths
+}
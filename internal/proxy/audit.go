@@ -0,0 +1,243 @@
+// Package proxy implements the HTTP proxy server.
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/jedarden/clasp/internal/secrets"
+	"github.com/jedarden/clasp/pkg/models"
+)
+
+// maxAuditContentBytes bounds how much of a response is kept for the audit
+// log when content logging is enabled.
+const maxAuditContentBytes = 1 << 20
+
+// AuditEntry is one line of the audit log, written when a request completes.
+type AuditEntry struct {
+	Timestamp        time.Time `json:"timestamp"`
+	RequestID        string    `json:"request_id"`
+	KeyLabel         string    `json:"key_label,omitempty"`
+	Model            string    `json:"model,omitempty"`
+	UserID           string    `json:"user_id,omitempty"`
+	ServiceTier      string    `json:"service_tier,omitempty"`
+	AnthropicVersion string    `json:"anthropic_version,omitempty"`
+	TargetModel      string    `json:"target_model,omitempty"`
+	Provider         string    `json:"provider,omitempty"`
+	Stream           bool      `json:"stream"`
+	Status           int       `json:"status"`
+	LatencyMs        int64     `json:"latency_ms"`
+	InputTokens      int       `json:"input_tokens"`
+	OutputTokens     int       `json:"output_tokens"`
+	CostUSD          float64   `json:"cost_usd"`
+	Cache            string    `json:"cache,omitempty"`
+	Fallback         bool      `json:"fallback"`
+	CircuitBreaker   string    `json:"circuit_breaker,omitempty"`
+	Request          string    `json:"request,omitempty"`
+	Response         string    `json:"response,omitempty"`
+}
+
+// AuditLogger appends audit entries to a JSONL file. Prompt and response
+// content is left out unless includeContent is set, and is masked when kept.
+type AuditLogger struct {
+	mu             sync.Mutex
+	file           *os.File
+	includeContent bool
+}
+
+// NewAuditLogger opens (or creates) the audit log at path for appending.
+func NewAuditLogger(path string, includeContent bool) (*AuditLogger, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("opening audit log: %w", err)
+	}
+	return &AuditLogger{file: f, includeContent: includeContent}, nil
+}
+
+// Write appends an entry as a single JSON line.
+func (a *AuditLogger) Write(entry *AuditEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("encoding audit entry: %w", err)
+	}
+	data = append(data, '\n')
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, err := a.file.Write(data); err != nil {
+		return fmt.Errorf("writing audit log: %w", err)
+	}
+	return nil
+}
+
+// Close closes the audit log file.
+func (a *AuditLogger) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.file.Close()
+}
+
+// SetAuditLogger sets the audit logger for completed requests.
+func (h *Handler) SetAuditLogger(a *AuditLogger) {
+	h.auditLog = a
+}
+
+// auditRecord collects the audit entry of an in-flight request. Usage can be
+// reported from stream callbacks, so fields are guarded by mu.
+type auditRecord struct {
+	mu    sync.Mutex
+	entry AuditEntry
+}
+
+// auditContextKey is the request context key for the request's audit record.
+type auditContextKey struct{}
+
+// auditRecordFromContext returns the request's audit record, or nil if audit
+// logging is disabled.
+func auditRecordFromContext(ctx context.Context) *auditRecord {
+	rec, _ := ctx.Value(auditContextKey{}).(*auditRecord)
+	return rec
+}
+
+// auditResponseWriter captures the status and, when content is audited, the
+// body of a response.
+type auditResponseWriter struct {
+	http.ResponseWriter
+	status  int
+	body    *bytes.Buffer
+	flusher http.Flusher
+}
+
+func (aw *auditResponseWriter) WriteHeader(code int) {
+	if aw.status == 0 {
+		aw.status = code
+	}
+	aw.ResponseWriter.WriteHeader(code)
+}
+
+func (aw *auditResponseWriter) Write(p []byte) (int, error) {
+	if aw.status == 0 {
+		aw.status = http.StatusOK
+	}
+	if aw.body != nil && aw.body.Len() < maxAuditContentBytes {
+		keep := p
+		if room := maxAuditContentBytes - aw.body.Len(); len(keep) > room {
+			keep = keep[:room]
+		}
+		aw.body.Write(keep)
+	}
+	return aw.ResponseWriter.Write(p)
+}
+
+// Flush implements http.Flusher.
+func (aw *auditResponseWriter) Flush() {
+	if aw.flusher != nil {
+		aw.flusher.Flush()
+	}
+}
+
+// startAudit begins the audit record of a /v1/messages request. The returned
+// function writes the entry once the response is complete. The request ID is
+// taken from X-Request-ID when the client sets it and echoed in the response.
+func (h *Handler) startAudit(w http.ResponseWriter, r *http.Request, start time.Time) (http.ResponseWriter, *http.Request, func()) {
+	requestID := r.Header.Get("X-Request-ID")
+	if requestID == "" {
+		requestID = "req_" + randomHex(12)
+	}
+	w.Header().Set("X-Request-ID", requestID)
+
+	rec := &auditRecord{entry: AuditEntry{
+		RequestID: requestID,
+		KeyLabel:  secrets.MaskAPIKey(extractAPIKey(r)),
+	}}
+	aw := &auditResponseWriter{ResponseWriter: w}
+	aw.flusher, _ = w.(http.Flusher)
+	if h.auditLog.includeContent {
+		aw.body = &bytes.Buffer{}
+	}
+	r = r.WithContext(context.WithValue(r.Context(), auditContextKey{}, rec))
+
+	finish := func() {
+		rec.mu.Lock()
+		entry := rec.entry
+		rec.mu.Unlock()
+
+		entry.Timestamp = start.UTC()
+		entry.LatencyMs = time.Since(start).Milliseconds()
+		entry.Status = aw.status
+		if entry.Status == 0 {
+			entry.Status = http.StatusOK
+		}
+		entry.Cache = w.Header().Get("X-CLASP-Cache")
+		entry.Fallback = entry.Fallback || w.Header().Get("X-CLASP-Fallback") != ""
+		entry.CircuitBreaker = w.Header().Get("X-CLASP-Circuit-Breaker")
+		if entry.InputTokens > 0 || entry.OutputTokens > 0 {
+			entry.CostUSD = h.costTracker.EstimateCostUSD(entry.TargetModel, entry.InputTokens, entry.OutputTokens)
+		}
+		if aw.body != nil {
+			entry.Response = secrets.MaskAllSecrets(aw.body.String())
+		}
+		if err := h.auditLog.Write(&entry); err != nil {
+			log.Printf("[CLASP] Error writing audit log: %v", err)
+		}
+	}
+	return aw, r, finish
+}
+
+// auditRequest records the parsed request, and its masked content when
+// content is audited.
+func (h *Handler) auditRequest(ctx context.Context, req *models.AnthropicRequest) {
+	rec := auditRecordFromContext(ctx)
+	if rec == nil {
+		return
+	}
+	var content string
+	if h.auditLog.includeContent {
+		if data, err := json.Marshal(req); err == nil {
+			content = secrets.MaskAllSecrets(string(data))
+		}
+	}
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	rec.entry.Model = req.Model
+	rec.entry.Stream = req.Stream
+	rec.entry.ServiceTier = req.ServiceTier
+	rec.entry.AnthropicVersion = anthropicVersionFromContext(ctx)
+	if req.Metadata != nil {
+		rec.entry.UserID = req.Metadata.UserID
+	}
+	rec.entry.Request = content
+}
+
+// auditRoute records the provider and model a request was sent to.
+func auditRoute(ctx context.Context, providerName, targetModel string, usedFallback bool) {
+	rec := auditRecordFromContext(ctx)
+	if rec == nil {
+		return
+	}
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	rec.entry.Provider = providerName
+	rec.entry.TargetModel = targetModel
+	rec.entry.Fallback = usedFallback
+}
+
+// auditUsage records the token usage reported for a request.
+func auditUsage(ctx context.Context, inputTokens, outputTokens int) {
+	rec := auditRecordFromContext(ctx)
+	if rec == nil {
+		return
+	}
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	rec.entry.InputTokens = inputTokens
+	rec.entry.OutputTokens = outputTokens
+}
@@ -19,6 +19,9 @@ type AuthConfig struct {
 	AllowAnonymousHealth bool
 	// AllowAnonymousMetrics allows unauthenticated access to /metrics endpoints.
 	AllowAnonymousMetrics bool
+	// AnonymousEndpoints lists further paths readable without an API key.
+	// When nil, only / is.
+	AnonymousEndpoints []string
 }
 
 // AuthMiddleware creates an authentication middleware.
@@ -33,18 +36,7 @@ func AuthMiddleware(config *AuthConfig) func(http.Handler) http.Handler {
 			}
 
 			// Allow anonymous access to specific endpoints
-			path := r.URL.Path
-			if config.AllowAnonymousHealth && path == "/health" {
-				next.ServeHTTP(w, r)
-				return
-			}
-			if config.AllowAnonymousMetrics && (path == "/metrics" || path == "/metrics/prometheus") {
-				next.ServeHTTP(w, r)
-				return
-			}
-
-			// Root endpoint is always accessible
-			if path == "/" {
+			if allowsAnonymous(r, config.AnonymousEndpoints, config.AllowAnonymousHealth, config.AllowAnonymousMetrics) {
 				next.ServeHTTP(w, r)
 				return
 			}
@@ -67,10 +59,55 @@ func AuthMiddleware(config *AuthConfig) func(http.Handler) http.Handler {
 	}
 }
 
+// isHealthPath returns true for the health and probe endpoints covered by
+// the anonymous health setting.
+func isHealthPath(path string) bool {
+	return path == "/health" || path == "/livez" || path == "/readyz"
+}
+
+// isMetricsPath returns true for the endpoints covered by the anonymous
+// metrics setting.
+func isMetricsPath(path string) bool {
+	return path == "/metrics" || path == "/metrics/prometheus"
+}
+
+// allowsAnonymous reports whether a request may skip authentication. The
+// anonymous health and metrics settings add their endpoints to the list.
+// Anonymous access is read-only, so resets and other writes still need the
+// key.
+func allowsAnonymous(r *http.Request, endpoints []string, health, metrics bool) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	path := r.URL.Path
+	if health && isHealthPath(path) {
+		return true
+	}
+	if metrics && isMetricsPath(path) {
+		return true
+	}
+	// The root endpoint is anonymous unless an explicit list leaves it out
+	if endpoints == nil {
+		return path == "/"
+	}
+	return containsPath(endpoints, path)
+}
+
+// containsPath reports whether path is one of paths.
+func containsPath(paths []string, path string) bool {
+	for _, p := range paths {
+		if p == path {
+			return true
+		}
+	}
+	return false
+}
+
 // extractAPIKey extracts the API key from the request headers.
 // It checks the following in order:
 // 1. x-api-key header
 // 2. Authorization header (Bearer token)
+// When both are present, x-api-key wins and the bearer token is ignored.
 func extractAPIKey(r *http.Request) string {
 	// Check x-api-key header first
 	if key := r.Header.Get("x-api-key"); key != "" {
@@ -78,14 +115,14 @@ func extractAPIKey(r *http.Request) string {
 	}
 
 	// Check Authorization header
-	auth := r.Header.Get("Authorization")
+	auth := strings.TrimSpace(r.Header.Get("Authorization"))
 	if auth == "" {
 		return ""
 	}
 
-	// Support "Bearer <key>" format
-	if strings.HasPrefix(auth, "Bearer ") {
-		return strings.TrimPrefix(auth, "Bearer ")
+	// Support "Bearer <key>" format; the scheme is case-insensitive
+	if len(auth) > len("Bearer ") && strings.EqualFold(auth[:len("Bearer ")], "Bearer ") {
+		return strings.TrimSpace(auth[len("Bearer "):])
 	}
 
 	// Support raw API key in Authorization header
@@ -105,3 +142,15 @@ func writeAuthError(w http.ResponseWriter, status int, errType, message string)
 		},
 	})
 }
+
+// requireAuth refuses a request to an endpoint that exposes request contents
+// or changes how the proxy behaves unless authentication is enabled, since
+// otherwise anyone who can reach the port could use it. It reports whether
+// the request may proceed.
+func (h *Handler) requireAuth(w http.ResponseWriter, endpoint string) bool {
+	if h.cfg.AuthEnabled {
+		return true
+	}
+	h.writeErrorResponse(w, http.StatusForbidden, "permission_error", endpoint+" requires authentication - set CLASP_AUTH=true and CLASP_AUTH_API_KEY")
+	return false
+}
@@ -3,9 +3,6 @@ package proxy
 
 import (
 	"container/list"
-	"crypto/sha256"
-	"encoding/hex"
-	"encoding/json"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -60,46 +57,19 @@ func NewRequestCache(maxSize int, ttl time.Duration) *RequestCache {
 
 // GenerateCacheKey creates a deterministic cache key from a request.
 // Only caches requests where the response would be deterministic:
-// - Same model, messages, system prompt, tools, and max_tokens
+// - Same model, prompt, tools, max_tokens and sampling and output settings
 // - Excludes streaming requests (they need fresh responses)
 // - Excludes requests with temperature > 0 (non-deterministic)
 func GenerateCacheKey(req *models.AnthropicRequest) (string, bool) {
-	// Don't cache streaming requests
-	if req.Stream {
-		return "", false
-	}
-
-	// Don't cache non-deterministic requests (temperature > 0)
-	if req.Temperature != nil && *req.Temperature > 0 {
-		return "", false
-	}
-
-	// Create a normalized representation for hashing
-	normalized := struct {
-		Model      string                    `json:"model"`
-		System     interface{}               `json:"system"`
-		Messages   []models.AnthropicMessage `json:"messages"`
-		Tools      []models.AnthropicTool    `json:"tools"`
-		ToolChoice interface{}               `json:"tool_choice,omitempty"`
-		MaxTokens  int                       `json:"max_tokens"`
-	}{
-		Model:      req.Model,
-		System:     req.System,
-		Messages:   req.Messages,
-		Tools:      req.Tools,
-		ToolChoice: req.ToolChoice,
-		MaxTokens:  req.MaxTokens,
-	}
+	return GenerateCacheKeyWithStrategy(req, CacheKeyFull)
+}
 
-	// Marshal to JSON for consistent representation
-	data, err := json.Marshal(normalized)
-	if err != nil {
-		return "", false
+// truncate returns at most the first n bytes of s, for logging key prefixes.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
 	}
-
-	// Create SHA256 hash
-	hash := sha256.Sum256(data)
-	return hex.EncodeToString(hash[:]), true
+	return s[:n]
 }
 
 // Get retrieves a cached response if it exists and is not expired.
@@ -202,6 +172,12 @@ func (rc *RequestCache) Stats() (size, maxSize int, hits, misses int64, hitRate
 	return
 }
 
+// ResetStats zeroes the hit and miss counters without evicting entries.
+func (rc *RequestCache) ResetStats() {
+	atomic.StoreInt64(&rc.hits, 0)
+	atomic.StoreInt64(&rc.misses, 0)
+}
+
 // Clear removes all entries from the cache.
 func (rc *RequestCache) Clear() {
 	rc.mu.Lock()
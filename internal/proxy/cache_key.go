@@ -0,0 +1,139 @@
+// Package proxy implements the HTTP proxy server.
+package proxy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+
+	"github.com/jedarden/clasp/pkg/models"
+)
+
+// Cache key strategies selected by CLASP_CACHE_KEY. No strategy caches a
+// request with a temperature above zero, and every strategy keys on the
+// fields that change the shape of the response: output_format, n, thinking,
+// parallel_tool_calls and the anthropic-beta flags.
+const (
+	// CacheKeyFull also keys on max_tokens and the sampling parameters
+	// (top_p, top_k, seed, penalties, stop_sequences) and on service_tier.
+	CacheKeyFull = "full"
+	// CacheKeyMessagesOnly keys on the model, prompt and tools but not the
+	// sampling parameters, so requests that differ only in those share an
+	// entry.
+	CacheKeyMessagesOnly = "messages_only"
+	// CacheKeyNormalized is CacheKeyMessagesOnly with the whitespace in the
+	// prompt text collapsed.
+	CacheKeyNormalized = "normalized"
+)
+
+// GenerateCacheKeyWithStrategy creates a deterministic cache key from a
+// request using the given CLASP_CACHE_KEY strategy. Streaming requests are
+// never cacheable; an unknown strategy falls back to CacheKeyFull.
+func GenerateCacheKeyWithStrategy(req *models.AnthropicRequest, strategy string) (string, bool) {
+	// Don't cache streaming requests
+	if req.Stream {
+		return "", false
+	}
+
+	// Don't cache non-deterministic requests (temperature > 0)
+	if req.Temperature != nil && *req.Temperature > 0 {
+		return "", false
+	}
+
+	keyed := struct {
+		Model             string                    `json:"model"`
+		System            interface{}               `json:"system"`
+		Messages          []models.AnthropicMessage `json:"messages"`
+		Tools             []models.AnthropicTool    `json:"tools"`
+		ToolChoice        interface{}               `json:"tool_choice,omitempty"`
+		OutputFormat      *models.OutputFormat      `json:"output_format,omitempty"`
+		N                 int                       `json:"n,omitempty"`
+		Thinking          *models.ThinkingConfig    `json:"thinking,omitempty"`
+		ParallelToolCalls *bool                     `json:"parallel_tool_calls,omitempty"`
+		Betas             []string                  `json:"betas,omitempty"`
+		MaxTokens         int                       `json:"max_tokens,omitempty"`
+		TopP              *float64                  `json:"top_p,omitempty"`
+		TopK              *int                      `json:"top_k,omitempty"`
+		Seed              *int                      `json:"seed,omitempty"`
+		PresencePenalty   *float64                  `json:"presence_penalty,omitempty"`
+		FrequencyPenalty  *float64                  `json:"frequency_penalty,omitempty"`
+		StopSequences     []string                  `json:"stop_sequences,omitempty"`
+		ServiceTier       string                    `json:"service_tier,omitempty"`
+	}{
+		Model:             req.Model,
+		System:            req.System,
+		Messages:          req.Messages,
+		Tools:             req.Tools,
+		ToolChoice:        req.ToolChoice,
+		OutputFormat:      req.OutputFormat,
+		N:                 req.N,
+		Thinking:          req.Thinking,
+		ParallelToolCalls: req.ParallelToolCalls,
+		Betas:             req.Betas,
+	}
+
+	switch strategy {
+	case CacheKeyMessagesOnly:
+	case CacheKeyNormalized:
+		keyed.System = normalizePromptWhitespace(req.System)
+		keyed.Messages = make([]models.AnthropicMessage, len(req.Messages))
+		for i, msg := range req.Messages {
+			keyed.Messages[i] = models.AnthropicMessage{Role: msg.Role, Content: normalizePromptWhitespace(msg.Content)}
+		}
+	default:
+		keyed.MaxTokens = req.MaxTokens
+		keyed.TopP = req.TopP
+		keyed.TopK = req.TopK
+		keyed.Seed = req.Seed
+		keyed.PresencePenalty = req.PresencePenalty
+		keyed.FrequencyPenalty = req.FrequencyPenalty
+		keyed.StopSequences = req.StopSequences
+		keyed.ServiceTier = req.ServiceTier
+	}
+
+	// Marshal to JSON for consistent representation
+	data, err := json.Marshal(keyed)
+	if err != nil {
+		return "", false
+	}
+
+	// Create SHA256 hash
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:]), true
+}
+
+// cacheKey returns the response cache key of a request under the configured
+// CLASP_CACHE_KEY strategy.
+func (h *Handler) cacheKey(req *models.AnthropicRequest) (string, bool) {
+	return GenerateCacheKeyWithStrategy(req, h.cfg.CacheKey)
+}
+
+// normalizePromptWhitespace returns system or message content with the text
+// of string content and text blocks trimmed and its whitespace runs
+// collapsed to single spaces. Other blocks are kept as they are.
+func normalizePromptWhitespace(content interface{}) interface{} {
+	switch c := content.(type) {
+	case string:
+		return strings.Join(strings.Fields(c), " ")
+	case []interface{}:
+		blocks := make([]interface{}, len(c))
+		for i, item := range c {
+			block, ok := item.(map[string]interface{})
+			text, isText := block["text"].(string)
+			if !ok || !isText || block["type"] != "text" {
+				blocks[i] = item
+				continue
+			}
+			normalized := make(map[string]interface{}, len(block))
+			for k, v := range block {
+				normalized[k] = v
+			}
+			normalized["text"] = strings.Join(strings.Fields(text), " ")
+			blocks[i] = normalized
+		}
+		return blocks
+	default:
+		return content
+	}
+}
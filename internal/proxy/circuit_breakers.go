@@ -0,0 +1,128 @@
+// Package proxy implements the HTTP proxy server.
+package proxy
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// CircuitBreakers holds a circuit breaker per provider, so failures from one
+// provider don't reject requests routed to another.
+type CircuitBreakers struct {
+	failureThreshold int
+	successThreshold int
+	timeout          time.Duration
+	halfOpenMax      int
+
+	mu       sync.Mutex
+	breakers map[string]*CircuitBreaker
+	hook     func(provider string) func(from, to string)
+}
+
+// NewCircuitBreakers creates a set of circuit breakers sharing the same settings.
+func NewCircuitBreakers(failureThreshold, successThreshold int, timeout time.Duration) *CircuitBreakers {
+	return &CircuitBreakers{
+		failureThreshold: failureThreshold,
+		successThreshold: successThreshold,
+		timeout:          timeout,
+		halfOpenMax:      1,
+		breakers:         make(map[string]*CircuitBreaker),
+	}
+}
+
+// SetHalfOpenMax sets how many probe requests each breaker admits at once
+// while half-open. It must be set before the breakers are used.
+func (c *CircuitBreakers) SetHalfOpenMax(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.halfOpenMax = n
+	for _, cb := range c.breakers {
+		cb.SetHalfOpenMax(n)
+	}
+}
+
+// Get returns the circuit breaker for a provider, creating it if needed.
+func (c *CircuitBreakers) Get(provider string) *CircuitBreaker {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cb, ok := c.breakers[provider]
+	if !ok {
+		cb = NewCircuitBreaker(c.failureThreshold, c.successThreshold, c.timeout)
+		cb.SetHalfOpenMax(c.halfOpenMax)
+		if c.hook != nil {
+			cb.SetStateChangeHook(c.hook(provider))
+		}
+		c.breakers[provider] = cb
+	}
+	return cb
+}
+
+// SetStateChangeHook sets a function that returns the state change hook for
+// a provider's breaker. It applies to existing and future breakers, and must
+// be set before the breakers are used.
+func (c *CircuitBreakers) SetStateChangeHook(hook func(provider string) func(from, to string)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.hook = hook
+	for provider, cb := range c.breakers {
+		cb.SetStateChangeHook(hook(provider))
+	}
+}
+
+// Providers returns the names of providers with a breaker, sorted.
+func (c *CircuitBreakers) Providers() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	names := make([]string, 0, len(c.breakers))
+	for name := range c.breakers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// States returns the state of each provider's breaker.
+func (c *CircuitBreakers) States() map[string]string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	states := make(map[string]string, len(c.breakers))
+	for name, cb := range c.breakers {
+		states[name] = cb.State()
+	}
+	return states
+}
+
+// circuitBreakerContextKey is the request context key for the circuit breaker
+// of the provider serving the request.
+type circuitBreakerContextKey struct{}
+
+// withCircuitBreaker records the circuit breaker for the request's provider.
+func withCircuitBreaker(ctx context.Context, cb *CircuitBreaker) context.Context {
+	if cb == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, circuitBreakerContextKey{}, cb)
+}
+
+// circuitBreakerFromContext returns the circuit breaker recorded by
+// withCircuitBreaker, or nil if circuit breaking is disabled.
+func circuitBreakerFromContext(ctx context.Context) *CircuitBreaker {
+	cb, _ := ctx.Value(circuitBreakerContextKey{}).(*CircuitBreaker)
+	return cb
+}
+
+// circuitBreakerFor returns the circuit breaker for a provider, or nil if
+// circuit breaking is disabled.
+func (h *Handler) circuitBreakerFor(name string) *CircuitBreaker {
+	if h.circuitBreakers == nil {
+		return nil
+	}
+	return h.circuitBreakers.Get(name)
+}
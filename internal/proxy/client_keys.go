@@ -0,0 +1,65 @@
+// Package proxy implements the HTTP proxy server.
+package proxy
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/jedarden/clasp/internal/secrets"
+)
+
+// ClientKeyHeader carries a client-supplied provider API key (BYO-key setups).
+const ClientKeyHeader = "X-CLASP-Provider-Key"
+
+// clientKeyContextKey is the request context key for a client-supplied provider key.
+type clientKeyContextKey struct{}
+
+// withClientKey attaches the client's provider key to the request context when
+// CLASP_ALLOW_CLIENT_KEYS is enabled. The key only lives for the request.
+func (h *Handler) withClientKey(r *http.Request) *http.Request {
+	key := strings.TrimSpace(r.Header.Get(ClientKeyHeader))
+	if key == "" {
+		return r
+	}
+	if !h.cfg.AllowClientKeys {
+		log.Printf("[CLASP] Ignoring %s header: client keys are disabled (set CLASP_ALLOW_CLIENT_KEYS=true)", ClientKeyHeader)
+		return r
+	}
+	log.Printf("[CLASP] Using client-supplied provider key %s", secrets.MaskAPIKey(key))
+	return r.WithContext(context.WithValue(r.Context(), clientKeyContextKey{}, key))
+}
+
+// clientKeyFromContext returns the client-supplied provider key, if any.
+func clientKeyFromContext(ctx context.Context) string {
+	key, _ := ctx.Value(clientKeyContextKey{}).(string)
+	return key
+}
+
+// fallbackAllowed reports whether a request may be sent to the fallback
+// providers. Requests made with the client's own provider key may not: the
+// fallback would serve them on the proxy's credentials.
+func fallbackAllowed(ctx context.Context) bool {
+	return clientKeyFromContext(ctx) == ""
+}
+
+// overrideAPIKey replaces the credential headers set by a provider with the given key.
+// Providers with an embedded (tier) key ignore the key passed to GetHeaders, so the
+// override is applied to the built headers instead.
+func overrideAPIKey(headers http.Header, key string) {
+	overridden := false
+	if headers.Get("Authorization") != "" {
+		headers.Set("Authorization", "Bearer "+key)
+		overridden = true
+	}
+	for _, name := range []string{"x-api-key", "api-key", "x-goog-api-key"} {
+		if headers.Get(name) != "" {
+			headers.Set(name, key)
+			overridden = true
+		}
+	}
+	if !overridden {
+		headers.Set("Authorization", "Bearer "+key)
+	}
+}
@@ -0,0 +1,115 @@
+// Package proxy implements the HTTP proxy server.
+package proxy
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"sync"
+	"sync/atomic"
+)
+
+// coalescedResponse is a response captured from the leader of a coalesced
+// request, to be written to every waiting client.
+type coalescedResponse struct {
+	status int
+	header http.Header
+	body   bytes.Buffer
+}
+
+// Header implements http.ResponseWriter.
+func (c *coalescedResponse) Header() http.Header {
+	return c.header
+}
+
+// Write implements http.ResponseWriter.
+func (c *coalescedResponse) Write(b []byte) (int, error) {
+	if c.status == 0 {
+		c.status = http.StatusOK
+	}
+	return c.body.Write(b)
+}
+
+// WriteHeader implements http.ResponseWriter.
+func (c *coalescedResponse) WriteHeader(status int) {
+	if c.status == 0 {
+		c.status = status
+	}
+}
+
+// writeTo writes the captured response to w.
+func (c *coalescedResponse) writeTo(w http.ResponseWriter) {
+	for k, v := range c.header {
+		w.Header()[k] = v
+	}
+	status := c.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	w.WriteHeader(status)
+	_, _ = w.Write(c.body.Bytes())
+}
+
+// inflightRequest is an upstream call shared by identical requests.
+type inflightRequest struct {
+	done chan struct{}
+	resp *coalescedResponse
+}
+
+// requestCoalescer tracks the in-flight requests by cache key.
+type requestCoalescer struct {
+	mu       sync.Mutex
+	inflight map[string]*inflightRequest
+}
+
+// newRequestCoalescer creates an empty request coalescer.
+func newRequestCoalescer() *requestCoalescer {
+	return &requestCoalescer{inflight: make(map[string]*inflightRequest)}
+}
+
+// coalesce serves a cacheable request. The first request for key runs forward
+// and its response is captured; identical requests that arrive while it is in
+// flight wait for it and receive a copy instead of calling the upstream again.
+func (h *Handler) coalesce(w http.ResponseWriter, r *http.Request, key string, forward func(http.ResponseWriter)) {
+	c := h.coalescer
+	c.mu.Lock()
+	if call, ok := c.inflight[key]; ok {
+		c.mu.Unlock()
+		select {
+		case <-call.done:
+		case <-r.Context().Done():
+			return
+		}
+		atomic.AddInt64(&h.metrics.CoalescedRequests, 1)
+		if call.resp.status < http.StatusBadRequest {
+			atomic.AddInt64(&h.metrics.SuccessRequests, 1)
+		} else {
+			atomic.AddInt64(&h.metrics.ErrorRequests, 1)
+		}
+		log.Printf("[CLASP] Coalesced request served from in-flight request (key: %s...)", truncate(key, 16))
+		w.Header().Set("X-CLASP-Coalesced", "true")
+		call.resp.writeTo(w)
+		return
+	}
+	call := &inflightRequest{done: make(chan struct{}), resp: &coalescedResponse{header: make(http.Header)}}
+	c.inflight[key] = call
+	c.mu.Unlock()
+
+	release := func() {
+		c.mu.Lock()
+		delete(c.inflight, key)
+		c.mu.Unlock()
+		close(call.done)
+	}
+	released := false
+	defer func() {
+		// Don't leave waiting requests blocked if forward panics
+		if !released {
+			release()
+		}
+	}()
+	forward(call.resp)
+	released = true
+	release()
+	call.resp.writeTo(w)
+}
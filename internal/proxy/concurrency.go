@@ -0,0 +1,77 @@
+// Package proxy implements the HTTP proxy server.
+package proxy
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// ConcurrencyLimiter bounds the number of simultaneous in-flight requests.
+type ConcurrencyLimiter struct {
+	slots chan struct{}
+
+	// Metrics
+	inFlight int64
+	rejected int64
+	queued   int64
+}
+
+// NewConcurrencyLimiter creates a limiter allowing max in-flight requests.
+func NewConcurrencyLimiter(max int) *ConcurrencyLimiter {
+	return &ConcurrencyLimiter{slots: make(chan struct{}, max)}
+}
+
+// Acquire takes a slot. With maxWait 0 it fails immediately when saturated;
+// otherwise it waits up to maxWait for a slot to free up (queuing).
+// Returns false if no slot was obtained; the request then counts as rejected.
+func (l *ConcurrencyLimiter) Acquire(ctx context.Context, maxWait time.Duration) bool {
+	select {
+	case l.slots <- struct{}{}:
+		atomic.AddInt64(&l.inFlight, 1)
+		return true
+	default:
+	}
+
+	if maxWait > 0 {
+		atomic.AddInt64(&l.queued, 1)
+		timer := time.NewTimer(maxWait)
+		defer timer.Stop()
+		select {
+		case l.slots <- struct{}{}:
+			atomic.AddInt64(&l.inFlight, 1)
+			return true
+		case <-timer.C:
+		case <-ctx.Done():
+		}
+	}
+
+	atomic.AddInt64(&l.rejected, 1)
+	return false
+}
+
+// Release frees a slot taken by Acquire.
+func (l *ConcurrencyLimiter) Release() {
+	atomic.AddInt64(&l.inFlight, -1)
+	<-l.slots
+}
+
+// Limit returns the maximum number of in-flight requests.
+func (l *ConcurrencyLimiter) Limit() int {
+	return cap(l.slots)
+}
+
+// InFlight returns the number of requests currently holding a slot.
+func (l *ConcurrencyLimiter) InFlight() int64 {
+	return atomic.LoadInt64(&l.inFlight)
+}
+
+// Rejected returns the number of requests turned away while saturated.
+func (l *ConcurrencyLimiter) Rejected() int64 {
+	return atomic.LoadInt64(&l.rejected)
+}
+
+// Queued returns the number of requests that had to wait for a slot.
+func (l *ConcurrencyLimiter) Queued() int64 {
+	return atomic.LoadInt64(&l.queued)
+}
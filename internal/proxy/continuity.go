@@ -0,0 +1,50 @@
+// Package proxy implements the HTTP proxy server.
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"github.com/jedarden/clasp/internal/provider"
+	"github.com/jedarden/clasp/pkg/models"
+)
+
+// isPreviousResponseRejected reports whether an upstream response rejected the
+// previous_response_id of a continued session, for example because the stored
+// response expired upstream. The response body is restored so it can still be
+// read by the caller.
+func isPreviousResponseRejected(resp *http.Response) bool {
+	if resp.StatusCode != http.StatusBadRequest && resp.StatusCode != http.StatusNotFound {
+		return false
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return false
+	}
+	lower := strings.ToLower(string(body))
+	return strings.Contains(lower, "previous_response") || strings.Contains(lower, "previous response")
+}
+
+// executeWithContinuity runs transformAndExecute and, when the upstream rejects
+// the stored previous_response_id, forgets the session and resends the full
+// conversation once.
+func (h *Handler) executeWithContinuity(ctx context.Context, req *models.AnthropicRequest, selectedProvider provider.Provider, targetModel, sessionKey, previousResponseID string, newMessagesOffset int, pinnedFallback bool) (*http.Response, string, bool, int, error) {
+	resp, usedModel, useResponsesAPI, fallbackHop, err := h.transformAndExecute(ctx, req, selectedProvider, targetModel, previousResponseID, newMessagesOffset, pinnedFallback)
+	if err != nil || previousResponseID == "" || !isPreviousResponseRejected(resp) {
+		return resp, usedModel, useResponsesAPI, fallbackHop, err
+	}
+
+	resp.Body.Close()
+	h.sessionTracker.Delete(sessionKey)
+	atomic.AddInt64(&h.metrics.CompactionResets, 1)
+	log.Printf("[CLASP] Compaction: upstream rejected previous_response_id=%s, resending session %s... with full context",
+		previousResponseID, truncate(sessionKey, 8))
+	return h.transformAndExecute(ctx, req, selectedProvider, targetModel, "", 0, pinnedFallback)
+}
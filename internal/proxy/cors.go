@@ -0,0 +1,73 @@
+// Package proxy implements the HTTP proxy server.
+package proxy
+
+import (
+	"net/http"
+	"strings"
+)
+
+const (
+	corsAllowMethods = "GET, POST, OPTIONS"
+	corsMaxAge       = "86400"
+)
+
+// corsAllowHeaders are the request headers browser clients may send.
+var corsAllowHeaders = strings.Join([]string{
+	"Content-Type",
+	"Authorization",
+	"x-api-key",
+	"anthropic-version",
+	"anthropic-beta",
+	ClientKeyHeader,
+	TimeoutHeader,
+}, ", ")
+
+// CORSMiddleware creates a middleware that adds CORS headers for the given
+// origins ("*" allows any origin) and answers preflight OPTIONS requests.
+// Requests from other origins are passed through without CORS headers, so
+// browsers block them.
+func CORSMiddleware(origins []string) func(http.Handler) http.Handler {
+	allowAll := false
+	allowed := make(map[string]bool, len(origins))
+	for _, origin := range origins {
+		if origin == "*" {
+			allowAll = true
+		}
+		allowed[strings.TrimSuffix(origin, "/")] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			originAllowed := allowAll || allowed[origin]
+			if originAllowed {
+				if allowAll {
+					w.Header().Set("Access-Control-Allow-Origin", "*")
+				} else {
+					w.Header().Set("Access-Control-Allow-Origin", origin)
+					w.Header().Add("Vary", "Origin")
+				}
+			}
+
+			// Preflight requests are answered here and never reach auth or the handler
+			if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+				if !originAllowed {
+					w.WriteHeader(http.StatusForbidden)
+					return
+				}
+				w.Header().Set("Access-Control-Allow-Methods", corsAllowMethods)
+				w.Header().Set("Access-Control-Allow-Headers", corsAllowHeaders)
+				w.Header().Set("Access-Control-Max-Age", corsMaxAge)
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,103 @@
+// Package proxy implements the HTTP proxy server.
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+)
+
+// Sort orders accepted by /costs/models.
+const (
+	CostSortCost     = "cost"
+	CostSortTokens   = "tokens"
+	CostSortRequests = "requests"
+)
+
+// ModelUsage is one model's entry in the cost leaderboard.
+type ModelUsage struct {
+	Model        string  `json:"model"`
+	Requests     int64   `json:"requests"`
+	InputTokens  int64   `json:"input_tokens"`
+	OutputTokens int64   `json:"output_tokens"`
+	TotalTokens  int64   `json:"total_tokens"`
+	TotalCostUSD float64 `json:"total_cost_usd"`
+	CostSharePct float64 `json:"cost_share_pct"` // Percentage of the spend across all models
+}
+
+// ModelLeaderboard ranks the models by usage.
+type ModelLeaderboard struct {
+	Sort         string       `json:"sort"`
+	TotalCostUSD float64      `json:"total_cost_usd"`
+	Models       []ModelUsage `json:"models"`
+}
+
+// NewModelLeaderboard ranks the models of a cost summary in descending order
+// of the given sort key, breaking ties by model name.
+func NewModelLeaderboard(summary CostSummary, sortBy string) (ModelLeaderboard, error) {
+	if sortBy == "" {
+		sortBy = CostSortCost
+	}
+	var less func(a, b ModelUsage) bool
+	switch sortBy {
+	case CostSortCost:
+		less = func(a, b ModelUsage) bool { return a.TotalCostUSD > b.TotalCostUSD }
+	case CostSortTokens:
+		less = func(a, b ModelUsage) bool { return a.TotalTokens > b.TotalTokens }
+	case CostSortRequests:
+		less = func(a, b ModelUsage) bool { return a.Requests > b.Requests }
+	default:
+		return ModelLeaderboard{}, fmt.Errorf("invalid sort %q (use cost, tokens, or requests)", sortBy)
+	}
+
+	board := ModelLeaderboard{Sort: sortBy, Models: make([]ModelUsage, 0, len(summary.ByModel))}
+	for model, ms := range summary.ByModel {
+		board.TotalCostUSD += ms.TotalCostUSD
+		board.Models = append(board.Models, ModelUsage{
+			Model:        model,
+			Requests:     ms.Requests,
+			InputTokens:  ms.InputTokens,
+			OutputTokens: ms.OutputTokens,
+			TotalTokens:  ms.InputTokens + ms.OutputTokens,
+			TotalCostUSD: ms.TotalCostUSD,
+		})
+	}
+	if board.TotalCostUSD > 0 {
+		for i := range board.Models {
+			board.Models[i].CostSharePct = board.Models[i].TotalCostUSD / board.TotalCostUSD * 100
+		}
+	}
+
+	sort.Slice(board.Models, func(i, j int) bool {
+		a, b := board.Models[i], board.Models[j]
+		if less(a, b) {
+			return true
+		}
+		if less(b, a) {
+			return false
+		}
+		return a.Model < b.Model
+	})
+	return board, nil
+}
+
+// HandleCostModels handles /costs/models, which ranks models by spend.
+func (h *Handler) HandleCostModels(w http.ResponseWriter, r *http.Request) {
+	if h.costTracker == nil {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"enabled": false,
+			"message": "Cost tracking is not enabled",
+		})
+		return
+	}
+
+	board, err := NewModelLeaderboard(h.costTracker.GetSummary(), r.URL.Query().Get("sort"))
+	if err != nil {
+		h.writeErrorResponse(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(board)
+}
@@ -0,0 +1,158 @@
+// Package proxy implements the HTTP proxy server.
+package proxy
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// costHistoryHours is how many hourly buckets the cost history keeps (30 days).
+const costHistoryHours = 30 * 24
+
+// costBucket holds the usage recorded during one hour.
+type costBucket struct {
+	hour         int64 // Hours since the Unix epoch; 0 marks an unused slot
+	costMicro    int64
+	inputTokens  int64
+	outputTokens int64
+	requests     int64
+}
+
+// costHistory is a ring buffer of hourly usage sums. It is guarded by the
+// owning CostTracker's mutex.
+type costHistory struct {
+	buckets [costHistoryHours]costBucket
+}
+
+// add records usage in the bucket for t, reusing the slot of an hour that has
+// fallen out of the history.
+func (ch *costHistory) add(t time.Time, costMicro, inputTokens, outputTokens int64) {
+	hour := t.Unix() / 3600
+	b := &ch.buckets[hour%costHistoryHours]
+	if b.hour != hour {
+		*b = costBucket{hour: hour}
+	}
+	b.costMicro += costMicro
+	b.inputTokens += inputTokens
+	b.outputTokens += outputTokens
+	b.requests++
+}
+
+// get returns the usage recorded during the given hour.
+func (ch *costHistory) get(hour int64) costBucket {
+	if b := ch.buckets[hour%costHistoryHours]; b.hour == hour {
+		return b
+	}
+	return costBucket{hour: hour}
+}
+
+// CostWindowBucket is the usage recorded during one hour of a cost window.
+type CostWindowBucket struct {
+	Start        time.Time `json:"start"`
+	CostUSD      float64   `json:"cost_usd"`
+	InputTokens  int64     `json:"input_tokens"`
+	OutputTokens int64     `json:"output_tokens"`
+	Requests     int64     `json:"requests"`
+}
+
+// CostWindow is the usage over a recent time window, in hourly buckets.
+type CostWindow struct {
+	Window            string             `json:"window"`
+	Bucket            string             `json:"bucket"`
+	TotalCostUSD      float64            `json:"total_cost_usd"`
+	TotalInputTokens  int64              `json:"total_input_tokens"`
+	TotalOutputTokens int64              `json:"total_output_tokens"`
+	TotalRequests     int64              `json:"total_requests"`
+	Buckets           []CostWindowBucket `json:"buckets"`
+}
+
+// SetClock replaces the clock used to place usage in hourly buckets. It is
+// meant for tests.
+func (ct *CostTracker) SetClock(now func() time.Time) {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+	ct.now = now
+}
+
+// recordHistoryLocked adds usage to the hourly history. ct.mu must be held.
+func (ct *CostTracker) recordHistoryLocked(costMicro, inputTokens, outputTokens int64) {
+	if ct.history == nil {
+		ct.history = &costHistory{}
+	}
+	ct.history.add(ct.clock(), costMicro, inputTokens, outputTokens)
+}
+
+func (ct *CostTracker) clock() time.Time {
+	if ct.now != nil {
+		return ct.now()
+	}
+	return time.Now()
+}
+
+// GetWindow returns the usage of the last window, rounded up to whole hours,
+// as one bucket per hour ending with the current hour. Empty hours are
+// included so the series has no gaps.
+func (ct *CostTracker) GetWindow(window time.Duration) CostWindow {
+	ct.mu.RLock()
+	defer ct.mu.RUnlock()
+
+	hours := int64((window + time.Hour - 1) / time.Hour)
+	if hours < 1 {
+		hours = 1
+	}
+	if hours > costHistoryHours {
+		hours = costHistoryHours
+	}
+
+	result := CostWindow{
+		Window:  (time.Duration(hours) * time.Hour).String(),
+		Bucket:  time.Hour.String(),
+		Buckets: make([]CostWindowBucket, 0, hours),
+	}
+	current := ct.clock().Unix() / 3600
+	for hour := current - hours + 1; hour <= current; hour++ {
+		var b costBucket
+		if ct.history != nil {
+			b = ct.history.get(hour)
+		}
+		costUSD := float64(b.costMicro) / 100000000.0
+		result.Buckets = append(result.Buckets, CostWindowBucket{
+			Start:        time.Unix(hour*3600, 0).UTC(),
+			CostUSD:      costUSD,
+			InputTokens:  b.inputTokens,
+			OutputTokens: b.outputTokens,
+			Requests:     b.requests,
+		})
+		result.TotalCostUSD += costUSD
+		result.TotalInputTokens += b.inputTokens
+		result.TotalOutputTokens += b.outputTokens
+		result.TotalRequests += b.requests
+	}
+	return result
+}
+
+// parseCostWindow parses the /costs window parameter: a Go duration such as
+// "24h", or a number of days such as "7d". It must be between one hour and
+// the length of the history.
+func parseCostWindow(s string) (time.Duration, error) {
+	var window time.Duration
+	if strings.HasSuffix(s, "d") {
+		n, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+		if err != nil {
+			return 0, fmt.Errorf("invalid window %q (use a duration such as 24h or 7d)", s)
+		}
+		window = time.Duration(n) * 24 * time.Hour
+	} else {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return 0, fmt.Errorf("invalid window %q (use a duration such as 24h or 7d)", s)
+		}
+		window = d
+	}
+	if window < time.Hour || window > costHistoryHours*time.Hour {
+		return 0, fmt.Errorf("invalid window %q (must be between 1h and %dd)", s, costHistoryHours/24)
+	}
+	return window, nil
+}
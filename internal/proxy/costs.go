@@ -2,15 +2,41 @@
 package proxy
 
 import (
+	"fmt"
 	"sync"
 	"sync/atomic"
 	"time"
+
+	"github.com/jedarden/clasp/pkg/models"
 )
 
 // ModelPricing holds pricing information for a model (per 1M tokens).
 type ModelPricing struct {
-	InputPer1M  float64 // Cost per 1 million input tokens
-	OutputPer1M float64 // Cost per 1 million output tokens
+	InputPer1M      float64 // Cost per 1 million input tokens
+	OutputPer1M     float64 // Cost per 1 million output tokens
+	CacheReadPer1M  float64 // Cost per 1 million prompt cache reads (0 = cacheReadRate of input)
+	CacheWritePer1M float64 // Cost per 1 million prompt cache writes (0 = cacheWriteRate of input)
+}
+
+// Prompt cache prices relative to the input price, for models without
+// explicit cache pricing. These are Anthropic's rates.
+const (
+	cacheReadRate  = 0.1
+	cacheWriteRate = 1.25
+)
+
+// cacheCostMicro returns the cost in microcents of the prompt cache reads and
+// writes.
+func (p ModelPricing) cacheCostMicro(readTokens, writeTokens int) int64 {
+	readPer1M := p.CacheReadPer1M
+	if readPer1M == 0 {
+		readPer1M = p.InputPer1M * cacheReadRate
+	}
+	writePer1M := p.CacheWritePer1M
+	if writePer1M == 0 {
+		writePer1M = p.InputPer1M * cacheWriteRate
+	}
+	return int64(float64(readTokens)*readPer1M + float64(writeTokens)*writePer1M)
 }
 
 // CostTracker tracks API costs across providers and models.
@@ -20,6 +46,7 @@ type CostTracker struct {
 	// Total costs in cents (using int64 for atomic operations, stored as microcents)
 	totalInputCostMicro  int64 // Microcents (1 cent = 1,000,000 microcents)
 	totalOutputCostMicro int64
+	totalCacheCostMicro  int64 // Prompt cache reads and writes
 
 	// Per-provider costs
 	providerCosts map[string]*ProviderCost
@@ -27,6 +54,14 @@ type CostTracker struct {
 	// Per-model costs
 	modelCosts map[string]*ModelCost
 
+	// Per-model token distributions for Prometheus histograms
+	inputTokenHists  map[string]*tokenHistogram
+	outputTokenHists map[string]*tokenHistogram
+
+	// Embedding costs, tracked separately from messages
+	totalEmbeddingCostMicro int64
+	embeddingCosts          map[string]*ModelCost
+
 	// Request tracking
 	totalRequests int64
 
@@ -35,24 +70,37 @@ type CostTracker struct {
 
 	// Custom pricing overrides
 	customPricing map[string]ModelPricing
+
+	// Hourly usage for /costs?window=, and the clock that places it
+	history *costHistory
+	now     func() time.Time
+
+	// Called with the new total cost in USD after usage is recorded
+	onUsage func(totalCostUSD float64)
 }
 
 // ProviderCost tracks costs for a specific provider.
 type ProviderCost struct {
-	InputCostMicro  int64
-	OutputCostMicro int64
-	InputTokens     int64
-	OutputTokens    int64
-	Requests        int64
+	InputCostMicro      int64
+	OutputCostMicro     int64
+	CacheCostMicro      int64
+	InputTokens         int64
+	OutputTokens        int64
+	CacheReadTokens     int64
+	CacheCreationTokens int64
+	Requests            int64
 }
 
 // ModelCost tracks costs for a specific model.
 type ModelCost struct {
-	InputCostMicro  int64
-	OutputCostMicro int64
-	InputTokens     int64
-	OutputTokens    int64
-	Requests        int64
+	InputCostMicro      int64
+	OutputCostMicro     int64
+	CacheCostMicro      int64
+	InputTokens         int64
+	OutputTokens        int64
+	CacheReadTokens     int64
+	CacheCreationTokens int64
+	Requests            int64
 }
 
 // Default pricing per 1M tokens (in USD cents * 100 for precision)
@@ -81,6 +129,11 @@ var defaultPricing = map[string]ModelPricing{
 	"openai/gpt-4o":             {InputPer1M: 250, OutputPer1M: 1000},
 	"openai/gpt-4-turbo":        {InputPer1M: 1000, OutputPer1M: 3000},
 
+	// Embedding models (input tokens only)
+	"text-embedding-3-small": {InputPer1M: 2},  // $0.02
+	"text-embedding-3-large": {InputPer1M: 13}, // $0.13
+	"text-embedding-ada-002": {InputPer1M: 10}, // $0.10
+
 	// Default for unknown models (conservative estimate)
 	"default": {InputPer1M: 100, OutputPer1M: 300},
 }
@@ -88,10 +141,11 @@ var defaultPricing = map[string]ModelPricing{
 // NewCostTracker creates a new cost tracker.
 func NewCostTracker() *CostTracker {
 	return &CostTracker{
-		providerCosts: make(map[string]*ProviderCost),
-		modelCosts:    make(map[string]*ModelCost),
-		startTime:     time.Now(),
-		customPricing: make(map[string]ModelPricing),
+		providerCosts:  make(map[string]*ProviderCost),
+		modelCosts:     make(map[string]*ModelCost),
+		embeddingCosts: make(map[string]*ModelCost),
+		startTime:      time.Now(),
+		customPricing:  make(map[string]ModelPricing),
 	}
 }
 
@@ -123,20 +177,32 @@ func (ct *CostTracker) GetPricing(model string) ModelPricing {
 
 // RecordUsage records token usage for cost tracking.
 func (ct *CostTracker) RecordUsage(provider, model string, inputTokens, outputTokens int) {
+	ct.RecordAnthropicUsage(provider, model, &models.AnthropicUsage{InputTokens: inputTokens, OutputTokens: outputTokens})
+}
+
+// RecordAnthropicUsage records the usage of a response for cost tracking.
+// Prompt cache reads and writes are priced separately from the other input
+// tokens.
+func (ct *CostTracker) RecordAnthropicUsage(provider, model string, usage *models.AnthropicUsage) {
+	defer ct.notifyUsage()
 	ct.mu.Lock()
 	defer ct.mu.Unlock()
 
 	pricing := ct.getPricingLocked(model)
+	inputTokens, outputTokens := usage.InputTokens, usage.OutputTokens
+	cacheRead, cacheCreation := usage.CacheReadInputTokens, usage.CacheCreationInputTokens
 
 	// Calculate costs in microcents (1 cent = 1,000,000 microcents)
 	// Cost = (tokens / 1,000,000) * (cents per 1M tokens) * 1,000,000 microcents/cent
 	// Simplified: inputCost = tokens * centsPerM (since the million cancels out)
 	inputCostMicro := int64(inputTokens) * int64(pricing.InputPer1M)
 	outputCostMicro := int64(outputTokens) * int64(pricing.OutputPer1M)
+	cacheCostMicro := pricing.cacheCostMicro(cacheRead, cacheCreation)
 
 	// Update totals
 	atomic.AddInt64(&ct.totalInputCostMicro, inputCostMicro)
 	atomic.AddInt64(&ct.totalOutputCostMicro, outputCostMicro)
+	atomic.AddInt64(&ct.totalCacheCostMicro, cacheCostMicro)
 	atomic.AddInt64(&ct.totalRequests, 1)
 
 	// Update provider costs
@@ -147,8 +213,11 @@ func (ct *CostTracker) RecordUsage(provider, model string, inputTokens, outputTo
 	}
 	atomic.AddInt64(&pc.InputCostMicro, inputCostMicro)
 	atomic.AddInt64(&pc.OutputCostMicro, outputCostMicro)
+	atomic.AddInt64(&pc.CacheCostMicro, cacheCostMicro)
 	atomic.AddInt64(&pc.InputTokens, int64(inputTokens))
 	atomic.AddInt64(&pc.OutputTokens, int64(outputTokens))
+	atomic.AddInt64(&pc.CacheReadTokens, int64(cacheRead))
+	atomic.AddInt64(&pc.CacheCreationTokens, int64(cacheCreation))
 	atomic.AddInt64(&pc.Requests, 1)
 
 	// Update model costs
@@ -159,9 +228,76 @@ func (ct *CostTracker) RecordUsage(provider, model string, inputTokens, outputTo
 	}
 	atomic.AddInt64(&mc.InputCostMicro, inputCostMicro)
 	atomic.AddInt64(&mc.OutputCostMicro, outputCostMicro)
+	atomic.AddInt64(&mc.CacheCostMicro, cacheCostMicro)
 	atomic.AddInt64(&mc.InputTokens, int64(inputTokens))
 	atomic.AddInt64(&mc.OutputTokens, int64(outputTokens))
+	atomic.AddInt64(&mc.CacheReadTokens, int64(cacheRead))
+	atomic.AddInt64(&mc.CacheCreationTokens, int64(cacheCreation))
 	atomic.AddInt64(&mc.Requests, 1)
+
+	ct.observeTokens(model, inputTokens, outputTokens)
+	ct.recordHistoryLocked(inputCostMicro+outputCostMicro+cacheCostMicro, int64(inputTokens), int64(outputTokens))
+}
+
+// cacheUsageLog describes the prompt cache tokens of usage for a log line, or
+// returns "" when the prompt cache wasn't used.
+func cacheUsageLog(usage *models.AnthropicUsage) string {
+	if usage.CacheReadInputTokens == 0 && usage.CacheCreationInputTokens == 0 {
+		return ""
+	}
+	return fmt.Sprintf(", %d cache read tokens, %d cache write tokens", usage.CacheReadInputTokens, usage.CacheCreationInputTokens)
+}
+
+// RecordEmbeddingUsage records token usage for an embeddings request. Embeddings
+// are reported in their own category rather than in the message totals.
+func (ct *CostTracker) RecordEmbeddingUsage(model string, inputTokens int) {
+	defer ct.notifyUsage()
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+
+	pricing := ct.getPricingLocked(model)
+	costMicro := int64(inputTokens) * int64(pricing.InputPer1M)
+	atomic.AddInt64(&ct.totalEmbeddingCostMicro, costMicro)
+
+	mc, ok := ct.embeddingCosts[model]
+	if !ok {
+		mc = &ModelCost{}
+		ct.embeddingCosts[model] = mc
+	}
+	atomic.AddInt64(&mc.InputCostMicro, costMicro)
+	atomic.AddInt64(&mc.InputTokens, int64(inputTokens))
+	atomic.AddInt64(&mc.Requests, 1)
+
+	ct.recordHistoryLocked(costMicro, int64(inputTokens), 0)
+}
+
+// SetUsageHook sets a function called with the total cost in USD each time
+// usage is recorded. It runs on the request path, so it must not block.
+func (ct *CostTracker) SetUsageHook(fn func(totalCostUSD float64)) {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+	ct.onUsage = fn
+}
+
+// notifyUsage calls the usage hook, if any. It must be called without ct.mu held.
+func (ct *CostTracker) notifyUsage() {
+	ct.mu.RLock()
+	fn := ct.onUsage
+	ct.mu.RUnlock()
+	if fn != nil {
+		fn(ct.GetTotalCostUSD())
+	}
+}
+
+// EstimateCostUSD returns what a request with the given usage costs, without
+// recording it.
+func (ct *CostTracker) EstimateCostUSD(model string, inputTokens, outputTokens int) float64 {
+	ct.mu.RLock()
+	defer ct.mu.RUnlock()
+
+	pricing := ct.getPricingLocked(model)
+	// Pricing is in cents per 1M tokens
+	return (float64(inputTokens)*pricing.InputPer1M + float64(outputTokens)*pricing.OutputPer1M) / 100000000.0
 }
 
 func (ct *CostTracker) getPricingLocked(model string) ModelPricing {
@@ -180,37 +316,55 @@ func (ct *CostTracker) getPricingLocked(model string) ModelPricing {
 
 // CostSummary represents a summary of costs.
 type CostSummary struct {
-	TotalCostUSD      float64                    `json:"total_cost_usd"`
+	TotalCostUSD      float64                    `json:"total_cost_usd"` // Includes embeddings
 	InputCostUSD      float64                    `json:"input_cost_usd"`
 	OutputCostUSD     float64                    `json:"output_cost_usd"`
+	CacheCostUSD      float64                    `json:"cache_cost_usd,omitempty"` // Prompt cache reads and writes
 	TotalRequests     int64                      `json:"total_requests"`
 	TotalInputTokens  int64                      `json:"total_input_tokens"`
 	TotalOutputTokens int64                      `json:"total_output_tokens"`
+	CacheReadTokens   int64                      `json:"cache_read_input_tokens,omitempty"`
+	CacheCreateTokens int64                      `json:"cache_creation_input_tokens,omitempty"`
 	CostPerRequest    float64                    `json:"avg_cost_per_request_usd"`
 	CostPerHour       float64                    `json:"cost_per_hour_usd"`
 	Uptime            string                     `json:"uptime"`
 	ByProvider        map[string]ProviderSummary `json:"by_provider"`
 	ByModel           map[string]ModelSummary    `json:"by_model"`
+	Embeddings        EmbeddingsSummary          `json:"embeddings"`
+}
+
+// EmbeddingsSummary provides cost summary for embeddings requests.
+type EmbeddingsSummary struct {
+	TotalCostUSD float64                 `json:"total_cost_usd"`
+	InputTokens  int64                   `json:"input_tokens"`
+	Requests     int64                   `json:"requests"`
+	ByModel      map[string]ModelSummary `json:"by_model"`
 }
 
 // ProviderSummary provides cost summary for a provider.
 type ProviderSummary struct {
-	TotalCostUSD  float64 `json:"total_cost_usd"`
-	InputCostUSD  float64 `json:"input_cost_usd"`
-	OutputCostUSD float64 `json:"output_cost_usd"`
-	InputTokens   int64   `json:"input_tokens"`
-	OutputTokens  int64   `json:"output_tokens"`
-	Requests      int64   `json:"requests"`
+	TotalCostUSD        float64 `json:"total_cost_usd"`
+	InputCostUSD        float64 `json:"input_cost_usd"`
+	OutputCostUSD       float64 `json:"output_cost_usd"`
+	CacheCostUSD        float64 `json:"cache_cost_usd,omitempty"`
+	InputTokens         int64   `json:"input_tokens"`
+	OutputTokens        int64   `json:"output_tokens"`
+	CacheReadTokens     int64   `json:"cache_read_input_tokens,omitempty"`
+	CacheCreationTokens int64   `json:"cache_creation_input_tokens,omitempty"`
+	Requests            int64   `json:"requests"`
 }
 
 // ModelSummary provides cost summary for a model.
 type ModelSummary struct {
-	TotalCostUSD  float64 `json:"total_cost_usd"`
-	InputCostUSD  float64 `json:"input_cost_usd"`
-	OutputCostUSD float64 `json:"output_cost_usd"`
-	InputTokens   int64   `json:"input_tokens"`
-	OutputTokens  int64   `json:"output_tokens"`
-	Requests      int64   `json:"requests"`
+	TotalCostUSD        float64 `json:"total_cost_usd"`
+	InputCostUSD        float64 `json:"input_cost_usd"`
+	OutputCostUSD       float64 `json:"output_cost_usd"`
+	CacheCostUSD        float64 `json:"cache_cost_usd,omitempty"`
+	InputTokens         int64   `json:"input_tokens"`
+	OutputTokens        int64   `json:"output_tokens"`
+	CacheReadTokens     int64   `json:"cache_read_input_tokens,omitempty"`
+	CacheCreationTokens int64   `json:"cache_creation_input_tokens,omitempty"`
+	Requests            int64   `json:"requests"`
 }
 
 // GetSummary returns the current cost summary.
@@ -227,7 +381,9 @@ func (ct *CostTracker) GetSummary() CostSummary {
 	// cents / 100 = dollars
 	inputCostUSD := float64(inputCostMicro) / 100000000.0
 	outputCostUSD := float64(outputCostMicro) / 100000000.0
-	totalCostUSD := inputCostUSD + outputCostUSD
+	cacheCostUSD := float64(atomic.LoadInt64(&ct.totalCacheCostMicro)) / 100000000.0
+	embeddingCostUSD := float64(atomic.LoadInt64(&ct.totalEmbeddingCostMicro)) / 100000000.0
+	totalCostUSD := inputCostUSD + outputCostUSD + cacheCostUSD + embeddingCostUSD
 
 	uptime := time.Since(ct.startTime)
 
@@ -235,6 +391,7 @@ func (ct *CostTracker) GetSummary() CostSummary {
 		TotalCostUSD:  totalCostUSD,
 		InputCostUSD:  inputCostUSD,
 		OutputCostUSD: outputCostUSD,
+		CacheCostUSD:  cacheCostUSD,
 		TotalRequests: totalRequests,
 		Uptime:        uptime.String(),
 		ByProvider:    make(map[string]ProviderSummary),
@@ -246,13 +403,15 @@ func (ct *CostTracker) GetSummary() CostSummary {
 	for _, pc := range ct.providerCosts {
 		totalInputTokens += atomic.LoadInt64(&pc.InputTokens)
 		totalOutputTokens += atomic.LoadInt64(&pc.OutputTokens)
+		summary.CacheReadTokens += atomic.LoadInt64(&pc.CacheReadTokens)
+		summary.CacheCreateTokens += atomic.LoadInt64(&pc.CacheCreationTokens)
 	}
 	summary.TotalInputTokens = totalInputTokens
 	summary.TotalOutputTokens = totalOutputTokens
 
 	// Cost per request
 	if totalRequests > 0 {
-		summary.CostPerRequest = totalCostUSD / float64(totalRequests)
+		summary.CostPerRequest = (inputCostUSD + outputCostUSD + cacheCostUSD) / float64(totalRequests)
 	}
 
 	// Cost per hour
@@ -265,13 +424,17 @@ func (ct *CostTracker) GetSummary() CostSummary {
 	for provider, pc := range ct.providerCosts {
 		inputUSD := float64(atomic.LoadInt64(&pc.InputCostMicro)) / 100000000.0
 		outputUSD := float64(atomic.LoadInt64(&pc.OutputCostMicro)) / 100000000.0
+		cacheUSD := float64(atomic.LoadInt64(&pc.CacheCostMicro)) / 100000000.0
 		summary.ByProvider[provider] = ProviderSummary{
-			TotalCostUSD:  inputUSD + outputUSD,
-			InputCostUSD:  inputUSD,
-			OutputCostUSD: outputUSD,
-			InputTokens:   atomic.LoadInt64(&pc.InputTokens),
-			OutputTokens:  atomic.LoadInt64(&pc.OutputTokens),
-			Requests:      atomic.LoadInt64(&pc.Requests),
+			TotalCostUSD:        inputUSD + outputUSD + cacheUSD,
+			InputCostUSD:        inputUSD,
+			OutputCostUSD:       outputUSD,
+			CacheCostUSD:        cacheUSD,
+			InputTokens:         atomic.LoadInt64(&pc.InputTokens),
+			OutputTokens:        atomic.LoadInt64(&pc.OutputTokens),
+			CacheReadTokens:     atomic.LoadInt64(&pc.CacheReadTokens),
+			CacheCreationTokens: atomic.LoadInt64(&pc.CacheCreationTokens),
+			Requests:            atomic.LoadInt64(&pc.Requests),
 		}
 	}
 
@@ -279,24 +442,45 @@ func (ct *CostTracker) GetSummary() CostSummary {
 	for model, mc := range ct.modelCosts {
 		inputUSD := float64(atomic.LoadInt64(&mc.InputCostMicro)) / 100000000.0
 		outputUSD := float64(atomic.LoadInt64(&mc.OutputCostMicro)) / 100000000.0
+		cacheUSD := float64(atomic.LoadInt64(&mc.CacheCostMicro)) / 100000000.0
 		summary.ByModel[model] = ModelSummary{
-			TotalCostUSD:  inputUSD + outputUSD,
-			InputCostUSD:  inputUSD,
-			OutputCostUSD: outputUSD,
-			InputTokens:   atomic.LoadInt64(&mc.InputTokens),
-			OutputTokens:  atomic.LoadInt64(&mc.OutputTokens),
-			Requests:      atomic.LoadInt64(&mc.Requests),
+			TotalCostUSD:        inputUSD + outputUSD + cacheUSD,
+			InputCostUSD:        inputUSD,
+			OutputCostUSD:       outputUSD,
+			CacheCostUSD:        cacheUSD,
+			InputTokens:         atomic.LoadInt64(&mc.InputTokens),
+			OutputTokens:        atomic.LoadInt64(&mc.OutputTokens),
+			CacheReadTokens:     atomic.LoadInt64(&mc.CacheReadTokens),
+			CacheCreationTokens: atomic.LoadInt64(&mc.CacheCreationTokens),
+			Requests:            atomic.LoadInt64(&mc.Requests),
+		}
+	}
+
+	// Embeddings breakdown
+	summary.Embeddings.TotalCostUSD = embeddingCostUSD
+	summary.Embeddings.ByModel = make(map[string]ModelSummary)
+	for model, mc := range ct.embeddingCosts {
+		inputUSD := float64(atomic.LoadInt64(&mc.InputCostMicro)) / 100000000.0
+		summary.Embeddings.InputTokens += atomic.LoadInt64(&mc.InputTokens)
+		summary.Embeddings.Requests += atomic.LoadInt64(&mc.Requests)
+		summary.Embeddings.ByModel[model] = ModelSummary{
+			TotalCostUSD: inputUSD,
+			InputCostUSD: inputUSD,
+			InputTokens:  atomic.LoadInt64(&mc.InputTokens),
+			Requests:     atomic.LoadInt64(&mc.Requests),
 		}
 	}
 
 	return summary
 }
 
-// GetTotalCostUSD returns the total cost in USD.
+// GetTotalCostUSD returns the total cost in USD, including embeddings.
 func (ct *CostTracker) GetTotalCostUSD() float64 {
 	inputCostMicro := atomic.LoadInt64(&ct.totalInputCostMicro)
 	outputCostMicro := atomic.LoadInt64(&ct.totalOutputCostMicro)
-	return float64(inputCostMicro+outputCostMicro) / 100000000.0
+	cacheCostMicro := atomic.LoadInt64(&ct.totalCacheCostMicro)
+	embeddingCostMicro := atomic.LoadInt64(&ct.totalEmbeddingCostMicro)
+	return float64(inputCostMicro+outputCostMicro+cacheCostMicro+embeddingCostMicro) / 100000000.0
 }
 
 // Reset resets all cost tracking data.
@@ -306,8 +490,14 @@ func (ct *CostTracker) Reset() {
 
 	atomic.StoreInt64(&ct.totalInputCostMicro, 0)
 	atomic.StoreInt64(&ct.totalOutputCostMicro, 0)
+	atomic.StoreInt64(&ct.totalCacheCostMicro, 0)
 	atomic.StoreInt64(&ct.totalRequests, 0)
 	ct.providerCosts = make(map[string]*ProviderCost)
 	ct.modelCosts = make(map[string]*ModelCost)
+	ct.inputTokenHists = nil
+	ct.outputTokenHists = nil
+	atomic.StoreInt64(&ct.totalEmbeddingCostMicro, 0)
+	ct.embeddingCosts = make(map[string]*ModelCost)
+	ct.history = nil
 	ct.startTime = time.Now()
 }
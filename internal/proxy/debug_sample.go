@@ -0,0 +1,55 @@
+// Package proxy implements the HTTP proxy server.
+package proxy
+
+import (
+	"context"
+	"crypto/rand"
+	"math/big"
+	"net/http"
+)
+
+// debugSampleResolution is the granularity of the sampling decision.
+const debugSampleResolution = 1_000_000
+
+// debugSampleContextKey is the request context key marking a request chosen
+// for debug sampling.
+type debugSampleContextKey struct{}
+
+// withDebugSample decides once per request whether it is captured in full to
+// the debug log, so a sampled request logs both its request and response.
+func (h *Handler) withDebugSample(r *http.Request) *http.Request {
+	if h.cfg.DebugSampleRate <= 0 || !sampled(h.cfg.DebugSampleRate) {
+		return r
+	}
+	return r.WithContext(context.WithValue(r.Context(), debugSampleContextKey{}, true))
+}
+
+// sampled returns true with probability rate.
+func sampled(rate float64) bool {
+	if rate >= 1 {
+		return true
+	}
+	n, err := rand.Int(rand.Reader, big.NewInt(debugSampleResolution))
+	if err != nil {
+		return false
+	}
+	return n.Int64() < int64(rate*debugSampleResolution)
+}
+
+// debugSampled reports whether the request was chosen by withDebugSample.
+func debugSampled(ctx context.Context) bool {
+	ok, _ := ctx.Value(debugSampleContextKey{}).(bool)
+	return ok
+}
+
+// debugRequests reports whether the request's payloads are debug-logged,
+// either because CLASP_DEBUG_REQUESTS is set or because it was sampled.
+func (h *Handler) debugRequests(ctx context.Context) bool {
+	return h.cfg.DebugRequests || debugSampled(ctx)
+}
+
+// debugResponses reports whether the response payloads are debug-logged,
+// either because CLASP_DEBUG_RESPONSES is set or because it was sampled.
+func (h *Handler) debugResponses(ctx context.Context) bool {
+	return h.cfg.DebugResponses || debugSampled(ctx)
+}
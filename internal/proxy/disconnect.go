@@ -0,0 +1,55 @@
+// Package proxy implements the HTTP proxy server.
+package proxy
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sync/atomic"
+)
+
+// watchClientDisconnect closes the upstream response body as soon as the client
+// goes away, so a stream nobody will receive stops being read (and billed).
+// The returned function ends the watch and reports whether the client disconnected;
+// it must be called once the stream has been processed.
+func (h *Handler) watchClientDisconnect(ctx context.Context, resp *http.Response) func() bool {
+	done := make(chan struct{})
+	var aborted int32
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			atomic.StoreInt32(&aborted, 1)
+			resp.Body.Close()
+		case <-done:
+		}
+	}()
+
+	return func() bool {
+		close(done)
+		if atomic.LoadInt32(&aborted) == 0 {
+			return false
+		}
+		atomic.AddInt64(&h.metrics.StreamClientAborts, 1)
+		log.Printf("[CLASP] Client disconnected mid-stream, closed upstream stream")
+		return true
+	}
+}
+
+// usageReporter is implemented by the stream processors.
+type usageReporter interface {
+	GetUsage() (inputTokens, outputTokens int)
+}
+
+// recordAbortedStreamUsage records the tokens counted before a stream was cut short.
+// The processor's usage callback only fires when a stream completes, so aborted
+// streams are recorded here instead.
+func (h *Handler) recordAbortedStreamUsage(ctx context.Context, targetModel string, processor usageReporter) {
+	inputTokens, outputTokens := processor.GetUsage()
+	auditUsage(ctx, inputTokens, outputTokens)
+	if h.costTracker == nil || inputTokens == 0 && outputTokens == 0 {
+		return
+	}
+	h.costTracker.RecordUsage(h.provider.Name(), targetModel, inputTokens, outputTokens)
+	log.Printf("[CLASP] Aborted stream cost tracked: %d input tokens, %d output tokens", inputTokens, outputTokens)
+}
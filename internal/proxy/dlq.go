@@ -0,0 +1,186 @@
+// Package proxy implements the HTTP proxy server.
+package proxy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jedarden/clasp/internal/secrets"
+)
+
+// DeadLetter is a queued request that failed after exhausting its retries.
+type DeadLetter struct {
+	ID        string    `json:"id"`
+	Body      string    `json:"body"`
+	Priority  string    `json:"priority"`
+	CreatedAt time.Time `json:"created_at"`
+	FailedAt  time.Time `json:"failed_at"`
+	Retries   int       `json:"retries"`
+	LastError string    `json:"last_error"`
+}
+
+// DeadLetterQueue stores permanently failed queued requests on disk, one file
+// per request, so they can be inspected and replayed.
+type DeadLetterQueue struct {
+	dir string
+	mu  sync.Mutex
+	seq uint64
+}
+
+// DefaultDeadLetterDir returns the default dead-letter directory, ~/.clasp/dlq.
+func DefaultDeadLetterDir() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("getting home directory: %w", err)
+	}
+	return filepath.Join(homeDir, ".clasp", "dlq"), nil
+}
+
+// NewDeadLetterQueue creates a dead-letter queue stored in dir.
+func NewDeadLetterQueue(dir string) (*DeadLetterQueue, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("creating dead-letter directory: %w", err)
+	}
+	return &DeadLetterQueue{dir: dir}, nil
+}
+
+// Add records a failed request. Secrets in the body and error are masked
+// before anything is written.
+func (d *DeadLetterQueue) Add(req *QueuedRequest, lastErr error) (*DeadLetter, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now().UTC()
+	d.seq++
+	entry := &DeadLetter{
+		ID:        fmt.Sprintf("%d-%d", now.UnixNano(), d.seq),
+		Body:      string(secrets.MaskJSONSecrets(req.Body)),
+		Priority:  req.Priority.String(),
+		CreatedAt: req.CreatedAt,
+		FailedAt:  now,
+		Retries:   req.RetryCount,
+	}
+	if lastErr != nil {
+		entry.LastError = secrets.MaskAllSecrets(lastErr.Error())
+	}
+
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("encoding dead letter: %w", err)
+	}
+	if err := os.WriteFile(d.path(entry.ID), data, 0o600); err != nil {
+		return nil, fmt.Errorf("writing dead letter: %w", err)
+	}
+	return entry, nil
+}
+
+// List returns the stored dead letters, oldest first.
+func (d *DeadLetterQueue) List() ([]*DeadLetter, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	entries, err := os.ReadDir(d.dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading dead-letter directory: %w", err)
+	}
+	letters := make([]*DeadLetter, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(d.dir, entry.Name()))
+		if err != nil {
+			continue
+		}
+		var letter DeadLetter
+		if err := json.Unmarshal(data, &letter); err != nil {
+			continue
+		}
+		letters = append(letters, &letter)
+	}
+	sort.SliceStable(letters, func(i, j int) bool {
+		return letters[i].FailedAt.Before(letters[j].FailedAt)
+	})
+	return letters, nil
+}
+
+// Remove deletes a dead letter.
+func (d *DeadLetterQueue) Remove(id string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return os.Remove(d.path(id))
+}
+
+func (d *DeadLetterQueue) path(id string) string {
+	return filepath.Join(d.dir, filepath.Base(id)+".json")
+}
+
+// HandleDLQ handles /queue/dlq. GET lists dead-lettered requests; POST with
+// action=replay puts them back on the queue (optionally only the one named
+// by id), where the queue workers send them upstream again, and removes them
+// from the dead-letter queue. It is only served with authentication enabled.
+func (h *Handler) HandleDLQ(w http.ResponseWriter, r *http.Request) {
+	if h.queue == nil || h.queue.DeadLetters() == nil {
+		h.writeErrorResponse(w, http.StatusNotFound, "not_found_error", "The request queue is not enabled")
+		return
+	}
+	// Dead letters hold full prompts
+	if !h.requireAuth(w, "/queue/dlq") {
+		return
+	}
+	dlq := h.queue.DeadLetters()
+
+	switch r.Method {
+	case http.MethodGet:
+		letters, err := dlq.List()
+		if err != nil {
+			h.writeErrorResponse(w, http.StatusInternalServerError, "api_error", err.Error())
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"count":    len(letters),
+			"requests": letters,
+		})
+
+	case http.MethodPost:
+		if r.URL.Query().Get("action") != "replay" {
+			h.writeErrorResponse(w, http.StatusBadRequest, "invalid_request_error", "Unknown action - use action=replay")
+			return
+		}
+		if !h.queue.Running() {
+			h.writeErrorResponse(w, http.StatusServiceUnavailable, "api_error", "The request queue is not being processed")
+			return
+		}
+		replayed, err := h.queue.ReplayDeadLetters(r.URL.Query().Get("id"))
+		if err != nil && replayed == 0 {
+			status := http.StatusServiceUnavailable
+			if errors.Is(err, os.ErrNotExist) {
+				status = http.StatusNotFound
+			}
+			h.writeErrorResponse(w, status, "api_error", err.Error())
+			return
+		}
+		response := map[string]interface{}{
+			"status":   "ok",
+			"replayed": replayed,
+		}
+		if err != nil {
+			response["error"] = err.Error()
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(response)
+
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		h.writeErrorResponse(w, http.StatusMethodNotAllowed, "invalid_request_error", "Method not allowed")
+	}
+}
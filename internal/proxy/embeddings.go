@@ -0,0 +1,133 @@
+// Package proxy implements the HTTP proxy server.
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/jedarden/clasp/internal/config"
+	"github.com/jedarden/clasp/internal/provider"
+)
+
+// HandleEmbeddings proxies an OpenAI-style embeddings request to the
+// provider's embeddings endpoint. The request is passed through unchanged
+// apart from the model, which goes through alias and tier routing.
+// Providers without an embeddings endpoint get a 501.
+func (h *Handler) HandleEmbeddings(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.writeErrorResponse(w, http.StatusMethodNotAllowed, "invalid_request_error", "Method not allowed")
+		return
+	}
+
+	r = h.withClientKey(r)
+	r, cancelTimeout := h.withRequestTimeout(r)
+	defer cancelTimeout()
+
+	if h.cfg.MaxRequestBytes > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, h.cfg.MaxRequestBytes)
+	}
+	defer r.Body.Close()
+
+	var req map[string]json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			h.writeErrorResponse(w, http.StatusRequestEntityTooLarge, "request_too_large",
+				fmt.Sprintf("Request body exceeds the maximum size of %d bytes", maxBytesErr.Limit))
+			return
+		}
+		h.writeErrorResponse(w, http.StatusBadRequest, "invalid_request_error",
+			fmt.Sprintf("Invalid request body: %v. Expected OpenAI embeddings format with 'model' and 'input'.", err))
+		return
+	}
+	var model string
+	if err := json.Unmarshal(req["model"], &model); err != nil || model == "" {
+		h.writeErrorResponse(w, http.StatusBadRequest, "invalid_request_error", "model is required")
+		return
+	}
+	if _, ok := req["input"]; !ok {
+		h.writeErrorResponse(w, http.StatusBadRequest, "invalid_request_error", "input is required")
+		return
+	}
+
+	p, targetModel := h.selectEmbeddingsProvider(model)
+	embeddings, ok := p.(provider.EmbeddingsProvider)
+	if !ok {
+		h.writeErrorResponse(w, http.StatusNotImplemented, "not_supported_error",
+			fmt.Sprintf("Provider '%s' does not support embeddings", p.Name()))
+		return
+	}
+
+	req["model"], _ = json.Marshal(targetModel)
+	reqBody, err := json.Marshal(req)
+	if err != nil {
+		h.writeErrorResponse(w, http.StatusInternalServerError, "api_error", "Error preparing request")
+		return
+	}
+	log.Printf("[CLASP] Embeddings: %s -> %s (provider: %s)", model, targetModel, p.Name())
+
+	resp, err := h.doRequestWithRetryTo(r.Context(), reqBody, p, func() string {
+		return embeddings.GetEmbeddingsURL(targetModel)
+	})
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			h.writeErrorResponse(w, http.StatusGatewayTimeout, "timeout_error", "Upstream request timed out")
+			return
+		}
+		log.Printf("[CLASP] Error in embeddings request: %v", err)
+		h.writeErrorResponse(w, http.StatusBadGateway, "api_error", fmt.Sprintf("Upstream error: %v", err))
+		return
+	}
+	defer resp.Body.Close()
+
+	body, err := h.readUpstreamBody(resp)
+	if err != nil {
+		log.Printf("[CLASP] Error reading embeddings response: %v", err)
+		h.writeErrorResponse(w, http.StatusBadGateway, "api_error", "Error reading upstream response")
+		return
+	}
+
+	if resp.StatusCode == http.StatusOK && h.costTracker != nil {
+		var usage struct {
+			Usage struct {
+				PromptTokens int `json:"prompt_tokens"`
+				TotalTokens  int `json:"total_tokens"`
+			} `json:"usage"`
+		}
+		if json.Unmarshal(body, &usage) == nil {
+			tokens := usage.Usage.PromptTokens
+			if tokens == 0 {
+				tokens = usage.Usage.TotalTokens
+			}
+			h.costTracker.RecordEmbeddingUsage(targetModel, tokens)
+		}
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = "application/json"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(resp.StatusCode)
+	_, _ = w.Write(body)
+}
+
+// selectEmbeddingsProvider resolves aliases and multi-provider tiers for an
+// embeddings model. Unlike messages, the default model (CLASP_MODEL) is not
+// applied since it names a chat model.
+func (h *Handler) selectEmbeddingsProvider(model string) (provider.Provider, string) {
+	model = h.cfg.ResolveAlias(model)
+	if tierCfg := h.cfg.GetTierConfig(model); tierCfg != nil {
+		if tierProvider, ok := h.tierProviders[config.GetModelTier(model)]; ok {
+			if tierCfg.Model != "" {
+				model = tierCfg.Model
+			}
+			return tierProvider, model
+		}
+	}
+	return h.provider, model
+}
@@ -0,0 +1,80 @@
+// Package proxy implements the HTTP proxy server.
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/jedarden/clasp/internal/provider"
+	"github.com/jedarden/clasp/internal/translator"
+	"github.com/jedarden/clasp/pkg/models"
+)
+
+// endpointUnsupportedHints are phrases in a 400 error body indicating the model
+// is not served on the requested endpoint (as opposed to an invalid request).
+var endpointUnsupportedHints = []string{
+	"v1/responses",
+	"v1/chat/completions",
+	"responses api",
+	"chat completions",
+	"not a chat model",
+	"endpoint",
+}
+
+// isEndpointUnsupported reports whether an upstream response indicates the endpoint
+// does not serve the model: a 404, or a 400 whose error mentions the endpoint.
+// The response body is restored so it can still be read by the caller.
+func isEndpointUnsupported(resp *http.Response) bool {
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		return true
+	case http.StatusBadRequest:
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		if err != nil {
+			return false
+		}
+		lower := strings.ToLower(string(body))
+		for _, hint := range endpointUnsupportedHints {
+			if strings.Contains(lower, hint) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// tryEndpointFallback retries the same model on the other OpenAI endpoint (Chat
+// Completions <-> Responses API) when the first one reports it is unsupported.
+// Returns the original response if the retry cannot be made.
+func (h *Handler) tryEndpointFallback(ctx context.Context, req *models.AnthropicRequest, resp *http.Response, p *provider.OpenAIProvider, targetModel string, useResponsesAPI bool) (*http.Response, bool) {
+	retryResponsesAPI := !useResponsesAPI
+	from, to := "Chat Completions", "Responses API"
+	endpointType := translator.EndpointResponses
+	if !retryResponsesAPI {
+		from, to = to, from
+		endpointType = translator.EndpointChatCompletions
+	}
+	log.Printf("[CLASP] %s returned %d for model %s, retrying via %s", from, resp.StatusCode, targetModel, to)
+
+	// The other endpoint never continues a compacted session, so send the full context.
+	reqBody, err := h.transformRequest(ctx, req, p, targetModel, retryResponsesAPI, "", 0)
+	if err != nil {
+		log.Printf("[CLASP] Endpoint fallback skipped: %v", err)
+		return resp, useResponsesAPI
+	}
+
+	p.SetEndpointType(endpointType)
+	retryResp, err := h.doRequestWithRetry(ctx, reqBody, p)
+	if err != nil {
+		log.Printf("[CLASP] Endpoint fallback via %s failed: %v", to, err)
+		return resp, useResponsesAPI
+	}
+	resp.Body.Close()
+	return retryResp, retryResponsesAPI
+}
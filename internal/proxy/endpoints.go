@@ -0,0 +1,169 @@
+// Package proxy implements the HTTP proxy server.
+package proxy
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jedarden/clasp/internal/provider"
+)
+
+const (
+	// endpointLatencyAlpha is the EWMA weight given to the newest latency sample.
+	endpointLatencyAlpha = 0.3
+	// endpointCooldown is how long an endpoint is avoided after a failure.
+	endpointCooldown = 30 * time.Second
+)
+
+// EndpointSelector routes a provider's requests across several regional base URLs.
+// Each request goes to the healthy endpoint with the lowest EWMA of recent
+// request latencies; endpoints that fail are skipped for a cooldown period.
+type EndpointSelector struct {
+	mu sync.Mutex
+
+	// baseURL is the provider's configured base URL, replaced by the chosen endpoint.
+	baseURL   string
+	endpoints []*regionEndpoint
+	cooldown  time.Duration
+}
+
+// regionEndpoint tracks the observed latency and health of one endpoint.
+type regionEndpoint struct {
+	url            string
+	latency        time.Duration // EWMA of successful request latencies
+	samples        int
+	unhealthyUntil time.Time
+}
+
+// NewEndpointSelector creates a selector for a provider with the given base URL.
+func NewEndpointSelector(baseURL string, endpoints []string) *EndpointSelector {
+	s := &EndpointSelector{
+		baseURL:  strings.TrimSuffix(baseURL, "/"),
+		cooldown: endpointCooldown,
+	}
+	for _, url := range endpoints {
+		s.endpoints = append(s.endpoints, &regionEndpoint{url: strings.TrimSuffix(url, "/")})
+	}
+	return s
+}
+
+// Select returns the base URL of the endpoint to use for the next request.
+// Endpoints without latency samples are tried first so every region gets measured.
+// If every endpoint is cooling down, the one that recovers soonest is used.
+func (s *EndpointSelector) Select() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	var best, soonest *regionEndpoint
+	for _, e := range s.endpoints {
+		if now.Before(e.unhealthyUntil) {
+			if soonest == nil || e.unhealthyUntil.Before(soonest.unhealthyUntil) {
+				soonest = e
+			}
+			continue
+		}
+		if best == nil || e.samples == 0 && best.samples > 0 || (e.samples > 0) == (best.samples > 0) && e.latency < best.latency {
+			best = e
+		}
+	}
+	if best == nil {
+		best = soonest
+	}
+	return best.url
+}
+
+// Rewrite points an upstream URL built from the provider's base URL at endpoint.
+func (s *EndpointSelector) Rewrite(upstreamURL, endpoint string) string {
+	if s.baseURL == "" || !strings.HasPrefix(upstreamURL, s.baseURL) {
+		return upstreamURL
+	}
+	return endpoint + strings.TrimPrefix(upstreamURL, s.baseURL)
+}
+
+// RecordSuccess adds a latency sample for an endpoint and marks it healthy.
+func (s *EndpointSelector) RecordSuccess(endpoint string, latency time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e := s.find(endpoint)
+	if e == nil {
+		return
+	}
+	if e.samples == 0 {
+		e.latency = latency
+	} else {
+		e.latency = time.Duration(endpointLatencyAlpha*float64(latency) + (1-endpointLatencyAlpha)*float64(e.latency))
+	}
+	e.samples++
+	e.unhealthyUntil = time.Time{}
+}
+
+// RecordFailure marks an endpoint unhealthy for the cooldown period.
+func (s *EndpointSelector) RecordFailure(endpoint string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e := s.find(endpoint); e != nil {
+		e.unhealthyUntil = time.Now().Add(s.cooldown)
+	}
+}
+
+// inherit copies the latency samples and cooldowns of the endpoints s shares
+// with prev, so a reload doesn't have to measure every region again.
+func (s *EndpointSelector) inherit(prev *EndpointSelector) {
+	prev.mu.Lock()
+	defer prev.mu.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, e := range s.endpoints {
+		if old := prev.find(e.url); old != nil {
+			e.latency = old.latency
+			e.samples = old.samples
+			e.unhealthyUntil = old.unhealthyUntil
+		}
+	}
+}
+
+func (s *EndpointSelector) find(endpoint string) *regionEndpoint {
+	for _, e := range s.endpoints {
+		if e.url == endpoint {
+			return e
+		}
+	}
+	return nil
+}
+
+// providerBaseURL returns the base URL a provider builds its endpoint URLs from.
+func providerBaseURL(p provider.Provider) string {
+	switch p := p.(type) {
+	case *provider.OpenAIProvider:
+		return p.BaseURL
+	case *provider.AzureProvider:
+		return p.Endpoint
+	case *provider.OpenRouterProvider:
+		return p.BaseURL
+	case *provider.AnthropicProvider:
+		return p.BaseURL
+	case *provider.OllamaProvider:
+		return p.BaseURL
+	case *provider.GeminiProvider:
+		return p.BaseURL
+	case *provider.DeepSeekProvider:
+		return p.BaseURL
+	case *provider.GrokProvider:
+		return p.BaseURL
+	case *provider.QwenProvider:
+		return p.BaseURL
+	case *provider.MiniMaxProvider:
+		return p.BaseURL
+	case *provider.LiteLLMProvider:
+		return p.BaseURL
+	case *provider.CustomProvider:
+		return p.BaseURL
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,50 @@
+// Package proxy implements the HTTP proxy server.
+package proxy
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/jedarden/clasp/internal/provider"
+)
+
+// credentialHeaders are the headers providers carry API keys in. Extra headers
+// never replace them.
+var credentialHeaders = []string{"Authorization", "x-api-key", "api-key", "x-goog-api-key"}
+
+// isCredentialHeader reports whether name is one of credentialHeaders.
+func isCredentialHeader(name string) bool {
+	for _, h := range credentialHeaders {
+		if strings.EqualFold(name, h) {
+			return true
+		}
+	}
+	return false
+}
+
+// addTierExtraHeaders records the CLASP_<TIER>_EXTRA_HEADERS of a tier's
+// provider.
+func (h *Handler) addTierExtraHeaders(p provider.Provider, headers map[string]string) {
+	if len(headers) == 0 {
+		return
+	}
+	if h.tierExtraHeaders == nil {
+		h.tierExtraHeaders = make(map[provider.Provider]map[string]string)
+	}
+	h.tierExtraHeaders[p] = headers
+}
+
+// applyExtraHeaders adds CLASP_EXTRA_HEADERS and the provider's tier headers to
+// the headers built by the provider. Tier headers take precedence, and both
+// replace provider defaults such as OpenRouter's HTTP-Referer and X-Title, but
+// credential headers are left untouched.
+func (h *Handler) applyExtraHeaders(p provider.Provider, headers http.Header) {
+	for _, extra := range []map[string]string{h.cfg.ExtraHeaders, h.tierExtraHeaders[p]} {
+		for name, value := range extra {
+			if isCredentialHeader(name) {
+				continue
+			}
+			headers.Set(name, value)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/jedarden/clasp/internal/config"
+	"github.com/jedarden/clasp/internal/provider"
+	"github.com/jedarden/clasp/pkg/models"
+)
+
+// fallbackHop is a provider tried after the primary fails. An empty model
+// keeps the primary's target model.
+type fallbackHop struct {
+	provider provider.Provider
+	model    string
+}
+
+// initializeFallbackChain creates the providers of CLASP_FALLBACK_CHAIN.
+func (h *Handler) initializeFallbackChain() {
+	for i, hopCfg := range h.cfg.GetFallbackChainConfigs() {
+		hopProvider, err := createTierProvider(hopCfg)
+		if err != nil {
+			log.Printf("[CLASP] Fallback chain: skipping hop %d: %v", i+1, err)
+			continue
+		}
+		h.fallbackChain = append(h.fallbackChain, fallbackHop{provider: hopProvider, model: hopCfg.Model})
+		h.addEndpointSelector(hopProvider, hopCfg.Provider)
+		log.Printf("[CLASP] Fallback chain: hop %d -> %s (%s)", i+1, hopCfg.Provider, hopCfg.Model)
+	}
+}
+
+// hasFallback reports whether any fallback provider is configured.
+func (h *Handler) hasFallback() bool {
+	return h.fallbackProvider != nil || len(h.tierFallbacks) > 0 || len(h.fallbackChain) > 0
+}
+
+// getFallbackChain returns the providers to try after the primary, in order:
+// the tier-specific or global fallback, then the CLASP_FALLBACK_CHAIN hops.
+func (h *Handler) getFallbackChain(requestModel string) []fallbackHop {
+	if !h.featureEnabled(featureFallback) {
+		return nil
+	}
+
+	var chain []fallbackHop
+	tier := config.GetModelTier(requestModel)
+	if fbProvider, ok := h.tierFallbacks[tier]; ok {
+		hop := fallbackHop{provider: fbProvider}
+		if tierCfg := h.cfg.GetTierConfig(requestModel); tierCfg != nil {
+			hop.model = tierCfg.FallbackModel
+		}
+		chain = append(chain, hop)
+	} else if h.fallbackProvider != nil {
+		chain = append(chain, fallbackHop{provider: h.fallbackProvider, model: h.cfg.FallbackModel})
+	}
+	return append(chain, h.fallbackChain...)
+}
+
+// tryFallback tries the fallback chain in order after the primary provider
+// failed and returns the first usable response with its 1-based hop number.
+// If every hop fails, the last hop's result is returned with hop 0.
+func (h *Handler) tryFallback(ctx context.Context, req *models.AnthropicRequest, primary provider.Provider, resp *http.Response, targetModel string, originalErr error) (*http.Response, string, bool, int, error) {
+	chain := h.getFallbackChain(req.Model)
+	if len(chain) == 0 || !fallbackAllowed(ctx) {
+		return resp, targetModel, false, 0, originalErr
+	}
+
+	// Close original response if it exists
+	if resp != nil {
+		resp.Body.Close()
+	}
+
+	source := fallbackSource{tier: h.fallbackTier(req.Model), provider: primary.Name()}
+	var hopTarget string
+	var useResponsesAPI bool
+	var err error
+	for i, hop := range chain {
+		atomic.AddInt64(&h.metrics.FallbackAttempts, 1)
+		if i == 0 {
+			log.Printf("[CLASP] Primary provider failed, attempting fallback to %s", hop.provider.Name())
+		} else {
+			log.Printf("[CLASP] Fallback %d failed, attempting fallback %d to %s", i, i+1, hop.provider.Name())
+		}
+
+		resp, hopTarget, useResponsesAPI, err = h.sendToFallback(ctx, req, hop.provider, hop.model, targetModel)
+		if err == nil && resp.StatusCode < 500 {
+			atomic.AddInt64(&h.metrics.FallbackSuccesses, 1)
+			h.metrics.fallbacks.record(source, true)
+			log.Printf("[CLASP] Fallback %d to %s succeeded", i+1, hop.provider.Name())
+			auditRoute(ctx, hop.provider.Name(), hopTarget, true)
+			return resp, hopTarget, useResponsesAPI, i + 1, nil
+		}
+		if i < len(chain)-1 && resp != nil {
+			resp.Body.Close()
+		}
+		h.metrics.fallbacks.record(source, false)
+	}
+
+	return resp, hopTarget, useResponsesAPI, 0, err
+}
+
+// fallbackHeader is the X-CLASP-Fallback value for a response served by a
+// fallback hop.
+func fallbackHeader(hop int) string {
+	return fmt.Sprintf("fallback:%d", hop)
+}
@@ -0,0 +1,119 @@
+// Package proxy implements the HTTP proxy server.
+package proxy
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/jedarden/clasp/internal/config"
+)
+
+// defaultFallbackTier labels fallbacks of requests not routed to a tier.
+const defaultFallbackTier = "default"
+
+// fallbackSource identifies the tier and primary provider a request fell back
+// from.
+type fallbackSource struct {
+	tier     string
+	provider string
+}
+
+// FallbackSourceStats counts the fallbacks from one tier and provider.
+type FallbackSourceStats struct {
+	Attempts  int64 `json:"attempts"`
+	Successes int64 `json:"successes"`
+}
+
+// fallbackStats counts fallbacks by source. The zero value is ready to use.
+type fallbackStats struct {
+	mu       sync.Mutex
+	bySource map[fallbackSource]*FallbackSourceStats
+}
+
+// record counts a fallback attempt from src, and whether it succeeded.
+func (fs *fallbackStats) record(src fallbackSource, success bool) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if fs.bySource == nil {
+		fs.bySource = make(map[fallbackSource]*FallbackSourceStats)
+	}
+	stats, ok := fs.bySource[src]
+	if !ok {
+		stats = &FallbackSourceStats{}
+		fs.bySource[src] = stats
+	}
+	stats.Attempts++
+	if success {
+		stats.Successes++
+	}
+}
+
+// snapshot returns a copy of the counts, sorted by tier then provider.
+func (fs *fallbackStats) snapshot() ([]fallbackSource, map[fallbackSource]FallbackSourceStats) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	sources := make([]fallbackSource, 0, len(fs.bySource))
+	counts := make(map[fallbackSource]FallbackSourceStats, len(fs.bySource))
+	for src, stats := range fs.bySource {
+		sources = append(sources, src)
+		counts[src] = *stats
+	}
+	sort.Slice(sources, func(i, j int) bool {
+		if sources[i].tier != sources[j].tier {
+			return sources[i].tier < sources[j].tier
+		}
+		return sources[i].provider < sources[j].provider
+	})
+	return sources, counts
+}
+
+// reset clears the counts.
+func (fs *fallbackStats) reset() {
+	fs.mu.Lock()
+	fs.bySource = nil
+	fs.mu.Unlock()
+}
+
+// fallbackTier returns the tier label for fallbacks of requests for the given
+// model: the model's tier when multi-provider routing configures it, or
+// "default".
+func (h *Handler) fallbackTier(requestModel string) string {
+	if h.cfg.GetTierConfig(requestModel) == nil {
+		return defaultFallbackTier
+	}
+	return string(config.GetModelTier(requestModel))
+}
+
+// fallbackBreakdown returns the /metrics fallback counts keyed by tier, then
+// by primary provider.
+func (h *Handler) fallbackBreakdown() map[string]map[string]FallbackSourceStats {
+	sources, counts := h.metrics.fallbacks.snapshot()
+	byTier := make(map[string]map[string]FallbackSourceStats)
+	for _, src := range sources {
+		if byTier[src.tier] == nil {
+			byTier[src.tier] = make(map[string]FallbackSourceStats)
+		}
+		byTier[src.tier][src.provider] = counts[src]
+	}
+	return byTier
+}
+
+// writeFallbackPrometheus writes the fallback counters, labelled by the tier
+// and primary provider the requests fell back from.
+func (h *Handler) writeFallbackPrometheus(w io.Writer) {
+	sources, counts := h.metrics.fallbacks.snapshot()
+
+	fmt.Fprintf(w, "# HELP clasp_fallback_attempts Total fallback attempts\n")
+	fmt.Fprintf(w, "# TYPE clasp_fallback_attempts counter\n")
+	for _, src := range sources {
+		fmt.Fprintf(w, "clasp_fallback_attempts{provider=\"%s\",tier=\"%s\"} %d\n", src.provider, src.tier, counts[src].Attempts)
+	}
+
+	fmt.Fprintf(w, "# HELP clasp_fallback_successes Total successful fallback attempts\n")
+	fmt.Fprintf(w, "# TYPE clasp_fallback_successes counter\n")
+	for _, src := range sources {
+		fmt.Fprintf(w, "clasp_fallback_successes{provider=\"%s\",tier=\"%s\"} %d\n", src.provider, src.tier, counts[src].Successes)
+	}
+}
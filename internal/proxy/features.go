@@ -0,0 +1,155 @@
+// Package proxy implements the HTTP proxy server.
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/jedarden/clasp/internal/cache"
+)
+
+// Features that can be switched off and on at runtime with /admin/features.
+const (
+	featureCache       = "cache"
+	featurePromptCache = "prompt_cache"
+	featureRateLimit   = "rate_limit"
+	featureFallback    = "fallback"
+)
+
+// featureNames lists the toggleable features in the order they are reported.
+var featureNames = []string{featureCache, featurePromptCache, featureRateLimit, featureFallback}
+
+// featureSwitches records features switched off at runtime. It is shared by
+// every handler of a server, so toggles survive config reloads, but is never
+// persisted: a restart brings every configured feature back.
+type featureSwitches struct {
+	mu       sync.RWMutex
+	disabled map[string]bool
+}
+
+func newFeatureSwitches() *featureSwitches {
+	return &featureSwitches{disabled: make(map[string]bool)}
+}
+
+// featureEnabled reports whether a feature has not been switched off. It
+// says nothing about whether the feature is configured.
+func (h *Handler) featureEnabled(name string) bool {
+	h.features.mu.RLock()
+	defer h.features.mu.RUnlock()
+	return !h.features.disabled[name]
+}
+
+// featureConfigured reports whether the component behind a feature exists.
+func (h *Handler) featureConfigured(name string) bool {
+	switch name {
+	case featureCache:
+		return h.cache != nil
+	case featurePromptCache:
+		return h.promptCache != nil
+	case featureRateLimit:
+		return h.rateLimiter != nil
+	case featureFallback:
+		return h.hasFallback()
+	}
+	return false
+}
+
+// activeCache returns the response cache, or nil if it is not configured or
+// has been switched off.
+func (h *Handler) activeCache() *RequestCache {
+	if h.cache == nil || !h.featureEnabled(featureCache) {
+		return nil
+	}
+	return h.cache
+}
+
+// activePromptCache returns the prompt cache, or nil if it is not configured
+// or has been switched off.
+func (h *Handler) activePromptCache() *cache.PromptCache {
+	if h.promptCache == nil || !h.featureEnabled(featurePromptCache) {
+		return nil
+	}
+	return h.promptCache
+}
+
+// FeatureStates reports whether each toggleable feature is currently active,
+// i.e. configured and not switched off.
+func (h *Handler) FeatureStates() map[string]bool {
+	states := make(map[string]bool, len(featureNames))
+	for _, name := range featureNames {
+		states[name] = h.featureConfigured(name) && h.featureEnabled(name)
+	}
+	return states
+}
+
+// SetFeatures switches features on or off. Features are validated first, so
+// either every change is applied or none is. Only configured features can be
+// switched on.
+func (h *Handler) SetFeatures(changes map[string]bool) error {
+	names := make([]string, 0, len(changes))
+	for name := range changes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		known := false
+		for _, feature := range featureNames {
+			known = known || feature == name
+		}
+		if !known {
+			return fmt.Errorf("unknown feature %q", name)
+		}
+		if changes[name] && !h.featureConfigured(name) {
+			return fmt.Errorf("feature %q is not configured", name)
+		}
+	}
+
+	h.features.mu.Lock()
+	defer h.features.mu.Unlock()
+	for name, enabled := range changes {
+		if enabled {
+			delete(h.features.disabled, name)
+		} else {
+			h.features.disabled[name] = true
+		}
+	}
+	return nil
+}
+
+// HandleAdminFeatures handles /admin/features. GET reports which features are
+// active; POST takes a JSON object such as {"cache":false} and switches the
+// named features off or on until the next restart. It is only served with
+// authentication enabled.
+func (h *Handler) HandleAdminFeatures(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAuth(w, "/admin/features") {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+
+	case http.MethodPost:
+		var changes map[string]bool
+		if err := json.NewDecoder(r.Body).Decode(&changes); err != nil {
+			h.writeErrorResponse(w, http.StatusBadRequest, "invalid_request_error", fmt.Sprintf("Invalid request body - expected an object of feature names to booleans: %v", err))
+			return
+		}
+		if err := h.SetFeatures(changes); err != nil {
+			h.writeErrorResponse(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+			return
+		}
+
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		h.writeErrorResponse(w, http.StatusMethodNotAllowed, "invalid_request_error", "Method not allowed")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"features": h.FeatureStates(),
+	})
+}
@@ -0,0 +1,244 @@
+// Package proxy implements the HTTP proxy server.
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/jedarden/clasp/internal/logging"
+	"github.com/jedarden/clasp/internal/provider"
+	"github.com/jedarden/clasp/internal/secrets"
+	"github.com/jedarden/clasp/pkg/models"
+)
+
+// handleGeminiRequest sends a request to the Gemini provider's native
+// generateContent API and translates the response back to Anthropic format.
+// If Gemini fails and a fallback provider is configured, the request is
+// retried there through the usual OpenAI translation.
+func (h *Handler) handleGeminiRequest(w http.ResponseWriter, r *http.Request, anthropicReq *models.AnthropicRequest, p *provider.GeminiProvider, targetModel string, start time.Time, cacheKey string, cacheable bool) {
+	ctx := r.Context()
+	cb := circuitBreakerFromContext(ctx)
+
+	reqBody, err := h.transformGeminiRequest(ctx, anthropicReq, targetModel)
+	if err != nil {
+		atomic.AddInt64(&h.metrics.ErrorRequests, 1)
+		h.writeErrorResponse(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+
+	resp, err := h.doRequestWithRetryTo(ctx, reqBody, p, func() string {
+		return p.GetNativeEndpointURL(targetModel, anthropicReq.Stream)
+	})
+
+	// A fallback provider answers in Chat Completions or Responses API format
+	if err != nil || resp.StatusCode >= 500 {
+		fallbackResp, fallbackModel, useResponsesAPI, fallbackHop, fallbackErr := h.tryFallback(ctx, anthropicReq, p, resp, targetModel, err)
+		if fallbackHop > 0 {
+			h.handleGeminiFallbackResponse(ctx, w, fallbackResp, anthropicReq, fallbackModel, useResponsesAPI, fallbackHop, start, cacheKey, cacheable)
+			return
+		}
+		resp, err = fallbackResp, fallbackErr
+	}
+	if err != nil {
+		atomic.AddInt64(&h.metrics.ErrorRequests, 1)
+		if errors.Is(err, context.DeadlineExceeded) {
+			log.Printf("[CLASP] Upstream request timed out: %v", err)
+			h.writeErrorResponse(w, http.StatusGatewayTimeout, "timeout_error", "Upstream request timed out")
+			return
+		}
+		if cb != nil {
+			cb.RecordFailure()
+		}
+		log.Printf("[CLASP] Error making Gemini request: %v", err)
+		h.writeErrorResponse(w, http.StatusBadGateway, "api_error", "Error connecting to upstream provider")
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		h.handleUpstreamError(ctx, w, resp, cb, targetModel, p.Name())
+		return
+	}
+
+	if cb != nil {
+		cb.RecordSuccess()
+	}
+	atomic.AddInt64(&h.metrics.SuccessRequests, 1)
+	atomic.AddInt64(&h.metrics.TotalLatencyMs, time.Since(start).Milliseconds())
+
+	if anthropicReq.Stream {
+		h.handleGeminiStreamingResponse(ctx, w, resp, p.Name(), targetModel, cacheKey, cacheable)
+	} else {
+		h.handleGeminiNonStreamingResponse(ctx, w, resp, p.Name(), targetModel, cacheKey, cacheable)
+	}
+}
+
+// handleGeminiFallbackResponse handles the response of the fallback provider
+// after Gemini failed.
+func (h *Handler) handleGeminiFallbackResponse(ctx context.Context, w http.ResponseWriter, resp *http.Response, req *models.AnthropicRequest, targetModel string, useResponsesAPI bool, fallbackHop int, start time.Time, cacheKey string, cacheable bool) {
+	defer resp.Body.Close()
+	if cb := circuitBreakerFromContext(ctx); cb != nil {
+		cb.RecordFailure()
+	}
+	if resp.StatusCode >= 400 {
+		h.handleUpstreamError(ctx, w, resp, nil, targetModel, "")
+		return
+	}
+
+	atomic.AddInt64(&h.metrics.SuccessRequests, 1)
+	atomic.AddInt64(&h.metrics.TotalLatencyMs, time.Since(start).Milliseconds())
+	w.Header().Set("X-CLASP-Fallback", fallbackHeader(fallbackHop))
+	if useResponsesAPI {
+		w.Header().Set("X-CLASP-Responses-API", "true")
+	}
+	h.handleResponse(ctx, w, resp, req.Stream, useResponsesAPI, targetModel, cacheKey, cacheable, "", len(req.Messages))
+}
+
+// transformGeminiRequest transforms an Anthropic request to a Gemini
+// generateContent request body.
+func (h *Handler) transformGeminiRequest(ctx context.Context, req *models.AnthropicRequest, targetModel string) ([]byte, error) {
+	geminiReq, err := h.translatorOpts.TransformRequestToGemini(req, targetModel)
+	if err != nil {
+		log.Printf("[CLASP] Error transforming request to Gemini: %v", err)
+		return nil, err
+	}
+
+	reqBody, err := json.Marshal(geminiReq)
+	if err != nil {
+		log.Printf("[CLASP] Error marshaling Gemini request: %v", err)
+		return nil, err
+	}
+
+	if h.debugRequests(ctx) {
+		debugJSON, _ := json.MarshalIndent(geminiReq, "", "  ")
+		maskedJSON := secrets.MaskJSONSecrets(debugJSON)
+		if h.cfg.DebugRequests {
+			log.Printf("[CLASP DEBUG] Outgoing Gemini generateContent request:\n%s", string(maskedJSON))
+		}
+		logging.LogDebugRequestRaw("OUTGOING", "/models/"+targetModel+":generateContent", maskedJSON)
+	}
+
+	return reqBody, nil
+}
+
+// handleGeminiStreamingResponse translates a Gemini SSE stream.
+func (h *Handler) handleGeminiStreamingResponse(ctx context.Context, w http.ResponseWriter, resp *http.Response, providerName, targetModel, cacheKey string, cacheable bool) {
+	storeInCache := cacheable && cacheKey != ""
+
+	// Set SSE headers
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	if storeInCache {
+		w.Header().Set("X-CLASP-Cache", "MISS")
+	}
+
+	// Flush headers
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+
+	fw := &flushWriter{w: w}
+	if f, ok := w.(http.Flusher); ok {
+		fw.flusher = f
+	}
+
+	// Keep idle connections alive during long gaps between tokens
+	out, stopHeartbeat := h.withHeartbeat(fw)
+	defer stopHeartbeat()
+
+	processor := h.translatorOpts.NewGeminiStreamProcessor(out, generateMessageID(), targetModel)
+	if h.costTracker != nil {
+		processor.SetUsageDetailsCallback(func(usage *models.AnthropicUsage) {
+			h.costTracker.RecordAnthropicUsage(providerName, targetModel, usage)
+			auditUsage(ctx, usage.InputTokens, usage.OutputTokens)
+			log.Printf("[CLASP] Streaming cost tracked: %d input tokens, %d output tokens%s", usage.InputTokens, usage.OutputTokens, cacheUsageLog(usage))
+		})
+	}
+
+	if h.debugResponses(ctx) || storeInCache {
+		processor.EnableResponseCapture()
+	}
+
+	clientAborted := h.watchClientDisconnect(ctx, resp)
+	err := processor.ProcessStream(resp.Body)
+	if clientAborted() {
+		if err != nil {
+			h.recordAbortedStreamUsage(ctx, targetModel, processor)
+		}
+		return
+	}
+	if err != nil {
+		log.Printf("[CLASP] Error processing Gemini stream: %v", err)
+		if termErr := processor.Terminate(); termErr != nil {
+			log.Printf("[CLASP] Error terminating stream: %v", termErr)
+		}
+	}
+
+	h.logStreamedResponse(ctx, processor.CapturedResponse())
+	if storeInCache && err == nil {
+		h.storeStreamedResponse(ctx, cacheKey, processor)
+	}
+}
+
+// handleGeminiNonStreamingResponse translates a Gemini generateContent response.
+func (h *Handler) handleGeminiNonStreamingResponse(ctx context.Context, w http.ResponseWriter, resp *http.Response, providerName, targetModel, cacheKey string, cacheable bool) {
+	body, err := h.readUpstreamBody(resp)
+	if err != nil {
+		log.Printf("[CLASP] Error reading response: %v", err)
+		h.writeErrorResponse(w, http.StatusBadGateway, "api_error", "Error reading upstream response")
+		return
+	}
+
+	// Debug logging for raw response (secrets are masked)
+	if h.debugResponses(ctx) {
+		maskedBody := secrets.MaskJSONSecrets(body)
+		if h.cfg.DebugResponses {
+			log.Printf("[CLASP DEBUG] Raw Gemini response:\n%s", string(maskedBody))
+		}
+		logging.LogDebugRequestRaw("RESPONSE", "/models/"+targetModel+":generateContent (raw)", maskedBody)
+	}
+
+	var geminiResp models.GeminiResponse
+	if err := json.Unmarshal(body, &geminiResp); err != nil {
+		log.Printf("[CLASP] Error parsing Gemini response: %v", err)
+		h.writeErrorResponse(w, http.StatusBadGateway, "api_error", "Error parsing upstream response")
+		return
+	}
+
+	anthropicResp := h.translatorOpts.TransformGeminiResponse(&geminiResp, generateMessageID(), targetModel)
+
+	// Debug logging for Anthropic response (secrets are masked)
+	if h.debugResponses(ctx) {
+		debugJSON, _ := json.MarshalIndent(anthropicResp, "", "  ")
+		maskedJSON := secrets.MaskJSONSecrets(debugJSON)
+		if h.cfg.DebugResponses {
+			log.Printf("[CLASP DEBUG] Transformed Anthropic response:\n%s", string(maskedJSON))
+		}
+		logging.LogDebugRequestRaw("RESPONSE", "/v1/messages (transformed)", maskedJSON)
+	}
+
+	// Track costs
+	if h.costTracker != nil {
+		h.costTracker.RecordAnthropicUsage(providerName, targetModel, anthropicResp.Usage)
+	}
+	auditUsage(ctx, anthropicResp.Usage.InputTokens, anthropicResp.Usage.OutputTokens)
+
+	// Store in cache if cacheable
+	if requestCache := h.activeCache(); requestCache != nil && cacheable && cacheKey != "" {
+		requestCache.Set(cacheKey, anthropicResp)
+		log.Printf("[CLASP] Response cached (key: %s...)", truncate(cacheKey, 16))
+		h.tryStorePromptCache(cacheKey, anthropicResp)
+		h.storeSemanticCache(ctx, anthropicResp)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-CLASP-Cache", "MISS")
+	_ = json.NewEncoder(w).Encode(anthropicResp)
+}
@@ -7,10 +7,11 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
-	"net"
+	"math/big"
 	"net/http"
 	"strings"
 	"sync"
@@ -34,18 +35,34 @@ type Handler struct {
 	provider         provider.Provider
 	fallbackProvider provider.Provider
 	client           *http.Client
+	untimedClient    *http.Client // No global timeout; used with X-CLASP-Timeout deadlines
 	metrics          *Metrics
 	rateLimiter      *RateLimiter
 	cache            *RequestCache
 	promptCache      *cache.PromptCache
 	promptCachePending sync.Map // map[string]promptCacheCtx — per-request prompt cache context
 	queue            *RequestQueue
-	circuitBreaker   *CircuitBreaker
+	circuitBreakers  *CircuitBreakers
 	costTracker      *CostTracker
 	healthChecker    *HealthChecker
 	tierProviders    map[config.ModelTier]provider.Provider
 	tierFallbacks    map[config.ModelTier]provider.Provider
+	fallbackChain    []fallbackHop // CLASP_FALLBACK_CHAIN hops, tried after the fallback above
 	sessionTracker   *session.Tracker
+	stickyRouter     *StickyRouter
+	concurrency      *ConcurrencyLimiter
+	endpointSelectors map[provider.Provider]*EndpointSelector // Multi-region routing per provider
+	tierExtraHeaders  map[provider.Provider]map[string]string // CLASP_<TIER>_EXTRA_HEADERS per tier provider
+	readiness        *readinessState
+	healthUpstream   *upstreamCheck
+	auditLog         *AuditLogger
+	retryBudget      *RetryBudget
+	semanticCache    *SemanticCache
+	negativeCache    *NegativeCache
+	coalescer        *requestCoalescer
+	features         *featureSwitches
+	requestTransformers []RequestTransformer // Hook chain from ~/.clasp/hooks.json
+	translatorOpts   *translator.Options // Translation settings passed with every request
 	version          string
 }
 
@@ -61,7 +78,17 @@ type Metrics struct {
 	FallbackSuccesses  int64
 	CompactionHits     int64 // Responses API requests using previous_response_id
 	CompactionMisses   int64 // Responses API requests without a stored session
+	CompactionResets   int64 // Sessions resent in full after the upstream rejected previous_response_id
+	StreamClientAborts int64 // Streams cancelled because the client disconnected
+	SlowFallbackRaces  int64 // Fallback requests raced against a slow primary
+	SlowFallbackWins   int64 // Races the fallback won
+	CoalescedRequests  int64 // Requests answered with the response of an identical in-flight request
+	ThinkingTokens     int64 // Estimated thinking tokens in passthrough streams
 	StartTime          time.Time
+
+	fallbacks fallbackStats // Fallbacks by tier and primary provider
+
+	startMu sync.RWMutex // Guards StartTime once the handler is serving
 }
 
 // isReasoningModel checks if the model is a reasoning/codex model that may require extended timeouts.
@@ -94,16 +121,9 @@ func NewHandler(cfg *config.Config) (*Handler, error) {
 	}
 
 	// Create optimized HTTP transport with connection pooling
-	transport := &http.Transport{
-		DialContext: (&net.Dialer{
-			Timeout:   30 * time.Second,
-			KeepAlive: 30 * time.Second,
-		}).DialContext,
-		MaxIdleConns:        100,
-		MaxIdleConnsPerHost: 100,
-		IdleConnTimeout:     90 * time.Second,
-		TLSHandshakeTimeout: 10 * time.Second,
-		DisableCompression:  false,
+	transport := newUpstreamTransport(cfg.UpstreamHTTPVersion)
+	if cfg.UpstreamHTTPVersion == "h1" || cfg.UpstreamHTTPVersion == "h2" {
+		log.Printf("[CLASP] Upstream HTTP version: %s", cfg.UpstreamHTTPVersion)
 	}
 
 	// Use configurable timeout (default 5 minutes for reasoning models)
@@ -118,25 +138,35 @@ func NewHandler(cfg *config.Config) (*Handler, error) {
 	}
 
 	handler := &Handler{
-		cfg:           cfg,
-		provider:      p,
-		client:        client,
-		metrics:       &Metrics{StartTime: time.Now()},
-		costTracker:   NewCostTracker(),
-		tierProviders: make(map[config.ModelTier]provider.Provider),
-		tierFallbacks: make(map[config.ModelTier]provider.Provider),
-	}
+		cfg:            cfg,
+		provider:       p,
+		client:         client,
+		untimedClient:  &http.Client{Transport: transport},
+		metrics:        &Metrics{StartTime: time.Now()},
+		costTracker:    NewCostTracker(),
+		readiness:      &readinessState{},
+		healthUpstream: &upstreamCheck{},
+		coalescer:      newRequestCoalescer(),
+		features:       newFeatureSwitches(),
+		tierProviders:  make(map[config.ModelTier]provider.Provider),
+		tierFallbacks:  make(map[config.ModelTier]provider.Provider),
+	}
+	handler.addEndpointSelector(p, cfg.Provider)
 
 	// Initialize global fallback provider if configured
 	if cfg.HasGlobalFallback() {
 		if fallbackCfg := cfg.GetGlobalFallbackConfig(); fallbackCfg != nil {
 			if fallbackProvider, err := createTierProvider(fallbackCfg); err == nil {
 				handler.fallbackProvider = fallbackProvider
+				handler.addEndpointSelector(fallbackProvider, cfg.FallbackProvider)
 				log.Printf("[CLASP] Global fallback: %s (%s)", cfg.FallbackProvider, cfg.FallbackModel)
 			}
 		}
 	}
 
+	// Initialize the ordered fallback chain if configured
+	handler.initializeFallbackChain()
+
 	// Initialize tier-specific providers if multi-provider routing is enabled
 	if cfg.MultiProviderEnabled {
 		handler.initializeTier(config.TierOpus, cfg.TierOpus)
@@ -144,6 +174,72 @@ func NewHandler(cfg *config.Config) (*Handler, error) {
 		handler.initializeTier(config.TierHaiku, cfg.TierHaiku)
 	}
 
+	// Bound simultaneous in-flight requests
+	if cfg.MaxConcurrent > 0 {
+		handler.concurrency = NewConcurrencyLimiter(cfg.MaxConcurrent)
+		log.Printf("[CLASP] Concurrency limit: %d in-flight requests", cfg.MaxConcurrent)
+	}
+
+	// Pin conversations to the provider that first served them
+	if cfg.StickyRoutingEnabled {
+		handler.stickyRouter = NewStickyRouter(cfg.StickyRoutingMaxEntries, time.Duration(cfg.StickyRoutingTTLSec)*time.Second)
+		log.Printf("[CLASP] Sticky routing enabled (header: %s, TTL: %ds)", cfg.StickyRoutingHeader, cfg.StickyRoutingTTLSec)
+	}
+
+	// Translation settings, passed to the translator with every request
+	opts := translator.DefaultOptions()
+	opts.DocumentFallback = translator.DocumentFallback(cfg.DocumentFallback)
+	opts.LogitBias = cfg.LogitBias
+	opts.Seed = cfg.Seed
+	opts.PresencePenalty = cfg.PresencePenalty
+	opts.FrequencyPenalty = cfg.FrequencyPenalty
+	opts.CustomTopK = cfg.CustomTopK
+	opts.CustomParallelToolCalls = cfg.CustomParallelToolCalls
+	opts.ForceJSON = cfg.ForceJSON
+	opts.ParallelToolCalls = cfg.ParallelToolCalls
+	opts.RepairToolJSON = cfg.RepairToolJSON
+	opts.ResponsesStore = cfg.ResponsesStore
+	opts.ResponsesReasoningSummary = cfg.ResponsesReasoningSummary
+	opts.DisableMaxTokensCap = cfg.DisableMaxTokensCap
+	opts.SetMaxTokensLimits(cfg.MaxTokensLimits)
+	if err := opts.SetFinishReasonOverrides(cfg.FinishReasonMap); err != nil {
+		return nil, fmt.Errorf("invalid CLASP_FINISH_REASON_MAP: %w", err)
+	}
+
+	// Configure identity filtering with optional custom rules
+	identityFile := cfg.IdentityFile
+	if identityFile == "" {
+		identityFile, _ = translator.DefaultIdentityRulesPath()
+	}
+	var identityRules *translator.IdentityRules
+	if identityFile != "" {
+		rules, err := translator.LoadIdentityRules(identityFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading identity rules: %w", err)
+		}
+		identityRules = rules
+	}
+	if err := opts.SetIdentityFilter(translator.IdentityFilter(cfg.IdentityFilter), identityRules); err != nil {
+		return nil, fmt.Errorf("invalid identity rules in %s: %w", identityFile, err)
+	}
+	handler.translatorOpts = opts
+
+	// Load the request hook chain
+	hooksFile := cfg.HooksFile
+	if hooksFile == "" {
+		hooksFile, _ = DefaultHooksPath()
+	}
+	if hooksFile != "" {
+		chain, err := LoadHooks(hooksFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading request hooks: %w", err)
+		}
+		handler.requestTransformers = chain
+		if len(chain) > 0 {
+			log.Printf("[CLASP] Loaded %d request hooks from %s", len(chain), hooksFile)
+		}
+	}
+
 	// Check if default model is a reasoning/codex model with insufficient timeout
 	if cfg.DefaultModel != "" && isReasoningModel(cfg.DefaultModel) && cfg.HTTPClientTimeoutSec < 600 {
 		log.Printf("[CLASP WARNING] Model %s is a reasoning/codex model that may require extended timeouts.", cfg.DefaultModel)
@@ -163,6 +259,8 @@ func (h *Handler) initializeTier(tier config.ModelTier, tierCfg *config.TierConf
 	// Initialize main tier provider
 	if tierProvider, err := createTierProvider(tierCfg); err == nil {
 		h.tierProviders[tier] = tierProvider
+		h.addEndpointSelector(tierProvider, tierCfg.Provider)
+		h.addTierExtraHeaders(tierProvider, tierCfg.ExtraHeaders)
 		log.Printf("[CLASP] Multi-provider: %s -> %s (%s)", tier, tierCfg.Provider, tierCfg.Model)
 	}
 
@@ -171,12 +269,33 @@ func (h *Handler) initializeTier(tier config.ModelTier, tierCfg *config.TierConf
 		if fb := tierCfg.GetFallbackConfig(); fb != nil {
 			if fbProvider, err := createTierProvider(fb); err == nil {
 				h.tierFallbacks[tier] = fbProvider
+				h.addEndpointSelector(fbProvider, fb.Provider)
+				h.addTierExtraHeaders(fbProvider, tierCfg.ExtraHeaders)
 				log.Printf("[CLASP] Fallback: %s -> %s (%s)", tier, fb.Provider, fb.Model)
 			}
 		}
 	}
 }
 
+// addEndpointSelector enables multi-region routing for a provider when
+// CLASP_<PROVIDER>_ENDPOINTS is configured for its type.
+func (h *Handler) addEndpointSelector(p provider.Provider, providerType config.ProviderType) {
+	endpoints := h.cfg.ProviderEndpoints[providerType]
+	if len(endpoints) == 0 {
+		return
+	}
+	baseURL := providerBaseURL(p)
+	if baseURL == "" {
+		log.Printf("[CLASP WARNING] Ignoring endpoints for %s: provider has no base URL", providerType)
+		return
+	}
+	if h.endpointSelectors == nil {
+		h.endpointSelectors = make(map[provider.Provider]*EndpointSelector)
+	}
+	h.endpointSelectors[p] = NewEndpointSelector(baseURL, endpoints)
+	log.Printf("[CLASP] Multi-region routing for %s across %d endpoints", providerType, len(endpoints))
+}
+
 // SetRateLimiter sets the rate limiter for metrics reporting.
 func (h *Handler) SetRateLimiter(rl *RateLimiter) {
 	h.rateLimiter = rl
@@ -197,9 +316,9 @@ func (h *Handler) SetQueue(queue *RequestQueue) {
 	h.queue = queue
 }
 
-// SetCircuitBreaker sets the circuit breaker.
-func (h *Handler) SetCircuitBreaker(cb *CircuitBreaker) {
-	h.circuitBreaker = cb
+// SetCircuitBreakers sets the per-provider circuit breakers.
+func (h *Handler) SetCircuitBreakers(cbs *CircuitBreakers) {
+	h.circuitBreakers = cbs
 }
 
 // SetHealthChecker sets the health checker.
@@ -241,7 +360,7 @@ func createProvider(cfg *config.Config) (provider.Provider, error) {
 	case config.ProviderOllama:
 		return provider.NewOllamaProvider(cfg.OllamaBaseURL), nil
 	case config.ProviderGemini:
-		return provider.NewGeminiProvider(cfg.GeminiAPIKey), nil
+		return provider.NewGeminiProviderWithURL(cfg.GeminiBaseURL, cfg.GeminiAPIKey), nil
 	case config.ProviderDeepSeek:
 		return provider.NewDeepSeekProvider(cfg.DeepSeekAPIKey), nil
 	case config.ProviderGrok:
@@ -334,14 +453,74 @@ func (h *Handler) HandleMessages(w http.ResponseWriter, r *http.Request) {
 	start := time.Now()
 	atomic.AddInt64(&h.metrics.TotalRequests, 1)
 
+	if h.auditLog != nil {
+		var finishAudit func()
+		w, r, finishAudit = h.startAudit(w, r, start)
+		defer finishAudit()
+	}
+
+	// While queue workers are running, requests wait their turn by priority
+	if h.queue != nil && h.queue.Running() {
+		h.enqueueMessages(w, r, start)
+		return
+	}
+	h.serveMessages(w, r, start)
+}
+
+// serveMessages serves a /v1/messages request that isn't waiting in the queue.
+func (h *Handler) serveMessages(w http.ResponseWriter, r *http.Request, start time.Time) {
+	// Bound in-flight requests; with queuing enabled, wait for a free slot instead of failing fast
+	if h.concurrency != nil {
+		var maxWait time.Duration
+		if h.cfg.QueueEnabled {
+			maxWait = time.Duration(h.cfg.QueueMaxWaitSeconds) * time.Second
+		}
+		if !h.concurrency.Acquire(r.Context(), maxWait) {
+			atomic.AddInt64(&h.metrics.ErrorRequests, 1)
+			log.Printf("[CLASP] Concurrency limit (%d) reached - rejecting request", h.concurrency.Limit())
+			h.writeErrorResponse(w, http.StatusServiceUnavailable, "overloaded_error", "Too many concurrent requests - try again later")
+			return
+		}
+		defer h.concurrency.Release()
+	}
+
+	r = h.withClientKey(r)
+	r = h.withDebugSample(r)
+	r, cancelTimeout := h.withRequestTimeout(r)
+	defer cancelTimeout()
+
+	r, versionErr := h.withAnthropicVersion(r)
+	if versionErr != nil {
+		atomic.AddInt64(&h.metrics.ErrorRequests, 1)
+		h.writeErrorResponse(w, versionErr.statusCode, versionErr.errType, versionErr.message)
+		return
+	}
+	r = withAnthropicBeta(r)
+
 	// Parse and validate request
-	anthropicReq, reqErr := h.parseAndValidateRequest(r)
+	anthropicReq, reqErr := h.parseAndValidateRequest(w, r)
 	if reqErr != nil {
 		atomic.AddInt64(&h.metrics.ErrorRequests, 1)
 		h.writeErrorResponse(w, reqErr.statusCode, reqErr.errType, reqErr.message)
 		return
 	}
 	defer r.Body.Close()
+	anthropicReq.Betas = anthropicBetasFromContext(r.Context())
+	h.auditRequest(r.Context(), anthropicReq)
+
+	// A request made with the client's own provider key skips the shared caches
+	// and coalescing, so it is never answered with a response another client
+	// paid for, and its responses and errors are never served to others
+	if clientKeyFromContext(r.Context()) != "" {
+		h.forwardMessages(w, r, anthropicReq, start, "", false)
+		return
+	}
+
+	// Fail fast on requests the upstream recently rejected
+	var negativeHit bool
+	if r, negativeHit = h.checkNegativeCache(w, r, anthropicReq); negativeHit {
+		return
+	}
 
 	// Check prompt cache first (prefix-based matching for cache_control-marked requests)
 	promptKey, promptCacheable, _ := h.checkPromptCache(w, anthropicReq)
@@ -349,21 +528,43 @@ func (h *Handler) HandleMessages(w http.ResponseWriter, r *http.Request) {
 		return // Response already sent from prompt cache
 	}
 
-	// Check cache for non-streaming requests
+	// Check cache (streaming requests only with CLASP_CACHE_STREAMING)
 	cacheKey, cacheable := h.checkCache(w, anthropicReq)
 	if cacheKey == "HIT" {
 		return // Response already sent from cache
 	}
+	if cacheable && cacheKey != "" {
+		var semanticHit bool
+		r, semanticHit = h.checkSemanticCache(w, r, anthropicReq)
+		if semanticHit {
+			return // Response already sent from the semantic cache
+		}
+	}
 
 	// Store prompt cache context for later use when storing the response
-	if h.promptCache != nil && cacheKey != "" && cacheable && promptCacheable {
+	if h.activePromptCache() != nil && cacheKey != "" && cacheable && promptCacheable {
 		if pk, pt, pok := cache.GeneratePromptCacheKey(anthropicReq); pok {
 			h.promptCachePending.Store(cacheKey, promptCacheCtx{key: pk, tokens: pt})
 		}
 	}
 
+	// Identical cacheable requests already in flight share one upstream call
+	if cacheable && cacheKey != "" && !anthropicReq.Stream {
+		h.coalesce(w, r, cacheKey, func(w http.ResponseWriter) {
+			h.forwardMessages(w, r, anthropicReq, start, cacheKey, cacheable)
+		})
+		return
+	}
+	h.forwardMessages(w, r, anthropicReq, start, cacheKey, cacheable)
+}
+
+// forwardMessages routes a /v1/messages request that was not served from a
+// cache to its provider and writes the response.
+func (h *Handler) forwardMessages(w http.ResponseWriter, r *http.Request, anthropicReq *models.AnthropicRequest, start time.Time, cacheKey string, cacheable bool) {
 	// Select provider and resolve target model
 	selectedProvider, targetModel := h.selectProviderAndModel(anthropicReq)
+	auditRoute(r.Context(), selectedProvider.Name(), targetModel, false)
+	r = r.WithContext(h.withUsageEstimate(r.Context(), anthropicReq, selectedProvider))
 
 	// Validate that Azure provider is not being used with Responses API models
 	// Azure OpenAI does not support the Responses API (gpt-5, gpt-5.1, codex)
@@ -386,22 +587,36 @@ func (h *Handler) HandleMessages(w http.ResponseWriter, r *http.Request) {
 				newMessagesOffset = entry.MessageCount
 				atomic.AddInt64(&h.metrics.CompactionHits, 1)
 				log.Printf("[CLASP] Compaction: continuing session %s..., previous_response_id=%s (offset=%d)",
-					sessionKey[:8], previousResponseID, newMessagesOffset)
+					truncate(sessionKey, 8), previousResponseID, newMessagesOffset)
 			} else {
 				atomic.AddInt64(&h.metrics.CompactionMisses, 1)
 			}
 		}
 	}
 
-	// Check circuit breaker
-	if h.circuitBreaker != nil && !h.circuitBreaker.Allow() {
+	// Sticky routing: later turns of a conversation stay on the provider that served it
+	conversationID, pinnedFallback := h.lookupStickyRoute(r)
+
+	// Check the circuit breaker of the selected provider
+	cb := h.circuitBreakerFor(selectedProvider.Name())
+	if cb != nil && !cb.Allow() {
 		atomic.AddInt64(&h.metrics.ErrorRequests, 1)
-		log.Printf("[CLASP] Circuit breaker open - rejecting request")
-		w.Header().Set("X-CLASP-Circuit-Breaker", "open")
+		log.Printf("[CLASP] Circuit breaker %s for %s - rejecting request", cb.State(), selectedProvider.Name())
+		w.Header().Set("X-CLASP-Circuit-Breaker", cb.State())
 		h.writeErrorResponse(w, http.StatusServiceUnavailable, "overloaded_error", "Service temporarily unavailable - circuit breaker open")
 		return
 	}
 
+	// A slow tier can have a longer upstream timeout than the others
+	r = r.WithContext(h.withTierTimeout(r.Context(), anthropicReq.Model))
+	r = r.WithContext(withCircuitBreaker(r.Context(), cb))
+
+	// Gemini is sent its native generateContent API instead of Chat Completions
+	if geminiProvider, ok := selectedProvider.(*provider.GeminiProvider); ok {
+		h.handleGeminiRequest(w, r, anthropicReq, geminiProvider, targetModel, start, cacheKey, cacheable)
+		return
+	}
+
 	// Check if this provider requires transformation (passthrough mode for Anthropic)
 	if !selectedProvider.RequiresTransformation() {
 		h.handlePassthroughRequest(w, r, anthropicReq, selectedProvider, start, cacheKey, cacheable)
@@ -409,11 +624,22 @@ func (h *Handler) HandleMessages(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Transform and execute request
-	resp, targetModel, useResponsesAPI, usedFallback, execErr := h.transformAndExecute(r.Context(), anthropicReq, selectedProvider, targetModel, previousResponseID, newMessagesOffset)
+	resp, targetModel, useResponsesAPI, fallbackHop, execErr := h.executeWithContinuity(r.Context(), anthropicReq, selectedProvider, targetModel, sessionKey, previousResponseID, newMessagesOffset, pinnedFallback)
 	if execErr != nil {
 		atomic.AddInt64(&h.metrics.ErrorRequests, 1)
-		if h.circuitBreaker != nil {
-			h.circuitBreaker.RecordFailure()
+		// Content the target provider cannot accept is a client error, not an upstream failure
+		var contentErr *translator.UnsupportedContentError
+		if errors.As(execErr, &contentErr) {
+			h.writeErrorResponse(w, http.StatusBadRequest, "invalid_request_error", contentErr.Error())
+			return
+		}
+		if errors.Is(execErr, context.DeadlineExceeded) {
+			log.Printf("[CLASP] Upstream request timed out: %v", execErr)
+			h.writeErrorResponse(w, http.StatusGatewayTimeout, "timeout_error", "Upstream request timed out")
+			return
+		}
+		if cb != nil {
+			cb.RecordFailure()
 		}
 		log.Printf("[CLASP] Error making upstream request: %v", execErr)
 		h.writeErrorResponse(w, http.StatusBadGateway, "api_error", "Error connecting to upstream provider")
@@ -423,27 +649,32 @@ func (h *Handler) HandleMessages(w http.ResponseWriter, r *http.Request) {
 
 	// Handle upstream errors
 	if resp.StatusCode >= 400 {
-		h.handleUpstreamError(w, resp)
+		providerName := selectedProvider.Name()
+		if fallbackHop > 0 {
+			providerName = "" // The fallback's provider isn't known here
+		}
+		h.handleUpstreamError(r.Context(), w, resp, cb, targetModel, providerName)
 		return
 	}
 
 	// Record success
-	if h.circuitBreaker != nil {
-		h.circuitBreaker.RecordSuccess()
+	if cb != nil {
+		cb.RecordSuccess()
 	}
 	atomic.AddInt64(&h.metrics.SuccessRequests, 1)
 	atomic.AddInt64(&h.metrics.TotalLatencyMs, time.Since(start).Milliseconds())
+	h.pinStickyRoute(conversationID, fallbackHop > 0)
 
 	// Set response headers
-	if usedFallback {
-		w.Header().Set("X-CLASP-Fallback", "true")
+	if fallbackHop > 0 {
+		w.Header().Set("X-CLASP-Fallback", fallbackHeader(fallbackHop))
 	}
 	if useResponsesAPI {
 		w.Header().Set("X-CLASP-Responses-API", "true")
 	}
 
 	// Handle streaming vs non-streaming response
-	h.handleResponse(w, resp, anthropicReq.Stream, useResponsesAPI, targetModel, cacheKey, cacheable, sessionKey, len(anthropicReq.Messages))
+	h.handleResponse(r.Context(), w, resp, anthropicReq.Stream, useResponsesAPI, targetModel, cacheKey, cacheable, sessionKey, len(anthropicReq.Messages))
 }
 
 // requestError represents a request validation error with HTTP status info.
@@ -454,7 +685,36 @@ type requestError struct {
 }
 
 // parseAndValidateRequest parses and validates an incoming Anthropic request.
-func (h *Handler) parseAndValidateRequest(r *http.Request) (*models.AnthropicRequest, *requestError) {
+func (h *Handler) parseAndValidateRequest(w http.ResponseWriter, r *http.Request) (*models.AnthropicRequest, *requestError) {
+	anthropicReq, reqErr := h.decodeRequest(w, r)
+	if reqErr != nil {
+		return nil, reqErr
+	}
+
+	// Track request types
+	if anthropicReq.Stream {
+		atomic.AddInt64(&h.metrics.StreamRequests, 1)
+	}
+	if len(anthropicReq.Tools) > 0 {
+		atomic.AddInt64(&h.metrics.ToolCallRequests, 1)
+	}
+
+	// Debug logging for incoming request (secrets are masked)
+	if h.debugRequests(r.Context()) {
+		debugJSON, _ := json.MarshalIndent(anthropicReq, "", "  ")
+		maskedJSON := secrets.MaskJSONSecrets(debugJSON)
+		if h.cfg.DebugRequests {
+			log.Printf("[CLASP DEBUG] Incoming Anthropic request:\n%s", string(maskedJSON))
+		}
+		logging.LogDebugRequestRaw("INCOMING", "/v1/messages", maskedJSON)
+	}
+
+	return anthropicReq, nil
+}
+
+// decodeRequest decodes and validates an Anthropic request body and resolves
+// its model alias, without recording metrics.
+func (h *Handler) decodeRequest(w http.ResponseWriter, r *http.Request) (*models.AnthropicRequest, *requestError) {
 	// Only accept POST
 	if r.Method != http.MethodPost {
 		return nil, &requestError{
@@ -464,9 +724,21 @@ func (h *Handler) parseAndValidateRequest(r *http.Request) (*models.AnthropicReq
 		}
 	}
 
-	// Parse request body
+	// Parse request body (bounded so an oversized body cannot exhaust memory)
+	if h.cfg.MaxRequestBytes > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, h.cfg.MaxRequestBytes)
+	}
 	var anthropicReq models.AnthropicRequest
 	if err := json.NewDecoder(r.Body).Decode(&anthropicReq); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			log.Printf("[CLASP] Request body exceeds %d bytes", maxBytesErr.Limit)
+			return nil, &requestError{
+				statusCode: http.StatusRequestEntityTooLarge,
+				errType:    "request_too_large",
+				message:    fmt.Sprintf("Request body exceeds the maximum size of %d bytes", maxBytesErr.Limit),
+			}
+		}
 		log.Printf("[CLASP] Error parsing request: %v", err)
 		return nil, &requestError{
 			statusCode: http.StatusBadRequest,
@@ -480,12 +752,9 @@ func (h *Handler) parseAndValidateRequest(r *http.Request) (*models.AnthropicReq
 		return nil, err
 	}
 
-	// Track request types
-	if anthropicReq.Stream {
-		atomic.AddInt64(&h.metrics.StreamRequests, 1)
-	}
-	if len(anthropicReq.Tools) > 0 {
-		atomic.AddInt64(&h.metrics.ToolCallRequests, 1)
+	// Run request hooks before the model is resolved and a provider selected
+	if err := h.applyRequestTransformers(&anthropicReq); err != nil {
+		return nil, err
 	}
 
 	// Resolve model alias if configured
@@ -495,14 +764,6 @@ func (h *Handler) parseAndValidateRequest(r *http.Request) (*models.AnthropicReq
 		log.Printf("[CLASP] Resolved model alias: %s -> %s", originalModel, anthropicReq.Model)
 	}
 
-	// Debug logging for incoming request (secrets are masked)
-	if h.cfg.DebugRequests {
-		debugJSON, _ := json.MarshalIndent(anthropicReq, "", "  ")
-		maskedJSON := secrets.MaskJSONSecrets(debugJSON)
-		log.Printf("[CLASP DEBUG] Incoming Anthropic request:\n%s", string(maskedJSON))
-		logging.LogDebugRequestRaw("INCOMING", "/v1/messages", maskedJSON)
-	}
-
 	return &anthropicReq, nil
 }
 
@@ -523,6 +784,15 @@ func (h *Handler) validateRequest(req *models.AnthropicRequest) *requestError {
 		}
 	}
 
+	// Anthropic responses carry a single candidate
+	if req.N > 1 && h.cfg.MultiChoice != multiChoiceWarn && h.cfg.MultiChoice != multiChoiceReturn {
+		return &requestError{
+			statusCode: http.StatusBadRequest,
+			errType:    "invalid_request_error",
+			message:    fmt.Sprintf("n=%d is not supported: Anthropic responses contain a single candidate (set CLASP_MULTI_CHOICE=warn or return to allow it)", req.N),
+		}
+	}
+
 	// Check for Azure + Responses API models (gpt-5, gpt-5.1, codex)
 	// Azure OpenAI does not support the Responses API
 	if h.provider.Name() == "azure" && translator.RequiresResponsesAPI(req.Model) {
@@ -539,18 +809,26 @@ func (h *Handler) validateRequest(req *models.AnthropicRequest) *requestError {
 // checkCache checks if the request is in cache and returns cache key/status.
 // Returns "HIT" as cacheKey if response was served from cache.
 func (h *Handler) checkCache(w http.ResponseWriter, req *models.AnthropicRequest) (string, bool) {
-	if h.cache == nil || req.Stream {
+	requestCache := h.activeCache()
+	if requestCache == nil {
 		return "", false
 	}
 
-	cacheKey, cacheable := GenerateCacheKey(req)
+	cacheKey, cacheable := h.cacheKey(req)
+	if req.Stream {
+		cacheKey, cacheable = h.streamCacheKey(req)
+	}
 	if !cacheable {
 		return "", false
 	}
 
-	if cachedResp, found := h.cache.Get(cacheKey); found {
+	if cachedResp, found := requestCache.Get(cacheKey); found {
 		log.Printf("[CLASP] Cache HIT for request")
 		atomic.AddInt64(&h.metrics.SuccessRequests, 1)
+		if req.Stream {
+			h.writeCachedStream(w, cachedResp)
+			return "HIT", true
+		}
 		w.Header().Set("Content-Type", "application/json")
 		w.Header().Set("X-CLASP-Cache", "HIT")
 		_ = json.NewEncoder(w).Encode(cachedResp)
@@ -570,12 +848,14 @@ type promptCacheCtx struct {
 // tryStorePromptCache stores a response in the prompt cache if a pending context exists.
 // The cacheKey parameter is the regular cache key used to look up the prompt cache context.
 func (h *Handler) tryStorePromptCache(cacheKey string, resp *models.AnthropicResponse) {
-	if h.promptCache == nil {
+	promptCache := h.activePromptCache()
+	if promptCache == nil {
+		h.promptCachePending.Delete(cacheKey)
 		return
 	}
 	if val, ok := h.promptCachePending.LoadAndDelete(cacheKey); ok {
 		ctx := val.(promptCacheCtx)
-		h.promptCache.Set(ctx.key, resp, ctx.tokens)
+		promptCache.Set(ctx.key, resp, ctx.tokens)
 		log.Printf("[CLASP] Response stored in prompt cache (prefix ~%d tokens)", ctx.tokens)
 	}
 }
@@ -611,7 +891,14 @@ func (h *Handler) selectProviderAndModel(req *models.AnthropicRequest) (provider
 }
 
 // transformAndExecute transforms the request and executes it against the provider.
-func (h *Handler) transformAndExecute(ctx interface{ Done() <-chan struct{} }, req *models.AnthropicRequest, selectedProvider provider.Provider, targetModel, previousResponseID string, newMessagesOffset int) (*http.Response, string, bool, bool, error) {
+// When pinnedFallback is set, the fallback provider is tried first (sticky routing).
+func (h *Handler) transformAndExecute(ctx context.Context, req *models.AnthropicRequest, selectedProvider provider.Provider, targetModel, previousResponseID string, newMessagesOffset int, pinnedFallback bool) (*http.Response, string, bool, int, error) {
+	if pinnedFallback {
+		if resp, fallbackModel, useResponsesAPI, ok := h.tryPinnedFallback(ctx, req, targetModel); ok {
+			return resp, fallbackModel, useResponsesAPI, 1, nil
+		}
+	}
+
 	endpointType := translator.GetEndpointType(targetModel)
 	useResponsesAPI := endpointType == translator.EndpointResponses
 
@@ -621,28 +908,49 @@ func (h *Handler) transformAndExecute(ctx interface{ Done() <-chan struct{} }, r
 	}
 
 	// Transform request
-	reqBody, err := h.transformRequest(req, targetModel, useResponsesAPI, previousResponseID, newMessagesOffset)
+	reqBody, err := h.transformRequest(ctx, req, selectedProvider, targetModel, useResponsesAPI, previousResponseID, newMessagesOffset)
 	if err != nil {
-		return nil, targetModel, useResponsesAPI, false, err
+		return nil, targetModel, useResponsesAPI, 0, err
 	}
 
-	// Execute request
-	resp, err := h.doRequestWithRetry(ctx, reqBody, selectedProvider)
-	usedFallback := false
+	// Execute request, racing the fallback when the primary is slow
+	var resp *http.Response
+	if h.cfg.FallbackOnSlow {
+		race := h.raceSlowPrimary(ctx, req, reqBody, selectedProvider, targetModel)
+		if race.fromFallback || (race.raced && !race.ok()) {
+			fallbackHop := 0
+			if race.fromFallback && race.ok() {
+				fallbackHop = 1
+			}
+			return race.resp, race.targetModel, race.useResponsesAPI, fallbackHop, race.err
+		}
+		resp, err = race.resp, race.err
+	} else {
+		resp, err = h.doRequestWithRetry(ctx, reqBody, selectedProvider)
+	}
+	fallbackHop := 0
+
+	// Retry the same model on the other endpoint if this one does not serve it
+	if err == nil && h.cfg.EndpointFallback && isEndpointUnsupported(resp) {
+		if openaiProvider, ok := selectedProvider.(*provider.OpenAIProvider); ok {
+			resp, useResponsesAPI = h.tryEndpointFallback(ctx, req, resp, openaiProvider, targetModel, useResponsesAPI)
+		}
+	}
 
 	// Check if we should try fallback
 	if err != nil || (resp != nil && resp.StatusCode >= 500) {
-		resp, targetModel, useResponsesAPI, usedFallback, err = h.tryFallback(ctx, req, resp, targetModel, err)
+		resp, targetModel, useResponsesAPI, fallbackHop, err = h.tryFallback(ctx, req, selectedProvider, resp, targetModel, err)
 	}
 
-	return resp, targetModel, useResponsesAPI, usedFallback, err
+	return resp, targetModel, useResponsesAPI, fallbackHop, err
 }
 
-// transformRequest transforms an Anthropic request to the appropriate format.
+// transformRequest transforms an Anthropic request to the appropriate format
+// for provider p.
 // previousResponseID and newMessagesOffset are used for Responses API compaction:
 // when set, only the messages after newMessagesOffset are sent (the rest are
 // captured by the previous_response_id chain).
-func (h *Handler) transformRequest(req *models.AnthropicRequest, targetModel string, useResponsesAPI bool, previousResponseID string, newMessagesOffset int) ([]byte, error) {
+func (h *Handler) transformRequest(ctx context.Context, req *models.AnthropicRequest, p provider.Provider, targetModel string, useResponsesAPI bool, previousResponseID string, newMessagesOffset int) ([]byte, error) {
 	if useResponsesAPI {
 		// Apply compaction: trim messages to only the new ones when continuing a session.
 		reqToTransform := req
@@ -653,7 +961,7 @@ func (h *Handler) transformRequest(req *models.AnthropicRequest, targetModel str
 				reqToTransform = &trimmed
 			}
 		}
-		responsesReq, err := translator.TransformRequestToResponses(reqToTransform, targetModel, previousResponseID)
+		responsesReq, err := h.translatorOpts.TransformRequestToResponses(reqToTransform, targetModel, previousResponseID)
 		if err != nil {
 			log.Printf("[CLASP] Error transforming request to Responses API: %v", err)
 			return nil, err
@@ -665,10 +973,12 @@ func (h *Handler) transformRequest(req *models.AnthropicRequest, targetModel str
 			return nil, err
 		}
 
-		if h.cfg.DebugRequests {
+		if h.debugRequests(ctx) {
 			debugJSON, _ := json.MarshalIndent(responsesReq, "", "  ")
 			maskedJSON := secrets.MaskJSONSecrets(debugJSON)
-			log.Printf("[CLASP DEBUG] Outgoing OpenAI Responses API request:\n%s", string(maskedJSON))
+			if h.cfg.DebugRequests {
+				log.Printf("[CLASP DEBUG] Outgoing OpenAI Responses API request:\n%s", string(maskedJSON))
+			}
 			logging.LogDebugRequestRaw("OUTGOING", "/v1/responses", maskedJSON)
 		}
 
@@ -676,11 +986,14 @@ func (h *Handler) transformRequest(req *models.AnthropicRequest, targetModel str
 		return reqBody, nil
 	}
 
-	openAIReq, err := translator.TransformRequest(req, targetModel)
+	openAIReq, err := h.translatorOpts.TransformRequest(req, targetModel)
 	if err != nil {
 		log.Printf("[CLASP] Error transforming request: %v", err)
 		return nil, err
 	}
+	if openAIReq.StreamOptions != nil && !h.streamUsageEnabled(p) {
+		openAIReq.StreamOptions = nil
+	}
 
 	reqBody, err := json.Marshal(openAIReq)
 	if err != nil {
@@ -688,34 +1001,73 @@ func (h *Handler) transformRequest(req *models.AnthropicRequest, targetModel str
 		return nil, err
 	}
 
-	if h.cfg.DebugRequests {
+	if h.debugRequests(ctx) {
 		debugJSON, _ := json.MarshalIndent(openAIReq, "", "  ")
 		maskedJSON := secrets.MaskJSONSecrets(debugJSON)
-		log.Printf("[CLASP DEBUG] Outgoing OpenAI Chat Completions request:\n%s", string(maskedJSON))
+		if h.cfg.DebugRequests {
+			log.Printf("[CLASP DEBUG] Outgoing OpenAI Chat Completions request:\n%s", string(maskedJSON))
+		}
 		logging.LogDebugRequestRaw("OUTGOING", "/v1/chat/completions", maskedJSON)
 	}
 
 	return reqBody, nil
 }
 
-// tryFallback attempts to use a fallback provider if the primary fails.
-func (h *Handler) tryFallback(ctx interface{ Done() <-chan struct{} }, req *models.AnthropicRequest, resp *http.Response, targetModel string, originalErr error) (*http.Response, string, bool, bool, error) {
+// lookupStickyRoute returns the request's conversation ID and whether the
+// conversation is pinned to the fallback provider.
+func (h *Handler) lookupStickyRoute(r *http.Request) (string, bool) {
+	if h.stickyRouter == nil {
+		return "", false
+	}
+	conversationID := r.Header.Get(h.cfg.StickyRoutingHeader)
+	route, ok := h.stickyRouter.Get(conversationID)
+	return conversationID, ok && route == routeFallback
+}
+
+// pinStickyRoute records which provider served a conversation.
+func (h *Handler) pinStickyRoute(conversationID string, usedFallback bool) {
+	if h.stickyRouter == nil || conversationID == "" {
+		return
+	}
+	route := routePrimary
+	if usedFallback {
+		route = routeFallback
+	}
+	h.stickyRouter.Set(conversationID, route)
+}
+
+// tryPinnedFallback sends a request straight to the fallback provider for a conversation
+// that sticky routing has pinned to it. Returns false if there is no fallback provider,
+// the request carries a client key, or the fallback fails, in which case the caller
+// should use the primary provider.
+func (h *Handler) tryPinnedFallback(ctx context.Context, req *models.AnthropicRequest, targetModel string) (*http.Response, string, bool, bool) {
+	if !fallbackAllowed(ctx) {
+		return nil, targetModel, false, false
+	}
 	fallbackProvider, fallbackModel := h.getFallbackProvider(req.Model)
 	if fallbackProvider == nil {
-		return resp, targetModel, false, false, originalErr
+		return nil, targetModel, false, false
 	}
 
-	// Close original response if it exists
-	if resp != nil {
-		resp.Body.Close()
+	resp, fallbackTarget, useResponsesAPI, err := h.sendToFallback(ctx, req, fallbackProvider, fallbackModel, targetModel)
+	if err != nil || resp.StatusCode >= 500 {
+		if resp != nil {
+			resp.Body.Close()
+		}
+		log.Printf("[CLASP] Sticky routing: pinned fallback %s unavailable, using primary provider", fallbackProvider.Name())
+		return nil, targetModel, false, false
 	}
 
-	atomic.AddInt64(&h.metrics.FallbackAttempts, 1)
-	log.Printf("[CLASP] Primary provider failed, attempting fallback to %s", fallbackProvider.Name())
+	log.Printf("[CLASP] Sticky routing: conversation pinned to fallback %s", fallbackProvider.Name())
+	auditRoute(ctx, fallbackProvider.Name(), fallbackTarget, true)
+	return resp, fallbackTarget, useResponsesAPI, true
+}
 
+// sendToFallback transforms the request for the fallback provider and executes it.
+// The fallback model replaces targetModel when set.
+func (h *Handler) sendToFallback(ctx context.Context, req *models.AnthropicRequest, fallbackProvider provider.Provider, fallbackModel, targetModel string) (*http.Response, string, bool, error) {
 	// Re-transform request with fallback model if specified
 	useResponsesAPI := false
-	var reqBody []byte
 	if fallbackModel != "" {
 		targetModel = fallbackModel
 		fallbackEndpointType := translator.GetEndpointType(fallbackModel)
@@ -726,52 +1078,66 @@ func (h *Handler) tryFallback(ctx interface{ Done() <-chan struct{} }, req *mode
 		}
 	}
 
-	var err error
 	// Fallback always uses full context (no compaction) for safety.
-	reqBody, err = h.transformRequest(req, targetModel, useResponsesAPI, "", 0)
+	reqBody, err := h.transformRequest(ctx, req, fallbackProvider, targetModel, useResponsesAPI, "", 0)
 	if err != nil {
-		return nil, targetModel, useResponsesAPI, false, err
-	}
-
-	// Try fallback provider
-	resp, err = h.doRequestWithRetry(ctx, reqBody, fallbackProvider)
-	if err == nil && resp.StatusCode < 500 {
-		atomic.AddInt64(&h.metrics.FallbackSuccesses, 1)
-		log.Printf("[CLASP] Fallback to %s succeeded", fallbackProvider.Name())
-		return resp, targetModel, useResponsesAPI, true, nil
+		return nil, targetModel, useResponsesAPI, err
 	}
 
-	return resp, targetModel, useResponsesAPI, false, err
+	resp, err := h.doRequestWithRetry(ctx, reqBody, fallbackProvider)
+	return resp, targetModel, useResponsesAPI, err
 }
 
 // handleUpstreamError handles error responses from the upstream provider.
-func (h *Handler) handleUpstreamError(w http.ResponseWriter, resp *http.Response) {
+// Server errors count against cb, the circuit breaker of the provider, if set.
+// An error saying targetModel doesn't exist is rewritten to suggest the
+// closest models known for providerName.
+func (h *Handler) handleUpstreamError(ctx context.Context, w http.ResponseWriter, resp *http.Response, cb *CircuitBreaker, targetModel, providerName string) {
 	atomic.AddInt64(&h.metrics.ErrorRequests, 1)
-	if h.circuitBreaker != nil && resp.StatusCode >= 500 {
-		h.circuitBreaker.RecordFailure()
+	if cb != nil && resp.StatusCode >= 500 {
+		cb.RecordFailure()
 	}
 	body, _ := io.ReadAll(resp.Body)
 	maskedBody := secrets.MaskAllSecrets(string(body))
 	log.Printf("[CLASP] Upstream error (%d): %s", resp.StatusCode, maskedBody)
+	if enriched, ok := h.modelNotFoundBody(resp.StatusCode, body, targetModel, providerName); ok {
+		body = enriched
+	}
+	h.storeNegativeCache(ctx, resp.StatusCode, body)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(resp.StatusCode)
 	_, _ = w.Write(body)
 }
 
+// writeBodyError translates an error embedded in a 200 upstream response into an
+// Anthropic error response. The request was already counted as a success, so the
+// metrics are corrected here.
+func (h *Handler) writeBodyError(ctx context.Context, w http.ResponseWriter, bodyErr *bodyError) {
+	atomic.AddInt64(&h.metrics.SuccessRequests, -1)
+	atomic.AddInt64(&h.metrics.ErrorRequests, 1)
+	status := bodyErr.statusCode()
+	if cb := circuitBreakerFromContext(ctx); cb != nil && status >= 500 {
+		cb.RecordFailure()
+	}
+	log.Printf("[CLASP] Upstream returned 200 with error body (mapped to %d): %s", status, secrets.MaskAllSecrets(bodyErr.Message))
+	h.writeErrorResponse(w, status, anthropicErrorType(status), bodyErr.Message)
+}
+
 // handleResponse routes the response to the appropriate handler.
 // sessionKey and messageCount are used for compaction session tracking on Responses API paths.
-func (h *Handler) handleResponse(w http.ResponseWriter, resp *http.Response, isStreaming, useResponsesAPI bool, targetModel, cacheKey string, cacheable bool, sessionKey string, messageCount int) {
+// ctx is the client request context; streams are cancelled when it is done.
+func (h *Handler) handleResponse(ctx context.Context, w http.ResponseWriter, resp *http.Response, isStreaming, useResponsesAPI bool, targetModel, cacheKey string, cacheable bool, sessionKey string, messageCount int) {
 	if isStreaming {
 		if useResponsesAPI {
-			h.handleResponsesStreamingResponse(w, resp, targetModel, sessionKey, messageCount)
+			h.handleResponsesStreamingResponse(ctx, w, resp, targetModel, cacheKey, cacheable, sessionKey, messageCount)
 		} else {
-			h.handleStreamingResponse(w, resp, targetModel)
+			h.handleStreamingResponse(ctx, w, resp, targetModel, cacheKey, cacheable)
 		}
 	} else {
 		if useResponsesAPI {
-			h.handleResponsesNonStreamingResponse(w, resp, targetModel, cacheKey, cacheable, sessionKey, messageCount)
+			h.handleResponsesNonStreamingResponse(ctx, w, resp, targetModel, cacheKey, cacheable, sessionKey, messageCount)
 		} else {
-			h.handleNonStreamingResponse(w, resp, targetModel, cacheKey, cacheable)
+			h.handleNonStreamingResponse(ctx, w, resp, targetModel, cacheKey, cacheable)
 		}
 	}
 }
@@ -781,7 +1147,8 @@ func (h *Handler) handleResponse(w http.ResponseWriter, resp *http.Response, isS
 // The prompt cache implements prefix-based LRU caching that simulates Anthropic's
 // cache_control behavior for non-Anthropic backends.
 func (h *Handler) checkPromptCache(w http.ResponseWriter, req *models.AnthropicRequest) (string, bool, int) {
-	if h.promptCache == nil || req.Stream {
+	promptCache := h.activePromptCache()
+	if promptCache == nil || req.Stream {
 		return "", false, 0
 	}
 
@@ -792,7 +1159,7 @@ func (h *Handler) checkPromptCache(w http.ResponseWriter, req *models.AnthropicR
 	}
 
 	// Look up in cache
-	cachedResp, _, found := h.promptCache.Get(cacheKey)
+	cachedResp, _, found := promptCache.Get(cacheKey)
 	if !found {
 		// Cache miss - return key and token estimate for later storage
 		return "", true, tokenEstimate
@@ -813,6 +1180,8 @@ func (h *Handler) checkPromptCache(w http.ResponseWriter, req *models.AnthropicR
 // in the correct format. The _ string and _ int params are reserved for
 // future prompt-cache integration (promptCacheKey, promptCacheTokens).
 func (h *Handler) handlePassthroughRequest(w http.ResponseWriter, r *http.Request, anthropicReq *models.AnthropicRequest, p provider.Provider, start time.Time, cacheKey string, cacheable bool) {
+	r = h.withPassthroughHeaders(r)
+
 	// Marshal the original Anthropic request
 	reqBody, err := json.Marshal(anthropicReq)
 	if err != nil {
@@ -823,19 +1192,22 @@ func (h *Handler) handlePassthroughRequest(w http.ResponseWriter, r *http.Reques
 	}
 
 	// Debug logging for passthrough request (secrets are masked)
-	if h.cfg.DebugRequests {
+	if h.debugRequests(r.Context()) {
 		maskedJSON := secrets.MaskJSONSecrets(reqBody)
-		log.Printf("[CLASP DEBUG] Passthrough to Anthropic API:\n%s", string(maskedJSON))
+		if h.cfg.DebugRequests {
+			log.Printf("[CLASP DEBUG] Passthrough to Anthropic API:\n%s", string(maskedJSON))
+		}
 		// Also log to dedicated debug file
 		logging.LogDebugRequestRaw("PASSTHROUGH", "/v1/messages", maskedJSON)
 	}
 
 	// Execute request with retry logic
+	cb := circuitBreakerFromContext(r.Context())
 	resp, err := h.doRequestWithRetry(r.Context(), reqBody, p)
 	if err != nil {
 		atomic.AddInt64(&h.metrics.ErrorRequests, 1)
-		if h.circuitBreaker != nil {
-			h.circuitBreaker.RecordFailure()
+		if cb != nil {
+			cb.RecordFailure()
 		}
 		log.Printf("[CLASP] Error in passthrough request: %v", err)
 		h.writeErrorResponse(w, http.StatusBadGateway, "api_error", "Error connecting to Anthropic API")
@@ -846,13 +1218,17 @@ func (h *Handler) handlePassthroughRequest(w http.ResponseWriter, r *http.Reques
 	// Check for upstream errors
 	if resp.StatusCode >= 400 {
 		atomic.AddInt64(&h.metrics.ErrorRequests, 1)
-		if h.circuitBreaker != nil && resp.StatusCode >= 500 {
-			h.circuitBreaker.RecordFailure()
+		if cb != nil && resp.StatusCode >= 500 {
+			cb.RecordFailure()
 		}
 		body, _ := io.ReadAll(resp.Body)
 		// Mask any secrets in error response before logging
 		maskedBody := secrets.MaskAllSecrets(string(body))
 		log.Printf("[CLASP] Anthropic API error (%d): %s", resp.StatusCode, maskedBody)
+		if enriched, ok := h.modelNotFoundBody(resp.StatusCode, body, anthropicReq.Model, p.Name()); ok {
+			body = enriched
+		}
+		h.storeNegativeCache(r.Context(), resp.StatusCode, body)
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(resp.StatusCode)
 		_, _ = w.Write(body) // Send original response to client
@@ -860,8 +1236,8 @@ func (h *Handler) handlePassthroughRequest(w http.ResponseWriter, r *http.Reques
 	}
 
 	// Record success for circuit breaker
-	if h.circuitBreaker != nil {
-		h.circuitBreaker.RecordSuccess()
+	if cb != nil {
+		cb.RecordSuccess()
 	}
 
 	atomic.AddInt64(&h.metrics.SuccessRequests, 1)
@@ -872,14 +1248,14 @@ func (h *Handler) handlePassthroughRequest(w http.ResponseWriter, r *http.Reques
 
 	// Handle streaming vs non-streaming passthrough
 	if anthropicReq.Stream {
-		h.handlePassthroughStreaming(w, resp)
+		h.handlePassthroughStreaming(r.Context(), w, resp)
 	} else {
-		h.handlePassthroughNonStreaming(w, resp, cacheKey, cacheable)
+		h.handlePassthroughNonStreaming(r.Context(), w, resp, cacheKey, cacheable)
 	}
 }
 
 // handlePassthroughStreaming streams the Anthropic response directly.
-func (h *Handler) handlePassthroughStreaming(w http.ResponseWriter, resp *http.Response) {
+func (h *Handler) handlePassthroughStreaming(ctx context.Context, w http.ResponseWriter, resp *http.Response) {
 	// Set SSE headers
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
@@ -891,21 +1267,31 @@ func (h *Handler) handlePassthroughStreaming(w http.ResponseWriter, resp *http.R
 		f.Flush()
 	}
 
+	// Stop reading upstream if the client goes away
+	clientAborted := h.watchClientDisconnect(ctx, resp)
+
+	// Read usage from a copy of the stream; the bytes sent are unchanged
+	usage := &passthroughUsage{}
+	defer h.recordPassthroughUsage(ctx, usage)
+
 	// Stream response directly
 	buf := make([]byte, 4096)
 	for {
 		n, err := resp.Body.Read(buf)
 		if n > 0 {
 			if _, writeErr := w.Write(buf[:n]); writeErr != nil {
-				log.Printf("[CLASP] Error writing passthrough stream: %v", writeErr)
+				if !clientAborted() {
+					log.Printf("[CLASP] Error writing passthrough stream: %v", writeErr)
+				}
 				return
 			}
 			if f, ok := w.(http.Flusher); ok {
 				f.Flush()
 			}
+			_, _ = usage.Write(buf[:n])
 		}
 		if err != nil {
-			if err != io.EOF {
+			if !clientAborted() && err != io.EOF {
 				log.Printf("[CLASP] Error reading passthrough stream: %v", err)
 			}
 			return
@@ -914,9 +1300,9 @@ func (h *Handler) handlePassthroughStreaming(w http.ResponseWriter, resp *http.R
 }
 
 // handlePassthroughNonStreaming handles non-streaming passthrough responses.
-func (h *Handler) handlePassthroughNonStreaming(w http.ResponseWriter, resp *http.Response, cacheKey string, cacheable bool) {
+func (h *Handler) handlePassthroughNonStreaming(ctx context.Context, w http.ResponseWriter, resp *http.Response, cacheKey string, cacheable bool) {
 	// Read response body
-	body, err := io.ReadAll(resp.Body)
+	body, err := h.readUpstreamBody(resp)
 	if err != nil {
 		log.Printf("[CLASP] Error reading passthrough response: %v", err)
 		h.writeErrorResponse(w, http.StatusBadGateway, "api_error", "Error reading upstream response")
@@ -924,9 +1310,11 @@ func (h *Handler) handlePassthroughNonStreaming(w http.ResponseWriter, resp *htt
 	}
 
 	// Debug logging (secrets are masked)
-	if h.cfg.DebugResponses {
+	if h.debugResponses(ctx) {
 		maskedBody := secrets.MaskJSONSecrets(body)
-		log.Printf("[CLASP DEBUG] Passthrough response:\n%s", string(maskedBody))
+		if h.cfg.DebugResponses {
+			log.Printf("[CLASP DEBUG] Passthrough response:\n%s", string(maskedBody))
+		}
 		// Also log to dedicated debug file
 		logging.LogDebugRequestRaw("RESPONSE", "/v1/messages (passthrough)", maskedBody)
 	}
@@ -936,19 +1324,18 @@ func (h *Handler) handlePassthroughNonStreaming(w http.ResponseWriter, resp *htt
 	if err := json.Unmarshal(body, &anthropicResp); err == nil {
 		// Track costs for passthrough
 		if h.costTracker != nil && anthropicResp.Usage != nil {
-			h.costTracker.RecordUsage(
-				"anthropic",
-				anthropicResp.Model,
-				anthropicResp.Usage.InputTokens,
-				anthropicResp.Usage.OutputTokens,
-			)
+			h.costTracker.RecordAnthropicUsage("anthropic", anthropicResp.Model, anthropicResp.Usage)
+		}
+		if anthropicResp.Usage != nil {
+			auditUsage(ctx, anthropicResp.Usage.InputTokens, anthropicResp.Usage.OutputTokens)
 		}
 
 		// Cache if enabled
-		if h.cache != nil && cacheable && cacheKey != "" {
-			h.cache.Set(cacheKey, &anthropicResp)
-			log.Printf("[CLASP] Passthrough response cached (key: %s...)", cacheKey[:16])
+		if requestCache := h.activeCache(); requestCache != nil && cacheable && cacheKey != "" {
+			requestCache.Set(cacheKey, &anthropicResp)
+			log.Printf("[CLASP] Passthrough response cached (key: %s...)", truncate(cacheKey, 16))
 			h.tryStorePromptCache(cacheKey, &anthropicResp)
+			h.storeSemanticCache(ctx, &anthropicResp)
 		}
 	}
 
@@ -959,29 +1346,86 @@ func (h *Handler) handlePassthroughNonStreaming(w http.ResponseWriter, resp *htt
 }
 
 // doRequestWithRetry executes the upstream request with exponential backoff retry.
-func (h *Handler) doRequestWithRetry(ctx interface{ Done() <-chan struct{} }, reqBody []byte, p provider.Provider) (*http.Response, error) {
-	maxRetries := 3
-	baseDelay := 500 * time.Millisecond
+func (h *Handler) doRequestWithRetry(ctx context.Context, reqBody []byte, p provider.Provider) (*http.Response, error) {
+	return h.doRequestWithRetryTo(ctx, reqBody, p, p.GetEndpointURL)
+}
+
+// doRequestWithRetryTo is doRequestWithRetry for the URL returned by
+// endpointURL, which is called for each attempt.
+func (h *Handler) doRequestWithRetryTo(ctx context.Context, reqBody []byte, p provider.Provider, endpointURL func() string) (*http.Response, error) {
+	maxRetries := h.cfg.RetryMax
+	if maxRetries < 1 {
+		maxRetries = 1
+	}
+
+	// A per-request timeout replaces the global HTTP client timeout. Failing
+	// that, a tier timeout does, bounding each attempt like the client timeout.
+	upstreamCtx, hasTimeout := upstreamContext(ctx)
+	if raceCtx, ok := ctx.Value(raceContextKey{}).(context.Context); ok {
+		upstreamCtx = raceCtx
+	}
+	attemptTimeout, hasTierTimeout := tierTimeout(ctx)
+	hasTierTimeout = hasTierTimeout && !hasTimeout
+	client := h.client
+	if hasTimeout || hasTierTimeout {
+		client = h.untimedClient
+	}
+
+	if h.retryBudget != nil {
+		h.retryBudget.RecordRequest()
+	}
 
 	var lastErr error
 	for attempt := 0; attempt < maxRetries; attempt++ {
+		// Pick the regional endpoint for this attempt (multi-region routing)
+		upstreamURL := endpointURL()
+		selector := h.endpointSelectors[p]
+		var endpoint string
+		if selector != nil {
+			endpoint = selector.Select()
+			upstreamURL = selector.Rewrite(upstreamURL, endpoint)
+		}
+
+		attemptCtx, cancel := upstreamCtx, context.CancelFunc(func() {})
+		if hasTierTimeout {
+			attemptCtx, cancel = context.WithTimeout(upstreamCtx, attemptTimeout)
+		}
+
 		// Create fresh request for each attempt with context
-		upstreamReq, err := http.NewRequestWithContext(context.Background(), http.MethodPost, p.GetEndpointURL(), bytes.NewReader(reqBody))
+		upstreamReq, err := http.NewRequestWithContext(attemptCtx, http.MethodPost, upstreamURL, bytes.NewReader(reqBody))
 		if err != nil {
+			cancel()
 			return nil, fmt.Errorf("creating request: %w", err)
 		}
 
 		// Set headers (API key may be embedded in provider for tier routing)
-		for key, values := range p.GetHeaders(h.cfg.GetAPIKey()) {
+		headers := p.GetHeaders(h.cfg.GetAPIKey())
+		h.applyExtraHeaders(p, headers)
+		if clientKey := clientKeyFromContext(ctx); clientKey != "" {
+			overrideAPIKey(headers, clientKey)
+		}
+		applyAnthropicVersion(ctx, headers)
+		applyAnthropicBeta(ctx, headers)
+		h.applyPassthroughHeaders(ctx, headers)
+		for key, values := range headers {
 			for _, v := range values {
 				upstreamReq.Header.Add(key, v)
 			}
 		}
 
-		resp, err := h.client.Do(upstreamReq)
+		attemptStart := time.Now()
+		resp, err := client.Do(upstreamReq)
+		if selector != nil && upstreamCtx.Err() == nil {
+			if err != nil || resp.StatusCode >= 500 {
+				selector.RecordFailure(endpoint)
+			} else {
+				selector.RecordSuccess(endpoint, time.Since(attemptStart))
+			}
+		}
 		if err == nil {
 			// Check if we should retry based on status code
 			if resp.StatusCode < 500 || resp.StatusCode == 529 { // Don't retry 5xx except overload
+				resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
 				return resp, nil
 			}
 			// Close response for retry
@@ -990,15 +1434,27 @@ func (h *Handler) doRequestWithRetry(ctx interface{ Done() <-chan struct{} }, re
 		} else {
 			lastErr = err
 		}
+		cancel()
+
+		// The per-request deadline has passed; retrying cannot succeed
+		if upstreamCtx.Err() != nil {
+			return nil, fmt.Errorf("request timed out: %w", lastErr)
+		}
 
 		// Don't retry on last attempt
 		if attempt < maxRetries-1 {
-			delay := baseDelay * time.Duration(1<<attempt) // Exponential backoff
+			if h.retryBudget != nil && !h.retryBudget.AllowRetry() {
+				log.Printf("[CLASP] Retry budget exhausted, not retrying: %v", lastErr)
+				return nil, fmt.Errorf("retry budget exhausted: %w", lastErr)
+			}
+			delay := h.retryDelay(attempt)
 			log.Printf("[CLASP] Retry %d/%d after %v: %v", attempt+1, maxRetries, delay, lastErr)
 
 			select {
 			case <-ctx.Done():
 				return nil, fmt.Errorf("context canceled")
+			case <-upstreamCtx.Done():
+				return nil, fmt.Errorf("request timed out: %w", upstreamCtx.Err())
 			case <-time.After(delay):
 			}
 		}
@@ -1007,26 +1463,53 @@ func (h *Handler) doRequestWithRetry(ctx interface{ Done() <-chan struct{} }, re
 	return nil, fmt.Errorf("max retries exceeded: %w", lastErr)
 }
 
-// getFallbackProvider returns the appropriate fallback provider and model for the given request model.
-// It checks tier-specific fallbacks first, then global fallback.
-func (h *Handler) getFallbackProvider(requestModel string) (provider.Provider, string) {
-	// First check for tier-specific fallback
-	tier := config.GetModelTier(requestModel)
-	if fbProvider, ok := h.tierFallbacks[tier]; ok {
-		// Get fallback model from tier config
-		tierCfg := h.cfg.GetTierConfig(requestModel)
-		if tierCfg != nil && tierCfg.FallbackModel != "" {
-			return fbProvider, tierCfg.FallbackModel
-		}
-		return fbProvider, ""
+// readUpstreamBody reads a non-streaming upstream response body, failing
+// instead of buffering more than the configured maximum.
+func (h *Handler) readUpstreamBody(resp *http.Response) ([]byte, error) {
+	if h.cfg.MaxResponseBytes <= 0 {
+		return io.ReadAll(resp.Body)
 	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, h.cfg.MaxResponseBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) > h.cfg.MaxResponseBytes {
+		return nil, fmt.Errorf("upstream response exceeds %d bytes", h.cfg.MaxResponseBytes)
+	}
+	return body, nil
+}
 
-	// Fall back to global fallback provider
-	if h.fallbackProvider != nil {
-		return h.fallbackProvider, h.cfg.FallbackModel
+// retryDelay returns the exponential backoff before retry number attempt+1,
+// capped at the configured maximum delay. With jitter enabled the delay is
+// drawn uniformly from [0, backoff] so clients don't retry in lockstep.
+func (h *Handler) retryDelay(attempt int) time.Duration {
+	baseDelay := time.Duration(h.cfg.RetryBaseDelayMs) * time.Millisecond
+	maxDelay := time.Duration(h.cfg.RetryMaxDelayMs) * time.Millisecond
+
+	delay := baseDelay
+	for i := 0; i < attempt && (maxDelay <= 0 || delay < maxDelay); i++ {
+		delay *= 2
+	}
+	if maxDelay > 0 && delay > maxDelay {
+		delay = maxDelay
+	}
+
+	if h.cfg.RetryJitter && delay > 0 {
+		if n, err := rand.Int(rand.Reader, big.NewInt(int64(delay)+1)); err == nil {
+			delay = time.Duration(n.Int64())
+		}
 	}
+	return delay
+}
 
-	return nil, ""
+// getFallbackProvider returns the first fallback provider and model for the given request model.
+// It checks tier-specific fallbacks first, then global fallback, then the fallback chain.
+func (h *Handler) getFallbackProvider(requestModel string) (provider.Provider, string) {
+	chain := h.getFallbackChain(requestModel)
+	if len(chain) == 0 {
+		return nil, ""
+	}
+	return chain[0].provider, chain[0].model
 }
 
 // writeErrorResponse writes an Anthropic-formatted error response.
@@ -1043,12 +1526,18 @@ func (h *Handler) writeErrorResponse(w http.ResponseWriter, status int, errType,
 }
 
 // handleStreamingResponse handles SSE streaming responses.
-func (h *Handler) handleStreamingResponse(w http.ResponseWriter, resp *http.Response, targetModel string) {
+// ctx is the client request context; the upstream stream is closed when it is done.
+func (h *Handler) handleStreamingResponse(ctx context.Context, w http.ResponseWriter, resp *http.Response, targetModel, cacheKey string, cacheable bool) {
+	storeInCache := cacheable && cacheKey != ""
+
 	// Set SSE headers
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Connection", "keep-alive")
 	w.Header().Set("X-Accel-Buffering", "no")
+	if storeInCache {
+		w.Header().Set("X-CLASP-Cache", "MISS")
+	}
 
 	// Flush headers
 	if f, ok := w.(http.Flusher); ok {
@@ -1061,34 +1550,81 @@ func (h *Handler) handleStreamingResponse(w http.ResponseWriter, resp *http.Resp
 		fw.flusher = f
 	}
 
+	// Keep idle connections alive during long gaps between tokens
+	out, stopHeartbeat := h.withHeartbeat(fw)
+	defer stopHeartbeat()
+
 	// Generate message ID
 	messageID := generateMessageID()
 
 	// Process stream
-	processor := translator.NewStreamProcessor(fw, messageID, targetModel)
+	processor := h.translatorOpts.NewStreamProcessor(out, messageID, targetModel)
+	if inputTokens, ok := usageEstimate(ctx); ok {
+		processor.SetUsageEstimate(inputTokens)
+	}
 
 	// Set up cost tracking callback if cost tracker is available
 	if h.costTracker != nil {
-		processor.SetUsageCallback(func(inputTokens, outputTokens int) {
-			h.costTracker.RecordUsage(
-				h.provider.Name(),
-				targetModel,
-				inputTokens,
-				outputTokens,
-			)
-			log.Printf("[CLASP] Streaming cost tracked: %d input tokens, %d output tokens", inputTokens, outputTokens)
+		processor.SetUsageDetailsCallback(func(usage *models.AnthropicUsage) {
+			h.costTracker.RecordAnthropicUsage(h.provider.Name(), targetModel, usage)
+			auditUsage(ctx, usage.InputTokens, usage.OutputTokens)
+			log.Printf("[CLASP] Streaming cost tracked: %d input tokens, %d output tokens%s", usage.InputTokens, usage.OutputTokens, cacheUsageLog(usage))
 		})
 	}
 
-	if err := processor.ProcessStream(resp.Body); err != nil {
+	if h.debugResponses(ctx) || storeInCache {
+		processor.EnableResponseCapture()
+	}
+
+	clientAborted := h.watchClientDisconnect(ctx, resp)
+	err := processor.ProcessStream(resp.Body)
+	if clientAborted() {
+		if err != nil {
+			h.recordAbortedStreamUsage(ctx, targetModel, processor)
+		}
+		return
+	}
+	if err != nil {
 		log.Printf("[CLASP] Error processing stream: %v", err)
+		if termErr := processor.Terminate(); termErr != nil {
+			log.Printf("[CLASP] Error terminating stream: %v", termErr)
+		}
+	}
+
+	h.logStreamedResponse(ctx, processor.CapturedResponse())
+	if storeInCache && err == nil {
+		h.storeStreamedResponse(ctx, cacheKey, processor)
+	}
+}
+
+// withHeartbeat wraps a streaming writer with SSE heartbeats when
+// CLASP_SSE_HEARTBEAT_SECONDS is set. The returned function stops them.
+func (h *Handler) withHeartbeat(w io.Writer) (io.Writer, func()) {
+	if h.cfg.SSEHeartbeatSeconds <= 0 {
+		return w, func() {}
+	}
+	hw := newHeartbeatWriter(w, time.Duration(h.cfg.SSEHeartbeatSeconds)*time.Second)
+	return hw, hw.Stop
+}
+
+// logStreamedResponse debug-logs the Anthropic response reconstructed from a stream (secrets are masked).
+func (h *Handler) logStreamedResponse(ctx context.Context, anthropicResp *models.AnthropicResponse) {
+	if !h.debugResponses(ctx) || anthropicResp == nil {
+		return
+	}
+	debugJSON, _ := json.MarshalIndent(anthropicResp, "", "  ")
+	maskedJSON := secrets.MaskJSONSecrets(debugJSON)
+	if h.cfg.DebugResponses {
+		log.Printf("[CLASP DEBUG] Streamed Anthropic response:\n%s", string(maskedJSON))
 	}
+	// Also log to dedicated debug file
+	logging.LogDebugRequestRaw("RESPONSE", "/v1/messages (streamed)", maskedJSON)
 }
 
 // handleNonStreamingResponse handles non-streaming responses.
-func (h *Handler) handleNonStreamingResponse(w http.ResponseWriter, resp *http.Response, targetModel, cacheKey string, cacheable bool) {
+func (h *Handler) handleNonStreamingResponse(ctx context.Context, w http.ResponseWriter, resp *http.Response, targetModel, cacheKey string, cacheable bool) {
 	// Read response body
-	body, err := io.ReadAll(resp.Body)
+	body, err := h.readUpstreamBody(resp)
 	if err != nil {
 		log.Printf("[CLASP] Error reading response: %v", err)
 		http.Error(w, "Error reading upstream response", http.StatusBadGateway)
@@ -1096,35 +1632,20 @@ func (h *Handler) handleNonStreamingResponse(w http.ResponseWriter, resp *http.R
 	}
 
 	// Debug logging for raw response (secrets are masked)
-	if h.cfg.DebugResponses {
+	if h.debugResponses(ctx) {
 		maskedBody := secrets.MaskJSONSecrets(body)
-		log.Printf("[CLASP DEBUG] Raw OpenAI response:\n%s", string(maskedBody))
+		if h.cfg.DebugResponses {
+			log.Printf("[CLASP DEBUG] Raw OpenAI response:\n%s", string(maskedBody))
+		}
 		// Also log to dedicated debug file
 		logging.LogDebugRequestRaw("RESPONSE", "/v1/chat/completions (raw)", maskedBody)
 	}
 
 	// Parse OpenAI response
 	var openAIResp struct {
-		ID      string `json:"id"`
-		Choices []struct {
-			Message struct {
-				Role      string `json:"role"`
-				Content   string `json:"content"`
-				ToolCalls []struct {
-					ID       string `json:"id"`
-					Type     string `json:"type"`
-					Function struct {
-						Name      string `json:"name"`
-						Arguments string `json:"arguments"`
-					} `json:"function"`
-				} `json:"tool_calls"`
-			} `json:"message"`
-			FinishReason string `json:"finish_reason"`
-		} `json:"choices"`
-		Usage struct {
-			PromptTokens     int `json:"prompt_tokens"`
-			CompletionTokens int `json:"completion_tokens"`
-		} `json:"usage"`
+		ID      string                 `json:"id"`
+		Choices []chatCompletionChoice `json:"choices"`
+		Usage   models.Usage           `json:"usage"`
 	}
 
 	if err := json.Unmarshal(body, &openAIResp); err != nil {
@@ -1133,68 +1654,65 @@ func (h *Handler) handleNonStreamingResponse(w http.ResponseWriter, resp *http.R
 		return
 	}
 
+	// Some backends return HTTP 200 with an error body instead of choices
+	if len(openAIResp.Choices) == 0 {
+		if bodyErr, ok := parseBodyError(body); ok {
+			h.writeBodyError(ctx, w, bodyErr)
+			return
+		}
+	}
+
 	// Build Anthropic response
 	anthropicResp := models.AnthropicResponse{
 		ID:    openAIResp.ID,
 		Type:  "message",
 		Role:  "assistant",
 		Model: targetModel,
-		Usage: &models.AnthropicUsage{
-			InputTokens:  openAIResp.Usage.PromptTokens,
-			OutputTokens: openAIResp.Usage.CompletionTokens,
-		},
+		Usage: openAIResp.Usage.AnthropicUsage(),
 	}
 
+	withThinking := h.emitsReasoningContent(targetModel)
 	if len(openAIResp.Choices) > 0 {
 		choice := openAIResp.Choices[0]
-		anthropicResp.StopReason = mapFinishReason(choice.FinishReason)
-
-		// Add text content
-		if choice.Message.Content != "" {
-			anthropicResp.Content = append(anthropicResp.Content, models.AnthropicContentBlock{
-				Type: "text",
-				Text: choice.Message.Content,
-			})
+		anthropicResp.StopReason = h.translatorOpts.MapFinishReason(choice.FinishReason)
+		anthropicResp.Content = choice.anthropicContent(withThinking)
+		// Some servers (Ollama) finish tool calls with "stop"
+		if anthropicResp.StopReason == "end_turn" && len(choice.Message.ToolCalls) > 0 {
+			anthropicResp.StopReason = "tool_use"
 		}
+	}
+	estimateMissingUsage(ctx, &anthropicResp)
 
-		// Add tool calls
-		for _, tc := range choice.Message.ToolCalls {
-			var input interface{}
-			_ = json.Unmarshal([]byte(tc.Function.Arguments), &input)
-
-			anthropicResp.Content = append(anthropicResp.Content, models.AnthropicContentBlock{
-				Type:  "tool_use",
-				ID:    tc.ID,
-				Name:  tc.Function.Name,
-				Input: input,
-			})
-		}
+	// Anthropic has a single candidate; extra choices (n>1) are returned or discarded
+	if len(openAIResp.Choices) > 1 {
+		h.handleExtraChoices(&anthropicResp, openAIResp.Choices[1:], withThinking)
 	}
 
 	// Debug logging for Anthropic response (secrets are masked)
-	if h.cfg.DebugResponses {
+	if h.debugResponses(ctx) {
 		debugJSON, _ := json.MarshalIndent(anthropicResp, "", "  ")
 		maskedJSON := secrets.MaskJSONSecrets(debugJSON)
-		log.Printf("[CLASP DEBUG] Transformed Anthropic response:\n%s", string(maskedJSON))
+		if h.cfg.DebugResponses {
+			log.Printf("[CLASP DEBUG] Transformed Anthropic response:\n%s", string(maskedJSON))
+		}
 		// Also log to dedicated debug file
 		logging.LogDebugRequestRaw("RESPONSE", "/v1/messages (transformed)", maskedJSON)
 	}
 
 	// Track costs
 	if h.costTracker != nil && anthropicResp.Usage != nil {
-		h.costTracker.RecordUsage(
-			h.provider.Name(),
-			targetModel,
-			anthropicResp.Usage.InputTokens,
-			anthropicResp.Usage.OutputTokens,
-		)
+		h.costTracker.RecordAnthropicUsage(h.provider.Name(), targetModel, anthropicResp.Usage)
+	}
+	if anthropicResp.Usage != nil {
+		auditUsage(ctx, anthropicResp.Usage.InputTokens, anthropicResp.Usage.OutputTokens)
 	}
 
 	// Store in cache if cacheable
-	if h.cache != nil && cacheable && cacheKey != "" {
-		h.cache.Set(cacheKey, &anthropicResp)
-		log.Printf("[CLASP] Response cached (key: %s...)", cacheKey[:16])
+	if requestCache := h.activeCache(); requestCache != nil && cacheable && cacheKey != "" {
+		requestCache.Set(cacheKey, &anthropicResp)
+		log.Printf("[CLASP] Response cached (key: %s...)", truncate(cacheKey, 16))
 		h.tryStorePromptCache(cacheKey, &anthropicResp)
+		h.storeSemanticCache(ctx, &anthropicResp)
 	}
 
 	// Write response
@@ -1206,12 +1724,17 @@ func (h *Handler) handleNonStreamingResponse(w http.ResponseWriter, resp *http.R
 // handleResponsesStreamingResponse handles SSE streaming responses from Responses API.
 // sessionKey and messageCount enable compaction session tracking: after a successful
 // stream, the response ID is stored so the next request can use previous_response_id.
-func (h *Handler) handleResponsesStreamingResponse(w http.ResponseWriter, resp *http.Response, targetModel, sessionKey string, messageCount int) {
+func (h *Handler) handleResponsesStreamingResponse(ctx context.Context, w http.ResponseWriter, resp *http.Response, targetModel, cacheKey string, cacheable bool, sessionKey string, messageCount int) {
+	storeInCache := cacheable && cacheKey != ""
+
 	// Set SSE headers
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Connection", "keep-alive")
 	w.Header().Set("X-Accel-Buffering", "no")
+	if storeInCache {
+		w.Header().Set("X-CLASP-Cache", "MISS")
+	}
 
 	// Flush headers
 	if f, ok := w.(http.Flusher); ok {
@@ -1224,27 +1747,47 @@ func (h *Handler) handleResponsesStreamingResponse(w http.ResponseWriter, resp *
 		fw.flusher = f
 	}
 
+	// Keep idle connections alive during long gaps between tokens
+	out, stopHeartbeat := h.withHeartbeat(fw)
+	defer stopHeartbeat()
+
 	// Generate message ID
 	messageID := generateMessageID()
 
 	// Process stream using Responses API processor
-	processor := translator.NewResponsesStreamProcessor(fw, messageID, targetModel)
+	processor := h.translatorOpts.NewResponsesStreamProcessor(out, messageID, targetModel)
 
 	// Set up cost tracking callback if cost tracker is available
 	if h.costTracker != nil {
-		processor.SetUsageCallback(func(inputTokens, outputTokens int) {
-			h.costTracker.RecordUsage(
-				h.provider.Name(),
-				targetModel,
-				inputTokens,
-				outputTokens,
-			)
-			log.Printf("[CLASP] Responses API streaming cost tracked: %d input tokens, %d output tokens", inputTokens, outputTokens)
+		processor.SetUsageDetailsCallback(func(usage *models.AnthropicUsage) {
+			h.costTracker.RecordAnthropicUsage(h.provider.Name(), targetModel, usage)
+			auditUsage(ctx, usage.InputTokens, usage.OutputTokens)
+			log.Printf("[CLASP] Responses API streaming cost tracked: %d input tokens, %d output tokens%s", usage.InputTokens, usage.OutputTokens, cacheUsageLog(usage))
 		})
 	}
 
-	if err := processor.ProcessStream(resp.Body); err != nil {
+	if h.debugResponses(ctx) || storeInCache {
+		processor.EnableResponseCapture()
+	}
+
+	clientAborted := h.watchClientDisconnect(ctx, resp)
+	err := processor.ProcessStream(resp.Body)
+	if clientAborted() {
+		if err != nil {
+			h.recordAbortedStreamUsage(ctx, targetModel, processor)
+		}
+		return
+	}
+	if err != nil {
 		log.Printf("[CLASP] Error processing Responses API stream: %v", err)
+		if termErr := processor.Terminate(); termErr != nil {
+			log.Printf("[CLASP] Error terminating stream: %v", termErr)
+		}
+	}
+
+	h.logStreamedResponse(ctx, processor.CapturedResponse())
+	if storeInCache && err == nil {
+		h.storeStreamedResponse(ctx, cacheKey, processor)
 	}
 
 	// Store response ID in session tracker for compaction.
@@ -1252,16 +1795,16 @@ func (h *Handler) handleResponsesStreamingResponse(w http.ResponseWriter, resp *
 		log.Printf("[CLASP] Responses API response ID: %s", responseID)
 		if h.sessionTracker != nil && sessionKey != "" {
 			h.sessionTracker.Set(sessionKey, responseID, messageCount)
-			log.Printf("[CLASP] Compaction: stored session %s... (messages=%d)", sessionKey[:8], messageCount)
+			log.Printf("[CLASP] Compaction: stored session %s... (messages=%d)", truncate(sessionKey, 8), messageCount)
 		}
 	}
 }
 
 // handleResponsesNonStreamingResponse handles non-streaming responses from Responses API.
 // sessionKey and messageCount enable compaction session tracking.
-func (h *Handler) handleResponsesNonStreamingResponse(w http.ResponseWriter, resp *http.Response, targetModel, cacheKey string, cacheable bool, sessionKey string, messageCount int) {
+func (h *Handler) handleResponsesNonStreamingResponse(ctx context.Context, w http.ResponseWriter, resp *http.Response, targetModel, cacheKey string, cacheable bool, sessionKey string, messageCount int) {
 	// Read response body
-	body, err := io.ReadAll(resp.Body)
+	body, err := h.readUpstreamBody(resp)
 	if err != nil {
 		log.Printf("[CLASP] Error reading Responses API response: %v", err)
 		http.Error(w, "Error reading upstream response", http.StatusBadGateway)
@@ -1269,9 +1812,11 @@ func (h *Handler) handleResponsesNonStreamingResponse(w http.ResponseWriter, res
 	}
 
 	// Debug logging for raw response (secrets are masked)
-	if h.cfg.DebugResponses {
+	if h.debugResponses(ctx) {
 		maskedBody := secrets.MaskJSONSecrets(body)
-		log.Printf("[CLASP DEBUG] Raw OpenAI Responses API response:\n%s", string(maskedBody))
+		if h.cfg.DebugResponses {
+			log.Printf("[CLASP DEBUG] Raw OpenAI Responses API response:\n%s", string(maskedBody))
+		}
 		// Also log to dedicated debug file
 		logging.LogDebugRequestRaw("RESPONSE", "/v1/responses (raw)", maskedBody)
 	}
@@ -1284,11 +1829,19 @@ func (h *Handler) handleResponsesNonStreamingResponse(w http.ResponseWriter, res
 		return
 	}
 
+	// Treat a 200 response carrying an error and no output as an upstream error
+	if len(responsesResp.Output) == 0 {
+		if bodyErr, ok := parseBodyError(body); ok {
+			h.writeBodyError(ctx, w, bodyErr)
+			return
+		}
+	}
+
 	// Store response ID in session tracker for compaction.
 	if h.sessionTracker != nil && sessionKey != "" && responsesResp.ID != "" {
 		h.sessionTracker.Set(sessionKey, responsesResp.ID, messageCount)
 		log.Printf("[CLASP] Compaction: stored session %s... response_id=%s (messages=%d)",
-			sessionKey[:8], responsesResp.ID, messageCount)
+			truncate(sessionKey, 8), responsesResp.ID, messageCount)
 	}
 
 	// Build Anthropic response from Responses API format
@@ -1301,10 +1854,7 @@ func (h *Handler) handleResponsesNonStreamingResponse(w http.ResponseWriter, res
 
 	// Map usage
 	if responsesResp.Usage != nil {
-		anthropicResp.Usage = &models.AnthropicUsage{
-			InputTokens:  responsesResp.Usage.InputTokens,
-			OutputTokens: responsesResp.Usage.OutputTokens,
-		}
+		anthropicResp.Usage = responsesResp.Usage.AnthropicUsage()
 	}
 
 	// Process output items
@@ -1346,7 +1896,7 @@ func (h *Handler) handleResponsesNonStreamingResponse(w http.ResponseWriter, res
 		case "function_call":
 			hasToolCalls = true
 			var input interface{}
-			if err := json.Unmarshal([]byte(item.Arguments), &input); err != nil {
+			if err := json.Unmarshal([]byte(translator.StripJSONCodeFence(item.Arguments)), &input); err != nil {
 				input = map[string]interface{}{}
 			}
 			// Convert Responses API "fc_xxx" ID back to Anthropic "call_xxx" format
@@ -1373,49 +1923,45 @@ func (h *Handler) handleResponsesNonStreamingResponse(w http.ResponseWriter, res
 				}
 				if summaryText != "" {
 					anthropicResp.Content = append(anthropicResp.Content, models.AnthropicContentBlock{
-						Type: "thinking",
-						Text: summaryText,
+						Type:     "thinking",
+						Thinking: summaryText,
 					})
 				}
 			}
 		}
 	}
 
-	// Determine stop reason
-	if responsesResp.Status == "completed" {
-		if hasToolCalls {
-			anthropicResp.StopReason = "tool_use"
-		} else {
-			anthropicResp.StopReason = "end_turn"
-		}
-	} else if responsesResp.Status == "failed" {
-		anthropicResp.StopReason = "end_turn"
+	// Determine stop reason; content filter stops also get a refusal block
+	anthropicResp.StopReason = h.translatorOpts.ResponsesStopReason(&responsesResp, hasToolCalls)
+	if reason := translator.ResponsesContentFilterReason(&responsesResp); reason != "" {
+		anthropicResp.Content = append(anthropicResp.Content, translator.RefusalBlock(reason))
 	}
 
 	// Debug logging for Anthropic response (secrets are masked)
-	if h.cfg.DebugResponses {
+	if h.debugResponses(ctx) {
 		debugJSON, _ := json.MarshalIndent(anthropicResp, "", "  ")
 		maskedJSON := secrets.MaskJSONSecrets(debugJSON)
-		log.Printf("[CLASP DEBUG] Transformed Anthropic response from Responses API:\n%s", string(maskedJSON))
+		if h.cfg.DebugResponses {
+			log.Printf("[CLASP DEBUG] Transformed Anthropic response from Responses API:\n%s", string(maskedJSON))
+		}
 		// Also log to dedicated debug file
 		logging.LogDebugRequestRaw("RESPONSE", "/v1/messages (from responses)", maskedJSON)
 	}
 
 	// Track costs
 	if h.costTracker != nil && anthropicResp.Usage != nil {
-		h.costTracker.RecordUsage(
-			h.provider.Name(),
-			targetModel,
-			anthropicResp.Usage.InputTokens,
-			anthropicResp.Usage.OutputTokens,
-		)
+		h.costTracker.RecordAnthropicUsage(h.provider.Name(), targetModel, anthropicResp.Usage)
+	}
+	if anthropicResp.Usage != nil {
+		auditUsage(ctx, anthropicResp.Usage.InputTokens, anthropicResp.Usage.OutputTokens)
 	}
 
 	// Store in cache if cacheable
-	if h.cache != nil && cacheable && cacheKey != "" {
-		h.cache.Set(cacheKey, &anthropicResp)
-		log.Printf("[CLASP] Responses API response cached (key: %s...)", cacheKey[:16])
+	if requestCache := h.activeCache(); requestCache != nil && cacheable && cacheKey != "" {
+		requestCache.Set(cacheKey, &anthropicResp)
+		log.Printf("[CLASP] Responses API response cached (key: %s...)", truncate(cacheKey, 16))
 		h.tryStorePromptCache(cacheKey, &anthropicResp)
+		h.storeSemanticCache(ctx, &anthropicResp)
 	}
 
 	// Write response
@@ -1431,15 +1977,17 @@ func (h *Handler) HandleHealth(w http.ResponseWriter, r *http.Request) {
 	response := map[string]interface{}{
 		"status":   "healthy",
 		"provider": h.provider.Name(),
-		"uptime":   time.Since(h.metrics.StartTime).String(),
+		"uptime":   h.metrics.Uptime().String(),
 	}
 
 	// Add circuit breaker status if enabled
-	if h.circuitBreaker != nil {
+	if h.circuitBreakers != nil {
+		cb := h.circuitBreakers.Get(h.provider.Name())
 		response["circuit_breaker"] = map[string]interface{}{
-			"state": h.circuitBreaker.State(),
-			"open":  h.circuitBreaker.IsOpen(),
+			"state": cb.State(),
+			"open":  cb.IsOpen(),
 		}
+		response["circuit_breakers"] = h.circuitBreakers.States()
 	}
 
 	// Add provider health details if health checker is enabled
@@ -1457,11 +2005,38 @@ func (h *Handler) HandleHealth(w http.ResponseWriter, r *http.Request) {
 		response["health_summary"] = stats
 	}
 
+	// Verify upstream connectivity if enabled
+	status := http.StatusOK
+	if h.cfg.HealthCheckUpstream {
+		reachable, checkedAt, lastError := h.upstreamHealth()
+		response["upstream"] = "reachable"
+		response["upstream_checked_at"] = checkedAt
+		if lastError != "" {
+			response["upstream_error"] = lastError
+		}
+		if !reachable {
+			response["upstream"] = "unreachable"
+			response["status"] = "unhealthy"
+			status = http.StatusServiceUnavailable
+		}
+	}
+
+	w.WriteHeader(status)
 	_ = json.NewEncoder(w).Encode(response)
 }
 
-// HandleMetrics handles metrics endpoint requests.
+// HandleMetrics handles metrics endpoint requests. POST with action=reset
+// zeroes the counters.
 func (h *Handler) HandleMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		// A reset wipes the cost figures budgets and alerts rely on
+		if !h.requireAuth(w, "/metrics") {
+			return
+		}
+		h.handleMetricsReset(w, r)
+		return
+	}
+
 	total := atomic.LoadInt64(&h.metrics.TotalRequests)
 	success := atomic.LoadInt64(&h.metrics.SuccessRequests)
 	errors := atomic.LoadInt64(&h.metrics.ErrorRequests)
@@ -1474,7 +2049,7 @@ func (h *Handler) HandleMetrics(w http.ResponseWriter, r *http.Request) {
 		avgLatency = float64(totalLatency) / float64(success)
 	}
 
-	uptime := time.Since(h.metrics.StartTime)
+	uptime := h.metrics.Uptime()
 	var requestsPerSec float64
 	if uptime.Seconds() > 0 {
 		requestsPerSec = float64(total) / uptime.Seconds()
@@ -1487,19 +2062,21 @@ func (h *Handler) HandleMetrics(w http.ResponseWriter, r *http.Request) {
 
 	response := map[string]interface{}{
 		"requests": map[string]interface{}{
-			"total":        total,
-			"successful":   success,
-			"errors":       errors,
-			"streaming":    streams,
-			"tool_calls":   toolCalls,
-			"success_rate": fmt.Sprintf("%.2f%%", successRate),
+			"total":                total,
+			"successful":           success,
+			"errors":               errors,
+			"streaming":            streams,
+			"tool_calls":           toolCalls,
+			"success_rate":         fmt.Sprintf("%.2f%%", successRate),
+			"stream_client_aborts": atomic.LoadInt64(&h.metrics.StreamClientAborts),
 		},
 		"performance": map[string]interface{}{
 			"avg_latency_ms":   fmt.Sprintf("%.2f", avgLatency),
 			"requests_per_sec": fmt.Sprintf("%.2f", requestsPerSec),
 		},
-		"uptime":   uptime.String(),
-		"provider": h.provider.Name(),
+		"uptime":          uptime.String(),
+		"provider":        h.provider.Name(),
+		"thinking_tokens": atomic.LoadInt64(&h.metrics.ThinkingTokens),
 		"config": map[string]interface{}{
 			"http_timeout_sec": h.cfg.HTTPClientTimeoutSec,
 		},
@@ -1517,6 +2094,7 @@ func (h *Handler) HandleMetrics(w http.ResponseWriter, r *http.Request) {
 			"enabled":          true,
 			"hits":             compHits,
 			"misses":           compMisses,
+			"resets":           atomic.LoadInt64(&h.metrics.CompactionResets),
 			"hit_rate":         fmt.Sprintf("%.2f%%", compRate),
 			"active_sessions":  h.sessionTracker.Len(),
 			"session_timeout_s": h.cfg.SessionTimeoutSec,
@@ -1546,6 +2124,36 @@ func (h *Handler) HandleMetrics(w http.ResponseWriter, r *http.Request) {
 			"hits":     hits,
 			"misses":   misses,
 			"hit_rate": fmt.Sprintf("%.2f%%", hitRate),
+			"coalesced": atomic.LoadInt64(&h.metrics.CoalescedRequests),
+		}
+	}
+
+	// Add semantic cache stats if enabled
+	if h.semanticCache != nil {
+		size, maxSize, hits, misses, threshold := h.semanticCache.Stats()
+		var hitRate float64
+		if total := hits + misses; total > 0 {
+			hitRate = float64(hits) / float64(total) * 100
+		}
+		response["semantic_cache"] = map[string]interface{}{
+			"enabled":   true,
+			"size":      size,
+			"max_size":  maxSize,
+			"hits":      hits,
+			"misses":    misses,
+			"hit_rate":  fmt.Sprintf("%.2f%%", hitRate),
+			"threshold": threshold,
+		}
+	}
+
+	// Add negative cache stats if enabled
+	if h.negativeCache != nil {
+		size, hits, misses := h.negativeCache.Stats()
+		response["negative_cache"] = map[string]interface{}{
+			"enabled": true,
+			"size":    size,
+			"hits":    hits,
+			"misses":  misses,
 		}
 	}
 
@@ -1564,7 +2172,7 @@ func (h *Handler) HandleMetrics(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Add fallback stats if fallback is configured
-	if h.fallbackProvider != nil || len(h.tierFallbacks) > 0 {
+	if h.hasFallback() {
 		fbAttempts := atomic.LoadInt64(&h.metrics.FallbackAttempts)
 		fbSuccesses := atomic.LoadInt64(&h.metrics.FallbackSuccesses)
 		var fbSuccessRate float64
@@ -1576,6 +2184,9 @@ func (h *Handler) HandleMetrics(w http.ResponseWriter, r *http.Request) {
 			"attempts":     fbAttempts,
 			"successes":    fbSuccesses,
 			"success_rate": fmt.Sprintf("%.2f%%", fbSuccessRate),
+			"slow_races":   atomic.LoadInt64(&h.metrics.SlowFallbackRaces),
+			"slow_wins":    atomic.LoadInt64(&h.metrics.SlowFallbackWins),
+			"by_tier":      h.fallbackBreakdown(),
 		}
 	}
 
@@ -1591,14 +2202,51 @@ func (h *Handler) HandleMetrics(w http.ResponseWriter, r *http.Request) {
 			"expired":  stats.Expired,
 			"length":   stats.Length,
 			"paused":   stats.Paused,
+
+			"length_by_priority": stats.LengthByPriority,
+			"dead_lettered":      stats.DeadLettered,
+		}
+	}
+
+	// Add retry budget stats if enabled
+	if h.retryBudget != nil {
+		stats := h.retryBudget.Stats()
+		response["retry_budget"] = map[string]interface{}{
+			"enabled":   true,
+			"ratio":     stats.Ratio,
+			"available": stats.Available,
+			"requests":  stats.Requests,
+			"retries":   stats.Retries,
+			"throttled": stats.Throttled,
+		}
+	}
+
+	// Add concurrency limit stats if enabled
+	if h.concurrency != nil {
+		response["concurrency"] = map[string]interface{}{
+			"enabled":   true,
+			"limit":     h.concurrency.Limit(),
+			"in_flight": h.concurrency.InFlight(),
+			"queued":    h.concurrency.Queued(),
+			"rejected":  h.concurrency.Rejected(),
 		}
 	}
 
 	// Add circuit breaker stats if enabled
-	if h.circuitBreaker != nil {
+	if h.circuitBreakers != nil {
+		probes := make(map[string]interface{})
+		for _, name := range h.circuitBreakers.Providers() {
+			cb := h.circuitBreakers.Get(name)
+			probes[name] = map[string]int64{
+				"admitted": cb.HalfOpenProbes(),
+				"rejected": cb.HalfOpenRejected(),
+			}
+		}
 		response["circuit_breaker"] = map[string]interface{}{
-			"enabled": true,
-			"state":   h.circuitBreaker.State(),
+			"enabled":          true,
+			"state":            h.circuitBreakers.Get(h.provider.Name()).State(),
+			"providers":        h.circuitBreakers.States(),
+			"half_open_probes": probes,
 		}
 	}
 
@@ -1636,7 +2284,7 @@ func (h *Handler) HandleMetricsPrometheus(w http.ResponseWriter, r *http.Request
 	toolCalls := atomic.LoadInt64(&h.metrics.ToolCallRequests)
 	totalLatency := atomic.LoadInt64(&h.metrics.TotalLatencyMs)
 
-	uptime := time.Since(h.metrics.StartTime)
+	uptime := h.metrics.Uptime()
 	providerName := h.provider.Name()
 
 	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
@@ -1662,6 +2310,14 @@ func (h *Handler) HandleMetricsPrometheus(w http.ResponseWriter, r *http.Request
 	fmt.Fprintf(w, "# TYPE clasp_requests_tool_calls counter\n")
 	fmt.Fprintf(w, "clasp_requests_tool_calls{provider=\"%s\"} %d\n", providerName, toolCalls)
 
+	fmt.Fprintf(w, "# HELP clasp_stream_client_aborts Total number of streams cancelled because the client disconnected\n")
+	fmt.Fprintf(w, "# TYPE clasp_stream_client_aborts counter\n")
+	fmt.Fprintf(w, "clasp_stream_client_aborts{provider=\"%s\"} %d\n", providerName, atomic.LoadInt64(&h.metrics.StreamClientAborts))
+
+	fmt.Fprintf(w, "# HELP clasp_tokens_thinking_total Estimated thinking tokens in passthrough streams\n")
+	fmt.Fprintf(w, "# TYPE clasp_tokens_thinking_total counter\n")
+	fmt.Fprintf(w, "clasp_tokens_thinking_total{provider=\"%s\"} %d\n", providerName, atomic.LoadInt64(&h.metrics.ThinkingTokens))
+
 	fmt.Fprintf(w, "# HELP clasp_latency_total_ms Total latency of all successful requests in milliseconds\n")
 	fmt.Fprintf(w, "# TYPE clasp_latency_total_ms counter\n")
 	fmt.Fprintf(w, "clasp_latency_total_ms{provider=\"%s\"} %d\n", providerName, totalLatency)
@@ -1717,6 +2373,38 @@ func (h *Handler) HandleMetricsPrometheus(w http.ResponseWriter, r *http.Request
 		fmt.Fprintf(w, "# HELP clasp_cache_misses Total cache misses\n")
 		fmt.Fprintf(w, "# TYPE clasp_cache_misses counter\n")
 		fmt.Fprintf(w, "clasp_cache_misses{provider=\"%s\"} %d\n", providerName, misses)
+
+		fmt.Fprintf(w, "# HELP clasp_cache_coalesced_total Requests that shared an identical in-flight request's upstream call\n")
+		fmt.Fprintf(w, "# TYPE clasp_cache_coalesced_total counter\n")
+		fmt.Fprintf(w, "clasp_cache_coalesced_total{provider=\"%s\"} %d\n", providerName, atomic.LoadInt64(&h.metrics.CoalescedRequests))
+	}
+
+	// Semantic cache metrics
+	if h.semanticCache != nil {
+		size, _, hits, misses, _ := h.semanticCache.Stats()
+		fmt.Fprintf(w, "# HELP clasp_semantic_cache_size Current number of entries in the semantic cache\n")
+		fmt.Fprintf(w, "# TYPE clasp_semantic_cache_size gauge\n")
+		fmt.Fprintf(w, "clasp_semantic_cache_size{provider=\"%s\"} %d\n", providerName, size)
+
+		fmt.Fprintf(w, "# HELP clasp_semantic_cache_hits Total semantic cache hits\n")
+		fmt.Fprintf(w, "# TYPE clasp_semantic_cache_hits counter\n")
+		fmt.Fprintf(w, "clasp_semantic_cache_hits{provider=\"%s\"} %d\n", providerName, hits)
+
+		fmt.Fprintf(w, "# HELP clasp_semantic_cache_misses Total semantic cache misses\n")
+		fmt.Fprintf(w, "# TYPE clasp_semantic_cache_misses counter\n")
+		fmt.Fprintf(w, "clasp_semantic_cache_misses{provider=\"%s\"} %d\n", providerName, misses)
+	}
+
+	// Negative cache metrics
+	if h.negativeCache != nil {
+		size, hits, _ := h.negativeCache.Stats()
+		fmt.Fprintf(w, "# HELP clasp_negative_cache_size Current number of cached upstream errors\n")
+		fmt.Fprintf(w, "# TYPE clasp_negative_cache_size gauge\n")
+		fmt.Fprintf(w, "clasp_negative_cache_size{provider=\"%s\"} %d\n", providerName, size)
+
+		fmt.Fprintf(w, "# HELP clasp_negative_cache_hits Total requests answered with a cached upstream error\n")
+		fmt.Fprintf(w, "# TYPE clasp_negative_cache_hits counter\n")
+		fmt.Fprintf(w, "clasp_negative_cache_hits{provider=\"%s\"} %d\n", providerName, hits)
 	}
 
 	// Prompt cache metrics
@@ -1725,17 +2413,33 @@ func (h *Handler) HandleMetricsPrometheus(w http.ResponseWriter, r *http.Request
 	}
 
 	// Fallback metrics
-	if h.fallbackProvider != nil || len(h.tierFallbacks) > 0 {
-		fbAttempts := atomic.LoadInt64(&h.metrics.FallbackAttempts)
-		fbSuccesses := atomic.LoadInt64(&h.metrics.FallbackSuccesses)
+	if h.hasFallback() {
+		h.writeFallbackPrometheus(w)
 
-		fmt.Fprintf(w, "# HELP clasp_fallback_attempts Total fallback attempts\n")
-		fmt.Fprintf(w, "# TYPE clasp_fallback_attempts counter\n")
-		fmt.Fprintf(w, "clasp_fallback_attempts{provider=\"%s\"} %d\n", providerName, fbAttempts)
+		fmt.Fprintf(w, "# HELP clasp_fallback_slow_races_total Fallback requests raced against a slow primary\n")
+		fmt.Fprintf(w, "# TYPE clasp_fallback_slow_races_total counter\n")
+		fmt.Fprintf(w, "clasp_fallback_slow_races_total{provider=\"%s\"} %d\n", providerName, atomic.LoadInt64(&h.metrics.SlowFallbackRaces))
 
-		fmt.Fprintf(w, "# HELP clasp_fallback_successes Total successful fallback attempts\n")
-		fmt.Fprintf(w, "# TYPE clasp_fallback_successes counter\n")
-		fmt.Fprintf(w, "clasp_fallback_successes{provider=\"%s\"} %d\n", providerName, fbSuccesses)
+		fmt.Fprintf(w, "# HELP clasp_fallback_slow_wins_total Slow-primary races won by the fallback\n")
+		fmt.Fprintf(w, "# TYPE clasp_fallback_slow_wins_total counter\n")
+		fmt.Fprintf(w, "clasp_fallback_slow_wins_total{provider=\"%s\"} %d\n", providerName, atomic.LoadInt64(&h.metrics.SlowFallbackWins))
+	}
+
+	// Retry budget metrics
+	if h.retryBudget != nil {
+		stats := h.retryBudget.Stats()
+
+		fmt.Fprintf(w, "# HELP clasp_retry_budget_retries_total Upstream retries allowed by the retry budget\n")
+		fmt.Fprintf(w, "# TYPE clasp_retry_budget_retries_total counter\n")
+		fmt.Fprintf(w, "clasp_retry_budget_retries_total{provider=\"%s\"} %d\n", providerName, stats.Retries)
+
+		fmt.Fprintf(w, "# HELP clasp_retry_budget_throttled_total Retries skipped because the retry budget was exhausted\n")
+		fmt.Fprintf(w, "# TYPE clasp_retry_budget_throttled_total counter\n")
+		fmt.Fprintf(w, "clasp_retry_budget_throttled_total{provider=\"%s\"} %d\n", providerName, stats.Throttled)
+
+		fmt.Fprintf(w, "# HELP clasp_retry_budget_available Retries currently available in the retry budget\n")
+		fmt.Fprintf(w, "# TYPE clasp_retry_budget_available gauge\n")
+		fmt.Fprintf(w, "clasp_retry_budget_available{provider=\"%s\"} %g\n", providerName, stats.Available)
 	}
 
 	// Queue metrics
@@ -1762,35 +2466,78 @@ func (h *Handler) HandleMetricsPrometheus(w http.ResponseWriter, r *http.Request
 		fmt.Fprintf(w, "# TYPE clasp_queue_expired counter\n")
 		fmt.Fprintf(w, "clasp_queue_expired{provider=\"%s\"} %d\n", providerName, stats.Expired)
 
+		fmt.Fprintf(w, "# HELP clasp_queue_dead_lettered Total requests moved to the dead-letter queue\n")
+		fmt.Fprintf(w, "# TYPE clasp_queue_dead_lettered counter\n")
+		fmt.Fprintf(w, "clasp_queue_dead_lettered{provider=\"%s\"} %d\n", providerName, stats.DeadLettered)
+
 		fmt.Fprintf(w, "# HELP clasp_queue_length Current queue length\n")
 		fmt.Fprintf(w, "# TYPE clasp_queue_length gauge\n")
 		fmt.Fprintf(w, "clasp_queue_length{provider=\"%s\"} %d\n", providerName, stats.Length)
-	}
 
-	// Circuit breaker metrics
-	if h.circuitBreaker != nil {
-		state := h.circuitBreaker.State()
-		var stateValue int
-		switch state {
-		case "closed":
-			stateValue = 0
-		case "half-open":
-			stateValue = 1
-		case "open":
-			stateValue = 2
+		fmt.Fprintf(w, "# HELP clasp_queue_length_by_priority Current queue length per priority\n")
+		fmt.Fprintf(w, "# TYPE clasp_queue_length_by_priority gauge\n")
+		for _, p := range queuePriorities {
+			fmt.Fprintf(w, "clasp_queue_length_by_priority{provider=\"%s\",priority=\"%s\"} %d\n", providerName, p, stats.LengthByPriority[p.String()])
 		}
+	}
+
+	// Concurrency limit metrics
+	if h.concurrency != nil {
+		fmt.Fprintf(w, "# HELP clasp_requests_in_flight Requests currently in flight\n")
+		fmt.Fprintf(w, "# TYPE clasp_requests_in_flight gauge\n")
+		fmt.Fprintf(w, "clasp_requests_in_flight{provider=\"%s\"} %d\n", providerName, h.concurrency.InFlight())
+
+		fmt.Fprintf(w, "# HELP clasp_requests_queued_concurrency Total requests that waited for a concurrency slot\n")
+		fmt.Fprintf(w, "# TYPE clasp_requests_queued_concurrency counter\n")
+		fmt.Fprintf(w, "clasp_requests_queued_concurrency{provider=\"%s\"} %d\n", providerName, h.concurrency.Queued())
+
+		fmt.Fprintf(w, "# HELP clasp_requests_rejected_concurrency Total requests rejected by the concurrency limit\n")
+		fmt.Fprintf(w, "# TYPE clasp_requests_rejected_concurrency counter\n")
+		fmt.Fprintf(w, "clasp_requests_rejected_concurrency{provider=\"%s\"} %d\n", providerName, h.concurrency.Rejected())
+	}
+
+	// Circuit breaker metrics, one series per provider
+	if h.circuitBreakers != nil {
+		// Always report the primary provider, even before its first request
+		h.circuitBreakers.Get(providerName)
+		names := h.circuitBreakers.Providers()
 
 		fmt.Fprintf(w, "# HELP clasp_circuit_breaker_state Circuit breaker state (0=closed, 1=half-open, 2=open)\n")
 		fmt.Fprintf(w, "# TYPE clasp_circuit_breaker_state gauge\n")
-		fmt.Fprintf(w, "clasp_circuit_breaker_state{provider=\"%s\"} %d\n", providerName, stateValue)
+		for _, name := range names {
+			var stateValue int
+			switch h.circuitBreakers.Get(name).State() {
+			case "closed":
+				stateValue = 0
+			case "half-open":
+				stateValue = 1
+			case "open":
+				stateValue = 2
+			}
+			fmt.Fprintf(w, "clasp_circuit_breaker_state{provider=\"%s\"} %d\n", name, stateValue)
+		}
 
 		fmt.Fprintf(w, "# HELP clasp_circuit_breaker_open Whether circuit breaker is open (1) or not (0)\n")
 		fmt.Fprintf(w, "# TYPE clasp_circuit_breaker_open gauge\n")
-		isOpen := 0
-		if h.circuitBreaker.IsOpen() {
-			isOpen = 1
+		for _, name := range names {
+			isOpen := 0
+			if h.circuitBreakers.Get(name).IsOpen() {
+				isOpen = 1
+			}
+			fmt.Fprintf(w, "clasp_circuit_breaker_open{provider=\"%s\"} %d\n", name, isOpen)
+		}
+
+		fmt.Fprintf(w, "# HELP clasp_circuit_breaker_half_open_probes_total Probe requests admitted while half-open\n")
+		fmt.Fprintf(w, "# TYPE clasp_circuit_breaker_half_open_probes_total counter\n")
+		for _, name := range names {
+			fmt.Fprintf(w, "clasp_circuit_breaker_half_open_probes_total{provider=\"%s\"} %d\n", name, h.circuitBreakers.Get(name).HalfOpenProbes())
+		}
+
+		fmt.Fprintf(w, "# HELP clasp_circuit_breaker_half_open_rejected_total Requests fast-failed while half-open probes were in flight\n")
+		fmt.Fprintf(w, "# TYPE clasp_circuit_breaker_half_open_rejected_total counter\n")
+		for _, name := range names {
+			fmt.Fprintf(w, "clasp_circuit_breaker_half_open_rejected_total{provider=\"%s\"} %d\n", name, h.circuitBreakers.Get(name).HalfOpenRejected())
 		}
-		fmt.Fprintf(w, "clasp_circuit_breaker_open{provider=\"%s\"} %d\n", providerName, isOpen)
 	}
 
 	// Health check metrics
@@ -1865,6 +2612,9 @@ func (h *Handler) HandleMetricsPrometheus(w http.ResponseWriter, r *http.Request
 			fmt.Fprintf(w, "clasp_tokens_by_model{provider=\"%s\",model=\"%s\",type=\"input\"} %d\n", providerName, model, mc.InputTokens)
 			fmt.Fprintf(w, "clasp_tokens_by_model{provider=\"%s\",model=\"%s\",type=\"output\"} %d\n", providerName, model, mc.OutputTokens)
 		}
+
+		// Per-request token distributions
+		h.costTracker.WriteTokenHistogramsPrometheus(w, providerName)
 	}
 }
 
@@ -1893,6 +2643,18 @@ func (h *Handler) HandleCosts(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// Hourly breakdown over a recent window
+	if window := r.URL.Query().Get("window"); window != "" {
+		d, err := parseCostWindow(window)
+		if err != nil {
+			h.writeErrorResponse(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(h.costTracker.GetWindow(d))
+		return
+	}
+
 	summary := h.costTracker.GetSummary()
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(summary)
@@ -1911,12 +2673,19 @@ func (h *Handler) HandleRoot(w http.ResponseWriter, r *http.Request) {
 		"provider": h.provider.Name(),
 		"status":   "running",
 		"endpoints": map[string]string{
-			"messages":        "/v1/messages",
+			"messages":        h.cfg.APIPathPrefix + "/messages",
+			"messages_preview": h.cfg.APIPathPrefix + "/messages/preview",
+			"embeddings":      h.cfg.APIPathPrefix + "/embeddings",
 			"health":          "/health",
+			"liveness":        "/livez",
+			"readiness":       "/readyz",
 			"providers_health": "/providers/health",
 			"metrics":         "/metrics",
 			"prometheus":      "/metrics/prometheus",
 			"costs":           "/costs",
+			"cost_models":     "/costs/models",
+			"dead_letters":    "/queue/dlq",
+			"admin_features":  "/admin/features",
 		},
 	}
 
@@ -1977,17 +2746,3 @@ func randomHex(n int) string {
 	}
 	return hex.EncodeToString(b)
 }
-
-// mapFinishReason maps OpenAI finish_reason to Anthropic stop_reason.
-func mapFinishReason(reason string) string {
-	switch strings.ToLower(reason) {
-	case "stop":
-		return "end_turn"
-	case "tool_calls":
-		return "tool_use"
-	case "length":
-		return "max_tokens"
-	default:
-		return "end_turn"
-	}
-}
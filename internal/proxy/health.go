@@ -231,14 +231,27 @@ func (hc *HealthChecker) checkProvider(name string, info *providerInfo) {
 
 // doHealthCheck performs the actual HTTP health check.
 func (hc *HealthChecker) doHealthCheck(ctx context.Context, info *providerInfo) (bool, error) {
+	status, err := probeProvider(ctx, hc.client, info.provider, info.apiKey)
+	if err != nil {
+		return false, err
+	}
+
+	// Consider healthy if we get any response (even 401 means the server is up)
+	// Only consider unhealthy on 5xx errors or connection failures
+	return status < 500, nil
+}
+
+// probeProvider sends a lightweight request to a provider's endpoint and
+// returns the response status.
+func probeProvider(ctx context.Context, client *http.Client, p provider.Provider, apiKey string) (int, error) {
 	// For most providers, we check if we can reach the models endpoint
 	// This is a lightweight check that doesn't consume tokens
-	endpoint := info.provider.GetEndpointURL()
+	endpoint := p.GetEndpointURL()
 
 	// Try to hit a lightweight endpoint
 	// For OpenAI-compatible providers, use /models endpoint
 	checkURL := endpoint
-	if info.provider.RequiresTransformation() {
+	if p.RequiresTransformation() {
 		// OpenAI-compatible: check /models endpoint
 		checkURL = endpoint + "/models"
 	} else {
@@ -248,30 +261,23 @@ func (hc *HealthChecker) doHealthCheck(ctx context.Context, info *providerInfo)
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, checkURL, nil)
 	if err != nil {
-		return false, err
+		return 0, err
 	}
 
 	// Set headers
-	headers := info.provider.GetHeaders(info.apiKey)
+	headers := p.GetHeaders(apiKey)
 	for key, values := range headers {
 		for _, v := range values {
 			req.Header.Add(key, v)
 		}
 	}
 
-	resp, err := hc.client.Do(req)
+	resp, err := client.Do(req)
 	if err != nil {
-		return false, err
+		return 0, err
 	}
-	defer resp.Body.Close()
-
-	// Consider healthy if we get any response (even 401 means the server is up)
-	// Only consider unhealthy on 5xx errors or connection failures
-	if resp.StatusCode >= 500 {
-		return false, nil
-	}
-
-	return true, nil
+	resp.Body.Close()
+	return resp.StatusCode, nil
 }
 
 // GetHealth returns the health status of all providers.
@@ -0,0 +1,97 @@
+// Package proxy implements the HTTP proxy server.
+package proxy
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/jedarden/clasp/pkg/models"
+)
+
+// sseHeartbeat is the SSE comment sent to keep idle streams open.
+// Comment lines are ignored by SSE clients, so the event sequence is unaffected.
+const sseHeartbeat = ": ping\n\n"
+
+var messageStopEvent = []byte("event: " + models.EventMessageStop + "\n")
+
+// heartbeatWriter serializes writes to a streaming response and writes an SSE
+// comment whenever nothing has been written for the heartbeat interval, so
+// proxies and load balancers don't close the connection during long gaps.
+// Heartbeats stop once message_stop has been written.
+type heartbeatWriter struct {
+	mu        sync.Mutex
+	w         io.Writer
+	interval  time.Duration
+	lastWrite time.Time
+	stopped   bool
+
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// newHeartbeatWriter wraps w and starts sending heartbeats. Call Stop when the stream ends.
+func newHeartbeatWriter(w io.Writer, interval time.Duration) *heartbeatWriter {
+	hw := &heartbeatWriter{
+		w:         w,
+		interval:  interval,
+		lastWrite: time.Now(),
+		done:      make(chan struct{}),
+	}
+	go hw.run()
+	return hw
+}
+
+// Write writes SSE data, marking the stream finished when it contains message_stop.
+// Each Anthropic event is written in a single call, so heartbeats never split an event.
+func (hw *heartbeatWriter) Write(p []byte) (int, error) {
+	hw.mu.Lock()
+	defer hw.mu.Unlock()
+
+	hw.lastWrite = time.Now()
+	if bytes.Contains(p, messageStopEvent) {
+		hw.stopped = true
+	}
+	return hw.w.Write(p)
+}
+
+// Stop ends heartbeats.
+func (hw *heartbeatWriter) Stop() {
+	hw.mu.Lock()
+	hw.stopped = true
+	hw.mu.Unlock()
+	hw.stopOnce.Do(func() { close(hw.done) })
+}
+
+func (hw *heartbeatWriter) run() {
+	timer := time.NewTimer(hw.interval)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-hw.done:
+			return
+		case <-timer.C:
+		}
+
+		hw.mu.Lock()
+		if hw.stopped {
+			hw.mu.Unlock()
+			return
+		}
+		idle := time.Since(hw.lastWrite)
+		if idle >= hw.interval {
+			if _, err := io.WriteString(hw.w, sseHeartbeat); err != nil {
+				hw.stopped = true
+				hw.mu.Unlock()
+				return
+			}
+			hw.lastWrite = time.Now()
+			idle = 0
+		}
+		hw.mu.Unlock()
+
+		timer.Reset(hw.interval - idle)
+	}
+}
@@ -0,0 +1,185 @@
+package proxy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jedarden/clasp/pkg/models"
+)
+
+// RequestTransformer modifies a validated Anthropic request before a provider
+// is selected. Transformers run in the order they are configured.
+type RequestTransformer interface {
+	// Name identifies the transformer in logs and errors.
+	Name() string
+	// Transform modifies req in place.
+	Transform(req *models.AnthropicRequest) error
+}
+
+// HooksConfig is the format of ~/.clasp/hooks.json.
+type HooksConfig struct {
+	Hooks []HookConfig `json:"hooks"`
+}
+
+// HookConfig configures one built-in transformer. Type selects it and the
+// other fields are its options.
+type HookConfig struct {
+	Type  string   `json:"type"`            // "prepend-system", "drop-tool" or "rename-model"
+	Text  string   `json:"text,omitempty"`  // prepend-system: text added before the system prompt
+	Tools []string `json:"tools,omitempty"` // drop-tool: names of the tools to remove
+	From  string   `json:"from,omitempty"`  // rename-model: model requested by the client
+	To    string   `json:"to,omitempty"`    // rename-model: model to request instead
+}
+
+// DefaultHooksPath returns the default hooks file, ~/.clasp/hooks.json.
+func DefaultHooksPath() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("getting home directory: %w", err)
+	}
+	return filepath.Join(homeDir, ".clasp", "hooks.json"), nil
+}
+
+// LoadHooks reads a hooks file and builds its transformer chain. A missing
+// file is not an error and returns an empty chain.
+func LoadHooks(path string) ([]RequestTransformer, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+
+	var cfg HooksConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	chain := make([]RequestTransformer, 0, len(cfg.Hooks))
+	for i, hook := range cfg.Hooks {
+		t, err := NewRequestTransformer(hook)
+		if err != nil {
+			return nil, fmt.Errorf("hook %d in %s: %w", i+1, path, err)
+		}
+		chain = append(chain, t)
+	}
+	return chain, nil
+}
+
+// NewRequestTransformer creates a built-in transformer from its configuration.
+func NewRequestTransformer(hook HookConfig) (RequestTransformer, error) {
+	switch hook.Type {
+	case "prepend-system":
+		if hook.Text == "" {
+			return nil, fmt.Errorf("prepend-system requires text")
+		}
+		return &prependSystemTransformer{text: hook.Text}, nil
+	case "drop-tool":
+		if len(hook.Tools) == 0 {
+			return nil, fmt.Errorf("drop-tool requires tools")
+		}
+		names := make(map[string]bool, len(hook.Tools))
+		for _, name := range hook.Tools {
+			names[name] = true
+		}
+		return &dropToolTransformer{names: names}, nil
+	case "rename-model":
+		if hook.From == "" || hook.To == "" {
+			return nil, fmt.Errorf("rename-model requires from and to")
+		}
+		return &renameModelTransformer{from: hook.From, to: hook.To}, nil
+	default:
+		return nil, fmt.Errorf("unknown hook type %q", hook.Type)
+	}
+}
+
+// applyRequestTransformers runs the configured transformer chain on req.
+func (h *Handler) applyRequestTransformers(req *models.AnthropicRequest) *requestError {
+	for _, t := range h.requestTransformers {
+		if err := t.Transform(req); err != nil {
+			return &requestError{
+				statusCode: http.StatusBadRequest,
+				errType:    "invalid_request_error",
+				message:    fmt.Sprintf("Request hook %s failed: %v", t.Name(), err),
+			}
+		}
+	}
+	return nil
+}
+
+// prependSystemTransformer adds text before the system prompt.
+type prependSystemTransformer struct {
+	text string
+}
+
+func (t *prependSystemTransformer) Name() string { return "prepend-system" }
+
+func (t *prependSystemTransformer) Transform(req *models.AnthropicRequest) error {
+	switch system := req.System.(type) {
+	case nil:
+		req.System = t.text
+	case string:
+		if system == "" {
+			req.System = t.text
+		} else {
+			req.System = t.text + "\n\n" + system
+		}
+	case []interface{}:
+		block := map[string]interface{}{"type": "text", "text": t.text}
+		req.System = append([]interface{}{block}, system...)
+	default:
+		return fmt.Errorf("unsupported system prompt type %T", req.System)
+	}
+	return nil
+}
+
+// dropToolTransformer removes tools by name. A tool_choice that forces a
+// removed tool, or any tool_choice once no tools remain, is cleared too.
+type dropToolTransformer struct {
+	names map[string]bool
+}
+
+func (t *dropToolTransformer) Name() string { return "drop-tool" }
+
+func (t *dropToolTransformer) Transform(req *models.AnthropicRequest) error {
+	if len(req.Tools) == 0 {
+		return nil
+	}
+
+	var tools []models.AnthropicTool
+	for _, tool := range req.Tools {
+		if !t.names[tool.Name] {
+			tools = append(tools, tool)
+		}
+	}
+	req.Tools = tools
+
+	if choice, ok := req.ToolChoice.(map[string]interface{}); ok {
+		name, _ := choice["name"].(string)
+		if len(tools) == 0 || (choice["type"] == "tool" && t.names[name]) {
+			req.ToolChoice = nil
+		}
+	}
+	return nil
+}
+
+// renameModelTransformer replaces the requested model. Matching is
+// case-insensitive and happens before alias resolution.
+type renameModelTransformer struct {
+	from, to string
+}
+
+func (t *renameModelTransformer) Name() string { return "rename-model" }
+
+func (t *renameModelTransformer) Transform(req *models.AnthropicRequest) error {
+	if strings.EqualFold(req.Model, t.from) {
+		req.Model = t.to
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+// Package proxy implements the HTTP proxy server.
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// IPFilterConfig holds IP allowlist/denylist configuration.
+type IPFilterConfig struct {
+	// Allowlist restricts access to these CIDRs. Empty allows every address not denied.
+	Allowlist []string
+	// Denylist rejects these CIDRs. It takes precedence over the allowlist.
+	Denylist []string
+	// TrustProxyHeaders takes the client IP from X-Forwarded-For / X-Real-IP.
+	// Only enable this behind a reverse proxy that sets these headers.
+	TrustProxyHeaders bool
+	// AllowAnonymousHealth exempts /health from filtering.
+	AllowAnonymousHealth bool
+	// AllowAnonymousMetrics exempts /metrics endpoints from filtering.
+	AllowAnonymousMetrics bool
+	// AnonymousEndpoints exempts further paths from filtering.
+	AnonymousEndpoints []string
+}
+
+// IPFilter decides whether a client IP may reach the proxy.
+type IPFilter struct {
+	allow        []*net.IPNet
+	deny         []*net.IPNet
+	trustHeaders bool
+	anonymous    []string // Never nil: the filter has no default anonymous endpoints
+	config       *IPFilterConfig
+}
+
+// NewIPFilter creates an IP filter, parsing the configured CIDRs.
+func NewIPFilter(config *IPFilterConfig) (*IPFilter, error) {
+	f := &IPFilter{
+		trustHeaders: config.TrustProxyHeaders,
+		anonymous:    append([]string{}, config.AnonymousEndpoints...),
+		config:       config,
+	}
+	for _, cidr := range config.Allowlist {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid allowlist entry %q: %w", cidr, err)
+		}
+		f.allow = append(f.allow, ipNet)
+	}
+	for _, cidr := range config.Denylist {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid denylist entry %q: %w", cidr, err)
+		}
+		f.deny = append(f.deny, ipNet)
+	}
+	return f, nil
+}
+
+// Allowed reports whether ip may reach the proxy.
+func (f *IPFilter) Allowed(ip net.IP) bool {
+	if ip == nil {
+		return len(f.allow) == 0 && len(f.deny) == 0
+	}
+	for _, ipNet := range f.deny {
+		if ipNet.Contains(ip) {
+			return false
+		}
+	}
+	if len(f.allow) == 0 {
+		return true
+	}
+	for _, ipNet := range f.allow {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP returns the IP address of the client that sent r.
+// When proxy headers are trusted, the last X-Forwarded-For entry is used, then
+// X-Real-IP, then the remote address. The last entry is the one added by the
+// reverse proxy in front of CLASP; earlier entries come from the client and
+// can be forged.
+func (f *IPFilter) ClientIP(r *http.Request) net.IP {
+	if f.trustHeaders {
+		if values := r.Header.Values("X-Forwarded-For"); len(values) > 0 {
+			entries := strings.Split(strings.Join(values, ","), ",")
+			if ip := net.ParseIP(strings.TrimSpace(entries[len(entries)-1])); ip != nil {
+				return ip
+			}
+		}
+		if ip := net.ParseIP(strings.TrimSpace(r.Header.Get("X-Real-IP"))); ip != nil {
+			return ip
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return net.ParseIP(host)
+}
+
+// IPFilterMiddleware creates a middleware that rejects requests from
+// disallowed IPs with 403. Health, metrics and anonymous endpoints follow the
+// same anonymity settings as authentication, so only their read-only GET and
+// HEAD requests skip the filter.
+func IPFilterMiddleware(filter *IPFilter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			path := r.URL.Path
+			if allowsAnonymous(r, filter.anonymous, filter.config.AllowAnonymousHealth, filter.config.AllowAnonymousMetrics) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ip := filter.ClientIP(r)
+			if !filter.Allowed(ip) {
+				log.Printf("[CLASP] Rejected request from %s to %s (IP filter)", ip, path)
+				writeIPFilterError(w)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// writeIPFilterError writes an Anthropic-formatted 403 response.
+func writeIPFilterError(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusForbidden)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"type": "error",
+		"error": map[string]string{
+			"type":    "permission_error",
+			"message": "Access from this IP address is not allowed",
+		},
+	})
+}
@@ -0,0 +1,90 @@
+// Package proxy implements the HTTP proxy server.
+package proxy
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// Uptime returns the time since the handler started or its metrics were
+// last reset.
+func (m *Metrics) Uptime() time.Duration {
+	m.startMu.RLock()
+	defer m.startMu.RUnlock()
+	return time.Since(m.StartTime)
+}
+
+// Reset zeroes the request counters and restarts the uptime clock.
+func (m *Metrics) Reset() {
+	m.startMu.Lock()
+	defer m.startMu.Unlock()
+
+	for _, counter := range []*int64{
+		&m.TotalRequests, &m.SuccessRequests, &m.ErrorRequests, &m.StreamRequests,
+		&m.ToolCallRequests, &m.TotalLatencyMs, &m.FallbackAttempts, &m.FallbackSuccesses,
+		&m.CompactionHits, &m.CompactionMisses, &m.CompactionResets, &m.StreamClientAborts, &m.ThinkingTokens,
+		&m.SlowFallbackRaces, &m.SlowFallbackWins, &m.CoalescedRequests,
+	} {
+		atomic.StoreInt64(counter, 0)
+	}
+	m.fallbacks.reset()
+	m.StartTime = time.Now()
+}
+
+// handleMetricsReset handles POST /metrics?action=reset. It zeroes the
+// request metrics along with the rate limiter, cache, retry budget and queue
+// statistics. Cost data is reset too unless keep_costs=true. Cached responses
+// and queued requests are kept.
+func (h *Handler) handleMetricsReset(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("action") != "reset" {
+		h.writeErrorResponse(w, http.StatusBadRequest, "invalid_request_error", "Unknown action - use action=reset")
+		return
+	}
+	keepCosts := query.Get("keep_costs") == "true" || query.Get("keep_costs") == "1"
+
+	h.metrics.Reset()
+	reset := []string{"requests"}
+	if h.rateLimiter != nil {
+		h.rateLimiter.ResetStats()
+		reset = append(reset, "rate_limit")
+	}
+	if h.cache != nil {
+		h.cache.ResetStats()
+		reset = append(reset, "cache")
+	}
+	if h.semanticCache != nil {
+		h.semanticCache.ResetStats()
+		reset = append(reset, "semantic_cache")
+	}
+	if h.negativeCache != nil {
+		h.negativeCache.ResetStats()
+		reset = append(reset, "negative_cache")
+	}
+	if h.promptCache != nil {
+		h.promptCache.ResetStats()
+		reset = append(reset, "prompt_cache")
+	}
+	if h.retryBudget != nil {
+		h.retryBudget.ResetStats()
+		reset = append(reset, "retry_budget")
+	}
+	if h.queue != nil {
+		h.queue.ResetStats()
+		reset = append(reset, "queue")
+	}
+	if h.costTracker != nil && !keepCosts {
+		h.costTracker.Reset()
+		reset = append(reset, "costs")
+	}
+	log.Printf("[CLASP] Metrics reset: %v", reset)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "ok",
+		"reset":  reset,
+	})
+}
@@ -0,0 +1,170 @@
+// Package proxy implements the HTTP proxy server.
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/jedarden/clasp/internal/setup"
+)
+
+// maxModelSuggestions bounds the closest matches named in a model-not-found
+// error.
+const maxModelSuggestions = 3
+
+// isModelNotFound reports whether an upstream error response says the
+// requested model does not exist.
+func isModelNotFound(status int, body []byte) bool {
+	if status != http.StatusBadRequest && status != http.StatusNotFound {
+		return false
+	}
+	be, ok := parseBodyError(body)
+	if !ok {
+		return false
+	}
+	kind := strings.ToLower(be.Type + " " + be.Code)
+	message := strings.ToLower(be.Message)
+	if strings.Contains(kind, "model_not_found") {
+		return true
+	}
+	if !strings.Contains(message, "model") {
+		return false
+	}
+	if strings.Contains(kind, "not_found") {
+		return true
+	}
+	for _, phrase := range []string{"not found", "does not exist", "not exist", "unknown model", "invalid model", "no such model", "not supported"} {
+		if strings.Contains(message, phrase) {
+			return true
+		}
+	}
+	return false
+}
+
+// availableModels returns the models CLASP knows for the provider followed by
+// the configured aliases, without duplicates.
+func (h *Handler) availableModels(providerName string) []string {
+	seen := make(map[string]bool)
+	var available []string
+	add := func(model string) {
+		if model != "" && !seen[model] {
+			seen[model] = true
+			available = append(available, model)
+		}
+	}
+	for _, m := range setup.GetKnownModels(providerName) {
+		add(m.ID)
+	}
+	aliases := h.cfg.GetAliases()
+	names := make([]string, 0, len(aliases))
+	for alias := range aliases {
+		names = append(names, alias)
+	}
+	sort.Strings(names)
+	for _, alias := range names {
+		add(alias)
+	}
+	return available
+}
+
+// suggestModels returns up to maxModelSuggestions of the candidates closest to
+// model by edit distance, ignoring ones too different to be a likely typo.
+func suggestModels(model string, candidates []string) []string {
+	target := strings.ToLower(model)
+	maxDistance := len(target) / 2
+	if maxDistance < 2 {
+		maxDistance = 2
+	}
+
+	type match struct {
+		model    string
+		distance int
+	}
+	var matches []match
+	for _, c := range candidates {
+		if d := levenshtein(target, strings.ToLower(c)); d <= maxDistance {
+			matches = append(matches, match{c, d})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].distance < matches[j].distance })
+	if len(matches) > maxModelSuggestions {
+		matches = matches[:maxModelSuggestions]
+	}
+	suggestions := make([]string, len(matches))
+	for i, m := range matches {
+		suggestions[i] = m.model
+	}
+	return suggestions
+}
+
+// levenshtein returns the edit distance between a and b.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = minInt(prev[j]+1, minInt(curr[j-1]+1, prev[j-1]+cost))
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// modelNotFoundBody rewrites a model-not-found upstream error as an Anthropic
+// error that names the closest known models and lists the available ones. It
+// returns false when body is not a model-not-found error.
+func (h *Handler) modelNotFoundBody(status int, body []byte, model, providerName string) ([]byte, bool) {
+	if model == "" || !isModelNotFound(status, body) {
+		return nil, false
+	}
+	be, _ := parseBodyError(body)
+	available := h.availableModels(providerName)
+	suggestions := suggestModels(model, available)
+
+	message := fmt.Sprintf("Model %q was not found", model)
+	if providerName != "" {
+		message += fmt.Sprintf(" on provider %s", providerName)
+	}
+	if len(suggestions) > 0 {
+		message += fmt.Sprintf(". Did you mean: %s?", strings.Join(suggestions, ", "))
+	}
+	message += fmt.Sprintf(" (upstream: %s)", be.Message)
+
+	errBody := map[string]interface{}{
+		"type":    anthropicErrorType(status),
+		"message": message,
+	}
+	if len(suggestions) > 0 {
+		errBody["suggestions"] = suggestions
+	}
+	if available != nil {
+		errBody["available_models"] = available
+	}
+	enriched, err := json.Marshal(map[string]interface{}{
+		"type":  "error",
+		"error": errBody,
+	})
+	if err != nil {
+		return nil, false
+	}
+	return enriched, true
+}
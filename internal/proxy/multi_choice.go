@@ -0,0 +1,106 @@
+// Package proxy implements the HTTP proxy server.
+package proxy
+
+import (
+	"encoding/json"
+	"log"
+
+	"github.com/jedarden/clasp/internal/translator"
+	"github.com/jedarden/clasp/pkg/models"
+)
+
+// Multi-choice modes for requests with n>1 (CLASP_MULTI_CHOICE).
+const (
+	multiChoiceReject = "reject" // Reject n>1 with a 400
+	multiChoiceWarn   = "warn"   // Forward n, keep the first choice and log the discarded ones
+	multiChoiceReturn = "return" // Forward n, return extra choices in clasp_alternatives
+)
+
+// chatCompletionChoice is a choice in a non-streaming Chat Completions response.
+type chatCompletionChoice struct {
+	Message struct {
+		Role             string `json:"role"`
+		Content          string `json:"content"`
+		ReasoningContent string `json:"reasoning_content"` // DeepSeek and other reasoning models
+		ToolCalls        []struct {
+			ID       string `json:"id"`
+			Type     string `json:"type"`
+			Function struct {
+				Name      string `json:"name"`
+				Arguments string `json:"arguments"`
+			} `json:"function"`
+		} `json:"tool_calls"`
+	} `json:"message"`
+	FinishReason string `json:"finish_reason"`
+}
+
+// anthropicContent converts the choice's message to Anthropic content blocks.
+// With withThinking set, reasoning_content becomes a leading thinking block. A
+// content filter stop adds a trailing refusal block.
+func (c *chatCompletionChoice) anthropicContent(withThinking bool) []models.AnthropicContentBlock {
+	var content []models.AnthropicContentBlock
+
+	// Thinking comes before text, as in Anthropic responses
+	if withThinking && c.Message.ReasoningContent != "" {
+		content = append(content, models.AnthropicContentBlock{
+			Type:     "thinking",
+			Thinking: c.Message.ReasoningContent,
+		})
+	}
+
+	// Add text content
+	if c.Message.Content != "" {
+		content = append(content, models.AnthropicContentBlock{
+			Type: "text",
+			Text: c.Message.Content,
+		})
+	}
+
+	// Add tool calls
+	for _, tc := range c.Message.ToolCalls {
+		var input interface{}
+		_ = json.Unmarshal([]byte(translator.StripJSONCodeFence(tc.Function.Arguments)), &input)
+
+		content = append(content, models.AnthropicContentBlock{
+			Type:  "tool_use",
+			ID:    tc.ID,
+			Name:  tc.Function.Name,
+			Input: input,
+		})
+	}
+
+	// Explain content filter stops, which map to end_turn
+	if translator.IsContentFilterReason(c.FinishReason) {
+		content = append(content, translator.RefusalBlock(c.FinishReason))
+	}
+
+	return content
+}
+
+// handleExtraChoices deals with choices beyond the first: in return mode they are
+// attached as clasp_alternatives, otherwise they are discarded with a warning.
+func (h *Handler) handleExtraChoices(anthropicResp *models.AnthropicResponse, extra []chatCompletionChoice, withThinking bool) {
+	if h.cfg.MultiChoice != multiChoiceReturn {
+		log.Printf("[CLASP WARNING] Upstream returned %d choices; discarding %d (set CLASP_MULTI_CHOICE=return to keep them)",
+			len(extra)+1, len(extra))
+		return
+	}
+
+	for i := range extra {
+		anthropicResp.Alternatives = append(anthropicResp.Alternatives, models.AnthropicAlternative{
+			Content:    extra[i].anthropicContent(withThinking),
+			StopReason: h.translatorOpts.MapFinishReason(extra[i].FinishReason),
+		})
+	}
+}
+
+// emitsReasoningContent reports whether reasoning_content from the target
+// model is returned as thinking: always with CLASP_REASONING_CONTENT, and
+// otherwise only for models the capability registry lists as reasoning models.
+func (h *Handler) emitsReasoningContent(targetModel string) bool {
+	if h.cfg.ReasoningContent {
+		return true
+	}
+	caps, _ := translator.LookupCapabilities(targetModel)
+	return caps.Thinking
+}
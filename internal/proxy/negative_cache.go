@@ -0,0 +1,181 @@
+// Package proxy implements the HTTP proxy server.
+package proxy
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jedarden/clasp/pkg/models"
+)
+
+// negativeCacheMaxSize bounds the number of cached upstream errors.
+const negativeCacheMaxSize = 1000
+
+// negativeCacheStatuses are the upstream error statuses that depend only on
+// the request body, so an identical request would fail the same way. Server
+// errors and rate limits are transient and never cached.
+var negativeCacheStatuses = map[int]bool{
+	http.StatusBadRequest:            true,
+	http.StatusNotFound:              true,
+	http.StatusRequestEntityTooLarge: true,
+	http.StatusUnprocessableEntity:   true,
+}
+
+// negativeEntry is a cached upstream error response.
+type negativeEntry struct {
+	status  int
+	body    []byte
+	expires time.Time
+}
+
+// NegativeCache remembers upstream errors for requests that cannot succeed,
+// so identical requests fail fast without calling the upstream again.
+type NegativeCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]negativeEntry
+
+	hits   int64
+	misses int64
+}
+
+// NewNegativeCache creates a negative cache whose entries expire after ttl.
+func NewNegativeCache(ttl time.Duration) *NegativeCache {
+	return &NegativeCache{
+		ttl:     ttl,
+		entries: make(map[string]negativeEntry),
+	}
+}
+
+// Get returns the cached error status and body for key.
+func (nc *NegativeCache) Get(key string) (int, []byte, bool) {
+	nc.mu.Lock()
+	defer nc.mu.Unlock()
+
+	entry, ok := nc.entries[key]
+	if ok && time.Now().After(entry.expires) {
+		delete(nc.entries, key)
+		ok = false
+	}
+	if !ok {
+		atomic.AddInt64(&nc.misses, 1)
+		return 0, nil, false
+	}
+	atomic.AddInt64(&nc.hits, 1)
+	return entry.status, entry.body, true
+}
+
+// Set caches an upstream error for key if its status is cacheable, and
+// reports whether it was stored.
+func (nc *NegativeCache) Set(key string, status int, body []byte) bool {
+	if !negativeCacheStatuses[status] {
+		return false
+	}
+	nc.mu.Lock()
+	defer nc.mu.Unlock()
+
+	now := time.Now()
+	if _, exists := nc.entries[key]; !exists && len(nc.entries) >= negativeCacheMaxSize {
+		nc.evict(now)
+	}
+	nc.entries[key] = negativeEntry{status: status, body: body, expires: now.Add(nc.ttl)}
+	return true
+}
+
+// evict removes the expired entries, or the entry closest to expiring when
+// none has expired. The caller must hold nc.mu.
+func (nc *NegativeCache) evict(now time.Time) {
+	var oldestKey string
+	var oldest time.Time
+	for key, entry := range nc.entries {
+		if now.After(entry.expires) {
+			delete(nc.entries, key)
+			continue
+		}
+		if oldestKey == "" || entry.expires.Before(oldest) {
+			oldestKey, oldest = key, entry.expires
+		}
+	}
+	if len(nc.entries) >= negativeCacheMaxSize {
+		delete(nc.entries, oldestKey)
+	}
+}
+
+// Stats returns negative cache statistics.
+func (nc *NegativeCache) Stats() (size int, hits, misses int64) {
+	nc.mu.Lock()
+	size = len(nc.entries)
+	nc.mu.Unlock()
+	return size, atomic.LoadInt64(&nc.hits), atomic.LoadInt64(&nc.misses)
+}
+
+// ResetStats zeroes the hit and miss counters. Cached errors are kept.
+func (nc *NegativeCache) ResetStats() {
+	atomic.StoreInt64(&nc.hits, 0)
+	atomic.StoreInt64(&nc.misses, 0)
+}
+
+// SetNegativeCache sets the cache of upstream errors.
+func (h *Handler) SetNegativeCache(nc *NegativeCache) {
+	h.negativeCache = nc
+}
+
+// negativeCacheContextKey is the request context key for the negative cache
+// key of a request.
+type negativeCacheContextKey struct{}
+
+// negativeCacheKey hashes the complete request, so only identical requests
+// share a cached error.
+func negativeCacheKey(req *models.AnthropicRequest) (string, bool) {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return "", false
+	}
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:]), true
+}
+
+// checkNegativeCache serves a request the upstream recently rejected from the
+// negative cache and returns true. On a miss, the request's key is kept in the
+// returned request's context for storeNegativeCache.
+func (h *Handler) checkNegativeCache(w http.ResponseWriter, r *http.Request, req *models.AnthropicRequest) (*http.Request, bool) {
+	if h.negativeCache == nil {
+		return r, false
+	}
+	key, ok := negativeCacheKey(req)
+	if !ok {
+		return r, false
+	}
+
+	status, body, found := h.negativeCache.Get(key)
+	if !found {
+		return r.WithContext(context.WithValue(r.Context(), negativeCacheContextKey{}, key)), false
+	}
+
+	log.Printf("[CLASP] Negative cache HIT (%d)", status)
+	atomic.AddInt64(&h.metrics.ErrorRequests, 1)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-CLASP-Negative-Cache", "HIT")
+	w.WriteHeader(status)
+	_, _ = w.Write(body)
+	return r, true
+}
+
+// storeNegativeCache caches an upstream error for the request of ctx when the
+// status means an identical request would fail again.
+func (h *Handler) storeNegativeCache(ctx context.Context, status int, body []byte) {
+	key, _ := ctx.Value(negativeCacheContextKey{}).(string)
+	if key == "" || h.negativeCache == nil {
+		return
+	}
+	if h.negativeCache.Set(key, status, body) {
+		log.Printf("[CLASP] Negative cache: storing upstream %d for %v", status, h.negativeCache.ttl)
+	}
+}
@@ -0,0 +1,58 @@
+// Package proxy implements the HTTP proxy server.
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// passthroughHeadersContextKey is the request context key for the client
+// headers forwarded to the Anthropic API in passthrough mode.
+type passthroughHeadersContextKey struct{}
+
+// withPassthroughHeaders attaches the client headers named by
+// CLASP_PASSTHROUGH_FORWARD_HEADERS to the request context. Headers named by
+// CLASP_PASSTHROUGH_STRIP_HEADERS, or by the client's Connection header, are
+// never forwarded, and neither are credential headers, so the provider key
+// is never replaced by what the client sent to the proxy.
+func (h *Handler) withPassthroughHeaders(r *http.Request) *http.Request {
+	strip := make(map[string]bool, len(h.cfg.PassthroughStripHeaders))
+	for _, name := range h.cfg.PassthroughStripHeaders {
+		strip[http.CanonicalHeaderKey(name)] = true
+	}
+	for _, value := range r.Header.Values("Connection") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				strip[http.CanonicalHeaderKey(name)] = true
+			}
+		}
+	}
+
+	forwarded := make(http.Header)
+	for _, name := range h.cfg.PassthroughForwardHeaders {
+		key := http.CanonicalHeaderKey(name)
+		if strip[key] || isCredentialHeader(key) {
+			continue
+		}
+		if values := r.Header.Values(key); len(values) > 0 {
+			forwarded[key] = append([]string(nil), values...)
+		}
+	}
+	return r.WithContext(context.WithValue(r.Context(), passthroughHeadersContextKey{}, forwarded))
+}
+
+// applyPassthroughHeaders sets the forwarded client headers of a passthrough
+// request, then removes the stripped ones so they never reach the upstream.
+func (h *Handler) applyPassthroughHeaders(ctx context.Context, headers http.Header) {
+	forwarded, ok := ctx.Value(passthroughHeadersContextKey{}).(http.Header)
+	if !ok {
+		return
+	}
+	for key, values := range forwarded {
+		headers[key] = values
+	}
+	for _, name := range h.cfg.PassthroughStripHeaders {
+		headers.Del(name)
+	}
+}
@@ -0,0 +1,153 @@
+// Package proxy implements the HTTP proxy server.
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"sync/atomic"
+
+	"github.com/jedarden/clasp/pkg/models"
+)
+
+const (
+	// maxSSELineBytes bounds how much of a single SSE line the usage parser
+	// buffers. Longer lines are skipped rather than held in memory.
+	maxSSELineBytes = 1 << 20
+	// thinkingCharsPerToken approximates Anthropic tokenization for thinking
+	// text, which the API bills as output but doesn't count separately.
+	thinkingCharsPerToken = 4
+)
+
+// passthroughUsage collects usage from an Anthropic SSE stream as its bytes
+// are forwarded. It only reads copies of the bytes and never fails, so a
+// malformed or unexpected event can't affect the stream sent to the client.
+type passthroughUsage struct {
+	Model        string
+	InputTokens  int
+	OutputTokens int
+
+	// Prompt cache reads and writes, which Anthropic reports apart from
+	// InputTokens
+	CacheReadTokens     int
+	CacheCreationTokens int
+
+	// ThinkingChars is the length of streamed thinking text.
+	ThinkingChars int
+
+	line     []byte
+	skipping bool // Dropping the rest of an oversized line
+}
+
+// Write consumes forwarded stream bytes. It always reports len(p) written.
+func (u *passthroughUsage) Write(p []byte) (int, error) {
+	data := p
+	for len(data) > 0 {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			u.buffer(data)
+			break
+		}
+		u.buffer(data[:i])
+		if !u.skipping {
+			u.parseLine(u.line)
+		}
+		u.line = u.line[:0]
+		u.skipping = false
+		data = data[i+1:]
+	}
+	return len(p), nil
+}
+
+func (u *passthroughUsage) buffer(b []byte) {
+	if u.skipping {
+		return
+	}
+	if len(u.line)+len(b) > maxSSELineBytes {
+		u.line = u.line[:0]
+		u.skipping = true
+		return
+	}
+	u.line = append(u.line, b...)
+}
+
+// parseLine extracts usage from a "data:" line.
+func (u *passthroughUsage) parseLine(line []byte) {
+	line = bytes.TrimRight(line, "\r")
+	if !bytes.HasPrefix(line, []byte("data:")) {
+		return
+	}
+	payload := bytes.TrimSpace(line[len("data:"):])
+
+	var event struct {
+		Type    string `json:"type"`
+		Message *struct {
+			Model string                 `json:"model"`
+			Usage *models.AnthropicUsage `json:"usage"`
+		} `json:"message"`
+		Delta *struct {
+			Type     string `json:"type"`
+			Thinking string `json:"thinking"`
+		} `json:"delta"`
+		Usage *struct {
+			InputTokens int `json:"input_tokens"`
+			models.MessageDeltaUsage
+		} `json:"usage"`
+	}
+	if json.Unmarshal(payload, &event) != nil {
+		return
+	}
+
+	switch event.Type {
+	case "message_start":
+		if event.Message != nil {
+			u.Model = event.Message.Model
+			if event.Message.Usage != nil {
+				u.InputTokens = event.Message.Usage.InputTokens
+				u.OutputTokens = event.Message.Usage.OutputTokens
+				u.CacheReadTokens = event.Message.Usage.CacheReadInputTokens
+				u.CacheCreationTokens = event.Message.Usage.CacheCreationInputTokens
+			}
+		}
+	case "content_block_delta":
+		if event.Delta != nil && event.Delta.Type == "thinking_delta" {
+			u.ThinkingChars += len(event.Delta.Thinking)
+		}
+	case "message_delta":
+		// message_delta usage is cumulative
+		if event.Usage != nil {
+			if event.Usage.InputTokens > 0 {
+				u.InputTokens = event.Usage.InputTokens
+			}
+			if event.Usage.CacheReadInputTokens > 0 || event.Usage.CacheCreationInputTokens > 0 {
+				u.CacheReadTokens = event.Usage.CacheReadInputTokens
+				u.CacheCreationTokens = event.Usage.CacheCreationInputTokens
+			}
+			u.OutputTokens = event.Usage.OutputTokens
+		}
+	}
+}
+
+// ThinkingTokens estimates the output tokens spent on thinking.
+func (u *passthroughUsage) ThinkingTokens() int {
+	return (u.ThinkingChars + thinkingCharsPerToken - 1) / thinkingCharsPerToken
+}
+
+// recordPassthroughUsage records usage parsed from a passthrough stream.
+func (h *Handler) recordPassthroughUsage(ctx context.Context, u *passthroughUsage) {
+	if u.InputTokens == 0 && u.OutputTokens == 0 && u.CacheReadTokens == 0 && u.CacheCreationTokens == 0 {
+		return
+	}
+	auditUsage(ctx, u.InputTokens, u.OutputTokens)
+	if thinking := u.ThinkingTokens(); thinking > 0 {
+		atomic.AddInt64(&h.metrics.ThinkingTokens, int64(thinking))
+	}
+	if h.costTracker != nil {
+		h.costTracker.RecordAnthropicUsage("anthropic", u.Model, &models.AnthropicUsage{
+			InputTokens:              u.InputTokens,
+			OutputTokens:             u.OutputTokens,
+			CacheReadInputTokens:     u.CacheReadTokens,
+			CacheCreationInputTokens: u.CacheCreationTokens,
+		})
+	}
+}
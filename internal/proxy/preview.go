@@ -0,0 +1,93 @@
+// Package proxy implements the HTTP proxy server.
+package proxy
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/jedarden/clasp/internal/provider"
+	"github.com/jedarden/clasp/internal/secrets"
+	"github.com/jedarden/clasp/internal/translator"
+)
+
+// requestPreview describes the upstream request CLASP would send for a
+// /v1/messages request.
+type requestPreview struct {
+	Provider     string          `json:"provider"`
+	Model        string          `json:"model"`
+	TargetModel  string          `json:"target_model"`
+	EndpointURL  string          `json:"endpoint_url"`
+	EndpointType string          `json:"endpoint_type"`
+	Request      json.RawMessage `json:"request"`
+}
+
+// HandleMessagesPreview handles POST /v1/messages/preview. It runs a Messages
+// request through alias resolution, provider selection and translation, and
+// returns the upstream request with secrets masked instead of sending it.
+// Sessions are not continued, so Responses API requests are shown in full.
+func (h *Handler) HandleMessagesPreview(w http.ResponseWriter, r *http.Request) {
+	anthropicReq, reqErr := h.decodeRequest(w, r)
+	if reqErr != nil {
+		if reqErr.statusCode == http.StatusMethodNotAllowed {
+			w.Header().Set("Allow", "POST")
+		}
+		h.writeErrorResponse(w, reqErr.statusCode, reqErr.errType, reqErr.message)
+		return
+	}
+	defer r.Body.Close()
+
+	selectedProvider, targetModel := h.selectProviderAndModel(anthropicReq)
+	preview := requestPreview{
+		Provider:    selectedProvider.Name(),
+		Model:       anthropicReq.Model,
+		TargetModel: targetModel,
+	}
+
+	var reqBody []byte
+	if geminiProvider, ok := selectedProvider.(*provider.GeminiProvider); ok {
+		body, err := h.transformGeminiRequest(r.Context(), anthropicReq, targetModel)
+		if err != nil {
+			h.writeErrorResponse(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+			return
+		}
+		reqBody = body
+		preview.EndpointURL = geminiProvider.GetNativeEndpointURL(targetModel, anthropicReq.Stream)
+		preview.EndpointType = "gemini"
+	} else if !selectedProvider.RequiresTransformation() {
+		body, err := json.Marshal(anthropicReq)
+		if err != nil {
+			log.Printf("[CLASP] Error marshaling preview request: %v", err)
+			h.writeErrorResponse(w, http.StatusInternalServerError, "api_error", "Error preparing request")
+			return
+		}
+		reqBody = body
+		preview.EndpointURL = selectedProvider.GetEndpointURL()
+		preview.EndpointType = "passthrough"
+	} else {
+		if selectedProvider.Name() == "azure" && translator.RequiresResponsesAPI(targetModel) {
+			h.writeErrorResponse(w, http.StatusBadRequest, "invalid_request_error",
+				"Azure OpenAI does not support the Responses API. The model '"+targetModel+"' requires the Responses API (/v1/responses), which is only available via OpenAI or OpenRouter providers.")
+			return
+		}
+
+		endpointType := translator.GetEndpointType(targetModel)
+		body, err := h.transformRequest(r.Context(), anthropicReq, selectedProvider, targetModel, endpointType == translator.EndpointResponses, "", 0)
+		if err != nil {
+			h.writeErrorResponse(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+			return
+		}
+		reqBody = body
+		preview.EndpointType = endpointType.String()
+		if openaiProvider, ok := selectedProvider.(*provider.OpenAIProvider); ok {
+			// The model decides the endpoint; don't touch the shared provider's target model
+			preview.EndpointURL = openaiProvider.GetEndpointURLForModel(targetModel)
+		} else {
+			preview.EndpointURL = selectedProvider.GetEndpointURL()
+		}
+	}
+	preview.Request = secrets.MaskJSONSecrets(reqBody)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(preview)
+}
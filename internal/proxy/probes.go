@@ -0,0 +1,172 @@
+// Package proxy implements the HTTP proxy server.
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// readinessCacheTTL is how long an upstream reachability result is reused.
+	readinessCacheTTL = 10 * time.Second
+	// readinessProbeTimeout bounds the upstream reachability check.
+	readinessProbeTimeout = 5 * time.Second
+)
+
+// readinessState tracks whether the proxy should receive traffic. It is
+// shared across config reloads so draining and the cached check survive them.
+type readinessState struct {
+	draining int32
+	upstream upstreamCheck
+}
+
+// upstreamCheck caches the result of probing the primary provider.
+type upstreamCheck struct {
+	mu        sync.Mutex
+	checkedAt time.Time
+	reachable bool
+	lastError string
+}
+
+// result returns the cached result if it is younger than ttl, and otherwise
+// runs probe and caches its result.
+func (c *upstreamCheck) result(ttl time.Duration, probe func() (bool, string)) (reachable bool, checkedAt time.Time, lastError string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.checkedAt.IsZero() || time.Since(c.checkedAt) >= ttl {
+		c.reachable, c.lastError = probe()
+		c.checkedAt = time.Now()
+	}
+	return c.reachable, c.checkedAt, c.lastError
+}
+
+// last returns the cached result without probing. ok is false if the
+// provider hasn't been checked yet.
+func (c *upstreamCheck) last() (reachable, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.reachable, !c.checkedAt.IsZero()
+}
+
+// UpstreamHealthy reports whether the primary provider is healthy, based on
+// its circuit breaker and the latest health check. It never probes the
+// provider itself, and returns nil when nothing is known yet.
+func (h *Handler) UpstreamHealthy() *bool {
+	healthy := func(v bool) *bool { return &v }
+
+	if h.circuitBreakers != nil && h.circuitBreakers.Get(h.provider.Name()).State() != "closed" {
+		return healthy(false)
+	}
+	if h.healthChecker != nil {
+		if health := h.healthChecker.GetProviderHealth(string(h.cfg.Provider)); health != nil && !health.LastCheckTime.IsZero() {
+			return healthy(health.Healthy)
+		}
+	}
+	if reachable, ok := h.healthUpstream.last(); ok {
+		return healthy(reachable)
+	}
+	if reachable, ok := h.readiness.upstream.last(); ok {
+		return healthy(reachable)
+	}
+	return nil
+}
+
+// SetDraining marks the proxy as shutting down, so /readyz reports 503.
+func (h *Handler) SetDraining() {
+	atomic.StoreInt32(&h.readiness.draining, 1)
+}
+
+// HandleLivez reports that the process is alive. It never checks upstream,
+// so a slow or unreachable provider doesn't get the process restarted.
+func (h *Handler) HandleLivez(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "alive",
+		"uptime": h.metrics.Uptime().String(),
+	})
+}
+
+// HandleReadyz reports whether the proxy can serve requests. It returns 503
+// while shutting down, while the primary provider's circuit breaker is open,
+// or when the upstream provider can't be reached.
+func (h *Handler) HandleReadyz(w http.ResponseWriter, r *http.Request) {
+	response := map[string]interface{}{
+		"status":   "ready",
+		"provider": h.provider.Name(),
+	}
+	status := http.StatusOK
+	notReady := func(reason string) {
+		status = http.StatusServiceUnavailable
+		response["status"] = "not_ready"
+		response["reason"] = reason
+	}
+
+	switch {
+	case atomic.LoadInt32(&h.readiness.draining) == 1:
+		notReady("draining")
+	case h.circuitBreakers != nil && h.circuitBreakers.Get(h.provider.Name()).IsOpen():
+		notReady("circuit_breaker_open")
+	default:
+		if ok, errMsg := h.upstreamReachable(); !ok {
+			notReady("upstream_unreachable")
+			if errMsg != "" {
+				response["error"] = errMsg
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// upstreamReachable reports whether the primary provider can be reached. The
+// health checker's results are used when it is running; otherwise the
+// provider is probed at most once per readinessCacheTTL.
+func (h *Handler) upstreamReachable() (bool, string) {
+	if h.healthChecker != nil {
+		return h.healthChecker.IsHealthy(), ""
+	}
+
+	reachable, _, lastError := h.readiness.upstream.result(readinessCacheTTL, func() (bool, string) {
+		status, err := h.probeUpstream(readinessProbeTimeout)
+		if err != nil {
+			return false, err.Error()
+		}
+		return status < 500, ""
+	})
+	return reachable, lastError
+}
+
+// upstreamHealth checks the primary provider for /health, reusing the result
+// for HealthCheckUpstreamTTLSec. Unlike readiness, a rejected API key counts
+// as unreachable since no request would succeed.
+func (h *Handler) upstreamHealth() (bool, time.Time, string) {
+	ttl := time.Duration(h.cfg.HealthCheckUpstreamTTLSec) * time.Second
+	return h.healthUpstream.result(ttl, func() (bool, string) {
+		status, err := h.probeUpstream(time.Duration(h.cfg.HealthCheckTimeoutSec) * time.Second)
+		switch {
+		case err != nil:
+			return false, err.Error()
+		case status == http.StatusUnauthorized || status == http.StatusForbidden:
+			return false, fmt.Sprintf("upstream rejected the API key (status %d)", status)
+		case status >= 500:
+			return false, fmt.Sprintf("upstream returned status %d", status)
+		}
+		return true, ""
+	})
+}
+
+// probeUpstream sends a lightweight request to the primary provider. It isn't
+// tied to the incoming request, so a disconnecting client isn't cached as a
+// failure.
+func (h *Handler) probeUpstream(timeout time.Duration) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return probeProvider(ctx, h.client, h.provider, h.cfg.GetAPIKey())
+}
@@ -7,6 +7,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/jedarden/clasp/internal/config"
 	"github.com/jedarden/clasp/pkg/models"
 )
 
@@ -311,6 +312,22 @@ func TestGenerateCacheKey(t *testing.T) {
 		}
 	})
 
+	t.Run("key is a fixed-length hash", func(t *testing.T) {
+		for _, req := range []*models.AnthropicRequest{
+			{},
+			{Model: "x"},
+			{Model: "claude-3-opus-20240229", MaxTokens: 1000, Messages: []models.AnthropicMessage{{Role: "user", Content: "Hello"}}},
+		} {
+			key, ok := GenerateCacheKey(req)
+			if !ok {
+				t.Fatal("Expected key for deterministic request")
+			}
+			if len(key) != 64 {
+				t.Errorf("len(key) = %d, want 64 (hex SHA-256)", len(key))
+			}
+		}
+	})
+
 	t.Run("same request produces same key", func(t *testing.T) {
 		temp := 0.0
 		req1 := &models.AnthropicRequest{
@@ -354,6 +371,24 @@ func TestGenerateCacheKey(t *testing.T) {
 	})
 }
 
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		s    string
+		n    int
+		want string
+	}{
+		{"", 16, ""},
+		{"abc", 16, "abc"},
+		{"0123456789abcdef", 16, "0123456789abcdef"},
+		{"0123456789abcdef0123", 16, "0123456789abcdef"},
+	}
+	for _, tt := range tests {
+		if got := truncate(tt.s, tt.n); got != tt.want {
+			t.Errorf("truncate(%q, %d) = %q, want %q", tt.s, tt.n, got, tt.want)
+		}
+	}
+}
+
 // ===== Auth Tests =====
 
 func TestAuthMiddleware(t *testing.T) {
@@ -853,6 +888,239 @@ func TestCircuitBreaker(t *testing.T) {
 	})
 }
 
+// ===== Retry Tests =====
+
+func TestRetryDelay(t *testing.T) {
+	h := &Handler{cfg: &config.Config{RetryBaseDelayMs: 100, RetryMaxDelayMs: 1000}}
+
+	want := []time.Duration{
+		100 * time.Millisecond,
+		200 * time.Millisecond,
+		400 * time.Millisecond,
+		800 * time.Millisecond,
+		1000 * time.Millisecond, // capped
+		1000 * time.Millisecond,
+	}
+	for attempt, w := range want {
+		if got := h.retryDelay(attempt); got != w {
+			t.Errorf("retryDelay(%d) = %v, want %v", attempt, got, w)
+		}
+	}
+
+	// A large attempt count must not overflow past the cap
+	if got := h.retryDelay(100); got != time.Second {
+		t.Errorf("retryDelay(100) = %v, want 1s", got)
+	}
+}
+
+func TestRetryDelay_Jitter(t *testing.T) {
+	h := &Handler{cfg: &config.Config{RetryBaseDelayMs: 100, RetryMaxDelayMs: 1000, RetryJitter: true}}
+
+	for attempt := 0; attempt < 6; attempt++ {
+		bound := 100 * time.Millisecond << attempt
+		if bound > time.Second {
+			bound = time.Second
+		}
+
+		seen := make(map[time.Duration]bool)
+		for i := 0; i < 50; i++ {
+			d := h.retryDelay(attempt)
+			if d < 0 || d > bound {
+				t.Fatalf("retryDelay(%d) = %v, want within [0, %v]", attempt, d, bound)
+			}
+			seen[d] = true
+		}
+		if len(seen) < 2 {
+			t.Errorf("retryDelay(%d) returned the same delay 50 times; expected jitter", attempt)
+		}
+	}
+}
+
+// ===== Endpoint Selector Tests =====
+
+func TestEndpointSelector(t *testing.T) {
+	const us, eu, ap = "https://us.example.com/v1", "https://eu.example.com/v1", "https://ap.example.com/v1"
+
+	t.Run("measures every endpoint first", func(t *testing.T) {
+		s := NewEndpointSelector("https://api.example.com/v1", []string{us, eu})
+		s.RecordSuccess(us, 10*time.Millisecond)
+		if got := s.Select(); got != eu {
+			t.Errorf("Select() = %s, want unmeasured endpoint %s", got, eu)
+		}
+	})
+
+	t.Run("prefers lower latency", func(t *testing.T) {
+		s := NewEndpointSelector("https://api.example.com/v1", []string{us, eu, ap})
+		s.RecordSuccess(us, 200*time.Millisecond)
+		s.RecordSuccess(eu, 50*time.Millisecond)
+		s.RecordSuccess(ap, 120*time.Millisecond)
+		if got := s.Select(); got != eu {
+			t.Errorf("Select() = %s, want fastest endpoint %s", got, eu)
+		}
+
+		// EWMA follows a sustained slowdown
+		for i := 0; i < 10; i++ {
+			s.RecordSuccess(eu, 400*time.Millisecond)
+		}
+		if got := s.Select(); got != ap {
+			t.Errorf("Select() = %s, want %s after eu slowed down", got, ap)
+		}
+	})
+
+	t.Run("avoids unhealthy endpoint", func(t *testing.T) {
+		s := NewEndpointSelector("https://api.example.com/v1", []string{us, eu})
+		s.RecordSuccess(us, 10*time.Millisecond)
+		s.RecordSuccess(eu, 100*time.Millisecond)
+		s.RecordFailure(us)
+		if got := s.Select(); got != eu {
+			t.Errorf("Select() = %s, want healthy endpoint %s", got, eu)
+		}
+
+		// A success clears the cooldown
+		s.RecordSuccess(us, 10*time.Millisecond)
+		if got := s.Select(); got != us {
+			t.Errorf("Select() = %s, want recovered endpoint %s", got, us)
+		}
+	})
+
+	t.Run("all unhealthy uses soonest recovery", func(t *testing.T) {
+		s := NewEndpointSelector("https://api.example.com/v1", []string{us, eu})
+		s.RecordFailure(eu)
+		time.Sleep(time.Millisecond)
+		s.RecordFailure(us)
+		if got := s.Select(); got != eu {
+			t.Errorf("Select() = %s, want %s", got, eu)
+		}
+	})
+
+	t.Run("rewrites base URL", func(t *testing.T) {
+		s := NewEndpointSelector("https://api.example.com/v1/", []string{us})
+		if got := s.Rewrite("https://api.example.com/v1/chat/completions", us); got != us+"/chat/completions" {
+			t.Errorf("Rewrite() = %s", got)
+		}
+		if got := s.Rewrite("https://other.example.com/v1/chat/completions", us); got != "https://other.example.com/v1/chat/completions" {
+			t.Errorf("Rewrite() changed a URL outside the base URL: %s", got)
+		}
+	})
+
+	t.Run("inherits shared endpoints", func(t *testing.T) {
+		prev := NewEndpointSelector("https://api.example.com/v1", []string{us, eu})
+		prev.RecordSuccess(us, 200*time.Millisecond)
+		prev.RecordSuccess(eu, 50*time.Millisecond)
+
+		s := NewEndpointSelector("https://api.example.com/v1", []string{us, eu, ap})
+		s.inherit(prev)
+		if got := s.Select(); got != ap {
+			t.Errorf("Select() = %s, want new endpoint %s measured first", got, ap)
+		}
+		s.RecordSuccess(ap, 100*time.Millisecond)
+		if got := s.Select(); got != eu {
+			t.Errorf("Select() = %s, want inherited fastest endpoint %s", got, eu)
+		}
+	})
+}
+
+// ===== Sticky Routing Tests =====
+
+func TestStickyRouter(t *testing.T) {
+	t.Run("get and set", func(t *testing.T) {
+		sr := NewStickyRouter(10, time.Hour)
+		if _, ok := sr.Get("conv-1"); ok {
+			t.Fatal("expected no route for unknown conversation")
+		}
+		sr.Set("conv-1", routeFallback)
+		if route, ok := sr.Get("conv-1"); !ok || route != routeFallback {
+			t.Errorf("expected fallback route, got %v (ok=%v)", route, ok)
+		}
+		sr.Set("conv-1", routePrimary)
+		if route, _ := sr.Get("conv-1"); route != routePrimary {
+			t.Errorf("expected route to be updated to primary, got %v", route)
+		}
+	})
+
+	t.Run("empty conversation ID is ignored", func(t *testing.T) {
+		sr := NewStickyRouter(10, time.Hour)
+		sr.Set("", routeFallback)
+		if sr.Len() != 0 {
+			t.Errorf("expected no entries, got %d", sr.Len())
+		}
+	})
+
+	t.Run("LRU eviction", func(t *testing.T) {
+		sr := NewStickyRouter(2, time.Hour)
+		sr.Set("a", routePrimary)
+		sr.Set("b", routePrimary)
+		sr.Set("a", routeFallback) // refresh a
+		sr.Set("c", routePrimary)  // evicts b
+		if _, ok := sr.Get("b"); ok {
+			t.Error("expected b to be evicted")
+		}
+		if _, ok := sr.Get("a"); !ok {
+			t.Error("expected a to be retained")
+		}
+		if sr.Len() != 2 {
+			t.Errorf("expected 2 entries, got %d", sr.Len())
+		}
+	})
+
+	t.Run("TTL expiration", func(t *testing.T) {
+		sr := NewStickyRouter(10, 10*time.Millisecond)
+		sr.Set("conv-1", routeFallback)
+		time.Sleep(20 * time.Millisecond)
+		if _, ok := sr.Get("conv-1"); ok {
+			t.Error("expected pin to expire")
+		}
+		if sr.Len() != 0 {
+			t.Errorf("expected expired entry to be removed, got %d", sr.Len())
+		}
+	})
+}
+
+// ===== Upstream Transport Tests =====
+
+func TestNewUpstreamTransport(t *testing.T) {
+	h1 := newUpstreamTransport("h1")
+	if h1.ForceAttemptHTTP2 {
+		t.Error("h1: expected ForceAttemptHTTP2 to be false")
+	}
+	if h1.TLSNextProto == nil || len(h1.TLSNextProto) != 0 {
+		t.Error("h1: expected an empty TLSNextProto map to disable HTTP/2")
+	}
+	if h1.TLSClientConfig == nil || len(h1.TLSClientConfig.NextProtos) != 1 || h1.TLSClientConfig.NextProtos[0] != "http/1.1" {
+		t.Errorf("h1: expected NextProtos [http/1.1], got %+v", h1.TLSClientConfig)
+	}
+
+	h2 := newUpstreamTransport("h2")
+	if !h2.ForceAttemptHTTP2 || h2.TLSNextProto != nil {
+		t.Error("h2: expected ForceAttemptHTTP2 with the default TLSNextProto")
+	}
+
+	auto := newUpstreamTransport("auto")
+	if auto.ForceAttemptHTTP2 || auto.TLSNextProto != nil || auto.TLSClientConfig != nil {
+		t.Error("auto: expected the default transport settings")
+	}
+
+	// The negotiated protocol against a server that supports both
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+	roots := server.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs
+
+	for version, wantMajor := range map[string]int{"h1": 1, "h2": 2} {
+		transport := newUpstreamTransport(version)
+		transport.TLSClientConfig.RootCAs = roots
+		resp, err := (&http.Client{Transport: transport}).Get(server.URL)
+		if err != nil {
+			t.Fatalf("%s: request failed: %v", version, err)
+		}
+		resp.Body.Close()
+		if resp.ProtoMajor != wantMajor {
+			t.Errorf("%s: expected HTTP/%d, got %s", version, wantMajor, resp.Proto)
+		}
+	}
+}
+
 func TestDefaultQueueConfig(t *testing.T) {
 	config := DefaultQueueConfig()
 
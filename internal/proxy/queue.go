@@ -2,10 +2,14 @@
 package proxy
 
 import (
-	"container/list"
+	"container/heap"
 	"context"
 	"errors"
+	"fmt"
+	"log"
 	"net/http"
+	"os"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -18,6 +22,7 @@ type QueueConfig struct {
 	MaxWait    time.Duration // Maximum time a request can wait in queue
 	RetryDelay time.Duration // Delay between retry attempts
 	MaxRetries int           // Maximum number of retries per request
+	PersistDir string        // Directory to persist queued requests in; empty disables persistence
 }
 
 // DefaultQueueConfig returns the default queue configuration.
@@ -31,12 +36,90 @@ func DefaultQueueConfig() *QueueConfig {
 	}
 }
 
+// PriorityHeader lets clients set a request's queue priority.
+const PriorityHeader = "X-CLASP-Priority"
+
+// QueuePriority orders dequeuing; higher priorities are dequeued first.
+type QueuePriority int
+
+// Queue priority levels.
+const (
+	PriorityLow QueuePriority = iota
+	PriorityNormal
+	PriorityHigh
+)
+
+// queuePriorities lists the priority levels from highest to lowest.
+var queuePriorities = []QueuePriority{PriorityHigh, PriorityNormal, PriorityLow}
+
+// String returns the priority's header value.
+func (p QueuePriority) String() string {
+	switch p {
+	case PriorityHigh:
+		return "high"
+	case PriorityLow:
+		return "low"
+	default:
+		return "normal"
+	}
+}
+
+// ParsePriority parses an X-CLASP-Priority value. Empty or unknown values
+// are treated as normal priority.
+func ParsePriority(value string) QueuePriority {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "high":
+		return PriorityHigh
+	case "low":
+		return PriorityLow
+	default:
+		return PriorityNormal
+	}
+}
+
+// RequestPriority returns the queue priority requested via X-CLASP-Priority.
+func RequestPriority(r *http.Request) QueuePriority {
+	return ParsePriority(r.Header.Get(PriorityHeader))
+}
+
 // QueuedRequest represents a request waiting in the queue.
 type QueuedRequest struct {
 	Body       []byte
+	Priority   QueuePriority
 	CreatedAt  time.Time
 	RetryCount int
 	ResultCh   chan QueueResult
+
+	seq    uint64        // Enqueue order, keeping FIFO within a priority
+	file   string        // Persisted copy, removed when the request leaves the queue
+	client *queuedClient // Client waiting for the response; nil for replayed requests
+}
+
+// requestHeap orders queued requests by priority, then by enqueue order.
+type requestHeap []*QueuedRequest
+
+func (h requestHeap) Len() int { return len(h) }
+
+func (h requestHeap) Less(i, j int) bool {
+	if h[i].Priority != h[j].Priority {
+		return h[i].Priority > h[j].Priority
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h requestHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *requestHeap) Push(x interface{}) {
+	*h = append(*h, x.(*QueuedRequest))
+}
+
+func (h *requestHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	req := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return req
 }
 
 // QueueResult holds the result of a queued request.
@@ -48,11 +131,17 @@ type QueueResult struct {
 // RequestQueue manages request queuing during provider outages.
 type RequestQueue struct {
 	config *QueueConfig
-	queue  *list.List
+	queue  requestHeap
+	seq    uint64
 	mu     sync.Mutex
 	cond   *sync.Cond
 	closed bool
 	paused int32
+	dlq    *DeadLetterQueue
+
+	workers int32 // Workers draining the queue
+
+	retryBudget *RetryBudget
 
 	// Metrics
 	totalQueued   int64
@@ -60,21 +149,38 @@ type RequestQueue struct {
 	totalDropped  int64
 	totalRetried  int64
 	totalExpired  int64
+
+	totalDeadLettered int64
 }
 
-// NewRequestQueue creates a new request queue.
+// NewRequestQueue creates a new request queue. With a PersistDir set,
+// requests persisted by a previous run are replayed.
 func NewRequestQueue(config *QueueConfig) *RequestQueue {
 	q := &RequestQueue{
 		config: config,
-		queue:  list.New(),
 	}
 	q.cond = sync.NewCond(&q.mu)
+	if config.PersistDir != "" {
+		q.restore()
+	}
 	return q
 }
 
-// Enqueue adds a request to the queue.
+// Enqueue adds a request to the queue at normal priority.
 // Returns an error if the queue is full or closed.
 func (q *RequestQueue) Enqueue(body []byte) (chan QueueResult, error) {
+	return q.EnqueueWithPriority(body, PriorityNormal)
+}
+
+// EnqueueWithPriority adds a request to the queue. Higher priority requests
+// are dequeued first; requests with the same priority are dequeued in order.
+// Returns an error if the queue is full or closed.
+func (q *RequestQueue) EnqueueWithPriority(body []byte, priority QueuePriority) (chan QueueResult, error) {
+	return q.enqueue(body, priority, nil)
+}
+
+// enqueue adds a request to the queue, optionally on behalf of a waiting client.
+func (q *RequestQueue) enqueue(body []byte, priority QueuePriority, client *queuedClient) (chan QueueResult, error) {
 	q.mu.Lock()
 	defer q.mu.Unlock()
 
@@ -88,13 +194,20 @@ func (q *RequestQueue) Enqueue(body []byte) (chan QueueResult, error) {
 	}
 
 	resultCh := make(chan QueueResult, 1)
+	q.seq++
 	req := &QueuedRequest{
 		Body:      body,
+		Priority:  priority,
 		CreatedAt: time.Now(),
 		ResultCh:  resultCh,
+		seq:       q.seq,
+		client:    client,
 	}
 
-	q.queue.PushBack(req)
+	if q.config.PersistDir != "" && client.persistable() {
+		q.persist(req)
+	}
+	heap.Push(&q.queue, req)
 	atomic.AddInt64(&q.totalQueued, 1)
 
 	// Signal that there's work to do
@@ -127,15 +240,11 @@ func (q *RequestQueue) Dequeue(ctx context.Context) (*QueuedRequest, error) {
 		}
 
 		if q.queue.Len() > 0 {
-			elem := q.queue.Front()
-			q.queue.Remove(elem)
-			req, ok := elem.Value.(*QueuedRequest)
-			if !ok {
-				continue
-			}
+			req := heap.Pop(&q.queue).(*QueuedRequest)
 
 			// Check if request has expired
 			if time.Since(req.CreatedAt) > q.config.MaxWait {
+				q.unpersist(req)
 				atomic.AddInt64(&q.totalExpired, 1)
 				req.ResultCh <- QueueResult{Error: errors.New("request timed out in queue")}
 				close(req.ResultCh)
@@ -151,6 +260,38 @@ func (q *RequestQueue) Dequeue(ctx context.Context) (*QueuedRequest, error) {
 	}
 }
 
+// Complete delivers the result of a dequeued request to its waiter. A
+// persisted request stays on disk until it completes, so a request in progress
+// when the proxy stops is replayed on the next start.
+func (q *RequestQueue) Complete(req *QueuedRequest, result QueueResult) {
+	q.unpersist(req)
+	req.ResultCh <- result
+	close(req.ResultCh)
+}
+
+// Run starts workers that dequeue requests and pass them to serve, one at a
+// time each, until the queue is closed.
+func (q *RequestQueue) Run(workers int, serve func(*QueuedRequest)) {
+	for i := 0; i < workers; i++ {
+		atomic.AddInt32(&q.workers, 1)
+		go func() {
+			defer atomic.AddInt32(&q.workers, -1)
+			for {
+				req, err := q.Dequeue(context.Background())
+				if err != nil {
+					return
+				}
+				serve(req)
+			}
+		}()
+	}
+}
+
+// Running reports whether workers are draining the queue.
+func (q *RequestQueue) Running() bool {
+	return atomic.LoadInt32(&q.workers) > 0
+}
+
 // Pause temporarily pauses processing (called during outages).
 func (q *RequestQueue) Pause() {
 	atomic.StoreInt32(&q.paused, 1)
@@ -176,14 +317,13 @@ func (q *RequestQueue) Close() {
 
 	q.closed = true
 
-	// Drain remaining requests with error
-	for elem := q.queue.Front(); elem != nil; elem = elem.Next() {
-		if req, ok := elem.Value.(*QueuedRequest); ok {
-			req.ResultCh <- QueueResult{Error: errors.New("queue closed")}
-			close(req.ResultCh)
-		}
+	// Drain remaining requests with error. Persisted copies are kept so
+	// they're replayed on the next start.
+	for _, req := range q.queue {
+		req.ResultCh <- QueueResult{Error: errors.New("queue closed")}
+		close(req.ResultCh)
 	}
-	q.queue.Init()
+	q.queue = nil
 
 	q.cond.Broadcast()
 }
@@ -204,30 +344,154 @@ type QueueStats struct {
 	Expired  int64
 	Length   int
 	Paused   bool
+
+	DeadLettered int64
+
+	// LengthByPriority is the current queue length per priority level.
+	LengthByPriority map[string]int
 }
 
 // Stats returns queue statistics.
 func (q *RequestQueue) Stats() QueueStats {
+	byPriority := make(map[string]int, len(queuePriorities))
+	for _, p := range queuePriorities {
+		byPriority[p.String()] = 0
+	}
+
 	q.mu.Lock()
 	length := q.queue.Len()
+	for _, req := range q.queue {
+		byPriority[req.Priority.String()]++
+	}
 	q.mu.Unlock()
 
 	return QueueStats{
-		Queued:   atomic.LoadInt64(&q.totalQueued),
-		Dequeued: atomic.LoadInt64(&q.totalDequeued),
-		Dropped:  atomic.LoadInt64(&q.totalDropped),
-		Retried:  atomic.LoadInt64(&q.totalRetried),
-		Expired:  atomic.LoadInt64(&q.totalExpired),
-		Length:   length,
-		Paused:   q.IsPaused(),
+		Queued:           atomic.LoadInt64(&q.totalQueued),
+		Dequeued:         atomic.LoadInt64(&q.totalDequeued),
+		Dropped:          atomic.LoadInt64(&q.totalDropped),
+		Retried:          atomic.LoadInt64(&q.totalRetried),
+		Expired:          atomic.LoadInt64(&q.totalExpired),
+		DeadLettered:     atomic.LoadInt64(&q.totalDeadLettered),
+		Length:           length,
+		Paused:           q.IsPaused(),
+		LengthByPriority: byPriority,
 	}
 }
 
+// ResetStats zeroes the queue counters. Queued requests are kept.
+func (q *RequestQueue) ResetStats() {
+	atomic.StoreInt64(&q.totalQueued, 0)
+	atomic.StoreInt64(&q.totalDequeued, 0)
+	atomic.StoreInt64(&q.totalDropped, 0)
+	atomic.StoreInt64(&q.totalRetried, 0)
+	atomic.StoreInt64(&q.totalExpired, 0)
+	atomic.StoreInt64(&q.totalDeadLettered, 0)
+}
+
 // IncrementRetried increments the retry counter.
 func (q *RequestQueue) IncrementRetried() {
 	atomic.AddInt64(&q.totalRetried, 1)
 }
 
+// SetDeadLetterQueue sets where requests that exhaust their retries are kept.
+func (q *RequestQueue) SetDeadLetterQueue(dlq *DeadLetterQueue) {
+	q.dlq = dlq
+}
+
+// DeadLetters returns the dead-letter queue, or nil if none is set.
+func (q *RequestQueue) DeadLetters() *DeadLetterQueue {
+	return q.dlq
+}
+
+// Retry handles a failed attempt at a dequeued request. While the request has
+// retries left, and the retry budget (if any) allows it, it's queued again
+// after RetryDelay and Retry returns true. Otherwise the request is moved to
+// the dead-letter queue, its waiter gets the error, and Retry returns false.
+func (q *RequestQueue) Retry(req *QueuedRequest, lastErr error) bool {
+	if req.RetryCount < q.config.MaxRetries && (q.retryBudget == nil || q.retryBudget.AllowRetry()) {
+		req.RetryCount++
+		atomic.AddInt64(&q.totalRetried, 1)
+		if q.config.RetryDelay > 0 {
+			time.AfterFunc(q.config.RetryDelay, func() { q.requeue(req) })
+		} else {
+			q.requeue(req)
+		}
+		return true
+	}
+
+	q.deadLetter(req, lastErr)
+	return false
+}
+
+// requeue puts a retried request back on the queue, keeping its place
+// among requests of the same priority.
+func (q *RequestQueue) requeue(req *QueuedRequest) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		req.ResultCh <- QueueResult{Error: errors.New("queue closed")}
+		close(req.ResultCh)
+		return
+	}
+	if q.config.PersistDir != "" && req.client.persistable() {
+		q.persist(req)
+	}
+	heap.Push(&q.queue, req)
+	q.cond.Signal()
+}
+
+// deadLetter records a request that exhausted its retries and fails it.
+func (q *RequestQueue) deadLetter(req *QueuedRequest, lastErr error) {
+	q.unpersist(req)
+	atomic.AddInt64(&q.totalDeadLettered, 1)
+	if q.dlq != nil && req.client.persistable() {
+		if entry, err := q.dlq.Add(req, lastErr); err != nil {
+			log.Printf("[CLASP] Error writing dead-lettered request: %v", err)
+		} else {
+			log.Printf("[CLASP] Queued request failed after %d retries, moved to dead-letter queue as %s", req.RetryCount, entry.ID)
+		}
+	}
+
+	err := fmt.Errorf("request failed after %d retries", req.RetryCount)
+	if lastErr != nil {
+		err = fmt.Errorf("request failed after %d retries: %w", req.RetryCount, lastErr)
+	}
+	req.ResultCh <- QueueResult{Error: err}
+	close(req.ResultCh)
+}
+
+// ReplayDeadLetters queues dead-lettered requests again and removes them from
+// the dead-letter queue. With an id, only that request is replayed. It
+// returns the number of requests replayed.
+func (q *RequestQueue) ReplayDeadLetters(id string) (int, error) {
+	if q.dlq == nil {
+		return 0, errors.New("dead-letter queue is not enabled")
+	}
+	letters, err := q.dlq.List()
+	if err != nil {
+		return 0, err
+	}
+
+	replayed := 0
+	for _, letter := range letters {
+		if id != "" && letter.ID != id {
+			continue
+		}
+		if _, err := q.EnqueueWithPriority([]byte(letter.Body), ParsePriority(letter.Priority)); err != nil {
+			return replayed, fmt.Errorf("replaying %s: %w", letter.ID, err)
+		}
+		if err := q.dlq.Remove(letter.ID); err != nil {
+			log.Printf("[CLASP] Error removing replayed dead letter %s: %v", letter.ID, err)
+		}
+		replayed++
+	}
+	if id != "" && replayed == 0 {
+		return 0, fmt.Errorf("dead letter %s: %w", id, os.ErrNotExist)
+	}
+	return replayed, nil
+}
+
 // QueueMiddleware creates HTTP middleware that queues requests during outages.
 func QueueMiddleware(queue *RequestQueue) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
@@ -264,6 +528,16 @@ type CircuitBreaker struct {
 	state       int32 // 0=closed, 1=open, 2=half-open
 	lastFailure time.Time
 	mu          sync.RWMutex
+
+	// Half-open probe limiting
+	halfOpenMax      int32     // Probe requests admitted at once while half-open
+	halfOpenInFlight int32     // Probes awaiting a result
+	lastProbe        time.Time // When the last probe was admitted, guarded by mu
+	halfOpenProbes   int64     // Total probes admitted
+	halfOpenRejected int64     // Total requests fast-failed while probing
+
+	// Called after each state transition; set before the breaker is used
+	onStateChange func(from, to string)
 }
 
 const (
@@ -278,7 +552,40 @@ func NewCircuitBreaker(failureThreshold, successThreshold int, timeout time.Dura
 		failureThreshold: failureThreshold,
 		successThreshold: successThreshold,
 		timeout:          timeout,
+		halfOpenMax:      1,
+	}
+}
+
+// SetHalfOpenMax sets how many probe requests are admitted at once while
+// half-open (default 1). It must be set before the breaker is used.
+func (cb *CircuitBreaker) SetHalfOpenMax(n int) {
+	if n < 1 {
+		n = 1
 	}
+	cb.halfOpenMax = int32(n)
+}
+
+// SetStateChangeHook sets a function called with the old and new state names
+// after each transition. It runs on the request path, so it must not block.
+// It must be set before the breaker is used.
+func (cb *CircuitBreaker) SetStateChangeHook(fn func(from, to string)) {
+	cb.onStateChange = fn
+}
+
+// transition moves the breaker from one state to another, returning false if
+// it wasn't in the from state.
+func (cb *CircuitBreaker) transition(from, to int32) bool {
+	if !atomic.CompareAndSwapInt32(&cb.state, from, to) {
+		return false
+	}
+	if to == circuitOpen {
+		// No probes are admitted until the timeout passes, so start the next half-open period empty
+		atomic.StoreInt32(&cb.halfOpenInFlight, 0)
+	}
+	if cb.onStateChange != nil {
+		cb.onStateChange(circuitStateName(from), circuitStateName(to))
+	}
+	return true
 }
 
 // Allow checks if a request should be allowed.
@@ -295,29 +602,68 @@ func (cb *CircuitBreaker) Allow() bool {
 
 		if elapsed > cb.timeout {
 			// Transition to half-open
-			if atomic.CompareAndSwapInt32(&cb.state, circuitOpen, circuitHalfOpen) {
+			if cb.transition(circuitOpen, circuitHalfOpen) {
 				atomic.StoreInt32(&cb.successes, 0)
 			}
-			return true
+			return cb.admitProbe()
 		}
 		return false
 	case circuitHalfOpen:
-		return true
+		return cb.admitProbe()
 	default:
 		return true
 	}
 }
 
+// admitProbe admits a half-open probe request if fewer than halfOpenMax are
+// awaiting a result. A probe that never reports a result (for example, one
+// that timed out) stops counting after the breaker timeout, so the breaker
+// can't get stuck half-open.
+func (cb *CircuitBreaker) admitProbe() bool {
+	for {
+		inFlight := atomic.LoadInt32(&cb.halfOpenInFlight)
+		if inFlight >= cb.halfOpenMax {
+			cb.mu.RLock()
+			stale := time.Since(cb.lastProbe) > cb.timeout
+			cb.mu.RUnlock()
+			if stale && atomic.CompareAndSwapInt32(&cb.halfOpenInFlight, inFlight, 0) {
+				continue
+			}
+			atomic.AddInt64(&cb.halfOpenRejected, 1)
+			return false
+		}
+		if atomic.CompareAndSwapInt32(&cb.halfOpenInFlight, inFlight, inFlight+1) {
+			cb.mu.Lock()
+			cb.lastProbe = time.Now()
+			cb.mu.Unlock()
+			atomic.AddInt64(&cb.halfOpenProbes, 1)
+			return true
+		}
+	}
+}
+
+// releaseProbe frees a probe slot after a probe succeeds.
+func (cb *CircuitBreaker) releaseProbe() {
+	for {
+		inFlight := atomic.LoadInt32(&cb.halfOpenInFlight)
+		if inFlight <= 0 || atomic.CompareAndSwapInt32(&cb.halfOpenInFlight, inFlight, inFlight-1) {
+			return
+		}
+	}
+}
+
 // RecordSuccess records a successful request.
 func (cb *CircuitBreaker) RecordSuccess() {
 	state := atomic.LoadInt32(&cb.state)
 
 	if state == circuitHalfOpen {
+		cb.releaseProbe()
 		successes := atomic.AddInt32(&cb.successes, 1)
 		if int(successes) >= cb.successThreshold {
 			// Transition to closed
-			atomic.StoreInt32(&cb.state, circuitClosed)
-			atomic.StoreInt32(&cb.failures, 0)
+			if cb.transition(circuitHalfOpen, circuitClosed) {
+				atomic.StoreInt32(&cb.failures, 0)
+			}
 		}
 	} else if state == circuitClosed {
 		// Reset failure count on success
@@ -334,7 +680,7 @@ func (cb *CircuitBreaker) RecordFailure() {
 		cb.mu.Lock()
 		cb.lastFailure = time.Now()
 		cb.mu.Unlock()
-		atomic.StoreInt32(&cb.state, circuitOpen)
+		cb.transition(circuitHalfOpen, circuitOpen)
 		return
 	}
 
@@ -344,13 +690,18 @@ func (cb *CircuitBreaker) RecordFailure() {
 		cb.mu.Lock()
 		cb.lastFailure = time.Now()
 		cb.mu.Unlock()
-		atomic.StoreInt32(&cb.state, circuitOpen)
+		cb.transition(circuitClosed, circuitOpen)
 	}
 }
 
 // State returns the current state as a string.
 func (cb *CircuitBreaker) State() string {
-	switch atomic.LoadInt32(&cb.state) {
+	return circuitStateName(atomic.LoadInt32(&cb.state))
+}
+
+// circuitStateName returns the name of a circuit state.
+func circuitStateName(state int32) string {
+	switch state {
 	case circuitClosed:
 		return "closed"
 	case circuitOpen:
@@ -362,6 +713,17 @@ func (cb *CircuitBreaker) State() string {
 	}
 }
 
+// HalfOpenProbes returns the total number of probe requests admitted while half-open.
+func (cb *CircuitBreaker) HalfOpenProbes() int64 {
+	return atomic.LoadInt64(&cb.halfOpenProbes)
+}
+
+// HalfOpenRejected returns the total number of requests fast-failed while
+// the half-open probe limit was reached.
+func (cb *CircuitBreaker) HalfOpenRejected() int64 {
+	return atomic.LoadInt64(&cb.halfOpenRejected)
+}
+
 // IsOpen returns true if the circuit is open.
 func (cb *CircuitBreaker) IsOpen() bool {
 	return atomic.LoadInt32(&cb.state) == circuitOpen
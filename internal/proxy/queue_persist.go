@@ -0,0 +1,138 @@
+// Package proxy implements the HTTP proxy server.
+package proxy
+
+import (
+	"container/heap"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jedarden/clasp/internal/secrets"
+)
+
+// persistedRequest is the on-disk form of a queued request.
+type persistedRequest struct {
+	Body       []byte    `json:"body"`
+	Priority   string    `json:"priority"`
+	CreatedAt  time.Time `json:"created_at"`
+	RetryCount int       `json:"retry_count"`
+}
+
+// DefaultQueuePersistDir returns the default directory for persisted
+// requests, ~/.clasp/queue.
+func DefaultQueuePersistDir() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("getting home directory: %w", err)
+	}
+	return filepath.Join(homeDir, ".clasp", "queue"), nil
+}
+
+// persist writes a queued request to the persistence directory. Request
+// bodies can contain prompts and keys, so files are only readable by the
+// owner. Failures are logged and the request stays queued in memory.
+func (q *RequestQueue) persist(req *QueuedRequest) {
+	data, err := json.Marshal(persistedRequest{
+		Body:       req.Body,
+		Priority:   req.Priority.String(),
+		CreatedAt:  req.CreatedAt,
+		RetryCount: req.RetryCount,
+	})
+	if err != nil {
+		log.Printf("[CLASP] Error persisting queued request: %v", err)
+		return
+	}
+
+	name := fmt.Sprintf("%d-%d.json", req.CreatedAt.UnixNano(), req.seq)
+	path := filepath.Join(q.config.PersistDir, name)
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o600); err != nil {
+		log.Printf("[CLASP] Error persisting queued request %s: %s", name, secrets.MaskAllSecrets(err.Error()))
+		return
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		_ = os.Remove(tmpPath)
+		log.Printf("[CLASP] Error persisting queued request %s: %s", name, secrets.MaskAllSecrets(err.Error()))
+		return
+	}
+	req.file = path
+}
+
+// unpersist removes a request's file once it has been served or failed.
+func (q *RequestQueue) unpersist(req *QueuedRequest) {
+	if req.file == "" {
+		return
+	}
+	if err := os.Remove(req.file); err != nil && !os.IsNotExist(err) {
+		log.Printf("[CLASP] Error removing persisted request %s: %v", filepath.Base(req.file), err)
+	}
+	req.file = ""
+}
+
+// restore queues requests persisted by a previous run again, for the queue
+// workers to send upstream. Requests that have waited longer than MaxWait are
+// discarded. Replayed requests keep their priority and original order.
+func (q *RequestQueue) restore() {
+	if err := os.MkdirAll(q.config.PersistDir, 0o700); err != nil {
+		log.Printf("[CLASP] Error creating queue persistence directory: %v", err)
+		return
+	}
+	entries, err := os.ReadDir(q.config.PersistDir)
+	if err != nil {
+		log.Printf("[CLASP] Error reading queue persistence directory: %v", err)
+		return
+	}
+
+	var restored []*QueuedRequest
+	expired := 0
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		path := filepath.Join(q.config.PersistDir, entry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			log.Printf("[CLASP] Error reading persisted request %s: %v", entry.Name(), err)
+			continue
+		}
+		var p persistedRequest
+		if err := json.Unmarshal(data, &p); err != nil {
+			// Don't echo the error: it can quote the request body
+			log.Printf("[CLASP] Discarding unreadable persisted request %s", entry.Name())
+			_ = os.Remove(path)
+			continue
+		}
+		if time.Since(p.CreatedAt) > q.config.MaxWait {
+			expired++
+			_ = os.Remove(path)
+			continue
+		}
+		restored = append(restored, &QueuedRequest{
+			Body:       p.Body,
+			Priority:   ParsePriority(p.Priority),
+			CreatedAt:  p.CreatedAt,
+			RetryCount: p.RetryCount,
+			ResultCh:   make(chan QueueResult, 1),
+			file:       path,
+		})
+	}
+
+	sort.SliceStable(restored, func(i, j int) bool {
+		return restored[i].CreatedAt.Before(restored[j].CreatedAt)
+	})
+	for _, req := range restored {
+		q.seq++
+		req.seq = q.seq
+		q.queue = append(q.queue, req)
+	}
+	heap.Init(&q.queue)
+
+	if len(restored) > 0 || expired > 0 {
+		log.Printf("[CLASP] Replayed %d persisted queued requests (%d expired)", len(restored), expired)
+	}
+}
@@ -0,0 +1,212 @@
+// Package proxy implements the HTTP proxy server.
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// queuedClient is a client waiting on a queued /v1/messages request. The
+// queue worker that dequeues the request writes the response on its behalf.
+type queuedClient struct {
+	h     *Handler // Handler that accepted the request
+	w     http.ResponseWriter
+	r     *http.Request
+	start time.Time
+	state int32 // queuedWaiting, queuedServing or queuedAbandoned
+
+	failure *queueResponseWriter // Last failed attempt, sent if the request isn't retried
+}
+
+// persistable reports whether the request can be persisted and replayed
+// without its client. Requests made with the client's own provider key can't:
+// the key isn't stored, so a replay would run on the proxy's credentials.
+func (c *queuedClient) persistable() bool {
+	return c == nil || c.r.Header.Get(ClientKeyHeader) == ""
+}
+
+// Queued client states.
+const (
+	queuedWaiting int32 = iota
+	queuedServing
+	queuedAbandoned
+)
+
+// enqueueMessages queues a /v1/messages request at its X-CLASP-Priority and
+// waits for a queue worker to serve it.
+func (h *Handler) enqueueMessages(w http.ResponseWriter, r *http.Request, start time.Time) {
+	if h.cfg.MaxRequestBytes > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, h.cfg.MaxRequestBytes)
+	}
+	body, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		atomic.AddInt64(&h.metrics.ErrorRequests, 1)
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			h.writeErrorResponse(w, http.StatusRequestEntityTooLarge, "request_too_large", "Request body is too large")
+			return
+		}
+		h.writeErrorResponse(w, http.StatusBadRequest, "invalid_request_error", "Error reading request body")
+		return
+	}
+
+	client := &queuedClient{h: h, w: w, r: r, start: start}
+	resultCh, err := h.queue.enqueue(body, RequestPriority(r), client)
+	if err != nil {
+		atomic.AddInt64(&h.metrics.ErrorRequests, 1)
+		log.Printf("[CLASP] Request queue rejected request: %v", err)
+		h.writeErrorResponse(w, http.StatusServiceUnavailable, "overloaded_error", "Request queue is full - try again later")
+		return
+	}
+
+	var result QueueResult
+	select {
+	case result = <-resultCh:
+	case <-r.Context().Done():
+		if atomic.CompareAndSwapInt32(&client.state, queuedWaiting, queuedAbandoned) {
+			return
+		}
+		// A worker is already writing the response
+		result = <-resultCh
+	}
+	if result.Error == nil {
+		return
+	}
+	if client.failure != nil {
+		// Already counted as an error when the attempt failed
+		client.failure.writeTo(w)
+		return
+	}
+	atomic.AddInt64(&h.metrics.ErrorRequests, 1)
+	h.writeErrorResponse(w, http.StatusServiceUnavailable, "overloaded_error", "Request was not served: "+result.Error.Error())
+}
+
+// serveQueued sends a dequeued request upstream, writing the response to its
+// waiting client if it has one. A server error is held back and the request
+// retried; once its retries are used up, it's moved to the dead-letter queue
+// and the client gets the last error.
+func (h *Handler) serveQueued(req *QueuedRequest) {
+	c := req.client
+	qw := &queueResponseWriter{header: make(http.Header)}
+	var r *http.Request
+	var start time.Time
+	if c == nil {
+		var err error
+		r, err = http.NewRequestWithContext(context.Background(), http.MethodPost, "/v1/messages", bytes.NewReader(req.Body))
+		if err != nil {
+			h.queue.Complete(req, QueueResult{Error: err})
+			return
+		}
+		r.Header.Set("Content-Type", "application/json")
+		start = time.Now()
+		atomic.AddInt64(&h.metrics.TotalRequests, 1)
+	} else {
+		if !atomic.CompareAndSwapInt32(&c.state, queuedWaiting, queuedServing) {
+			h.queue.Complete(req, QueueResult{Error: errors.New("client went away")})
+			return
+		}
+		qw.w = c.w
+		r, start = c.r, c.start
+		r.Body = io.NopCloser(bytes.NewReader(req.Body))
+	}
+
+	h.serveMessages(qw, r, start)
+	if !qw.failed() {
+		if c == nil {
+			log.Printf("[CLASP] Replayed queued request (%s priority): status %d", req.Priority, qw.statusCode())
+		}
+		h.queue.Complete(req, QueueResult{})
+		return
+	}
+
+	lastErr := fmt.Errorf("upstream returned status %d", qw.status)
+	if c != nil {
+		// Set before the request can be picked up again by another worker
+		c.failure = qw
+		atomic.StoreInt32(&c.state, queuedWaiting)
+	}
+	if h.queue.Retry(req, lastErr) {
+		log.Printf("[CLASP] Queued request failed (%v), retrying", lastErr)
+	}
+}
+
+// queueResponseWriter passes a queued request's response through to the
+// waiting client, if any, unless it's a server error. Server errors are held
+// back so the request can be retried without the client seeing the failure.
+type queueResponseWriter struct {
+	w      http.ResponseWriter // nil for replayed requests
+	header http.Header
+	status int
+	body   bytes.Buffer // Held-back server error
+}
+
+func (qw *queueResponseWriter) Header() http.Header {
+	return qw.header
+}
+
+func (qw *queueResponseWriter) WriteHeader(code int) {
+	if qw.status != 0 {
+		return
+	}
+	qw.status = code
+	if qw.failed() || qw.w == nil {
+		return
+	}
+	for key, values := range qw.header {
+		qw.w.Header()[key] = values
+	}
+	qw.w.WriteHeader(code)
+}
+
+func (qw *queueResponseWriter) Write(p []byte) (int, error) {
+	if qw.status == 0 {
+		qw.WriteHeader(http.StatusOK)
+	}
+	if qw.failed() {
+		return qw.body.Write(p)
+	}
+	if qw.w == nil {
+		return len(p), nil
+	}
+	return qw.w.Write(p)
+}
+
+// Flush implements http.Flusher.
+func (qw *queueResponseWriter) Flush() {
+	if qw.status == 0 || qw.failed() {
+		return
+	}
+	if f, ok := qw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// failed reports whether the response is a server error.
+func (qw *queueResponseWriter) failed() bool {
+	return qw.status >= http.StatusInternalServerError
+}
+
+// statusCode returns the response status, 200 if nothing was written.
+func (qw *queueResponseWriter) statusCode() int {
+	if qw.status == 0 {
+		return http.StatusOK
+	}
+	return qw.status
+}
+
+// writeTo sends a held-back server error to w.
+func (qw *queueResponseWriter) writeTo(w http.ResponseWriter) {
+	for key, values := range qw.header {
+		w.Header()[key] = values
+	}
+	w.WriteHeader(qw.status)
+	_, _ = w.Write(qw.body.Bytes())
+}
@@ -75,6 +75,14 @@ func (rl *RateLimiter) Stats() (allowed, denied int64) {
 	return rl.allowed, rl.denied
 }
 
+// ResetStats zeroes the allowed and denied counters.
+func (rl *RateLimiter) ResetStats() {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.allowed = 0
+	rl.denied = 0
+}
+
 // WaitTime returns the duration until the next request would be allowed.
 func (rl *RateLimiter) WaitTime() time.Duration {
 	rl.mu.Lock()
@@ -88,12 +96,16 @@ func (rl *RateLimiter) WaitTime() time.Duration {
 	return time.Duration(needed/rl.rate*1000) * time.Millisecond
 }
 
-// RateLimitMiddleware creates a middleware that enforces rate limiting.
-func RateLimitMiddleware(limiter *RateLimiter) func(http.Handler) http.Handler {
+// RateLimitMiddleware creates a middleware that enforces rate limiting on the
+// given paths, /v1/messages by default.
+func RateLimitMiddleware(limiter *RateLimiter, paths ...string) func(http.Handler) http.Handler {
+	if len(paths) == 0 {
+		paths = []string{"/v1/messages"}
+	}
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			// Skip rate limiting for non-API endpoints
-			if r.URL.Path != "/v1/messages" {
+			if !containsPath(paths, r.URL.Path) {
 				next.ServeHTTP(w, r)
 				return
 			}
@@ -0,0 +1,206 @@
+package proxy
+
+import (
+	"fmt"
+	"log"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/jedarden/clasp/internal/config"
+)
+
+// restartOnlySettings are Config fields consumed when the server starts
+// (listener, middleware, queue, circuit breaker, health checker, sessions,
+// audit log).
+// A reload keeps their current values and logs that they need a restart.
+var restartOnlySettings = []string{
+	"Port", "LogLevel", "APIPathPrefix", "APIPathUnprefixed",
+	"RateLimitEnabled", "RateLimitRequests", "RateLimitWindow", "RateLimitBurst",
+	"PromptCacheEnabled", "PromptCacheMaxSize",
+	"AuthEnabled", "AuthAPIKey", "AuthAllowAnonymousHealth", "AuthAllowAnonymousMetrics", "AuthAnonymousEndpoints",
+	"IPAllowlist", "IPDenylist", "TrustProxyHeaders", "CORSOrigins",
+	"QueueEnabled", "QueueMaxSize", "QueueMaxWaitSeconds", "QueueRetryDelayMs", "QueueMaxRetries", "QueuePersist", "QueuePersistDir", "QueueDLQDir",
+	"CircuitBreakerEnabled", "CircuitBreakerThreshold", "CircuitBreakerRecovery", "CircuitBreakerTimeoutSec", "CircuitBreakerHalfOpenMax",
+	"HealthCheckEnabled", "HealthCheckIntervalSec", "HealthCheckTimeoutSec",
+	"Warmup",
+	"AlertWebhookURL", "AlertBudgetUSD",
+	"CompactionEnabled", "SessionTimeoutSec", "SessionFile",
+	"AuditLog", "AuditIncludeContent",
+	"RetryBudgetRatio",
+	"CacheMode", "SemanticCacheThreshold",
+	"NegativeCacheEnabled", "NegativeCacheTTL",
+}
+
+// SetConfigLoader sets the function Reload uses to read the configuration.
+func (s *Server) SetConfigLoader(load func() (*config.Config, error)) {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+	s.loadConfig = load
+}
+
+// Reload re-reads the configuration with the configured loader and applies it.
+func (s *Server) Reload() error {
+	s.reloadMu.Lock()
+	load := s.loadConfig
+	s.reloadMu.Unlock()
+	if load == nil {
+		return fmt.Errorf("no configuration loader set")
+	}
+
+	cfg, err := load()
+	if err != nil {
+		return fmt.Errorf("loading configuration: %w", err)
+	}
+	return s.ApplyConfig(cfg)
+}
+
+// ApplyConfig rebuilds providers, tier routing and cache settings from cfg
+// and swaps them in atomically. In-flight requests finish on the handler they
+// started with; metrics, costs, server-managed components, sticky routing pins
+// and endpoint latencies carry over.
+// The new handler is built and validated before anything is swapped, so a cfg
+// that fails leaves the server serving as before.
+func (s *Server) ApplyConfig(cfg *config.Config) error {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+
+	oldCfg := s.currentConfig()
+	if cfg.Port == s.requestedPort {
+		// Not a change: the server may have moved to a free port at startup
+		cfg.Port = oldCfg.Port
+	}
+	ignored := keepRestartOnlySettings(oldCfg, cfg)
+	changed := changedSettings(oldCfg, cfg)
+	if len(ignored) > 0 {
+		log.Printf("[CLASP] Reload: ignoring changes that need a restart: %s", strings.Join(ignored, ", "))
+	}
+	if len(changed) == 0 {
+		log.Printf("[CLASP] Reload: no configuration changes")
+		return nil
+	}
+
+	handler, err := NewHandler(cfg)
+	if err != nil {
+		return fmt.Errorf("creating handler: %w", err)
+	}
+	oldHandler := s.currentHandler()
+	handler.inheritState(oldHandler)
+
+	// Rebuild the response cache only if its settings changed, so a reload
+	// doesn't throw away cached responses
+	requestCache := oldHandler.cache
+	if cfg.CacheEnabled != oldCfg.CacheEnabled || cfg.CacheMaxSize != oldCfg.CacheMaxSize || cfg.CacheTTL != oldCfg.CacheTTL {
+		requestCache = nil
+		if cfg.CacheEnabled {
+			requestCache = NewRequestCache(cfg.CacheMaxSize, time.Duration(cfg.CacheTTL)*time.Second)
+		}
+	}
+	handler.SetCache(requestCache)
+
+	s.mu.Lock()
+	s.cfg = cfg
+	s.handler = handler
+	s.cache = requestCache
+	s.mu.Unlock()
+
+	// The old handler's pooled connections are no longer reused; requests
+	// still in flight on it keep theirs
+	oldHandler.client.CloseIdleConnections()
+
+	if s.statusManager != nil && s.server != nil && cfg.DefaultModel != oldCfg.DefaultModel {
+		_ = s.statusManager.SetModel(cfg.DefaultModel)
+	}
+
+	log.Printf("[CLASP] Reload: applied changes to %s", strings.Join(changed, ", "))
+	return nil
+}
+
+// currentHandler returns the handler serving new requests.
+func (s *Server) currentHandler() *Handler {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.handler
+}
+
+// currentConfig returns the configuration of the current handler.
+func (s *Server) currentConfig() *config.Config {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.cfg
+}
+
+// inheritState carries state that must survive a reload over from prev.
+func (h *Handler) inheritState(prev *Handler) {
+	h.metrics = prev.metrics
+	h.costTracker = prev.costTracker
+	h.rateLimiter = prev.rateLimiter
+	h.cache = prev.cache
+	h.promptCache = prev.promptCache
+	h.queue = prev.queue
+	h.circuitBreakers = prev.circuitBreakers
+	h.healthChecker = prev.healthChecker
+	h.sessionTracker = prev.sessionTracker
+	h.readiness = prev.readiness
+	h.auditLog = prev.auditLog
+	h.retryBudget = prev.retryBudget
+	h.semanticCache = prev.semanticCache
+	h.negativeCache = prev.negativeCache
+	h.coalescer = prev.coalescer
+	h.features = prev.features
+	h.version = prev.version
+
+	// Keep counting slots held by in-flight requests
+	if h.cfg.MaxConcurrent == prev.cfg.MaxConcurrent {
+		h.concurrency = prev.concurrency
+	}
+
+	// Keep conversation pins unless sticky routing was turned off or resized
+	if h.stickyRouter != nil && prev.stickyRouter != nil &&
+		h.cfg.StickyRoutingMaxEntries == prev.cfg.StickyRoutingMaxEntries && h.cfg.StickyRoutingTTLSec == prev.cfg.StickyRoutingTTLSec {
+		h.stickyRouter = prev.stickyRouter
+	}
+
+	// Keep the measured latency and health of endpoints that are still
+	// configured for the same base URL
+	for _, selector := range h.endpointSelectors {
+		for _, prevSelector := range prev.endpointSelectors {
+			if prevSelector.baseURL == selector.baseURL {
+				selector.inherit(prevSelector)
+			}
+		}
+	}
+}
+
+// keepRestartOnlySettings copies restart-only fields from oldCfg into newCfg,
+// returning the names of those whose values differed.
+func keepRestartOnlySettings(oldCfg, newCfg *config.Config) []string {
+	oldValue := reflect.ValueOf(oldCfg).Elem()
+	newValue := reflect.ValueOf(newCfg).Elem()
+
+	var ignored []string
+	for _, name := range restartOnlySettings {
+		oldField := oldValue.FieldByName(name)
+		newField := newValue.FieldByName(name)
+		if !reflect.DeepEqual(oldField.Interface(), newField.Interface()) {
+			ignored = append(ignored, name)
+			newField.Set(oldField)
+		}
+	}
+	return ignored
+}
+
+// changedSettings returns the names of the Config fields that differ.
+// Values aren't logged since many of them are API keys.
+func changedSettings(oldCfg, newCfg *config.Config) []string {
+	oldValue := reflect.ValueOf(oldCfg).Elem()
+	newValue := reflect.ValueOf(newCfg).Elem()
+
+	var changed []string
+	for i := 0; i < oldValue.NumField(); i++ {
+		if !reflect.DeepEqual(oldValue.Field(i).Interface(), newValue.Field(i).Interface()) {
+			changed = append(changed, oldValue.Type().Field(i).Name)
+		}
+	}
+	return changed
+}
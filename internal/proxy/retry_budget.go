@@ -0,0 +1,109 @@
+// Package proxy implements the HTTP proxy server.
+package proxy
+
+import (
+	"sync"
+)
+
+// retryBudgetBurst is how many retries the budget holds at most, so a few
+// failures can be retried even before many requests have been made.
+const retryBudgetBurst = 10
+
+// RetryBudget caps retries at a fraction of requests across all clients, so
+// retries can't multiply the load on an upstream that is already failing.
+// It is a token bucket: each request adds ratio tokens and each retry spends
+// one. When the bucket is empty, retries are skipped and requests fail fast.
+type RetryBudget struct {
+	mu sync.Mutex
+
+	// Configuration
+	ratio float64 // Retries allowed per request
+
+	// State
+	tokens float64
+
+	// Metrics
+	requests  int64
+	retries   int64
+	throttled int64
+}
+
+// RetryBudgetStats reports the state of a retry budget.
+type RetryBudgetStats struct {
+	Ratio     float64 `json:"ratio"`
+	Available float64 `json:"available"`
+	Requests  int64   `json:"requests"`
+	Retries   int64   `json:"retries"`
+	Throttled int64   `json:"throttled"`
+}
+
+// NewRetryBudget creates a retry budget allowing ratio retries per request,
+// e.g. 0.1 for at most one retry per ten requests. It starts full.
+func NewRetryBudget(ratio float64) *RetryBudget {
+	return &RetryBudget{
+		ratio:  ratio,
+		tokens: retryBudgetBurst,
+	}
+}
+
+// RecordRequest adds a request's share of retries to the budget.
+func (b *RetryBudget) RecordRequest() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.requests++
+	b.tokens += b.ratio
+	if b.tokens > retryBudgetBurst {
+		b.tokens = retryBudgetBurst
+	}
+}
+
+// AllowRetry spends a token for a retry, reporting false if the budget is
+// exhausted.
+func (b *RetryBudget) AllowRetry() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.tokens >= 1.0 {
+		b.tokens -= 1.0
+		b.retries++
+		return true
+	}
+
+	b.throttled++
+	return false
+}
+
+// Stats returns retry budget statistics.
+func (b *RetryBudget) Stats() RetryBudgetStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return RetryBudgetStats{
+		Ratio:     b.ratio,
+		Available: b.tokens,
+		Requests:  b.requests,
+		Retries:   b.retries,
+		Throttled: b.throttled,
+	}
+}
+
+// ResetStats zeroes the request, retry and throttled counters. The budget
+// itself is kept.
+func (b *RetryBudget) ResetStats() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.requests = 0
+	b.retries = 0
+	b.throttled = 0
+}
+
+// SetRetryBudget sets the retry budget shared by upstream retries.
+func (h *Handler) SetRetryBudget(b *RetryBudget) {
+	h.retryBudget = b
+}
+
+// SetRetryBudget sets a retry budget that queued request retries must also
+// fit within.
+func (q *RequestQueue) SetRetryBudget(b *RetryBudget) {
+	q.retryBudget = b
+}
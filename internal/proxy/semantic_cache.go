@@ -0,0 +1,357 @@
+// Package proxy implements the HTTP proxy server.
+package proxy
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jedarden/clasp/internal/provider"
+	"github.com/jedarden/clasp/pkg/models"
+)
+
+// Embedder turns text into an embedding vector.
+type Embedder interface {
+	Embed(ctx context.Context, text string) ([]float64, error)
+}
+
+// EmbedderFunc adapts a function to the Embedder interface.
+type EmbedderFunc func(ctx context.Context, text string) ([]float64, error)
+
+// Embed calls f.
+func (f EmbedderFunc) Embed(ctx context.Context, text string) ([]float64, error) {
+	return f(ctx, text)
+}
+
+// SemanticCache caches responses by the meaning of the prompt rather than its
+// exact text. Each entry holds the embedding of a request's last message and
+// a scope hashing everything else about the request (model, system prompt,
+// tools, earlier messages), so only requests that differ in the wording of
+// the final message can share a response. Lookups return the most similar
+// entry in the same scope whose cosine similarity reaches the threshold.
+type SemanticCache struct {
+	mu sync.Mutex
+
+	// Configuration
+	maxSize   int
+	ttl       time.Duration
+	threshold float64
+	embedder  Embedder // nil embeds with the handler's embeddings provider
+
+	// Storage, most recently used first
+	lru *list.List
+
+	// Metrics
+	hits   int64
+	misses int64
+}
+
+// semanticEntry is a cached response and the normalized embedding of its prompt.
+type semanticEntry struct {
+	scope     string
+	vector    []float64
+	response  *models.AnthropicResponse
+	createdAt time.Time
+}
+
+// NewSemanticCache creates a semantic cache holding at most maxSize entries,
+// each kept for ttl (0 = never expire). A lookup hits when cosine similarity
+// is at least threshold. With a nil embedder, prompts are embedded through
+// the provider's embeddings endpoint.
+func NewSemanticCache(maxSize int, ttl time.Duration, threshold float64, embedder Embedder) *SemanticCache {
+	if maxSize <= 0 {
+		maxSize = 1000
+	}
+	return &SemanticCache{
+		maxSize:   maxSize,
+		ttl:       ttl,
+		threshold: threshold,
+		embedder:  embedder,
+		lru:       list.New(),
+	}
+}
+
+// Lookup returns the cached response most similar to vector within scope,
+// and its similarity, if that similarity reaches the threshold.
+func (sc *SemanticCache) Lookup(scope string, vector []float64) (*models.AnthropicResponse, float64, bool) {
+	query := normalizeVector(vector)
+
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	var best *list.Element
+	bestScore := -1.0
+	for elem := sc.lru.Front(); elem != nil; {
+		next := elem.Next()
+		entry := elem.Value.(*semanticEntry)
+		if sc.ttl > 0 && time.Since(entry.createdAt) > sc.ttl {
+			sc.lru.Remove(elem)
+		} else if entry.scope == scope && len(entry.vector) == len(query) {
+			if score := dotProduct(entry.vector, query); score > bestScore {
+				best, bestScore = elem, score
+			}
+		}
+		elem = next
+	}
+
+	if best == nil || bestScore < sc.threshold {
+		atomic.AddInt64(&sc.misses, 1)
+		return nil, bestScore, false
+	}
+	sc.lru.MoveToFront(best)
+	atomic.AddInt64(&sc.hits, 1)
+	return best.Value.(*semanticEntry).response, bestScore, true
+}
+
+// Store adds a response under the embedding of its prompt, evicting the least
+// recently used entries when full.
+func (sc *SemanticCache) Store(scope string, vector []float64, response *models.AnthropicResponse) {
+	entry := &semanticEntry{
+		scope:     scope,
+		vector:    normalizeVector(vector),
+		response:  response,
+		createdAt: time.Now(),
+	}
+
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	for sc.lru.Len() >= sc.maxSize {
+		sc.lru.Remove(sc.lru.Back())
+	}
+	sc.lru.PushFront(entry)
+}
+
+// Stats returns semantic cache statistics.
+func (sc *SemanticCache) Stats() (size, maxSize int, hits, misses int64, threshold float64) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return sc.lru.Len(), sc.maxSize, atomic.LoadInt64(&sc.hits), atomic.LoadInt64(&sc.misses), sc.threshold
+}
+
+// ResetStats zeroes the hit and miss counters without evicting entries.
+func (sc *SemanticCache) ResetStats() {
+	atomic.StoreInt64(&sc.hits, 0)
+	atomic.StoreInt64(&sc.misses, 0)
+}
+
+// normalizeVector scales v to unit length, so cosine similarity is a dot product.
+func normalizeVector(v []float64) []float64 {
+	var sum float64
+	for _, x := range v {
+		sum += x * x
+	}
+	norm := math.Sqrt(sum)
+	out := make([]float64, len(v))
+	if norm == 0 {
+		return out
+	}
+	for i, x := range v {
+		out[i] = x / norm
+	}
+	return out
+}
+
+func dotProduct(a, b []float64) float64 {
+	var sum float64
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return sum
+}
+
+// SetSemanticCache sets the semantic cache consulted after exact-match misses.
+func (h *Handler) SetSemanticCache(sc *SemanticCache) {
+	h.semanticCache = sc
+}
+
+// semanticPending is the prompt embedding of a request that missed the
+// semantic cache, kept in the request context until its response is stored.
+type semanticPending struct {
+	scope  string
+	vector []float64
+}
+
+// semanticContextKey is the request context key for a semanticPending.
+type semanticContextKey struct{}
+
+// checkSemanticCache looks up a request that missed the exact-match cache in
+// the semantic cache. On a hit the cached response is written and true is
+// returned. On a miss the prompt embedding is kept in the returned request's
+// context, so storeSemanticCache doesn't have to embed it again.
+func (h *Handler) checkSemanticCache(w http.ResponseWriter, r *http.Request, req *models.AnthropicRequest) (*http.Request, bool) {
+	if h.semanticCache == nil || h.activeCache() == nil {
+		return r, false
+	}
+	scope, prompt, ok := semanticScope(req)
+	if !ok {
+		return r, false
+	}
+
+	vector, err := h.embedPrompt(r.Context(), prompt)
+	if err != nil {
+		log.Printf("[CLASP] Semantic cache: embedding failed, skipping lookup: %v", err)
+		return r, false
+	}
+
+	cachedResp, similarity, found := h.semanticCache.Lookup(scope, vector)
+	if !found {
+		log.Printf("[CLASP] Semantic cache MISS")
+		ctx := context.WithValue(r.Context(), semanticContextKey{}, &semanticPending{scope: scope, vector: vector})
+		return r.WithContext(ctx), false
+	}
+
+	log.Printf("[CLASP] Semantic cache HIT (similarity %.4f)", similarity)
+	atomic.AddInt64(&h.metrics.SuccessRequests, 1)
+	w.Header().Set("X-CLASP-Cache-Similarity", fmt.Sprintf("%.4f", similarity))
+	if req.Stream {
+		h.writeCachedStream(w, cachedResp)
+		return r, true
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-CLASP-Cache", "HIT")
+	_ = json.NewEncoder(w).Encode(cachedResp)
+	return r, true
+}
+
+// storeSemanticCache stores a response under the prompt embedding computed
+// when the request missed the semantic cache.
+func (h *Handler) storeSemanticCache(ctx context.Context, resp *models.AnthropicResponse) {
+	pending, _ := ctx.Value(semanticContextKey{}).(*semanticPending)
+	if pending == nil || h.semanticCache == nil {
+		return
+	}
+	h.semanticCache.Store(pending.scope, pending.vector, resp)
+}
+
+// semanticScope splits a request into the text of its last message, which is
+// compared by meaning, and a hash of everything else, which must match
+// exactly. Requests whose last message has no text are not cached.
+func semanticScope(req *models.AnthropicRequest) (string, string, bool) {
+	if len(req.Messages) == 0 {
+		return "", "", false
+	}
+	last := req.Messages[len(req.Messages)-1]
+	prompt := strings.TrimSpace(messageText(last.Content))
+	if prompt == "" {
+		return "", "", false
+	}
+
+	scope := struct {
+		Model      string                    `json:"model"`
+		System     interface{}               `json:"system"`
+		Messages   []models.AnthropicMessage `json:"messages"`
+		Role       string                    `json:"role"`
+		Tools      []models.AnthropicTool    `json:"tools"`
+		ToolChoice interface{}               `json:"tool_choice,omitempty"`
+		MaxTokens  int                       `json:"max_tokens"`
+	}{
+		Model:      req.Model,
+		System:     req.System,
+		Messages:   req.Messages[:len(req.Messages)-1],
+		Role:       last.Role,
+		Tools:      req.Tools,
+		ToolChoice: req.ToolChoice,
+		MaxTokens:  req.MaxTokens,
+	}
+	data, err := json.Marshal(scope)
+	if err != nil {
+		return "", "", false
+	}
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:]), prompt, true
+}
+
+// messageText returns the text of a message's content, which is either a
+// string or a list of content blocks.
+func messageText(content interface{}) string {
+	switch c := content.(type) {
+	case string:
+		return c
+	case []models.ContentBlock:
+		var parts []string
+		for _, block := range c {
+			if block.Type == "text" {
+				parts = append(parts, block.Text)
+			}
+		}
+		return strings.Join(parts, "\n")
+	case []interface{}:
+		var parts []string
+		for _, item := range c {
+			if block, ok := item.(map[string]interface{}); ok && block["type"] == "text" {
+				if text, ok := block["text"].(string); ok {
+					parts = append(parts, text)
+				}
+			}
+		}
+		return strings.Join(parts, "\n")
+	}
+	return ""
+}
+
+// embedPrompt embeds prompt text with the semantic cache's embedder, or with
+// the embeddings endpoint of the provider serving CLASP_SEMANTIC_CACHE_MODEL.
+func (h *Handler) embedPrompt(ctx context.Context, prompt string) ([]float64, error) {
+	if h.semanticCache.embedder != nil {
+		return h.semanticCache.embedder.Embed(ctx, prompt)
+	}
+
+	p, targetModel := h.selectEmbeddingsProvider(h.cfg.SemanticCacheModel)
+	embeddings, ok := p.(provider.EmbeddingsProvider)
+	if !ok {
+		return nil, fmt.Errorf("provider %s does not support embeddings", p.Name())
+	}
+	reqBody, err := json.Marshal(map[string]interface{}{
+		"model": targetModel,
+		"input": prompt,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("encoding embeddings request: %w", err)
+	}
+
+	resp, err := h.doRequestWithRetryTo(ctx, reqBody, p, func() string {
+		return embeddings.GetEmbeddingsURL(targetModel)
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading embeddings response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("embeddings endpoint returned %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Data []struct {
+			Embedding []float64 `json:"embedding"`
+		} `json:"data"`
+		Usage struct {
+			PromptTokens int `json:"prompt_tokens"`
+		} `json:"usage"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("decoding embeddings response: %w", err)
+	}
+	if len(result.Data) == 0 || len(result.Data[0].Embedding) == 0 {
+		return nil, errors.New("embeddings response contains no embedding")
+	}
+	if h.costTracker != nil {
+		h.costTracker.RecordEmbeddingUsage(targetModel, result.Usage.PromptTokens)
+	}
+	return result.Data[0].Embedding, nil
+}
@@ -9,6 +9,8 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -21,19 +23,26 @@ import (
 
 // Server represents the CLASP proxy server.
 type Server struct {
-	cfg            *config.Config
-	handler        *Handler
-	server         *http.Server
-	rateLimiter    *RateLimiter
-	cache          *RequestCache
-	authConfig     *AuthConfig
-	queue          *RequestQueue
-	circuitBreaker *CircuitBreaker
-	healthChecker  *HealthChecker
-	sessionTracker *session.Tracker
-	statusManager  *statusline.Manager
-	version        string
-	shutdownCh     chan struct{} // Channel to signal goroutines to stop
+	mu              sync.RWMutex // Guards cfg, handler and cache, which a reload replaces
+	cfg             *config.Config
+	handler         *Handler
+	server          *http.Server
+	rateLimiter     *RateLimiter
+	cache           *RequestCache
+	authConfig      *AuthConfig
+	ipFilter        *IPFilter
+	queue           *RequestQueue
+	circuitBreakers *CircuitBreakers
+	healthChecker   *HealthChecker
+	alerter         *Alerter
+	sessionTracker  *session.Tracker
+	auditLog        *AuditLogger
+	statusManager   *statusline.Manager
+	version         string
+	shutdownCh      chan struct{} // Channel to signal goroutines to stop
+	reloadMu        sync.Mutex    // Serializes reloads
+	loadConfig      func() (*config.Config, error)
+	requestedPort   int // Port from the configuration, before auto-selection
 }
 
 // NewServer creates a new proxy server.
@@ -64,6 +73,7 @@ func NewServerWithVersion(cfg *config.Config, version string) (*Server, error) {
 		statusManager: statusManager,
 		version:       version,
 		shutdownCh:    make(chan struct{}),
+		requestedPort: cfg.Port,
 	}
 
 	// Initialize rate limiter if enabled
@@ -83,6 +93,19 @@ func NewServerWithVersion(cfg *config.Config, version string) (*Server, error) {
 		s.handler.SetCache(s.cache)
 	}
 
+	// Semantic caching sits behind the exact-match cache, so it only takes
+	// effect while response caching is enabled
+	if cfg.CacheMode == "semantic" {
+		s.handler.SetSemanticCache(NewSemanticCache(cfg.CacheMaxSize, time.Duration(cfg.CacheTTL)*time.Second, cfg.SemanticCacheThreshold, nil))
+		log.Printf("[CLASP] Semantic caching enabled: similarity threshold %g, embedding model %s", cfg.SemanticCacheThreshold, cfg.SemanticCacheModel)
+	}
+
+	// Negative caching works independently of the response cache
+	if cfg.NegativeCacheEnabled {
+		s.handler.SetNegativeCache(NewNegativeCache(time.Duration(cfg.NegativeCacheTTL) * time.Second))
+		log.Printf("[CLASP] Negative caching enabled: upstream request errors cached for %ds", cfg.NegativeCacheTTL)
+	}
+
 	// Initialize prompt cache if enabled
 	if cfg.PromptCacheEnabled {
 		promptCache := cache.NewPromptCache(cfg.PromptCacheMaxSize, time.Duration(cfg.CacheTTL)*time.Second)
@@ -98,9 +121,26 @@ func NewServerWithVersion(cfg *config.Config, version string) (*Server, error) {
 			APIKey:                cfg.AuthAPIKey,
 			AllowAnonymousHealth:  cfg.AuthAllowAnonymousHealth,
 			AllowAnonymousMetrics: cfg.AuthAllowAnonymousMetrics,
+			AnonymousEndpoints:    cfg.AuthAnonymousEndpoints,
 		}
 	}
 
+	// Initialize IP filter if allow/deny lists are configured
+	if len(cfg.IPAllowlist) > 0 || len(cfg.IPDenylist) > 0 {
+		ipFilter, err := NewIPFilter(&IPFilterConfig{
+			Allowlist:             cfg.IPAllowlist,
+			Denylist:              cfg.IPDenylist,
+			TrustProxyHeaders:     cfg.TrustProxyHeaders,
+			AllowAnonymousHealth:  cfg.AuthAllowAnonymousHealth,
+			AllowAnonymousMetrics: cfg.AuthAllowAnonymousMetrics,
+			AnonymousEndpoints:    cfg.AuthAnonymousEndpoints,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("creating IP filter: %w", err)
+		}
+		s.ipFilter = ipFilter
+	}
+
 	// Initialize request queue if enabled
 	if cfg.QueueEnabled {
 		queueConfig := &QueueConfig{
@@ -110,18 +150,64 @@ func NewServerWithVersion(cfg *config.Config, version string) (*Server, error) {
 			RetryDelay: time.Duration(cfg.QueueRetryDelayMs) * time.Millisecond,
 			MaxRetries: cfg.QueueMaxRetries,
 		}
+		if cfg.QueuePersist {
+			queueConfig.PersistDir = cfg.QueuePersistDir
+			if queueConfig.PersistDir == "" {
+				dir, err := DefaultQueuePersistDir()
+				if err != nil {
+					return nil, fmt.Errorf("resolving queue persistence directory: %w", err)
+				}
+				queueConfig.PersistDir = dir
+			}
+		}
 		s.queue = NewRequestQueue(queueConfig)
+
+		dlqDir := cfg.QueueDLQDir
+		if dlqDir == "" {
+			dir, err := DefaultDeadLetterDir()
+			if err != nil {
+				return nil, fmt.Errorf("resolving dead-letter directory: %w", err)
+			}
+			dlqDir = dir
+		}
+		dlq, err := NewDeadLetterQueue(dlqDir)
+		if err != nil {
+			return nil, err
+		}
+		s.queue.SetDeadLetterQueue(dlq)
 		s.handler.SetQueue(s.queue)
 	}
 
-	// Initialize circuit breaker if enabled
+	// Cap upstream and queue retries at a fraction of requests
+	if cfg.RetryBudgetRatio > 0 {
+		retryBudget := NewRetryBudget(cfg.RetryBudgetRatio)
+		s.handler.SetRetryBudget(retryBudget)
+		if s.queue != nil {
+			s.queue.SetRetryBudget(retryBudget)
+		}
+		log.Printf("[CLASP] Retry budget: %g retries per request", cfg.RetryBudgetRatio)
+	}
+
+	// Open the audit log if configured
+	if cfg.AuditLog != "" {
+		auditLog, err := NewAuditLogger(cfg.AuditLog, cfg.AuditIncludeContent)
+		if err != nil {
+			return nil, err
+		}
+		s.auditLog = auditLog
+		s.handler.SetAuditLogger(auditLog)
+		log.Printf("[CLASP] Audit log: %s (content: %v)", cfg.AuditLog, cfg.AuditIncludeContent)
+	}
+
+	// Initialize per-provider circuit breakers if enabled
 	if cfg.CircuitBreakerEnabled {
-		s.circuitBreaker = NewCircuitBreaker(
+		s.circuitBreakers = NewCircuitBreakers(
 			cfg.CircuitBreakerThreshold,
 			cfg.CircuitBreakerRecovery,
 			time.Duration(cfg.CircuitBreakerTimeoutSec)*time.Second,
 		)
-		s.handler.SetCircuitBreaker(s.circuitBreaker)
+		s.circuitBreakers.SetHalfOpenMax(cfg.CircuitBreakerHalfOpenMax)
+		s.handler.SetCircuitBreakers(s.circuitBreakers)
 	}
 
 	// Initialize health checker if enabled
@@ -137,13 +223,16 @@ func NewServerWithVersion(cfg *config.Config, version string) (*Server, error) {
 		s.healthChecker.RegisterProvider(string(cfg.Provider), s.handler.provider, cfg.GetAPIKey(), "primary")
 
 		// Register circuit breaker if enabled
-		if s.circuitBreaker != nil {
-			s.healthChecker.RegisterCircuitBreaker(string(cfg.Provider), s.circuitBreaker)
+		if s.circuitBreakers != nil {
+			s.healthChecker.RegisterCircuitBreaker(string(cfg.Provider), s.circuitBreakers.Get(s.handler.provider.Name()))
 		}
 
 		// Register fallback provider if configured
 		if s.handler.fallbackProvider != nil {
 			s.healthChecker.RegisterProvider(string(cfg.FallbackProvider), s.handler.fallbackProvider, cfg.FallbackAPIKey, "fallback")
+			if s.circuitBreakers != nil {
+				s.healthChecker.RegisterCircuitBreaker(string(cfg.FallbackProvider), s.circuitBreakers.Get(s.handler.fallbackProvider.Name()))
+			}
 		}
 
 		// Register tier-specific providers if multi-provider routing is enabled
@@ -166,12 +255,26 @@ func NewServerWithVersion(cfg *config.Config, version string) (*Server, error) {
 					}
 				}
 				s.healthChecker.RegisterProvider(string(tier), p, apiKey, string(tier))
+				if s.circuitBreakers != nil {
+					s.healthChecker.RegisterCircuitBreaker(string(tier), s.circuitBreakers.Get(p.Name()))
+				}
 			}
 		}
 
 		s.handler.SetHealthChecker(s.healthChecker)
 	}
 
+	// Initialize webhook alerts if configured
+	if cfg.AlertWebhookURL != "" {
+		s.alerter = NewAlerter(cfg.AlertWebhookURL, cfg.AlertBudgetUSD)
+		if s.circuitBreakers != nil {
+			s.circuitBreakers.SetStateChangeHook(s.alerter.CircuitBreakerHook)
+		}
+		if cfg.AlertBudgetUSD > 0 {
+			s.handler.GetCostTracker().SetUsageHook(s.alerter.CheckBudget)
+		}
+	}
+
 	// Initialize session tracker for Responses API compaction if enabled.
 	// Note: NewHandler already creates the tracker when CompactionEnabled is set;
 	// we store a reference here so Shutdown can stop the background goroutine.
@@ -180,31 +283,91 @@ func NewServerWithVersion(cfg *config.Config, version string) (*Server, error) {
 		s.sessionTracker = session.NewTracker(ttl)
 		s.handler.SetSessionTracker(s.sessionTracker)
 		log.Printf("[CLASP] Compaction enabled (session TTL: %v)", ttl)
+		if cfg.SessionFile != "" {
+			n, err := s.sessionTracker.Load(cfg.SessionFile)
+			if err != nil {
+				log.Printf("[CLASP] Warning: Could not restore sessions: %v", err)
+			} else if n > 0 {
+				log.Printf("[CLASP] Restored %d sessions from %s", n, cfg.SessionFile)
+			}
+		}
+	}
+
+	// Serve queued requests once the handler is fully set up
+	if s.queue != nil {
+		s.queue.Run(queueWorkers(cfg), s.serveQueued)
 	}
 
 	return s, nil
 }
 
-// Start starts the proxy server.
-func (s *Server) Start() error {
-	// Create mux
+// queueWorkers returns how many queued requests are served at once: the
+// concurrency limit, or the queue size without one.
+func queueWorkers(cfg *config.Config) int {
+	if cfg.MaxConcurrent > 0 {
+		return cfg.MaxConcurrent
+	}
+	return cfg.QueueMaxSize
+}
+
+// serveQueued serves a dequeued request on the handler that accepted it.
+func (s *Server) serveQueued(req *QueuedRequest) {
+	h := s.currentHandler()
+	if req.client != nil {
+		h = req.client.h
+	}
+	h.serveQueued(req)
+}
+
+// Routes returns the router for the proxy's endpoints, without middleware.
+// The messages and embeddings routes are mounted under CLASP_API_PATH_PREFIX.
+func (s *Server) Routes() *http.ServeMux {
 	mux := http.NewServeMux()
 
-	// Register routes
-	mux.HandleFunc("/", s.handler.HandleRoot)
-	mux.HandleFunc("/health", s.handler.HandleHealth)
-	mux.HandleFunc("/providers/health", s.handler.HandleProvidersHealth)
-	mux.HandleFunc("/metrics", s.handler.HandleMetrics)
-	mux.HandleFunc("/metrics/prometheus", s.handler.HandleMetricsPrometheus)
-	mux.HandleFunc("/costs", s.handler.HandleCosts)
-	mux.HandleFunc("/v1/messages", s.handler.HandleMessages)
+	// Register routes. Each request is served by the handler current when it
+	// arrives, so a config reload never affects requests already in flight.
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) { s.currentHandler().HandleRoot(w, r) })
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) { s.currentHandler().HandleHealth(w, r) })
+	mux.HandleFunc("/livez", func(w http.ResponseWriter, r *http.Request) { s.currentHandler().HandleLivez(w, r) })
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) { s.currentHandler().HandleReadyz(w, r) })
+	mux.HandleFunc("/providers/health", func(w http.ResponseWriter, r *http.Request) { s.currentHandler().HandleProvidersHealth(w, r) })
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) { s.currentHandler().HandleMetrics(w, r) })
+	mux.HandleFunc("/metrics/prometheus", func(w http.ResponseWriter, r *http.Request) { s.currentHandler().HandleMetricsPrometheus(w, r) })
+	mux.HandleFunc("/costs", func(w http.ResponseWriter, r *http.Request) { s.currentHandler().HandleCosts(w, r) })
+	mux.HandleFunc("/costs/models", func(w http.ResponseWriter, r *http.Request) { s.currentHandler().HandleCostModels(w, r) })
+	mux.HandleFunc("/queue/dlq", func(w http.ResponseWriter, r *http.Request) { s.currentHandler().HandleDLQ(w, r) })
+	mux.HandleFunc("/admin/features", func(w http.ResponseWriter, r *http.Request) { s.currentHandler().HandleAdminFeatures(w, r) })
+	for _, path := range apiPaths(s.cfg, "/messages") {
+		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) { s.currentHandler().HandleMessages(w, r) })
+	}
+	for _, path := range apiPaths(s.cfg, "/messages/preview") {
+		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) { s.currentHandler().HandleMessagesPreview(w, r) })
+	}
+	for _, path := range apiPaths(s.cfg, "/embeddings") {
+		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) { s.currentHandler().HandleEmbeddings(w, r) })
+	}
+	return mux
+}
+
+// Start starts the proxy server.
+func (s *Server) Start() error {
+	mux := s.Routes()
 
 	// Build middleware chain
 	var handler http.Handler = mux
 
 	// Apply rate limiting middleware if enabled
 	if s.rateLimiter != nil {
-		handler = RateLimitMiddleware(s.rateLimiter)(handler)
+		// Rate limiting can be switched off at runtime via /admin/features
+		unlimited := handler
+		limited := RateLimitMiddleware(s.rateLimiter, apiPaths(s.cfg, "/messages")...)(handler)
+		handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if s.currentHandler().featureEnabled(featureRateLimit) {
+				limited.ServeHTTP(w, r)
+				return
+			}
+			unlimited.ServeHTTP(w, r)
+		})
 		log.Printf("[CLASP] Rate limiting enabled: %d requests per %d seconds (burst: %d)",
 			s.cfg.RateLimitRequests, s.cfg.RateLimitWindow, s.cfg.RateLimitBurst)
 	} else {
@@ -219,14 +382,25 @@ func (s *Server) Start() error {
 
 	// Log queue status
 	if s.queue != nil {
-		log.Printf("[CLASP] Request queue enabled: max %d requests, timeout %d seconds",
-			s.cfg.QueueMaxSize, s.cfg.QueueMaxWaitSeconds)
+		log.Printf("[CLASP] Request queue enabled: max %d requests, timeout %d seconds, %d workers",
+			s.cfg.QueueMaxSize, s.cfg.QueueMaxWaitSeconds, queueWorkers(s.cfg))
+		if s.cfg.QueuePersist {
+			log.Printf("[CLASP] Queued requests persist across restarts")
+		}
 	}
 
 	// Log circuit breaker status
-	if s.circuitBreaker != nil {
-		log.Printf("[CLASP] Circuit breaker enabled: threshold %d failures, recovery %d successes, timeout %d seconds",
-			s.cfg.CircuitBreakerThreshold, s.cfg.CircuitBreakerRecovery, s.cfg.CircuitBreakerTimeoutSec)
+	if s.circuitBreakers != nil {
+		log.Printf("[CLASP] Circuit breakers enabled per provider: threshold %d failures, recovery %d successes, timeout %d seconds, %d half-open probes",
+			s.cfg.CircuitBreakerThreshold, s.cfg.CircuitBreakerRecovery, s.cfg.CircuitBreakerTimeoutSec, s.cfg.CircuitBreakerHalfOpenMax)
+	}
+
+	if s.alerter != nil {
+		if s.cfg.AlertBudgetUSD > 0 {
+			log.Printf("[CLASP] Alert webhook enabled (budget alert at $%.2f)", s.cfg.AlertBudgetUSD)
+		} else {
+			log.Printf("[CLASP] Alert webhook enabled")
+		}
 	}
 
 	// Log and start health checker
@@ -241,10 +415,26 @@ func (s *Server) Start() error {
 		handler = AuthMiddleware(s.authConfig)(handler)
 		log.Printf("[CLASP] Authentication enabled (anonymous health: %v, anonymous metrics: %v)",
 			s.authConfig.AllowAnonymousHealth, s.authConfig.AllowAnonymousMetrics)
+		if s.authConfig.AnonymousEndpoints != nil {
+			log.Printf("[CLASP] Anonymous endpoints: %s", strings.Join(s.authConfig.AnonymousEndpoints, ", "))
+		}
 	} else {
 		log.Printf("[CLASP] Warning: Authentication is disabled. Set AUTH_ENABLED=true for production use.")
 	}
 
+	// Apply CORS outside auth so preflight requests (which carry no credentials) succeed
+	if len(s.cfg.CORSOrigins) > 0 {
+		handler = CORSMiddleware(s.cfg.CORSOrigins)(handler)
+		log.Printf("[CLASP] CORS enabled for origins: %s", strings.Join(s.cfg.CORSOrigins, ", "))
+	}
+
+	// Apply IP filtering before anything else so rejected clients never reach auth
+	if s.ipFilter != nil {
+		handler = IPFilterMiddleware(s.ipFilter)(handler)
+		log.Printf("[CLASP] IP filtering enabled: %d allowed, %d denied ranges (trust proxy headers: %v)",
+			len(s.cfg.IPAllowlist), len(s.cfg.IPDenylist), s.cfg.TrustProxyHeaders)
+	}
+
 	// Apply logging middleware
 	handler = loggingMiddleware(handler)
 
@@ -322,6 +512,12 @@ func (s *Server) Start() error {
 		go s.updateStatusPeriodically()
 	}
 
+	// Register for signals before serving so none reaches the default handler:
+	// SIGHUP reloads the configuration, SIGINT and SIGTERM shut down
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(sigCh)
+
 	// Start server in goroutine
 	errCh := make(chan error, 1)
 	go func() {
@@ -337,16 +533,26 @@ func (s *Server) Start() error {
 		}
 	}()
 
-	// Wait for interrupt signal
-	sigCh := make(chan os.Signal, 1)
-	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	// Warm up provider connections in the background; failures only warn
+	if s.cfg.Warmup {
+		go s.currentHandler().Warmup(context.Background())
+	}
 
-	select {
-	case err := <-errCh:
-		return fmt.Errorf("server error: %w", err)
-	case sig := <-sigCh:
-		log.Printf("[CLASP] Received signal %v, shutting down...", sig)
-		return s.Shutdown()
+	for {
+		select {
+		case err := <-errCh:
+			return fmt.Errorf("server error: %w", err)
+		case sig := <-sigCh:
+			if sig == syscall.SIGHUP {
+				log.Printf("[CLASP] Received SIGHUP, reloading configuration...")
+				if err := s.Reload(); err != nil {
+					log.Printf("[CLASP] Reload failed, keeping the current configuration: %v", err)
+				}
+				continue
+			}
+			log.Printf("[CLASP] Received signal %v, shutting down...", sig)
+			return s.Shutdown()
+		}
 	}
 }
 
@@ -385,7 +591,7 @@ func findAvailablePort(startPort int) (int, error) {
 // GetPort returns the actual port the server is running on.
 // This is useful when auto-port selection is used.
 func (s *Server) GetPort() int {
-	return s.cfg.Port
+	return s.currentConfig().Port
 }
 
 // Shutdown gracefully shuts down the server.
@@ -393,6 +599,14 @@ func (s *Server) Shutdown() error {
 	// Signal all goroutines to stop
 	close(s.shutdownCh)
 
+	// Fail readiness checks while in-flight requests drain
+	s.currentHandler().SetDraining()
+
+	// Fail requests still waiting in the queue and stop its workers
+	if s.queue != nil {
+		s.queue.Close()
+	}
+
 	// Stop health checker
 	if s.healthChecker != nil {
 		s.healthChecker.Stop()
@@ -401,6 +615,11 @@ func (s *Server) Shutdown() error {
 	// Stop session tracker cleanup goroutine
 	if s.sessionTracker != nil {
 		s.sessionTracker.Stop()
+		if s.cfg.SessionFile != "" {
+			if err := s.sessionTracker.Save(s.cfg.SessionFile); err != nil {
+				log.Printf("[CLASP] Warning: Could not save sessions: %v", err)
+			}
+		}
 	}
 
 	// Mark status as stopped
@@ -413,7 +632,19 @@ func (s *Server) Shutdown() error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	if err := s.server.Shutdown(ctx); err != nil {
+	var err error
+	if s.server != nil {
+		err = s.server.Shutdown(ctx)
+	}
+
+	// Close the audit log once in-flight requests have been written
+	if s.auditLog != nil {
+		if closeErr := s.auditLog.Close(); closeErr != nil {
+			log.Printf("[CLASP] Warning: Could not close audit log: %v", closeErr)
+		}
+	}
+
+	if err != nil {
 		return fmt.Errorf("shutdown error: %w", err)
 	}
 
@@ -437,8 +668,11 @@ func (s *Server) updateStatusPeriodically() {
 			}
 
 			// Get metrics from handler
-			metrics := s.handler.GetMetrics()
-			costs := s.handler.GetCostTracker()
+			s.mu.RLock()
+			handler, requestCache := s.handler, s.cache
+			s.mu.RUnlock()
+			metrics := handler.GetMetrics()
+			costs := handler.GetCostTracker()
 
 			// Calculate average latency
 			var avgLatency float64
@@ -448,8 +682,8 @@ func (s *Server) updateStatusPeriodically() {
 
 			// Get cache hit rate if available
 			var cacheHitRate float64
-			if s.cache != nil {
-				_, _, hits, misses, _ := s.cache.Stats()
+			if requestCache != nil {
+				_, _, hits, misses, _ := requestCache.Stats()
 				total := hits + misses
 				if total > 0 {
 					cacheHitRate = float64(hits) / float64(total)
@@ -468,16 +702,21 @@ func (s *Server) updateStatusPeriodically() {
 			}
 
 			// Update cache stats
-			if s.cache != nil {
-				_ = s.statusManager.UpdateCacheStats(true, cacheHitRate)
-			}
+			_ = s.statusManager.UpdateCacheStats(requestCache != nil, cacheHitRate)
+
+			// Update token totals
+			summary := costs.GetSummary()
+			_ = s.statusManager.UpdateTokens(summary.TotalInputTokens, summary.TotalOutputTokens)
+
+			// Update upstream provider health
+			_ = s.statusManager.UpdateUpstreamHealth(handler.UpstreamHealthy())
 		}
 	}
 }
 
 // GetHandler returns the handler for testing and metrics access.
 func (s *Server) GetHandler() *Handler {
-	return s.handler
+	return s.currentHandler()
 }
 
 // loggingMiddleware logs incoming requests.
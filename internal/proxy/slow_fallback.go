@@ -0,0 +1,134 @@
+package proxy
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/jedarden/clasp/internal/provider"
+	"github.com/jedarden/clasp/pkg/models"
+)
+
+// slowRaceResult is the outcome of raceSlowPrimary.
+type slowRaceResult struct {
+	resp *http.Response
+	err  error
+
+	raced           bool   // The primary was slow and the fallback was started
+	fromFallback    bool   // resp is the fallback's response
+	targetModel     string // Model the fallback was asked for, when raced
+	useResponsesAPI bool   // Whether the fallback request used the Responses API, when raced
+}
+
+// ok reports whether the result is a usable response.
+func (r slowRaceResult) ok() bool {
+	return r.err == nil && r.resp != nil && r.resp.StatusCode < 500
+}
+
+// raceContextKey is the request context key for the context that cancels an
+// upstream request once it has lost a slow-primary race.
+type raceContextKey struct{}
+
+// withRaceCancel returns a context whose upstream requests are cancelled by
+// the returned function. Upstream calls are not tied to the client
+// connection, so the race context derives from the upstream context.
+func withRaceCancel(ctx context.Context) (context.Context, context.CancelFunc) {
+	upstreamCtx, _ := upstreamContext(ctx)
+	raceCtx, cancel := context.WithCancel(upstreamCtx)
+	return context.WithValue(ctx, raceContextKey{}, raceCtx), cancel
+}
+
+// raceSlowPrimary sends reqBody to the primary provider. If the primary has not
+// responded within CLASP_FALLBACK_SLOW_MS, the request is also sent to the
+// fallback provider and whichever returns a usable response first wins; the
+// other request is cancelled. A failure of one side waits for the other.
+func (h *Handler) raceSlowPrimary(ctx context.Context, req *models.AnthropicRequest, reqBody []byte, p provider.Provider, targetModel string) slowRaceResult {
+	results := make(chan slowRaceResult, 2)
+
+	primaryCtx, cancelPrimary := withRaceCancel(ctx)
+	go func() {
+		resp, err := h.doRequestWithRetry(primaryCtx, reqBody, p)
+		results <- slowRaceResult{resp: resp, err: err}
+	}()
+
+	timer := time.NewTimer(time.Duration(h.cfg.FallbackSlowMs) * time.Millisecond)
+	defer timer.Stop()
+
+	select {
+	case r := <-results:
+		return keepAlive(r, cancelPrimary)
+	case <-timer.C:
+	}
+
+	fallbackProvider, fallbackModel := h.getFallbackProvider(req.Model)
+	if fallbackProvider == nil || !fallbackAllowed(ctx) {
+		return keepAlive(<-results, cancelPrimary)
+	}
+
+	atomic.AddInt64(&h.metrics.SlowFallbackRaces, 1)
+	log.Printf("[CLASP] Primary provider has not responded in %dms, racing fallback %s", h.cfg.FallbackSlowMs, fallbackProvider.Name())
+
+	fallbackCtx, cancelFallback := withRaceCancel(ctx)
+	cancelOf := func(r slowRaceResult) context.CancelFunc {
+		if r.fromFallback {
+			return cancelFallback
+		}
+		return cancelPrimary
+	}
+	go func() {
+		resp, fallbackTarget, useResponsesAPI, err := h.sendToFallback(fallbackCtx, req, fallbackProvider, fallbackModel, targetModel)
+		results <- slowRaceResult{resp: resp, err: err, fromFallback: true, targetModel: fallbackTarget, useResponsesAPI: useResponsesAPI}
+	}()
+
+	winner := <-results
+	if !winner.ok() {
+		// Discard the failure and wait for the other request
+		closeResult(winner)
+		cancelOf(winner)()
+		winner = <-results
+	} else {
+		// Release the loser's response if it still arrives
+		go func() {
+			loser := <-results
+			closeResult(loser)
+			cancelOf(loser)()
+		}()
+	}
+	// Cancel the loser; the winner's request lives until its body is closed
+	if winner.fromFallback {
+		cancelPrimary()
+	} else {
+		cancelFallback()
+	}
+	winner = keepAlive(winner, cancelOf(winner))
+
+	if !winner.fromFallback {
+		winner.targetModel = targetModel
+	} else if winner.ok() {
+		atomic.AddInt64(&h.metrics.SlowFallbackWins, 1)
+		log.Printf("[CLASP] Slow primary: fallback %s responded first", fallbackProvider.Name())
+		auditRoute(ctx, fallbackProvider.Name(), winner.targetModel, true)
+	}
+	winner.raced = true
+	return winner
+}
+
+// keepAlive ties cancel to the result's response body, or calls it right away
+// when there is no response.
+func keepAlive(r slowRaceResult, cancel context.CancelFunc) slowRaceResult {
+	if r.resp == nil {
+		cancel()
+		return r
+	}
+	r.resp.Body = &cancelOnClose{ReadCloser: r.resp.Body, cancel: cancel}
+	return r
+}
+
+// closeResult releases the response of a discarded result.
+func closeResult(r slowRaceResult) {
+	if r.resp != nil {
+		r.resp.Body.Close()
+	}
+}
@@ -0,0 +1,116 @@
+// Package proxy implements the HTTP proxy server.
+package proxy
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// stickyRoute identifies which provider served a conversation.
+type stickyRoute int
+
+const (
+	routePrimary stickyRoute = iota
+	routeFallback
+)
+
+// String returns the route name used in logs.
+func (r stickyRoute) String() string {
+	if r == routeFallback {
+		return "fallback"
+	}
+	return "primary"
+}
+
+// StickyRouter pins conversations to the provider that served them, so later
+// turns are not switched mid-conversation. Entries expire after the TTL and the
+// least recently used entries are evicted once maxSize is reached.
+type StickyRouter struct {
+	mu sync.Mutex
+
+	maxSize int
+	ttl     time.Duration
+
+	entries map[string]*list.Element
+	lru     *list.List
+}
+
+// stickyEntry holds a conversation's pinned route for the LRU list.
+type stickyEntry struct {
+	conversationID string
+	route          stickyRoute
+	lastSeen       time.Time
+}
+
+// NewStickyRouter creates a sticky router.
+// maxSize: maximum number of pinned conversations (defaults to 10000)
+// ttl: how long a pin lasts after the conversation's last turn (0 = never expire)
+func NewStickyRouter(maxSize int, ttl time.Duration) *StickyRouter {
+	if maxSize <= 0 {
+		maxSize = 10000
+	}
+	return &StickyRouter{
+		maxSize: maxSize,
+		ttl:     ttl,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// Get returns the pinned route for a conversation.
+func (sr *StickyRouter) Get(conversationID string) (stickyRoute, bool) {
+	if conversationID == "" {
+		return routePrimary, false
+	}
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+
+	elem, ok := sr.entries[conversationID]
+	if !ok {
+		return routePrimary, false
+	}
+	entry := elem.Value.(*stickyEntry)
+	if sr.ttl > 0 && time.Since(entry.lastSeen) > sr.ttl {
+		sr.lru.Remove(elem)
+		delete(sr.entries, conversationID)
+		return routePrimary, false
+	}
+	return entry.route, true
+}
+
+// Set pins a conversation to a route and refreshes its TTL.
+func (sr *StickyRouter) Set(conversationID string, route stickyRoute) {
+	if conversationID == "" {
+		return
+	}
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+
+	if elem, ok := sr.entries[conversationID]; ok {
+		entry := elem.Value.(*stickyEntry)
+		entry.route = route
+		entry.lastSeen = time.Now()
+		sr.lru.MoveToFront(elem)
+		return
+	}
+
+	for sr.lru.Len() >= sr.maxSize {
+		oldest := sr.lru.Back()
+		sr.lru.Remove(oldest)
+		delete(sr.entries, oldest.Value.(*stickyEntry).conversationID)
+	}
+
+	sr.entries[conversationID] = sr.lru.PushFront(&stickyEntry{
+		conversationID: conversationID,
+		route:          route,
+		lastSeen:       time.Now(),
+	})
+}
+
+// Len returns the number of pinned conversations.
+func (sr *StickyRouter) Len() int {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+	return sr.lru.Len()
+}
@@ -0,0 +1,68 @@
+// Package proxy implements the HTTP proxy server.
+package proxy
+
+import (
+	"context"
+	"log"
+	"net/http"
+
+	"github.com/jedarden/clasp/internal/translator"
+	"github.com/jedarden/clasp/pkg/models"
+)
+
+// streamCacheKey returns the response cache key of a streaming request when
+// CLASP_CACHE_STREAMING is on. Streaming and non-streaming requests share
+// entries, since both are cached as the final Anthropic response.
+func (h *Handler) streamCacheKey(req *models.AnthropicRequest) (string, bool) {
+	if !h.cfg.CacheStreaming {
+		return "", false
+	}
+	keyReq := *req
+	keyReq.Stream = false
+	return h.cacheKey(&keyReq)
+}
+
+// writeCachedStream replays a cached response to a streaming request.
+func (h *Handler) writeCachedStream(w http.ResponseWriter, resp *models.AnthropicResponse) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-CLASP-Cache", "HIT")
+
+	fw := &flushWriter{w: w}
+	if f, ok := w.(http.Flusher); ok {
+		fw.flusher = f
+	}
+	if err := translator.ReplayStream(fw, resp); err != nil {
+		log.Printf("[CLASP] Error replaying cached stream: %v", err)
+	}
+}
+
+// streamResult is implemented by the stream processors.
+type streamResult interface {
+	usageReporter
+	CapturedResponse() *models.AnthropicResponse
+	FinishReasonReceived() bool
+}
+
+// storeStreamedResponse caches the response reconstructed from a completed
+// stream. The stream's final usage replaces the placeholder counts of its
+// message_start event. Streams that ended before the upstream sent a finish
+// reason are incomplete and not cached, although the processor closed them
+// with a stop reason.
+func (h *Handler) storeStreamedResponse(ctx context.Context, cacheKey string, processor streamResult) {
+	requestCache := h.activeCache()
+	if requestCache == nil || !processor.FinishReasonReceived() {
+		return
+	}
+	resp := processor.CapturedResponse()
+	if resp == nil || resp.StopReason == "" {
+		return
+	}
+	inputTokens, outputTokens := processor.GetUsage()
+	if inputTokens > 0 || outputTokens > 0 {
+		resp.Usage = &models.AnthropicUsage{InputTokens: inputTokens, OutputTokens: outputTokens}
+	}
+	requestCache.Set(cacheKey, resp)
+	h.storeSemanticCache(ctx, resp)
+}
@@ -0,0 +1,22 @@
+// Package proxy implements the HTTP proxy server.
+package proxy
+
+import (
+	"github.com/jedarden/clasp/internal/provider"
+)
+
+// streamUsageEnabled reports whether streaming Chat Completions requests to p
+// ask for usage with stream_options.include_usage. CLASP_STREAM_INCLUDE_USAGE
+// forces it on or off; "auto" follows the provider's capability.
+func (h *Handler) streamUsageEnabled(p provider.Provider) bool {
+	switch h.cfg.StreamIncludeUsage {
+	case "on":
+		return true
+	case "off":
+		return false
+	}
+	if sp, ok := p.(provider.StreamUsageProvider); ok {
+		return sp.SupportsStreamUsage()
+	}
+	return true
+}
@@ -0,0 +1,98 @@
+// Package proxy implements the HTTP proxy server.
+package proxy
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TimeoutHeader sets a per-request upstream timeout in seconds.
+const TimeoutHeader = "X-CLASP-Timeout"
+
+// upstreamContextKey is the request context key for the context used by upstream calls.
+type upstreamContextKey struct{}
+
+// requestTimeout returns the upstream timeout requested via X-CLASP-Timeout,
+// clamped to the configured bounds. Returns false when the header is absent or
+// invalid, in which case the global HTTP client timeout applies.
+func (h *Handler) requestTimeout(r *http.Request) (time.Duration, bool) {
+	raw := strings.TrimSpace(r.Header.Get(TimeoutHeader))
+	if raw == "" {
+		return 0, false
+	}
+	seconds, err := strconv.ParseFloat(raw, 64)
+	if err != nil || seconds <= 0 {
+		log.Printf("[CLASP] Ignoring invalid %s header %q", TimeoutHeader, raw)
+		return 0, false
+	}
+
+	timeout := time.Duration(seconds * float64(time.Second))
+	minTimeout := time.Duration(h.cfg.RequestTimeoutMinSec) * time.Second
+	maxTimeout := time.Duration(h.cfg.RequestTimeoutMaxSec) * time.Second
+	if timeout < minTimeout {
+		log.Printf("[CLASP] %s %s below minimum, using %v", TimeoutHeader, raw, minTimeout)
+		timeout = minTimeout
+	}
+	if maxTimeout > 0 && timeout > maxTimeout {
+		log.Printf("[CLASP] %s %s above maximum, using %v", TimeoutHeader, raw, maxTimeout)
+		timeout = maxTimeout
+	}
+	return timeout, true
+}
+
+// withRequestTimeout applies an X-CLASP-Timeout override to the request's upstream calls.
+// The returned cancel function must be called once the response has been written.
+func (h *Handler) withRequestTimeout(r *http.Request) (*http.Request, context.CancelFunc) {
+	timeout, ok := h.requestTimeout(r)
+	if !ok {
+		return r, func() {}
+	}
+	// Upstream calls are not tied to the client connection, only to the deadline
+	upstreamCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	return r.WithContext(context.WithValue(r.Context(), upstreamContextKey{}, upstreamCtx)), cancel
+}
+
+// upstreamContext returns the context for an upstream call and whether it
+// carries a per-request timeout.
+func upstreamContext(ctx context.Context) (context.Context, bool) {
+	if upstreamCtx, ok := ctx.Value(upstreamContextKey{}).(context.Context); ok {
+		return upstreamCtx, true
+	}
+	return context.Background(), false
+}
+
+// tierTimeoutContextKey is the request context key for the upstream timeout of the request's tier.
+type tierTimeoutContextKey struct{}
+
+// withTierTimeout records the upstream timeout of the tier serving model, if
+// the tier overrides the global HTTP client timeout.
+func (h *Handler) withTierTimeout(ctx context.Context, model string) context.Context {
+	tierCfg := h.cfg.GetTierConfig(model)
+	if tierCfg == nil || tierCfg.HTTPTimeoutSec <= 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, tierTimeoutContextKey{}, time.Duration(tierCfg.HTTPTimeoutSec)*time.Second)
+}
+
+// tierTimeout returns the tier timeout recorded by withTierTimeout.
+func tierTimeout(ctx context.Context) (time.Duration, bool) {
+	timeout, ok := ctx.Value(tierTimeoutContextKey{}).(time.Duration)
+	return timeout, ok
+}
+
+// cancelOnClose releases an upstream attempt's deadline once its response body is closed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
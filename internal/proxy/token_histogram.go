@@ -0,0 +1,85 @@
+// Package proxy implements the HTTP proxy server.
+package proxy
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+)
+
+// tokenHistogramBuckets are the upper bounds of the per-request token
+// histograms, spanning short prompts to full long-context requests.
+var tokenHistogramBuckets = []int{100, 500, 1000, 4000, 16000, 64000, 256000}
+
+// tokenHistogram counts per-request token totals in tokenHistogramBuckets.
+// It is guarded by the owning CostTracker's mutex.
+type tokenHistogram struct {
+	buckets []int64 // Non-cumulative count per bucket, plus one for +Inf
+	sum     int64
+	count   int64
+}
+
+func newTokenHistogram() *tokenHistogram {
+	return &tokenHistogram{buckets: make([]int64, len(tokenHistogramBuckets)+1)}
+}
+
+func (th *tokenHistogram) observe(tokens int) {
+	i := sort.SearchInts(tokenHistogramBuckets, tokens)
+	th.buckets[i]++
+	th.sum += int64(tokens)
+	th.count++
+}
+
+// observeTokens records a request's token counts in the model's histograms.
+// ct.mu must be held.
+func (ct *CostTracker) observeTokens(model string, inputTokens, outputTokens int) {
+	if ct.inputTokenHists == nil {
+		ct.inputTokenHists = make(map[string]*tokenHistogram)
+		ct.outputTokenHists = make(map[string]*tokenHistogram)
+	}
+	for _, obs := range []struct {
+		hists  map[string]*tokenHistogram
+		tokens int
+	}{{ct.inputTokenHists, inputTokens}, {ct.outputTokenHists, outputTokens}} {
+		th, ok := obs.hists[model]
+		if !ok {
+			th = newTokenHistogram()
+			obs.hists[model] = th
+		}
+		th.observe(obs.tokens)
+	}
+}
+
+// WriteTokenHistogramsPrometheus writes the per-model input and output token
+// histograms in Prometheus format.
+func (ct *CostTracker) WriteTokenHistogramsPrometheus(w io.Writer, provider string) {
+	ct.mu.RLock()
+	defer ct.mu.RUnlock()
+
+	writeTokenHistogram(w, "clasp_input_tokens", "Input tokens per request", provider, ct.inputTokenHists)
+	writeTokenHistogram(w, "clasp_output_tokens", "Output tokens per request", provider, ct.outputTokenHists)
+}
+
+func writeTokenHistogram(w io.Writer, name, help, provider string, hists map[string]*tokenHistogram) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(w, "# TYPE %s histogram\n", name)
+
+	models := make([]string, 0, len(hists))
+	for model := range hists {
+		models = append(models, model)
+	}
+	sort.Strings(models)
+
+	for _, model := range models {
+		th := hists[model]
+		var cumulative int64
+		for i, bound := range tokenHistogramBuckets {
+			cumulative += th.buckets[i]
+			fmt.Fprintf(w, "%s_bucket{provider=\"%s\",model=\"%s\",le=\"%s\"} %d\n", name, provider, model, strconv.Itoa(bound), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket{provider=\"%s\",model=\"%s\",le=\"+Inf\"} %d\n", name, provider, model, th.count)
+		fmt.Fprintf(w, "%s_sum{provider=\"%s\",model=\"%s\"} %d\n", name, provider, model, th.sum)
+		fmt.Fprintf(w, "%s_count{provider=\"%s\",model=\"%s\"} %d\n", name, provider, model, th.count)
+	}
+}
@@ -0,0 +1,114 @@
+// Package proxy implements the HTTP proxy server.
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// bodyError is an error object embedded in an otherwise successful (HTTP 200) upstream response.
+// Some OpenAI-compatible backends report failures this way instead of using a 4xx/5xx status.
+type bodyError struct {
+	Message string
+	Type    string
+	Code    string
+}
+
+// parseBodyError extracts an "error" field from an upstream response body.
+// The error may be an object ({"message", "type", "code"}) or a plain string.
+// Returns false if the body has no non-empty error field.
+func parseBodyError(body []byte) (*bodyError, bool) {
+	var envelope struct {
+		Error json.RawMessage `json:"error"`
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return nil, false
+	}
+	raw := strings.TrimSpace(string(envelope.Error))
+	if raw == "" || raw == "null" {
+		return nil, false
+	}
+
+	// Plain string error
+	var message string
+	if err := json.Unmarshal(envelope.Error, &message); err == nil {
+		if message == "" {
+			return nil, false
+		}
+		return &bodyError{Message: message}, true
+	}
+
+	// Object error; code may be a string or a number
+	var obj struct {
+		Message string      `json:"message"`
+		Type    string      `json:"type"`
+		Code    interface{} `json:"code"`
+	}
+	if err := json.Unmarshal(envelope.Error, &obj); err != nil {
+		return nil, false
+	}
+	be := &bodyError{Message: obj.Message, Type: obj.Type}
+	switch code := obj.Code.(type) {
+	case string:
+		be.Code = code
+	case float64:
+		be.Code = strconv.Itoa(int(code))
+	}
+	if be.Message == "" && be.Type == "" && be.Code == "" {
+		return nil, false
+	}
+	if be.Message == "" {
+		be.Message = "Upstream provider returned an error"
+	}
+	return be, true
+}
+
+// statusCode infers the HTTP status that best represents the embedded error.
+func (e *bodyError) statusCode() int {
+	// Numeric codes in the HTTP error range are used directly
+	if n, err := strconv.Atoi(e.Code); err == nil && n >= 400 && n < 600 {
+		return n
+	}
+
+	kind := strings.ToLower(e.Type + " " + e.Code)
+	switch {
+	case strings.Contains(kind, "rate_limit") || strings.Contains(kind, "quota"):
+		return http.StatusTooManyRequests
+	case strings.Contains(kind, "auth") || strings.Contains(kind, "api_key"):
+		return http.StatusUnauthorized
+	case strings.Contains(kind, "permission"):
+		return http.StatusForbidden
+	case strings.Contains(kind, "not_found") || strings.Contains(kind, "model_not_found"):
+		return http.StatusNotFound
+	case strings.Contains(kind, "invalid_request") || strings.Contains(kind, "context_length"):
+		return http.StatusBadRequest
+	case strings.Contains(kind, "overloaded"):
+		return 529
+	default:
+		return http.StatusBadGateway
+	}
+}
+
+// anthropicErrorType maps an HTTP status code to the Anthropic error type.
+func anthropicErrorType(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return "invalid_request_error"
+	case http.StatusUnauthorized:
+		return "authentication_error"
+	case http.StatusForbidden:
+		return "permission_error"
+	case http.StatusNotFound:
+		return "not_found_error"
+	case http.StatusRequestEntityTooLarge:
+		return "request_too_large"
+	case http.StatusTooManyRequests:
+		return "rate_limit_error"
+	case 529:
+		return "overloaded_error"
+	default:
+		return "api_error"
+	}
+}
@@ -0,0 +1,40 @@
+// Package proxy implements the HTTP proxy server.
+package proxy
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"time"
+)
+
+// newUpstreamTransport creates the pooled HTTP transport for upstream requests.
+// httpVersion selects the protocol negotiated with the upstream:
+//   - "h1" disables HTTP/2, for providers that reset HTTP/2 streams
+//   - "h2" offers HTTP/2 through ALPN even with the custom dialer
+//   - "auto" (or empty) keeps net/http's default negotiation
+func newUpstreamTransport(httpVersion string) *http.Transport {
+	transport := &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		MaxIdleConns:        100,
+		MaxIdleConnsPerHost: 100,
+		IdleConnTimeout:     90 * time.Second,
+		TLSHandshakeTimeout: 10 * time.Second,
+		DisableCompression:  false,
+	}
+
+	switch httpVersion {
+	case "h1":
+		// A non-nil, empty TLSNextProto map turns off HTTP/2 entirely
+		transport.ForceAttemptHTTP2 = false
+		transport.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+		transport.TLSClientConfig = &tls.Config{NextProtos: []string{"http/1.1"}}
+	case "h2":
+		transport.ForceAttemptHTTP2 = true
+		transport.TLSClientConfig = &tls.Config{NextProtos: []string{"h2", "http/1.1"}}
+	}
+	return transport
+}
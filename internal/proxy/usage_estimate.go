@@ -0,0 +1,50 @@
+// Package proxy implements the HTTP proxy server.
+package proxy
+
+import (
+	"context"
+
+	"github.com/jedarden/clasp/internal/provider"
+	"github.com/jedarden/clasp/internal/translator"
+	"github.com/jedarden/clasp/pkg/models"
+)
+
+// providersWithoutUsage lists providers whose responses often carry no token
+// usage. Ollama omits it for some models and versions, which would leave
+// cost tracking at zero, so usage is estimated for them instead.
+var providersWithoutUsage = map[string]bool{
+	"ollama": true,
+}
+
+// usageEstimateKey is the request context key for the estimated input tokens
+// of a request whose provider may not report usage.
+type usageEstimateKey struct{}
+
+// withUsageEstimate records the estimated input tokens of req in ctx when p
+// may not report usage: it is in providersWithoutUsage, or req is streamed
+// without stream_options.include_usage.
+func (h *Handler) withUsageEstimate(ctx context.Context, req *models.AnthropicRequest, p provider.Provider) context.Context {
+	if !providersWithoutUsage[p.Name()] && !(req.Stream && !h.streamUsageEnabled(p)) {
+		return ctx
+	}
+	return context.WithValue(ctx, usageEstimateKey{}, translator.EstimateInputTokens(req))
+}
+
+// usageEstimate returns the estimated input tokens recorded by withUsageEstimate.
+func usageEstimate(ctx context.Context) (int, bool) {
+	tokens, ok := ctx.Value(usageEstimateKey{}).(int)
+	return tokens, ok
+}
+
+// estimateMissingUsage fills in the usage of a response whose upstream
+// reported none, if the request was estimated.
+func estimateMissingUsage(ctx context.Context, resp *models.AnthropicResponse) {
+	inputTokens, ok := usageEstimate(ctx)
+	if !ok || resp.Usage != nil && (resp.Usage.InputTokens > 0 || resp.Usage.OutputTokens > 0) {
+		return
+	}
+	resp.Usage = &models.AnthropicUsage{
+		InputTokens:  inputTokens,
+		OutputTokens: translator.EstimateOutputTokens(resp.Content),
+	}
+}
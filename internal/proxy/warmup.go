@@ -0,0 +1,96 @@
+// Package proxy implements the HTTP proxy server.
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/jedarden/clasp/internal/config"
+	"github.com/jedarden/clasp/internal/provider"
+)
+
+// WarmupResult is the outcome of warming up one provider.
+type WarmupResult struct {
+	Name     string // "primary", "fallback", "fallback_chain_<n>", or a tier
+	Provider string
+	Status   int // HTTP status of the probe (0 when the request failed)
+	Latency  time.Duration
+	Err      error
+}
+
+// warmupTarget is a provider to warm up.
+type warmupTarget struct {
+	name     string
+	provider provider.Provider
+}
+
+// warmupTargets returns the configured providers, each once: the primary,
+// the fallback and fallback chain, and the tier providers and their fallbacks.
+func (h *Handler) warmupTargets() []warmupTarget {
+	seen := make(map[provider.Provider]bool)
+	var targets []warmupTarget
+	add := func(name string, p provider.Provider) {
+		if p != nil && !seen[p] {
+			seen[p] = true
+			targets = append(targets, warmupTarget{name, p})
+		}
+	}
+
+	add("primary", h.provider)
+	add("fallback", h.fallbackProvider)
+	for i, hop := range h.fallbackChain {
+		add(fmt.Sprintf("fallback_chain_%d", i+1), hop.provider)
+	}
+	for _, tier := range []config.ModelTier{config.TierOpus, config.TierSonnet, config.TierHaiku} {
+		add(string(tier), h.tierProviders[tier])
+		add(string(tier)+"_fallback", h.tierFallbacks[tier])
+	}
+	return targets
+}
+
+// Warmup sends a lightweight request to each configured provider, so the first
+// client request finds DNS resolved and a connection open, and logs whether
+// the provider accepted the API key. Failures are logged as warnings only.
+func (h *Handler) Warmup(ctx context.Context) []WarmupResult {
+	targets := h.warmupTargets()
+	results := make([]WarmupResult, len(targets))
+	timeout := time.Duration(h.cfg.HealthCheckTimeoutSec) * time.Second
+
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		wg.Add(1)
+		go func(i int, target warmupTarget) {
+			defer wg.Done()
+			probeCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			start := time.Now()
+			status, err := probeProvider(probeCtx, h.client, target.provider, h.cfg.GetAPIKey())
+			results[i] = WarmupResult{
+				Name:     target.name,
+				Provider: target.provider.Name(),
+				Status:   status,
+				Latency:  time.Since(start),
+				Err:      err,
+			}
+		}(i, target)
+	}
+	wg.Wait()
+
+	for _, r := range results {
+		switch {
+		case r.Err != nil:
+			log.Printf("[CLASP] Warmup: warning: %s (%s) unreachable: %v", r.Name, r.Provider, r.Err)
+		case r.Status == http.StatusUnauthorized || r.Status == http.StatusForbidden:
+			log.Printf("[CLASP] Warmup: warning: %s (%s) rejected the API key (status %d)", r.Name, r.Provider, r.Status)
+		case r.Status >= 500:
+			log.Printf("[CLASP] Warmup: warning: %s (%s) returned status %d", r.Name, r.Provider, r.Status)
+		default:
+			log.Printf("[CLASP] Warmup: %s (%s) ready in %dms", r.Name, r.Provider, r.Latency.Milliseconds())
+		}
+	}
+	return results
+}
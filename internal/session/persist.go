@@ -0,0 +1,89 @@
+// Package session manages conversation session state for Responses API compaction.
+package session
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// persistedEntry is the on-disk form of a session entry.
+type persistedEntry struct {
+	ResponseID   string    `json:"response_id"`
+	MessageCount int       `json:"message_count"`
+	LastSeen     time.Time `json:"last_seen"`
+}
+
+// Load restores sessions saved by Save. A missing file is not an error, and
+// sessions that expired while the proxy was down are skipped. It returns the
+// number of sessions restored.
+func (t *Tracker) Load(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("reading session file: %w", err)
+	}
+	var saved map[string]persistedEntry
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return 0, fmt.Errorf("parsing session file: %w", err)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	restored := 0
+	for key, e := range saved {
+		if key == "" || e.ResponseID == "" {
+			continue
+		}
+		if t.ttl > 0 && time.Since(e.LastSeen) > t.ttl {
+			continue
+		}
+		t.entries[key] = &Entry{
+			ResponseID:   e.ResponseID,
+			MessageCount: e.MessageCount,
+			LastSeen:     e.LastSeen,
+		}
+		restored++
+	}
+	return restored, nil
+}
+
+// Save writes the unexpired sessions to path so a restarted proxy can keep
+// continuing conversations. The file is replaced atomically and is only
+// readable by the owner.
+func (t *Tracker) Save(path string) error {
+	t.mu.Lock()
+	saved := make(map[string]persistedEntry, len(t.entries))
+	for key, e := range t.entries {
+		if t.ttl > 0 && time.Since(e.LastSeen) > t.ttl {
+			continue
+		}
+		saved[key] = persistedEntry{
+			ResponseID:   e.ResponseID,
+			MessageCount: e.MessageCount,
+			LastSeen:     e.LastSeen,
+		}
+	}
+	t.mu.Unlock()
+
+	data, err := json.Marshal(saved)
+	if err != nil {
+		return fmt.Errorf("encoding sessions: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("creating session directory: %w", err)
+	}
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o600); err != nil {
+		return fmt.Errorf("writing session file: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("writing session file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package session
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTrackerSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "sessions.json")
+
+	tr := NewTracker(time.Minute)
+	tr.Set("session1", "resp_abc", 4)
+	tr.Set("session2", "resp_def", 2)
+	if err := tr.Save(path); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	tr.Stop()
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("expected session file: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Errorf("got file mode %o, want 600", perm)
+	}
+
+	restored := NewTracker(time.Minute)
+	defer restored.Stop()
+	n, err := restored.Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("got %d restored sessions, want 2", n)
+	}
+	entry, ok := restored.Get("session1")
+	if !ok {
+		t.Fatal("expected entry for session1 after Load")
+	}
+	if entry.ResponseID != "resp_abc" || entry.MessageCount != 4 {
+		t.Errorf("got %+v, want resp_abc with 4 messages", entry)
+	}
+}
+
+func TestTrackerLoadSkipsExpired(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sessions.json")
+	old := time.Now().Add(-2 * time.Minute).UTC().Format(time.RFC3339Nano)
+	data := `{"stale":{"response_id":"resp_old","message_count":2,"last_seen":"` + old + `"}}`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tr := NewTracker(time.Minute)
+	defer tr.Stop()
+	n, err := tr.Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if n != 0 || tr.Len() != 0 {
+		t.Errorf("expected the expired session to be skipped, restored %d", n)
+	}
+}
+
+func TestTrackerLoadMissingFile(t *testing.T) {
+	tr := NewTracker(time.Minute)
+	defer tr.Stop()
+	n, err := tr.Load(filepath.Join(t.TempDir(), "missing.json"))
+	if err != nil || n != 0 {
+		t.Errorf("got (%d, %v), want (0, nil) for a missing file", n, err)
+	}
+}
@@ -14,6 +14,9 @@ import (
 	"runtime"
 	"strings"
 	"time"
+
+	"github.com/jedarden/clasp/internal/claudecode"
+	"github.com/jedarden/clasp/internal/logging"
 )
 
 // DiagnosticResult represents the result of a single diagnostic check.
@@ -28,12 +31,14 @@ type DiagnosticResult struct {
 type Doctor struct {
 	results []DiagnosticResult
 	verbose bool
+	wizard  *Wizard // used for provider model listing
 }
 
 // NewDoctor creates a new Doctor instance.
 func NewDoctor(verbose bool) *Doctor {
 	return &Doctor{
 		verbose: verbose,
+		wizard:  NewWizard(),
 	}
 }
 
@@ -50,6 +55,7 @@ func (d *Doctor) Run() []DiagnosticResult {
 	d.checkConfigDirectory()
 	d.checkConfigFile()
 	d.checkProfiles()
+	d.checkLogDirectory()
 
 	// API Key checks
 	d.checkAPIKeys()
@@ -175,9 +181,35 @@ func (d *Doctor) checkConfigFile() {
 	if cfg.Provider == "" {
 		d.addResult("Configuration", "warning", "No provider configured",
 			"Run 'clasp -setup' to configure a provider")
-	} else {
-		d.addResult("Configuration", "ok", fmt.Sprintf("Provider: %s, Model: %s", cfg.Provider, cfg.Model), "")
+		return
+	}
+	if err := ValidateConfigFile(&cfg); err != nil {
+		d.addResult("Configuration", "error", fmt.Sprintf("Invalid configuration: %v", err),
+			"Run 'clasp -setup' or edit ~/.clasp/config.json to add the missing settings")
+		return
+	}
+	d.addResult("Configuration", "ok", fmt.Sprintf("Provider: %s, Model: %s", cfg.Provider, cfg.Model), "")
+}
+
+// checkLogDirectory verifies the log directory can be written.
+func (d *Doctor) checkLogDirectory() {
+	logDir := filepath.Dir(logging.GetLogPath())
+
+	if err := os.MkdirAll(logDir, 0o755); err != nil {
+		d.addResult("Log Directory", "error", fmt.Sprintf("Cannot create %s: %v", logDir, err),
+			"Check permissions on ~/.clasp or set HOME to a writable directory")
+		return
 	}
+	f, err := os.CreateTemp(logDir, ".doctor-*")
+	if err != nil {
+		d.addResult("Log Directory", "error", fmt.Sprintf("%s is not writable: %v", logDir, err),
+			fmt.Sprintf("Fix permissions: chmod u+w %s", logDir))
+		return
+	}
+	f.Close()
+	os.Remove(f.Name())
+
+	d.addResult("Log Directory", "ok", fmt.Sprintf("%s is writable", logDir), "")
 }
 
 // checkProfiles verifies profiles are properly configured.
@@ -245,8 +277,14 @@ func (d *Doctor) checkAPIKeys() {
 	}
 }
 
-// checkProviderConnectivity tests connectivity to configured providers.
+// checkProviderConnectivity tests connectivity to the configured provider,
+// falling back to general internet reachability when none is configured.
 func (d *Doctor) checkProviderConnectivity() {
+	if cfg := providerConfig(); cfg != nil {
+		d.results = append(d.results, d.CheckProviderReachability(cfg))
+		return
+	}
+
 	client := &http.Client{Timeout: 5 * time.Second}
 
 	// Test OpenAI API reachability (doesn't require auth for this check)
@@ -279,6 +317,115 @@ func (d *Doctor) checkProviderConnectivity() {
 	}
 }
 
+// CheckProviderReachability makes a cheap call to the provider's API to verify
+// the endpoint is reachable and the API key is accepted. Providers with a
+// models endpoint are checked by listing models; others by contacting the API host.
+func (d *Doctor) CheckProviderReachability(cfg *ConfigFile) DiagnosticResult {
+	name := fmt.Sprintf("Upstream (%s)", cfg.Provider)
+
+	switch cfg.Provider {
+	case "openai", "openrouter", "ollama", "custom", "litellm":
+		models, err := d.wizard.FetchModelsPublic(cfg.Provider, cfg.APIKey, cfg.BaseURL, cfg.AzureEndpoint)
+		if err != nil {
+			return upstreamFailure(name, err)
+		}
+		return DiagnosticResult{Name: name, Status: "ok", Message: fmt.Sprintf("Reachable, %d model(s) listed", len(models))}
+	}
+
+	var hostURL string
+	switch cfg.Provider {
+	case "azure":
+		hostURL = cfg.AzureEndpoint
+	case "anthropic":
+		hostURL = "https://api.anthropic.com"
+	case "gemini":
+		hostURL = "https://generativelanguage.googleapis.com"
+	case "deepseek":
+		hostURL = "https://api.deepseek.com"
+	}
+	if hostURL == "" {
+		return DiagnosticResult{Name: name, Status: "warning", Message: "No endpoint to check",
+			Fix: "Set the provider's endpoint in the config file or environment"}
+	}
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodHead, hostURL, http.NoBody)
+	if err != nil {
+		return upstreamFailure(name, err)
+	}
+	resp, err := d.wizard.client.Do(req)
+	if err != nil {
+		return upstreamFailure(name, err)
+	}
+	resp.Body.Close()
+	return DiagnosticResult{Name: name, Status: "ok", Message: fmt.Sprintf("%s is reachable", hostURL)}
+}
+
+// upstreamFailure describes a failed provider check with a remediation hint.
+func upstreamFailure(name string, err error) DiagnosticResult {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "(401)") || strings.Contains(msg, "(403)"):
+		return DiagnosticResult{Name: name, Status: "error", Message: "API key was rejected",
+			Fix: "Check the API key, or run 'clasp -setup' to enter a new one"}
+	case strings.Contains(msg, "(404)"):
+		return DiagnosticResult{Name: name, Status: "error", Message: "Endpoint not found (404)",
+			Fix: "Check the base URL, endpoint, or deployment name"}
+	default:
+		return DiagnosticResult{Name: name, Status: "error", Message: fmt.Sprintf("Cannot reach provider: %v", err),
+			Fix: "Check your internet connection, firewall, and the configured base URL"}
+	}
+}
+
+// providerKeyEnvVars maps providers to the environment variable holding their API key.
+var providerKeyEnvVars = map[string]string{
+	"openai":     "OPENAI_API_KEY",
+	"azure":      "AZURE_API_KEY",
+	"openrouter": "OPENROUTER_API_KEY",
+	"anthropic":  "ANTHROPIC_API_KEY",
+	"gemini":     "GEMINI_API_KEY",
+	"deepseek":   "DEEPSEEK_API_KEY",
+	"litellm":    "LITELLM_API_KEY",
+	"custom":     "CUSTOM_API_KEY",
+}
+
+// providerConfig returns the provider settings CLASP would use: the config file
+// if it names a provider, otherwise the environment. Returns nil if neither does.
+func providerConfig() *ConfigFile {
+	if cfg, err := LoadConfig(); err == nil && cfg.Provider != "" {
+		return cfg
+	}
+
+	provider := os.Getenv("PROVIDER")
+	if provider == "" {
+		for _, p := range setupProviders {
+			if env, ok := providerKeyEnvVars[p]; ok && os.Getenv(env) != "" {
+				provider = p
+				break
+			}
+		}
+	}
+	if provider == "" {
+		return nil
+	}
+
+	cfg := &ConfigFile{
+		Provider:      provider,
+		AzureEndpoint: os.Getenv("AZURE_OPENAI_ENDPOINT"),
+	}
+	if env, ok := providerKeyEnvVars[provider]; ok {
+		cfg.APIKey = os.Getenv(env)
+	}
+	switch provider {
+	case "ollama":
+		cfg.BaseURL = os.Getenv("OLLAMA_BASE_URL")
+	case "litellm":
+		cfg.BaseURL = os.Getenv("LITELLM_BASE_URL")
+	case "custom":
+		cfg.BaseURL = os.Getenv("CUSTOM_BASE_URL")
+	}
+	return cfg
+}
+
 // checkPortAvailability checks if a port is available for the proxy.
 func (d *Doctor) checkPortAvailability(port int) {
 	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
@@ -296,25 +443,24 @@ func (d *Doctor) checkPortAvailability(port int) {
 
 // checkClaudeCodeInstallation verifies Claude Code CLI is installed.
 func (d *Doctor) checkClaudeCodeInstallation() {
-	// Check for claude command
-	claudePath, err := exec.LookPath("claude")
-	if err != nil {
-		d.addResult("Claude Code", "warning", "Claude Code CLI not found in PATH",
+	status, err := claudecode.NewManager("", false).CheckInstallation()
+	if err != nil || !status.Installed {
+		d.addResult("Claude Code", "warning", "Claude Code CLI not found",
 			"Run 'clasp' to automatically install Claude Code, or install with: npm install -g @anthropic-ai/claude-code")
 		return
 	}
 
-	// Get version
-	cmd := exec.Command(claudePath, "--version")
-	output, err := cmd.Output()
-	if err != nil {
-		d.addResult("Claude Code", "warning", fmt.Sprintf("Found at %s but could not get version", claudePath),
+	if status.Version == "" {
+		d.addResult("Claude Code", "warning", fmt.Sprintf("Found (%s) but could not get version", status.InstallMethod),
 			"Try reinstalling: npm install -g @anthropic-ai/claude-code")
 		return
 	}
 
-	version := strings.TrimSpace(string(output))
-	d.addResult("Claude Code", "ok", fmt.Sprintf("Version %s at %s", version, claudePath), "")
+	location := status.Path
+	if location == "" {
+		location = status.InstallMethod
+	}
+	d.addResult("Claude Code", "ok", fmt.Sprintf("Version %s at %s", status.Version, location), "")
 }
 
 // checkBinaryVersion verifies the CLASP binary version matches package.
@@ -0,0 +1,170 @@
+// Package setup provides interactive configuration wizards for first-run experience.
+package setup
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+)
+
+// azureKnownModels are offered for Azure when its deployments can't be listed.
+var azureKnownModels = []string{
+	"gpt-4",
+	"gpt-4o",
+	"gpt-4o-mini",
+	"gpt-4-turbo",
+	"gpt-35-turbo",
+}
+
+// defaultAzureMgmtURL is the Azure Resource Manager endpoint.
+const defaultAzureMgmtURL = "https://management.azure.com"
+
+// azureDeploymentsAPIVersion is the Azure Resource Manager API version used to
+// list Azure OpenAI deployments.
+const azureDeploymentsAPIVersion = "2023-05-01"
+
+// customModelsURL returns where to list a custom gateway's models: the
+// CUSTOM_MODELS_PATH path under baseURL, or a full URL given there. It reports
+// whether CUSTOM_MODELS_PATH was set.
+func customModelsURL(baseURL string) (string, bool) {
+	path := strings.TrimSpace(os.Getenv("CUSTOM_MODELS_PATH"))
+	if path == "" {
+		return strings.TrimSuffix(baseURL, "/") + "/models", false
+	}
+	if strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") {
+		return path, true
+	}
+	return strings.TrimSuffix(baseURL, "/") + "/" + strings.TrimPrefix(path, "/"), true
+}
+
+// parseModelList reads a model listing in any of the shapes gateways use: an
+// OpenAI-style {"data": [...]}, a {"models": [...]} object, or a bare array.
+// Entries may be model names or objects with an id or name.
+func parseModelList(body []byte) ([]string, error) {
+	var entries []json.RawMessage
+	if err := json.Unmarshal(body, &entries); err != nil {
+		var wrapped struct {
+			Data   []json.RawMessage `json:"data"`
+			Models []json.RawMessage `json:"models"`
+		}
+		if err := json.Unmarshal(body, &wrapped); err != nil {
+			return nil, fmt.Errorf("unrecognized models response: %w", err)
+		}
+		entries = wrapped.Data
+		if entries == nil {
+			entries = wrapped.Models
+		}
+	}
+
+	models := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		var name string
+		if err := json.Unmarshal(entry, &name); err != nil {
+			var obj struct {
+				ID   string `json:"id"`
+				Name string `json:"name"`
+			}
+			if err := json.Unmarshal(entry, &obj); err != nil {
+				continue
+			}
+			name = obj.ID
+			if name == "" {
+				name = obj.Name
+			}
+		}
+		if name != "" {
+			models = append(models, name)
+		}
+	}
+	sort.Strings(models)
+	return models, nil
+}
+
+// azureMgmt holds the Azure Resource Manager credentials and resource names
+// needed to list an Azure OpenAI resource's deployments.
+type azureMgmt struct {
+	baseURL       string
+	token         string
+	subscription  string
+	resourceGroup string
+	account       string
+}
+
+// azureMgmtFromEnv reads the AZURE_MGMT_* settings. The account defaults to
+// the resource name in the Azure OpenAI endpoint. It returns false unless a
+// token, subscription, resource group and account are all known.
+func azureMgmtFromEnv(azureEndpoint string) (azureMgmt, bool) {
+	m := azureMgmt{
+		baseURL:       os.Getenv("AZURE_MGMT_URL"),
+		token:         os.Getenv("AZURE_MGMT_TOKEN"),
+		subscription:  os.Getenv("AZURE_MGMT_SUBSCRIPTION_ID"),
+		resourceGroup: os.Getenv("AZURE_MGMT_RESOURCE_GROUP"),
+		account:       os.Getenv("AZURE_MGMT_ACCOUNT"),
+	}
+	if m.baseURL == "" {
+		m.baseURL = defaultAzureMgmtURL
+	}
+	if m.account == "" && azureEndpoint != "" {
+		if u, err := url.Parse(azureEndpoint); err == nil {
+			m.account = strings.SplitN(u.Hostname(), ".", 2)[0]
+		}
+	}
+	ok := m.token != "" && m.subscription != "" && m.resourceGroup != "" && m.account != ""
+	return m, ok
+}
+
+// fetchAzureModels lists the deployments of the Azure OpenAI resource through
+// the Azure Resource Manager API when AZURE_MGMT_* credentials are set, and
+// returns the common deployment names otherwise.
+func (w *Wizard) fetchAzureModels(azureEndpoint string) ([]string, error) {
+	mgmt, ok := azureMgmtFromEnv(azureEndpoint)
+	if !ok {
+		return azureKnownModels, nil
+	}
+
+	listURL := fmt.Sprintf("%s/subscriptions/%s/resourceGroups/%s/providers/Microsoft.CognitiveServices/accounts/%s/deployments?api-version=%s",
+		strings.TrimSuffix(mgmt.baseURL, "/"),
+		url.PathEscape(mgmt.subscription),
+		url.PathEscape(mgmt.resourceGroup),
+		url.PathEscape(mgmt.account),
+		azureDeploymentsAPIVersion)
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, listURL, http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+mgmt.token)
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return nil, w.modelsRequestError(err, listURL)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("Azure management API error (%d): %s", resp.StatusCode, string(body))
+	}
+
+	var deployments struct {
+		Value []struct {
+			Name string `json:"name"`
+		} `json:"value"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&deployments); err != nil {
+		return nil, fmt.Errorf("failed to decode Azure deployments: %w", err)
+	}
+	models := make([]string, 0, len(deployments.Value))
+	for _, d := range deployments.Value {
+		if d.Name != "" {
+			models = append(models, d.Name)
+		}
+	}
+	sort.Strings(models)
+	return models, nil
+}
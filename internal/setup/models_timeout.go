@@ -0,0 +1,50 @@
+// Package setup provides interactive configuration wizards for first-run experience.
+package setup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// DefaultModelsTimeout bounds a request for a provider's model list when
+// CLASP_MODELS_TIMEOUT is not set.
+const DefaultModelsTimeout = 15 * time.Second
+
+// ModelsTimeoutFromEnv returns the model listing timeout from
+// CLASP_MODELS_TIMEOUT, in seconds, or DefaultModelsTimeout when it is unset.
+func ModelsTimeoutFromEnv() (time.Duration, error) {
+	raw := os.Getenv("CLASP_MODELS_TIMEOUT")
+	if raw == "" {
+		return DefaultModelsTimeout, nil
+	}
+	seconds, err := strconv.Atoi(raw)
+	if err != nil || seconds <= 0 {
+		return 0, fmt.Errorf("invalid CLASP_MODELS_TIMEOUT: %s (must be a positive number of seconds)", raw)
+	}
+	return time.Duration(seconds) * time.Second, nil
+}
+
+// SetModelsTimeout sets how long the wizard waits for a provider's model list.
+func (w *Wizard) SetModelsTimeout(timeout time.Duration) {
+	w.client.Timeout = timeout
+}
+
+// ModelsTimeout returns how long the wizard waits for a provider's model list.
+func (w *Wizard) ModelsTimeout() time.Duration {
+	return w.client.Timeout
+}
+
+// modelsRequestError explains a failed model list request, pointing at the
+// timeout settings when the provider was too slow to answer.
+func (w *Wizard) modelsRequestError(err error, url string) error {
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return fmt.Errorf("timed out after %v waiting for %s (set CLASP_MODELS_TIMEOUT or --timeout to wait longer)", w.client.Timeout, url)
+	}
+	return err
+}
@@ -0,0 +1,107 @@
+// Package setup provides interactive configuration wizards for first-run experience.
+package setup
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jedarden/clasp/internal/translator"
+)
+
+// setupProviders lists the providers supported by setup, in wizard order.
+var setupProviders = []string{"openai", "azure", "openrouter", "anthropic", "ollama", "gemini", "deepseek", "litellm", "custom"}
+
+// ValidateConfigFile checks that a config has the fields its provider requires.
+func ValidateConfigFile(cfg *ConfigFile) error {
+	known := false
+	for _, p := range setupProviders {
+		if cfg.Provider == p {
+			known = true
+			break
+		}
+	}
+	if !known {
+		if cfg.Provider == "" {
+			return fmt.Errorf("provider is required (one of: %s)", strings.Join(setupProviders, ", "))
+		}
+		return fmt.Errorf("unknown provider %q (one of: %s)", cfg.Provider, strings.Join(setupProviders, ", "))
+	}
+
+	var missing []string
+	switch cfg.Provider {
+	case "openai", "openrouter", "anthropic", "gemini", "deepseek":
+		if cfg.APIKey == "" {
+			missing = append(missing, "api_key")
+		}
+	case "azure":
+		if cfg.APIKey == "" {
+			missing = append(missing, "api_key")
+		}
+		if cfg.AzureEndpoint == "" {
+			missing = append(missing, "azure_endpoint")
+		}
+		if cfg.AzureDeployment == "" {
+			missing = append(missing, "azure_deployment")
+		}
+	case "custom":
+		if cfg.BaseURL == "" {
+			missing = append(missing, "base_url")
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("provider %s requires: %s", cfg.Provider, strings.Join(missing, ", "))
+	}
+
+	if cfg.Provider == "azure" && translator.RequiresResponsesAPI(cfg.Model) {
+		return fmt.Errorf("model %s requires the Responses API, which Azure OpenAI does not support", cfg.Model)
+	}
+	return nil
+}
+
+// LoadConfigFileJSON reads a config file to apply with RunNonInteractive.
+func LoadConfigFileJSON(path string) (*ConfigFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg ConfigFile
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+// RunNonInteractive validates cfg and saves it as the CLASP config file
+// without prompting, for CI and container provisioning. Missing optional
+// fields get the same defaults the interactive wizard would use.
+func (w *Wizard) RunNonInteractive(cfg *ConfigFile) error {
+	if err := ValidateConfigFile(cfg); err != nil {
+		return err
+	}
+
+	if cfg.Model == "" {
+		cfg.Model = getDefaultModel(cfg.Provider)
+	}
+	if cfg.Provider == "ollama" && cfg.BaseURL == "" {
+		cfg.BaseURL = "http://localhost:11434"
+	}
+	if cfg.ClaudeCodeConfig == nil {
+		cfg.ClaudeCodeConfig = &ClaudeCodeConfig{SkipPermissions: true}
+	}
+	now := time.Now().Format(time.RFC3339)
+	if cfg.CreatedAt == "" {
+		cfg.CreatedAt = now
+	}
+	cfg.UpdatedAt = now
+
+	if err := w.saveConfig(cfg); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+
+	w.printf("Configuration saved to %s (provider: %s, model: %s)\n", GetConfigPath(), cfg.Provider, cfg.Model)
+	return nil
+}
@@ -0,0 +1,123 @@
+package setup
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// ProjectProfileFile names the profile to use for a project directory.
+	ProjectProfileFile = ".clasp/profile"
+	// ProjectConfigFile holds per-project profile, provider, and model overrides.
+	ProjectConfigFile = ".clasp.json"
+)
+
+// ProjectProfile is the profile selection found in a project directory.
+type ProjectProfile struct {
+	// Dir is the directory containing the project files.
+	Dir string `json:"-"`
+
+	Profile  string `json:"profile,omitempty"`
+	Provider string `json:"provider,omitempty"`
+	Model    string `json:"model,omitempty"`
+}
+
+// FindProjectProfile looks for project files in startDir and each parent
+// directory, like git does for .git, and returns the nearest selection.
+// When a directory has both files, .clasp.json takes precedence over
+// .clasp/profile. It returns nil if no project files are found.
+func FindProjectProfile(startDir string) (*ProjectProfile, error) {
+	dir, err := filepath.Abs(startDir)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		project, err := readProjectProfile(dir)
+		if err != nil || project != nil {
+			return project, err
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, nil
+		}
+		dir = parent
+	}
+}
+
+// readProjectProfile reads the project files in dir, returning nil if there are none.
+func readProjectProfile(dir string) (*ProjectProfile, error) {
+	var project *ProjectProfile
+
+	profilePath := filepath.Join(dir, ProjectProfileFile)
+	if info, err := os.Stat(profilePath); err == nil && info.Mode().IsRegular() {
+		data, err := os.ReadFile(profilePath)
+		if err != nil {
+			return nil, err
+		}
+		name := strings.TrimSpace(string(data))
+		if name == "" {
+			return nil, fmt.Errorf("%s is empty", profilePath)
+		}
+		project = &ProjectProfile{Dir: dir, Profile: name}
+	}
+
+	configPath := filepath.Join(dir, ProjectConfigFile)
+	if info, err := os.Stat(configPath); err == nil && info.Mode().IsRegular() {
+		data, err := os.ReadFile(configPath)
+		if err != nil {
+			return nil, err
+		}
+		var overrides ProjectProfile
+		if err := json.Unmarshal(data, &overrides); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", configPath, err)
+		}
+		if project == nil {
+			project = &ProjectProfile{Dir: dir}
+		}
+		if overrides.Profile != "" {
+			project.Profile = overrides.Profile
+		}
+		project.Provider = overrides.Provider
+		project.Model = overrides.Model
+	}
+
+	return project, nil
+}
+
+// ResolveProjectProfile returns the profile to use for a project: the named
+// profile, or the active profile if none is named, with the project's
+// provider and model overrides applied.
+func (pm *ProfileManager) ResolveProjectProfile(project *ProjectProfile) (*Profile, error) {
+	var profile *Profile
+	if project.Profile != "" {
+		p, err := pm.GetProfile(project.Profile)
+		if err != nil {
+			return nil, err
+		}
+		profile = p
+	} else if p, err := pm.GetActiveProfile(); err == nil {
+		profile = p
+	} else if project.Provider != "" {
+		// No profiles: the project's provider stands on its own, with
+		// credentials coming from the environment
+		profile = &Profile{Name: filepath.Base(project.Dir)}
+	} else {
+		return nil, fmt.Errorf("no profile to apply %s overrides to", filepath.Join(project.Dir, ProjectConfigFile))
+	}
+
+	if project.Provider != "" && project.Provider != profile.Provider {
+		// The profile's key belongs to another provider; use the environment's
+		profile.Provider = project.Provider
+		profile.APIKey = ""
+		profile.APIKeyEnv = providerKeyEnvVars[project.Provider]
+	}
+	if project.Model != "" {
+		profile.DefaultModel = project.Model
+	}
+	return profile, nil
+}
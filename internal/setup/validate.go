@@ -0,0 +1,258 @@
+package setup
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jedarden/clasp/internal/config"
+)
+
+// ValidationReport holds the results of checking a configuration.
+type ValidationReport struct {
+	// Source is the config file that was checked, or "environment".
+	Source   string
+	Errors   []string
+	Warnings []string
+}
+
+// OK returns true if the configuration has no errors.
+func (r *ValidationReport) OK() bool {
+	return len(r.Errors) == 0
+}
+
+func (r *ValidationReport) errorf(format string, args ...interface{}) {
+	r.Errors = append(r.Errors, fmt.Sprintf(format, args...))
+}
+
+func (r *ValidationReport) warnf(format string, args ...interface{}) {
+	r.Warnings = append(r.Warnings, fmt.Sprintf(format, args...))
+}
+
+// ValidateConfig loads the configuration CLASP would start with and checks
+// it without contacting any provider. path selects a config file (JSON or
+// YAML); if empty, the file is found the same way the proxy finds it.
+// Settings from config.json are applied to the process environment, as they
+// are at startup.
+func ValidateConfig(path string) *ValidationReport {
+	report := &ValidationReport{Source: "environment"}
+
+	// Malformed entries are dropped silently at load time, so check the raw value
+	if aliases := os.Getenv("CLASP_MODEL_ALIASES"); aliases != "" {
+		for _, err := range config.ValidateModelAliasList(aliases) {
+			report.errorf("CLASP_MODEL_ALIASES: %v", err)
+		}
+	}
+
+	if path == "" {
+		path = os.Getenv("CLASP_CONFIG_FILE")
+	}
+
+	var cfg *config.Config
+	switch {
+	case path != "" && strings.EqualFold(filepath.Ext(path), ".json"):
+		report.Source = path
+		if applyJSONConfig(report, path) {
+			cfg = loadEnvConfig(report)
+		}
+	case path != "":
+		report.Source = path
+		cfg = loadYAMLConfig(report, path)
+	default:
+		// Same as startup: config.json is applied to the environment, which
+		// takes precedence over a YAML config file
+		var sources []string
+		if _, err := os.Stat(GetConfigPath()); err == nil {
+			sources = append(sources, GetConfigPath())
+			if !applyJSONConfig(report, GetConfigPath()) {
+				return report
+			}
+		}
+		if yamlPath := config.FindConfigFile(); yamlPath != "" {
+			sources = append(sources, yamlPath)
+			cfg = loadYAMLConfig(report, yamlPath)
+		} else {
+			cfg = loadEnvConfig(report)
+		}
+		if len(sources) > 0 {
+			report.Source = strings.Join(sources, " and ")
+		}
+	}
+	if cfg == nil {
+		return report
+	}
+
+	if err := cfg.Validate(); err != nil {
+		report.errorf("%v", err)
+	}
+	checkTiers(report, cfg)
+	checkConflicts(report, cfg)
+	checkModels(report, cfg)
+	return report
+}
+
+// loadEnvConfig loads the configuration from environment variables alone.
+func loadEnvConfig(report *ValidationReport) *config.Config {
+	cfg, err := config.LoadFromEnv()
+	if err != nil {
+		report.errorf("%v", err)
+		return nil
+	}
+	return cfg
+}
+
+// applyJSONConfig checks a config.json file and applies it to the environment
+// the way startup does. It returns false if the file couldn't be read.
+func applyJSONConfig(report *ValidationReport, path string) bool {
+	var file *ConfigFile
+	var err error
+	if path == GetConfigPath() {
+		file, err = LoadConfig()
+	} else {
+		file, err = LoadConfigFileJSON(path)
+	}
+	if err != nil {
+		report.errorf("%v", err)
+		return false
+	}
+
+	if err := ValidateConfigFile(file); err != nil {
+		report.errorf("%v", err)
+	}
+	for _, err := range config.ValidateModelAliases(file.ModelAliases) {
+		report.errorf("model_aliases: %v", err)
+	}
+
+	applyConfigFileToEnv(file)
+	return true
+}
+
+// loadYAMLConfig checks a YAML config file and merges it with the environment.
+func loadYAMLConfig(report *ValidationReport, path string) *config.Config {
+	if _, err := os.Stat(path); err != nil {
+		report.errorf("%v", err)
+		return nil
+	}
+	fileCfg, err := config.LoadFromFile(path)
+	if err != nil {
+		report.errorf("%v", err)
+		return nil
+	}
+	for _, err := range config.ValidateModelAliases(fileCfg.Aliases) {
+		report.errorf("aliases: %v", err)
+	}
+	return config.MergeWithEnv(fileCfg, config.DefaultConfig())
+}
+
+// checkTiers checks the providers and credentials of multi-provider tiers.
+func checkTiers(report *ValidationReport, cfg *config.Config) {
+	if !cfg.MultiProviderEnabled {
+		return
+	}
+	for _, tier := range configuredTiers(cfg) {
+		provider := string(tier.cfg.Provider)
+		if provider == "" {
+			continue // Uses the main provider
+		}
+		if !isKnownProvider(provider) {
+			report.errorf("%s tier: unknown provider %q", tier.name, provider)
+			continue
+		}
+		if env, ok := providerKeyEnvVars[provider]; ok && tier.cfg.APIKey == "" && provider != "litellm" && provider != "custom" {
+			report.errorf("%s tier: no API key for provider %q (set CLASP_%s_API_KEY or %s)",
+				tier.name, provider, strings.ToUpper(tier.name), env)
+		}
+	}
+}
+
+// checkConflicts warns about settings that contradict or have no effect.
+func checkConflicts(report *ValidationReport, cfg *config.Config) {
+	tiers := configuredTiers(cfg)
+	if cfg.MultiProviderEnabled && len(tiers) == 0 {
+		report.warnf("multi-provider routing is enabled but no tier providers are set (CLASP_OPUS_PROVIDER, CLASP_SONNET_PROVIDER, CLASP_HAIKU_PROVIDER)")
+	}
+	if !cfg.MultiProviderEnabled && len(tiers) > 0 {
+		report.warnf("tier settings are ignored because multi-provider routing is disabled (set CLASP_MULTI_PROVIDER=true)")
+	}
+	if cfg.FallbackEnabled && cfg.FallbackProvider == "" {
+		report.warnf("fallback is enabled but no fallback provider is set (CLASP_FALLBACK_PROVIDER)")
+	}
+	if cfg.AuthEnabled && cfg.AuthAPIKey == "" {
+		report.warnf("authentication is enabled but no API key is set (CLASP_AUTH_API_KEY)")
+	}
+}
+
+// checkModels warns about models missing from the known model lists. The lists
+// aren't exhaustive, so these are warnings rather than errors.
+func checkModels(report *ValidationReport, cfg *config.Config) {
+	provider := string(cfg.Provider)
+	checkModel(report, provider, "model", cfg.DefaultModel)
+	checkModel(report, provider, "opus model", cfg.ModelOpus)
+	checkModel(report, provider, "sonnet model", cfg.ModelSonnet)
+	checkModel(report, provider, "haiku model", cfg.ModelHaiku)
+
+	if cfg.MultiProviderEnabled {
+		for _, tier := range configuredTiers(cfg) {
+			tierProvider := string(tier.cfg.Provider)
+			if tierProvider == "" {
+				tierProvider = provider
+			}
+			checkModel(report, tierProvider, tier.name+" tier model", tier.cfg.Model)
+		}
+	}
+
+	aliases := make([]string, 0, len(cfg.ModelAliases))
+	for alias := range cfg.ModelAliases {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+	for _, alias := range aliases {
+		checkModel(report, provider, fmt.Sprintf("alias %q target", alias), cfg.ModelAliases[alias])
+	}
+}
+
+// checkModel warns if model isn't a known model for provider. Providers that
+// serve user-defined models (Ollama, LiteLLM, custom endpoints) aren't checked.
+func checkModel(report *ValidationReport, provider, what, model string) {
+	if model == "" || provider == "ollama" || provider == "litellm" {
+		return
+	}
+	known := GetKnownModels(provider)
+	if len(known) == 0 {
+		return
+	}
+	for _, m := range known {
+		if m.ID == model {
+			return
+		}
+	}
+	report.warnf("%s %q is not a known %s model (run 'clasp -models' to list available models)", what, model, provider)
+}
+
+type namedTier struct {
+	name string
+	cfg  *config.TierConfig
+}
+
+// configuredTiers returns the tiers that have settings, in opus, sonnet, haiku order.
+func configuredTiers(cfg *config.Config) []namedTier {
+	var tiers []namedTier
+	for _, tier := range []namedTier{{"opus", cfg.TierOpus}, {"sonnet", cfg.TierSonnet}, {"haiku", cfg.TierHaiku}} {
+		if tier.cfg != nil {
+			tiers = append(tiers, tier)
+		}
+	}
+	return tiers
+}
+
+// isKnownProvider returns true if provider is supported by the proxy.
+func isKnownProvider(provider string) bool {
+	for _, p := range config.AllProviders {
+		if string(p) == provider {
+			return true
+		}
+	}
+	return false
+}
@@ -49,12 +49,17 @@ type Wizard struct {
 	client *http.Client
 }
 
-// NewWizard creates a new setup wizard.
+// NewWizard creates a new setup wizard. Model lists are fetched with the
+// CLASP_MODELS_TIMEOUT timeout, or DefaultModelsTimeout if it is invalid.
 func NewWizard() *Wizard {
+	timeout, err := ModelsTimeoutFromEnv()
+	if err != nil {
+		timeout = DefaultModelsTimeout
+	}
 	return &Wizard{
 		reader: bufio.NewReader(os.Stdin),
 		writer: os.Stdout,
-		client: &http.Client{Timeout: 15 * time.Second},
+		client: &http.Client{Timeout: timeout},
 	}
 }
 
@@ -77,6 +82,11 @@ func NeedsSetup() bool {
 		return false
 	}
 
+	// A YAML config file (e.g. ~/.clasp/config.yaml) is configuration too
+	if config.FindConfigFile() != "" {
+		return false
+	}
+
 	return true
 }
 
@@ -587,6 +597,7 @@ type openRouterModelsResponse struct {
 func (w *Wizard) fetchModels(provider, apiKey, baseURL, azureEndpoint string) ([]string, error) {
 	var url string
 	var headers map[string]string
+	var customListing bool
 
 	switch provider {
 	case "openai":
@@ -605,7 +616,7 @@ func (w *Wizard) fetchModels(provider, apiKey, baseURL, azureEndpoint string) ([
 		if baseURL == "" {
 			return nil, fmt.Errorf("no base URL provided")
 		}
-		url = strings.TrimSuffix(baseURL, "/") + "/models"
+		url, customListing = customModelsURL(baseURL)
 		if apiKey != "" {
 			headers = map[string]string{"Authorization": "Bearer " + apiKey}
 		}
@@ -636,14 +647,9 @@ func (w *Wizard) fetchModels(provider, apiKey, baseURL, azureEndpoint string) ([
 			"deepseek-reasoner",
 		}, nil
 	case "azure":
-		// Azure doesn't have a models endpoint, return common deployments
-		return []string{
-			"gpt-4",
-			"gpt-4o",
-			"gpt-4o-mini",
-			"gpt-4-turbo",
-			"gpt-35-turbo",
-		}, nil
+		// Azure has no models endpoint; deployments are listed through the
+		// management API when credentials are set
+		return w.fetchAzureModels(azureEndpoint)
 	case "litellm":
 		// LiteLLM has an OpenAI-compatible /models endpoint
 		if baseURL == "" {
@@ -669,7 +675,7 @@ func (w *Wizard) fetchModels(provider, apiKey, baseURL, azureEndpoint string) ([
 
 	resp, err := w.client.Do(req)
 	if err != nil {
-		return nil, err
+		return nil, w.modelsRequestError(err, url)
 	}
 	defer resp.Body.Close()
 
@@ -678,6 +684,16 @@ func (w *Wizard) fetchModels(provider, apiKey, baseURL, azureEndpoint string) ([
 		return nil, fmt.Errorf("API error (%d): %s", resp.StatusCode, string(body))
 	}
 
+	// A gateway's own listing path may return any shape and non-OpenAI names,
+	// so its models are kept as listed
+	if customListing {
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		return parseModelList(body)
+	}
+
 	var models []string
 
 	switch provider {
@@ -760,17 +776,14 @@ func (w *Wizard) fetchOllamaModels(baseURL string) ([]string, error) {
 	baseURL = strings.TrimSuffix(baseURL, "/v1")
 	baseURL = strings.TrimSuffix(baseURL, "/")
 
-	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
-	defer cancel()
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+"/api/tags", http.NoBody)
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, baseURL+"/api/tags", http.NoBody)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	resp, err := w.client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to Ollama: %w", err)
+		return nil, fmt.Errorf("failed to connect to Ollama: %w", w.modelsRequestError(err, baseURL+"/api/tags"))
 	}
 	defer resp.Body.Close()
 
@@ -987,6 +1000,12 @@ func ApplyConfigToEnv() error {
 		return err
 	}
 
+	applyConfigFileToEnv(cfg)
+	return nil
+}
+
+// applyConfigFileToEnv sets the environment variables for cfg's settings.
+func applyConfigFileToEnv(cfg *ConfigFile) {
 	os.Setenv("PROVIDER", cfg.Provider)
 
 	switch cfg.Provider {
@@ -1028,7 +1047,14 @@ func ApplyConfigToEnv() error {
 		os.Setenv("CLASP_MODEL", cfg.Model)
 	}
 
-	return nil
+	if len(cfg.ModelAliases) > 0 {
+		aliases := make([]string, 0, len(cfg.ModelAliases))
+		for alias, target := range cfg.ModelAliases {
+			aliases = append(aliases, alias+":"+target)
+		}
+		sort.Strings(aliases)
+		os.Setenv("CLASP_MODEL_ALIASES", strings.Join(aliases, ","))
+	}
 }
 
 // FetchModelsPublic is a public wrapper for fetchModels.
@@ -0,0 +1,172 @@
+package statusline
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var (
+	// ErrNoInstance is returned when no CLASP instance matches.
+	ErrNoInstance = errors.New("no running CLASP instance found")
+	// ErrStaleInstance is returned when a status file refers to a process
+	// that has exited or is no longer CLASP (the PID was reused).
+	ErrStaleInstance = errors.New("stale status file")
+)
+
+// MaskedArg replaces the values of secret flags in recorded arguments.
+const MaskedArg = "********"
+
+// secretFlags are the command-line flags whose values are never recorded.
+var secretFlags = map[string]bool{"api-key": true, "auth-api-key": true}
+
+// processCommandLine returns the executable, arguments and working directory
+// of the current process, recorded so the proxy can be restarted. The values
+// of secret flags are masked.
+func processCommandLine() (executable string, args []string, workDir string) {
+	executable, err := os.Executable()
+	if err != nil {
+		executable = os.Args[0]
+	}
+	workDir, _ = os.Getwd()
+	return executable, maskSecretArgs(os.Args[1:]), workDir
+}
+
+// maskSecretArgs returns a copy of args with the values of secret flags
+// replaced by MaskedArg, in both the "-flag value" and "-flag=value" forms.
+// Every argument is checked, since the values of other flags aren't known.
+func maskSecretArgs(args []string) []string {
+	masked := append([]string(nil), args...)
+	for i := 0; i < len(masked); i++ {
+		arg := masked[i]
+		if !strings.HasPrefix(arg, "-") {
+			continue
+		}
+		name := strings.TrimPrefix(strings.TrimPrefix(arg, "-"), "-")
+		if eq := strings.IndexByte(name, '='); eq >= 0 {
+			if secretFlags[name[:eq]] {
+				masked[i] = arg[:len(arg)-len(name)+eq+1] + MaskedArg
+			}
+			continue
+		}
+		if secretFlags[name] && i+1 < len(masked) {
+			masked[i+1] = MaskedArg
+			i++
+		}
+	}
+	return masked
+}
+
+// HasMaskedArgs reports whether args recorded by an instance had secret flag
+// values masked, so the command line can't be replayed as it is.
+func HasMaskedArgs(args []string) bool {
+	for _, arg := range args {
+		if arg == MaskedArg || strings.HasSuffix(arg, "="+MaskedArg) {
+			return true
+		}
+	}
+	return false
+}
+
+// FindInstance returns the instance on port, or the only running instance
+// when port is 0. With several instances running, a port must be given.
+func FindInstance(port int) (*InstanceInfo, error) {
+	instances, err := ListAllInstances()
+	if err != nil {
+		return nil, err
+	}
+
+	if port > 0 {
+		for i := range instances {
+			if instances[i].Port == port {
+				return &instances[i], nil
+			}
+		}
+		return nil, fmt.Errorf("%w on port %d", ErrNoInstance, port)
+	}
+
+	var running []*InstanceInfo
+	for i := range instances {
+		if instances[i].IsRunning {
+			running = append(running, &instances[i])
+		}
+	}
+	switch len(running) {
+	case 0:
+		// Report a stale file if that's all there is
+		if len(instances) == 1 {
+			return &instances[0], nil
+		}
+		return nil, ErrNoInstance
+	case 1:
+		return running[0], nil
+	default:
+		ports := make([]string, len(running))
+		for i, inst := range running {
+			ports[i] = strconv.Itoa(inst.Port)
+		}
+		return nil, fmt.Errorf("%d CLASP instances running (ports %s); specify one with -p <port>",
+			len(running), strings.Join(ports, ", "))
+	}
+}
+
+// IsCLASPProcess reports whether pid is a live CLASP process, guarding
+// against signaling an unrelated process that reused a stale PID.
+func IsCLASPProcess(pid int) bool {
+	if !isProcessAlive(pid) {
+		return false
+	}
+	return strings.HasPrefix(strings.ToLower(processName(pid)), "clasp")
+}
+
+// processName returns the executable name of pid, or "" if it can't be determined.
+func processName(pid int) string {
+	// On Linux, argv[0] is the first NUL-separated field of /proc/[pid]/cmdline
+	if data, err := os.ReadFile(fmt.Sprintf("/proc/%d/cmdline", pid)); err == nil {
+		argv0, _, _ := bytes.Cut(data, []byte{0})
+		return filepath.Base(string(argv0))
+	}
+
+	// Elsewhere, ask ps
+	out, err := exec.Command("ps", "-p", strconv.Itoa(pid), "-o", "comm=").Output()
+	if err != nil {
+		return ""
+	}
+	return filepath.Base(strings.TrimSpace(string(out)))
+}
+
+// StopInstance sends SIGTERM to the instance's process and waits up to
+// timeout for it to exit. If the status file is stale, it is removed and
+// ErrStaleInstance is returned without signaling anything.
+func StopInstance(inst *InstanceInfo, timeout time.Duration) error {
+	if !inst.IsRunning || !IsCLASPProcess(inst.PID) {
+		_ = os.Remove(inst.StatusFile)
+		return fmt.Errorf("%w for PID %d (process is not a running CLASP instance)", ErrStaleInstance, inst.PID)
+	}
+
+	process, err := os.FindProcess(inst.PID)
+	if err != nil {
+		return fmt.Errorf("finding process %d: %w", inst.PID, err)
+	}
+	if err := process.Signal(syscall.SIGTERM); err != nil {
+		return fmt.Errorf("signaling process %d: %w", inst.PID, err)
+	}
+
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if !isProcessAlive(inst.PID) {
+			// A clean shutdown removes the status file; make sure it's gone
+			_ = os.Remove(inst.StatusFile)
+			return nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return fmt.Errorf("process %d did not exit within %v (force it with: kill -9 %d)", inst.PID, timeout, inst.PID)
+}
@@ -26,6 +26,8 @@ type Status struct {
 	Requests        int64     `json:"requests"`
 	Errors          int64     `json:"errors"`
 	CostUSD         float64   `json:"cost_usd"`
+	InputTokens     int64     `json:"input_tokens"`
+	OutputTokens    int64     `json:"output_tokens"`
 	AvgLatencyMs    float64   `json:"avg_latency_ms"`
 	StartTime       time.Time `json:"start_time"`
 	LastUpdated     time.Time `json:"last_updated"`
@@ -34,6 +36,11 @@ type Status struct {
 	CacheHitRate    float64   `json:"cache_hit_rate"`
 	Fallback        string    `json:"fallback,omitempty"`
 	HTTPTimeoutSec  int       `json:"http_timeout_sec,omitempty"`
+	UpstreamHealthy *bool     `json:"upstream_healthy,omitempty"` // Nil until the provider's health is known
+	// Command line of the proxy process, used by 'clasp restart'
+	Executable string   `json:"executable,omitempty"`
+	Args       []string `json:"args,omitempty"`
+	WorkDir    string   `json:"work_dir,omitempty"`
 }
 
 // Manager handles status file updates and script installation.
@@ -105,6 +112,7 @@ func (m *Manager) UpdateStatus(s Status) error {
 
 	s.LastUpdated = time.Now()
 	s.PID = os.Getpid()
+	s.Executable, s.Args, s.WorkDir = processCommandLine()
 	if m.port != 0 {
 		s.Port = m.port
 	}
@@ -138,6 +146,18 @@ func (m *Manager) UpdateMetrics(requests, errors int64, costUSD, avgLatencyMs fl
 	return m.writeStatus()
 }
 
+// UpdateTokens updates the total input and output tokens.
+func (m *Manager) UpdateTokens(input, output int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.status.InputTokens = input
+	m.status.OutputTokens = output
+	m.status.LastUpdated = time.Now()
+
+	return m.writeStatus()
+}
+
 // UpdateCacheStats updates cache statistics.
 func (m *Manager) UpdateCacheStats(enabled bool, hitRate float64) error {
 	m.mu.Lock()
@@ -150,6 +170,18 @@ func (m *Manager) UpdateCacheStats(enabled bool, hitRate float64) error {
 	return m.writeStatus()
 }
 
+// UpdateUpstreamHealth records whether the upstream provider is healthy.
+// A nil value means its health is not known yet.
+func (m *Manager) UpdateUpstreamHealth(healthy *bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.status.UpstreamHealthy = healthy
+	m.status.LastUpdated = time.Now()
+
+	return m.writeStatus()
+}
+
 // SetModel updates the current model.
 func (m *Manager) SetModel(model string) error {
 	m.mu.Lock()
@@ -239,6 +271,7 @@ if [ -n "$ANTHROPIC_BASE_URL" ]; then
                     REQUESTS=$(jq -r '.requests // 0' "$STATUS_FILE")
                     COST=$(jq -r '.cost_usd // 0' "$STATUS_FILE")
                     VERSION=$(jq -r '.version // ""' "$STATUS_FILE")
+                    UPSTREAM=$(jq -r 'if .upstream_healthy == null then "unknown" else .upstream_healthy end' "$STATUS_FILE")
 
                     # Format cost display
                     if [ "$COST" != "null" ] && [ "$COST" != "0" ]; then
@@ -254,9 +287,17 @@ if [ -n "$ANTHROPIC_BASE_URL" ]; then
                         VERSION_STR=" $VERSION"
                     fi
 
+                    # Format upstream health display
+                    UPSTREAM_STR=""
+                    if [ "$UPSTREAM" = "true" ]; then
+                        UPSTREAM_STR=" \033[32m●\033[0m"
+                    elif [ "$UPSTREAM" = "false" ]; then
+                        UPSTREAM_STR=" \033[31m● upstream down\033[0m"
+                    fi
+
                     # Output status with ANSI colors
-                    # Format: [CLASP:PORT VERSION] MODEL (PROVIDER) | REQUESTS reqs | $COST
-                    echo -e "\033[36m[CLASP:$PORT$VERSION_STR]\033[0m \033[33m$MODEL\033[0m ($PROVIDER) | $REQUESTS reqs$COST_STR"
+                    # Format: [CLASP:PORT VERSION] MODEL (PROVIDER) HEALTH | REQUESTS reqs | $COST
+                    echo -e "\033[36m[CLASP:$PORT$VERSION_STR]\033[0m \033[33m$MODEL\033[0m ($PROVIDER)$UPSTREAM_STR | $REQUESTS reqs$COST_STR"
                     exit 0
                 fi
             fi
@@ -300,6 +341,12 @@ echo "$1" | jq -r '.model.display_name // "Claude"'
 
 // ConfigureClaudeCode updates Claude Code's settings.json to use the CLASP status line.
 func (m *Manager) ConfigureClaudeCode() error {
+	return m.ConfigureClaudeCodeCommand(m.scriptPath)
+}
+
+// ConfigureClaudeCodeCommand updates Claude Code's settings.json to run
+// command for the status line.
+func (m *Manager) ConfigureClaudeCodeCommand(command string) error {
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
 		return fmt.Errorf("getting home directory: %w", err)
@@ -326,7 +373,7 @@ func (m *Manager) ConfigureClaudeCode() error {
 	// Add or update statusLine configuration
 	settings["statusLine"] = map[string]interface{}{
 		"type":    "command",
-		"command": m.scriptPath,
+		"command": command,
 		"padding": 0,
 	}
 
@@ -410,6 +457,9 @@ type InstanceInfo struct {
 	Uptime     string    `json:"uptime"`
 	IsRunning  bool      `json:"is_running"`
 	StatusFile string    `json:"status_file"`
+	Executable string    `json:"executable,omitempty"`
+	Args       []string  `json:"args,omitempty"`
+	WorkDir    string    `json:"work_dir,omitempty"`
 }
 
 // ListAllInstances returns information about all CLASP instances.
@@ -469,6 +519,9 @@ func ListAllInstances() ([]InstanceInfo, error) {
 			Uptime:     uptime,
 			IsRunning:  isRunning,
 			StatusFile: statusPath,
+			Executable: status.Executable,
+			Args:       status.Args,
+			WorkDir:    status.WorkDir,
 		})
 	}
 
@@ -495,6 +548,9 @@ func ListAllInstances() ([]InstanceInfo, error) {
 				Uptime:     uptime,
 				IsRunning:  isRunning,
 				StatusFile: legacyPath,
+				Executable: status.Executable,
+				Args:       status.Args,
+				WorkDir:    status.WorkDir,
 			})
 		}
 	}
@@ -616,11 +672,31 @@ func ReadStatusFromPort(port int) (*Status, error) {
 	return &status, nil
 }
 
+// ANSI-colored upstream health indicators for FormatStatusLine.
+const (
+	upstreamHealthyIndicator   = " \033[32m●\033[0m"
+	upstreamUnhealthyIndicator = " \033[31m● upstream down\033[0m"
+)
+
+// upstreamIndicator returns the health indicator for the upstream provider,
+// or an empty string while its health is unknown.
+func upstreamIndicator(healthy *bool) string {
+	switch {
+	case healthy == nil:
+		return ""
+	case *healthy:
+		return upstreamHealthyIndicator
+	default:
+		return upstreamUnhealthyIndicator
+	}
+}
+
 // FormatStatusLine returns a formatted status line string for terminal display.
 func FormatStatusLine(s *Status, verbose bool) string {
 	if s == nil || !s.Running {
 		return "CLASP proxy is not running"
 	}
+	health := upstreamIndicator(s.UpstreamHealthy)
 
 	if verbose {
 		uptime := time.Since(s.StartTime).Round(time.Second)
@@ -632,15 +708,47 @@ func FormatStatusLine(s *Status, verbose bool) string {
 		if s.CacheEnabled {
 			cacheStr = fmt.Sprintf(" | Cache: %.1f%% hit rate", s.CacheHitRate*100)
 		}
-		return fmt.Sprintf("[CLASP:%d] %s (%s) | PID %d | %d requests | %d errors | Uptime: %s%s%s",
-			s.Port, s.Model, s.Provider, s.PID, s.Requests, s.Errors, uptime, costStr, cacheStr)
+		return fmt.Sprintf("[CLASP:%d] %s (%s)%s | PID %d | %d requests | %d errors | Uptime: %s%s%s",
+			s.Port, s.Model, s.Provider, health, s.PID, s.Requests, s.Errors, uptime, costStr, cacheStr)
 	}
 
 	costStr := ""
 	if s.CostUSD > 0 {
 		costStr = fmt.Sprintf(" | $%.2f", s.CostUSD)
 	}
-	return fmt.Sprintf("[CLASP:%d] %s (%s) | %d reqs%s", s.Port, s.Model, s.Provider, s.Requests, costStr)
+	return fmt.Sprintf("[CLASP:%d] %s (%s)%s | %d reqs%s", s.Port, s.Model, s.Provider, health, s.Requests, costStr)
+}
+
+// FormatClaudeStatusLine returns the compact line printed by 'clasp statusline'
+// for Claude Code's statusLine setting: model, cost, total tokens, and the
+// average request rate since the proxy started.
+func FormatClaudeStatusLine(s *Status, now time.Time) string {
+	if s == nil || !s.Running {
+		return "CLASP not running"
+	}
+
+	// Rates over less than a minute are reported per elapsed minute
+	minutes := now.Sub(s.StartTime).Minutes()
+	if minutes < 1 {
+		minutes = 1
+	}
+	rate := float64(s.Requests) / minutes
+
+	return fmt.Sprintf("[CLASP] %s%s | $%.2f | %s tok | %.1f req/min",
+		s.Model, upstreamIndicator(s.UpstreamHealthy), s.CostUSD,
+		formatTokenCount(s.InputTokens+s.OutputTokens), rate)
+}
+
+// formatTokenCount abbreviates a token count, e.g. 950, 45.2k, 1.3M.
+func formatTokenCount(n int64) string {
+	switch {
+	case n >= 1_000_000:
+		return fmt.Sprintf("%.1fM", float64(n)/1_000_000)
+	case n >= 1_000:
+		return fmt.Sprintf("%.1fk", float64(n)/1_000)
+	default:
+		return fmt.Sprintf("%d", n)
+	}
 }
 
 // FormatAllInstancesTable formats all instances as a table for CLI output.
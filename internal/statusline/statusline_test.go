@@ -2,9 +2,15 @@
 package statusline
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestIsProcessAlive_CurrentProcess(t *testing.T) {
@@ -111,6 +117,44 @@ func TestFormatStatusLine(t *testing.T) {
 			verbose: false,
 			want:    "[CLASP:8080] gpt-4o (openai) | 10 reqs | $1.50",
 		},
+		{
+			name: "upstream healthy",
+			status: &Status{
+				Running:         true,
+				Port:            8080,
+				Model:           "gpt-4o",
+				Provider:        "openai",
+				Requests:        10,
+				UpstreamHealthy: boolPtr(true),
+			},
+			verbose: false,
+			want:    "[CLASP:8080] gpt-4o (openai) \033[32m●\033[0m | 10 reqs",
+		},
+		{
+			name: "upstream unhealthy",
+			status: &Status{
+				Running:         true,
+				Port:            8080,
+				Model:           "gpt-4o",
+				Provider:        "openai",
+				Requests:        10,
+				UpstreamHealthy: boolPtr(false),
+			},
+			verbose: false,
+			want:    "[CLASP:8080] gpt-4o (openai) \033[31m● upstream down\033[0m | 10 reqs",
+		},
+		{
+			name: "upstream health unknown",
+			status: &Status{
+				Running:  true,
+				Port:     8080,
+				Model:    "gpt-4o",
+				Provider: "openai",
+				Requests: 10,
+			},
+			verbose: false,
+			want:    "[CLASP:8080] gpt-4o (openai) | 10 reqs",
+		},
 	}
 
 	for _, tt := range tests {
@@ -122,3 +166,260 @@ func TestFormatStatusLine(t *testing.T) {
 		})
 	}
 }
+
+func TestFormatStatusLine_VerboseUpstreamHealth(t *testing.T) {
+	status := &Status{
+		Running:         true,
+		Port:            8080,
+		Model:           "gpt-4o",
+		Provider:        "openai",
+		StartTime:       time.Now(),
+		UpstreamHealthy: boolPtr(false),
+	}
+	got := FormatStatusLine(status, true)
+	if !strings.HasPrefix(got, "[CLASP:8080] gpt-4o (openai) \033[31m● upstream down\033[0m | PID") {
+		t.Errorf("FormatStatusLine() = %q, want the unhealthy indicator after the provider", got)
+	}
+}
+
+func TestFormatClaudeStatusLine(t *testing.T) {
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		status *Status
+		now    time.Time
+		want   string
+	}{
+		{
+			name:   "not running",
+			status: &Status{Running: false},
+			now:    start,
+			want:   "CLASP not running",
+		},
+		{
+			name: "snapshot",
+			status: &Status{
+				Running:      true,
+				Model:        "gpt-4o",
+				Requests:     30,
+				CostUSD:      1.234,
+				InputTokens:  40000,
+				OutputTokens: 5200,
+				StartTime:    start,
+			},
+			now:  start.Add(10 * time.Minute),
+			want: "[CLASP] gpt-4o | $1.23 | 45.2k tok | 3.0 req/min",
+		},
+		{
+			name: "first minute",
+			status: &Status{
+				Running:      true,
+				Model:        "deepseek-chat",
+				Requests:     4,
+				InputTokens:  800,
+				OutputTokens: 150,
+				StartTime:    start,
+			},
+			now:  start.Add(20 * time.Second),
+			want: "[CLASP] deepseek-chat | $0.00 | 950 tok | 4.0 req/min",
+		},
+		{
+			name: "millions of tokens and unhealthy upstream",
+			status: &Status{
+				Running:         true,
+				Model:           "gpt-4o",
+				Requests:        90,
+				CostUSD:         12.5,
+				InputTokens:     1200000,
+				OutputTokens:    100000,
+				StartTime:       start,
+				UpstreamHealthy: boolPtr(false),
+			},
+			now:  start.Add(time.Hour),
+			want: "[CLASP] gpt-4o \033[31m● upstream down\033[0m | $12.50 | 1.3M tok | 1.5 req/min",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := FormatClaudeStatusLine(tt.status, tt.now)
+			if got != tt.want {
+				t.Errorf("FormatClaudeStatusLine() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func boolPtr(v bool) *bool {
+	return &v
+}
+
+func TestIsCLASPProcess(t *testing.T) {
+	// The test binary is alive but is not CLASP
+	if IsCLASPProcess(os.Getpid()) {
+		t.Error("IsCLASPProcess(test process) = true, want false")
+	}
+	if IsCLASPProcess(999999999) {
+		t.Error("IsCLASPProcess(non-existent PID) = true, want false")
+	}
+}
+
+// writeInstanceStatus writes a per-port status file under home/.clasp/status.
+func writeInstanceStatus(t *testing.T, home string, s Status) string {
+	t.Helper()
+	dir := filepath.Join(home, ".clasp", "status")
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	path := filepath.Join(dir, fmt.Sprintf("%d.json", s.Port))
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	return path
+}
+
+func TestStopInstance_StaleStatusFile(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	path := writeInstanceStatus(t, home, Status{Running: true, Port: 8123, PID: 999999999})
+
+	inst, err := FindInstance(0)
+	if err != nil {
+		t.Fatalf("FindInstance failed: %v", err)
+	}
+	if inst.Port != 8123 || inst.IsRunning {
+		t.Fatalf("Unexpected instance: %+v", inst)
+	}
+
+	err = StopInstance(inst, time.Second)
+	if !errors.Is(err, ErrStaleInstance) {
+		t.Fatalf("StopInstance error = %v, want ErrStaleInstance", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("Expected stale status file to be removed")
+	}
+}
+
+func TestStopInstance_ReusedPID(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	// A live process that isn't CLASP must never be signaled
+	path := writeInstanceStatus(t, home, Status{Running: true, Port: 8124, PID: os.Getpid()})
+	inst, err := FindInstance(8124)
+	if err != nil {
+		t.Fatalf("FindInstance failed: %v", err)
+	}
+	if err := StopInstance(inst, time.Second); !errors.Is(err, ErrStaleInstance) {
+		t.Fatalf("StopInstance error = %v, want ErrStaleInstance", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("Expected status file to be removed")
+	}
+}
+
+func TestStopInstance_SignalsCLASPProcess(t *testing.T) {
+	sleepPath, err := exec.LookPath("sleep")
+	if err != nil {
+		t.Skip("sleep not available")
+	}
+	data, err := os.ReadFile(sleepPath)
+	if err != nil {
+		t.Skipf("cannot read %s: %v", sleepPath, err)
+	}
+
+	// Run sleep under the name "clasp" so it passes the process check
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	binary := filepath.Join(home, "clasp")
+	if err := os.WriteFile(binary, data, 0o700); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	cmd := exec.Command(binary, "30")
+	if err := cmd.Start(); err != nil {
+		t.Skipf("cannot start fake clasp: %v", err)
+	}
+	done := make(chan struct{})
+	go func() {
+		_ = cmd.Wait()
+		close(done)
+	}()
+	t.Cleanup(func() {
+		_ = cmd.Process.Kill()
+		<-done
+	})
+
+	if !IsCLASPProcess(cmd.Process.Pid) {
+		t.Fatalf("IsCLASPProcess(%d) = false, want true", cmd.Process.Pid)
+	}
+
+	path := writeInstanceStatus(t, home, Status{Running: true, Port: 8125, PID: cmd.Process.Pid})
+	inst, err := FindInstance(0)
+	if err != nil {
+		t.Fatalf("FindInstance failed: %v", err)
+	}
+	if err := StopInstance(inst, 5*time.Second); err != nil {
+		t.Fatalf("StopInstance failed: %v", err)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Process did not exit after StopInstance")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("Expected status file to be removed")
+	}
+}
+
+func TestFindInstance_MultipleRequirePort(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	writeInstanceStatus(t, home, Status{Running: true, Port: 8126, PID: os.Getpid()})
+	writeInstanceStatus(t, home, Status{Running: true, Port: 8127, PID: os.Getpid()})
+
+	if _, err := FindInstance(0); err == nil || !strings.Contains(err.Error(), "-p <port>") {
+		t.Errorf("FindInstance(0) error = %v, want port hint", err)
+	}
+	if inst, err := FindInstance(8127); err != nil || inst.Port != 8127 {
+		t.Errorf("FindInstance(8127) = %+v, %v", inst, err)
+	}
+	if _, err := FindInstance(9999); !errors.Is(err, ErrNoInstance) {
+		t.Errorf("FindInstance(9999) error = %v, want ErrNoInstance", err)
+	}
+}
+
+func TestMaskSecretArgs(t *testing.T) {
+	args := []string{
+		"-proxy-only", "-port", "8080",
+		"-api-key", "sk-provider",
+		"--auth-api-key=proxy-secret",
+		"-auth", "-auth-api-key", "other-secret",
+		"-model=gpt-4o",
+	}
+	want := []string{
+		"-proxy-only", "-port", "8080",
+		"-api-key", MaskedArg,
+		"--auth-api-key=" + MaskedArg,
+		"-auth", "-auth-api-key", MaskedArg,
+		"-model=gpt-4o",
+	}
+
+	got := maskSecretArgs(args)
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("maskSecretArgs() = %q, want %q", got, want)
+	}
+	if args[4] != "sk-provider" {
+		t.Error("maskSecretArgs modified its input")
+	}
+	if !HasMaskedArgs(got) {
+		t.Error("HasMaskedArgs() = false for masked arguments")
+	}
+	if HasMaskedArgs([]string{"-proxy-only", "-port", "8080"}) {
+		t.Error("HasMaskedArgs() = true without secret flags")
+	}
+}
@@ -0,0 +1,109 @@
+// Package translator handles protocol translation between Anthropic and OpenAI formats.
+package translator
+
+import (
+	"strings"
+
+	"github.com/jedarden/clasp/internal/logging"
+	"github.com/jedarden/clasp/pkg/models"
+)
+
+// Known anthropic-beta flags that change how requests are translated.
+const (
+	// BetaPromptCaching keeps cache_control markers for providers that honor them.
+	BetaPromptCaching = "prompt-caching-2024-07-31"
+)
+
+// knownBetas lists the anthropic-beta flags the translator acts on. Other flags
+// are only forwarded in passthrough.
+var knownBetas = map[string]bool{
+	BetaPromptCaching: true,
+}
+
+// ParseBetaHeader splits anthropic-beta header values, which may each hold a
+// comma-separated list, into the individual flags without duplicates.
+func ParseBetaHeader(values []string) []string {
+	var betas []string
+	seen := make(map[string]bool)
+	for _, value := range values {
+		for _, flag := range strings.Split(value, ",") {
+			flag = strings.TrimSpace(flag)
+			if flag == "" || seen[flag] {
+				continue
+			}
+			seen[flag] = true
+			betas = append(betas, flag)
+			if !knownBetas[flag] {
+				logging.LogDebugMessage("[REQUEST] anthropic-beta %s has no effect on translated requests", flag)
+			}
+		}
+	}
+	return betas
+}
+
+// hasBeta reports whether the request enabled the given anthropic-beta flag.
+func hasBeta(req *models.AnthropicRequest, flag string) bool {
+	for _, b := range req.Betas {
+		if b == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// providerSupportsCacheControl reports whether a provider accepts Anthropic-style
+// cache_control markers on Chat Completions content parts.
+func providerSupportsCacheControl(provider ProviderType) bool {
+	return provider == ProviderOpenRouter
+}
+
+// cacheControlEnabled reports whether cache_control markers are translated for
+// this request: the client sent the prompt caching beta and the provider
+// honors the markers.
+func cacheControlEnabled(req *models.AnthropicRequest, provider ProviderType) bool {
+	if !hasBeta(req, BetaPromptCaching) {
+		return false
+	}
+	if !providerSupportsCacheControl(provider) {
+		logging.LogDebugMessage("[REQUEST] Dropping cache_control: not supported by provider %s", provider)
+		return false
+	}
+	return true
+}
+
+// systemContentParts converts a system prompt given as content blocks into
+// text parts that keep their cache_control markers. It returns nil when no
+// block carries a marker, so the prompt is sent as a plain string.
+func (o *Options) systemContentParts(system interface{}) []models.OpenAIContentPart {
+	blocks, ok := system.([]interface{})
+	if !ok {
+		return nil
+	}
+	var parts []models.OpenAIContentPart
+	var note string
+	marked := false
+	for _, item := range blocks {
+		block, err := parseContentBlock(item)
+		if err != nil || block.Text == "" {
+			continue
+		}
+		var text string
+		text, note = o.rewriteIdentity(block.Text)
+		parts = append(parts, models.OpenAIContentPart{
+			Type:         "text",
+			Text:         text,
+			CacheControl: block.CacheControl,
+		})
+		if block.CacheControl != nil {
+			marked = true
+		}
+	}
+	if !marked {
+		return nil
+	}
+	// The identity note leads the prompt, ahead of the first breakpoint
+	if note != "" {
+		parts = append([]models.OpenAIContentPart{{Type: "text", Text: note}}, parts...)
+	}
+	return parts
+}
@@ -0,0 +1,97 @@
+package translator
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jedarden/clasp/pkg/models"
+)
+
+func TestParseBetaHeader(t *testing.T) {
+	got := ParseBetaHeader([]string{"prompt-caching-2024-07-31, token-efficient-tools-2025-02-19", " prompt-caching-2024-07-31", ""})
+	want := []string{"prompt-caching-2024-07-31", "token-efficient-tools-2025-02-19"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseBetaHeader = %v, want %v", got, want)
+	}
+	if got := ParseBetaHeader(nil); got != nil {
+		t.Errorf("ParseBetaHeader(nil) = %v, want nil", got)
+	}
+}
+
+// cachedRequest returns a request with cache_control markers on the system
+// prompt and the user message.
+func cachedRequest(betas ...string) *models.AnthropicRequest {
+	return &models.AnthropicRequest{
+		System: []interface{}{
+			map[string]interface{}{"type": "text", "text": "You are a coding assistant."},
+			map[string]interface{}{"type": "text", "text": "Project context.", "cache_control": map[string]interface{}{"type": "ephemeral"}},
+		},
+		Messages: []models.AnthropicMessage{{
+			Role: "user",
+			Content: []interface{}{
+				map[string]interface{}{"type": "text", "text": "Long document", "cache_control": map[string]interface{}{"type": "ephemeral"}},
+			},
+		}},
+		Betas: betas,
+	}
+}
+
+func TestCacheControl_KeptWithPromptCachingBeta(t *testing.T) {
+	result, err := TransformRequest(cachedRequest(BetaPromptCaching), "anthropic/claude-3.5-sonnet")
+	if err != nil {
+		t.Fatalf("TransformRequest failed: %v", err)
+	}
+	data, _ := json.Marshal(result.Messages)
+	var messages []struct {
+		Role    string `json:"role"`
+		Content []struct {
+			Text         string               `json:"text"`
+			CacheControl *models.CacheControl `json:"cache_control"`
+		} `json:"content"`
+	}
+	if err := json.Unmarshal(data, &messages); err != nil {
+		t.Fatalf("Expected content parts in every message, got %s", data)
+	}
+	if len(messages) != 2 {
+		t.Fatalf("Expected 2 messages, got %d", len(messages))
+	}
+
+	// The identity note, then the two system blocks
+	system := messages[0].Content
+	if len(system) != 3 || system[1].CacheControl != nil || system[2].CacheControl == nil || system[2].CacheControl.Type != "ephemeral" {
+		t.Errorf("Expected the cache breakpoint on the last system part, got %s", data)
+	}
+	if system[0].Text != defaultIdentityNote {
+		t.Errorf("Expected the identity note first, got %q", system[0].Text)
+	}
+	user := messages[1].Content
+	if len(user) != 1 || user[0].Text != "Long document" || user[0].CacheControl == nil {
+		t.Errorf("Expected the user text to keep its cache_control, got %s", data)
+	}
+}
+
+func TestCacheControl_StrippedWithoutBeta(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		model string
+		betas []string
+	}{
+		{"no beta", "anthropic/claude-3.5-sonnet", nil},
+		{"other beta", "anthropic/claude-3.5-sonnet", []string{"token-efficient-tools-2025-02-19"}},
+		{"unsupported provider", "gpt-4o", []string{BetaPromptCaching}},
+	} {
+		result, err := TransformRequest(cachedRequest(tc.betas...), tc.model)
+		if err != nil {
+			t.Fatalf("%s: TransformRequest failed: %v", tc.name, err)
+		}
+		data, _ := json.Marshal(result)
+		if strings.Contains(string(data), "cache_control") {
+			t.Errorf("%s: expected cache_control to be stripped, got %s", tc.name, data)
+		}
+		if system, ok := result.Messages[0].Content.(string); !ok || !strings.HasSuffix(system, "You are a coding assistant.\n\nProject context.") {
+			t.Errorf("%s: expected the system prompt as a string, got %v", tc.name, result.Messages[0].Content)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+// Package translator handles protocol translation between Anthropic and OpenAI formats.
+package translator
+
+import (
+	"strings"
+
+	"github.com/jedarden/clasp/internal/logging"
+	"github.com/jedarden/clasp/pkg/models"
+)
+
+// imageOmittedText replaces images sent to a model without vision support.
+const imageOmittedText = "[Image omitted: the model does not accept images]"
+
+// Capabilities lists the request features a model supports.
+type Capabilities struct {
+	Tools    bool `json:"tools"`
+	Vision   bool `json:"vision"`
+	Thinking bool `json:"thinking"`
+	JSONMode bool `json:"json_mode"`
+}
+
+// ModelFamily maps model name prefixes to the capabilities of that family.
+type ModelFamily struct {
+	Name     string
+	Prefixes []string
+	Capabilities
+}
+
+// allCapabilities is assumed for models not in the registry, so unknown
+// models get requests forwarded unchanged.
+var allCapabilities = Capabilities{Tools: true, Vision: true, Thinking: true, JSONMode: true}
+
+// modelFamilies is the capability registry. Prefixes are matched against the
+// lowercased model name without any "vendor/" prefix, and the first matching
+// family wins, so more specific families come first.
+var modelFamilies = []ModelFamily{
+	// OpenAI
+	{"o1-mini", []string{"o1-mini", "o1-preview"}, Capabilities{Thinking: true}},
+	{"o-series", []string{"o1", "o3", "o4"}, Capabilities{Tools: true, Vision: true, Thinking: true, JSONMode: true}},
+	{"gpt-5", []string{"gpt-5", "gpt5", "codex"}, Capabilities{Tools: true, Vision: true, Thinking: true, JSONMode: true}},
+	{"gpt-4o", []string{"gpt-4o", "gpt-4.1", "gpt-4.5", "gpt-4-turbo", "chatgpt-4o"}, Capabilities{Tools: true, Vision: true, JSONMode: true}},
+	{"gpt-4", []string{"gpt-4"}, Capabilities{Tools: true, JSONMode: true}},
+	{"gpt-3.5", []string{"gpt-3.5"}, Capabilities{Tools: true, JSONMode: true}},
+	// Anthropic (via OpenRouter)
+	{"claude-thinking", []string{"claude-3-7", "claude-3.7", "claude-sonnet-4", "claude-opus-4", "claude-haiku-4", "claude-4"}, Capabilities{Tools: true, Vision: true, Thinking: true, JSONMode: true}},
+	{"claude", []string{"claude"}, Capabilities{Tools: true, Vision: true, JSONMode: true}},
+	// Google
+	{"gemini-thinking", []string{"gemini-2.5", "gemini-2-5", "gemini-3"}, Capabilities{Tools: true, Vision: true, Thinking: true, JSONMode: true}},
+	{"gemini", []string{"gemini"}, Capabilities{Tools: true, Vision: true, JSONMode: true}},
+	// DeepSeek
+	{"deepseek-speciale", []string{"deepseek-v3.2-speciale"}, Capabilities{Thinking: true, JSONMode: true}},
+	{"deepseek-reasoner", []string{"deepseek-r1", "deepseek-reasoner", "deepseek-v3.1", "deepseek-v3.2"}, Capabilities{Tools: true, Thinking: true, JSONMode: true}},
+	{"deepseek", []string{"deepseek"}, Capabilities{Tools: true, JSONMode: true}},
+	// xAI
+	{"grok-reasoning", []string{"grok-3-mini", "grok-4"}, Capabilities{Tools: true, Vision: true, Thinking: true, JSONMode: true}},
+	{"grok-vision", []string{"grok-2-vision", "grok-vision"}, Capabilities{Tools: true, Vision: true, JSONMode: true}},
+	{"grok", []string{"grok"}, Capabilities{Tools: true, JSONMode: true}},
+	// Qwen
+	{"qwen-vl", []string{"qwen-vl", "qwen2-vl", "qwen2.5-vl", "qwen3-vl"}, Capabilities{Tools: true, Vision: true, JSONMode: true}},
+	{"qwen-thinking", []string{"qwen3", "qwq"}, Capabilities{Tools: true, Thinking: true, JSONMode: true}},
+	{"qwen", []string{"qwen"}, Capabilities{Tools: true, JSONMode: true}},
+	// MiniMax
+	{"minimax", []string{"minimax"}, Capabilities{Tools: true, Thinking: true}},
+	// Local models (Ollama)
+	{"llava", []string{"llava", "bakllava"}, Capabilities{Vision: true, JSONMode: true}},
+	{"llama-vision", []string{"llama3.2-vision", "llama-3.2-11b-vision", "llama-3.2-90b-vision"}, Capabilities{Vision: true, JSONMode: true}},
+	{"llama", []string{"llama", "mistral", "mixtral", "codellama"}, Capabilities{Tools: true, JSONMode: true}},
+}
+
+// ModelFamilies returns the capability registry in match order.
+func ModelFamilies() []ModelFamily {
+	families := make([]ModelFamily, len(modelFamilies))
+	copy(families, modelFamilies)
+	return families
+}
+
+// LookupCapabilities returns the capabilities of a model and the name of its
+// family. Models not in the registry report every capability and an empty
+// family name.
+func LookupCapabilities(model string) (Capabilities, string) {
+	name := strings.ToLower(model)
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	for _, family := range modelFamilies {
+		for _, prefix := range family.Prefixes {
+			if strings.HasPrefix(name, prefix) {
+				return family.Capabilities, family.Name
+			}
+		}
+	}
+	return allCapabilities, ""
+}
+
+// String lists the supported capabilities, e.g. "tools, vision".
+func (c Capabilities) String() string {
+	var names []string
+	if c.Tools {
+		names = append(names, "tools")
+	}
+	if c.Vision {
+		names = append(names, "vision")
+	}
+	if c.Thinking {
+		names = append(names, "thinking")
+	}
+	if c.JSONMode {
+		names = append(names, "json_mode")
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, ", ")
+}
+
+// stripImages replaces image parts in messages with a text note, for models
+// that would reject them.
+func stripImages(messages []models.OpenAIMessage, targetModel string) []models.OpenAIMessage {
+	stripped := 0
+	for i, msg := range messages {
+		parts, ok := msg.Content.([]interface{})
+		if !ok {
+			continue
+		}
+		for j, p := range parts {
+			if part, ok := p.(models.OpenAIContentPart); ok && part.Type == "image_url" {
+				parts[j] = models.OpenAIContentPart{Type: "text", Text: imageOmittedText}
+				stripped++
+			}
+		}
+		messages[i].Content = parts
+	}
+	if stripped > 0 {
+		logging.LogDebugMessage("[REQUEST] Replaced %d image(s): model %s does not support vision", stripped, targetModel)
+	}
+	return messages
+}
@@ -0,0 +1,141 @@
+package translator
+
+import (
+	"testing"
+
+	"github.com/jedarden/clasp/pkg/models"
+)
+
+func TestLookupCapabilities(t *testing.T) {
+	tests := []struct {
+		model    string
+		family   string
+		tools    bool
+		vision   bool
+		thinking bool
+	}{
+		{"gpt-4o", "gpt-4o", true, true, false},
+		{"openai/gpt-4o-mini", "gpt-4o", true, true, false},
+		{"gpt-4", "gpt-4", true, false, false},
+		{"o3-mini", "o-series", true, true, true},
+		{"o1-mini", "o1-mini", false, false, true},
+		{"gpt-5.1-codex", "gpt-5", true, true, true},
+		{"deepseek-chat", "deepseek", true, false, false},
+		{"deepseek/deepseek-r1", "deepseek-reasoner", true, false, true},
+		{"x-ai/grok-3-mini", "grok-reasoning", true, true, true},
+		{"grok-2", "grok", true, false, false},
+		{"google/gemini-2.5-pro", "gemini-thinking", true, true, true},
+		{"Qwen3-Coder", "qwen-thinking", true, false, true},
+		{"llava:13b", "llava", false, true, false},
+		{"my-finetune", "", true, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.model, func(t *testing.T) {
+			caps, family := LookupCapabilities(tt.model)
+			if family != tt.family {
+				t.Errorf("family = %q, want %q", family, tt.family)
+			}
+			if caps.Tools != tt.tools || caps.Vision != tt.vision || caps.Thinking != tt.thinking {
+				t.Errorf("capabilities = %s, want tools=%v vision=%v thinking=%v", caps, tt.tools, tt.vision, tt.thinking)
+			}
+		})
+	}
+}
+
+func TestTransformRequest_StripsThinkingForNonReasoningModel(t *testing.T) {
+	req := &models.AnthropicRequest{
+		Model:     "claude-3-5-sonnet-20241022",
+		MaxTokens: 4096,
+		Messages: []models.AnthropicMessage{
+			{Role: "user", Content: "Think it through"},
+		},
+		Thinking: &models.ThinkingConfig{
+			Type:         "enabled",
+			BudgetTokens: 16000,
+		},
+	}
+
+	for _, model := range []string{"gpt-4o", "grok-2", "qwen-2.5-72b", "deepseek-chat"} {
+		t.Run(model, func(t *testing.T) {
+			result, err := TransformRequest(req, model)
+			if err != nil {
+				t.Fatalf("TransformRequest failed: %v", err)
+			}
+			if result.ReasoningEffort != "" || result.EnableThinking != nil || result.ThinkingBudget != 0 ||
+				result.ThinkingConfig != nil || result.ThinkingLevel != "" {
+				t.Errorf("expected thinking parameters to be stripped, got %+v", result)
+			}
+			if result.MaxTokens != 4096 || result.MaxCompletionTokens != 0 {
+				t.Errorf("MaxTokens = %d, MaxCompletionTokens = %d, want 4096 and 0", result.MaxTokens, result.MaxCompletionTokens)
+			}
+		})
+	}
+
+	// A reasoning model in the same family still gets them
+	result, err := TransformRequest(req, "grok-3-mini")
+	if err != nil {
+		t.Fatalf("TransformRequest failed: %v", err)
+	}
+	if result.ReasoningEffort != "low" {
+		t.Errorf("ReasoningEffort = %q, want %q", result.ReasoningEffort, "low")
+	}
+}
+
+func TestTransformRequest_StripsToolsForModelWithoutFunctionCalling(t *testing.T) {
+	req := &models.AnthropicRequest{
+		MaxTokens: 1024,
+		Messages: []models.AnthropicMessage{
+			{Role: "user", Content: "What's the weather?"},
+		},
+		Tools: []models.AnthropicTool{
+			{Name: "get_weather", InputSchema: map[string]interface{}{"type": "object"}},
+		},
+		ToolChoice: map[string]interface{}{"type": "auto"},
+	}
+
+	result, err := TransformRequestWithProvider(req, "o1-mini", ProviderOpenAI)
+	if err != nil {
+		t.Fatalf("TransformRequestWithProvider failed: %v", err)
+	}
+	if len(result.Tools) != 0 || result.ToolChoice != nil || result.ParallelToolCalls != nil {
+		t.Errorf("expected tools to be stripped, got tools=%v tool_choice=%v", result.Tools, result.ToolChoice)
+	}
+}
+
+func TestTransformRequest_ReplacesImagesForTextOnlyModel(t *testing.T) {
+	req := &models.AnthropicRequest{
+		MaxTokens: 1024,
+		Messages: []models.AnthropicMessage{
+			{Role: "user", Content: []interface{}{
+				map[string]interface{}{"type": "text", "text": "What is this?"},
+				map[string]interface{}{"type": "image", "source": map[string]interface{}{
+					"type": "base64", "media_type": "image/png", "data": "iVBORw0KGgo=",
+				}},
+			}},
+		},
+	}
+
+	result, err := TransformRequestWithProvider(req, "deepseek-chat", ProviderDeepSeek)
+	if err != nil {
+		t.Fatalf("TransformRequestWithProvider failed: %v", err)
+	}
+	parts, ok := result.Messages[0].Content.([]interface{})
+	if !ok || len(parts) != 2 {
+		t.Fatalf("expected 2 content parts, got %#v", result.Messages[0].Content)
+	}
+	part := parts[1].(models.OpenAIContentPart)
+	if part.Type != "text" || part.Text != imageOmittedText {
+		t.Errorf("expected the image to be replaced with a note, got %+v", part)
+	}
+
+	// Vision models keep the image
+	result, err = TransformRequestWithProvider(req, "gpt-4o", ProviderOpenAI)
+	if err != nil {
+		t.Fatalf("TransformRequestWithProvider failed: %v", err)
+	}
+	parts = result.Messages[0].Content.([]interface{})
+	if part := parts[1].(models.OpenAIContentPart); part.Type != "image_url" {
+		t.Errorf("expected image_url part for gpt-4o, got %+v", part)
+	}
+}
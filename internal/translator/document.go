@@ -0,0 +1,297 @@
+// Package translator handles protocol translation between Anthropic and OpenAI formats.
+package translator
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/jedarden/clasp/pkg/models"
+)
+
+// DocumentFallback controls how document blocks are handled for providers
+// that cannot accept file inputs.
+type DocumentFallback string
+
+const (
+	// DocumentFallbackText extracts the document text and sends it as a text part.
+	DocumentFallbackText DocumentFallback = "text"
+	// DocumentFallbackError rejects the request with an UnsupportedContentError.
+	DocumentFallbackError DocumentFallback = "error"
+)
+
+// maxInlineDocumentBytes is the largest decoded document sent as an inline file part.
+// Larger documents go through the text fallback instead.
+const maxInlineDocumentBytes = 512 * 1024
+
+// Limits on the PDF content inflated during text extraction, so a small
+// compressed stream can't expand into gigabytes of memory.
+const (
+	maxPDFStreamBytes   = 4 * 1024 * 1024  // Per content stream
+	maxPDFInflatedBytes = 16 * 1024 * 1024 // Per document
+)
+
+// UnsupportedContentError is returned when a request contains content that
+// cannot be translated for the target provider.
+type UnsupportedContentError struct {
+	Provider ProviderType
+	Reason   string
+}
+
+func (e *UnsupportedContentError) Error() string {
+	return fmt.Sprintf("unsupported content for provider %s: %s", e.Provider, e.Reason)
+}
+
+// providerSupportsFileInput reports whether a provider accepts inline file parts
+// in Chat Completions messages.
+func providerSupportsFileInput(provider ProviderType) bool {
+	switch provider {
+	case ProviderOpenAI, ProviderOpenRouter:
+		return true
+	default:
+		return false
+	}
+}
+
+// documentFilename returns a filename for a document block, derived from its title.
+func documentFilename(block models.ContentBlock) string {
+	if block.Title != "" {
+		return block.Title
+	}
+	if block.Source != nil && block.Source.MediaType == "application/pdf" {
+		return "document.pdf"
+	}
+	return "document"
+}
+
+// withDocumentTitle prefixes extracted document text with its title, if any.
+func withDocumentTitle(block models.ContentBlock, text string) string {
+	if block.Title == "" {
+		return text
+	}
+	return fmt.Sprintf("[Document: %s]\n%s", block.Title, text)
+}
+
+// decodedDocumentSize estimates the decoded size of base64 document data.
+func decodedDocumentSize(data string) int {
+	return base64.StdEncoding.DecodedLen(len(data))
+}
+
+// documentToText converts a document block to plain text using the configured
+// fallback. Unknown fallback modes behave like DocumentFallbackText.
+func (o *Options) documentToText(block models.ContentBlock, provider ProviderType) (string, error) {
+	if block.Source == nil {
+		return "", &UnsupportedContentError{Provider: provider, Reason: "document block has no source"}
+	}
+
+	// Plain text documents never need a fallback
+	if block.Source.Type == "text" {
+		return withDocumentTitle(block, block.Source.Data), nil
+	}
+
+	if o.DocumentFallback == DocumentFallbackError {
+		return "", &UnsupportedContentError{
+			Provider: provider,
+			Reason:   fmt.Sprintf("document inputs (%s) are not supported and CLASP_DOCUMENT_FALLBACK=error", documentDescription(block)),
+		}
+	}
+
+	switch block.Source.Type {
+	case "base64":
+		raw, err := base64.StdEncoding.DecodeString(block.Source.Data)
+		if err != nil {
+			return "", &UnsupportedContentError{Provider: provider, Reason: fmt.Sprintf("invalid base64 document data: %v", err)}
+		}
+		var text string
+		if block.Source.MediaType == "application/pdf" {
+			text = extractPDFText(raw)
+		} else if strings.HasPrefix(block.Source.MediaType, "text/") {
+			text = string(raw)
+		}
+		if strings.TrimSpace(text) == "" {
+			return "", &UnsupportedContentError{
+				Provider: provider,
+				Reason:   fmt.Sprintf("could not extract text from %s document", documentDescription(block)),
+			}
+		}
+		return withDocumentTitle(block, text), nil
+	default:
+		return "", &UnsupportedContentError{
+			Provider: provider,
+			Reason:   fmt.Sprintf("document source type %q cannot be converted to text", block.Source.Type),
+		}
+	}
+}
+
+// documentDescription returns a short human-readable description of a document source.
+func documentDescription(block models.ContentBlock) string {
+	if block.Source.MediaType != "" {
+		return block.Source.MediaType
+	}
+	return block.Source.Type
+}
+
+// transformDocumentForChat converts a document block to a Chat Completions content part.
+func (o *Options) transformDocumentForChat(block models.ContentBlock, provider ProviderType) (models.OpenAIContentPart, error) {
+	if block.Source != nil && block.Source.Type == "base64" && providerSupportsFileInput(provider) &&
+		decodedDocumentSize(block.Source.Data) <= maxInlineDocumentBytes {
+		return models.OpenAIContentPart{
+			Type: "file",
+			File: &models.FilePart{
+				Filename: documentFilename(block),
+				FileData: fmt.Sprintf("data:%s;base64,%s", block.Source.MediaType, block.Source.Data),
+			},
+		}, nil
+	}
+
+	text, err := o.documentToText(block, provider)
+	if err != nil {
+		return models.OpenAIContentPart{}, err
+	}
+	return models.OpenAIContentPart{Type: "text", Text: text}, nil
+}
+
+// transformDocumentForResponses converts a document block to a Responses API input part.
+func (o *Options) transformDocumentForResponses(block models.ContentBlock) (models.ResponsesContentPart, error) {
+	if block.Source != nil {
+		switch {
+		case block.Source.Type == "base64" && decodedDocumentSize(block.Source.Data) <= maxInlineDocumentBytes:
+			return models.ResponsesContentPart{
+				Type:     "input_file",
+				Filename: documentFilename(block),
+				FileData: fmt.Sprintf("data:%s;base64,%s", block.Source.MediaType, block.Source.Data),
+			}, nil
+		case block.Source.Type == "url" && block.Source.URL != "":
+			return models.ResponsesContentPart{
+				Type:    "input_file",
+				FileURL: block.Source.URL,
+			}, nil
+		}
+	}
+
+	text, err := o.documentToText(block, ProviderOpenAI)
+	if err != nil {
+		return models.ResponsesContentPart{}, err
+	}
+	return models.ResponsesContentPart{Type: "input_text", Text: text}, nil
+}
+
+// Patterns used by the best-effort PDF text extractor.
+var (
+	pdfStreamPattern = regexp.MustCompile(`(?s)stream\r?\n(.*?)\r?\nendstream`)
+	pdfTextBlock     = regexp.MustCompile(`(?s)BT(.*?)ET`)
+)
+
+// extractPDFText performs a best-effort extraction of literal text strings from a PDF.
+// It decompresses FlateDecode content streams and collects strings shown by the
+// Tj, TJ, ' and " operators. Fonts with custom encodings are not decoded.
+// Inflated streams are truncated at maxPDFStreamBytes, and extraction stops
+// once maxPDFInflatedBytes have been inflated.
+func extractPDFText(data []byte) string {
+	var lines []string
+	remaining := int64(maxPDFInflatedBytes)
+
+	for _, match := range pdfStreamPattern.FindAllSubmatch(data, -1) {
+		if remaining <= 0 {
+			break
+		}
+		content := match[1]
+		if r, err := zlib.NewReader(bytes.NewReader(content)); err == nil {
+			limit := remaining
+			if limit > maxPDFStreamBytes {
+				limit = maxPDFStreamBytes
+			}
+			if inflated, readErr := io.ReadAll(io.LimitReader(r, limit)); readErr == nil || len(inflated) > 0 {
+				content = inflated
+				remaining -= int64(len(inflated))
+			}
+			r.Close()
+		}
+
+		for _, block := range pdfTextBlock.FindAllSubmatch(content, -1) {
+			if line := strings.TrimSpace(extractPDFTextBlock(block[1])); line != "" {
+				lines = append(lines, line)
+			}
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// extractPDFTextBlock extracts text from the operators of a single BT/ET block.
+func extractPDFTextBlock(ops []byte) string {
+	var sb strings.Builder
+	for i := 0; i < len(ops); i++ {
+		switch c := ops[i]; {
+		case c == '(':
+			str, next := readPDFLiteralString(ops, i+1)
+			sb.WriteString(str)
+			i = next
+		case c == 'T' && i+1 < len(ops) && (ops[i+1] == '*' || ops[i+1] == 'd' || ops[i+1] == 'D'):
+			// Text positioning operators start a new line
+			if sb.Len() > 0 {
+				sb.WriteByte('\n')
+			}
+			i++
+		}
+	}
+	return sb.String()
+}
+
+// readPDFLiteralString reads a PDF literal string starting after the opening
+// parenthesis, returning the decoded string and the index of the closing parenthesis.
+func readPDFLiteralString(ops []byte, start int) (string, int) {
+	var sb strings.Builder
+	depth := 1
+	i := start
+	for ; i < len(ops); i++ {
+		c := ops[i]
+		switch c {
+		case '\\':
+			if i+1 >= len(ops) {
+				continue
+			}
+			i++
+			switch esc := ops[i]; esc {
+			case 'n':
+				sb.WriteByte('\n')
+			case 'r':
+				sb.WriteByte('\r')
+			case 't':
+				sb.WriteByte('\t')
+			case 'b', 'f':
+				// Ignore backspace and form feed
+			case '0', '1', '2', '3', '4', '5', '6', '7':
+				val := 0
+				n := 0
+				for n < 3 && i < len(ops) && ops[i] >= '0' && ops[i] <= '7' {
+					val = val*8 + int(ops[i]-'0')
+					i++
+					n++
+				}
+				i--
+				sb.WriteByte(byte(val))
+			case '\r', '\n':
+				// Line continuation
+			default:
+				sb.WriteByte(esc)
+			}
+		case '(':
+			depth++
+			sb.WriteByte(c)
+		case ')':
+			depth--
+			if depth == 0 {
+				return sb.String(), i
+			}
+			sb.WriteByte(c)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String(), i
+}
@@ -0,0 +1,241 @@
+// Package translator provides protocol translation between Anthropic and OpenAI formats.
+package translator
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/jedarden/clasp/pkg/models"
+)
+
+// minimalPDF is a tiny uncompressed PDF with a single text object.
+const minimalPDF = "%PDF-1.4\n1 0 obj\n<< /Length 44 >>\nstream\nBT /F1 12 Tf 72 712 Td (Hello \\(PDF\\) world) Tj ET\nendstream\nendobj\n%%EOF"
+
+func documentRequest(source *models.ImageSource) *models.AnthropicRequest {
+	return &models.AnthropicRequest{
+		Model:     "claude-3-5-sonnet-20241022",
+		MaxTokens: 1024,
+		Messages: []models.AnthropicMessage{
+			{
+				Role: "user",
+				Content: []interface{}{
+					map[string]interface{}{
+						"type":   "document",
+						"title":  "report.pdf",
+						"source": source,
+					},
+					map[string]interface{}{
+						"type": "text",
+						"text": "Summarize this document",
+					},
+				},
+			},
+		},
+	}
+}
+
+func base64PDFSource() *models.ImageSource {
+	return &models.ImageSource{
+		Type:      "base64",
+		MediaType: "application/pdf",
+		Data:      base64.StdEncoding.EncodeToString([]byte(minimalPDF)),
+	}
+}
+
+func TestTransformRequest_DocumentAsFilePart(t *testing.T) {
+	result, err := TransformRequestWithProvider(documentRequest(base64PDFSource()), "gpt-4o", ProviderOpenAI)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	parts, ok := result.Messages[0].Content.([]interface{})
+	if !ok || len(parts) != 2 {
+		t.Fatalf("expected 2 content parts, got %#v", result.Messages[0].Content)
+	}
+	filePart, ok := parts[0].(models.OpenAIContentPart)
+	if !ok || filePart.Type != "file" || filePart.File == nil {
+		t.Fatalf("expected file part, got %#v", parts[0])
+	}
+	if filePart.File.Filename != "report.pdf" {
+		t.Errorf("expected filename report.pdf, got %q", filePart.File.Filename)
+	}
+	if !strings.HasPrefix(filePart.File.FileData, "data:application/pdf;base64,") {
+		t.Errorf("expected PDF data URL, got %q", filePart.File.FileData)
+	}
+}
+
+func TestTransformRequest_DocumentTextFallback(t *testing.T) {
+	opts := DefaultOptions()
+	opts.DocumentFallback = DocumentFallbackText
+
+	result, err := opts.TransformRequestWithProvider(documentRequest(base64PDFSource()), "deepseek-chat", ProviderDeepSeek)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	parts, ok := result.Messages[0].Content.([]interface{})
+	if !ok || len(parts) != 2 {
+		t.Fatalf("expected 2 content parts, got %#v", result.Messages[0].Content)
+	}
+	textPart, ok := parts[0].(models.OpenAIContentPart)
+	if !ok || textPart.Type != "text" {
+		t.Fatalf("expected text part, got %#v", parts[0])
+	}
+	if !strings.Contains(textPart.Text, "Hello (PDF) world") {
+		t.Errorf("expected extracted PDF text, got %q", textPart.Text)
+	}
+	if !strings.Contains(textPart.Text, "report.pdf") {
+		t.Errorf("expected document title in text, got %q", textPart.Text)
+	}
+}
+
+func TestTransformRequest_DocumentUnsupportedProviderError(t *testing.T) {
+	opts := DefaultOptions()
+	opts.DocumentFallback = DocumentFallbackError
+
+	_, err := opts.TransformRequestWithProvider(documentRequest(base64PDFSource()), "deepseek-chat", ProviderDeepSeek)
+	if err == nil {
+		t.Fatal("expected error for unsupported provider, got nil")
+	}
+
+	var contentErr *UnsupportedContentError
+	if !errors.As(err, &contentErr) {
+		t.Fatalf("expected UnsupportedContentError, got %T: %v", err, err)
+	}
+	if contentErr.Provider != ProviderDeepSeek {
+		t.Errorf("expected provider deepseek, got %q", contentErr.Provider)
+	}
+	if !strings.Contains(contentErr.Error(), "application/pdf") {
+		t.Errorf("expected media type in error, got %q", contentErr.Error())
+	}
+}
+
+func TestTransformRequest_DocumentUnextractableText(t *testing.T) {
+	source := &models.ImageSource{
+		Type:      "base64",
+		MediaType: "application/pdf",
+		Data:      base64.StdEncoding.EncodeToString([]byte("%PDF-1.4\n%%EOF")),
+	}
+
+	_, err := TransformRequestWithProvider(documentRequest(source), "qwen-max", ProviderQwen)
+	var contentErr *UnsupportedContentError
+	if !errors.As(err, &contentErr) {
+		t.Fatalf("expected UnsupportedContentError, got %v", err)
+	}
+}
+
+func TestTransformRequest_PlainTextDocument(t *testing.T) {
+	opts := DefaultOptions()
+	opts.DocumentFallback = DocumentFallbackError
+
+	source := &models.ImageSource{Type: "text", MediaType: "text/plain", Data: "plain document body"}
+	result, err := opts.TransformRequestWithProvider(documentRequest(source), "deepseek-chat", ProviderDeepSeek)
+	if err != nil {
+		t.Fatalf("text documents should never need a fallback: %v", err)
+	}
+
+	parts := result.Messages[0].Content.([]interface{})
+	textPart := parts[0].(models.OpenAIContentPart)
+	if !strings.Contains(textPart.Text, "plain document body") {
+		t.Errorf("expected document text, got %q", textPart.Text)
+	}
+}
+
+func TestTransformRequestToResponses_DocumentAsInputFile(t *testing.T) {
+	result, err := TransformRequestToResponses(documentRequest(base64PDFSource()), "gpt-5", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	parts, ok := result.Input[0].Content.([]interface{})
+	if !ok || len(parts) != 2 {
+		t.Fatalf("expected 2 content parts, got %#v", result.Input[0].Content)
+	}
+	filePart, ok := parts[0].(models.ResponsesContentPart)
+	if !ok || filePart.Type != "input_file" {
+		t.Fatalf("expected input_file part, got %#v", parts[0])
+	}
+	if filePart.Filename != "report.pdf" {
+		t.Errorf("expected filename report.pdf, got %q", filePart.Filename)
+	}
+	if !strings.HasPrefix(filePart.FileData, "data:application/pdf;base64,") {
+		t.Errorf("expected PDF data URL, got %q", filePart.FileData)
+	}
+}
+
+func TestTransformRequestToResponses_DocumentURL(t *testing.T) {
+	source := &models.ImageSource{Type: "url", URL: "https://example.com/report.pdf"}
+	result, err := TransformRequestToResponses(documentRequest(source), "gpt-5", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	parts := result.Input[0].Content.([]interface{})
+	filePart := parts[0].(models.ResponsesContentPart)
+	if filePart.Type != "input_file" || filePart.FileURL != "https://example.com/report.pdf" {
+		t.Errorf("expected input_file with file_url, got %#v", filePart)
+	}
+}
+
+func TestExtractPDFText_MultipleOperators(t *testing.T) {
+	pdf := "stream\nBT (First line) Tj 0 -14 Td (Second) Tj [(li) -20 (ne)] TJ ET\nendstream"
+	text := extractPDFText([]byte(pdf))
+	if text != "First line\nSecondline" {
+		t.Errorf("extractPDFText() = %q", text)
+	}
+}
+
+// flateStream returns content as a FlateDecode PDF stream.
+func flateStream(t *testing.T, content string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	if _, err := w.Write([]byte(content)); err != nil {
+		t.Fatalf("compress: %v", err)
+	}
+	w.Close()
+	return "stream\n" + buf.String() + "\nendstream\n"
+}
+
+func TestExtractPDFText_InflationLimits(t *testing.T) {
+	padding := strings.Repeat(" ", maxPDFStreamBytes)
+
+	// Text past the per-stream limit is cut off
+	pdf := flateStream(t, "BT (Early) Tj ET"+padding+"BT (Late) Tj ET")
+	if text := extractPDFText([]byte(pdf)); text != "Early" {
+		t.Errorf("extractPDFText() = %q, want only the text within the stream limit", text)
+	}
+
+	// Streams after the document limit are not inflated
+	pdf = flateStream(t, "BT (First) Tj ET")
+	for i := 0; i < maxPDFInflatedBytes/maxPDFStreamBytes; i++ {
+		pdf += flateStream(t, padding)
+	}
+	pdf += flateStream(t, "BT (Last) Tj ET")
+	if text := extractPDFText([]byte(pdf)); text != "First" {
+		t.Errorf("extractPDFText() = %q, want extraction to stop at the document limit", text)
+	}
+}
+
+func TestTransformRequest_LargeDocumentUsesTextFallback(t *testing.T) {
+	pdf := minimalPDF + strings.Repeat(" ", maxInlineDocumentBytes)
+	source := &models.ImageSource{
+		Type:      "base64",
+		MediaType: "application/pdf",
+		Data:      base64.StdEncoding.EncodeToString([]byte(pdf)),
+	}
+	result, err := TransformRequestWithProvider(documentRequest(source), "gpt-4o", ProviderOpenAI)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	parts := result.Messages[0].Content.([]interface{})
+	textPart, ok := parts[0].(models.OpenAIContentPart)
+	if !ok || textPart.Type != "text" || !strings.Contains(textPart.Text, "Hello (PDF) world") {
+		t.Errorf("expected the extracted text for a document over the inline limit, got %#v", parts[0])
+	}
+}
@@ -0,0 +1,166 @@
+// Package translator handles protocol translation between Anthropic and OpenAI formats.
+package translator
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jedarden/clasp/pkg/models"
+)
+
+// finishReasonMap maps upstream finish_reason values (lowercased) to Anthropic stop_reason.
+// Besides OpenAI's own values it covers common provider-specific reasons.
+var finishReasonMap = map[string]string{
+	// Natural end of turn
+	"stop":      "end_turn",
+	"eos":       "end_turn", // Ollama, vLLM, TGI
+	"eos_token": "end_turn", // TGI
+	"end_turn":  "end_turn",
+	"complete":  "end_turn",
+	"finished":  "end_turn",
+
+	// Stop sequence hit
+	"stop_sequence": "stop_sequence",
+
+	// Tool use
+	"tool_calls":    "tool_use",
+	"tool_call":     "tool_use",
+	"function_call": "tool_use", // Legacy OpenAI functions API
+	"tool_use":      "tool_use",
+
+	// Output token limit
+	"length":       "max_tokens",
+	"max_length":   "max_tokens", // Hugging Face
+	"max_tokens":   "max_tokens", // Gemini (MAX_TOKENS)
+	"model_length": "max_tokens", // vLLM when the context window is exhausted
+
+	// Content filtering
+	"content_filter":     "end_turn",
+	"safety":             "end_turn", // Gemini
+	"recitation":         "end_turn", // Gemini
+	"blocklist":          "end_turn", // Gemini
+	"prohibited_content": "end_turn", // Gemini
+	"spii":               "end_turn", // Gemini
+
+	// Anthropic reasons reported as-is by Anthropic-compatible servers
+	"pause_turn": "pause_turn",
+	"refusal":    "refusal",
+}
+
+// contentFilterReasons lists the upstream finish_reason values (lowercased) that
+// mean the provider's content filter stopped the response.
+var contentFilterReasons = map[string]bool{
+	"content_filter":     true,
+	"safety":             true,
+	"recitation":         true,
+	"blocklist":          true,
+	"prohibited_content": true,
+	"spii":               true,
+}
+
+// validStopReasons lists the Anthropic stop_reason values a finish reason may map to.
+var validStopReasons = map[string]bool{
+	"end_turn":      true,
+	"max_tokens":    true,
+	"stop_sequence": true,
+	"tool_use":      true,
+	"pause_turn":    true,
+	"refusal":       true,
+}
+
+// SetFinishReasonOverrides sets finish_reason mappings that take precedence over
+// the built-in table. Keys are matched case-insensitively. On error o is left
+// unchanged.
+func (o *Options) SetFinishReasonOverrides(overrides map[string]string) error {
+	normalized := make(map[string]string, len(overrides))
+	for reason, stopReason := range overrides {
+		if !validStopReasons[stopReason] {
+			return fmt.Errorf("invalid stop_reason %q for finish_reason %q", stopReason, reason)
+		}
+		normalized[strings.ToLower(reason)] = stopReason
+	}
+	o.finishReasonOverrides = normalized
+	return nil
+}
+
+// MapFinishReason maps an upstream finish_reason to an Anthropic stop_reason
+// with the default options. Unknown reasons map to "end_turn".
+func MapFinishReason(reason string) string {
+	return defaultOptions.mapFinishReason(reason)
+}
+
+// MapFinishReason maps an upstream finish_reason to an Anthropic stop_reason,
+// applying the overrides set on o. Unknown reasons map to "end_turn".
+func (o *Options) MapFinishReason(reason string) string {
+	return o.mapFinishReason(reason)
+}
+
+// mapFinishReason maps OpenAI finish_reason to Anthropic stop_reason.
+func (o *Options) mapFinishReason(reason string) string {
+	key := strings.ToLower(reason)
+
+	if override, ok := o.finishReasonOverrides[key]; ok {
+		return override
+	}
+
+	if stopReason, ok := finishReasonMap[key]; ok {
+		return stopReason
+	}
+	return "end_turn"
+}
+
+// IsContentFilterReason reports whether an upstream finish_reason means the
+// response was stopped by the provider's content filter.
+func IsContentFilterReason(reason string) bool {
+	return contentFilterReasons[strings.ToLower(reason)]
+}
+
+// RefusalBlock returns the refusal content block added to a response that a
+// content filter stopped, so clients can tell it apart from a normal end_turn.
+func RefusalBlock(reason string) models.AnthropicContentBlock {
+	return models.AnthropicContentBlock{
+		Type: "refusal",
+		Text: fmt.Sprintf("The upstream provider stopped this response with its content filter (finish_reason %q). The output above may be incomplete.", reason),
+	}
+}
+
+// ResponsesStopReason maps the status of a Responses API response to an
+// Anthropic stop_reason with the default options.
+func ResponsesStopReason(resp *models.ResponsesResponse, hasToolCalls bool) string {
+	return defaultOptions.ResponsesStopReason(resp, hasToolCalls)
+}
+
+// ResponsesStopReason maps the status of a Responses API response to an
+// Anthropic stop_reason. A response still running in the background maps to
+// pause_turn, so the client can continue the turn. Unknown statuses map to "".
+func (o *Options) ResponsesStopReason(resp *models.ResponsesResponse, hasToolCalls bool) string {
+	switch resp.Status {
+	case "completed":
+		if hasToolCalls {
+			return "tool_use"
+		}
+		return "end_turn"
+	case "incomplete":
+		if resp.IncompleteDetails != nil && IsContentFilterReason(resp.IncompleteDetails.Reason) {
+			return o.mapFinishReason(resp.IncompleteDetails.Reason)
+		}
+		return "max_tokens"
+	case "in_progress", "queued":
+		return "pause_turn"
+	case "failed", "canceled":
+		return "end_turn"
+	}
+	return ""
+}
+
+// ResponsesContentFilterReason returns the incomplete reason of a Responses
+// API response stopped by a content filter, or "" otherwise.
+func ResponsesContentFilterReason(resp *models.ResponsesResponse) string {
+	if resp == nil || resp.Status != "incomplete" || resp.IncompleteDetails == nil {
+		return ""
+	}
+	if IsContentFilterReason(resp.IncompleteDetails.Reason) {
+		return resp.IncompleteDetails.Reason
+	}
+	return ""
+}
@@ -0,0 +1,165 @@
+// Package translator provides protocol translation between Anthropic and OpenAI formats.
+package translator
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/jedarden/clasp/pkg/models"
+)
+
+func TestMapFinishReason_ProviderSpecific(t *testing.T) {
+	tests := []struct {
+		reason   string
+		expected string
+	}{
+		{"eos", "end_turn"},
+		{"EOS_TOKEN", "end_turn"},
+		{"STOP", "end_turn"},
+		{"function_call", "tool_use"},
+		{"tool_call", "tool_use"},
+		{"max_length", "max_tokens"},
+		{"MAX_TOKENS", "max_tokens"},
+		{"model_length", "max_tokens"},
+		{"stop_sequence", "stop_sequence"},
+		{"SAFETY", "end_turn"},
+		{"recitation", "end_turn"},
+		{"pause_turn", "pause_turn"},
+		{"refusal", "refusal"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.reason, func(t *testing.T) {
+			if got := MapFinishReason(tt.reason); got != tt.expected {
+				t.Errorf("MapFinishReason(%q) = %q, want %q", tt.reason, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSetFinishReasonOverrides(t *testing.T) {
+	opts := DefaultOptions()
+	if err := opts.SetFinishReasonOverrides(map[string]string{"Safety": "refusal", "eos": "stop_sequence"}); err != nil {
+		t.Fatalf("SetFinishReasonOverrides failed: %v", err)
+	}
+
+	if got := opts.MapFinishReason("safety"); got != "refusal" {
+		t.Errorf("override not applied: got %q", got)
+	}
+	if got := opts.MapFinishReason("eos"); got != "stop_sequence" {
+		t.Errorf("override not applied: got %q", got)
+	}
+	if got := opts.MapFinishReason("length"); got != "max_tokens" {
+		t.Errorf("built-in mapping should still apply: got %q", got)
+	}
+	if got := MapFinishReason("safety"); got != "end_turn" {
+		t.Errorf("overrides should not affect the default options, got %q", got)
+	}
+
+	if err := opts.SetFinishReasonOverrides(map[string]string{"eos": "done"}); err == nil {
+		t.Error("expected error for invalid stop_reason")
+	}
+	if got := opts.MapFinishReason("safety"); got != "refusal" {
+		t.Errorf("invalid overrides should not replace the current ones, got %q", got)
+	}
+}
+
+func TestStreamProcessor_ProviderFinishReason(t *testing.T) {
+	var buf bytes.Buffer
+	sp := NewStreamProcessor(&buf, "msg_123", "llama3.1")
+
+	stream := "data: {\"id\":\"1\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Hi\"}}]}\n\n" +
+		"data: {\"id\":\"1\",\"choices\":[{\"index\":0,\"delta\":{},\"finish_reason\":\"max_length\"}]}\n\n" +
+		"data: [DONE]\n\n"
+	if err := sp.ProcessStream(strings.NewReader(stream)); err != nil {
+		t.Fatalf("ProcessStream() error = %v", err)
+	}
+	if !strings.Contains(buf.String(), `"stop_reason":"max_tokens"`) {
+		t.Errorf("expected max_tokens stop reason, got:\n%s", buf.String())
+	}
+}
+
+func TestStreamProcessor_ContentFilterRefusal(t *testing.T) {
+	var buf bytes.Buffer
+	sp := NewStreamProcessor(&buf, "msg_123", "gpt-4o")
+
+	stream := "data: {\"id\":\"1\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Here is\"}}]}\n\n" +
+		"data: {\"id\":\"1\",\"choices\":[{\"index\":0,\"delta\":{},\"finish_reason\":\"content_filter\"}]}\n\n" +
+		"data: [DONE]\n\n"
+	if err := sp.ProcessStream(strings.NewReader(stream)); err != nil {
+		t.Fatalf("ProcessStream() error = %v", err)
+	}
+
+	output := buf.String()
+	want := []string{"message_start", "ping", "content_block_start", "content_block_delta", "content_block_stop", "content_block_start", "content_block_stop", "message_delta", "message_stop", "[DONE]"}
+	if got := streamEventTypes(output); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("events = %v, want %v", got, want)
+	}
+	if !strings.Contains(output, `"index":1,"content_block":{"type":"refusal","text":"The upstream provider stopped this response with its content filter`) {
+		t.Errorf("expected a refusal block after the text block, got:\n%s", output)
+	}
+	if !strings.Contains(output, `"stop_reason":"end_turn"`) {
+		t.Errorf("expected end_turn stop reason, got:\n%s", output)
+	}
+}
+
+func TestResponsesStopReason(t *testing.T) {
+	tests := []struct {
+		name         string
+		resp         models.ResponsesResponse
+		hasToolCalls bool
+		expected     string
+	}{
+		{"completed", models.ResponsesResponse{Status: "completed"}, false, "end_turn"},
+		{"completed with tools", models.ResponsesResponse{Status: "completed"}, true, "tool_use"},
+		{"max output tokens", models.ResponsesResponse{Status: "incomplete", IncompleteDetails: &models.ResponsesIncompleteDetails{Reason: "max_output_tokens"}}, false, "max_tokens"},
+		{"content filter", models.ResponsesResponse{Status: "incomplete", IncompleteDetails: &models.ResponsesIncompleteDetails{Reason: "content_filter"}}, false, "end_turn"},
+		{"background", models.ResponsesResponse{Status: "in_progress"}, false, "pause_turn"},
+		{"queued", models.ResponsesResponse{Status: "queued"}, false, "pause_turn"},
+		{"failed", models.ResponsesResponse{Status: "failed"}, false, "end_turn"},
+		{"unknown", models.ResponsesResponse{}, false, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ResponsesStopReason(&tt.resp, tt.hasToolCalls); got != tt.expected {
+				t.Errorf("ResponsesStopReason() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestResponsesStreamProcessor_ContentFilterRefusal(t *testing.T) {
+	var buf bytes.Buffer
+	sp := NewResponsesStreamProcessor(&buf, "msg_test", "gpt-5")
+
+	stream := `data: {"type":"response.created","response":{"id":"resp_1"}}` + "\n\n" +
+		`data: {"type":"response.output_text.delta","delta":"Here is","sequence_number":1}` + "\n\n" +
+		`data: {"type":"response.incomplete","response":{"id":"resp_1","status":"incomplete","incomplete_details":{"reason":"content_filter"}}}` + "\n\n"
+	if err := sp.ProcessStream(strings.NewReader(stream)); err != nil {
+		t.Fatalf("ProcessStream() error = %v", err)
+	}
+
+	output := buf.String()
+	if !strings.Contains(output, `"index":1,"content_block":{"type":"refusal"`) {
+		t.Errorf("expected a refusal block after the text block, got:\n%s", output)
+	}
+	if !strings.Contains(output, `"stop_reason":"end_turn"`) || strings.Contains(output, `"stop_reason":"max_tokens"`) {
+		t.Errorf("expected end_turn stop reason, got:\n%s", output)
+	}
+}
+
+func TestTransformGeminiResponse_SafetyRefusal(t *testing.T) {
+	resp := &models.GeminiResponse{
+		Candidates: []models.GeminiCandidate{{FinishReason: "SAFETY"}},
+	}
+
+	anthropicResp := TransformGeminiResponse(resp, "msg_1", "gemini-2.5-pro")
+	if anthropicResp.StopReason != "end_turn" {
+		t.Errorf("StopReason = %q, want end_turn", anthropicResp.StopReason)
+	}
+	if len(anthropicResp.Content) != 1 || anthropicResp.Content[0].Type != "refusal" || anthropicResp.Content[0].Text == "" {
+		t.Errorf("expected a single refusal block, got %+v", anthropicResp.Content)
+	}
+}
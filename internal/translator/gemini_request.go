@@ -0,0 +1,352 @@
+// Package translator handles protocol translation between Anthropic and OpenAI formats.
+package translator
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/jedarden/clasp/internal/logging"
+	"github.com/jedarden/clasp/pkg/models"
+)
+
+// geminiSkipThoughtSignature is the documented placeholder for the thought
+// signature Gemini 3 requires on replayed function calls. Signatures returned
+// by Gemini are not kept in Anthropic tool_use blocks, so the placeholder is
+// sent instead.
+const geminiSkipThoughtSignature = "skip_thought_signature_validator"
+
+// geminiMaxThinkingBudget is the largest thinking budget Gemini 2.5 accepts.
+const geminiMaxThinkingBudget = 24576
+
+// TransformRequestToGemini converts an Anthropic request to a Gemini
+// generateContent request with the default options.
+func TransformRequestToGemini(req *models.AnthropicRequest, targetModel string) (*models.GeminiRequest, error) {
+	return defaultOptions.TransformRequestToGemini(req, targetModel)
+}
+
+// TransformRequestToGemini converts an Anthropic request to a Gemini
+// generateContent request. The model and streaming mode are part of the
+// endpoint URL, not the request body.
+func (o *Options) TransformRequestToGemini(req *models.AnthropicRequest, targetModel string) (*models.GeminiRequest, error) {
+	geminiReq := &models.GeminiRequest{}
+
+	if req.System != nil {
+		systemContent, err := extractSystemContent(req.System)
+		if err != nil {
+			return nil, fmt.Errorf("extracting system content: %w", err)
+		}
+		if systemContent = o.filterIdentity(systemContent); systemContent != "" {
+			geminiReq.SystemInstruction = &models.GeminiContent{
+				Parts: []models.GeminiPart{{Text: systemContent}},
+			}
+		}
+	}
+
+	contents, err := transformMessagesToGemini(req.Messages, targetModel)
+	if err != nil {
+		return nil, fmt.Errorf("transforming messages: %w", err)
+	}
+	geminiReq.Contents = contents
+
+	caps, family := LookupCapabilities(targetModel)
+	if len(req.Tools) > 0 {
+		if caps.Tools {
+			geminiReq.Tools = transformToolsToGemini(req.Tools)
+		} else {
+			logging.LogDebugMessage("[REQUEST] Dropping %d tools: model %s (%s) does not support function calling", len(req.Tools), targetModel, family)
+		}
+	}
+	if choice := clientToolChoice(req.ToolChoice, req.Tools); choice != nil && len(geminiReq.Tools) > 0 {
+		geminiReq.ToolConfig = transformToolChoiceToGemini(choice)
+	}
+
+	genConfig := &models.GeminiGenerationConfig{
+		MaxOutputTokens: o.capMaxTokens(req.MaxTokens, targetModel),
+		Temperature:     req.Temperature,
+		TopP:            req.TopP,
+		TopK:            req.TopK,
+		StopSequences:   req.StopSequences,
+	}
+	if req.Thinking != nil && req.Thinking.BudgetTokens > 0 {
+		genConfig.ThinkingConfig = geminiThinkingConfig(req.Thinking.BudgetTokens, targetModel)
+	}
+	if format := o.requestedOutputFormat(req); format != nil {
+		genConfig.ResponseMimeType = "application/json"
+		if format.Type == "json_schema" {
+			genConfig.ResponseJSONSchema = format.Schema
+		}
+	}
+	geminiReq.GenerationConfig = genConfig
+
+	return geminiReq, nil
+}
+
+// transformMessagesToGemini converts Anthropic messages to Gemini contents.
+// Gemini identifies function responses by name rather than by call ID, so the
+// names of earlier tool_use blocks are tracked to label tool results.
+func transformMessagesToGemini(messages []models.AnthropicMessage, targetModel string) ([]models.GeminiContent, error) {
+	toolNames := make(map[string]string)
+	needsSignature := ProviderRequiresThoughtSignature(ProviderGemini, targetModel)
+
+	var contents []models.GeminiContent
+	for _, msg := range messages {
+		blocks, err := parseContent(msg.Content)
+		if err != nil {
+			return nil, err
+		}
+
+		var parts []models.GeminiPart
+		if msg.Role == "assistant" {
+			parts = transformAssistantPartsToGemini(blocks, toolNames, needsSignature)
+		} else {
+			parts, err = transformUserPartsToGemini(blocks, toolNames)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		// Gemini rejects contents without parts, e.g. a turn of only thinking
+		if len(parts) == 0 {
+			continue
+		}
+		role := "user"
+		if msg.Role == "assistant" {
+			role = "model"
+		}
+		contents = append(contents, models.GeminiContent{Role: role, Parts: parts})
+	}
+	return contents, nil
+}
+
+// transformAssistantPartsToGemini converts assistant content to model parts.
+// Thinking blocks are dropped; Gemini does not accept thoughts as input.
+func transformAssistantPartsToGemini(blocks []models.ContentBlock, toolNames map[string]string, needsSignature bool) []models.GeminiPart {
+	var parts []models.GeminiPart
+	for _, block := range blocks {
+		switch block.Type {
+		case "text":
+			if block.Text != "" {
+				parts = append(parts, models.GeminiPart{Text: block.Text})
+			}
+		case "tool_use":
+			toolNames[block.ID] = block.Name
+			part := models.GeminiPart{
+				FunctionCall: &models.GeminiFunctionCall{
+					Name: block.Name,
+					Args: toolInputToArgs(block.Input),
+				},
+			}
+			if needsSignature {
+				part.ThoughtSignature = geminiSkipThoughtSignature
+			}
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
+
+// transformUserPartsToGemini converts user content to user parts. Tool results
+// become function responses; images they contain follow as inline data.
+func transformUserPartsToGemini(blocks []models.ContentBlock, toolNames map[string]string) ([]models.GeminiPart, error) {
+	var parts []models.GeminiPart
+	for _, block := range blocks {
+		switch block.Type {
+		case "text":
+			if block.Text != "" {
+				parts = append(parts, models.GeminiPart{Text: block.Text})
+			}
+		case "image":
+			if block.Source != nil {
+				parts = append(parts, imageSourceToGeminiPart(block.Source))
+			}
+		case "document":
+			part, err := documentToGeminiPart(block)
+			if err != nil {
+				return nil, err
+			}
+			parts = append(parts, part)
+		case "tool_result":
+			// A result whose call was trimmed from the history keeps its ID as the name
+			name, ok := toolNames[block.ToolUseID]
+			if !ok {
+				name = block.ToolUseID
+			}
+			output := extractToolResultContentForChat(block)
+			response := map[string]interface{}{"content": output}
+			if block.IsError {
+				response = map[string]interface{}{"error": output}
+			}
+			parts = append(parts, models.GeminiPart{
+				FunctionResponse: &models.GeminiFunctionResponse{Name: name, Response: response},
+			})
+			for _, img := range extractToolResultImages(block) {
+				parts = append(parts, imageSourceToGeminiPart(img))
+			}
+		}
+	}
+	return parts, nil
+}
+
+// imageSourceToGeminiPart converts an Anthropic image source to inline data,
+// or to file data for URL sources.
+func imageSourceToGeminiPart(src *models.ImageSource) models.GeminiPart {
+	if src.Type == "url" && src.URL != "" {
+		return models.GeminiPart{FileData: &models.GeminiFileData{MimeType: src.MediaType, FileURI: src.URL}}
+	}
+	return models.GeminiPart{InlineData: &models.GeminiBlob{MimeType: src.MediaType, Data: src.Data}}
+}
+
+// maxGeminiInlineDocumentBytes is the largest decoded document sent to Gemini
+// as inline data. Gemini reads documents itself, so nothing is extracted.
+const maxGeminiInlineDocumentBytes = 32 * 1024 * 1024
+
+// documentToGeminiPart converts a document block. Gemini reads PDFs natively,
+// so base64 and URL documents are sent as they are and text documents as text.
+func documentToGeminiPart(block models.ContentBlock) (models.GeminiPart, error) {
+	if block.Source == nil {
+		return models.GeminiPart{}, &UnsupportedContentError{Provider: ProviderGemini, Reason: "document block has no source"}
+	}
+	if block.Source.Type == "text" {
+		return models.GeminiPart{Text: withDocumentTitle(block, block.Source.Data)}, nil
+	}
+	if block.Source.Type == "base64" && decodedDocumentSize(block.Source.Data) > maxGeminiInlineDocumentBytes {
+		return models.GeminiPart{}, &UnsupportedContentError{
+			Provider: ProviderGemini,
+			Reason:   fmt.Sprintf("document %q exceeds the %d MB inline limit", documentFilename(block), maxGeminiInlineDocumentBytes/(1024*1024)),
+		}
+	}
+	return imageSourceToGeminiPart(block.Source), nil
+}
+
+// toolInputToArgs converts tool_use input to function call arguments, which
+// Gemini requires to be an object.
+func toolInputToArgs(input interface{}) map[string]interface{} {
+	switch v := input.(type) {
+	case nil:
+		return map[string]interface{}{}
+	case map[string]interface{}:
+		return v
+	}
+	data, err := json.Marshal(input)
+	if err != nil {
+		return map[string]interface{}{}
+	}
+	var args map[string]interface{}
+	if err := json.Unmarshal(data, &args); err != nil || args == nil {
+		return map[string]interface{}{}
+	}
+	return args
+}
+
+// transformToolsToGemini converts Anthropic tools to function declarations.
+// Anthropic-only tool types (computer use, text editor, bash) have no input
+// schema Gemini can use and are skipped, as are Anthropic server tools.
+func transformToolsToGemini(tools []models.AnthropicTool) []models.GeminiTool {
+	var declarations []models.GeminiFunctionDeclaration
+	for _, tool := range clientTools(tools, nil) {
+		if tool.InputSchema == nil {
+			logging.LogDebugMessage("[REQUEST] Dropping tool %s: no input schema for Gemini", tool.Name)
+			continue
+		}
+		declarations = append(declarations, models.GeminiFunctionDeclaration{
+			Name:        tool.Name,
+			Description: tool.Description,
+			Parameters:  cleanupSchemaForGeminiNative(tool.InputSchema),
+		})
+	}
+	if len(declarations) == 0 {
+		return nil
+	}
+	return []models.GeminiTool{{FunctionDeclarations: declarations}}
+}
+
+// geminiUnsupportedSchemaKeys are JSON Schema keywords the Gemini function
+// declaration schema rejects.
+var geminiUnsupportedSchemaKeys = []string{"$schema", "$id", "$ref", "$defs", "definitions", "additionalProperties", "default", "examples", "const"}
+
+// cleanupSchemaForGeminiNative prepares a tool schema for Gemini function
+// declarations, which accept a subset of OpenAPI 3 schema.
+func cleanupSchemaForGeminiNative(schema interface{}) interface{} {
+	cleaned := cleanupSchemaForGemini(schema)
+	if schemaMap, ok := cleaned.(map[string]interface{}); ok {
+		stripGeminiSchemaKeys(schemaMap)
+	}
+	return cleaned
+}
+
+// stripGeminiSchemaKeys recursively removes unsupported keywords.
+func stripGeminiSchemaKeys(schema map[string]interface{}) {
+	for _, key := range geminiUnsupportedSchemaKeys {
+		delete(schema, key)
+	}
+	if props, ok := schema["properties"].(map[string]interface{}); ok {
+		for _, v := range props {
+			if propMap, ok := v.(map[string]interface{}); ok {
+				stripGeminiSchemaKeys(propMap)
+			}
+		}
+	}
+	if items, ok := schema["items"].(map[string]interface{}); ok {
+		stripGeminiSchemaKeys(items)
+	}
+	for _, key := range []string{"anyOf", "oneOf", "allOf"} {
+		if variants, ok := schema[key].([]interface{}); ok {
+			for _, v := range variants {
+				if variant, ok := v.(map[string]interface{}); ok {
+					stripGeminiSchemaKeys(variant)
+				}
+			}
+		}
+	}
+}
+
+// transformToolChoiceToGemini converts an Anthropic tool_choice to a
+// function calling config.
+func transformToolChoiceToGemini(choice interface{}) *models.GeminiToolConfig {
+	choiceMap, ok := choice.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	config := &models.GeminiFunctionCallingConfig{}
+	switch choiceMap["type"] {
+	case "auto":
+		config.Mode = "AUTO"
+	case "any":
+		config.Mode = "ANY"
+	case "none":
+		config.Mode = "NONE"
+	case "tool":
+		name, _ := choiceMap["name"].(string)
+		if name == "" {
+			return nil
+		}
+		config.Mode = "ANY"
+		config.AllowedFunctionNames = []string{name}
+	default:
+		return nil
+	}
+	return &models.GeminiToolConfig{FunctionCallingConfig: config}
+}
+
+// geminiThinkingConfig maps an Anthropic thinking budget to Gemini's thinking
+// config: a level for Gemini 3 and a capped budget for Gemini 2.5. Other
+// models do not think and get none.
+func geminiThinkingConfig(budgetTokens int, targetModel string) *models.GeminiThinkingConfig {
+	switch {
+	case isGemini3Model(targetModel):
+		level := "low"
+		if budgetTokens >= 16000 {
+			level = "high"
+		}
+		return &models.GeminiThinkingConfig{ThinkingLevel: level, IncludeThoughts: true}
+	case isGemini25Model(targetModel):
+		budget := budgetTokens
+		if budget > geminiMaxThinkingBudget {
+			budget = geminiMaxThinkingBudget
+		}
+		return &models.GeminiThinkingConfig{ThinkingBudget: &budget, IncludeThoughts: true}
+	default:
+		logging.LogDebugMessage("[REQUEST] Dropping thinking: model %s does not support thinking", targetModel)
+		return nil
+	}
+}
@@ -0,0 +1,292 @@
+package translator
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/jedarden/clasp/pkg/models"
+)
+
+func TestTransformRequestToGemini_ToolCall(t *testing.T) {
+	temp := 0.2
+	req := &models.AnthropicRequest{
+		Model:         "claude-3-5-sonnet-20241022",
+		System:        "You are a weather assistant.",
+		MaxTokens:     1024,
+		Temperature:   &temp,
+		StopSequences: []string{"END"},
+		Messages: []models.AnthropicMessage{
+			{Role: "user", Content: "What's the weather in Paris?"},
+		},
+		Tools: []models.AnthropicTool{
+			{
+				Name:        "get_weather",
+				Description: "Get the current weather",
+				InputSchema: map[string]interface{}{
+					"$schema":              "http://json-schema.org/draft-07/schema#",
+					"type":                 "object",
+					"additionalProperties": false,
+					"properties": map[string]interface{}{
+						"city": map[string]interface{}{"type": "string", "description": "City name"},
+					},
+					"required": []interface{}{"city"},
+				},
+			},
+		},
+		ToolChoice: map[string]interface{}{"type": "tool", "name": "get_weather"},
+	}
+
+	result, err := TransformRequestToGemini(req, "gemini-2.5-flash")
+	if err != nil {
+		t.Fatalf("TransformRequestToGemini failed: %v", err)
+	}
+
+	if result.SystemInstruction == nil || !strings.HasSuffix(result.SystemInstruction.Parts[0].Text, "You are a weather assistant.") {
+		t.Errorf("SystemInstruction = %+v, want the system prompt", result.SystemInstruction)
+	}
+	if len(result.Contents) != 1 || result.Contents[0].Role != "user" || result.Contents[0].Parts[0].Text != "What's the weather in Paris?" {
+		t.Errorf("Contents = %+v, want a single user turn", result.Contents)
+	}
+
+	if len(result.Tools) != 1 || len(result.Tools[0].FunctionDeclarations) != 1 {
+		t.Fatalf("Tools = %+v, want one function declaration", result.Tools)
+	}
+	decl := result.Tools[0].FunctionDeclarations[0]
+	if decl.Name != "get_weather" || decl.Description != "Get the current weather" {
+		t.Errorf("Declaration = %+v", decl)
+	}
+	params := decl.Parameters.(map[string]interface{})
+	for _, key := range []string{"$schema", "additionalProperties"} {
+		if _, ok := params[key]; ok {
+			t.Errorf("Parameters should not contain %s", key)
+		}
+	}
+	if _, ok := params["properties"].(map[string]interface{})["city"]; !ok {
+		t.Errorf("Parameters lost the city property: %v", params)
+	}
+
+	fc := result.ToolConfig.FunctionCallingConfig
+	if fc.Mode != "ANY" || len(fc.AllowedFunctionNames) != 1 || fc.AllowedFunctionNames[0] != "get_weather" {
+		t.Errorf("FunctionCallingConfig = %+v, want ANY restricted to get_weather", fc)
+	}
+
+	gen := result.GenerationConfig
+	if gen.MaxOutputTokens != 1024 || *gen.Temperature != 0.2 || gen.StopSequences[0] != "END" {
+		t.Errorf("GenerationConfig = %+v", gen)
+	}
+}
+
+func TestTransformRequestToGemini_MultiTurn(t *testing.T) {
+	req := &models.AnthropicRequest{
+		Model:     "claude-3-5-sonnet-20241022",
+		MaxTokens: 1024,
+		Messages: []models.AnthropicMessage{
+			{Role: "user", Content: "List the files"},
+			{Role: "assistant", Content: []interface{}{
+				map[string]interface{}{"type": "thinking", "thinking": "I should call ls"},
+				map[string]interface{}{"type": "text", "text": "Let me check."},
+				map[string]interface{}{"type": "tool_use", "id": "toolu_01", "name": "Bash", "input": map[string]interface{}{"command": "ls"}},
+			}},
+			{Role: "user", Content: []interface{}{
+				map[string]interface{}{"type": "tool_result", "tool_use_id": "toolu_01", "content": "main.go\nREADME.md"},
+			}},
+			{Role: "assistant", Content: "There are two files."},
+			{Role: "user", Content: []interface{}{
+				map[string]interface{}{"type": "text", "text": "Delete README.md"},
+				map[string]interface{}{"type": "image", "source": map[string]interface{}{"type": "base64", "media_type": "image/png", "data": "iVBORw0KGgo="}},
+			}},
+		},
+	}
+
+	result, err := TransformRequestToGemini(req, "gemini-2.5-pro")
+	if err != nil {
+		t.Fatalf("TransformRequestToGemini failed: %v", err)
+	}
+
+	wantRoles := []string{"user", "model", "user", "model", "user"}
+	if len(result.Contents) != len(wantRoles) {
+		t.Fatalf("got %d contents, want %d: %+v", len(result.Contents), len(wantRoles), result.Contents)
+	}
+	for i, role := range wantRoles {
+		if result.Contents[i].Role != role {
+			t.Errorf("Contents[%d].Role = %q, want %q", i, result.Contents[i].Role, role)
+		}
+	}
+
+	// The thinking block is dropped; text and the function call remain
+	modelParts := result.Contents[1].Parts
+	if len(modelParts) != 2 || modelParts[0].Text != "Let me check." {
+		t.Fatalf("model parts = %+v, want text and a function call", modelParts)
+	}
+	call := modelParts[1].FunctionCall
+	if call == nil || call.Name != "Bash" || call.Args["command"] != "ls" {
+		t.Errorf("FunctionCall = %+v, want Bash with command ls", call)
+	}
+	if modelParts[1].ThoughtSignature != "" {
+		t.Error("Gemini 2.5 function calls should not carry a thought signature")
+	}
+
+	// The tool result is labeled with the name of the call it answers
+	response := result.Contents[2].Parts[0].FunctionResponse
+	if response == nil || response.Name != "Bash" || response.Response["content"] != "main.go\nREADME.md" {
+		t.Errorf("FunctionResponse = %+v, want the Bash output", response)
+	}
+
+	last := result.Contents[4].Parts
+	if len(last) != 2 || last[1].InlineData == nil || last[1].InlineData.MimeType != "image/png" {
+		t.Errorf("last user parts = %+v, want text and an inline image", last)
+	}
+}
+
+func TestTransformRequestToGemini_ToolResultError(t *testing.T) {
+	req := &models.AnthropicRequest{
+		Model: "claude-3-5-sonnet-20241022",
+		Messages: []models.AnthropicMessage{
+			{Role: "assistant", Content: []interface{}{
+				map[string]interface{}{"type": "tool_use", "id": "toolu_01", "name": "Read", "input": map[string]interface{}{}},
+			}},
+			{Role: "user", Content: []interface{}{
+				map[string]interface{}{"type": "tool_result", "tool_use_id": "toolu_01", "content": "file not found", "is_error": true},
+			}},
+		},
+	}
+
+	result, err := TransformRequestToGemini(req, "gemini-3-pro-preview")
+	if err != nil {
+		t.Fatalf("TransformRequestToGemini failed: %v", err)
+	}
+	if got := result.Contents[0].Parts[0].ThoughtSignature; got != geminiSkipThoughtSignature {
+		t.Errorf("ThoughtSignature = %q, want the skip placeholder for Gemini 3", got)
+	}
+	response := result.Contents[1].Parts[0].FunctionResponse
+	if response.Response["error"] != "file not found" {
+		t.Errorf("Response = %v, want the error output", response.Response)
+	}
+}
+
+func TestTransformRequestToGemini_Thinking(t *testing.T) {
+	tests := []struct {
+		model      string
+		budget     int
+		wantBudget int
+		wantLevel  string
+	}{
+		{"gemini-2.5-pro", 8000, 8000, ""},
+		{"gemini-2.5-flash", 50000, geminiMaxThinkingBudget, ""},
+		{"gemini-3-pro-preview", 20000, 0, "high"},
+		{"gemini-3-pro-preview", 4000, 0, "low"},
+	}
+
+	for _, tt := range tests {
+		req := &models.AnthropicRequest{
+			Messages: []models.AnthropicMessage{{Role: "user", Content: "Think"}},
+			Thinking: &models.ThinkingConfig{Type: "enabled", BudgetTokens: tt.budget},
+		}
+		result, err := TransformRequestToGemini(req, tt.model)
+		if err != nil {
+			t.Fatalf("TransformRequestToGemini failed: %v", err)
+		}
+		tc := result.GenerationConfig.ThinkingConfig
+		if tc == nil || !tc.IncludeThoughts {
+			t.Fatalf("%s: ThinkingConfig = %+v, want thoughts included", tt.model, tc)
+		}
+		if tt.wantLevel != "" && tc.ThinkingLevel != tt.wantLevel {
+			t.Errorf("%s budget %d: ThinkingLevel = %q, want %q", tt.model, tt.budget, tc.ThinkingLevel, tt.wantLevel)
+		}
+		if tt.wantBudget != 0 && (tc.ThinkingBudget == nil || *tc.ThinkingBudget != tt.wantBudget) {
+			t.Errorf("%s budget %d: ThinkingBudget = %v, want %d", tt.model, tt.budget, tc.ThinkingBudget, tt.wantBudget)
+		}
+	}
+}
+
+func TestTransformGeminiResponse(t *testing.T) {
+	var resp models.GeminiResponse
+	body := `{
+		"candidates": [{
+			"content": {"role": "model", "parts": [
+				{"text": "Checking the weather.", "thought": true},
+				{"text": "Let me look that up."},
+				{"functionCall": {"name": "get_weather", "args": {"city": "Paris"}}}
+			]},
+			"finishReason": "STOP"
+		}],
+		"usageMetadata": {"promptTokenCount": 20, "candidatesTokenCount": 12, "thoughtsTokenCount": 8, "totalTokenCount": 40}
+	}`
+	if err := json.Unmarshal([]byte(body), &resp); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+
+	result := TransformGeminiResponse(&resp, "msg_1", "gemini-2.5-flash")
+	if result.StopReason != "tool_use" {
+		t.Errorf("StopReason = %q, want tool_use for a function call finished with STOP", result.StopReason)
+	}
+	if len(result.Content) != 3 {
+		t.Fatalf("got %d blocks, want 3: %+v", len(result.Content), result.Content)
+	}
+	if result.Content[0].Type != "thinking" || result.Content[0].Thinking != "Checking the weather." {
+		t.Errorf("Content[0] = %+v, want the thought as thinking", result.Content[0])
+	}
+	if result.Content[1].Type != "text" {
+		t.Errorf("Content[1] = %+v, want text", result.Content[1])
+	}
+	tool := result.Content[2]
+	if tool.Type != "tool_use" || tool.Name != "get_weather" || !strings.HasPrefix(tool.ID, "toolu_") {
+		t.Errorf("Content[2] = %+v, want a get_weather tool_use", tool)
+	}
+	if result.Usage.InputTokens != 20 || result.Usage.OutputTokens != 20 {
+		t.Errorf("Usage = %+v, want 20 input and 20 output tokens (thoughts included)", result.Usage)
+	}
+}
+
+func TestGeminiUsage_CachedContent(t *testing.T) {
+	usage := geminiUsage(&models.GeminiUsageMetadata{
+		PromptTokenCount:        5000,
+		CandidatesTokenCount:    40,
+		CachedContentTokenCount: 4096,
+	})
+	want := models.AnthropicUsage{InputTokens: 904, OutputTokens: 40, CacheReadInputTokens: 4096}
+	if *usage != want {
+		t.Errorf("usage = %+v, want %+v", *usage, want)
+	}
+}
+
+func TestGeminiStreamProcessor(t *testing.T) {
+	stream := strings.Join([]string{
+		`data: {"candidates":[{"content":{"role":"model","parts":[{"text":"Planning","thought":true}]}}],"usageMetadata":{"promptTokenCount":15}}`,
+		`data: {"candidates":[{"content":{"role":"model","parts":[{"text":"Sure, "}]}}]}`,
+		`data: {"candidates":[{"content":{"role":"model","parts":[{"text":"checking."},{"functionCall":{"name":"get_weather","args":{"city":"Paris"}}}]},"finishReason":"STOP"}],"usageMetadata":{"promptTokenCount":15,"candidatesTokenCount":9,"thoughtsTokenCount":3}}`,
+	}, "\n\n") + "\n\n"
+
+	var out bytes.Buffer
+	processor := NewGeminiStreamProcessor(&out, "msg_1", "gemini-2.5-flash")
+	processor.EnableResponseCapture()
+	var gotInput, gotOutput int
+	processor.SetUsageCallback(func(inputTokens, outputTokens int) {
+		gotInput, gotOutput = inputTokens, outputTokens
+	})
+	if err := processor.ProcessStream(strings.NewReader(stream)); err != nil {
+		t.Fatalf("ProcessStream failed: %v", err)
+	}
+
+	resp := processor.CapturedResponse()
+	if resp.StopReason != "tool_use" {
+		t.Errorf("StopReason = %q, want tool_use", resp.StopReason)
+	}
+	if len(resp.Content) != 3 {
+		t.Fatalf("got %d blocks, want thinking, text and tool_use: %+v", len(resp.Content), resp.Content)
+	}
+	if resp.Content[0].Thinking != "Planning" || resp.Content[1].Text != "Sure, checking." {
+		t.Errorf("Content = %+v", resp.Content)
+	}
+	if resp.Content[2].Name != "get_weather" {
+		t.Errorf("Content[2] = %+v, want the get_weather call", resp.Content[2])
+	}
+	if gotInput != 15 || gotOutput != 12 {
+		t.Errorf("usage = %d/%d, want 15/12", gotInput, gotOutput)
+	}
+	if !strings.Contains(out.String(), `"partial_json":"{\"city\":\"Paris\"}"`) {
+		t.Errorf("Expected the call arguments as one input_json_delta, got:\n%s", out.String())
+	}
+}
@@ -0,0 +1,110 @@
+// Package translator handles protocol translation between Anthropic and OpenAI formats.
+package translator
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/jedarden/clasp/pkg/models"
+)
+
+// TransformGeminiResponse converts a Gemini generateContent response to an
+// Anthropic response with the default options.
+func TransformGeminiResponse(resp *models.GeminiResponse, messageID, targetModel string) *models.AnthropicResponse {
+	return defaultOptions.TransformGeminiResponse(resp, messageID, targetModel)
+}
+
+// TransformGeminiResponse converts a Gemini generateContent response to an
+// Anthropic response. Thought parts become thinking blocks and function calls
+// become tool_use blocks.
+func (o *Options) TransformGeminiResponse(resp *models.GeminiResponse, messageID, targetModel string) *models.AnthropicResponse {
+	anthropicResp := &models.AnthropicResponse{
+		ID:      messageID,
+		Type:    "message",
+		Role:    "assistant",
+		Model:   targetModel,
+		Content: []models.AnthropicContentBlock{},
+		Usage:   geminiUsage(resp.UsageMetadata),
+	}
+
+	if len(resp.Candidates) == 0 {
+		// The prompt was blocked before any candidate was generated
+		reason := ""
+		if resp.PromptFeedback != nil {
+			reason = resp.PromptFeedback.BlockReason
+		}
+		anthropicResp.StopReason = o.mapFinishReason(reason)
+		if IsContentFilterReason(reason) {
+			anthropicResp.Content = append(anthropicResp.Content, RefusalBlock(reason))
+		}
+		return anthropicResp
+	}
+
+	candidate := resp.Candidates[0]
+	hasToolCalls := false
+	for _, part := range candidate.Content.Parts {
+		switch {
+		case part.FunctionCall != nil:
+			hasToolCalls = true
+			anthropicResp.Content = append(anthropicResp.Content, models.AnthropicContentBlock{
+				Type:  "tool_use",
+				ID:    geminiToolCallID(part.FunctionCall.ID),
+				Name:  part.FunctionCall.Name,
+				Input: toolInputToArgs(part.FunctionCall.Args),
+			})
+		case part.Thought:
+			if part.Text != "" {
+				anthropicResp.Content = append(anthropicResp.Content, models.AnthropicContentBlock{
+					Type:     "thinking",
+					Thinking: part.Text,
+				})
+			}
+		case part.Text != "":
+			anthropicResp.Content = append(anthropicResp.Content, models.AnthropicContentBlock{
+				Type: "text",
+				Text: part.Text,
+			})
+		}
+	}
+	anthropicResp.StopReason = o.mapGeminiFinishReason(candidate.FinishReason, hasToolCalls)
+	if IsContentFilterReason(candidate.FinishReason) {
+		anthropicResp.Content = append(anthropicResp.Content, RefusalBlock(candidate.FinishReason))
+	}
+
+	return anthropicResp
+}
+
+// mapGeminiFinishReason maps a Gemini finishReason to an Anthropic
+// stop_reason. Gemini finishes function calls with STOP.
+func (o *Options) mapGeminiFinishReason(reason string, hasToolCalls bool) string {
+	stopReason := o.mapFinishReason(reason)
+	if stopReason == "end_turn" && hasToolCalls {
+		return "tool_use"
+	}
+	return stopReason
+}
+
+// geminiUsage converts Gemini usage metadata. Thinking tokens are billed as
+// output, so they count toward the output tokens. Cached content tokens are
+// part of Gemini's prompt count and become cache reads.
+func geminiUsage(usage *models.GeminiUsageMetadata) *models.AnthropicUsage {
+	if usage == nil {
+		return &models.AnthropicUsage{}
+	}
+	return &models.AnthropicUsage{
+		InputTokens:          usage.PromptTokenCount - usage.CachedContentTokenCount,
+		OutputTokens:         usage.CandidatesTokenCount + usage.ThoughtsTokenCount,
+		CacheReadInputTokens: usage.CachedContentTokenCount,
+	}
+}
+
+// geminiToolCallID returns an Anthropic tool_use ID for a Gemini function
+// call. Gemini usually sends no call ID, so one is generated.
+func geminiToolCallID(id string) string {
+	if id != "" {
+		return "toolu_" + id
+	}
+	b := make([]byte, 12)
+	_, _ = rand.Read(b)
+	return "toolu_" + hex.EncodeToString(b)
+}
@@ -0,0 +1,413 @@
+// Package translator handles protocol translation between Anthropic and OpenAI formats.
+package translator
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/jedarden/clasp/internal/logging"
+	"github.com/jedarden/clasp/pkg/models"
+)
+
+// GeminiStreamProcessor handles the transformation of Gemini
+// streamGenerateContent SSE streams (alt=sse) to Anthropic format. Each chunk
+// is a complete GenerateContentResponse holding the next parts.
+type GeminiStreamProcessor struct {
+	mu sync.Mutex
+
+	opts *Options
+
+	messageID   string
+	targetModel string
+	started     bool
+
+	// Open content block: "text", "thinking" or "" when none is open
+	openBlockType string
+	blockIndex    int
+
+	hasToolCalls bool
+	finishReason string
+	usage        *models.GeminiUsageMetadata
+
+	usageCallback        UsageCallback
+	usageDetailsCallback UsageDetailsCallback
+
+	// The terminating events were written (see Terminate)
+	finished bool
+
+	// Output
+	writer io.Writer
+
+	// Reconstructed response for debug logging (nil unless enabled)
+	capture *responseCapture
+}
+
+// NewGeminiStreamProcessor creates a new stream processor for the Gemini API
+// with the default options.
+func NewGeminiStreamProcessor(writer io.Writer, messageID, targetModel string) *GeminiStreamProcessor {
+	return defaultOptions.NewGeminiStreamProcessor(writer, messageID, targetModel)
+}
+
+// NewGeminiStreamProcessor creates a new stream processor for the Gemini API
+// that translates with o.
+func (o *Options) NewGeminiStreamProcessor(writer io.Writer, messageID, targetModel string) *GeminiStreamProcessor {
+	return &GeminiStreamProcessor{
+		opts:        o,
+		writer:      writer,
+		messageID:   messageID,
+		targetModel: targetModel,
+	}
+}
+
+// SetUsageCallback sets the callback function for usage reporting.
+func (sp *GeminiStreamProcessor) SetUsageCallback(callback UsageCallback) {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	sp.usageCallback = callback
+}
+
+// SetUsageDetailsCallback sets a callback that receives the final usage in
+// Anthropic format, with cached content tokens separated from the input tokens.
+func (sp *GeminiStreamProcessor) SetUsageDetailsCallback(callback UsageDetailsCallback) {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	sp.usageDetailsCallback = callback
+}
+
+// GetUsage returns the final usage statistics from the stream.
+func (sp *GeminiStreamProcessor) GetUsage() (inputTokens, outputTokens int) {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	usage := geminiUsage(sp.usage)
+	return usage.InputTokens, usage.OutputTokens
+}
+
+// FinishReasonReceived reports whether the upstream sent a finishReason or
+// block reason. Without one the stream was cut short, even though it still
+// ends with a stop_reason. This should be called after ProcessStream completes.
+func (sp *GeminiStreamProcessor) FinishReasonReceived() bool {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	return sp.finishReason != ""
+}
+
+// EnableResponseCapture makes the processor reconstruct the final Anthropic
+// response from the events it emits. Call before ProcessStream.
+func (sp *GeminiStreamProcessor) EnableResponseCapture() {
+	sp.capture = newResponseCapture()
+}
+
+// CapturedResponse returns the reconstructed Anthropic response, or nil if
+// capture was not enabled. This should be called after ProcessStream completes.
+func (sp *GeminiStreamProcessor) CapturedResponse() *models.AnthropicResponse {
+	if sp.capture == nil {
+		return nil
+	}
+	return sp.capture.response()
+}
+
+// ProcessStream reads a Gemini SSE stream and writes Anthropic SSE events.
+func (sp *GeminiStreamProcessor) ProcessStream(reader io.Reader) error {
+	scanner := bufio.NewScanner(reader)
+	buf := make([]byte, 0, 64*1024)
+	scanner.Buffer(buf, 1024*1024)
+
+	logging.LogDebugMessage("[STREAM] Starting Gemini SSE stream processing for model: %s", sp.targetModel)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+		data := strings.TrimPrefix(line, "data: ")
+
+		// Debug log incoming Gemini SSE chunk
+		logging.LogDebugSSE("INCOMING Gemini", "chunk", data)
+
+		var chunk models.GeminiResponse
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			logging.LogDebugMessage("[STREAM] Error parsing Gemini chunk: %v", err)
+			continue
+		}
+
+		if err := sp.processChunk(&chunk); err != nil {
+			return fmt.Errorf("processing chunk: %w", err)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("scanning stream: %w", err)
+	}
+
+	return sp.finalize()
+}
+
+// processChunk handles a single streamed GenerateContentResponse.
+func (sp *GeminiStreamProcessor) processChunk(chunk *models.GeminiResponse) error {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+
+	// Usage metadata is cumulative; the last chunk holds the totals
+	if chunk.UsageMetadata != nil {
+		sp.usage = chunk.UsageMetadata
+	}
+
+	if !sp.started {
+		if err := sp.emitMessageStart(); err != nil {
+			return err
+		}
+		sp.started = true
+	}
+
+	if len(chunk.Candidates) == 0 {
+		if chunk.PromptFeedback != nil && chunk.PromptFeedback.BlockReason != "" {
+			sp.finishReason = chunk.PromptFeedback.BlockReason
+		}
+		return nil
+	}
+
+	candidate := chunk.Candidates[0]
+	for _, part := range candidate.Content.Parts {
+		var err error
+		switch {
+		case part.FunctionCall != nil:
+			err = sp.emitToolUse(part.FunctionCall)
+		case part.Thought:
+			err = sp.emitText("thinking", part.Text)
+		default:
+			err = sp.emitText("text", part.Text)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if candidate.FinishReason != "" {
+		sp.finishReason = candidate.FinishReason
+	}
+	return nil
+}
+
+// emitText appends text to the open block of blockType ("text" or
+// "thinking"), starting a new block when another kind is open.
+func (sp *GeminiStreamProcessor) emitText(blockType, text string) error {
+	if text == "" {
+		return nil
+	}
+
+	if sp.openBlockType != blockType {
+		if err := sp.closeBlock(); err != nil {
+			return err
+		}
+		start := models.ContentBlockStartData{Type: blockType}
+		if err := sp.writeEvent(models.EventContentBlockStart, models.ContentBlockStartEvent{
+			Type:         models.EventContentBlockStart,
+			Index:        sp.blockIndex,
+			ContentBlock: start,
+		}); err != nil {
+			return err
+		}
+		sp.openBlockType = blockType
+	}
+
+	delta := models.DeltaData{Type: "text_delta", Text: text}
+	if blockType == "thinking" {
+		delta = models.DeltaData{Type: "thinking_delta", Thinking: text}
+	}
+	return sp.writeEvent(models.EventContentBlockDelta, models.ContentBlockDeltaEvent{
+		Type:  models.EventContentBlockDelta,
+		Index: sp.blockIndex,
+		Delta: delta,
+	})
+}
+
+// emitToolUse emits a complete tool_use block. Gemini streams each function
+// call whole, so its arguments are sent as a single input_json_delta.
+func (sp *GeminiStreamProcessor) emitToolUse(call *models.GeminiFunctionCall) error {
+	if err := sp.closeBlock(); err != nil {
+		return err
+	}
+	sp.hasToolCalls = true
+
+	args, err := json.Marshal(toolInputToArgs(call.Args))
+	if err != nil {
+		return fmt.Errorf("marshaling function call args: %w", err)
+	}
+
+	if err := sp.writeEvent(models.EventContentBlockStart, models.ContentBlockStartEvent{
+		Type:  models.EventContentBlockStart,
+		Index: sp.blockIndex,
+		ContentBlock: models.ContentBlockStartData{
+			Type: "tool_use",
+			ID:   geminiToolCallID(call.ID),
+			Name: call.Name,
+		},
+	}); err != nil {
+		return err
+	}
+	if err := sp.writeEvent(models.EventContentBlockDelta, models.ContentBlockDeltaEvent{
+		Type:  models.EventContentBlockDelta,
+		Index: sp.blockIndex,
+		Delta: models.DeltaData{Type: "input_json_delta", PartialJSON: string(args)},
+	}); err != nil {
+		return err
+	}
+	if err := sp.writeEvent(models.EventContentBlockStop, models.ContentBlockStopEvent{
+		Type:  models.EventContentBlockStop,
+		Index: sp.blockIndex,
+	}); err != nil {
+		return err
+	}
+	sp.blockIndex++
+	return nil
+}
+
+// closeBlock stops the open text or thinking block, if any.
+func (sp *GeminiStreamProcessor) closeBlock() error {
+	if sp.openBlockType == "" {
+		return nil
+	}
+	if err := sp.writeEvent(models.EventContentBlockStop, models.ContentBlockStopEvent{
+		Type:  models.EventContentBlockStop,
+		Index: sp.blockIndex,
+	}); err != nil {
+		return err
+	}
+	sp.openBlockType = ""
+	sp.blockIndex++
+	return nil
+}
+
+// Terminate completes the Anthropic stream after ProcessStream failed
+// mid-stream, so the client receives message_delta, message_stop and [DONE].
+// It does nothing if the stream was already completed.
+func (sp *GeminiStreamProcessor) Terminate() error {
+	sp.mu.Lock()
+	finished := sp.finished
+	sp.mu.Unlock()
+	if finished {
+		return nil
+	}
+	return sp.finalize()
+}
+
+// finalize completes the stream processing.
+func (sp *GeminiStreamProcessor) finalize() error {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	sp.finished = true
+
+	if !sp.started {
+		if err := sp.emitMessageStart(); err != nil {
+			return err
+		}
+		sp.started = true
+	}
+	if err := sp.closeBlock(); err != nil {
+		return err
+	}
+
+	// Explain content filter stops with a refusal block
+	if IsContentFilterReason(sp.finishReason) {
+		block := RefusalBlock(sp.finishReason)
+		if err := sp.writeEvent(models.EventContentBlockStart, models.ContentBlockStartEvent{
+			Type:         models.EventContentBlockStart,
+			Index:        sp.blockIndex,
+			ContentBlock: models.ContentBlockStartData{Type: block.Type, Text: block.Text},
+		}); err != nil {
+			return err
+		}
+		if err := sp.writeEvent(models.EventContentBlockStop, models.ContentBlockStopEvent{
+			Type:  models.EventContentBlockStop,
+			Index: sp.blockIndex,
+		}); err != nil {
+			return err
+		}
+		sp.blockIndex++
+	}
+
+	usage := geminiUsage(sp.usage)
+	if sp.usageCallback != nil && sp.usage != nil {
+		sp.usageCallback(usage.InputTokens, usage.OutputTokens)
+	}
+	if sp.usageDetailsCallback != nil && sp.usage != nil {
+		sp.usageDetailsCallback(usage)
+	}
+
+	if err := sp.writeEvent(models.EventMessageDelta, models.MessageDeltaEvent{
+		Type: models.EventMessageDelta,
+		Delta: models.MessageDeltaData{
+			StopReason: sp.opts.mapGeminiFinishReason(sp.finishReason, sp.hasToolCalls),
+		},
+		Usage: &models.MessageDeltaUsage{
+			OutputTokens:         usage.OutputTokens,
+			CacheReadInputTokens: usage.CacheReadInputTokens,
+		},
+	}); err != nil {
+		return err
+	}
+
+	if err := sp.writeEvent(models.EventMessageStop, models.MessageStopEvent{Type: models.EventMessageStop}); err != nil {
+		return err
+	}
+
+	// Emit [DONE]
+	return sp.writeSSE("", "[DONE]")
+}
+
+// emitMessageStart emits message_start and ping events.
+func (sp *GeminiStreamProcessor) emitMessageStart() error {
+	event := models.MessageStartEvent{
+		Type: models.EventMessageStart,
+		Message: models.AnthropicResponse{
+			ID:      sp.messageID,
+			Type:    "message",
+			Role:    "assistant",
+			Content: []models.AnthropicContentBlock{},
+			Model:   sp.targetModel,
+			Usage: &models.AnthropicUsage{
+				InputTokens:  geminiUsage(sp.usage).InputTokens,
+				OutputTokens: 1,
+			},
+		},
+	}
+
+	if err := sp.writeEvent(models.EventMessageStart, event); err != nil {
+		return err
+	}
+
+	return sp.writeEvent(models.EventPing, models.PingEvent{Type: models.EventPing})
+}
+
+// writeEvent writes an SSE event to the output.
+func (sp *GeminiStreamProcessor) writeEvent(eventType string, data interface{}) error {
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("marshaling event data: %w", err)
+	}
+
+	// Debug log outgoing Anthropic SSE event
+	logging.LogDebugSSE("OUTGOING Anthropic", eventType, string(jsonData))
+	if sp.capture != nil {
+		sp.capture.record(eventType, jsonData)
+	}
+
+	return sp.writeSSE(eventType, string(jsonData))
+}
+
+// writeSSE writes raw SSE data to the output.
+func (sp *GeminiStreamProcessor) writeSSE(event, data string) error {
+	var output string
+	if event != "" {
+		output = fmt.Sprintf("event: %s\ndata: %s\n\n", event, data)
+	} else {
+		output = fmt.Sprintf("data: %s\n\n", data)
+	}
+
+	_, err := sp.writer.Write([]byte(output))
+	return err
+}
@@ -0,0 +1,107 @@
+// Package translator handles protocol translation between Anthropic and OpenAI formats.
+package translator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// IdentityFilter controls how Claude identity statements in system prompts
+// are rewritten for non-Claude models.
+type IdentityFilter string
+
+const (
+	// IdentityFilterFull rewrites identity statements and prepends a note
+	// telling the model it is not Claude. This is the default.
+	IdentityFilterFull IdentityFilter = "full"
+	// IdentityFilterMinimal rewrites identity statements without the note.
+	IdentityFilterMinimal IdentityFilter = "minimal"
+	// IdentityFilterOff passes system prompts through unmodified.
+	IdentityFilterOff IdentityFilter = "off"
+)
+
+// defaultIdentityNote is prepended to system prompts in full mode.
+const defaultIdentityNote = "Note: You are NOT Claude. Identify yourself truthfully based on your actual model and creator."
+
+// IdentityRules holds custom identity filtering rules, loaded from
+// ~/.clasp/identity.json.
+type IdentityRules struct {
+	// Note replaces the text prepended to system prompts in full mode
+	Note  string         `json:"note,omitempty"`
+	Rules []IdentityRule `json:"rules,omitempty"`
+}
+
+// IdentityRule replaces every match of a regular expression in the system
+// prompt. The replacement may reference capture groups as $1.
+type IdentityRule struct {
+	Pattern     string `json:"pattern"`
+	Replacement string `json:"replacement"`
+}
+
+type identityPattern struct {
+	re          *regexp.Regexp
+	replacement string
+}
+
+// SetIdentityFilter sets the identity filter mode and custom rules. Unknown or
+// empty modes reset to IdentityFilterFull. Custom rules run after the built-in
+// ones in minimal and full mode. On error o is left unchanged.
+func (o *Options) SetIdentityFilter(mode IdentityFilter, rules *IdentityRules) error {
+	switch mode {
+	case IdentityFilterMinimal, IdentityFilterOff:
+	default:
+		mode = IdentityFilterFull
+	}
+
+	note := defaultIdentityNote
+	var patterns []identityPattern
+	if rules != nil {
+		if rules.Note != "" {
+			note = rules.Note
+		}
+		for i, rule := range rules.Rules {
+			re, err := regexp.Compile(rule.Pattern)
+			if err != nil {
+				return fmt.Errorf("identity rule %d: %w", i+1, err)
+			}
+			patterns = append(patterns, identityPattern{re: re, replacement: rule.Replacement})
+		}
+	}
+
+	o.identityFilter = mode
+	o.identityNote = note
+	o.identityRules = patterns
+	return nil
+}
+
+// DefaultIdentityRulesPath returns the default identity rules file,
+// ~/.clasp/identity.json.
+func DefaultIdentityRulesPath() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("getting home directory: %w", err)
+	}
+	return filepath.Join(homeDir, ".clasp", "identity.json"), nil
+}
+
+// LoadIdentityRules reads custom identity rules from path. A missing file is
+// not an error and returns nil rules.
+func LoadIdentityRules(path string) (*IdentityRules, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+
+	var rules IdentityRules
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return &rules, nil
+}
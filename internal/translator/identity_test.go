@@ -0,0 +1,119 @@
+package translator
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFilterIdentity_Modes(t *testing.T) {
+	input := "You are Claude, a roleplay partner."
+
+	tests := []struct {
+		mode       IdentityFilter
+		want       string
+		wantPrefix bool
+	}{
+		{IdentityFilterFull, "You are an AI assistant, a roleplay partner.", true},
+		{IdentityFilterMinimal, "You are an AI assistant, a roleplay partner.", false},
+		{IdentityFilterOff, input, false},
+		{"", "You are an AI assistant, a roleplay partner.", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			opts := DefaultOptions()
+			if err := opts.SetIdentityFilter(tt.mode, nil); err != nil {
+				t.Fatalf("SetIdentityFilter failed: %v", err)
+			}
+			result := opts.filterIdentity(input)
+
+			if hasPrefix := strings.HasPrefix(result, defaultIdentityNote); hasPrefix != tt.wantPrefix {
+				t.Errorf("prefix present = %v, want %v (result %q)", hasPrefix, tt.wantPrefix, result)
+			}
+			if !strings.HasSuffix(result, tt.want) {
+				t.Errorf("filterIdentity() = %q, want it to end with %q", result, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterIdentity_OffLeavesPromptUnmodified(t *testing.T) {
+	opts := DefaultOptions()
+	if err := opts.SetIdentityFilter(IdentityFilterOff, nil); err != nil {
+		t.Fatalf("SetIdentityFilter failed: %v", err)
+	}
+
+	input := "<claude_background_info>keep</claude_background_info>\n\n\n\nI am Claude."
+	if result := opts.filterIdentity(input); result != input {
+		t.Errorf("filterIdentity() = %q, want the prompt unmodified", result)
+	}
+}
+
+func TestFilterIdentity_CustomRules(t *testing.T) {
+	rules := &IdentityRules{
+		Note: "Note: Answer as the underlying model.",
+		Rules: []IdentityRule{
+			{Pattern: `(?i)Anthropic's (\w+)`, Replacement: "the vendor's $1"},
+		},
+	}
+	opts := DefaultOptions()
+	if err := opts.SetIdentityFilter(IdentityFilterFull, rules); err != nil {
+		t.Fatalf("SetIdentityFilter failed: %v", err)
+	}
+
+	result := opts.filterIdentity("Follow Anthropic's guidelines.")
+	want := "Note: Answer as the underlying model.\n\nFollow the vendor's guidelines."
+	if result != want {
+		t.Errorf("filterIdentity() = %q, want %q", result, want)
+	}
+
+	// Custom rules also apply in minimal mode, without the note
+	if err := opts.SetIdentityFilter(IdentityFilterMinimal, rules); err != nil {
+		t.Fatalf("SetIdentityFilter failed: %v", err)
+	}
+	if result := opts.filterIdentity("Follow Anthropic's guidelines."); result != "Follow the vendor's guidelines." {
+		t.Errorf("filterIdentity() = %q in minimal mode", result)
+	}
+}
+
+func TestSetIdentityFilter_InvalidPattern(t *testing.T) {
+	opts := DefaultOptions()
+	rules := &IdentityRules{Rules: []IdentityRule{{Pattern: "(unclosed"}}}
+	if err := opts.SetIdentityFilter(IdentityFilterMinimal, rules); err == nil {
+		t.Error("expected error for an invalid pattern")
+	}
+	if opts.identityFilter != IdentityFilterFull {
+		t.Errorf("identity filter = %q after a failed update, want it unchanged", opts.identityFilter)
+	}
+}
+
+func TestLoadIdentityRules(t *testing.T) {
+	dir := t.TempDir()
+
+	rules, err := LoadIdentityRules(filepath.Join(dir, "missing.json"))
+	if err != nil || rules != nil {
+		t.Errorf("LoadIdentityRules(missing) = %v, %v; want nil, nil", rules, err)
+	}
+
+	path := filepath.Join(dir, "identity.json")
+	data := `{"note":"Be yourself.","rules":[{"pattern":"Claude","replacement":"the assistant"}]}`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	rules, err = LoadIdentityRules(path)
+	if err != nil {
+		t.Fatalf("LoadIdentityRules failed: %v", err)
+	}
+	if rules.Note != "Be yourself." || len(rules.Rules) != 1 || rules.Rules[0].Replacement != "the assistant" {
+		t.Errorf("LoadIdentityRules() = %+v", rules)
+	}
+
+	if err := os.WriteFile(path, []byte("{"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadIdentityRules(path); err == nil {
+		t.Error("expected error for malformed JSON")
+	}
+}
@@ -0,0 +1,129 @@
+// Package translator handles protocol translation between Anthropic and OpenAI formats.
+package translator
+
+import (
+	"strings"
+
+	"github.com/jedarden/clasp/internal/logging"
+	"github.com/jedarden/clasp/pkg/models"
+)
+
+// jsonModeInstruction is added to the system prompt when JSON mode is enabled.
+// OpenAI rejects json_object requests unless the prompt mentions JSON.
+const jsonModeInstruction = "Respond only with a valid JSON object."
+
+// defaultJSONSchemaName is used when an output_format schema has no name.
+const defaultJSONSchemaName = "output"
+
+// requestedOutputFormat returns the JSON output format for a request: the request's
+// output_format hint if present, otherwise json_object when force JSON mode is on.
+// Returns nil when JSON mode is not requested.
+func (o *Options) requestedOutputFormat(req *models.AnthropicRequest) *models.OutputFormat {
+	if req.OutputFormat != nil {
+		switch req.OutputFormat.Type {
+		case "json", "json_object":
+			return &models.OutputFormat{Type: "json_object"}
+		case "json_schema":
+			if req.OutputFormat.Schema == nil {
+				return &models.OutputFormat{Type: "json_object"}
+			}
+			return req.OutputFormat
+		}
+	}
+	if o.ForceJSON {
+		return &models.OutputFormat{Type: "json_object"}
+	}
+	return nil
+}
+
+// providerSupportsJSONMode reports whether a provider accepts response_format json_object.
+func providerSupportsJSONMode(provider ProviderType) bool {
+	switch provider {
+	case ProviderMiniMax:
+		return false
+	default:
+		return true
+	}
+}
+
+// providerSupportsJSONSchema reports whether a provider accepts response_format json_schema.
+// Providers with only json_object support get the schema downgraded to json_object.
+func providerSupportsJSONSchema(provider ProviderType) bool {
+	switch provider {
+	case ProviderOpenAI, ProviderAzure, ProviderOpenRouter, ProviderGemini, ProviderOllama, ProviderGrok, ProviderCustom:
+		return true
+	default:
+		return false
+	}
+}
+
+// applyJSONMode sets response_format on a Chat Completions request when JSON output is requested.
+func (o *Options) applyJSONMode(req *models.AnthropicRequest, openAIReq *models.OpenAIRequest, provider ProviderType) {
+	format := o.requestedOutputFormat(req)
+	if format == nil {
+		return
+	}
+	if !providerSupportsJSONMode(provider) {
+		logging.LogDebugMessage("[REQUEST] Dropping JSON mode: response_format not supported by provider %s", provider)
+		return
+	}
+
+	if format.Type == "json_schema" && providerSupportsJSONSchema(provider) {
+		openAIReq.ResponseFormat = &models.ResponseFormat{
+			Type: "json_schema",
+			JSONSchema: &models.JSONSchemaFormat{
+				Name:   jsonSchemaName(format),
+				Schema: format.Schema,
+			},
+		}
+	} else {
+		openAIReq.ResponseFormat = &models.ResponseFormat{Type: "json_object"}
+	}
+	openAIReq.Messages = ensureJSONInstruction(openAIReq.Messages)
+}
+
+// applyJSONModeToResponses sets text.format on a Responses request when JSON output is requested.
+func (o *Options) applyJSONModeToResponses(req *models.AnthropicRequest, responsesReq *models.ResponsesRequest) {
+	format := o.requestedOutputFormat(req)
+	if format == nil {
+		return
+	}
+
+	textFormat := &models.ResponsesTextFormat{Type: "json_object"}
+	if format.Type == "json_schema" {
+		textFormat = &models.ResponsesTextFormat{
+			Type:   "json_schema",
+			Name:   jsonSchemaName(format),
+			Schema: format.Schema,
+		}
+	}
+	responsesReq.Text = &models.ResponsesText{Format: textFormat}
+
+	if !strings.Contains(strings.ToLower(responsesReq.Instructions), "json") {
+		if responsesReq.Instructions != "" {
+			responsesReq.Instructions += "\n\n"
+		}
+		responsesReq.Instructions += jsonModeInstruction
+	}
+}
+
+func jsonSchemaName(format *models.OutputFormat) string {
+	if format.Name != "" {
+		return format.Name
+	}
+	return defaultJSONSchemaName
+}
+
+// ensureJSONInstruction makes sure the system message mentions JSON,
+// adding one if the conversation has no system message.
+func ensureJSONInstruction(messages []models.OpenAIMessage) []models.OpenAIMessage {
+	if len(messages) > 0 && messages[0].Role == "system" {
+		if content, ok := messages[0].Content.(string); ok {
+			if !strings.Contains(strings.ToLower(content), "json") {
+				messages[0].Content = content + "\n\n" + jsonModeInstruction
+			}
+			return messages
+		}
+	}
+	return append([]models.OpenAIMessage{{Role: "system", Content: jsonModeInstruction}}, messages...)
+}
@@ -0,0 +1,120 @@
+// Package translator provides protocol translation between Anthropic and OpenAI formats.
+package translator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jedarden/clasp/pkg/models"
+)
+
+func jsonModeRequest(format *models.OutputFormat) *models.AnthropicRequest {
+	return &models.AnthropicRequest{
+		Model:        "claude-3-5-sonnet-20241022",
+		MaxTokens:    100,
+		System:       "You are a helpful assistant.",
+		OutputFormat: format,
+		Messages: []models.AnthropicMessage{
+			{Role: "user", Content: "List three colors"},
+		},
+	}
+}
+
+func TestTransformRequest_OutputFormatJSON(t *testing.T) {
+	result, err := TransformRequestWithProvider(jsonModeRequest(&models.OutputFormat{Type: "json"}), "gpt-4o", ProviderOpenAI)
+	if err != nil {
+		t.Fatalf("TransformRequestWithProvider failed: %v", err)
+	}
+	if result.ResponseFormat == nil || result.ResponseFormat.Type != "json_object" {
+		t.Fatalf("ResponseFormat = %+v, want json_object", result.ResponseFormat)
+	}
+	system, _ := result.Messages[0].Content.(string)
+	if !strings.Contains(strings.ToLower(system), "json") {
+		t.Errorf("system message should mention JSON, got %q", system)
+	}
+}
+
+func TestTransformRequest_OutputFormatJSONSchema(t *testing.T) {
+	schema := map[string]interface{}{"type": "object"}
+	format := &models.OutputFormat{Type: "json_schema", Name: "colors", Schema: schema}
+
+	result, err := TransformRequestWithProvider(jsonModeRequest(format), "gpt-4o", ProviderOpenAI)
+	if err != nil {
+		t.Fatalf("TransformRequestWithProvider failed: %v", err)
+	}
+	rf := result.ResponseFormat
+	if rf == nil || rf.Type != "json_schema" || rf.JSONSchema == nil || rf.JSONSchema.Name != "colors" {
+		t.Fatalf("ResponseFormat = %+v, want json_schema named colors", rf)
+	}
+
+	// DeepSeek only supports json_object
+	result, err = TransformRequestWithProvider(jsonModeRequest(format), "deepseek-chat", ProviderDeepSeek)
+	if err != nil {
+		t.Fatalf("TransformRequestWithProvider failed: %v", err)
+	}
+	if result.ResponseFormat == nil || result.ResponseFormat.Type != "json_object" {
+		t.Errorf("ResponseFormat = %+v, want json_object downgrade", result.ResponseFormat)
+	}
+}
+
+func TestTransformRequest_JSONModeUnsupportedProvider(t *testing.T) {
+	result, err := TransformRequestWithProvider(jsonModeRequest(&models.OutputFormat{Type: "json"}), "minimax-m2", ProviderMiniMax)
+	if err != nil {
+		t.Fatalf("TransformRequestWithProvider failed: %v", err)
+	}
+	if result.ResponseFormat != nil {
+		t.Errorf("expected no response_format for MiniMax, got %+v", result.ResponseFormat)
+	}
+	if system, _ := result.Messages[0].Content.(string); strings.Contains(system, jsonModeInstruction) {
+		t.Errorf("JSON instruction should not be added when JSON mode is dropped")
+	}
+}
+
+func TestTransformRequest_ForceJSON(t *testing.T) {
+	req := jsonModeRequest(nil)
+	req.System = nil
+
+	result, err := TransformRequestWithProvider(req, "gpt-4o", ProviderOpenAI)
+	if err != nil {
+		t.Fatalf("TransformRequestWithProvider failed: %v", err)
+	}
+	if result.ResponseFormat != nil {
+		t.Fatalf("expected no response_format without force JSON, got %+v", result.ResponseFormat)
+	}
+
+	opts := DefaultOptions()
+	opts.ForceJSON = true
+
+	result, err = opts.TransformRequestWithProvider(req, "gpt-4o", ProviderOpenAI)
+	if err != nil {
+		t.Fatalf("TransformRequestWithProvider failed: %v", err)
+	}
+	if result.ResponseFormat == nil || result.ResponseFormat.Type != "json_object" {
+		t.Fatalf("ResponseFormat = %+v, want json_object", result.ResponseFormat)
+	}
+	if result.Messages[0].Role != "system" || result.Messages[0].Content != jsonModeInstruction {
+		t.Errorf("expected JSON system instruction, got %+v", result.Messages[0])
+	}
+}
+
+func TestTransformRequestToResponses_JSONMode(t *testing.T) {
+	result, err := TransformRequestToResponses(jsonModeRequest(&models.OutputFormat{Type: "json"}), "gpt-5", "")
+	if err != nil {
+		t.Fatalf("TransformRequestToResponses failed: %v", err)
+	}
+	if result.Text == nil || result.Text.Format == nil || result.Text.Format.Type != "json_object" {
+		t.Fatalf("Text = %+v, want json_object format", result.Text)
+	}
+	if !strings.Contains(result.Instructions, jsonModeInstruction) {
+		t.Errorf("instructions should mention JSON, got %q", result.Instructions)
+	}
+
+	schema := map[string]interface{}{"type": "object"}
+	result, err = TransformRequestToResponses(jsonModeRequest(&models.OutputFormat{Type: "json_schema", Schema: schema}), "gpt-5", "")
+	if err != nil {
+		t.Fatalf("TransformRequestToResponses failed: %v", err)
+	}
+	if f := result.Text.Format; f.Type != "json_schema" || f.Name != defaultJSONSchemaName || f.Schema == nil {
+		t.Errorf("Text.Format = %+v, want json_schema with default name", f)
+	}
+}
@@ -0,0 +1,53 @@
+// Package translator handles protocol translation between Anthropic and OpenAI formats.
+package translator
+
+import (
+	"strings"
+)
+
+// SetMaxTokensLimits sets per-model max_tokens limits. They extend the built-in
+// limit table and take precedence over it; keys are model names in the form
+// produced by NormalizeMaxTokensModel and also match model variants by prefix.
+func (o *Options) SetMaxTokensLimits(limits map[string]int) {
+	normalized := make(map[string]int, len(limits))
+	for model, limit := range limits {
+		normalized[NormalizeMaxTokensModel(model)] = limit
+	}
+	o.maxTokensLimits = normalized
+}
+
+// NormalizeMaxTokensModel lowercases a model name and replaces every character
+// that cannot appear in an environment variable name with "_", so
+// CLASP_MAX_TOKENS_GPT_4O matches gpt-4o.
+func NormalizeMaxTokensModel(model string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + ('a' - 'A')
+		default:
+			return '_'
+		}
+	}, model)
+}
+
+// maxTokensOverride returns the user-configured limit for targetModel, matching
+// the exact model first and then the longest prefix.
+func (o *Options) maxTokensOverride(targetModel string) (int, bool) {
+	if len(o.maxTokensLimits) == 0 {
+		return 0, false
+	}
+
+	model := NormalizeMaxTokensModel(targetModel)
+	if limit, ok := o.maxTokensLimits[model]; ok {
+		return limit, true
+	}
+	limit, longest := 0, 0
+	for prefix, prefixLimit := range o.maxTokensLimits {
+		if strings.HasPrefix(model, prefix) && len(prefix) > longest {
+			limit, longest = prefixLimit, len(prefix)
+		}
+	}
+	return limit, longest > 0
+}
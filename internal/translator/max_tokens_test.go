@@ -0,0 +1,56 @@
+package translator
+
+import "testing"
+
+func TestCapMaxTokens_Disabled(t *testing.T) {
+	opts := DefaultOptions()
+	opts.DisableMaxTokensCap = true
+
+	if got := opts.capMaxTokens(100000, "gpt-4o"); got != 100000 {
+		t.Errorf("capMaxTokens() = %d, want 100000 with capping disabled", got)
+	}
+	if got := opts.capMaxTokens(50000, "unknown-model"); got != 50000 {
+		t.Errorf("capMaxTokens() = %d, want 50000 with capping disabled", got)
+	}
+}
+
+func TestCapMaxTokens_CustomLimit(t *testing.T) {
+	opts := DefaultOptions()
+	opts.SetMaxTokensLimits(map[string]int{"gpt_4o": 32768, "my-local-model": 65536})
+
+	tests := []struct {
+		name        string
+		maxTokens   int
+		targetModel string
+		expected    int
+	}{
+		{"override raises the built-in limit", 30000, "gpt-4o", 30000},
+		{"override still caps", 40000, "gpt-4o", 32768},
+		{"override matches variants by prefix", 40000, "gpt-4o-2024-08-06", 32768},
+		{"override for an unknown model", 60000, "my-local-model", 60000},
+		{"other models keep the built-in limit", 20000, "gpt-4-turbo", 4096},
+		{"unknown models keep the default limit", 20000, "other-model", defaultMaxTokenLimit},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := opts.capMaxTokens(tt.maxTokens, tt.targetModel); got != tt.expected {
+				t.Errorf("capMaxTokens(%d, %q) = %d, want %d", tt.maxTokens, tt.targetModel, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestNormalizeMaxTokensModel(t *testing.T) {
+	tests := map[string]string{
+		"gpt-4o":                        "gpt_4o",
+		"GPT_4O":                        "gpt_4o",
+		"anthropic/claude-3.5-sonnet":   "anthropic_claude_3_5_sonnet",
+		"meta-llama/Llama-3.1-70B-Inst": "meta_llama_llama_3_1_70b_inst",
+	}
+	for model, want := range tests {
+		if got := NormalizeMaxTokensModel(model); got != want {
+			t.Errorf("NormalizeMaxTokensModel(%q) = %q, want %q", model, got, want)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+// Package translator handles protocol translation between Anthropic and OpenAI formats.
+package translator
+
+// Options holds the proxy-level settings applied while translating requests
+// and responses. Each proxy handler builds its own from its configuration and
+// passes it with every request; an Options must not be modified once in use.
+type Options struct {
+	// DocumentFallback controls document blocks for providers without file inputs.
+	DocumentFallback DocumentFallback
+
+	// Sampling parameters with no Anthropic equivalent. LogitBias is forwarded
+	// as logit_bias; the seed and penalties apply when a request doesn't set its own.
+	LogitBias        map[string]float64
+	Seed             *int
+	PresencePenalty  *float64
+	FrequencyPenalty *float64
+
+	// CustomTopK forwards top_k to custom endpoints. Other providers get it
+	// only when they are known to accept it.
+	CustomTopK bool
+
+	// CustomParallelToolCalls forwards parallel_tool_calls to custom
+	// endpoints, the same way CustomTopK does for top_k.
+	CustomParallelToolCalls bool
+
+	// ForceJSON enables JSON mode for every request, even without an output_format hint.
+	ForceJSON bool
+
+	// ParallelToolCalls is the parallel_tool_calls value sent with tool requests
+	// that don't set their own.
+	ParallelToolCalls bool
+
+	// RepairToolJSON holds streamed tool arguments until the tool call
+	// completes, so malformed JSON can be repaired before it is emitted.
+	RepairToolJSON bool
+
+	// ResponsesStore is sent as store with every Responses API request; nil
+	// leaves it to the provider. ResponsesReasoningSummary is the reasoning
+	// summary requested from reasoning models; "" requests none.
+	ResponsesStore            *bool
+	ResponsesReasoningSummary string
+
+	// DisableMaxTokensCap forwards max_tokens unchanged.
+	DisableMaxTokensCap bool
+
+	maxTokensLimits       map[string]int    // See SetMaxTokensLimits
+	finishReasonOverrides map[string]string // See SetFinishReasonOverrides
+
+	// See SetIdentityFilter
+	identityFilter IdentityFilter
+	identityNote   string
+	identityRules  []identityPattern
+}
+
+// DefaultOptions returns the options used when the proxy configures none:
+// documents fall back to text, parallel tool calls are allowed and identity
+// statements are fully filtered.
+func DefaultOptions() *Options {
+	return &Options{
+		DocumentFallback:  DocumentFallbackText,
+		ParallelToolCalls: true,
+		identityFilter:    IdentityFilterFull,
+		identityNote:      defaultIdentityNote,
+	}
+}
+
+// defaultOptions is used by the package-level translation functions.
+var defaultOptions = DefaultOptions()
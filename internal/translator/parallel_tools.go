@@ -0,0 +1,61 @@
+// Package translator handles protocol translation between Anthropic and OpenAI formats.
+package translator
+
+import (
+	"github.com/jedarden/clasp/internal/logging"
+	"github.com/jedarden/clasp/pkg/models"
+)
+
+// providerSupportsParallelToolCalls reports whether a provider accepts parallel_tool_calls.
+func providerSupportsParallelToolCalls(provider ProviderType) bool {
+	switch provider {
+	case ProviderOpenAI, ProviderAzure, ProviderOpenRouter, ProviderGrok, ProviderQwen:
+		return true
+	default:
+		return false
+	}
+}
+
+// forwardsParallelToolCalls reports whether parallel_tool_calls is sent to
+// provider. Custom endpoints get it only when CustomParallelToolCalls is set,
+// since many reject unknown parameters.
+func (o *Options) forwardsParallelToolCalls(provider ProviderType) bool {
+	return providerSupportsParallelToolCalls(provider) || (provider == ProviderCustom && o.CustomParallelToolCalls)
+}
+
+// toolChoiceDisablesParallel reports whether an Anthropic tool_choice sets
+// disable_parallel_tool_use, which is valid with every tool_choice type.
+func toolChoiceDisablesParallel(choice interface{}) bool {
+	choiceMap, ok := choice.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	disabled, _ := choiceMap["disable_parallel_tool_use"].(bool)
+	return disabled
+}
+
+// resolveParallelToolCalls returns the parallel_tool_calls value for a request:
+// false if tool_choice sets disable_parallel_tool_use, then the request's own
+// value if set, otherwise the configured default.
+func (o *Options) resolveParallelToolCalls(req *models.AnthropicRequest) bool {
+	if toolChoiceDisablesParallel(req.ToolChoice) {
+		return false
+	}
+	if req.ParallelToolCalls != nil {
+		return *req.ParallelToolCalls
+	}
+	return o.ParallelToolCalls
+}
+
+// applyParallelToolCalls sets parallel_tool_calls on a Chat Completions request with tools.
+func (o *Options) applyParallelToolCalls(req *models.AnthropicRequest, openAIReq *models.OpenAIRequest, provider ProviderType) {
+	if len(openAIReq.Tools) == 0 {
+		return
+	}
+	if !o.forwardsParallelToolCalls(provider) {
+		logging.LogDebugMessage("[REQUEST] Dropping parallel_tool_calls: not supported by provider %s", provider)
+		return
+	}
+	enabled := o.resolveParallelToolCalls(req)
+	openAIReq.ParallelToolCalls = &enabled
+}
@@ -0,0 +1,198 @@
+// Package translator provides protocol translation between Anthropic and OpenAI formats.
+package translator
+
+import (
+	"testing"
+
+	"github.com/jedarden/clasp/pkg/models"
+)
+
+func parallelToolsRequest(withTools bool) *models.AnthropicRequest {
+	req := &models.AnthropicRequest{
+		Model:     "claude-3-5-sonnet-20241022",
+		MaxTokens: 100,
+		Messages: []models.AnthropicMessage{
+			{Role: "user", Content: "Read both files"},
+		},
+	}
+	if withTools {
+		req.Tools = []models.AnthropicTool{
+			{
+				Name:        "read_file",
+				Description: "Read a file",
+				InputSchema: map[string]interface{}{"type": "object"},
+			},
+		}
+	}
+	return req
+}
+
+func TestTransformRequest_ParallelToolCalls(t *testing.T) {
+	disabled := false
+	enabled := true
+
+	tests := []struct {
+		name       string
+		withTools  bool
+		global     bool
+		perRequest *bool
+		provider   ProviderType
+		want       *bool
+	}{
+		{"default with tools", true, true, nil, ProviderOpenAI, &enabled},
+		{"global disabled", true, false, nil, ProviderOpenAI, &disabled},
+		{"request overrides global", true, false, &enabled, ProviderOpenAI, &enabled},
+		{"request disables", true, true, &disabled, ProviderOpenAI, &disabled},
+		{"no tools", false, true, nil, ProviderOpenAI, nil},
+		{"unsupported provider", true, false, nil, ProviderDeepSeek, nil},
+		{"custom endpoint by default", true, true, nil, ProviderCustom, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := DefaultOptions()
+			opts.ParallelToolCalls = tt.global
+
+			req := parallelToolsRequest(tt.withTools)
+			req.ParallelToolCalls = tt.perRequest
+
+			result, err := opts.TransformRequestWithProvider(req, "gpt-4o", tt.provider)
+			if err != nil {
+				t.Fatalf("TransformRequestWithProvider failed: %v", err)
+			}
+			switch {
+			case tt.want == nil && result.ParallelToolCalls != nil:
+				t.Errorf("expected parallel_tool_calls to be omitted, got %v", *result.ParallelToolCalls)
+			case tt.want != nil && result.ParallelToolCalls == nil:
+				t.Errorf("expected parallel_tool_calls=%v, got omitted", *tt.want)
+			case tt.want != nil && *result.ParallelToolCalls != *tt.want:
+				t.Errorf("parallel_tool_calls = %v, want %v", *result.ParallelToolCalls, *tt.want)
+			}
+		})
+	}
+}
+
+func TestTransformRequest_CustomParallelToolCalls(t *testing.T) {
+	opts := DefaultOptions()
+	opts.CustomParallelToolCalls = true
+
+	result, err := opts.TransformRequestWithProvider(parallelToolsRequest(true), "my-model", ProviderCustom)
+	if err != nil {
+		t.Fatalf("TransformRequestWithProvider failed: %v", err)
+	}
+	if result.ParallelToolCalls == nil || !*result.ParallelToolCalls {
+		t.Errorf("parallel_tool_calls = %v, want true with CustomParallelToolCalls set", result.ParallelToolCalls)
+	}
+
+	result, err = opts.TransformRequestWithProvider(parallelToolsRequest(true), "deepseek-chat", ProviderDeepSeek)
+	if err != nil {
+		t.Fatalf("TransformRequestWithProvider failed: %v", err)
+	}
+	if result.ParallelToolCalls != nil {
+		t.Errorf("parallel_tool_calls = %v, want omitted for DeepSeek even with CustomParallelToolCalls set", *result.ParallelToolCalls)
+	}
+}
+
+func TestTransformRequestToResponses_ParallelToolCalls(t *testing.T) {
+	opts := DefaultOptions()
+	opts.ParallelToolCalls = false
+
+	result, err := opts.TransformRequestToResponses(parallelToolsRequest(true), "gpt-5", "")
+	if err != nil {
+		t.Fatalf("TransformRequestToResponses failed: %v", err)
+	}
+	if result.ParallelToolCalls == nil || *result.ParallelToolCalls {
+		t.Errorf("expected parallel_tool_calls=false, got %v", result.ParallelToolCalls)
+	}
+
+	result, err = opts.TransformRequestToResponses(parallelToolsRequest(false), "gpt-5", "")
+	if err != nil {
+		t.Fatalf("TransformRequestToResponses failed: %v", err)
+	}
+	if result.ParallelToolCalls != nil {
+		t.Errorf("expected parallel_tool_calls to be omitted without tools")
+	}
+}
+
+func TestTransformRequest_DisableParallelToolUse(t *testing.T) {
+	choices := []struct {
+		choice     map[string]interface{}
+		wantChoice interface{}
+	}{
+		{map[string]interface{}{"type": "auto"}, "auto"},
+		{map[string]interface{}{"type": "any"}, "required"},
+		{map[string]interface{}{"type": "none"}, "none"},
+		{map[string]interface{}{"type": "tool", "name": "read_file"}, "read_file"},
+	}
+
+	for _, tc := range choices {
+		for _, disable := range []bool{true, false} {
+			name := tc.choice["type"].(string)
+			if disable {
+				name += " with disable_parallel_tool_use"
+			}
+			t.Run(name, func(t *testing.T) {
+				choice := map[string]interface{}{}
+				for k, v := range tc.choice {
+					choice[k] = v
+				}
+				if disable {
+					choice["disable_parallel_tool_use"] = true
+				}
+				req := parallelToolsRequest(true)
+				req.ToolChoice = choice
+
+				chat, err := TransformRequestWithProvider(req, "gpt-4o", ProviderOpenAI)
+				if err != nil {
+					t.Fatalf("TransformRequestWithProvider failed: %v", err)
+				}
+				if chat.ParallelToolCalls == nil || *chat.ParallelToolCalls == disable {
+					t.Errorf("chat parallel_tool_calls = %v, want %v", chat.ParallelToolCalls, !disable)
+				}
+				if got := toolChoiceName(chat.ToolChoice); got != tc.wantChoice {
+					t.Errorf("chat tool_choice = %v, want %v", chat.ToolChoice, tc.wantChoice)
+				}
+
+				responses, err := TransformRequestToResponses(req, "gpt-5", "")
+				if err != nil {
+					t.Fatalf("TransformRequestToResponses failed: %v", err)
+				}
+				if responses.ParallelToolCalls == nil || *responses.ParallelToolCalls == disable {
+					t.Errorf("responses parallel_tool_calls = %v, want %v", responses.ParallelToolCalls, !disable)
+				}
+				if got := toolChoiceName(responses.ToolChoice); got != tc.wantChoice {
+					t.Errorf("responses tool_choice = %v, want %v", responses.ToolChoice, tc.wantChoice)
+				}
+			})
+		}
+	}
+}
+
+// toolChoiceName returns a string tool_choice as is and the function name of
+// a forced function choice.
+func toolChoiceName(choice interface{}) interface{} {
+	if m, ok := choice.(map[string]interface{}); ok {
+		if fn, ok := m["function"].(map[string]interface{}); ok {
+			return fn["name"]
+		}
+	}
+	return choice
+}
+
+func TestTransformRequest_DisableParallelToolUseOverridesRequest(t *testing.T) {
+	enabled := true
+	req := parallelToolsRequest(true)
+	req.ParallelToolCalls = &enabled
+	req.ToolChoice = map[string]interface{}{"type": "any", "disable_parallel_tool_use": true}
+
+	result, err := TransformRequestWithProvider(req, "gpt-4o", ProviderOpenAI)
+	if err != nil {
+		t.Fatalf("TransformRequestWithProvider failed: %v", err)
+	}
+	if result.ParallelToolCalls == nil || *result.ParallelToolCalls {
+		t.Errorf("expected parallel_tool_calls=false, got %v", result.ParallelToolCalls)
+	}
+	if result.ToolChoice != "required" {
+		t.Errorf("tool_choice = %v, want required", result.ToolChoice)
+	}
+}
@@ -52,7 +52,9 @@ func DetectProviderFromModel(model string) ProviderType {
 }
 
 // TransformToolsForProvider converts Anthropic tools to provider-specific format.
+// Anthropic server tools are dropped.
 func TransformToolsForProvider(tools []models.AnthropicTool, provider ProviderType, model string) []models.OpenAITool {
+	tools = clientTools(tools, nil)
 	result := make([]models.OpenAITool, len(tools))
 
 	for i, tool := range tools {
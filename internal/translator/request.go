@@ -7,6 +7,7 @@ import (
 	"regexp"
 	"strings"
 
+	"github.com/jedarden/clasp/internal/logging"
 	"github.com/jedarden/clasp/pkg/models"
 )
 
@@ -73,10 +74,7 @@ const defaultMaxTokenLimit = 4096
 // Pre-compiled regex patterns for identity filtering.
 // These are compiled once at package initialization for better performance.
 var (
-	identityPatterns = []struct {
-		re          *regexp.Regexp
-		replacement string
-	}{
+	identityPatterns = []identityPattern{
 		// Replace "You are Claude Code, Anthropic's official CLI" with neutral version
 		{regexp.MustCompile(`(?i)You are Claude Code, Anthropic's official CLI`), "This is Claude Code, an AI-powered CLI tool"},
 		// Replace "You are Claude" at start of sentences
@@ -96,13 +94,17 @@ var (
 )
 
 // capMaxTokens ensures max_tokens doesn't exceed the target model's limit.
-func capMaxTokens(maxTokens int, targetModel string) int {
-	if maxTokens <= 0 {
+// Limits configured with SetMaxTokensLimits take precedence over the built-in table.
+func (o *Options) capMaxTokens(maxTokens int, targetModel string) int {
+	if maxTokens <= 0 || o.DisableMaxTokensCap {
 		return maxTokens
 	}
 
 	// Look up model limit
-	limit, ok := modelMaxTokenLimits[targetModel]
+	limit, ok := o.maxTokensOverride(targetModel)
+	if !ok {
+		limit, ok = modelMaxTokenLimits[targetModel]
+	}
 	if !ok {
 		// Try prefix matching for model variants
 		for modelPrefix, modelLimit := range modelMaxTokenLimits {
@@ -126,21 +128,35 @@ func capMaxTokens(maxTokens int, targetModel string) int {
 	return maxTokens
 }
 
-// TransformRequest converts an Anthropic request to OpenAI format.
+// TransformRequest converts an Anthropic request to OpenAI format with the
+// default options.
 // This is a convenience wrapper that auto-detects the provider from the model name.
 func TransformRequest(req *models.AnthropicRequest, targetModel string) (*models.OpenAIRequest, error) {
+	return defaultOptions.TransformRequest(req, targetModel)
+}
+
+// TransformRequestWithProvider converts an Anthropic request to provider-specific
+// format with the default options.
+func TransformRequestWithProvider(req *models.AnthropicRequest, targetModel string, provider ProviderType) (*models.OpenAIRequest, error) {
+	return defaultOptions.TransformRequestWithProvider(req, targetModel, provider)
+}
+
+// TransformRequest converts an Anthropic request to OpenAI format, detecting
+// the provider from the model name.
+func (o *Options) TransformRequest(req *models.AnthropicRequest, targetModel string) (*models.OpenAIRequest, error) {
 	provider := DetectProviderFromModel(targetModel)
-	return TransformRequestWithProvider(req, targetModel, provider)
+	return o.TransformRequestWithProvider(req, targetModel, provider)
 }
 
 // TransformRequestWithProvider converts an Anthropic request to provider-specific format.
-func TransformRequestWithProvider(req *models.AnthropicRequest, targetModel string, provider ProviderType) (*models.OpenAIRequest, error) {
+func (o *Options) TransformRequestWithProvider(req *models.AnthropicRequest, targetModel string, provider ProviderType) (*models.OpenAIRequest, error) {
 	openAIReq := &models.OpenAIRequest{
 		Model:       targetModel,
 		Stream:      req.Stream,
-		MaxTokens:   capMaxTokens(req.MaxTokens, targetModel),
+		MaxTokens:   o.capMaxTokens(req.MaxTokens, targetModel),
 		Temperature: req.Temperature,
 		TopP:        req.TopP,
+		ServiceTier: mapServiceTier(req.ServiceTier, provider),
 	}
 
 	// Transform stop sequences
@@ -148,25 +164,56 @@ func TransformRequestWithProvider(req *models.AnthropicRequest, targetModel stri
 		openAIReq.Stop = req.StopSequences
 	}
 
+	// top_k is only forwarded to providers that accept it
+	if req.TopK != nil {
+		if o.forwardsTopK(provider) {
+			openAIReq.TopK = req.TopK
+		} else {
+			logging.LogDebugMessage("[REQUEST] Dropping top_k=%d: not supported by provider %s", *req.TopK, provider)
+		}
+	}
+
+	// Multiple candidates; the stream processor only translates a single choice
+	if req.N > 1 {
+		if req.Stream {
+			logging.LogDebugMessage("[REQUEST] Dropping n=%d: not supported for streaming requests", req.N)
+		} else {
+			openAIReq.N = req.N
+		}
+	}
+
+	// Proxy-level sampling parameters (seed, penalties, logit_bias)
+	o.applySamplingParameters(req, openAIReq, provider)
+
+	// Features the target model lacks are stripped or adapted rather than
+	// forwarded for the upstream to reject
+	caps, family := LookupCapabilities(targetModel)
+
 	// Build messages with provider-specific handling
-	messages, err := transformMessages(req, targetModel, provider)
+	messages, err := o.transformMessages(req, targetModel, provider)
 	if err != nil {
 		return nil, fmt.Errorf("transforming messages: %w", err)
 	}
+	if !caps.Vision {
+		messages = stripImages(messages, targetModel)
+	}
 	openAIReq.Messages = messages
 
 	// Transform tools with provider-specific handling
 	if len(req.Tools) > 0 {
-		if ProviderSupportsTools(provider, targetModel) {
+		if !caps.Tools {
+			logging.LogDebugMessage("[REQUEST] Dropping %d tools: model %s (%s) does not support function calling", len(req.Tools), targetModel, family)
+		} else if ProviderSupportsTools(provider, targetModel) {
 			openAIReq.Tools = TransformToolsForProvider(req.Tools, provider, targetModel)
 		}
 		// If provider doesn't support tools, they are silently omitted
 	}
 
 	// Transform tool choice
-	if req.ToolChoice != nil {
-		openAIReq.ToolChoice = transformToolChoice(req.ToolChoice)
+	if req.ToolChoice != nil && caps.Tools {
+		openAIReq.ToolChoice = transformToolChoice(clientToolChoice(req.ToolChoice, req.Tools))
 	}
+	o.applyParallelToolCalls(req, openAIReq, provider)
 
 	// Enable usage tracking for streaming
 	if req.Stream {
@@ -176,11 +223,39 @@ func TransformRequestWithProvider(req *models.AnthropicRequest, targetModel stri
 	}
 
 	// Transform thinking/reasoning parameters based on target model
-	applyThinkingParameters(req, openAIReq, targetModel)
+	if caps.Thinking {
+		applyThinkingParameters(req, openAIReq, targetModel)
+	} else if req.Thinking != nil && req.Thinking.BudgetTokens > 0 {
+		logging.LogDebugMessage("[REQUEST] Dropping thinking: model %s (%s) is not a reasoning model", targetModel, family)
+	}
+
+	// JSON mode (output_format hint or CLASP_FORCE_JSON)
+	if caps.JSONMode {
+		o.applyJSONMode(req, openAIReq, provider)
+	} else if o.requestedOutputFormat(req) != nil {
+		logging.LogDebugMessage("[REQUEST] Dropping JSON mode: model %s (%s) does not support response_format", targetModel, family)
+	}
 
 	return openAIReq, nil
 }
 
+// providerSupportsTopK reports whether a provider accepts the non-standard top_k
+// sampling parameter on its Chat Completions endpoint.
+func providerSupportsTopK(provider ProviderType) bool {
+	switch provider {
+	case ProviderOpenRouter, ProviderOllama, ProviderQwen:
+		return true
+	default:
+		return false
+	}
+}
+
+// forwardsTopK reports whether top_k is sent to provider. Custom endpoints
+// get it only when CustomTopK is set, since many reject unknown parameters.
+func (o *Options) forwardsTopK(provider ProviderType) bool {
+	return providerSupportsTopK(provider) || (provider == ProviderCustom && o.CustomTopK)
+}
+
 // applyThinkingParameters maps Anthropic thinking.budget_tokens to model-specific parameters.
 // This enables extended reasoning capabilities across different model providers.
 func applyThinkingParameters(req *models.AnthropicRequest, openAIReq *models.OpenAIRequest, targetModel string) {
@@ -394,29 +469,50 @@ func isDeepSeekModel(model string) bool {
 // filterIdentity removes Claude-specific identity strings from content to prevent model confusion.
 // This is important when proxying to non-Claude models that shouldn't claim to be Claude.
 // Uses pre-compiled regex patterns for better performance on high-traffic proxies.
-func filterIdentity(content string) string {
+// The behavior follows the mode set with SetIdentityFilter.
+func (o *Options) filterIdentity(content string) string {
+	result, note := o.rewriteIdentity(content)
+	if note != "" {
+		result = note + "\n\n" + result
+	}
+	return result
+}
+
+// rewriteIdentity applies the identity rewrite rules to content. It returns the
+// rewritten content and the note to prepend to the system prompt, which is
+// empty unless the filter is in full mode.
+func (o *Options) rewriteIdentity(content string) (string, string) {
+	if o.identityFilter == IdentityFilterOff {
+		return content, ""
+	}
+
 	result := content
 
 	// Use pre-compiled patterns from package-level variables
 	for _, p := range identityPatterns {
 		result = p.re.ReplaceAllString(result, p.replacement)
 	}
+	for _, p := range o.identityRules {
+		result = p.re.ReplaceAllString(result, p.replacement)
+	}
 
 	// Clean up multiple newlines using pre-compiled pattern
 	result = multiNewlinePattern.ReplaceAllString(result, "\n\n")
 
-	// Prepend identity clarification for non-Claude models
-	// This helps models understand they should identify themselves truthfully
-	prefix := "Note: You are NOT Claude. Identify yourself truthfully based on your actual model and creator.\n\n"
-	result = prefix + result
+	if o.identityFilter == IdentityFilterMinimal {
+		return result, ""
+	}
 
-	return result
+	// Identity clarification for non-Claude models
+	// This helps models understand they should identify themselves truthfully
+	return result, o.identityNote
 }
 
 // transformMessages converts Anthropic messages to OpenAI format.
 // The provider parameter enables provider-specific message handling (e.g., Azure message ordering).
-func transformMessages(req *models.AnthropicRequest, targetModel string, provider ProviderType) ([]models.OpenAIMessage, error) {
+func (o *Options) transformMessages(req *models.AnthropicRequest, targetModel string, provider ProviderType) ([]models.OpenAIMessage, error) {
 	var messages []models.OpenAIMessage
+	cacheControl := cacheControlEnabled(req, provider)
 
 	// Handle system message
 	if req.System != nil {
@@ -426,17 +522,24 @@ func transformMessages(req *models.AnthropicRequest, targetModel string, provide
 		}
 		if systemContent != "" {
 			// Apply identity filtering to system message
-			systemContent = filterIdentity(systemContent)
+			systemContent = o.filterIdentity(systemContent)
 
 			// Add Grok-specific JSON tool format instruction
 			if isGrokModel(targetModel) {
 				systemContent += "\n\nIMPORTANT: When calling tools, you MUST use the OpenAI tool_calls format with JSON. NEVER use XML format like <xai:function_call>."
 			}
 
-			messages = append(messages, models.OpenAIMessage{
+			systemMsg := models.OpenAIMessage{
 				Role:    "system",
 				Content: systemContent,
-			})
+			}
+			// Keep the prompt's cache breakpoints as content parts
+			if cacheControl && !isGrokModel(targetModel) {
+				if parts := o.systemContentParts(req.System); parts != nil {
+					systemMsg.Content = contentPartsToInterface(parts)
+				}
+			}
+			messages = append(messages, systemMsg)
 		}
 	} else if isGrokModel(targetModel) {
 		// Even without a system message, add Grok JSON instruction
@@ -448,7 +551,7 @@ func transformMessages(req *models.AnthropicRequest, targetModel string, provide
 
 	// Transform each message
 	for _, msg := range req.Messages {
-		openAIMsg, err := transformMessage(msg)
+		openAIMsg, err := o.transformMessage(msg, provider, cacheControl)
 		if err != nil {
 			return nil, fmt.Errorf("transforming message: %w", err)
 		}
@@ -559,8 +662,9 @@ func extractSystemContent(system interface{}) (string, error) {
 }
 
 // transformMessage converts a single Anthropic message to OpenAI format.
-// May return multiple messages (e.g., for tool results).
-func transformMessage(msg models.AnthropicMessage) ([]models.OpenAIMessage, error) {
+// May return multiple messages (e.g., for tool results). With cacheControl,
+// cache_control markers on user content are kept.
+func (o *Options) transformMessage(msg models.AnthropicMessage, provider ProviderType, cacheControl bool) ([]models.OpenAIMessage, error) {
 	content, err := parseContent(msg.Content)
 	if err != nil {
 		return nil, err
@@ -584,7 +688,11 @@ func transformMessage(msg models.AnthropicMessage) ([]models.OpenAIMessage, erro
 
 		// Only add user message if there's actual user content (not just tool results)
 		if hasNonToolContent {
-			result = append(result, transformUserMessage(content))
+			userMsg, err := o.transformUserMessage(content, provider, cacheControl)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, userMsg)
 		}
 
 		// Add tool results (these become "tool" role messages in OpenAI format)
@@ -657,15 +765,23 @@ func parseContentBlock(item interface{}) (models.ContentBlock, error) {
 }
 
 // transformUserMessage transforms user message content to OpenAI format.
-func transformUserMessage(content []models.ContentBlock) models.OpenAIMessage {
+// Document blocks become file parts for providers that accept them, otherwise
+// they are converted to text according to the configured DocumentFallback.
+// With cacheControl, text and image parts keep their cache_control markers.
+func (o *Options) transformUserMessage(content []models.ContentBlock, provider ProviderType, cacheControl bool) (models.OpenAIMessage, error) {
 	var parts []models.OpenAIContentPart
 
 	for _, block := range content {
+		var marker *models.CacheControl
+		if cacheControl {
+			marker = block.CacheControl
+		}
 		switch block.Type {
 		case "text":
 			parts = append(parts, models.OpenAIContentPart{
-				Type: "text",
-				Text: block.Text,
+				Type:         "text",
+				Text:         block.Text,
+				CacheControl: marker,
 			})
 		case "image":
 			if block.Source != nil {
@@ -675,18 +791,25 @@ func transformUserMessage(content []models.ContentBlock) models.OpenAIMessage {
 					ImageURL: &models.ImageURL{
 						URL: dataURL,
 					},
+					CacheControl: marker,
 				})
 			}
+		case "document":
+			part, err := o.transformDocumentForChat(block, provider)
+			if err != nil {
+				return models.OpenAIMessage{}, err
+			}
+			parts = append(parts, part)
 		}
 		// Skip tool_result blocks - handled separately
 	}
 
-	// If only text content, use string format
-	if len(parts) == 1 && parts[0].Type == "text" {
+	// If only text content, use string format (a string cannot carry cache_control)
+	if len(parts) == 1 && parts[0].Type == "text" && parts[0].CacheControl == nil {
 		return models.OpenAIMessage{
 			Role:    "user",
 			Content: parts[0].Text,
-		}
+		}, nil
 	}
 
 	// Otherwise use array format
@@ -694,13 +817,13 @@ func transformUserMessage(content []models.ContentBlock) models.OpenAIMessage {
 		return models.OpenAIMessage{
 			Role:    "user",
 			Content: "",
-		}
+		}, nil
 	}
 
 	return models.OpenAIMessage{
 		Role:    "user",
 		Content: contentPartsToInterface(parts),
-	}
+	}, nil
 }
 
 // contentPartsToInterface converts content parts to interface for JSON marshaling.
@@ -713,13 +836,20 @@ func contentPartsToInterface(parts []models.OpenAIContentPart) interface{} {
 }
 
 // extractToolResults extracts tool result blocks and converts to OpenAI tool messages.
+// The "tool" role cannot carry images, so image parts from tool results are sent in a
+// user message placed after all tool messages, labelled with the originating tool_use_id.
 func extractToolResults(content []models.ContentBlock) []models.OpenAIMessage {
 	var results []models.OpenAIMessage
+	var imageParts []models.OpenAIContentPart
 
 	for _, block := range content {
 		if block.Type == "tool_result" {
 			// Extract content from the tool result (can be string or array)
 			output := extractToolResultContentForChat(block)
+			images := extractToolResultImages(block)
+			if output == "" && len(images) > 0 {
+				output = toolResultImagePlaceholder
+			}
 
 			// If the tool result indicates an error, prefix the output
 			if block.IsError {
@@ -732,12 +862,68 @@ func extractToolResults(content []models.ContentBlock) []models.OpenAIMessage {
 				ToolCallID: block.ToolUseID,
 			}
 			results = append(results, toolMsg)
+
+			if len(images) > 0 {
+				imageParts = append(imageParts, models.OpenAIContentPart{
+					Type: "text",
+					Text: toolResultImageLabel(block.ToolUseID),
+				})
+				for _, img := range images {
+					imageParts = append(imageParts, models.OpenAIContentPart{
+						Type: "image_url",
+						ImageURL: &models.ImageURL{
+							URL: imageSourceURL(img),
+						},
+					})
+				}
+			}
 		}
 	}
 
+	if len(imageParts) > 0 {
+		results = append(results, models.OpenAIMessage{
+			Role:    "user",
+			Content: contentPartsToInterface(imageParts),
+		})
+	}
+
 	return results
 }
 
+// toolResultImagePlaceholder is the tool message content for tool results that only contain images.
+const toolResultImagePlaceholder = "[Image output provided in the following message]"
+
+// toolResultImageLabel returns the text that introduces images returned by a tool call.
+func toolResultImageLabel(toolUseID string) string {
+	return fmt.Sprintf("Image output from tool call %s:", toolUseID)
+}
+
+// extractToolResultImages returns the image sources nested in a tool result's array content.
+func extractToolResultImages(block models.ContentBlock) []*models.ImageSource {
+	arr, ok := block.Content.([]interface{})
+	if !ok {
+		return nil
+	}
+
+	var images []*models.ImageSource
+	for _, item := range arr {
+		nested, err := parseContentBlock(item)
+		if err != nil || nested.Type != "image" || nested.Source == nil {
+			continue
+		}
+		images = append(images, nested.Source)
+	}
+	return images
+}
+
+// imageSourceURL converts an Anthropic image source to a URL usable by OpenAI (a data URL for base64).
+func imageSourceURL(src *models.ImageSource) string {
+	if src.Type == "url" && src.URL != "" {
+		return src.URL
+	}
+	return fmt.Sprintf("data:%s;base64,%s", src.MediaType, src.Data)
+}
+
 // extractToolResultContentForChat extracts content from a tool result block for Chat Completions API.
 // The content can be a string or an array of content blocks.
 func extractToolResultContentForChat(block models.ContentBlock) string {
@@ -87,7 +87,7 @@ func TestCapMaxTokens(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := capMaxTokens(tt.maxTokens, tt.targetModel)
+			result := defaultOptions.capMaxTokens(tt.maxTokens, tt.targetModel)
 			if result != tt.expected {
 				t.Errorf("capMaxTokens(%d, %q) = %d, want %d", tt.maxTokens, tt.targetModel, result, tt.expected)
 			}
@@ -334,6 +334,131 @@ func TestTransformRequest_Temperature(t *testing.T) {
 	}
 }
 
+func TestTransformRequest_TopP(t *testing.T) {
+	topP := 0.9
+	req := &models.AnthropicRequest{
+		Model:     "claude-3-sonnet-20240229",
+		MaxTokens: 1000,
+		TopP:      &topP,
+		Messages: []models.AnthropicMessage{
+			{Role: "user", Content: "Test"},
+		},
+	}
+
+	result, err := TransformRequest(req, "gpt-4o")
+	if err != nil {
+		t.Fatalf("TransformRequest failed: %v", err)
+	}
+
+	if result.TopP == nil || *result.TopP != 0.9 {
+		t.Errorf("TopP = %v, want 0.9", result.TopP)
+	}
+}
+
+func TestTransformRequest_TopKPerProvider(t *testing.T) {
+	tests := []struct {
+		name     string
+		model    string
+		provider ProviderType
+		wantTopK bool
+	}{
+		{"OpenAI drops top_k", "gpt-4o", ProviderOpenAI, false},
+		{"Azure drops top_k", "gpt-4o", ProviderAzure, false},
+		{"DeepSeek drops top_k", "deepseek-chat", ProviderDeepSeek, false},
+		{"OpenRouter keeps top_k", "meta-llama/llama-3.1-70b-instruct", ProviderOpenRouter, true},
+		{"Ollama keeps top_k", "llama3.1", ProviderOllama, true},
+		{"Qwen keeps top_k", "qwen-max", ProviderQwen, true},
+		{"Custom drops top_k by default", "my-model", ProviderCustom, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			topK := 40
+			req := &models.AnthropicRequest{
+				Model:     "claude-3-sonnet-20240229",
+				MaxTokens: 1000,
+				TopK:      &topK,
+				Messages: []models.AnthropicMessage{
+					{Role: "user", Content: "Test"},
+				},
+			}
+
+			result, err := TransformRequestWithProvider(req, tt.model, tt.provider)
+			if err != nil {
+				t.Fatalf("TransformRequestWithProvider failed: %v", err)
+			}
+
+			body, err := json.Marshal(result)
+			if err != nil {
+				t.Fatalf("Failed to marshal request: %v", err)
+			}
+			hasTopK := strings.Contains(string(body), `"top_k":40`)
+			if hasTopK != tt.wantTopK {
+				t.Errorf("top_k present = %v, want %v (body: %s)", hasTopK, tt.wantTopK, body)
+			}
+		})
+	}
+}
+
+func TestTransformRequest_CustomTopK(t *testing.T) {
+	opts := DefaultOptions()
+	opts.CustomTopK = true
+	topK := 40
+	req := &models.AnthropicRequest{
+		Model:     "claude-3-sonnet-20240229",
+		MaxTokens: 1000,
+		TopK:      &topK,
+		Messages: []models.AnthropicMessage{
+			{Role: "user", Content: "Test"},
+		},
+	}
+
+	result, err := opts.TransformRequestWithProvider(req, "my-model", ProviderCustom)
+	if err != nil {
+		t.Fatalf("TransformRequestWithProvider failed: %v", err)
+	}
+	if result.TopK == nil || *result.TopK != 40 {
+		t.Errorf("TopK = %v, want 40 with CustomTopK set", result.TopK)
+	}
+
+	result, err = opts.TransformRequestWithProvider(req, "gpt-4o", ProviderOpenAI)
+	if err != nil {
+		t.Fatalf("TransformRequestWithProvider failed: %v", err)
+	}
+	if result.TopK != nil {
+		t.Errorf("TopK = %v, want nil for OpenAI even with CustomTopK set", *result.TopK)
+	}
+}
+
+func TestTransformRequest_MultipleChoices(t *testing.T) {
+	req := &models.AnthropicRequest{
+		Model:     "claude-3-sonnet-20240229",
+		MaxTokens: 1000,
+		N:         3,
+		Messages: []models.AnthropicMessage{
+			{Role: "user", Content: "Test"},
+		},
+	}
+
+	result, err := TransformRequest(req, "gpt-4o")
+	if err != nil {
+		t.Fatalf("TransformRequest failed: %v", err)
+	}
+	if result.N != 3 {
+		t.Errorf("N = %d, want 3", result.N)
+	}
+
+	// Streaming only translates a single choice
+	req.Stream = true
+	result, err = TransformRequest(req, "gpt-4o")
+	if err != nil {
+		t.Fatalf("TransformRequest failed: %v", err)
+	}
+	if result.N != 0 {
+		t.Errorf("N = %d, want n dropped for streaming", result.N)
+	}
+}
+
 func TestExtractSystemContent(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -578,6 +703,101 @@ func TestExtractToolResults(t *testing.T) {
 	}
 }
 
+func TestTransformRequest_ToolResultWithImages(t *testing.T) {
+	req := &models.AnthropicRequest{
+		Model:     "claude-3-5-sonnet-20241022",
+		MaxTokens: 1024,
+		Messages: []models.AnthropicMessage{
+			{
+				Role: "assistant",
+				Content: []interface{}{
+					map[string]interface{}{"type": "tool_use", "id": "call_shot", "name": "screenshot", "input": map[string]interface{}{}},
+				},
+			},
+			{
+				Role: "user",
+				Content: []interface{}{
+					map[string]interface{}{
+						"type":        "tool_result",
+						"tool_use_id": "call_shot",
+						"content": []interface{}{
+							map[string]interface{}{"type": "text", "text": "Captured screen"},
+							map[string]interface{}{
+								"type": "image",
+								"source": map[string]interface{}{
+									"type":       "base64",
+									"media_type": "image/png",
+									"data":       "iVBORw0KGgo=",
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	result, err := TransformRequest(req, "gpt-4o")
+	if err != nil {
+		t.Fatalf("TransformRequest() error = %v", err)
+	}
+
+	// assistant tool call, tool result, then user message carrying the image
+	if len(result.Messages) != 3 {
+		t.Fatalf("len(Messages) = %d, want 3", len(result.Messages))
+	}
+	toolMsg := result.Messages[1]
+	if toolMsg.Role != "tool" || toolMsg.ToolCallID != "call_shot" {
+		t.Errorf("Messages[1] = role %q id %q, want tool call_shot", toolMsg.Role, toolMsg.ToolCallID)
+	}
+	if toolMsg.Content != "Captured screen" {
+		t.Errorf("Messages[1].Content = %v, want %q", toolMsg.Content, "Captured screen")
+	}
+
+	imageMsg := result.Messages[2]
+	if imageMsg.Role != "user" {
+		t.Fatalf("Messages[2].Role = %q, want user", imageMsg.Role)
+	}
+	parts, ok := imageMsg.Content.([]interface{})
+	if !ok || len(parts) != 2 {
+		t.Fatalf("Messages[2].Content = %#v, want 2 parts", imageMsg.Content)
+	}
+	label := parts[0].(models.OpenAIContentPart)
+	if !strings.Contains(label.Text, "call_shot") {
+		t.Errorf("label = %q, want tool_use_id reference", label.Text)
+	}
+	image := parts[1].(models.OpenAIContentPart)
+	if image.Type != "image_url" || image.ImageURL.URL != "data:image/png;base64,iVBORw0KGgo=" {
+		t.Errorf("image part = %#v", image)
+	}
+}
+
+func TestExtractToolResults_ImageOnly(t *testing.T) {
+	content := []models.ContentBlock{
+		{
+			Type:      "tool_result",
+			ToolUseID: "call_1",
+			Content: []interface{}{
+				map[string]interface{}{
+					"type":   "image",
+					"source": map[string]interface{}{"type": "base64", "media_type": "image/jpeg", "data": "abc"},
+				},
+			},
+		},
+	}
+
+	results := extractToolResults(content)
+	if len(results) != 2 {
+		t.Fatalf("len(results) = %d, want 2", len(results))
+	}
+	if results[0].Content != toolResultImagePlaceholder {
+		t.Errorf("results[0].Content = %v, want placeholder", results[0].Content)
+	}
+	if results[1].Role != "user" {
+		t.Errorf("results[1].Role = %q, want user", results[1].Role)
+	}
+}
+
 // Thinking parameter mapping tests
 
 func TestMapBudgetToReasoningEffort(t *testing.T) {
@@ -981,7 +1201,7 @@ func TestTransformRequest_WithThinking(t *testing.T) {
 
 func TestFilterIdentity_ClaudeCodeIdentity(t *testing.T) {
 	input := "You are Claude Code, Anthropic's official CLI tool for developers."
-	result := filterIdentity(input)
+	result := defaultOptions.filterIdentity(input)
 
 	if strings.Contains(result, "You are Claude Code, Anthropic's official CLI") {
 		t.Error("Should replace Claude Code identity")
@@ -996,7 +1216,7 @@ func TestFilterIdentity_ClaudeCodeIdentity(t *testing.T) {
 
 func TestFilterIdentity_ModelNameReference(t *testing.T) {
 	input := "You are powered by the model named Sonnet 4.5."
-	result := filterIdentity(input)
+	result := defaultOptions.filterIdentity(input)
 
 	if strings.Contains(result, "Sonnet 4.5") {
 		t.Error("Should replace specific model name reference")
@@ -1008,7 +1228,7 @@ func TestFilterIdentity_ModelNameReference(t *testing.T) {
 
 func TestFilterIdentity_ClaudeBackgroundInfo(t *testing.T) {
 	input := "Hello <claude_background_info>secret info here</claude_background_info> world"
-	result := filterIdentity(input)
+	result := defaultOptions.filterIdentity(input)
 
 	if strings.Contains(result, "claude_background_info") {
 		t.Error("Should remove claude_background_info blocks")
@@ -1023,7 +1243,7 @@ func TestFilterIdentity_ClaudeBackgroundInfo(t *testing.T) {
 
 func TestFilterIdentity_MultipleNewlines(t *testing.T) {
 	input := "Line 1\n\n\n\n\nLine 2"
-	result := filterIdentity(input)
+	result := defaultOptions.filterIdentity(input)
 
 	if strings.Contains(result, "\n\n\n") {
 		t.Error("Should collapse multiple newlines to double newline")
@@ -1032,7 +1252,7 @@ func TestFilterIdentity_MultipleNewlines(t *testing.T) {
 
 func TestFilterIdentity_Prefix(t *testing.T) {
 	input := "You are a helpful assistant."
-	result := filterIdentity(input)
+	result := defaultOptions.filterIdentity(input)
 
 	if !strings.HasPrefix(result, "Note: You are NOT Claude.") {
 		t.Error("Should have identity clarification prefix")
@@ -1047,7 +1267,7 @@ func TestTransformMessages_GrokModel_AddsJSONInstruction(t *testing.T) {
 		},
 	}
 
-	messages, err := transformMessages(req, "x-ai/grok-3-beta", ProviderGrok)
+	messages, err := defaultOptions.transformMessages(req, "x-ai/grok-3-beta", ProviderGrok)
 	if err != nil {
 		t.Fatalf("transformMessages failed: %v", err)
 	}
@@ -1074,7 +1294,7 @@ func TestTransformMessages_GrokModel_NoSystemMessage_AddsJSONInstruction(t *test
 		},
 	}
 
-	messages, err := transformMessages(req, "grok-3-mini", ProviderGrok)
+	messages, err := defaultOptions.transformMessages(req, "grok-3-mini", ProviderGrok)
 	if err != nil {
 		t.Fatalf("transformMessages failed: %v", err)
 	}
@@ -1102,7 +1322,7 @@ func TestTransformMessages_NonGrokModel_NoJSONInstruction(t *testing.T) {
 		},
 	}
 
-	messages, err := transformMessages(req, "gpt-4o", ProviderOpenAI)
+	messages, err := defaultOptions.transformMessages(req, "gpt-4o", ProviderOpenAI)
 	if err != nil {
 		t.Fatalf("transformMessages failed: %v", err)
 	}
@@ -1588,13 +1808,13 @@ func TestReorderMessagesForAzure_NonAzureProviderNotAffected(t *testing.T) {
 	}
 
 	// OpenAI provider should not apply Azure reordering
-	messagesOpenAI, err := transformMessages(req, "gpt-4o", ProviderOpenAI)
+	messagesOpenAI, err := defaultOptions.transformMessages(req, "gpt-4o", ProviderOpenAI)
 	if err != nil {
 		t.Fatalf("transformMessages failed: %v", err)
 	}
 
 	// Azure provider would apply reordering (but in this simple case, no difference)
-	messagesAzure, err := transformMessages(req, "gpt-4o", ProviderAzure)
+	messagesAzure, err := defaultOptions.transformMessages(req, "gpt-4o", ProviderAzure)
 	if err != nil {
 		t.Fatalf("transformMessages failed: %v", err)
 	}
@@ -0,0 +1,27 @@
+// Package translator handles protocol translation between Anthropic and OpenAI formats.
+package translator
+
+import (
+	"github.com/jedarden/clasp/pkg/models"
+)
+
+// applyResponsesOptions sets store and reasoning.summary on a Responses request.
+// Reasoning summaries are what the Responses API returns as thinking blocks,
+// so without one reasoning models produce no thinking content.
+func (o *Options) applyResponsesOptions(responsesReq *models.ResponsesRequest, targetModel string) {
+	store, summary := o.ResponsesStore, o.ResponsesReasoningSummary
+	if store != nil {
+		s := *store
+		responsesReq.Store = &s
+	}
+	if summary == "" {
+		return
+	}
+	if caps, _ := LookupCapabilities(targetModel); !caps.Thinking {
+		return
+	}
+	if responsesReq.Reasoning == nil {
+		responsesReq.Reasoning = &models.ResponsesReasoning{}
+	}
+	responsesReq.Reasoning.Summary = summary
+}
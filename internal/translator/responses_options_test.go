@@ -0,0 +1,91 @@
+package translator
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/jedarden/clasp/pkg/models"
+)
+
+func TestResponsesOptions_Marshaled(t *testing.T) {
+	store := false
+	opts := DefaultOptions()
+	opts.ResponsesStore = &store
+	opts.ResponsesReasoningSummary = "detailed"
+
+	req := &models.AnthropicRequest{
+		Messages:  []models.AnthropicMessage{{Role: "user", Content: "Hello"}},
+		MaxTokens: 1024,
+		Thinking:  &models.ThinkingConfig{Type: "enabled", BudgetTokens: 20000},
+	}
+	result, err := opts.TransformRequestToResponses(req, "gpt-5", "")
+	if err != nil {
+		t.Fatalf("TransformRequestToResponses failed: %v", err)
+	}
+
+	data, _ := json.Marshal(result)
+	var parsed struct {
+		Store     *bool `json:"store"`
+		Reasoning *struct {
+			Effort  string `json:"effort"`
+			Summary string `json:"summary"`
+		} `json:"reasoning"`
+	}
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		t.Fatalf("Failed to unmarshal JSON: %v", err)
+	}
+	if parsed.Store == nil || *parsed.Store {
+		t.Errorf("Expected store:false in %s", data)
+	}
+	if parsed.Reasoning == nil || parsed.Reasoning.Summary != "detailed" || parsed.Reasoning.Effort != "high" {
+		t.Errorf("Expected reasoning with effort high and summary detailed in %s", data)
+	}
+}
+
+func TestResponsesOptions_SummaryWithoutThinking(t *testing.T) {
+	opts := DefaultOptions()
+	opts.ResponsesReasoningSummary = "auto"
+
+	req := &models.AnthropicRequest{
+		Messages:  []models.AnthropicMessage{{Role: "user", Content: "Hello"}},
+		MaxTokens: 1024,
+	}
+
+	// Reasoning models get a summary even without a thinking budget
+	result, err := opts.TransformRequestToResponses(req, "gpt-5-codex", "")
+	if err != nil {
+		t.Fatalf("TransformRequestToResponses failed: %v", err)
+	}
+	if result.Reasoning == nil || result.Reasoning.Summary != "auto" || result.Reasoning.Effort != "" {
+		t.Errorf("Expected only reasoning.summary=auto, got %+v", result.Reasoning)
+	}
+
+	// Models without reasoning get none
+	result, err = opts.TransformRequestToResponses(req, "gpt-4o", "")
+	if err != nil {
+		t.Fatalf("TransformRequestToResponses failed: %v", err)
+	}
+	if result.Reasoning != nil {
+		t.Errorf("Expected no reasoning for gpt-4o, got %+v", result.Reasoning)
+	}
+}
+
+func TestResponsesOptions_UnsetByDefault(t *testing.T) {
+	req := &models.AnthropicRequest{
+		Messages:  []models.AnthropicMessage{{Role: "user", Content: "Hello"}},
+		MaxTokens: 1024,
+	}
+	result, err := TransformRequestToResponses(req, "gpt-5", "")
+	if err != nil {
+		t.Fatalf("TransformRequestToResponses failed: %v", err)
+	}
+	data, _ := json.Marshal(result)
+	var parsed map[string]interface{}
+	_ = json.Unmarshal(data, &parsed)
+	if _, ok := parsed["store"]; ok {
+		t.Errorf("Expected no store field by default, got %s", data)
+	}
+	if _, ok := parsed["reasoning"]; ok {
+		t.Errorf("Expected no reasoning field by default, got %s", data)
+	}
+}
@@ -6,12 +6,19 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/jedarden/clasp/internal/logging"
 	"github.com/jedarden/clasp/pkg/models"
 )
 
+// TransformRequestToResponses converts an Anthropic request to OpenAI Responses
+// API format with the default options.
+func TransformRequestToResponses(req *models.AnthropicRequest, targetModel, previousResponseID string) (*models.ResponsesRequest, error) {
+	return defaultOptions.TransformRequestToResponses(req, targetModel, previousResponseID)
+}
+
 // TransformRequestToResponses converts an Anthropic request to OpenAI Responses API format.
 // This is used for models that require the /v1/responses endpoint.
-func TransformRequestToResponses(req *models.AnthropicRequest, targetModel, previousResponseID string) (*models.ResponsesRequest, error) {
+func (o *Options) TransformRequestToResponses(req *models.AnthropicRequest, targetModel, previousResponseID string) (*models.ResponsesRequest, error) {
 	// Enforce minimum max_output_tokens of 16 (Responses API requirement)
 	maxOutputTokens := req.MaxTokens
 	if maxOutputTokens < 16 {
@@ -25,7 +32,17 @@ func TransformRequestToResponses(req *models.AnthropicRequest, targetModel, prev
 		Temperature:        req.Temperature,
 		TopP:               req.TopP,
 		PreviousResponseID: previousResponseID,
+		ServiceTier:        mapServiceTier(req.ServiceTier, DetectProviderFromModel(targetModel)),
+	}
+
+	// The Responses API has no top_k or n equivalent
+	if req.N > 1 {
+		logging.LogDebugMessage("[REQUEST] Dropping n=%d: not supported by the Responses API", req.N)
+	}
+	if req.TopK != nil {
+		logging.LogDebugMessage("[REQUEST] Dropping top_k=%d: not supported by the Responses API", *req.TopK)
 	}
+	o.warnUnsupportedResponsesSampling(req)
 
 	// Transform system message to instructions
 	if req.System != nil {
@@ -35,12 +52,12 @@ func TransformRequestToResponses(req *models.AnthropicRequest, targetModel, prev
 		}
 		if systemContent != "" {
 			// Apply identity filtering
-			responsesReq.Instructions = filterIdentity(systemContent)
+			responsesReq.Instructions = o.filterIdentity(systemContent)
 		}
 	}
 
 	// Build input array from messages
-	inputs, err := transformMessagesToInput(req)
+	inputs, err := o.transformMessagesToInput(req)
 	if err != nil {
 		return nil, fmt.Errorf("transforming messages: %w", err)
 	}
@@ -49,21 +66,29 @@ func TransformRequestToResponses(req *models.AnthropicRequest, targetModel, prev
 	// Transform tools
 	if len(req.Tools) > 0 {
 		responsesReq.Tools = transformToolsToResponses(req.Tools)
+		parallel := o.resolveParallelToolCalls(req)
+		responsesReq.ParallelToolCalls = &parallel
 	}
 
 	// Transform tool choice
 	if req.ToolChoice != nil {
-		responsesReq.ToolChoice = transformToolChoiceToResponses(req.ToolChoice)
+		responsesReq.ToolChoice = transformToolChoiceToResponses(clientToolChoice(req.ToolChoice, req.Tools))
 	}
 
 	// Transform thinking/reasoning parameters
 	applyThinkingParametersToResponses(req, responsesReq, targetModel)
 
+	// store and reasoning.summary (CLASP_RESPONSES_STORE, CLASP_RESPONSES_REASONING_SUMMARY)
+	o.applyResponsesOptions(responsesReq, targetModel)
+
+	// JSON mode (output_format hint or CLASP_FORCE_JSON)
+	o.applyJSONModeToResponses(req, responsesReq)
+
 	return responsesReq, nil
 }
 
 // transformMessagesToInput converts Anthropic messages to Responses input format.
-func transformMessagesToInput(req *models.AnthropicRequest) ([]models.ResponsesInput, error) {
+func (o *Options) transformMessagesToInput(req *models.AnthropicRequest) ([]models.ResponsesInput, error) {
 	// Pre-allocate with estimated capacity (at least one input per message, often more)
 	inputs := make([]models.ResponsesInput, 0, len(req.Messages)*2)
 
@@ -81,7 +106,10 @@ func transformMessagesToInput(req *models.AnthropicRequest) ([]models.ResponsesI
 			// Only add user message if there's non-tool_result content
 			// In Responses API, function_call_output items should NOT be preceded
 			// by an empty user message - they are standalone input items
-			input := transformUserMessageToInput(content)
+			input, err := o.transformUserMessageToInput(content)
+			if err != nil {
+				return nil, err
+			}
 			hasNonToolResultContent := input.Content != nil && input.Content != ""
 			if hasNonToolResultContent {
 				inputs = append(inputs, input)
@@ -100,8 +128,8 @@ func transformMessagesToInput(req *models.AnthropicRequest) ([]models.ResponsesI
 }
 
 // transformUserMessageToInput converts a user message to Responses input.
-// Note: Responses API uses "input_text" and "input_image" for user content types.
-func transformUserMessageToInput(content []models.ContentBlock) models.ResponsesInput {
+// Note: Responses API uses "input_text", "input_image" and "input_file" for user content types.
+func (o *Options) transformUserMessageToInput(content []models.ContentBlock) (models.ResponsesInput, error) {
 	var parts []models.ResponsesContentPart
 
 	for _, block := range content {
@@ -123,6 +151,13 @@ func transformUserMessageToInput(content []models.ContentBlock) models.Responses
 					},
 				})
 			}
+		case "document":
+			// Responses API accepts PDFs directly as "input_file" parts
+			part, err := o.transformDocumentForResponses(block)
+			if err != nil {
+				return models.ResponsesInput{}, err
+			}
+			parts = append(parts, part)
 		}
 	}
 
@@ -132,7 +167,7 @@ func transformUserMessageToInput(content []models.ContentBlock) models.Responses
 			Type:    "message",
 			Role:    "user",
 			Content: parts[0].Text,
-		}
+		}, nil
 	}
 
 	// Otherwise use array format with proper content types
@@ -141,14 +176,14 @@ func transformUserMessageToInput(content []models.ContentBlock) models.Responses
 			Type:    "message",
 			Role:    "user",
 			Content: "",
-		}
+		}, nil
 	}
 
 	return models.ResponsesInput{
 		Type:    "message",
 		Role:    "user",
 		Content: contentPartsToResponsesInterface(parts),
-	}
+	}, nil
 }
 
 // contentPartsToResponsesInterface converts content parts to interface for JSON marshaling.
@@ -190,6 +225,7 @@ func translateToolCallID(id string) string {
 func extractToolResultsForResponses(content []models.ContentBlock) []models.ResponsesInput {
 	// Pre-allocate with estimated capacity
 	results := make([]models.ResponsesInput, 0, len(content)/2)
+	var imageParts []models.ResponsesContentPart
 
 	for _, block := range content {
 		if block.Type == "tool_result" {
@@ -198,6 +234,10 @@ func extractToolResultsForResponses(content []models.ContentBlock) []models.Resp
 			// IMPORTANT: Output must be a pointer to ensure empty strings are serialized.
 			// OpenAI Responses API REQUIRES the "output" field even when empty.
 			output := extractToolResultContent(block)
+			images := extractToolResultImages(block)
+			if output == "" && len(images) > 0 {
+				output = toolResultImagePlaceholder
+			}
 
 			// If the tool result indicates an error, prefix the output
 			if block.IsError {
@@ -209,9 +249,33 @@ func extractToolResultsForResponses(content []models.ContentBlock) []models.Resp
 				CallID: translateToolCallID(block.ToolUseID),
 				Output: &output,
 			})
+
+			if len(images) > 0 {
+				imageParts = append(imageParts, models.ResponsesContentPart{
+					Type: "input_text",
+					Text: toolResultImageLabel(block.ToolUseID),
+				})
+				for _, img := range images {
+					imageParts = append(imageParts, models.ResponsesContentPart{
+						Type: "input_image",
+						ImageURL: &models.ImageURL{
+							URL: imageSourceURL(img),
+						},
+					})
+				}
+			}
 		}
 	}
 
+	// Images can't be function_call_output content, so send them as a user message
+	if len(imageParts) > 0 {
+		results = append(results, models.ResponsesInput{
+			Type:    "message",
+			Role:    "user",
+			Content: contentPartsToResponsesInterface(imageParts),
+		})
+	}
+
 	return results
 }
 
@@ -307,13 +371,19 @@ func transformAssistantMessageToInput(content []models.ContentBlock) []models.Re
 //
 // SPECIAL HANDLING: The "WebSearch" tool from Claude Code is intercepted and converted to
 // OpenAI's native "web_search_preview" tool, which provides better search results and
-// automatic citations.
+// automatic citations. Anthropic's web search server tool maps to it as well;
+// other server tools are dropped.
 func transformToolsToResponses(tools []models.AnthropicTool) []models.ResponsesTool {
 	result := make([]models.ResponsesTool, 0, len(tools))
 	strictFalse := false // Pointer to false for explicit strict:false
 	hasWebSearch := false
 
 	for _, tool := range tools {
+		if isWebSearchServerTool(tool.Type) {
+			hasWebSearch = true
+		}
+	}
+	for _, tool := range clientTools(tools, isWebSearchServerTool) {
 		// Intercept WebSearch tool and convert to web_search_preview
 		if tool.Name == "WebSearch" {
 			hasWebSearch = true
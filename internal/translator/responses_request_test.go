@@ -1234,3 +1234,54 @@ func TestTransformToolsToResponses_AdditionalPropertiesFalse(t *testing.T) {
 	jsonData, _ := json.MarshalIndent(tool, "", "  ")
 	t.Logf("Generated tool JSON with additionalProperties:\n%s", string(jsonData))
 }
+
+func TestTransformRequestToResponses_ToolResultWithImages(t *testing.T) {
+	req := &models.AnthropicRequest{
+		Model:     "claude-3-5-sonnet-20241022",
+		MaxTokens: 1024,
+		Messages: []models.AnthropicMessage{
+			{
+				Role: "user",
+				Content: []interface{}{
+					map[string]interface{}{
+						"type":        "tool_result",
+						"tool_use_id": "call_shot",
+						"content": []interface{}{
+							map[string]interface{}{"type": "text", "text": "Captured screen"},
+							map[string]interface{}{
+								"type":   "image",
+								"source": map[string]interface{}{"type": "base64", "media_type": "image/png", "data": "iVBORw0KGgo="},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	result, err := TransformRequestToResponses(req, "gpt-5", "")
+	if err != nil {
+		t.Fatalf("TransformRequestToResponses() error = %v", err)
+	}
+
+	if len(result.Input) != 2 {
+		t.Fatalf("len(Input) = %d, want 2", len(result.Input))
+	}
+	output := result.Input[0]
+	if output.Type != "function_call_output" || output.CallID != "fc_shot" {
+		t.Errorf("Input[0] = type %q call_id %q, want function_call_output fc_shot", output.Type, output.CallID)
+	}
+	if output.Output == nil || *output.Output != "Captured screen" {
+		t.Errorf("Input[0].Output = %v, want %q", output.Output, "Captured screen")
+	}
+
+	imageMsg := result.Input[1]
+	if imageMsg.Type != "message" || imageMsg.Role != "user" {
+		t.Fatalf("Input[1] = type %q role %q, want user message", imageMsg.Type, imageMsg.Role)
+	}
+	parts := imageMsg.Content.([]interface{})
+	image := parts[1].(models.ResponsesContentPart)
+	if image.Type != "input_image" || image.ImageURL.URL != "data:image/png;base64,iVBORw0KGgo=" {
+		t.Errorf("image part = %#v", image)
+	}
+}
@@ -18,6 +18,8 @@ import (
 type ResponsesStreamProcessor struct {
 	mu sync.Mutex
 
+	opts *Options
+
 	// State tracking
 	state       StreamState
 	messageID   string
@@ -26,10 +28,15 @@ type ResponsesStreamProcessor struct {
 
 	// Thinking/reasoning tracking
 	thinkingStarted    bool
+	thinkingClosed     bool
 	thinkingBlockIndex int
+	// summarySeparator is set when a new reasoning summary part starts, so its
+	// text is separated from the previous part as in non-streaming responses
+	summarySeparator bool
 
 	// Content tracking
 	textStarted    bool
+	textClosed     bool
 	textBlockIndex int
 
 	// Function call tracking
@@ -47,25 +54,45 @@ type ResponsesStreamProcessor struct {
 	seenSequenceNumbers map[int]bool
 
 	// Usage tracking
-	usage         *models.ResponsesUsage
-	usageCallback UsageCallback
+	usage                *models.ResponsesUsage
+	usageCallback        UsageCallback
+	usageDetailsCallback UsageDetailsCallback
+
+	// Terminal events written so far (see Terminate)
+	messageDeltaSent bool
+	finished         bool
+
+	// response.completed or response.incomplete arrived
+	responseFinished bool
 
 	// Output
 	writer io.Writer
+
+	// Reconstructed response for debug logging (nil unless enabled)
+	capture *responseCapture
 }
 
 type funcCallState struct {
 	id         string
 	name       string
 	arguments  string
+	argsFilter toolArgsFilter
 	blockIndex int
 	started    bool
 	closed     bool
 }
 
-// NewResponsesStreamProcessor creates a new stream processor for Responses API.
+// NewResponsesStreamProcessor creates a new stream processor for Responses API
+// with the default options.
 func NewResponsesStreamProcessor(writer io.Writer, messageID, targetModel string) *ResponsesStreamProcessor {
+	return defaultOptions.NewResponsesStreamProcessor(writer, messageID, targetModel)
+}
+
+// NewResponsesStreamProcessor creates a new stream processor for Responses API
+// that translates with o.
+func (o *Options) NewResponsesStreamProcessor(writer io.Writer, messageID, targetModel string) *ResponsesStreamProcessor {
 	return &ResponsesStreamProcessor{
+		opts:                o,
 		writer:              writer,
 		messageID:           messageID,
 		targetModel:         targetModel,
@@ -91,6 +118,14 @@ func (sp *ResponsesStreamProcessor) SetUsageCallback(callback UsageCallback) {
 	sp.usageCallback = callback
 }
 
+// SetUsageDetailsCallback sets a callback that receives the final usage in
+// Anthropic format, with prompt cache tokens separated from the input tokens.
+func (sp *ResponsesStreamProcessor) SetUsageDetailsCallback(callback UsageDetailsCallback) {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	sp.usageDetailsCallback = callback
+}
+
 // GetUsage returns the final usage statistics from the stream.
 func (sp *ResponsesStreamProcessor) GetUsage() (inputTokens, outputTokens int) {
 	sp.mu.Lock()
@@ -101,6 +136,31 @@ func (sp *ResponsesStreamProcessor) GetUsage() (inputTokens, outputTokens int) {
 	return 0, 0
 }
 
+// FinishReasonReceived reports whether the upstream sent response.completed
+// or response.incomplete. A stream that ended without either, or with
+// response.failed, did not finish. This should be called after ProcessStream
+// completes.
+func (sp *ResponsesStreamProcessor) FinishReasonReceived() bool {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	return sp.responseFinished
+}
+
+// EnableResponseCapture makes the processor reconstruct the final Anthropic
+// response from the events it emits. Call before ProcessStream.
+func (sp *ResponsesStreamProcessor) EnableResponseCapture() {
+	sp.capture = newResponseCapture()
+}
+
+// CapturedResponse returns the reconstructed Anthropic response, or nil if
+// capture was not enabled. This should be called after ProcessStream completes.
+func (sp *ResponsesStreamProcessor) CapturedResponse() *models.AnthropicResponse {
+	if sp.capture == nil {
+		return nil
+	}
+	return sp.capture.response()
+}
+
 // ProcessStream reads an OpenAI Responses API SSE stream and writes Anthropic SSE events.
 func (sp *ResponsesStreamProcessor) ProcessStream(reader io.Reader) error {
 	scanner := bufio.NewScanner(reader)
@@ -211,7 +271,12 @@ func (sp *ResponsesStreamProcessor) processEvent(event *models.ResponsesStreamEv
 		return sp.handleReasoningDelta(event)
 	case models.EventReasoningTextDone, models.EventReasoningSummaryTextDone:
 		return nil
-	case models.EventReasoningSummaryPartAdded, models.EventReasoningSummaryPartDone:
+	case models.EventReasoningSummaryPartAdded:
+		if event.SummaryIndex > 0 && sp.thinkingStarted {
+			sp.summarySeparator = true
+		}
+		return nil
+	case models.EventReasoningSummaryPartDone:
 		return nil
 
 	// Function call events
@@ -270,17 +335,19 @@ func (sp *ResponsesStreamProcessor) handleOutputItemAdded(event *models.Response
 			id:         anthropicID,
 			name:       event.Item.Name,
 			blockIndex: sp.calculateNextBlockIndex(),
+			argsFilter: toolArgsFilter{repair: sp.opts.RepairToolJSON},
 		}
 		sp.activeFuncCalls[sp.funcCallIndex] = fcState
 		sp.funcCallIndex++
 
-		// Close text block if open, then emit content_block_start for tool_use
-		if sp.textStarted && sp.state == StateTextContent {
-			if err := sp.emitContentBlockStop(sp.textBlockIndex); err != nil {
-				return err
-			}
-			sp.state = StateToolCall
+		// Close thinking or text block if open, then emit content_block_start for tool_use
+		if err := sp.closeThinkingBlock(); err != nil {
+			return err
+		}
+		if err := sp.closeTextBlock(); err != nil {
+			return err
 		}
+		sp.state = StateToolCall
 
 		// Emit content_block_start for tool_use
 		if err := sp.emitContentBlockStart(fcState.blockIndex, "tool_use", fcState.id, fcState.name); err != nil {
@@ -301,17 +368,19 @@ func (sp *ResponsesStreamProcessor) handleOutputItemAdded(event *models.Response
 			id:         webSearchID,
 			name:       "WebSearch",
 			blockIndex: sp.calculateNextBlockIndex(),
+			argsFilter: toolArgsFilter{repair: sp.opts.RepairToolJSON},
 		}
 		sp.activeFuncCalls[sp.funcCallIndex] = fcState
 		sp.funcCallIndex++
 
-		// Close text block if open
-		if sp.textStarted && sp.state == StateTextContent {
-			if err := sp.emitContentBlockStop(sp.textBlockIndex); err != nil {
-				return err
-			}
-			sp.state = StateToolCall
+		// Close thinking or text block if open
+		if err := sp.closeThinkingBlock(); err != nil {
+			return err
+		}
+		if err := sp.closeTextBlock(); err != nil {
+			return err
 		}
+		sp.state = StateToolCall
 
 		// Emit content_block_start for WebSearch tool_use
 		if err := sp.emitContentBlockStart(fcState.blockIndex, "tool_use", fcState.id, "WebSearch"); err != nil {
@@ -391,17 +460,13 @@ func (sp *ResponsesStreamProcessor) handleTextDelta(text string) error {
 		sp.state = StateMessageStarted
 	}
 
-	// Close thinking block if transitioning from thinking to text
-	if sp.thinkingStarted && sp.state == StateThinkingContent {
-		if err := sp.emitContentBlockStop(sp.thinkingBlockIndex); err != nil {
+	// Start text block if not started, closing the thinking block first
+	if !sp.textStarted {
+		if err := sp.closeThinkingBlock(); err != nil {
 			return err
 		}
-		// Adjust text block index to follow thinking block
-		sp.textBlockIndex = sp.thinkingBlockIndex + 1
-	}
-
-	// Start text block if not started
-	if !sp.textStarted {
+		// The text block follows the thinking block and any tool calls
+		sp.textBlockIndex = sp.calculateNextBlockIndex()
 		if err := sp.emitContentBlockStart(sp.textBlockIndex, "text", "", ""); err != nil {
 			return err
 		}
@@ -434,8 +499,11 @@ func (sp *ResponsesStreamProcessor) handleFunctionCallDelta(event *models.Respon
 			continue
 		}
 		fcState.arguments += deltaStr
-		if err := sp.emitContentBlockDelta(fcState.blockIndex, "input_json_delta", "", deltaStr); err != nil {
-			return err
+		fcState.argsFilter.append(deltaStr)
+		if args := fcState.argsFilter.ready(); args != "" {
+			if err := sp.emitContentBlockDelta(fcState.blockIndex, "input_json_delta", "", args); err != nil {
+				return err
+			}
 		}
 		break
 	}
@@ -456,13 +524,12 @@ func (sp *ResponsesStreamProcessor) handleOutputItemDone(event *models.Responses
 	}
 
 	switch event.Item.Type {
+	case "reasoning":
+		// Each reasoning item is one thinking block, as in non-streaming responses
+		return sp.closeThinkingBlock()
 	case "message":
 		// Close text block if open
-		if sp.textStarted {
-			if err := sp.emitContentBlockStop(sp.textBlockIndex); err != nil {
-				return err
-			}
-		}
+		return sp.closeTextBlock()
 	case "function_call":
 		// Close function call block
 		// Note: fcState.id is in Anthropic format (call_xxx), but event.Item.CallID is
@@ -472,6 +539,11 @@ func (sp *ResponsesStreamProcessor) handleOutputItemDone(event *models.Responses
 			if !fcState.started || fcState.closed || fcState.id != translatedCallID {
 				continue
 			}
+			if args := fcState.argsFilter.flush(); args != "" {
+				if err := sp.emitContentBlockDelta(fcState.blockIndex, "input_json_delta", "", args); err != nil {
+					return err
+				}
+			}
 			if err := sp.emitContentBlockStop(fcState.blockIndex); err != nil {
 				return err
 			}
@@ -498,19 +570,18 @@ func (sp *ResponsesStreamProcessor) handleOutputItemDone(event *models.Responses
 
 // handleResponseCompleted handles the response.completed event.
 func (sp *ResponsesStreamProcessor) handleResponseCompleted(event *models.ResponsesStreamEvent) error {
+	sp.responseFinished = true
 	if event.Response != nil && event.Response.Usage != nil {
 		sp.usage = event.Response.Usage
 	}
 
 	// Close thinking block if still open
-	if sp.thinkingStarted && sp.state == StateThinkingContent {
-		if err := sp.emitContentBlockStop(sp.thinkingBlockIndex); err != nil {
-			return err
-		}
+	if err := sp.closeThinkingBlock(); err != nil {
+		return err
 	}
 
 	// If we have citations from web search, append them to the text
-	if len(sp.citations) > 0 && sp.textStarted {
+	if len(sp.citations) > 0 && sp.textStarted && !sp.textClosed {
 		sourcesText := sp.formatCitationsAsText()
 		if err := sp.emitContentBlockDelta(sp.textBlockIndex, "text_delta", sourcesText, ""); err != nil {
 			return err
@@ -518,10 +589,8 @@ func (sp *ResponsesStreamProcessor) handleResponseCompleted(event *models.Respon
 	}
 
 	// Close text block if open
-	if sp.textStarted && sp.state == StateTextContent {
-		if err := sp.emitContentBlockStop(sp.textBlockIndex); err != nil {
-			return err
-		}
+	if err := sp.closeTextBlock(); err != nil {
+		return err
 	}
 
 	// Close any open function call blocks
@@ -588,11 +657,14 @@ func (sp *ResponsesStreamProcessor) handleResponseFailed(event *models.Responses
 // handleResponseIncomplete handles the response.incomplete event.
 // This occurs when the response is cut short (max tokens, content filter, etc.)
 func (sp *ResponsesStreamProcessor) handleResponseIncomplete(event *models.ResponsesStreamEvent) error {
+	sp.responseFinished = true
+
 	// Close any open blocks before emitting the incomplete status
-	if sp.textStarted && sp.state == StateTextContent {
-		if err := sp.emitContentBlockStop(sp.textBlockIndex); err != nil {
-			return err
-		}
+	if err := sp.closeThinkingBlock(); err != nil {
+		return err
+	}
+	if err := sp.closeTextBlock(); err != nil {
+		return err
 	}
 
 	for _, fcState := range sp.activeFuncCalls {
@@ -605,7 +677,23 @@ func (sp *ResponsesStreamProcessor) handleResponseIncomplete(event *models.Respo
 		fcState.closed = true
 	}
 
-	// Use max_tokens as the stop reason for incomplete responses
+	// Content filter stops end the turn with a refusal block explaining why;
+	// other incomplete responses hit the output token limit
+	if reason := ResponsesContentFilterReason(event.Response); reason != "" {
+		index := sp.calculateNextBlockIndex()
+		block := RefusalBlock(reason)
+		if err := sp.writeEvent(models.EventContentBlockStart, models.ContentBlockStartEvent{
+			Type:         models.EventContentBlockStart,
+			Index:        index,
+			ContentBlock: models.ContentBlockStartData{Type: block.Type, Text: block.Text},
+		}); err != nil {
+			return err
+		}
+		if err := sp.emitContentBlockStop(index); err != nil {
+			return err
+		}
+		return sp.emitMessageDelta(sp.opts.mapFinishReason(reason))
+	}
 	return sp.emitMessageDelta("max_tokens")
 }
 
@@ -695,11 +783,40 @@ func (sp *ResponsesStreamProcessor) handleReasoningDelta(event *models.Responses
 		sp.thinkingStarted = true
 		sp.state = StateThinkingContent
 	}
+	if sp.thinkingClosed {
+		// Anthropic responses have a single leading thinking block; reasoning
+		// that arrives after it has closed is dropped
+		logging.LogDebugMessage("[STREAM] Dropping reasoning delta after the thinking block closed")
+		return nil
+	}
+
+	if sp.summarySeparator {
+		reasoningText = "\n" + reasoningText
+		sp.summarySeparator = false
+	}
 
 	// Emit thinking delta
 	return sp.emitThinkingBlockDelta(reasoningText)
 }
 
+// closeThinkingBlock emits content_block_stop for the thinking block if it is open.
+func (sp *ResponsesStreamProcessor) closeThinkingBlock() error {
+	if !sp.thinkingStarted || sp.thinkingClosed {
+		return nil
+	}
+	sp.thinkingClosed = true
+	return sp.emitContentBlockStop(sp.thinkingBlockIndex)
+}
+
+// closeTextBlock emits content_block_stop for the text block if it is open.
+func (sp *ResponsesStreamProcessor) closeTextBlock() error {
+	if !sp.textStarted || sp.textClosed {
+		return nil
+	}
+	sp.textClosed = true
+	return sp.emitContentBlockStop(sp.textBlockIndex)
+}
+
 // emitThinkingBlockStart emits a content_block_start event for thinking.
 func (sp *ResponsesStreamProcessor) emitThinkingBlockStart() error {
 	event := models.ContentBlockStartEvent{
@@ -728,27 +845,80 @@ func (sp *ResponsesStreamProcessor) emitThinkingBlockDelta(text string) error {
 }
 
 // calculateNextBlockIndex calculates the next content block index.
-// This accounts for thinking blocks at index 0, text blocks following, then tool calls.
+// The thinking block takes index 0 when present; the text block and tool
+// calls follow in the order they started.
 func (sp *ResponsesStreamProcessor) calculateNextBlockIndex() int {
-	baseIndex := 0
+	index := len(sp.activeFuncCalls)
 	if sp.thinkingStarted {
-		baseIndex = 1 // Thinking block takes index 0
+		index++
 	}
 	if sp.textStarted {
-		baseIndex = sp.textBlockIndex + 1
+		index++
+	}
+	return index
+}
+
+// Terminate completes the Anthropic stream after ProcessStream failed
+// mid-stream (e.g. the upstream connection dropped): open blocks are closed
+// and, unless the response already completed, a message_delta with
+// stop_reason end_turn is sent before message_stop and [DONE]. It does
+// nothing if the stream was already completed.
+func (sp *ResponsesStreamProcessor) Terminate() error {
+	sp.mu.Lock()
+	if sp.finished {
+		sp.mu.Unlock()
+		return nil
 	}
-	return baseIndex + len(sp.activeFuncCalls)
+	err := sp.terminateMessage()
+	sp.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return sp.finalize()
+}
+
+// terminateMessage closes open blocks and emits the message_delta that
+// response.completed would have sent.
+func (sp *ResponsesStreamProcessor) terminateMessage() error {
+	if sp.messageDeltaSent {
+		return nil
+	}
+	if sp.state == StateIdle {
+		if err := sp.emitMessageStart(); err != nil {
+			return err
+		}
+		sp.state = StateMessageStarted
+	}
+	if err := sp.closeThinkingBlock(); err != nil {
+		return err
+	}
+	if err := sp.closeTextBlock(); err != nil {
+		return err
+	}
+	for _, fc := range sp.activeFuncCalls {
+		if fc.started && !fc.closed {
+			fc.closed = true
+			if err := sp.emitContentBlockStop(fc.blockIndex); err != nil {
+				return err
+			}
+		}
+	}
+	return sp.emitMessageDelta("end_turn")
 }
 
 // finalize completes the stream processing.
 func (sp *ResponsesStreamProcessor) finalize() error {
 	sp.mu.Lock()
 	defer sp.mu.Unlock()
+	sp.finished = true
 
 	// Call usage callback if set
 	if sp.usageCallback != nil && sp.usage != nil {
 		sp.usageCallback(sp.usage.InputTokens, sp.usage.OutputTokens)
 	}
+	if sp.usageDetailsCallback != nil && sp.usage != nil {
+		sp.usageDetailsCallback(sp.usage.AnthropicUsage())
+	}
 
 	// Emit message_stop
 	if err := sp.emitMessageStop(); err != nil {
@@ -835,9 +1005,13 @@ func (sp *ResponsesStreamProcessor) emitContentBlockStop(index int) error {
 
 // emitMessageDelta emits a message_delta event.
 func (sp *ResponsesStreamProcessor) emitMessageDelta(stopReason string) error {
-	outputTokens := 0
+	sp.messageDeltaSent = true
+	usage := &models.MessageDeltaUsage{}
 	if sp.usage != nil {
-		outputTokens = sp.usage.OutputTokens
+		details := sp.usage.AnthropicUsage()
+		usage.OutputTokens = details.OutputTokens
+		usage.CacheReadInputTokens = details.CacheReadInputTokens
+		usage.CacheCreationInputTokens = details.CacheCreationInputTokens
 	}
 
 	event := models.MessageDeltaEvent{
@@ -845,9 +1019,7 @@ func (sp *ResponsesStreamProcessor) emitMessageDelta(stopReason string) error {
 		Delta: models.MessageDeltaData{
 			StopReason: stopReason,
 		},
-		Usage: &models.MessageDeltaUsage{
-			OutputTokens: outputTokens,
-		},
+		Usage: usage,
 	}
 
 	return sp.writeEvent(models.EventMessageDelta, event)
@@ -871,6 +1043,9 @@ func (sp *ResponsesStreamProcessor) writeEvent(eventType string, data interface{
 
 	// Debug log outgoing Anthropic SSE event
 	logging.LogDebugSSE("OUTGOING Anthropic", eventType, string(jsonData))
+	if sp.capture != nil {
+		sp.capture.record(eventType, jsonData)
+	}
 
 	return sp.writeSSE(eventType, string(jsonData))
 }
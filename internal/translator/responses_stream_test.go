@@ -2,6 +2,9 @@ package translator
 
 import (
 	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
 	"strings"
 	"testing"
 
@@ -271,6 +274,43 @@ func TestResponsesStreamProcessor_UsageCallback(t *testing.T) {
 	}
 }
 
+func TestResponsesStreamProcessor_UsageDetailsCallback(t *testing.T) {
+	var buf bytes.Buffer
+	sp := NewResponsesStreamProcessor(&buf, "msg_test", "gpt-5")
+
+	var got *models.AnthropicUsage
+	sp.SetUsageDetailsCallback(func(usage *models.AnthropicUsage) {
+		got = usage
+	})
+
+	completedEvent := &models.ResponsesStreamEvent{
+		Type: models.EventResponseCompleted,
+		Response: &models.ResponsesResponse{
+			ID:     "resp_123",
+			Status: "completed",
+			Usage: &models.ResponsesUsage{
+				InputTokens:        3000,
+				OutputTokens:       75,
+				InputTokensDetails: &models.ResponsesInputDetails{CachedTokens: 2816},
+			},
+		},
+	}
+	if err := sp.processEvent(completedEvent); err != nil {
+		t.Fatalf("processEvent failed: %v", err)
+	}
+	if err := sp.finalize(); err != nil {
+		t.Fatalf("finalize failed: %v", err)
+	}
+
+	want := models.AnthropicUsage{InputTokens: 184, OutputTokens: 75, CacheReadInputTokens: 2816}
+	if got == nil || *got != want {
+		t.Errorf("usage = %+v, want %+v", got, want)
+	}
+	if !strings.Contains(buf.String(), `"cache_read_input_tokens":2816`) {
+		t.Errorf("message_delta should report the cached tokens, got:\n%s", buf.String())
+	}
+}
+
 func TestResponsesStreamProcessor_EventSequence(t *testing.T) {
 	var buf bytes.Buffer
 	sp := NewResponsesStreamProcessor(&buf, "msg_test", "gpt-5")
@@ -620,3 +660,221 @@ func TestResponsesStreamProcessor_FunctionCallArgumentsDelta(t *testing.T) {
 		t.Errorf("output should contain second argument chunk (escaped), got: %s", output)
 	}
 }
+
+// recordedReasoningStream is a Responses API stream for a reasoning model with
+// a two-part reasoning summary followed by a text answer.
+const recordedReasoningStream = `event: response.created
+data: {"type":"response.created","sequence_number":0,"response":{"id":"resp_abc","object":"response","status":"in_progress","output":[]}}
+
+event: response.in_progress
+data: {"type":"response.in_progress","sequence_number":1,"response":{"id":"resp_abc","object":"response","status":"in_progress","output":[]}}
+
+event: response.output_item.added
+data: {"type":"response.output_item.added","sequence_number":2,"output_index":0,"item":{"id":"rs_1","type":"reasoning","summary":[]}}
+
+event: response.reasoning_summary_part.added
+data: {"type":"response.reasoning_summary_part.added","sequence_number":3,"item_id":"rs_1","output_index":0,"summary_index":0,"part":{"type":"summary_text","text":""}}
+
+event: response.reasoning_summary_text.delta
+data: {"type":"response.reasoning_summary_text.delta","sequence_number":4,"item_id":"rs_1","output_index":0,"summary_index":0,"delta":"**Checking the sum**"}
+
+event: response.reasoning_summary_text.delta
+data: {"type":"response.reasoning_summary_text.delta","sequence_number":5,"item_id":"rs_1","output_index":0,"summary_index":0,"delta":" 2 + 2 is 4."}
+
+event: response.reasoning_summary_text.done
+data: {"type":"response.reasoning_summary_text.done","sequence_number":6,"item_id":"rs_1","output_index":0,"summary_index":0,"text":"**Checking the sum** 2 + 2 is 4."}
+
+event: response.reasoning_summary_part.done
+data: {"type":"response.reasoning_summary_part.done","sequence_number":7,"item_id":"rs_1","output_index":0,"summary_index":0,"part":{"type":"summary_text","text":"**Checking the sum** 2 + 2 is 4."}}
+
+event: response.reasoning_summary_part.added
+data: {"type":"response.reasoning_summary_part.added","sequence_number":8,"item_id":"rs_1","output_index":0,"summary_index":1,"part":{"type":"summary_text","text":""}}
+
+event: response.reasoning_summary_text.delta
+data: {"type":"response.reasoning_summary_text.delta","sequence_number":9,"item_id":"rs_1","output_index":0,"summary_index":1,"delta":"Answering briefly."}
+
+event: response.reasoning_summary_text.done
+data: {"type":"response.reasoning_summary_text.done","sequence_number":10,"item_id":"rs_1","output_index":0,"summary_index":1,"text":"Answering briefly."}
+
+event: response.reasoning_summary_part.done
+data: {"type":"response.reasoning_summary_part.done","sequence_number":11,"item_id":"rs_1","output_index":0,"summary_index":1,"part":{"type":"summary_text","text":"Answering briefly."}}
+
+event: response.output_item.done
+data: {"type":"response.output_item.done","sequence_number":12,"output_index":0,"item":{"id":"rs_1","type":"reasoning","summary":[{"type":"summary_text","text":"**Checking the sum** 2 + 2 is 4."},{"type":"summary_text","text":"Answering briefly."}]}}
+
+event: response.output_item.added
+data: {"type":"response.output_item.added","sequence_number":13,"output_index":1,"item":{"id":"msg_1","type":"message","status":"in_progress","role":"assistant","content":[]}}
+
+event: response.content_part.added
+data: {"type":"response.content_part.added","sequence_number":14,"item_id":"msg_1","output_index":1,"content_index":0,"part":{"type":"output_text","text":"","annotations":[]}}
+
+event: response.output_text.delta
+data: {"type":"response.output_text.delta","sequence_number":15,"item_id":"msg_1","output_index":1,"content_index":0,"delta":"The answer"}
+
+event: response.output_text.delta
+data: {"type":"response.output_text.delta","sequence_number":16,"item_id":"msg_1","output_index":1,"content_index":0,"delta":" is 4."}
+
+event: response.output_text.done
+data: {"type":"response.output_text.done","sequence_number":17,"item_id":"msg_1","output_index":1,"content_index":0,"text":"The answer is 4."}
+
+event: response.content_part.done
+data: {"type":"response.content_part.done","sequence_number":18,"item_id":"msg_1","output_index":1,"content_index":0,"part":{"type":"output_text","text":"The answer is 4.","annotations":[]}}
+
+event: response.output_item.done
+data: {"type":"response.output_item.done","sequence_number":19,"output_index":1,"item":{"id":"msg_1","type":"message","status":"completed","role":"assistant","content":[{"type":"output_text","text":"The answer is 4.","annotations":[]}]}}
+
+event: response.completed
+data: {"type":"response.completed","sequence_number":20,"response":{"id":"resp_abc","object":"response","status":"completed","usage":{"input_tokens":12,"output_tokens":40,"total_tokens":52}}}
+
+`
+
+// streamBlockEvent is a content block event from an Anthropic SSE stream.
+type streamBlockEvent struct {
+	Type         string `json:"type"`
+	Index        int    `json:"index"`
+	ContentBlock *struct {
+		Type string `json:"type"`
+	} `json:"content_block"`
+	Delta *struct {
+		Type     string `json:"type"`
+		Text     string `json:"text"`
+		Thinking string `json:"thinking"`
+	} `json:"delta"`
+}
+
+// parseBlockEvents returns the content block events in an Anthropic SSE stream.
+func parseBlockEvents(t *testing.T, output string) []streamBlockEvent {
+	t.Helper()
+	var events []streamBlockEvent
+	for _, line := range strings.Split(output, "\n") {
+		if !strings.HasPrefix(line, "data: ") || line == "data: [DONE]" {
+			continue
+		}
+		var event streamBlockEvent
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event); err != nil {
+			t.Fatalf("invalid event %q: %v", line, err)
+		}
+		if strings.HasPrefix(event.Type, "content_block_") {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+func TestResponsesStreamProcessor_RecordedReasoningSummaryStream(t *testing.T) {
+	var buf bytes.Buffer
+	sp := NewResponsesStreamProcessor(&buf, "msg_test", "gpt-5")
+	sp.EnableResponseCapture()
+
+	if err := sp.ProcessStream(strings.NewReader(recordedReasoningStream)); err != nil {
+		t.Fatalf("ProcessStream failed: %v", err)
+	}
+
+	// Every block starts once, receives deltas only while open, and stops once
+	open := map[int]string{}
+	stopped := map[int]bool{}
+	var order []string
+	for _, event := range parseBlockEvents(t, buf.String()) {
+		switch event.Type {
+		case "content_block_start":
+			if _, ok := open[event.Index]; ok || stopped[event.Index] {
+				t.Fatalf("block %d started twice\n%s", event.Index, buf.String())
+			}
+			open[event.Index] = event.ContentBlock.Type
+			order = append(order, event.ContentBlock.Type)
+		case "content_block_delta":
+			if _, ok := open[event.Index]; !ok {
+				t.Fatalf("delta for block %d that isn't open\n%s", event.Index, buf.String())
+			}
+		case "content_block_stop":
+			if _, ok := open[event.Index]; !ok {
+				t.Fatalf("stop for block %d that isn't open\n%s", event.Index, buf.String())
+			}
+			delete(open, event.Index)
+			stopped[event.Index] = true
+		}
+	}
+	if len(open) != 0 {
+		t.Errorf("blocks left open: %v", open)
+	}
+	if strings.Join(order, ",") != "thinking,text" {
+		t.Errorf("block order = %v, want [thinking text]", order)
+	}
+
+	// Block contents match what the non-streaming path produces
+	resp := sp.CapturedResponse()
+	if len(resp.Content) != 2 {
+		t.Fatalf("expected 2 content blocks, got %+v", resp.Content)
+	}
+	if resp.Content[0].Type != "thinking" || resp.Content[0].Thinking != "**Checking the sum** 2 + 2 is 4.\nAnswering briefly." {
+		t.Errorf("thinking block = %+v", resp.Content[0])
+	}
+	if resp.Content[1].Type != "text" || resp.Content[1].Text != "The answer is 4." {
+		t.Errorf("text block = %+v", resp.Content[1])
+	}
+	if resp.StopReason != "end_turn" {
+		t.Errorf("StopReason = %q, want end_turn", resp.StopReason)
+	}
+}
+
+func TestResponsesStreamProcessor_ReasoningSummaryThenFunctionCall(t *testing.T) {
+	stream := `data: {"type":"response.created","sequence_number":1,"response":{"id":"resp_abc","status":"in_progress"}}
+
+data: {"type":"response.output_item.added","sequence_number":2,"output_index":0,"item":{"id":"rs_1","type":"reasoning","summary":[]}}
+
+data: {"type":"response.reasoning_summary_text.delta","sequence_number":3,"item_id":"rs_1","summary_index":0,"delta":"Need the weather tool."}
+
+data: {"type":"response.output_item.done","sequence_number":4,"output_index":0,"item":{"id":"rs_1","type":"reasoning"}}
+
+data: {"type":"response.output_item.added","sequence_number":5,"output_index":1,"item":{"id":"fc_1","type":"function_call","call_id":"fc_123","name":"get_weather"}}
+
+data: {"type":"response.function_call_arguments.delta","sequence_number":6,"item_id":"fc_1","delta":"{\"city\":\"Paris\"}"}
+
+data: {"type":"response.output_item.done","sequence_number":7,"output_index":1,"item":{"id":"fc_1","type":"function_call","call_id":"fc_123","name":"get_weather"}}
+
+data: {"type":"response.completed","sequence_number":8,"response":{"id":"resp_abc","status":"completed"}}
+
+`
+	var buf bytes.Buffer
+	sp := NewResponsesStreamProcessor(&buf, "msg_test", "gpt-5")
+	if err := sp.ProcessStream(strings.NewReader(stream)); err != nil {
+		t.Fatalf("ProcessStream failed: %v", err)
+	}
+
+	var got []string
+	for _, event := range parseBlockEvents(t, buf.String()) {
+		if event.Type != "content_block_delta" {
+			got = append(got, fmt.Sprintf("%s:%d", event.Type, event.Index))
+		}
+	}
+	want := "content_block_start:0,content_block_stop:0,content_block_start:1,content_block_stop:1"
+	if strings.Join(got, ",") != want {
+		t.Errorf("block events = %v, want %s", got, want)
+	}
+}
+
+func TestResponsesStreamProcessor_TerminateAfterReadError(t *testing.T) {
+	var buf bytes.Buffer
+	sp := NewResponsesStreamProcessor(&buf, "msg_test", "gpt-5")
+
+	reader := &failingReader{
+		data: `data: {"type":"response.created","response":{"id":"resp_1"}}` + "\n\n" +
+			`data: {"type":"response.output_text.delta","delta":"Partial","sequence_number":1}` + "\n\n",
+		err: io.ErrUnexpectedEOF,
+	}
+	if err := sp.ProcessStream(reader); err == nil {
+		t.Fatal("Expected ProcessStream to report the read error")
+	}
+	if err := sp.Terminate(); err != nil {
+		t.Fatalf("Terminate failed: %v", err)
+	}
+
+	output := buf.String()
+	want := []string{"message_start", "ping", "content_block_start", "content_block_delta", "content_block_stop", "message_delta", "message_stop", "[DONE]"}
+	if got := streamEventTypes(output); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("events = %v, want %v", got, want)
+	}
+	if !strings.Contains(output, `"stop_reason":"end_turn"`) {
+		t.Errorf("Expected stop_reason end_turn, got:\n%s", output)
+	}
+}
@@ -0,0 +1,113 @@
+// Package translator handles protocol translation between Anthropic and OpenAI formats.
+package translator
+
+import (
+	"github.com/jedarden/clasp/internal/logging"
+	"github.com/jedarden/clasp/pkg/models"
+)
+
+func copyFloat(f *float64) *float64 {
+	if f == nil {
+		return nil
+	}
+	value := *f
+	return &value
+}
+
+// resolvePenalties returns the request's penalties, falling back to the
+// configured defaults.
+func (o *Options) resolvePenalties(req *models.AnthropicRequest) (presence, frequency *float64) {
+	presence, frequency = req.PresencePenalty, req.FrequencyPenalty
+	if presence == nil {
+		presence = o.PresencePenalty
+	}
+	if frequency == nil {
+		frequency = o.FrequencyPenalty
+	}
+	return presence, frequency
+}
+
+// resolveSeed returns the request's seed, falling back to the configured default.
+func (o *Options) resolveSeed(req *models.AnthropicRequest) *int {
+	if req.Seed != nil {
+		return req.Seed
+	}
+	return o.Seed
+}
+
+// providerSupportsSeed reports whether a provider accepts the seed parameter.
+func providerSupportsSeed(provider ProviderType) bool {
+	switch provider {
+	case ProviderDeepSeek, ProviderMiniMax:
+		return false
+	default:
+		return true
+	}
+}
+
+// providerSupportsPenalties reports whether a provider accepts presence_penalty
+// and frequency_penalty.
+func providerSupportsPenalties(provider ProviderType) bool {
+	switch provider {
+	case ProviderGemini, ProviderMiniMax:
+		return false
+	default:
+		return true
+	}
+}
+
+// providerSupportsLogitBias reports whether a provider accepts logit_bias.
+func providerSupportsLogitBias(provider ProviderType) bool {
+	switch provider {
+	case ProviderOpenAI, ProviderAzure, ProviderOpenRouter, ProviderCustom:
+		return true
+	default:
+		return false
+	}
+}
+
+// applySamplingParameters sets the configured proxy-level sampling parameters,
+// skipping any the provider does not support.
+func (o *Options) applySamplingParameters(req *models.AnthropicRequest, openAIReq *models.OpenAIRequest, provider ProviderType) {
+	if seed := o.resolveSeed(req); seed != nil {
+		if providerSupportsSeed(provider) {
+			value := *seed
+			openAIReq.Seed = &value
+		} else {
+			logging.LogDebugMessage("[REQUEST] Dropping seed=%d: not supported by provider %s", *seed, provider)
+		}
+	}
+
+	if presence, frequency := o.resolvePenalties(req); presence != nil || frequency != nil {
+		if providerSupportsPenalties(provider) {
+			openAIReq.PresencePenalty = copyFloat(presence)
+			openAIReq.FrequencyPenalty = copyFloat(frequency)
+		} else {
+			logging.LogDebugMessage("[REQUEST] Dropping presence_penalty/frequency_penalty: not supported by provider %s", provider)
+		}
+	}
+
+	if len(o.LogitBias) > 0 {
+		if providerSupportsLogitBias(provider) {
+			openAIReq.LogitBias = o.LogitBias
+		} else {
+			logging.LogDebugMessage("[REQUEST] Dropping logit_bias: not supported by provider %s", provider)
+		}
+	}
+}
+
+// warnUnsupportedResponsesSampling logs sampling parameters that the
+// Responses API does not accept.
+func (o *Options) warnUnsupportedResponsesSampling(req *models.AnthropicRequest) {
+	if seed := o.resolveSeed(req); seed != nil {
+		logging.LogDebugMessage("[REQUEST] Dropping seed=%d: not supported by the Responses API", *seed)
+	}
+
+	if presence, frequency := o.resolvePenalties(req); presence != nil || frequency != nil {
+		logging.LogDebugMessage("[REQUEST] Dropping presence_penalty/frequency_penalty: not supported by the Responses API")
+	}
+
+	if len(o.LogitBias) > 0 {
+		logging.LogDebugMessage("[REQUEST] Dropping logit_bias: not supported by the Responses API")
+	}
+}
@@ -0,0 +1,194 @@
+// Package translator provides protocol translation between Anthropic and OpenAI formats.
+package translator
+
+import (
+	"testing"
+
+	"github.com/jedarden/clasp/pkg/models"
+)
+
+func samplingRequest() *models.AnthropicRequest {
+	return &models.AnthropicRequest{
+		Model:     "claude-3-5-sonnet-20241022",
+		MaxTokens: 100,
+		Messages: []models.AnthropicMessage{
+			{Role: "user", Content: "Hello"},
+		},
+	}
+}
+
+func TestTransformRequest_LogitBias(t *testing.T) {
+	opts := DefaultOptions()
+	opts.LogitBias = map[string]float64{"50256": -100}
+
+	tests := []struct {
+		name     string
+		model    string
+		provider ProviderType
+		want     bool
+	}{
+		{"OpenAI forwards", "gpt-4o", ProviderOpenAI, true},
+		{"Azure forwards", "gpt-4o", ProviderAzure, true},
+		{"OpenRouter forwards", "openai/gpt-4o", ProviderOpenRouter, true},
+		{"DeepSeek strips", "deepseek-chat", ProviderDeepSeek, false},
+		{"Gemini strips", "gemini-2.5-pro", ProviderGemini, false},
+		{"Ollama strips", "llama3.1", ProviderOllama, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := opts.TransformRequestWithProvider(samplingRequest(), tt.model, tt.provider)
+			if err != nil {
+				t.Fatalf("TransformRequestWithProvider failed: %v", err)
+			}
+			if got := result.LogitBias != nil; got != tt.want {
+				t.Fatalf("logit_bias present = %v, want %v", got, tt.want)
+			}
+			if tt.want && result.LogitBias["50256"] != -100 {
+				t.Errorf("LogitBias = %v", result.LogitBias)
+			}
+		})
+	}
+}
+
+func TestTransformRequest_LogitBiasUnset(t *testing.T) {
+	result, err := TransformRequestWithProvider(samplingRequest(), "gpt-4o", ProviderOpenAI)
+	if err != nil {
+		t.Fatalf("TransformRequestWithProvider failed: %v", err)
+	}
+	if result.LogitBias != nil {
+		t.Errorf("expected no logit_bias, got %v", result.LogitBias)
+	}
+}
+
+func TestTransformRequest_Seed(t *testing.T) {
+	requestSeed := 7
+	globalSeed := 42
+
+	t.Run("request seed forwarded for OpenAI", func(t *testing.T) {
+		req := samplingRequest()
+		req.Seed = &requestSeed
+		result, err := TransformRequestWithProvider(req, "gpt-4o", ProviderOpenAI)
+		if err != nil {
+			t.Fatalf("TransformRequestWithProvider failed: %v", err)
+		}
+		if result.Seed == nil || *result.Seed != 7 {
+			t.Errorf("Seed = %v, want 7", result.Seed)
+		}
+	})
+
+	t.Run("global default applies when request omits seed", func(t *testing.T) {
+		opts := DefaultOptions()
+		opts.Seed = &globalSeed
+
+		result, err := opts.TransformRequestWithProvider(samplingRequest(), "gpt-4o", ProviderOpenAI)
+		if err != nil {
+			t.Fatalf("TransformRequestWithProvider failed: %v", err)
+		}
+		if result.Seed == nil || *result.Seed != 42 {
+			t.Errorf("Seed = %v, want 42", result.Seed)
+		}
+	})
+
+	t.Run("request seed wins over global default", func(t *testing.T) {
+		opts := DefaultOptions()
+		opts.Seed = &globalSeed
+
+		req := samplingRequest()
+		req.Seed = &requestSeed
+		result, err := opts.TransformRequestWithProvider(req, "gpt-4o", ProviderOpenAI)
+		if err != nil {
+			t.Fatalf("TransformRequestWithProvider failed: %v", err)
+		}
+		if result.Seed == nil || *result.Seed != 7 {
+			t.Errorf("Seed = %v, want 7", result.Seed)
+		}
+	})
+
+	t.Run("dropped for unsupported provider", func(t *testing.T) {
+		req := samplingRequest()
+		req.Seed = &requestSeed
+		result, err := TransformRequestWithProvider(req, "deepseek-chat", ProviderDeepSeek)
+		if err != nil {
+			t.Fatalf("TransformRequestWithProvider failed: %v", err)
+		}
+		if result.Seed != nil {
+			t.Errorf("expected seed to be dropped, got %d", *result.Seed)
+		}
+	})
+
+	t.Run("no seed by default", func(t *testing.T) {
+		result, err := TransformRequestWithProvider(samplingRequest(), "gpt-4o", ProviderOpenAI)
+		if err != nil {
+			t.Fatalf("TransformRequestWithProvider failed: %v", err)
+		}
+		if result.Seed != nil {
+			t.Errorf("expected no seed, got %d", *result.Seed)
+		}
+	})
+}
+
+func TestTransformRequest_Penalties(t *testing.T) {
+	presence, frequency := 0.5, -0.25
+	globalPresence, globalFrequency := 1.0, 0.75
+
+	t.Run("request penalties forwarded", func(t *testing.T) {
+		req := samplingRequest()
+		req.PresencePenalty = &presence
+		req.FrequencyPenalty = &frequency
+		result, err := TransformRequestWithProvider(req, "gpt-4o", ProviderOpenAI)
+		if err != nil {
+			t.Fatalf("TransformRequestWithProvider failed: %v", err)
+		}
+		if result.PresencePenalty == nil || *result.PresencePenalty != 0.5 {
+			t.Errorf("PresencePenalty = %v, want 0.5", result.PresencePenalty)
+		}
+		if result.FrequencyPenalty == nil || *result.FrequencyPenalty != -0.25 {
+			t.Errorf("FrequencyPenalty = %v, want -0.25", result.FrequencyPenalty)
+		}
+	})
+
+	t.Run("global defaults fill in missing penalties", func(t *testing.T) {
+		opts := DefaultOptions()
+		opts.PresencePenalty = &globalPresence
+		opts.FrequencyPenalty = &globalFrequency
+
+		req := samplingRequest()
+		req.PresencePenalty = &presence
+		result, err := opts.TransformRequestWithProvider(req, "deepseek-chat", ProviderDeepSeek)
+		if err != nil {
+			t.Fatalf("TransformRequestWithProvider failed: %v", err)
+		}
+		if result.PresencePenalty == nil || *result.PresencePenalty != 0.5 {
+			t.Errorf("PresencePenalty = %v, want the request's 0.5", result.PresencePenalty)
+		}
+		if result.FrequencyPenalty == nil || *result.FrequencyPenalty != 0.75 {
+			t.Errorf("FrequencyPenalty = %v, want the default 0.75", result.FrequencyPenalty)
+		}
+	})
+
+	t.Run("dropped for unsupported provider", func(t *testing.T) {
+		opts := DefaultOptions()
+		opts.PresencePenalty = &globalPresence
+
+		req := samplingRequest()
+		req.FrequencyPenalty = &frequency
+		result, err := opts.TransformRequestWithProvider(req, "gemini-2.5-pro", ProviderGemini)
+		if err != nil {
+			t.Fatalf("TransformRequestWithProvider failed: %v", err)
+		}
+		if result.PresencePenalty != nil || result.FrequencyPenalty != nil {
+			t.Errorf("expected penalties to be dropped, got %v and %v", result.PresencePenalty, result.FrequencyPenalty)
+		}
+	})
+
+	t.Run("no penalties by default", func(t *testing.T) {
+		result, err := TransformRequestWithProvider(samplingRequest(), "gpt-4o", ProviderOpenAI)
+		if err != nil {
+			t.Fatalf("TransformRequestWithProvider failed: %v", err)
+		}
+		if result.PresencePenalty != nil || result.FrequencyPenalty != nil {
+			t.Errorf("expected no penalties, got %v and %v", result.PresencePenalty, result.FrequencyPenalty)
+		}
+	})
+}
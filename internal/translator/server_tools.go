@@ -0,0 +1,80 @@
+// Package translator handles protocol translation between Anthropic and OpenAI formats.
+package translator
+
+import (
+	"strings"
+
+	"github.com/jedarden/clasp/internal/logging"
+	"github.com/jedarden/clasp/pkg/models"
+)
+
+// serverToolTypePrefixes identify Anthropic server tools, which Anthropic runs
+// itself rather than returning tool_use blocks for the client to execute.
+var serverToolTypePrefixes = []string{
+	"web_search_",
+	"web_fetch_",
+	"code_execution_",
+	"tool_search_tool_",
+	"mcp",
+}
+
+// IsServerTool reports whether toolType is an Anthropic server tool type, such
+// as web_search_20250305 or mcp_toolset. Translated providers can't run them.
+func IsServerTool(toolType string) bool {
+	for _, prefix := range serverToolTypePrefixes {
+		if strings.HasPrefix(toolType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// isWebSearchServerTool reports whether toolType is Anthropic's web search
+// server tool, which maps to the Responses API's web_search_preview.
+func isWebSearchServerTool(toolType string) bool {
+	return strings.HasPrefix(toolType, "web_search_")
+}
+
+// clientTools returns the tools without Anthropic server tools, logging each
+// one dropped. mapped reports server tool types the caller translates to a
+// provider equivalent instead; those are dropped without a log line.
+func clientTools(tools []models.AnthropicTool, mapped func(toolType string) bool) []models.AnthropicTool {
+	kept := make([]models.AnthropicTool, 0, len(tools))
+	for _, tool := range tools {
+		if !IsServerTool(tool.Type) {
+			kept = append(kept, tool)
+			continue
+		}
+		if mapped == nil || !mapped(tool.Type) {
+			logging.LogDebugMessage("[REQUEST] Dropping server tool %s (%s): not supported by translated providers", tool.Name, tool.Type)
+		}
+	}
+	return kept
+}
+
+// clientToolChoice returns choice, or nil when it can't apply once server
+// tools are dropped: every tool was a server tool, or it forces one.
+func clientToolChoice(choice interface{}, tools []models.AnthropicTool) interface{} {
+	if choice == nil || len(tools) == 0 {
+		return choice
+	}
+	servers := make(map[string]bool)
+	for _, tool := range tools {
+		if IsServerTool(tool.Type) {
+			servers[tool.Name] = true
+		}
+	}
+	if len(servers) == 0 {
+		return choice
+	}
+	if len(servers) == len(tools) {
+		return nil
+	}
+	if choiceMap, ok := choice.(map[string]interface{}); ok && choiceMap["type"] == "tool" {
+		if name, _ := choiceMap["name"].(string); servers[name] {
+			logging.LogDebugMessage("[REQUEST] Dropping tool_choice for server tool %s", name)
+			return nil
+		}
+	}
+	return choice
+}
@@ -0,0 +1,95 @@
+// Package translator handles protocol translation between Anthropic and OpenAI formats.
+package translator
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/jedarden/clasp/pkg/models"
+)
+
+func serverToolsRequest() *models.AnthropicRequest {
+	return &models.AnthropicRequest{
+		Model:     "claude-3-5-sonnet-20241022",
+		MaxTokens: 1024,
+		Messages:  []models.AnthropicMessage{{Role: "user", Content: "Search for Go releases"}},
+		Tools: []models.AnthropicTool{
+			{Name: "web_search", Type: "web_search_20250305", Extra: map[string]json.RawMessage{"max_uses": json.RawMessage("5")}},
+			{Name: "docs", Type: "mcp_toolset"},
+			{Name: "get_weather", Description: "Get the weather", InputSchema: map[string]interface{}{"type": "object"}},
+		},
+	}
+}
+
+func TestIsServerTool(t *testing.T) {
+	tests := map[string]bool{
+		"web_search_20250305":     true,
+		"web_fetch_20250910":      true,
+		"code_execution_20250522": true,
+		"mcp_toolset":             true,
+		"mcp_tool":                true,
+		"":                        false,
+		"custom":                  false,
+		models.ToolTypeComputer:   false,
+		models.ToolTypeBash:       false,
+	}
+	for toolType, want := range tests {
+		if got := IsServerTool(toolType); got != want {
+			t.Errorf("IsServerTool(%q) = %v, want %v", toolType, got, want)
+		}
+	}
+}
+
+func TestTransformRequest_DropsServerTools(t *testing.T) {
+	req := serverToolsRequest()
+	req.ToolChoice = map[string]interface{}{"type": "tool", "name": "web_search"}
+
+	result, err := TransformRequest(req, "gpt-4o")
+	if err != nil {
+		t.Fatalf("TransformRequest failed: %v", err)
+	}
+	if len(result.Tools) != 1 || result.Tools[0].Function.Name != "get_weather" {
+		t.Errorf("Expected only get_weather, got %+v", result.Tools)
+	}
+	if result.ToolChoice != nil {
+		t.Errorf("Expected tool_choice forcing a server tool to be dropped, got %v", result.ToolChoice)
+	}
+}
+
+func TestTransformRequest_OnlyServerTools(t *testing.T) {
+	req := serverToolsRequest()
+	req.Tools = req.Tools[:2]
+	req.ToolChoice = map[string]interface{}{"type": "auto"}
+
+	result, err := TransformRequest(req, "gpt-4o")
+	if err != nil {
+		t.Fatalf("TransformRequest failed: %v", err)
+	}
+	if len(result.Tools) != 0 || result.ToolChoice != nil {
+		t.Errorf("Expected no tools and no tool_choice, got %+v and %v", result.Tools, result.ToolChoice)
+	}
+}
+
+func TestTransformRequestToResponses_MapsWebSearchServerTool(t *testing.T) {
+	result, err := TransformRequestToResponses(serverToolsRequest(), "gpt-5", "")
+	if err != nil {
+		t.Fatalf("TransformRequestToResponses failed: %v", err)
+	}
+	var types []string
+	for _, tool := range result.Tools {
+		types = append(types, tool.Type+":"+tool.Name)
+	}
+	if len(types) != 2 || types[0] != "function:get_weather" || types[1] != "web_search_preview:" {
+		t.Errorf("Expected get_weather and web_search_preview, got %v", types)
+	}
+}
+
+func TestTransformRequestToGemini_DropsServerTools(t *testing.T) {
+	result, err := TransformRequestToGemini(serverToolsRequest(), "gemini-2.5-pro")
+	if err != nil {
+		t.Fatalf("TransformRequestToGemini failed: %v", err)
+	}
+	if len(result.Tools) != 1 || len(result.Tools[0].FunctionDeclarations) != 1 || result.Tools[0].FunctionDeclarations[0].Name != "get_weather" {
+		t.Errorf("Expected only get_weather, got %+v", result.Tools)
+	}
+}
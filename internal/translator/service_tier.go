@@ -0,0 +1,35 @@
+// Package translator handles protocol translation between Anthropic and OpenAI formats.
+package translator
+
+import (
+	"github.com/jedarden/clasp/internal/logging"
+)
+
+// serviceTierMap maps Anthropic service_tier values to OpenAI service_tier values.
+// "standard_only" keeps a request off priority capacity, like OpenAI's "default".
+var serviceTierMap = map[string]string{
+	"auto":          "auto",
+	"standard_only": "default",
+}
+
+// providerSupportsServiceTier reports whether a provider accepts service_tier.
+func providerSupportsServiceTier(provider ProviderType) bool {
+	return provider == ProviderOpenAI
+}
+
+// mapServiceTier returns the OpenAI service_tier for an Anthropic request's
+// service_tier, or "" when it is unset, unknown or unsupported by the provider.
+func mapServiceTier(serviceTier string, provider ProviderType) string {
+	if serviceTier == "" {
+		return ""
+	}
+	if !providerSupportsServiceTier(provider) {
+		logging.LogDebugMessage("[REQUEST] Dropping service_tier=%s: not supported by provider %s", serviceTier, provider)
+		return ""
+	}
+	tier, ok := serviceTierMap[serviceTier]
+	if !ok {
+		logging.LogDebugMessage("[REQUEST] Dropping unknown service_tier=%s", serviceTier)
+	}
+	return tier
+}
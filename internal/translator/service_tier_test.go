@@ -0,0 +1,50 @@
+package translator
+
+import (
+	"testing"
+
+	"github.com/jedarden/clasp/pkg/models"
+)
+
+func TestServiceTier_ChatCompletions(t *testing.T) {
+	tests := []struct {
+		serviceTier string
+		model       string
+		want        string
+	}{
+		{"auto", "gpt-4o", "auto"},
+		{"standard_only", "gpt-4o", "default"},
+		{"unknown", "gpt-4o", ""},
+		{"", "gpt-4o", ""},
+		// Only OpenAI accepts service_tier
+		{"auto", "deepseek-chat", ""},
+	}
+
+	for _, tt := range tests {
+		req := &models.AnthropicRequest{
+			Messages:    []models.AnthropicMessage{{Role: "user", Content: "Hi"}},
+			ServiceTier: tt.serviceTier,
+		}
+		result, err := TransformRequest(req, tt.model)
+		if err != nil {
+			t.Fatalf("TransformRequest failed: %v", err)
+		}
+		if result.ServiceTier != tt.want {
+			t.Errorf("service_tier %q for %s = %q, want %q", tt.serviceTier, tt.model, result.ServiceTier, tt.want)
+		}
+	}
+}
+
+func TestServiceTier_Responses(t *testing.T) {
+	req := &models.AnthropicRequest{
+		Messages:    []models.AnthropicMessage{{Role: "user", Content: "Hi"}},
+		ServiceTier: "standard_only",
+	}
+	result, err := TransformRequestToResponses(req, "gpt-5", "")
+	if err != nil {
+		t.Fatalf("TransformRequestToResponses failed: %v", err)
+	}
+	if result.ServiceTier != "default" {
+		t.Errorf("ServiceTier = %q, want default", result.ServiceTier)
+	}
+}
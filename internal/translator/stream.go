@@ -9,6 +9,7 @@ import (
 	"regexp"
 	"strings"
 	"sync"
+	"unicode/utf8"
 
 	"github.com/jedarden/clasp/internal/logging"
 	"github.com/jedarden/clasp/pkg/models"
@@ -33,10 +34,16 @@ const maxXMLBufferSize = 100 * 1024
 // UsageCallback is called when streaming completes with usage information.
 type UsageCallback func(inputTokens, outputTokens int)
 
+// UsageDetailsCallback is called when streaming completes with the usage in
+// Anthropic format, including prompt cache reads and writes.
+type UsageDetailsCallback func(usage *models.AnthropicUsage)
+
 // StreamProcessor handles the transformation of OpenAI SSE streams to Anthropic format.
 type StreamProcessor struct {
 	mu sync.Mutex
 
+	opts *Options
+
 	// State tracking
 	state       StreamState
 	messageID   string
@@ -55,33 +62,57 @@ type StreamProcessor struct {
 	activeToolCalls map[int]*toolCallState
 
 	// Usage tracking
-	usage         *models.Usage
-	usageCallback UsageCallback
+	usage                *models.Usage
+	usageCallback        UsageCallback
+	usageDetailsCallback UsageDetailsCallback
+
+	// Usage estimation for upstreams that report none (see SetUsageEstimate)
+	estimateUsage        bool
+	estimatedInputTokens int
+	outputChars          int
 
 	// Output
 	writer io.Writer
 
+	// Reconstructed response for debug logging (nil unless enabled)
+	capture *responseCapture
+
 	// Grok XML tool call extraction
 	xmlBuffer        string
 	extractedXMLCall bool
 	xmlToolCallID    int
 
 	// Track stop reason for delayed message_delta emission
-	stopReason string
+	stopReason   string
+	blocksClosed bool // Open content blocks were closed by a finish reason
+	refused      bool // A refusal block was emitted for a content filter stop
+
+	// Unparseable data lines skipped so far
+	skippedChunks int
+
+	// The terminating events were written (see Terminate)
+	finished bool
 }
 
 type toolCallState struct {
 	id         string
 	name       string
 	arguments  string
+	argsFilter toolArgsFilter
 	blockIndex int
 	started    bool
 	closed     bool
 }
 
-// NewStreamProcessor creates a new stream processor.
+// NewStreamProcessor creates a new stream processor with the default options.
 func NewStreamProcessor(writer io.Writer, messageID, targetModel string) *StreamProcessor {
+	return defaultOptions.NewStreamProcessor(writer, messageID, targetModel)
+}
+
+// NewStreamProcessor creates a new stream processor that translates with o.
+func (o *Options) NewStreamProcessor(writer io.Writer, messageID, targetModel string) *StreamProcessor {
 	return &StreamProcessor{
+		opts:               o,
 		writer:             writer,
 		messageID:          messageID,
 		targetModel:        targetModel,
@@ -101,6 +132,14 @@ func (sp *StreamProcessor) SetUsageCallback(callback UsageCallback) {
 	sp.usageCallback = callback
 }
 
+// SetUsageDetailsCallback sets a callback that receives the final usage in
+// Anthropic format, with prompt cache tokens separated from the input tokens.
+func (sp *StreamProcessor) SetUsageDetailsCallback(callback UsageDetailsCallback) {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	sp.usageDetailsCallback = callback
+}
+
 // GetUsage returns the final usage statistics from the stream.
 // This should be called after ProcessStream completes.
 func (sp *StreamProcessor) GetUsage() (inputTokens, outputTokens int) {
@@ -112,6 +151,41 @@ func (sp *StreamProcessor) GetUsage() (inputTokens, outputTokens int) {
 	return 0, 0
 }
 
+// FinishReasonReceived reports whether the upstream sent a finish_reason.
+// Without one the stream was cut short, even though it still ends with
+// stop_reason end_turn. This should be called after ProcessStream completes.
+func (sp *StreamProcessor) FinishReasonReceived() bool {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	return sp.stopReason != ""
+}
+
+// SetUsageEstimate makes the processor estimate usage when the stream ends
+// without any, as some local servers (Ollama) do: inputTokens is the estimate
+// of the request, and output tokens are estimated from the streamed content.
+// Call before ProcessStream.
+func (sp *StreamProcessor) SetUsageEstimate(inputTokens int) {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	sp.estimateUsage = true
+	sp.estimatedInputTokens = inputTokens
+}
+
+// EnableResponseCapture makes the processor reconstruct the final Anthropic
+// response from the events it emits. Call before ProcessStream.
+func (sp *StreamProcessor) EnableResponseCapture() {
+	sp.capture = newResponseCapture()
+}
+
+// CapturedResponse returns the reconstructed Anthropic response, or nil if
+// capture was not enabled. This should be called after ProcessStream completes.
+func (sp *StreamProcessor) CapturedResponse() *models.AnthropicResponse {
+	if sp.capture == nil {
+		return nil
+	}
+	return sp.capture.response()
+}
+
 // ProcessStream reads an OpenAI SSE stream and writes Anthropic SSE events.
 func (sp *StreamProcessor) ProcessStream(reader io.Reader) error {
 	scanner := bufio.NewScanner(reader)
@@ -129,9 +203,9 @@ func (sp *StreamProcessor) ProcessStream(reader io.Reader) error {
 			continue
 		}
 
-		// Handle data lines
-		if strings.HasPrefix(line, "data: ") {
-			data := strings.TrimPrefix(line, "data: ")
+		// Handle data lines (the space after "data:" is optional)
+		if strings.HasPrefix(line, "data:") {
+			data := strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " ")
 
 			// Handle [DONE] signal
 			if data == "[DONE]" {
@@ -142,10 +216,12 @@ func (sp *StreamProcessor) ProcessStream(reader io.Reader) error {
 			// Debug log incoming OpenAI SSE event
 			logging.LogDebugSSE("INCOMING OpenAI", "chunk", data)
 
-			// Parse chunk
+			// Parse chunk; a malformed chunk is skipped so the rest of the
+			// stream still gets through
 			var chunk models.OpenAIStreamChunk
 			if err := json.Unmarshal([]byte(data), &chunk); err != nil {
-				logging.LogDebugMessage("[STREAM] Error parsing chunk: %v", err)
+				sp.skippedChunks++
+				logging.LogDebugMessage("[STREAM] Skipping malformed chunk: %v", err)
 				continue
 			}
 
@@ -167,8 +243,10 @@ func (sp *StreamProcessor) processChunk(chunk *models.OpenAIStreamChunk) error {
 	sp.mu.Lock()
 	defer sp.mu.Unlock()
 
-	// Track usage if provided
-	if chunk.Usage != nil {
+	// Track usage if provided. With stream_options.include_usage the totals
+	// arrive in the terminal chunk; some providers also attach an empty usage
+	// object to other chunks, which must not replace the real counts.
+	if chunk.Usage != nil && (sp.usage == nil || chunk.Usage.PromptTokens > 0 || chunk.Usage.CompletionTokens > 0) {
 		sp.usage = chunk.Usage
 	}
 
@@ -291,6 +369,7 @@ func (sp *StreamProcessor) handleToolCall(tc *models.OpenAIToolCall) error {
 		// New tool call
 		tcState = &toolCallState{
 			blockIndex: sp.textBlockIndex + len(sp.activeToolCalls) + 1,
+			argsFilter: toolArgsFilter{repair: sp.opts.RepairToolJSON},
 		}
 		if sp.textStarted {
 			tcState.blockIndex = sp.textBlockIndex + 1 + len(sp.activeToolCalls)
@@ -309,6 +388,7 @@ func (sp *StreamProcessor) handleToolCall(tc *models.OpenAIToolCall) error {
 	}
 	if tc.Function.Arguments != "" {
 		tcState.arguments += tc.Function.Arguments
+		tcState.argsFilter.append(tc.Function.Arguments)
 	}
 
 	// Start tool block if we have enough info and not started
@@ -327,10 +407,12 @@ func (sp *StreamProcessor) handleToolCall(tc *models.OpenAIToolCall) error {
 		tcState.started = true
 	}
 
-	// Emit tool input delta if we have arguments
-	if tcState.started && tc.Function.Arguments != "" {
-		if err := sp.emitContentBlockDelta(tcState.blockIndex, "input_json_delta", "", tc.Function.Arguments); err != nil {
-			return err
+	// Emit tool input delta if we have arguments (fence-wrapped arguments are held until the block closes)
+	if tcState.started {
+		if args := tcState.argsFilter.ready(); args != "" {
+			if err := sp.emitContentBlockDelta(tcState.blockIndex, "input_json_delta", "", args); err != nil {
+				return err
+			}
 		}
 	}
 
@@ -339,6 +421,38 @@ func (sp *StreamProcessor) handleToolCall(tc *models.OpenAIToolCall) error {
 
 // handleFinishReason handles the finish reason from the stream.
 func (sp *StreamProcessor) handleFinishReason(reason string) error {
+	if err := sp.closeOpenBlocks(); err != nil {
+		return err
+	}
+
+	// Map finish reason to Anthropic stop reason and store it
+	// Don't emit message_delta yet - wait for usage data in finalize()
+	sp.stopReason = sp.opts.mapFinishReason(reason)
+
+	// Explain content filter stops with a refusal block
+	if IsContentFilterReason(reason) && !sp.refused {
+		sp.refused = true
+		if err := sp.emitRefusalBlock(sp.nextBlockIndex(), reason); err != nil {
+			return err
+		}
+	}
+
+	// Some servers (Ollama) finish tool calls with "stop"
+	if sp.stopReason == "end_turn" && len(sp.activeToolCalls) > 0 {
+		sp.stopReason = "tool_use"
+	}
+
+	return nil
+}
+
+// closeOpenBlocks stops every open thinking, text and tool block. It runs
+// once, on the finish reason or at the end of a stream that had none.
+func (sp *StreamProcessor) closeOpenBlocks() error {
+	if sp.blocksClosed {
+		return nil
+	}
+	sp.blocksClosed = true
+
 	// Close any open thinking block first (thinking comes before text)
 	if sp.thinkingStarted {
 		if err := sp.emitContentBlockStop(sp.thinkingBlockIndex); err != nil {
@@ -356,6 +470,11 @@ func (sp *StreamProcessor) handleFinishReason(reason string) error {
 	// Close any open tool blocks
 	for _, tcState := range sp.activeToolCalls {
 		if tcState.started && !tcState.closed {
+			if args := tcState.argsFilter.flush(); args != "" {
+				if err := sp.emitContentBlockDelta(tcState.blockIndex, "input_json_delta", "", args); err != nil {
+					return err
+				}
+			}
 			if err := sp.emitContentBlockStop(tcState.blockIndex); err != nil {
 				return err
 			}
@@ -363,22 +482,63 @@ func (sp *StreamProcessor) handleFinishReason(reason string) error {
 		}
 	}
 
-	// Map finish reason to Anthropic stop reason and store it
-	// Don't emit message_delta yet - wait for usage data in finalize()
-	sp.stopReason = mapFinishReason(reason)
-
 	return nil
 }
 
+// Terminate completes the Anthropic stream after ProcessStream failed mid-stream
+// (e.g. the upstream connection dropped), so the client receives message_delta,
+// message_stop and [DONE] instead of waiting for more events. It does nothing
+// if the stream was already completed.
+func (sp *StreamProcessor) Terminate() error {
+	sp.mu.Lock()
+	finished := sp.finished
+	sp.mu.Unlock()
+	if finished {
+		return nil
+	}
+	return sp.finalize()
+}
+
 // finalize completes the stream processing.
 func (sp *StreamProcessor) finalize() error {
 	sp.mu.Lock()
 	defer sp.mu.Unlock()
+	sp.finished = true
+
+	if sp.skippedChunks > 0 {
+		logging.LogDebugMessage("[STREAM] Skipped %d malformed chunks", sp.skippedChunks)
+	}
+
+	// The upstream may end before any valid chunk or without a finish
+	// reason; complete the message so the client sees a well-formed stream
+	if sp.state == StateIdle {
+		if err := sp.emitMessageStart(); err != nil {
+			return err
+		}
+		sp.state = StateMessageStarted
+	}
+	if sp.stopReason == "" {
+		logging.LogDebugMessage("[STREAM] Stream ended without a finish reason")
+		if err := sp.closeOpenBlocks(); err != nil {
+			return err
+		}
+	}
+
+	// Fill in usage the upstream didn't report
+	if sp.estimateUsage && (sp.usage == nil || sp.usage.PromptTokens == 0 && sp.usage.CompletionTokens == 0) {
+		sp.usage = &models.Usage{
+			PromptTokens:     sp.estimatedInputTokens,
+			CompletionTokens: charsToTokens(sp.outputChars),
+		}
+	}
 
 	// Call usage callback if set and we have usage data
 	if sp.usageCallback != nil && sp.usage != nil {
 		sp.usageCallback(sp.usage.PromptTokens, sp.usage.CompletionTokens)
 	}
+	if sp.usageDetailsCallback != nil && sp.usage != nil {
+		sp.usageDetailsCallback(sp.usage.AnthropicUsage())
+	}
 
 	// Emit message_delta with final usage (delayed from handleFinishReason)
 	// This ensures we have the correct usage.output_tokens value
@@ -416,6 +576,9 @@ func (sp *StreamProcessor) emitMessageStart() error {
 			},
 		},
 	}
+	if sp.estimateUsage {
+		event.Message.Usage.InputTokens = sp.estimatedInputTokens
+	}
 
 	if err := sp.writeEvent(models.EventMessageStart, event); err != nil {
 		return err
@@ -445,6 +608,40 @@ func (sp *StreamProcessor) emitContentBlockStart(index int, blockType, id, name
 	return sp.writeEvent(models.EventContentBlockStart, event)
 }
 
+// emitRefusalBlock emits a complete refusal content block explaining a
+// content filter stop.
+func (sp *StreamProcessor) emitRefusalBlock(index int, reason string) error {
+	block := RefusalBlock(reason)
+	if err := sp.writeEvent(models.EventContentBlockStart, models.ContentBlockStartEvent{
+		Type:  models.EventContentBlockStart,
+		Index: index,
+		ContentBlock: models.ContentBlockStartData{
+			Type: block.Type,
+			Text: block.Text,
+		},
+	}); err != nil {
+		return err
+	}
+	return sp.emitContentBlockStop(index)
+}
+
+// nextBlockIndex returns the index after the last content block started so far.
+func (sp *StreamProcessor) nextBlockIndex() int {
+	next := 0
+	if sp.thinkingStarted {
+		next = sp.thinkingBlockIndex + 1
+	}
+	if sp.textStarted && sp.textBlockIndex+1 > next {
+		next = sp.textBlockIndex + 1
+	}
+	for _, tcState := range sp.activeToolCalls {
+		if tcState.started && tcState.blockIndex+1 > next {
+			next = tcState.blockIndex + 1
+		}
+	}
+	return next
+}
+
 // emitContentBlockDelta emits a content_block_delta event.
 func (sp *StreamProcessor) emitContentBlockDelta(index int, deltaType, text, partialJSON string) error {
 	event := models.ContentBlockDeltaEvent{
@@ -460,6 +657,7 @@ func (sp *StreamProcessor) emitContentBlockDelta(index int, deltaType, text, par
 	} else if deltaType == "input_json_delta" {
 		event.Delta.PartialJSON = partialJSON
 	}
+	sp.outputChars += utf8.RuneCountInString(text) + utf8.RuneCountInString(partialJSON)
 
 	return sp.writeEvent(models.EventContentBlockDelta, event)
 }
@@ -488,6 +686,7 @@ func (sp *StreamProcessor) emitThinkingBlockDelta(index int, thinking string) er
 			Thinking: thinking,
 		},
 	}
+	sp.outputChars += utf8.RuneCountInString(thinking)
 
 	return sp.writeEvent(models.EventContentBlockDelta, event)
 }
@@ -504,9 +703,12 @@ func (sp *StreamProcessor) emitContentBlockStop(index int) error {
 
 // emitMessageDelta emits a message_delta event.
 func (sp *StreamProcessor) emitMessageDelta(stopReason string) error {
-	outputTokens := 0
+	usage := &models.MessageDeltaUsage{}
 	if sp.usage != nil {
-		outputTokens = sp.usage.CompletionTokens
+		details := sp.usage.AnthropicUsage()
+		usage.OutputTokens = details.OutputTokens
+		usage.CacheReadInputTokens = details.CacheReadInputTokens
+		usage.CacheCreationInputTokens = details.CacheCreationInputTokens
 	}
 
 	event := models.MessageDeltaEvent{
@@ -514,9 +716,7 @@ func (sp *StreamProcessor) emitMessageDelta(stopReason string) error {
 		Delta: models.MessageDeltaData{
 			StopReason: stopReason,
 		},
-		Usage: &models.MessageDeltaUsage{
-			OutputTokens: outputTokens,
-		},
+		Usage: usage,
 	}
 
 	return sp.writeEvent(models.EventMessageDelta, event)
@@ -540,6 +740,9 @@ func (sp *StreamProcessor) writeEvent(eventType string, data interface{}) error
 
 	// Debug log outgoing Anthropic SSE event
 	logging.LogDebugSSE("OUTGOING Anthropic", eventType, string(jsonData))
+	if sp.capture != nil {
+		sp.capture.record(eventType, jsonData)
+	}
 
 	return sp.writeSSE(eventType, string(jsonData))
 }
@@ -557,22 +760,6 @@ func (sp *StreamProcessor) writeSSE(event, data string) error {
 	return err
 }
 
-// mapFinishReason maps OpenAI finish_reason to Anthropic stop_reason.
-func mapFinishReason(reason string) string {
-	switch reason {
-	case "stop":
-		return "end_turn"
-	case "tool_calls":
-		return "tool_use"
-	case "length":
-		return "max_tokens"
-	case "content_filter":
-		return "end_turn"
-	default:
-		return "end_turn"
-	}
-}
-
 // isGrokModelStream checks if the model is a Grok model (for streaming context).
 func isGrokModelStream(model string) bool {
 	m := strings.ToLower(model)
@@ -0,0 +1,109 @@
+// Package translator handles protocol translation between Anthropic and OpenAI formats.
+package translator
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/jedarden/clasp/pkg/models"
+)
+
+// responseCapture reconstructs the final Anthropic response from the SSE events
+// a stream processor emits, so streamed responses can be debug-logged the same
+// way as non-streaming ones.
+type responseCapture struct {
+	resp   models.AnthropicResponse
+	blocks map[int]*capturedBlock
+}
+
+// capturedBlock is a content block being assembled from deltas.
+type capturedBlock struct {
+	block       models.AnthropicContentBlock
+	partialJSON strings.Builder
+}
+
+func newResponseCapture() *responseCapture {
+	return &responseCapture{blocks: make(map[int]*capturedBlock)}
+}
+
+// record applies an emitted event to the captured response.
+func (c *responseCapture) record(eventType string, data []byte) {
+	switch eventType {
+	case models.EventMessageStart:
+		var event models.MessageStartEvent
+		if json.Unmarshal(data, &event) == nil {
+			c.resp = event.Message
+			c.resp.Content = nil
+		}
+
+	case models.EventContentBlockStart:
+		var event models.ContentBlockStartEvent
+		if json.Unmarshal(data, &event) == nil {
+			c.blocks[event.Index] = &capturedBlock{
+				block: models.AnthropicContentBlock{
+					Type:     event.ContentBlock.Type,
+					Text:     event.ContentBlock.Text,
+					ID:       event.ContentBlock.ID,
+					Name:     event.ContentBlock.Name,
+					Thinking: event.ContentBlock.Thinking,
+				},
+			}
+		}
+
+	case models.EventContentBlockDelta:
+		var event models.ContentBlockDeltaEvent
+		if json.Unmarshal(data, &event) != nil {
+			return
+		}
+		b, ok := c.blocks[event.Index]
+		if !ok {
+			return
+		}
+		b.block.Text += event.Delta.Text
+		b.block.Thinking += event.Delta.Thinking
+		b.partialJSON.WriteString(event.Delta.PartialJSON)
+
+	case models.EventMessageDelta:
+		var event models.MessageDeltaEvent
+		if json.Unmarshal(data, &event) != nil {
+			return
+		}
+		c.resp.StopReason = event.Delta.StopReason
+		c.resp.StopSequence = event.Delta.StopSequence
+		if event.Usage != nil {
+			if c.resp.Usage == nil {
+				c.resp.Usage = &models.AnthropicUsage{}
+			}
+			c.resp.Usage.OutputTokens = event.Usage.OutputTokens
+			c.resp.Usage.CacheReadInputTokens = event.Usage.CacheReadInputTokens
+			c.resp.Usage.CacheCreationInputTokens = event.Usage.CacheCreationInputTokens
+		}
+	}
+}
+
+// response returns the reconstructed response with content blocks in index order.
+func (c *responseCapture) response() *models.AnthropicResponse {
+	resp := c.resp
+	indexes := make([]int, 0, len(c.blocks))
+	for i := range c.blocks {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+
+	resp.Content = make([]models.AnthropicContentBlock, 0, len(indexes))
+	for _, i := range indexes {
+		b := c.blocks[i]
+		block := b.block
+		if block.Type == "tool_use" {
+			args := b.partialJSON.String()
+			var input interface{}
+			if args == "" || json.Unmarshal([]byte(args), &input) != nil {
+				input = map[string]interface{}{}
+			}
+			block.Input = input
+		}
+		resp.Content = append(resp.Content, block)
+	}
+	return &resp
+}
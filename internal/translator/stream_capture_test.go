@@ -0,0 +1,62 @@
+// Package translator provides protocol translation between Anthropic and OpenAI formats.
+package translator
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestStreamProcessor_ResponseCapture(t *testing.T) {
+	var buf bytes.Buffer
+	sp := NewStreamProcessor(&buf, "msg_123", "gpt-4o")
+	sp.EnableResponseCapture()
+
+	stream := strings.Join([]string{
+		`data: {"id":"1","choices":[{"index":0,"delta":{"content":"Checking "}}]}`,
+		`data: {"id":"1","choices":[{"index":0,"delta":{"content":"the weather"}}]}`,
+		`data: {"id":"1","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{\"city\":"}}]}}]}`,
+		`data: {"id":"1","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"Paris\"}"}}]}}]}`,
+		`data: {"id":"1","choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}],"usage":{"prompt_tokens":12,"completion_tokens":8}}`,
+		`data: [DONE]`,
+	}, "\n\n")
+
+	if err := sp.ProcessStream(strings.NewReader(stream)); err != nil {
+		t.Fatalf("ProcessStream() error = %v", err)
+	}
+
+	resp := sp.CapturedResponse()
+	if resp == nil {
+		t.Fatal("expected a captured response")
+	}
+	if resp.ID != "msg_123" || resp.StopReason != "tool_use" {
+		t.Errorf("unexpected response metadata: id=%q stop_reason=%q", resp.ID, resp.StopReason)
+	}
+	if len(resp.Content) != 2 {
+		t.Fatalf("expected 2 content blocks, got %+v", resp.Content)
+	}
+	if resp.Content[0].Type != "text" || resp.Content[0].Text != "Checking the weather" {
+		t.Errorf("unexpected text block: %+v", resp.Content[0])
+	}
+	tool := resp.Content[1]
+	input, ok := tool.Input.(map[string]interface{})
+	if tool.Type != "tool_use" || tool.Name != "get_weather" || !ok || input["city"] != "Paris" {
+		t.Errorf("unexpected tool_use block: %+v", tool)
+	}
+	if resp.Usage == nil || resp.Usage.OutputTokens != 8 {
+		t.Errorf("unexpected usage: %+v", resp.Usage)
+	}
+}
+
+func TestStreamProcessor_ResponseCaptureDisabled(t *testing.T) {
+	var buf bytes.Buffer
+	sp := NewStreamProcessor(&buf, "msg_123", "gpt-4o")
+
+	stream := "data: {\"id\":\"1\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Hi\"},\"finish_reason\":\"stop\"}]}\n\ndata: [DONE]\n\n"
+	if err := sp.ProcessStream(strings.NewReader(stream)); err != nil {
+		t.Fatalf("ProcessStream() error = %v", err)
+	}
+	if sp.CapturedResponse() != nil {
+		t.Error("expected no captured response when capture is disabled")
+	}
+}
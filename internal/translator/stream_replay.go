@@ -0,0 +1,110 @@
+// Package translator handles protocol translation between Anthropic and OpenAI formats.
+package translator
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/jedarden/clasp/pkg/models"
+)
+
+// ReplayStream writes a complete Anthropic response as an SSE stream, with
+// events in the order the Anthropic API sends them: message_start, ping, a
+// start, delta and stop event per content block, message_delta and
+// message_stop. It serves cached responses to streaming requests.
+func ReplayStream(w io.Writer, resp *models.AnthropicResponse) error {
+	var inputTokens, outputTokens int
+	if resp.Usage != nil {
+		inputTokens = resp.Usage.InputTokens
+		outputTokens = resp.Usage.OutputTokens
+	}
+
+	start := *resp
+	start.Content = []models.AnthropicContentBlock{}
+	start.StopReason = ""
+	start.StopSequence = ""
+	start.Usage = &models.AnthropicUsage{InputTokens: inputTokens, OutputTokens: 1}
+	if err := writeReplayEvent(w, models.EventMessageStart, models.MessageStartEvent{
+		Type:    models.EventMessageStart,
+		Message: start,
+	}); err != nil {
+		return err
+	}
+	if err := writeReplayEvent(w, models.EventPing, models.PingEvent{Type: models.EventPing}); err != nil {
+		return err
+	}
+
+	for i, block := range resp.Content {
+		startData := models.ContentBlockStartData{Type: block.Type}
+		var delta models.DeltaData
+		switch block.Type {
+		case "text":
+			delta = models.DeltaData{Type: "text_delta", Text: block.Text}
+		case "thinking":
+			delta = models.DeltaData{Type: "thinking_delta", Thinking: block.Thinking}
+		case "tool_use":
+			startData.ID = block.ID
+			startData.Name = block.Name
+			input := block.Input
+			if input == nil {
+				input = map[string]interface{}{}
+			}
+			args, err := json.Marshal(input)
+			if err != nil {
+				return fmt.Errorf("marshaling tool input: %w", err)
+			}
+			delta = models.DeltaData{Type: "input_json_delta", PartialJSON: string(args)}
+		case "refusal":
+			// Refusal blocks carry their text in content_block_start
+			startData.Text = block.Text
+		default:
+			continue
+		}
+
+		if err := writeReplayEvent(w, models.EventContentBlockStart, models.ContentBlockStartEvent{
+			Type:         models.EventContentBlockStart,
+			Index:        i,
+			ContentBlock: startData,
+		}); err != nil {
+			return err
+		}
+		if delta.Type != "" {
+			if err := writeReplayEvent(w, models.EventContentBlockDelta, models.ContentBlockDeltaEvent{
+				Type:  models.EventContentBlockDelta,
+				Index: i,
+				Delta: delta,
+			}); err != nil {
+				return err
+			}
+		}
+		if err := writeReplayEvent(w, models.EventContentBlockStop, models.ContentBlockStopEvent{
+			Type:  models.EventContentBlockStop,
+			Index: i,
+		}); err != nil {
+			return err
+		}
+	}
+
+	if err := writeReplayEvent(w, models.EventMessageDelta, models.MessageDeltaEvent{
+		Type: models.EventMessageDelta,
+		Delta: models.MessageDeltaData{
+			StopReason:   resp.StopReason,
+			StopSequence: resp.StopSequence,
+		},
+		Usage: &models.MessageDeltaUsage{OutputTokens: outputTokens},
+	}); err != nil {
+		return err
+	}
+	return writeReplayEvent(w, models.EventMessageStop, models.MessageStopEvent{Type: models.EventMessageStop})
+}
+
+// writeReplayEvent writes one SSE event.
+func writeReplayEvent(w io.Writer, eventType string, data interface{}) error {
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("marshaling event data: %w", err)
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", eventType, jsonData)
+	return err
+}
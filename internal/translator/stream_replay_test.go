@@ -0,0 +1,67 @@
+// Package translator provides protocol translation between Anthropic and OpenAI formats.
+package translator
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jedarden/clasp/pkg/models"
+)
+
+func TestReplayStream(t *testing.T) {
+	resp := &models.AnthropicResponse{
+		ID:    "msg_123",
+		Type:  "message",
+		Role:  "assistant",
+		Model: "gpt-4o",
+		Content: []models.AnthropicContentBlock{
+			{Type: "thinking", Thinking: "The user wants the weather."},
+			{Type: "text", Text: "Checking the weather"},
+			{Type: "tool_use", ID: "call_1", Name: "get_weather", Input: map[string]interface{}{"city": "Paris"}},
+		},
+		StopReason: "tool_use",
+		Usage:      &models.AnthropicUsage{InputTokens: 12, OutputTokens: 8},
+	}
+
+	var buf bytes.Buffer
+	if err := ReplayStream(&buf, resp); err != nil {
+		t.Fatalf("ReplayStream() error = %v", err)
+	}
+
+	// Replaying the events through a capture must reproduce the response
+	capture := newResponseCapture()
+	var events []string
+	for _, block := range strings.Split(strings.TrimSpace(buf.String()), "\n\n") {
+		lines := strings.SplitN(block, "\n", 2)
+		if len(lines) != 2 || !strings.HasPrefix(lines[0], "event: ") || !strings.HasPrefix(lines[1], "data: ") {
+			t.Fatalf("malformed SSE event: %q", block)
+		}
+		eventType := strings.TrimPrefix(lines[0], "event: ")
+		events = append(events, eventType)
+		capture.record(eventType, []byte(strings.TrimPrefix(lines[1], "data: ")))
+	}
+
+	wantEvents := []string{
+		"message_start", "ping",
+		"content_block_start", "content_block_delta", "content_block_stop",
+		"content_block_start", "content_block_delta", "content_block_stop",
+		"content_block_start", "content_block_delta", "content_block_stop",
+		"message_delta", "message_stop",
+	}
+	if !reflect.DeepEqual(events, wantEvents) {
+		t.Errorf("events = %v, want %v", events, wantEvents)
+	}
+
+	got := capture.response()
+	if got.ID != resp.ID || got.Model != resp.Model || got.StopReason != resp.StopReason {
+		t.Errorf("unexpected metadata: %+v", got)
+	}
+	if !reflect.DeepEqual(got.Content, resp.Content) {
+		t.Errorf("content = %+v, want %+v", got.Content, resp.Content)
+	}
+	if got.Usage == nil || got.Usage.InputTokens != 12 || got.Usage.OutputTokens != 8 {
+		t.Errorf("unexpected usage: %+v", got.Usage)
+	}
+}
@@ -3,6 +3,8 @@ package translator
 
 import (
 	"bytes"
+	"encoding/json"
+	"io"
 	"strings"
 	"testing"
 
@@ -24,7 +26,7 @@ func TestMapFinishReason(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.reason, func(t *testing.T) {
-			result := mapFinishReason(tt.reason)
+			result := MapFinishReason(tt.reason)
 			if result != tt.expected {
 				t.Errorf("mapFinishReason(%q) = %q, want %q", tt.reason, result, tt.expected)
 			}
@@ -260,6 +262,154 @@ data: [DONE]
 	}
 }
 
+func TestStreamProcessor_ProcessStream_CorruptChunkMidStream(t *testing.T) {
+	var buf bytes.Buffer
+	sp := NewStreamProcessor(&buf, "msg_123", "gpt-4o")
+
+	// A truncated chunk between valid ones, and a data line without a space
+	input := `data: {"choices":[{"delta":{"content":"Hello"}}]}
+
+data: {"choices":[{"delta":{"content":" wor
+
+data:{"choices":[{"delta":{"content":" world"}}]}
+
+data: {"choices":[{"delta":{},"finish_reason":"stop"}]}
+
+data: [DONE]
+`
+	if err := sp.ProcessStream(strings.NewReader(input)); err != nil {
+		t.Fatalf("ProcessStream failed: %v", err)
+	}
+
+	output := buf.String()
+	if !strings.Contains(output, `"text":"Hello"`) || !strings.Contains(output, `"text":" world"`) {
+		t.Errorf("Expected the valid chunks around the corrupt one, got:\n%s", output)
+	}
+	want := []string{"message_start", "ping", "content_block_start", "content_block_delta", "content_block_delta", "content_block_stop", "message_delta", "message_stop", "[DONE]"}
+	if got := streamEventTypes(output); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("events = %v, want %v", got, want)
+	}
+}
+
+func TestStreamProcessor_ProcessStream_AbruptEnd(t *testing.T) {
+	var buf bytes.Buffer
+	sp := NewStreamProcessor(&buf, "msg_123", "gpt-4o")
+
+	// The upstream stops mid tool call: no finish_reason and no [DONE]
+	input := `data: {"choices":[{"delta":{"content":"Let me check"}}]}
+
+data: {"choices":[{"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"read_file","arguments":"{\"path\""}}]}}]}
+
+data: {"choices":[{"delta":{"tool_calls":[{"ind`
+	if err := sp.ProcessStream(strings.NewReader(input)); err != nil {
+		t.Fatalf("ProcessStream failed: %v", err)
+	}
+
+	got := streamEventTypes(buf.String())
+	want := []string{"message_start", "ping", "content_block_start", "content_block_delta", "content_block_stop", "content_block_start", "content_block_delta", "content_block_stop", "message_delta", "message_stop", "[DONE]"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("events = %v, want %v", got, want)
+	}
+	if !strings.Contains(buf.String(), `"stop_reason":"end_turn"`) {
+		t.Errorf("Expected stop_reason end_turn, got:\n%s", buf.String())
+	}
+	if sp.FinishReasonReceived() {
+		t.Error("Expected FinishReasonReceived to be false without a finish_reason")
+	}
+}
+
+func TestStreamProcessor_ProcessStream_NoValidChunks(t *testing.T) {
+	var buf bytes.Buffer
+	sp := NewStreamProcessor(&buf, "msg_123", "gpt-4o")
+
+	if err := sp.ProcessStream(strings.NewReader("data: {broken\n\n")); err != nil {
+		t.Fatalf("ProcessStream failed: %v", err)
+	}
+
+	want := []string{"message_start", "ping", "message_delta", "message_stop", "[DONE]"}
+	if got := streamEventTypes(buf.String()); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("events = %v, want %v", got, want)
+	}
+	if sp.FinishReasonReceived() {
+		t.Error("Expected FinishReasonReceived to be false for a stream of malformed chunks")
+	}
+}
+
+// failingReader returns data and then err, like an upstream connection that
+// drops mid-stream.
+type failingReader struct {
+	data string
+	err  error
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if r.data == "" {
+		return 0, r.err
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func TestStreamProcessor_TerminateAfterReadError(t *testing.T) {
+	var buf bytes.Buffer
+	sp := NewStreamProcessor(&buf, "msg_123", "gpt-4o")
+
+	reader := &failingReader{
+		data: "data: {\"choices\":[{\"delta\":{\"content\":\"Partial\"}}]}\n\n",
+		err:  io.ErrUnexpectedEOF,
+	}
+	if err := sp.ProcessStream(reader); err == nil {
+		t.Fatal("Expected ProcessStream to report the read error")
+	}
+	if err := sp.Terminate(); err != nil {
+		t.Fatalf("Terminate failed: %v", err)
+	}
+	// A second call must not write the terminator again
+	if err := sp.Terminate(); err != nil {
+		t.Fatalf("Terminate failed: %v", err)
+	}
+
+	want := []string{"message_start", "ping", "content_block_start", "content_block_delta", "content_block_stop", "message_delta", "message_stop", "[DONE]"}
+	if got := streamEventTypes(buf.String()); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("events = %v, want %v", got, want)
+	}
+}
+
+func TestStreamProcessor_TerminateAfterCompletion(t *testing.T) {
+	var buf bytes.Buffer
+	sp := NewStreamProcessor(&buf, "msg_123", "gpt-4o")
+
+	if err := sp.ProcessStream(strings.NewReader("data: {\"choices\":[{\"delta\":{\"content\":\"Hi\"},\"finish_reason\":\"stop\"}]}\n\ndata: [DONE]\n\n")); err != nil {
+		t.Fatalf("ProcessStream failed: %v", err)
+	}
+	if !sp.FinishReasonReceived() {
+		t.Error("Expected FinishReasonReceived to be true after finish_reason stop")
+	}
+	before := buf.Len()
+	if err := sp.Terminate(); err != nil {
+		t.Fatalf("Terminate failed: %v", err)
+	}
+	if buf.Len() != before {
+		t.Errorf("Expected Terminate to write nothing after a completed stream, got %q", buf.String()[before:])
+	}
+}
+
+// streamEventTypes returns the SSE event types in output in order, with
+// "[DONE]" for the terminating data line.
+func streamEventTypes(output string) []string {
+	var types []string
+	for _, line := range strings.Split(output, "\n") {
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			types = append(types, strings.TrimPrefix(line, "event: "))
+		case line == "data: [DONE]":
+			types = append(types, "[DONE]")
+		}
+	}
+	return types
+}
+
 func TestStreamProcessor_WriteSSE(t *testing.T) {
 	var buf bytes.Buffer
 	sp := NewStreamProcessor(&buf, "msg_123", "gpt-4o")
@@ -501,6 +651,49 @@ func TestStreamProcessor_ProcessChunk_TracksUsage(t *testing.T) {
 	}
 }
 
+func TestStreamProcessor_MessageDeltaUsesTerminalUsage(t *testing.T) {
+	// The usage chunk follows the finish_reason chunk, and a provider attaches
+	// an empty usage object to the content chunks
+	input := "data: {\"choices\":[{\"index\":0,\"delta\":{\"role\":\"assistant\",\"content\":\"Hello\"}}],\"usage\":{\"prompt_tokens\":0,\"completion_tokens\":0}}\n\n" +
+		"data: {\"choices\":[{\"index\":0,\"delta\":{},\"finish_reason\":\"stop\"}]}\n\n" +
+		"data: {\"choices\":[],\"usage\":{\"prompt_tokens\":12,\"completion_tokens\":7,\"total_tokens\":19}}\n\n" +
+		"data: {\"choices\":[],\"usage\":{\"prompt_tokens\":0,\"completion_tokens\":0,\"total_tokens\":0}}\n\n" +
+		"data: [DONE]\n\n"
+
+	var buf bytes.Buffer
+	sp := NewStreamProcessor(&buf, "msg_123", "gpt-4o")
+	var gotInput, gotOutput int
+	sp.SetUsageCallback(func(input, output int) {
+		gotInput, gotOutput = input, output
+	})
+	if err := sp.ProcessStream(strings.NewReader(input)); err != nil {
+		t.Fatalf("ProcessStream failed: %v", err)
+	}
+
+	var delta *models.MessageDeltaEvent
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if !strings.HasPrefix(line, "data: {\"type\":\"message_delta\"") {
+			continue
+		}
+		delta = &models.MessageDeltaEvent{}
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), delta); err != nil {
+			t.Fatalf("Failed to parse message_delta: %v", err)
+		}
+	}
+	if delta == nil || delta.Usage == nil {
+		t.Fatalf("Expected a message_delta with usage, got:\n%s", buf.String())
+	}
+	if delta.Usage.OutputTokens != 7 {
+		t.Errorf("message_delta usage.output_tokens = %d, want 7", delta.Usage.OutputTokens)
+	}
+	if delta.Delta.StopReason != "end_turn" {
+		t.Errorf("message_delta stop_reason = %q, want end_turn", delta.Delta.StopReason)
+	}
+	if gotInput != 12 || gotOutput != 7 {
+		t.Errorf("usage callback got (%d, %d), want (12, 7)", gotInput, gotOutput)
+	}
+}
+
 func TestStreamProcessor_HandleFinishReason_StopReasons(t *testing.T) {
 	tests := []struct {
 		reason       string
@@ -522,6 +715,13 @@ func TestStreamProcessor_HandleFinishReason_StopReasons(t *testing.T) {
 			if err != nil {
 				t.Fatalf("handleFinishReason failed: %v", err)
 			}
+			// message_delta waits for the terminal usage chunk
+			if strings.Contains(buf.String(), "message_delta") {
+				t.Error("message_delta should not be emitted before the stream ends")
+			}
+			if err := sp.finalize(); err != nil {
+				t.Fatalf("finalize failed: %v", err)
+			}
 
 			output := buf.String()
 			expected := "\"stop_reason\":\"" + tt.expectedStop + "\""
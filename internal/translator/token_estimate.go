@@ -0,0 +1,104 @@
+// Package translator handles protocol translation between Anthropic and OpenAI formats.
+package translator
+
+import (
+	"encoding/json"
+	"unicode/utf8"
+
+	"github.com/jedarden/clasp/pkg/models"
+)
+
+// estimateCharsPerToken is the rough character-to-token ratio used when an
+// upstream reports no usage.
+const estimateCharsPerToken = 4
+
+// imageTokenEstimate is the token estimate for an image or document block,
+// whose base64 data says little about what the model is billed for.
+const imageTokenEstimate = 1000
+
+// EstimateTokens estimates the number of tokens in text.
+func EstimateTokens(text string) int {
+	return charsToTokens(utf8.RuneCountInString(text))
+}
+
+// EstimateInputTokens estimates the input tokens of a request: its system
+// prompt, messages, and tool definitions.
+func EstimateInputTokens(req *models.AnthropicRequest) int {
+	tokens := 0
+	chars := contentChars(req.System, &tokens)
+	for _, msg := range req.Messages {
+		chars += contentChars(msg.Content, &tokens)
+	}
+	for _, tool := range req.Tools {
+		chars += utf8.RuneCountInString(tool.Name) + utf8.RuneCountInString(tool.Description)
+		if schema, err := json.Marshal(tool.InputSchema); err == nil {
+			chars += len(schema)
+		}
+	}
+	return tokens + charsToTokens(chars)
+}
+
+// EstimateOutputTokens estimates the output tokens of a response's content.
+func EstimateOutputTokens(content []models.AnthropicContentBlock) int {
+	chars := 0
+	for _, block := range content {
+		chars += utf8.RuneCountInString(block.Text) + utf8.RuneCountInString(block.Thinking)
+		if block.Type == "tool_use" {
+			chars += utf8.RuneCountInString(block.Name)
+			if input, err := json.Marshal(block.Input); err == nil {
+				chars += len(input)
+			}
+		}
+	}
+	return charsToTokens(chars)
+}
+
+// contentChars counts the characters of message or system content, which is
+// a string or a list of content blocks. Images and documents add a fixed
+// estimate to tokens instead.
+func contentChars(content interface{}, tokens *int) int {
+	switch c := content.(type) {
+	case nil:
+		return 0
+	case string:
+		return utf8.RuneCountInString(c)
+	case []interface{}:
+		chars := 0
+		for _, item := range c {
+			chars += contentChars(item, tokens)
+		}
+		return chars
+	case map[string]interface{}:
+		if c["type"] == "image" || c["type"] == "document" {
+			*tokens += imageTokenEstimate
+			return 0
+		}
+		chars := 0
+		for _, key := range []string{"text", "thinking", "name"} {
+			if s, ok := c[key].(string); ok {
+				chars += utf8.RuneCountInString(s)
+			}
+		}
+		if input, ok := c["input"]; ok {
+			if data, err := json.Marshal(input); err == nil {
+				chars += len(data)
+			}
+		}
+		return chars + contentChars(c["content"], tokens)
+	default:
+		// Typed content blocks: count them in their JSON form
+		data, err := json.Marshal(c)
+		if err != nil {
+			return 0
+		}
+		var generic interface{}
+		if err := json.Unmarshal(data, &generic); err != nil {
+			return 0
+		}
+		return contentChars(generic, tokens)
+	}
+}
+
+func charsToTokens(chars int) int {
+	return (chars + estimateCharsPerToken - 1) / estimateCharsPerToken
+}
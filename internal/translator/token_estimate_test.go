@@ -0,0 +1,65 @@
+// Package translator provides protocol translation between Anthropic and OpenAI formats.
+package translator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jedarden/clasp/pkg/models"
+)
+
+func TestEstimateTokens(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"", 0},
+		{"abc", 1},
+		{"abcd", 1},
+		{"abcde", 2},
+		{"héllo wörld", 3}, // counted in characters, not bytes
+	}
+	for _, tt := range tests {
+		if got := EstimateTokens(tt.text); got != tt.want {
+			t.Errorf("EstimateTokens(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestEstimateInputTokens(t *testing.T) {
+	req := &models.AnthropicRequest{
+		System: strings.Repeat("s", 40),
+		Messages: []models.AnthropicMessage{
+			{Role: "user", Content: strings.Repeat("u", 40)},
+			{Role: "assistant", Content: []interface{}{
+				map[string]interface{}{"type": "text", "text": strings.Repeat("a", 40)},
+			}},
+			{Role: "user", Content: []interface{}{
+				map[string]interface{}{"type": "image", "source": map[string]interface{}{"data": strings.Repeat("x", 100000)}},
+			}},
+		},
+	}
+
+	// 120 characters of text plus a fixed estimate for the image
+	if got, want := EstimateInputTokens(req), 30+imageTokenEstimate; got != want {
+		t.Errorf("EstimateInputTokens() = %d, want %d", got, want)
+	}
+
+	// Typed content blocks are counted like decoded JSON
+	typed := &models.AnthropicRequest{Messages: []models.AnthropicMessage{
+		{Role: "user", Content: []models.ContentBlock{{Type: "text", Text: strings.Repeat("t", 40)}}},
+	}}
+	if got := EstimateInputTokens(typed); got != 10 {
+		t.Errorf("EstimateInputTokens(typed) = %d, want 10", got)
+	}
+}
+
+func TestEstimateOutputTokens(t *testing.T) {
+	content := []models.AnthropicContentBlock{
+		{Type: "thinking", Thinking: strings.Repeat("t", 20)},
+		{Type: "text", Text: strings.Repeat("a", 20)},
+	}
+	if got := EstimateOutputTokens(content); got != 10 {
+		t.Errorf("EstimateOutputTokens() = %d, want 10", got)
+	}
+}
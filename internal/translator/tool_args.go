@@ -0,0 +1,94 @@
+// Package translator handles protocol translation between Anthropic and OpenAI formats.
+package translator
+
+import (
+	"encoding/json"
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/jedarden/clasp/internal/logging"
+)
+
+// jsonCodeFencePattern matches tool arguments wrapped in a markdown code fence,
+// e.g. "```json\n{...}\n```". Some models emit tool arguments this way.
+var jsonCodeFencePattern = regexp.MustCompile("(?s)^\\s*```[A-Za-z]*[ \\t]*\\r?\\n?(.*?)\\s*```\\s*$")
+
+// StripJSONCodeFence removes a markdown code fence wrapped around tool-call arguments.
+// Arguments without a fence are returned unchanged.
+func StripJSONCodeFence(args string) string {
+	if !strings.Contains(args, "```") {
+		return args
+	}
+	if m := jsonCodeFencePattern.FindStringSubmatch(args); m != nil {
+		return strings.TrimSpace(m[1])
+	}
+	return args
+}
+
+// toolArgsFilter sits between streamed tool-call argument deltas and the emitted
+// input_json_delta events. Arguments that start with a code fence are held back
+// until the tool call completes so the fence can be stripped, and with
+// Options.RepairToolJSON all arguments are held so they can be repaired;
+// otherwise arguments stream through unchanged.
+type toolArgsFilter struct {
+	buf     string
+	emitted int
+	decided bool
+	fenced  bool
+	repair  bool // Set from Options.RepairToolJSON when the filter is created
+}
+
+// append adds an argument delta to the filter.
+func (f *toolArgsFilter) append(chunk string) {
+	f.buf += chunk
+	if f.decided {
+		return
+	}
+	trimmed := strings.TrimLeft(f.buf, " \t\r\n")
+	if trimmed == "" {
+		return
+	}
+	f.decided = true
+	f.fenced = strings.HasPrefix(trimmed, "`")
+}
+
+// ready returns the arguments that can be emitted now.
+func (f *toolArgsFilter) ready() string {
+	if !f.decided || f.fenced || f.repair {
+		return ""
+	}
+	out := f.buf[f.emitted:]
+	f.emitted = len(f.buf)
+	return out
+}
+
+// flush returns any remaining arguments once the tool call is complete,
+// with a surrounding code fence removed and, with RepairToolJSON, malformed
+// JSON repaired.
+func (f *toolArgsFilter) flush() string {
+	if f.fenced || f.repair {
+		if f.emitted == len(f.buf) {
+			return ""
+		}
+		f.emitted = len(f.buf)
+		args := f.buf
+		if f.fenced {
+			args = StripJSONCodeFence(args)
+		}
+		if f.repair {
+			if repaired, ok := RepairToolJSON(args); ok {
+				log.Printf("[CLASP] Repaired malformed tool arguments from the upstream stream")
+				logging.LogDebugMessage("[STREAM] Repaired tool arguments %q -> %q", args, repaired)
+				args = repaired
+			} else if !json.Valid([]byte(args)) {
+				log.Printf("[CLASP] Streamed tool arguments are not valid JSON and could not be repaired")
+			}
+		}
+		return args
+	}
+	if !f.decided {
+		return ""
+	}
+	return f.ready()
+}
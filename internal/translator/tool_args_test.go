@@ -0,0 +1,104 @@
+// Package translator provides protocol translation between Anthropic and OpenAI formats.
+package translator
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestStripJSONCodeFence(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"plain JSON", `{"a":1}`, `{"a":1}`},
+		{"json fence", "```json\n{\"a\":1}\n```", `{"a":1}`},
+		{"bare fence", "```\n{\"a\":1}\n```", `{"a":1}`},
+		{"fence with whitespace", "  ```JSON\n  {\"a\": [1, 2]}\n```  \n", `{"a": [1, 2]}`},
+		{"single-line fence", "```json {\"a\":1}```", `{"a":1}`},
+		{"backticks inside value", `{"code":"use ` + "```" + ` here"}`, `{"code":"use ` + "```" + ` here"}`},
+		{"empty", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := StripJSONCodeFence(tt.input); got != tt.expected {
+				t.Errorf("StripJSONCodeFence(%q) = %q, want %q", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestStreamProcessor_FencedToolArguments(t *testing.T) {
+	var buf bytes.Buffer
+	sp := NewStreamProcessor(&buf, "msg_123", "gpt-4o")
+
+	stream := strings.Join([]string{
+		`data: {"id":"1","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"` + "```json\\n" + `"}}]}}]}`,
+		`data: {"id":"1","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"city\": "}}]}}]}`,
+		`data: {"id":"1","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"Paris\"}\n` + "```" + `"}}]}}]}`,
+		`data: {"id":"1","choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}`,
+		`data: [DONE]`,
+	}, "\n\n")
+
+	if err := sp.ProcessStream(strings.NewReader(stream)); err != nil {
+		t.Fatalf("ProcessStream() error = %v", err)
+	}
+
+	partial := collectPartialJSON(t, buf.String())
+	if partial != `{"city": "Paris"}` {
+		t.Errorf("partial_json = %q, want fence-free arguments", partial)
+	}
+	var input map[string]interface{}
+	if err := json.Unmarshal([]byte(partial), &input); err != nil {
+		t.Errorf("arguments are not valid JSON: %v", err)
+	}
+}
+
+func TestStreamProcessor_UnfencedToolArgumentsStream(t *testing.T) {
+	var buf bytes.Buffer
+	sp := NewStreamProcessor(&buf, "msg_123", "gpt-4o")
+
+	stream := strings.Join([]string{
+		`data: {"id":"1","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{\"city\":"}}]}}]}`,
+		`data: {"id":"1","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"Paris\"}"}}]}}]}`,
+		`data: {"id":"1","choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}`,
+		`data: [DONE]`,
+	}, "\n\n")
+
+	if err := sp.ProcessStream(strings.NewReader(stream)); err != nil {
+		t.Fatalf("ProcessStream() error = %v", err)
+	}
+
+	// Unfenced arguments are streamed incrementally, one delta per chunk
+	if count := strings.Count(buf.String(), "input_json_delta"); count != 2 {
+		t.Errorf("input_json_delta count = %d, want 2", count)
+	}
+	if partial := collectPartialJSON(t, buf.String()); partial != `{"city":"Paris"}` {
+		t.Errorf("partial_json = %q", partial)
+	}
+}
+
+// collectPartialJSON concatenates the partial_json of all input_json_delta events in an SSE stream.
+func collectPartialJSON(t *testing.T, output string) string {
+	t.Helper()
+	var sb strings.Builder
+	for _, line := range strings.Split(output, "\n") {
+		if !strings.HasPrefix(line, "data: ") || !strings.Contains(line, "input_json_delta") {
+			continue
+		}
+		var event struct {
+			Delta struct {
+				PartialJSON string `json:"partial_json"`
+			} `json:"delta"`
+		}
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event); err != nil {
+			t.Fatalf("invalid event %q: %v", line, err)
+		}
+		sb.WriteString(event.Delta.PartialJSON)
+	}
+	return sb.String()
+}
@@ -0,0 +1,105 @@
+// Package translator handles protocol translation between Anthropic and OpenAI formats.
+package translator
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// RepairToolJSON returns args unchanged when they are valid JSON. Otherwise it
+// attempts a lenient repair of the mistakes models make in tool arguments:
+// trailing commas, raw control characters and invalid escapes in strings, and
+// unterminated strings, objects and arrays. It reports whether the arguments
+// were repaired; arguments that cannot be repaired are returned unchanged.
+func RepairToolJSON(args string) (string, bool) {
+	if strings.TrimSpace(args) == "" || json.Valid([]byte(args)) {
+		return args, false
+	}
+
+	out := make([]byte, 0, len(args)+8)
+	var stack []byte
+	inString := false
+	for i := 0; i < len(args); i++ {
+		c := args[i]
+		if inString {
+			switch {
+			case c == '"':
+				inString = false
+				out = append(out, c)
+			case c == '\\':
+				if i+1 < len(args) && strings.IndexByte(`"\/bfnrtu`, args[i+1]) >= 0 {
+					out = append(out, c, args[i+1])
+					i++
+				} else {
+					out = append(out, `\\`...)
+				}
+			case c == '\n':
+				out = append(out, `\n`...)
+			case c == '\r':
+				out = append(out, `\r`...)
+			case c == '\t':
+				out = append(out, `\t`...)
+			case c < 0x20:
+				out = append(out, '\\', 'u', '0', '0', hexDigits[c>>4], hexDigits[c&0xf])
+			default:
+				out = append(out, c)
+			}
+			continue
+		}
+
+		switch c {
+		case '"':
+			inString = true
+		case '{':
+			stack = append(stack, '}')
+		case '[':
+			stack = append(stack, ']')
+		case '}', ']':
+			out = trimTrailingComma(out)
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		}
+		out = append(out, c)
+	}
+
+	// Close whatever the arguments left open
+	if inString {
+		out = append(out, '"')
+	}
+	out = trimTrailingComma(out)
+	if i := lastNonSpace(out); i >= 0 && out[i] == ':' {
+		out = append(out, "null"...)
+	}
+	for i := len(stack) - 1; i >= 0; i-- {
+		out = append(out, stack[i])
+	}
+
+	if !json.Valid(out) {
+		return args, false
+	}
+	return string(out), true
+}
+
+const hexDigits = "0123456789abcdef"
+
+// trimTrailingComma removes a comma, and the whitespace after it, from the end
+// of out.
+func trimTrailingComma(out []byte) []byte {
+	if i := lastNonSpace(out); i >= 0 && out[i] == ',' {
+		return out[:i]
+	}
+	return out
+}
+
+// lastNonSpace returns the index of the last non-whitespace byte in b, or -1.
+func lastNonSpace(b []byte) int {
+	for i := len(b) - 1; i >= 0; i-- {
+		switch b[i] {
+		case ' ', '\t', '\r', '\n':
+		default:
+			return i
+		}
+	}
+	return -1
+}
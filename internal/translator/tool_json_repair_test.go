@@ -0,0 +1,117 @@
+package translator
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestRepairToolJSON(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+		repaired bool
+	}{
+		{"valid", `{"a":1}`, `{"a":1}`, false},
+		{"empty", "", "", false},
+		{"trailing comma in object", `{"a":1,}`, `{"a":1}`, true},
+		{"trailing comma in array", `{"a":[1, 2, ]}`, `{"a":[1, 2]}`, true},
+		{"raw newline in string", "{\"code\":\"line 1\nline 2\"}", `{"code":"line 1\nline 2"}`, true},
+		{"raw tab in string", "{\"a\":\"x\ty\"}", `{"a":"x\ty"}`, true},
+		{"invalid escape", `{"path":"C:\Users\me"}`, `{"path":"C:\\Users\\me"}`, true},
+		{"unterminated string", `{"a":"hello`, `{"a":"hello"}`, true},
+		{"unclosed object", `{"a":{"b":[1,2`, `{"a":{"b":[1,2]}}`, true},
+		{"dangling key", `{"a":1,"b":`, `{"a":1,"b":null}`, true},
+		{"unrepairable", `{"a" 1}`, `{"a" 1}`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, repaired := RepairToolJSON(tt.input)
+			if got != tt.expected || repaired != tt.repaired {
+				t.Errorf("RepairToolJSON(%q) = %q, %v, want %q, %v", tt.input, got, repaired, tt.expected, tt.repaired)
+			}
+		})
+	}
+}
+
+func TestStreamProcessor_RepairsMalformedToolArguments(t *testing.T) {
+	opts := DefaultOptions()
+	opts.RepairToolJSON = true
+
+	var buf bytes.Buffer
+	sp := opts.NewStreamProcessor(&buf, "msg_123", "gpt-4o")
+
+	// A raw newline inside the string and a trailing comma
+	stream := strings.Join([]string{
+		`data: {"id":"1","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"write_file","arguments":"{\"path\": \"a.txt\", "}}]}}]}`,
+		`data: {"id":"1","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"content\": \"one\ntwo\","}}]}}]}`,
+		`data: {"id":"1","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"}"}}]}}]}`,
+		`data: {"id":"1","choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}`,
+		`data: [DONE]`,
+	}, "\n\n")
+
+	if err := sp.ProcessStream(strings.NewReader(stream)); err != nil {
+		t.Fatalf("ProcessStream() error = %v", err)
+	}
+
+	// Arguments are held until the tool call completes, then emitted once
+	if count := strings.Count(buf.String(), "input_json_delta"); count != 1 {
+		t.Errorf("input_json_delta count = %d, want 1", count)
+	}
+	var input map[string]interface{}
+	partial := collectPartialJSON(t, buf.String())
+	if err := json.Unmarshal([]byte(partial), &input); err != nil {
+		t.Fatalf("partial_json %q is not valid JSON: %v", partial, err)
+	}
+	if input["path"] != "a.txt" || input["content"] != "one\ntwo" {
+		t.Errorf("input = %v", input)
+	}
+}
+
+func TestStreamProcessor_RepairKeepsValidToolArguments(t *testing.T) {
+	opts := DefaultOptions()
+	opts.RepairToolJSON = true
+
+	var buf bytes.Buffer
+	sp := opts.NewStreamProcessor(&buf, "msg_123", "gpt-4o")
+
+	stream := strings.Join([]string{
+		`data: {"id":"1","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{\"city\":"}}]}}]}`,
+		`data: {"id":"1","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"Paris\"}"}}]}}]}`,
+		`data: {"id":"1","choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}`,
+		`data: [DONE]`,
+	}, "\n\n")
+
+	if err := sp.ProcessStream(strings.NewReader(stream)); err != nil {
+		t.Fatalf("ProcessStream() error = %v", err)
+	}
+	if partial := collectPartialJSON(t, buf.String()); partial != `{"city":"Paris"}` {
+		t.Errorf("partial_json = %q, want the arguments unchanged", partial)
+	}
+}
+
+func TestResponsesStreamProcessor_RepairsMalformedToolArguments(t *testing.T) {
+	opts := DefaultOptions()
+	opts.RepairToolJSON = true
+
+	var buf bytes.Buffer
+	sp := opts.NewResponsesStreamProcessor(&buf, "msg_123", "gpt-5")
+
+	stream := strings.Join([]string{
+		`data: {"type":"response.output_item.added","output_index":0,"item":{"type":"function_call","id":"fc_1","call_id":"call_1","name":"get_weather"}}`,
+		`data: {"type":"response.function_call_arguments.delta","output_index":0,"delta":"{\"city\":\"Paris\","}`,
+		`data: {"type":"response.function_call_arguments.delta","output_index":0,"delta":"\"units\":[\"c\",]"}`,
+		`data: {"type":"response.output_item.done","output_index":0,"item":{"type":"function_call","id":"fc_1","call_id":"call_1","name":"get_weather"}}`,
+		`data: {"type":"response.completed","response":{"id":"resp_1","status":"completed","usage":{"input_tokens":5,"output_tokens":3}}}`,
+	}, "\n\n")
+
+	if err := sp.ProcessStream(strings.NewReader(stream)); err != nil {
+		t.Fatalf("ProcessStream() error = %v", err)
+	}
+	if partial := collectPartialJSON(t, buf.String()); partial != `{"city":"Paris","units":["c"]}` {
+		t.Errorf("partial_json = %q", partial)
+	}
+}
@@ -16,6 +16,7 @@ type ResponsesRequest struct {
 	PreviousResponseID string              `json:"previous_response_id,omitempty"`
 	Tools              []ResponsesTool     `json:"tools,omitempty"`
 	ToolChoice         interface{}         `json:"tool_choice,omitempty"`
+	ParallelToolCalls  *bool               `json:"parallel_tool_calls,omitempty"`
 	MaxOutputTokens    int                 `json:"max_output_tokens,omitempty"`
 	Stream             bool                `json:"stream,omitempty"`
 	Temperature        *float64            `json:"temperature,omitempty"`
@@ -24,12 +25,29 @@ type ResponsesRequest struct {
 	Metadata           map[string]string   `json:"metadata,omitempty"`
 	Reasoning          *ResponsesReasoning `json:"reasoning,omitempty"`
 	Instructions       string              `json:"instructions,omitempty"`
+	Text               *ResponsesText      `json:"text,omitempty"`
+	ServiceTier        string              `json:"service_tier,omitempty"`
+	Store              *bool               `json:"store,omitempty"`
+}
+
+// ResponsesText configures the text output of a Responses request.
+type ResponsesText struct {
+	Format *ResponsesTextFormat `json:"format,omitempty"`
+}
+
+// ResponsesTextFormat selects JSON mode for the Responses API.
+// Unlike Chat Completions, the schema fields sit directly on the format object.
+type ResponsesTextFormat struct {
+	Type   string      `json:"type"` // "text", "json_object" or "json_schema"
+	Name   string      `json:"name,omitempty"`
+	Schema interface{} `json:"schema,omitempty"`
 }
 
 // ResponsesReasoning represents the nested reasoning configuration for Responses API.
 // The Responses API requires reasoning parameters under this nested object.
 type ResponsesReasoning struct {
-	Effort string `json:"effort,omitempty"` // "low", "medium", "high"
+	Effort  string `json:"effort,omitempty"`  // "low", "medium", "high"
+	Summary string `json:"summary,omitempty"` // "auto", "concise", "detailed"
 }
 
 // ResponsesInput represents an input item in a Responses request.
@@ -56,10 +74,14 @@ type ResponsesInput struct {
 // Note: Responses API uses "input_text" and "input_image" for user/input content types,
 // and "output_text" for assistant/output content types.
 type ResponsesContentPart struct {
-	Type     string              `json:"type"` // "input_text", "input_image", "output_text", "input_audio", "text", "image_url"
+	Type     string              `json:"type"` // "input_text", "input_image", "input_file", "output_text", "input_audio", "text", "image_url"
 	Text     string              `json:"text,omitempty"`
 	ImageURL *ImageURL           `json:"image_url,omitempty"`
 	Audio    *ResponsesAudioPart `json:"input_audio,omitempty"`
+	// File fields (type: "input_file")
+	Filename string `json:"filename,omitempty"`
+	FileData string `json:"file_data,omitempty"`
+	FileURL  string `json:"file_url,omitempty"`
 }
 
 // ResponsesAudioPart represents audio input in Responses API.
@@ -124,11 +146,18 @@ type ResponsesResponse struct {
 	Object    string            `json:"object"` // "response"
 	CreatedAt int64             `json:"created_at"`
 	Model     string            `json:"model"`
-	Status    string            `json:"status"` // "completed", "in_progress", "failed", "canceled"
+	Status    string            `json:"status"` // "completed", "in_progress", "queued", "incomplete", "failed", "canceled"
 	Output    []ResponsesItem   `json:"output"`
 	Usage     *ResponsesUsage   `json:"usage,omitempty"`
 	Metadata  map[string]string `json:"metadata,omitempty"`
 	Error     *ResponsesError   `json:"error,omitempty"`
+
+	IncompleteDetails *ResponsesIncompleteDetails `json:"incomplete_details,omitempty"`
+}
+
+// ResponsesIncompleteDetails explains why a response has status "incomplete".
+type ResponsesIncompleteDetails struct {
+	Reason string `json:"reason"` // "max_output_tokens" or "content_filter"
 }
 
 // ResponsesItem represents an output item in Responses API.
@@ -197,11 +226,35 @@ type ResponsesAnnotation struct {
 type ResponsesUsage struct {
 	InputTokens              int                    `json:"input_tokens"`
 	OutputTokens             int                    `json:"output_tokens"`
+	InputTokensDetails       *ResponsesInputDetails `json:"input_tokens_details,omitempty"`
 	OutputTokensDetails      *ResponsesTokenDetails `json:"output_tokens_details,omitempty"`
 	CacheReadInputTokens     int                    `json:"cache_read_input_tokens,omitempty"`
 	CacheCreationInputTokens int                    `json:"cache_creation_input_tokens,omitempty"`
 }
 
+// ResponsesInputDetails breaks down the input tokens.
+type ResponsesInputDetails struct {
+	CachedTokens int `json:"cached_tokens"`
+}
+
+// AnthropicUsage converts the usage to Anthropic's format, moving the cached
+// input tokens out of input_tokens.
+func (u *ResponsesUsage) AnthropicUsage() *AnthropicUsage {
+	usage := &AnthropicUsage{
+		OutputTokens:             u.OutputTokens,
+		CacheReadInputTokens:     u.CacheReadInputTokens,
+		CacheCreationInputTokens: u.CacheCreationInputTokens,
+	}
+	if u.InputTokensDetails != nil && u.InputTokensDetails.CachedTokens > 0 {
+		usage.CacheReadInputTokens = u.InputTokensDetails.CachedTokens
+	}
+	usage.InputTokens = u.InputTokens - usage.CacheReadInputTokens - usage.CacheCreationInputTokens
+	if usage.InputTokens < 0 {
+		usage.InputTokens = 0
+	}
+	return usage
+}
+
 // ResponsesTokenDetails provides detailed token breakdown.
 type ResponsesTokenDetails struct {
 	ReasoningTokens int `json:"reasoning_tokens"`
@@ -231,6 +284,7 @@ type ResponsesStreamEvent struct {
 	DeltaText      string `json:"-"`              // Populated from delta field after checking type (not a direct JSON field)
 	Text           string `json:"text,omitempty"` // Complete text for done events
 	SequenceNumber int    `json:"sequence_number,omitempty"`
+	SummaryIndex   int    `json:"summary_index,omitempty"` // For reasoning_summary_* events
 
 	// Part field for content_part events
 	Part *ResponsesOutputContentPart `json:"part,omitempty"`
@@ -1,6 +1,8 @@
 // Package models defines shared types for the CLASP proxy.
 package models
 
+import "encoding/json"
+
 // AnthropicRequest represents an incoming Anthropic Messages API request.
 type AnthropicRequest struct {
 	Model         string             `json:"model"`
@@ -15,7 +17,29 @@ type AnthropicRequest struct {
 	Tools         []AnthropicTool    `json:"tools,omitempty"`
 	ToolChoice    interface{}        `json:"tool_choice,omitempty"`
 	Metadata      *Metadata          `json:"metadata,omitempty"`
-	Thinking      *ThinkingConfig    `json:"thinking,omitempty"` // Extended thinking configuration
+	ServiceTier   string             `json:"service_tier,omitempty"`  // "auto" or "standard_only"
+	Thinking      *ThinkingConfig    `json:"thinking,omitempty"`      // Extended thinking configuration
+	OutputFormat  *OutputFormat      `json:"output_format,omitempty"` // Structured output hint
+	// Anthropic server tool settings, forwarded in passthrough and ignored by
+	// translated providers
+	MCPServers interface{} `json:"mcp_servers,omitempty"`
+	Container  interface{} `json:"container,omitempty"`
+	// CLASP extensions: override CLASP_PARALLEL_TOOL_CALLS, CLASP_SEED and the
+	// CLASP_*_PENALTY defaults for this request, and request n candidates (see CLASP_MULTI_CHOICE)
+	ParallelToolCalls *bool    `json:"parallel_tool_calls,omitempty"`
+	Seed              *int     `json:"seed,omitempty"`
+	PresencePenalty   *float64 `json:"presence_penalty,omitempty"`
+	FrequencyPenalty  *float64 `json:"frequency_penalty,omitempty"`
+	N                 int      `json:"n,omitempty"`
+	// anthropic-beta flags from the request headers; not part of the body
+	Betas []string `json:"-"`
+}
+
+// OutputFormat requests structured JSON output from the model.
+type OutputFormat struct {
+	Type   string      `json:"type"`             // "json", "json_object" or "json_schema"
+	Name   string      `json:"name,omitempty"`   // Schema name for "json_schema"
+	Schema interface{} `json:"schema,omitempty"` // JSON Schema for "json_schema"
 }
 
 // ThinkingConfig represents the Anthropic thinking/extended reasoning configuration.
@@ -35,6 +59,8 @@ type ContentBlock struct {
 	Type   string       `json:"type"`
 	Text   string       `json:"text,omitempty"`
 	Source *ImageSource `json:"source,omitempty"`
+	// Document fields (type: "document")
+	Title string `json:"title,omitempty"`
 	// Tool use fields
 	ID    string      `json:"id,omitempty"`
 	Name  string      `json:"name,omitempty"`
@@ -43,21 +69,24 @@ type ContentBlock struct {
 	ToolUseID string      `json:"tool_use_id,omitempty"`
 	Content   interface{} `json:"content,omitempty"` // Can be string or []ContentBlock for tool results
 	IsError   bool        `json:"is_error,omitempty"`
-	// Cache control (Anthropic-specific, stripped during translation)
+	// Cache control (Anthropic-specific, kept only with the prompt caching beta)
 	CacheControl *CacheControl `json:"cache_control,omitempty"`
 }
 
 // CacheControl represents Anthropic's prompt caching configuration.
-// This is stripped during translation as OpenAI doesn't support prompt caching.
+// It is stripped during translation unless the client sends the prompt caching
+// beta and the provider honors the markers (OpenRouter).
 type CacheControl struct {
 	Type string `json:"type"` // "ephemeral"
 }
 
-// ImageSource represents an image source in Anthropic format.
+// ImageSource represents an image or document source in Anthropic format.
+// Type is "base64", "text", or "url".
 type ImageSource struct {
 	Type      string `json:"type"`
 	MediaType string `json:"media_type"`
 	Data      string `json:"data"`
+	URL       string `json:"url,omitempty"`
 }
 
 // AnthropicTool represents a tool definition in Anthropic format.
@@ -69,6 +98,56 @@ type AnthropicTool struct {
 	Type string `json:"type,omitempty"`
 	// Cache control (Anthropic-specific, stripped during translation)
 	CacheControl *CacheControl `json:"cache_control,omitempty"`
+	// Other fields of typed tools, e.g. max_uses of a web search server tool,
+	// kept so passthrough forwards the tool unchanged
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// anthropicToolFields are the AnthropicTool fields decoded into struct fields
+// rather than Extra.
+var anthropicToolFields = []string{"name", "description", "input_schema", "type", "cache_control"}
+
+// UnmarshalJSON decodes a tool, keeping fields CLASP doesn't model in Extra.
+func (t *AnthropicTool) UnmarshalJSON(data []byte) error {
+	type Alias AnthropicTool
+	if err := json.Unmarshal(data, (*Alias)(t)); err != nil {
+		return err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	for _, name := range anthropicToolFields {
+		delete(fields, name)
+	}
+	t.Extra = nil
+	if len(fields) > 0 {
+		t.Extra = fields
+	}
+	return nil
+}
+
+// MarshalJSON encodes a tool with its Extra fields. Typed tools without an
+// input schema, such as server tools, are encoded without input_schema.
+func (t AnthropicTool) MarshalJSON() ([]byte, error) {
+	type Alias AnthropicTool
+	data, err := json.Marshal(Alias(t))
+	if err != nil || (len(t.Extra) == 0 && (t.Type == "" || t.InputSchema != nil)) {
+		return data, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	if t.Type != "" && t.InputSchema == nil {
+		delete(fields, "input_schema")
+	}
+	for name, value := range t.Extra {
+		if _, ok := fields[name]; !ok {
+			fields[name] = value
+		}
+	}
+	return json.Marshal(fields)
 }
 
 // Computer use tool type constants
@@ -85,18 +164,27 @@ type Metadata struct {
 
 // OpenAIRequest represents an outgoing OpenAI Chat Completions API request.
 type OpenAIRequest struct {
-	Model               string          `json:"model"`
-	Messages            []OpenAIMessage `json:"messages"`
-	MaxTokens           int             `json:"max_tokens,omitempty"`
-	MaxCompletionTokens int             `json:"max_completion_tokens,omitempty"` // For O1/O3 reasoning models
-	Stream              bool            `json:"stream,omitempty"`
-	Stop                []string        `json:"stop,omitempty"`
-	Temperature         *float64        `json:"temperature,omitempty"`
-	TopP                *float64        `json:"top_p,omitempty"`
-	Tools               []OpenAITool    `json:"tools,omitempty"`
-	ToolChoice          interface{}     `json:"tool_choice,omitempty"`
-	StreamOptions       *StreamOptions  `json:"stream_options,omitempty"`
-	ReasoningEffort     string          `json:"reasoning_effort,omitempty"` // O1/O3: "minimal", "low", "medium", "high"
+	Model               string             `json:"model"`
+	Messages            []OpenAIMessage    `json:"messages"`
+	MaxTokens           int                `json:"max_tokens,omitempty"`
+	MaxCompletionTokens int                `json:"max_completion_tokens,omitempty"` // For O1/O3 reasoning models
+	Stream              bool               `json:"stream,omitempty"`
+	Stop                []string           `json:"stop,omitempty"`
+	Temperature         *float64           `json:"temperature,omitempty"`
+	TopP                *float64           `json:"top_p,omitempty"`
+	TopK                *int               `json:"top_k,omitempty"` // Not part of the OpenAI API; accepted by OpenRouter, Ollama, Qwen and most self-hosted servers
+	LogitBias           map[string]float64 `json:"logit_bias,omitempty"`
+	ResponseFormat      *ResponseFormat    `json:"response_format,omitempty"`
+	ParallelToolCalls   *bool              `json:"parallel_tool_calls,omitempty"`
+	Seed                *int               `json:"seed,omitempty"`
+	PresencePenalty     *float64           `json:"presence_penalty,omitempty"`
+	FrequencyPenalty    *float64           `json:"frequency_penalty,omitempty"`
+	N                   int                `json:"n,omitempty"`
+	Tools               []OpenAITool       `json:"tools,omitempty"`
+	ToolChoice          interface{}        `json:"tool_choice,omitempty"`
+	StreamOptions       *StreamOptions     `json:"stream_options,omitempty"`
+	ReasoningEffort     string             `json:"reasoning_effort,omitempty"` // O1/O3: "minimal", "low", "medium", "high"
+	ServiceTier         string             `json:"service_tier,omitempty"`     // OpenAI: "auto", "default", "flex" or "priority"
 	// Provider-specific reasoning parameters (OpenRouter)
 	ThinkingConfig *OpenRouterThinkingConfig `json:"thinking_config,omitempty"` // Gemini 2.5
 	ThinkingLevel  string                    `json:"thinking_level,omitempty"`  // Gemini 3
@@ -105,6 +193,18 @@ type OpenAIRequest struct {
 	ReasoningSplit *bool                     `json:"reasoning_split,omitempty"` // MiniMax
 }
 
+// ResponseFormat selects JSON mode for Chat Completions.
+type ResponseFormat struct {
+	Type       string            `json:"type"` // "json_object" or "json_schema"
+	JSONSchema *JSONSchemaFormat `json:"json_schema,omitempty"`
+}
+
+// JSONSchemaFormat describes the schema for response_format type "json_schema".
+type JSONSchemaFormat struct {
+	Name   string      `json:"name"`
+	Schema interface{} `json:"schema,omitempty"`
+}
+
 // OpenRouterThinkingConfig for Gemini 2.5 models.
 type OpenRouterThinkingConfig struct {
 	ThinkingBudget int `json:"thinking_budget"`
@@ -120,9 +220,17 @@ type OpenAIMessage struct {
 
 // OpenAIContentPart represents a content part in OpenAI messages.
 type OpenAIContentPart struct {
-	Type     string    `json:"type"`
-	Text     string    `json:"text,omitempty"`
-	ImageURL *ImageURL `json:"image_url,omitempty"`
+	Type         string        `json:"type"`
+	Text         string        `json:"text,omitempty"`
+	ImageURL     *ImageURL     `json:"image_url,omitempty"`
+	File         *FilePart     `json:"file,omitempty"`
+	CacheControl *CacheControl `json:"cache_control,omitempty"` // OpenRouter prompt caching
+}
+
+// FilePart represents an inline file (e.g. a PDF) in OpenAI Chat Completions format.
+type FilePart struct {
+	Filename string `json:"filename,omitempty"`
+	FileData string `json:"file_data,omitempty"` // data:<media_type>;base64,<data>
 }
 
 // ImageURL represents an image URL in OpenAI format.
@@ -197,9 +305,40 @@ type ReasoningContent struct {
 
 // Usage represents token usage information.
 type Usage struct {
-	PromptTokens     int `json:"prompt_tokens"`
-	CompletionTokens int `json:"completion_tokens"`
-	TotalTokens      int `json:"total_tokens"`
+	PromptTokens        int                  `json:"prompt_tokens"`
+	CompletionTokens    int                  `json:"completion_tokens"`
+	TotalTokens         int                  `json:"total_tokens"`
+	PromptTokensDetails *PromptTokensDetails `json:"prompt_tokens_details,omitempty"`
+	// DeepSeek reports prompt cache usage as hit and miss counts
+	PromptCacheHitTokens  int `json:"prompt_cache_hit_tokens,omitempty"`
+	PromptCacheMissTokens int `json:"prompt_cache_miss_tokens,omitempty"`
+}
+
+// PromptTokensDetails breaks down the prompt tokens. OpenAI and OpenRouter
+// report prompt cache reads as cached_tokens; OpenRouter also reports cache
+// writes as cache_write_tokens.
+type PromptTokensDetails struct {
+	CachedTokens     int `json:"cached_tokens,omitempty"`
+	CacheWriteTokens int `json:"cache_write_tokens,omitempty"`
+}
+
+// AnthropicUsage converts the usage to Anthropic's format, in which
+// input_tokens counts only the prompt tokens that were neither read from nor
+// written to the prompt cache.
+func (u *Usage) AnthropicUsage() *AnthropicUsage {
+	usage := &AnthropicUsage{OutputTokens: u.CompletionTokens}
+	if u.PromptTokensDetails != nil {
+		usage.CacheReadInputTokens = u.PromptTokensDetails.CachedTokens
+		usage.CacheCreationInputTokens = u.PromptTokensDetails.CacheWriteTokens
+	}
+	if usage.CacheReadInputTokens == 0 {
+		usage.CacheReadInputTokens = u.PromptCacheHitTokens
+	}
+	usage.InputTokens = u.PromptTokens - usage.CacheReadInputTokens - usage.CacheCreationInputTokens
+	if usage.InputTokens < 0 {
+		usage.InputTokens = 0
+	}
+	return usage
 }
 
 // AnthropicResponse represents a response in Anthropic format.
@@ -212,21 +351,33 @@ type AnthropicResponse struct {
 	StopReason   string                  `json:"stop_reason,omitempty"`
 	StopSequence string                  `json:"stop_sequence,omitempty"`
 	Usage        *AnthropicUsage         `json:"usage,omitempty"`
+	// CLASP extension: additional candidates when n>1 and CLASP_MULTI_CHOICE=return
+	Alternatives []AnthropicAlternative `json:"clasp_alternatives,omitempty"`
+}
+
+// AnthropicAlternative is an extra response candidate from an n>1 request.
+type AnthropicAlternative struct {
+	Content    []AnthropicContentBlock `json:"content"`
+	StopReason string                  `json:"stop_reason,omitempty"`
 }
 
 // AnthropicContentBlock represents a content block in Anthropic response.
 type AnthropicContentBlock struct {
-	Type  string      `json:"type"`
-	Text  string      `json:"text,omitempty"`
-	ID    string      `json:"id,omitempty"`
-	Name  string      `json:"name,omitempty"`
-	Input interface{} `json:"input,omitempty"`
+	Type     string      `json:"type"`
+	Text     string      `json:"text,omitempty"`
+	ID       string      `json:"id,omitempty"`
+	Name     string      `json:"name,omitempty"`
+	Input    interface{} `json:"input,omitempty"`
+	Thinking string      `json:"thinking,omitempty"` // For thinking blocks
 }
 
-// AnthropicUsage represents usage in Anthropic format.
+// AnthropicUsage represents usage in Anthropic format. InputTokens excludes
+// the prompt tokens read from or written to the prompt cache.
 type AnthropicUsage struct {
-	InputTokens  int `json:"input_tokens"`
-	OutputTokens int `json:"output_tokens"`
+	InputTokens              int `json:"input_tokens"`
+	OutputTokens             int `json:"output_tokens"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens,omitempty"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens,omitempty"`
 }
 
 // SSE Event types for Anthropic streaming.
@@ -298,7 +449,9 @@ type MessageDeltaData struct {
 
 // MessageDeltaUsage represents usage in a message_delta event.
 type MessageDeltaUsage struct {
-	OutputTokens int `json:"output_tokens"`
+	OutputTokens             int `json:"output_tokens"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens,omitempty"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens,omitempty"`
 }
 
 // MessageStopEvent represents a message_stop SSE event.
@@ -0,0 +1,140 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jedarden/clasp/internal/config"
+	"github.com/jedarden/clasp/internal/proxy"
+)
+
+// adminFeatures sends a request to /admin/features and returns the decoded
+// feature states.
+func adminFeatures(t *testing.T, handler *proxy.Handler, method, body string) (*httptest.ResponseRecorder, map[string]bool) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	handler.HandleAdminFeatures(rec, httptest.NewRequest(method, "/admin/features", strings.NewReader(body)))
+	var resp struct {
+		Features map[string]bool `json:"features"`
+	}
+	_ = json.Unmarshal(rec.Body.Bytes(), &resp)
+	return rec, resp.Features
+}
+
+func TestAdminFeatures_ToggleCache(t *testing.T) {
+	var upstreamCalls int32
+	handler := newTestHandler(t, func(cfg *config.Config) {
+		cfg.AuthEnabled = true
+		cfg.AuthAPIKey = "proxy-key"
+	}, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&upstreamCalls, 1)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"chatcmpl-1","choices":[{"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}],"usage":{"prompt_tokens":10,"completion_tokens":5}}`))
+	})
+	handler.SetCache(proxy.NewRequestCache(100, time.Hour))
+
+	postMessages(t, handler, simpleRequest())
+	if rec := postMessages(t, handler, simpleRequest()); rec.Header().Get("X-CLASP-Cache") != "HIT" {
+		t.Fatalf("Expected a cache HIT before toggling, got %q", rec.Header().Get("X-CLASP-Cache"))
+	}
+
+	rec, features := adminFeatures(t, handler, http.MethodPost, `{"cache":false}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if features["cache"] {
+		t.Error("Expected cache to be reported as disabled")
+	}
+
+	for i := 0; i < 2; i++ {
+		if rec := postMessages(t, handler, simpleRequest()); rec.Header().Get("X-CLASP-Cache") != "MISS" {
+			t.Errorf("Expected a cache MISS after disabling the cache, got %q", rec.Header().Get("X-CLASP-Cache"))
+		}
+	}
+	if calls := atomic.LoadInt32(&upstreamCalls); calls != 3 {
+		t.Errorf("Expected 3 upstream calls, got %d", calls)
+	}
+
+	if rec, features := adminFeatures(t, handler, http.MethodPost, `{"cache":true}`); rec.Code != http.StatusOK || !features["cache"] {
+		t.Fatalf("Expected cache to be re-enabled, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := postMessages(t, handler, simpleRequest()); rec.Header().Get("X-CLASP-Cache") != "HIT" {
+		t.Errorf("Expected a cache HIT after re-enabling the cache, got %q", rec.Header().Get("X-CLASP-Cache"))
+	}
+}
+
+func TestAdminFeatures_Get(t *testing.T) {
+	handler := newTestHandler(t, func(cfg *config.Config) {
+		cfg.AuthEnabled = true
+		cfg.AuthAPIKey = "proxy-key"
+	}, func(w http.ResponseWriter, r *http.Request) {})
+	handler.SetRateLimiter(proxy.NewRateLimiter(60, 60, 10))
+
+	rec, features := adminFeatures(t, handler, http.MethodGet, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	expected := map[string]bool{"cache": false, "prompt_cache": false, "rate_limit": true, "fallback": false}
+	for name, want := range expected {
+		got, ok := features[name]
+		if !ok {
+			t.Errorf("Expected feature %s to be reported", name)
+		} else if got != want {
+			t.Errorf("Expected %s=%v, got %v", name, want, got)
+		}
+	}
+}
+
+func TestAdminFeatures_InvalidRequests(t *testing.T) {
+	handler := newTestHandler(t, func(cfg *config.Config) {
+		cfg.AuthEnabled = true
+		cfg.AuthAPIKey = "proxy-key"
+	}, func(w http.ResponseWriter, r *http.Request) {})
+	handler.SetRateLimiter(proxy.NewRateLimiter(60, 60, 10))
+
+	tests := []struct {
+		name   string
+		method string
+		body   string
+		status int
+	}{
+		{"malformed body", http.MethodPost, `{"cache":`, http.StatusBadRequest},
+		{"non-boolean value", http.MethodPost, `{"cache":"off"}`, http.StatusBadRequest},
+		{"unknown feature", http.MethodPost, `{"turbo":true}`, http.StatusBadRequest},
+		{"unconfigured feature", http.MethodPost, `{"cache":true}`, http.StatusBadRequest},
+		{"unsupported method", http.MethodDelete, "", http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec, _ := adminFeatures(t, handler, tt.method, tt.body)
+			if rec.Code != tt.status {
+				t.Errorf("Expected status %d, got %d: %s", tt.status, rec.Code, rec.Body.String())
+			}
+		})
+	}
+
+	// A rejected request changes nothing, even if other features were valid
+	adminFeatures(t, handler, http.MethodPost, `{"rate_limit":false,"turbo":true}`)
+	if _, features := adminFeatures(t, handler, http.MethodGet, ""); !features["rate_limit"] {
+		t.Error("Expected rate_limit to stay enabled after a rejected request")
+	}
+}
+
+func TestAdminFeatures_RequiresAuth(t *testing.T) {
+	handler := newTestHandler(t, nil, func(w http.ResponseWriter, r *http.Request) {})
+	handler.SetRateLimiter(proxy.NewRateLimiter(60, 60, 10))
+
+	for _, method := range []string{http.MethodGet, http.MethodPost} {
+		if rec, _ := adminFeatures(t, handler, method, `{"rate_limit":false}`); rec.Code != http.StatusForbidden {
+			t.Errorf("%s: expected 403 with authentication disabled, got %d: %s", method, rec.Code, rec.Body.String())
+		}
+	}
+	if states := handler.FeatureStates(); !states["rate_limit"] {
+		t.Error("Expected rate limiting to stay enabled")
+	}
+}
@@ -0,0 +1,140 @@
+package tests
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jedarden/clasp/internal/proxy"
+)
+
+// newAlertCapture starts a webhook server that forwards each payload to the returned channel.
+func newAlertCapture(t *testing.T) (string, <-chan map[string]interface{}) {
+	t.Helper()
+	received := make(chan map[string]interface{}, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("Unexpected alert request: %s %s", r.Method, r.Header.Get("Content-Type"))
+		}
+		data, _ := io.ReadAll(r.Body)
+		var payload map[string]interface{}
+		if err := json.Unmarshal(data, &payload); err != nil {
+			t.Errorf("Invalid alert payload %s: %v", data, err)
+		}
+		received <- payload
+	}))
+	t.Cleanup(server.Close)
+	return server.URL, received
+}
+
+// nextAlert waits for the next alert payload.
+func nextAlert(t *testing.T, received <-chan map[string]interface{}) map[string]interface{} {
+	t.Helper()
+	select {
+	case payload := <-received:
+		return payload
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for alert")
+		return nil
+	}
+}
+
+func TestAlerts_CircuitBreakerOpen(t *testing.T) {
+	url, received := newAlertCapture(t)
+	alerter := proxy.NewAlerter(url, 0)
+	cb := proxy.NewCircuitBreaker(2, 1, time.Minute)
+	cb.SetStateChangeHook(alerter.CircuitBreakerHook("openai"))
+
+	cb.RecordFailure()
+	cb.RecordFailure()
+	if !cb.IsOpen() {
+		t.Fatal("Expected the circuit breaker to be open")
+	}
+
+	payload := nextAlert(t, received)
+	want := map[string]interface{}{
+		"event":          "circuit_breaker",
+		"provider":       "openai",
+		"state":          "open",
+		"previous_state": "closed",
+	}
+	for key, value := range want {
+		if payload[key] != value {
+			t.Errorf("%s = %v, want %v", key, payload[key], value)
+		}
+	}
+	timestamp, _ := payload["timestamp"].(string)
+	if _, err := time.Parse(time.RFC3339, timestamp); err != nil {
+		t.Errorf("Expected an RFC 3339 timestamp, got %v", payload["timestamp"])
+	}
+
+	// Further failures while open don't send more alerts
+	cb.RecordFailure()
+	select {
+	case extra := <-received:
+		t.Errorf("Unexpected alert: %v", extra)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestAlerts_CircuitBreakerRecovery(t *testing.T) {
+	url, received := newAlertCapture(t)
+	alerter := proxy.NewAlerter(url, 0)
+	cb := proxy.NewCircuitBreaker(1, 1, 10*time.Millisecond)
+	cb.SetStateChangeHook(alerter.CircuitBreakerHook("openai"))
+
+	cb.RecordFailure()
+	time.Sleep(20 * time.Millisecond)
+	if !cb.Allow() {
+		t.Fatal("Expected the circuit breaker to allow a trial request")
+	}
+	cb.RecordSuccess()
+
+	// Deliveries run concurrently, so collect the states before checking them
+	states := map[string]string{}
+	for i := 0; i < 3; i++ {
+		payload := nextAlert(t, received)
+		states[payload["state"].(string)] = payload["previous_state"].(string)
+	}
+	want := map[string]string{"open": "closed", "half-open": "open", "closed": "half-open"}
+	for state, previous := range want {
+		if states[state] != previous {
+			t.Errorf("Expected a %s -> %s alert, got %v", previous, state, states)
+		}
+	}
+}
+
+func TestAlerts_BudgetExceeded(t *testing.T) {
+	url, received := newAlertCapture(t)
+	alerter := proxy.NewAlerter(url, 1.0)
+	tracker := proxy.NewCostTracker()
+	tracker.SetUsageHook(alerter.CheckBudget)
+
+	// gpt-4o input is $2.50 per 1M tokens
+	tracker.RecordUsage("openai", "gpt-4o", 200000, 0)
+	select {
+	case payload := <-received:
+		t.Fatalf("Unexpected alert below the budget: %v", payload)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	tracker.RecordUsage("openai", "gpt-4o", 300000, 0)
+	payload := nextAlert(t, received)
+	if payload["event"] != "budget_exceeded" || payload["budget_usd"] != 1.0 {
+		t.Errorf("Unexpected budget alert: %v", payload)
+	}
+	if spend, _ := payload["spend_usd"].(float64); spend < 1.0 {
+		t.Errorf("Expected spend of at least $1, got %v", payload["spend_usd"])
+	}
+
+	// Only the first crossing alerts
+	tracker.RecordUsage("openai", "gpt-4o", 100000, 0)
+	select {
+	case extra := <-received:
+		t.Errorf("Unexpected second budget alert: %v", extra)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -214,3 +214,90 @@ func TestModelAlias_EmptyValues(t *testing.T) {
 		t.Error("empty key should not create alias")
 	}
 }
+
+func TestModelAlias_GlobRule(t *testing.T) {
+	rule, err := config.NewAliasRule("claude-*-haiku-*", "gpt-4o-mini", true)
+	if err != nil {
+		t.Fatalf("NewAliasRule error: %v", err)
+	}
+	cfg := config.DefaultConfig()
+	cfg.AliasRules = []config.AliasRule{rule}
+
+	tests := map[string]string{
+		"claude-3-5-haiku-20241022":  "gpt-4o-mini",
+		"CLAUDE-3-HAIKU-20240307":    "gpt-4o-mini",
+		"claude-3-5-sonnet-20241022": "claude-3-5-sonnet-20241022",
+		// A glob must match the whole name
+		"my-claude-3-haiku-latest": "my-claude-3-haiku-latest",
+	}
+	for input, expected := range tests {
+		if result := cfg.ResolveAlias(input); result != expected {
+			t.Errorf("ResolveAlias(%q) = %q, want %q", input, result, expected)
+		}
+	}
+}
+
+func TestModelAlias_RegexRuleFromEnv(t *testing.T) {
+	os.Setenv("OPENAI_API_KEY", "test-key")
+	os.Setenv("CLASP_ALIAS_REGEX", "^claude-3-.*haiku.*=gpt-4o-mini; ^claude-.*opus=o3")
+	defer os.Unsetenv("OPENAI_API_KEY")
+	defer os.Unsetenv("CLASP_ALIAS_REGEX")
+
+	cfg, err := config.LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv error: %v", err)
+	}
+	if len(cfg.AliasRules) != 2 {
+		t.Fatalf("expected 2 alias rules, got %d", len(cfg.AliasRules))
+	}
+	if _, ok := cfg.GetAliases()["regex"]; ok {
+		t.Error("CLASP_ALIAS_REGEX should not be loaded as an exact alias named regex")
+	}
+	if result := cfg.ResolveAlias("claude-3-5-haiku-20241022"); result != "gpt-4o-mini" {
+		t.Errorf("expected gpt-4o-mini, got %s", result)
+	}
+	if result := cfg.ResolveAlias("claude-opus-4-20250514"); result != "o3" {
+		t.Errorf("expected o3, got %s", result)
+	}
+
+	os.Setenv("CLASP_ALIAS_REGEX", "^claude-(=gpt-4o")
+	if _, err := config.LoadFromEnv(); err == nil {
+		t.Error("expected an error for an invalid regular expression")
+	}
+}
+
+func TestModelAlias_Precedence(t *testing.T) {
+	haikuRule, err := config.NewAliasRule("^claude-3-.*haiku", "gpt-4o-mini", false)
+	if err != nil {
+		t.Fatalf("NewAliasRule error: %v", err)
+	}
+	anyClaude, err := config.NewAliasRule("claude-*", "gpt-4.1", true)
+	if err != nil {
+		t.Fatalf("NewAliasRule error: %v", err)
+	}
+	cfg := config.DefaultConfig()
+	cfg.DefaultModel = "gpt-4o"
+	cfg.ModelHaiku = "gpt-3.5-turbo"
+	cfg.ModelAliases = map[string]string{"claude-3-haiku-20240307": "o4-mini"}
+	cfg.AliasRules = []config.AliasRule{haikuRule, anyClaude}
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"exact alias beats rules", "claude-3-haiku-20240307", "o4-mini"},
+		{"first matching rule wins", "claude-3-5-haiku-20241022", "gpt-4o-mini"},
+		{"later rule", "claude-3-5-sonnet-20241022", "gpt-4.1"},
+		{"tier mapping without alias", "haiku-latest", "gpt-3.5-turbo"},
+		{"default model without alias", "unknown-model", "gpt-4o"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The handler resolves aliases before mapping the model
+			if result := cfg.MapModel(cfg.ResolveAlias(tt.input)); result != tt.expected {
+				t.Errorf("MapModel(ResolveAlias(%q)) = %q, want %q", tt.input, result, tt.expected)
+			}
+		})
+	}
+}
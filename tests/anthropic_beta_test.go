@@ -0,0 +1,78 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jedarden/clasp/internal/config"
+	"github.com/jedarden/clasp/pkg/models"
+)
+
+func TestAnthropicBeta_ForwardedInPassthrough(t *testing.T) {
+	var upstreamBeta string
+	anthropic := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamBeta = r.Header.Get("anthropic-beta")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"msg_01","type":"message","role":"assistant","content":[{"type":"text","text":"Hi"}],"model":"claude-3-7-sonnet-20250219","stop_reason":"end_turn","usage":{"input_tokens":10,"output_tokens":2}}`))
+	}))
+	t.Cleanup(anthropic.Close)
+
+	handler := newTestHandler(t, func(cfg *config.Config) {
+		cfg.MultiProviderEnabled = true
+		cfg.TierSonnet = &config.TierConfig{Provider: config.ProviderAnthropic, BaseURL: anthropic.URL, APIKey: "test-key", Model: "claude-3-7-sonnet-20250219"}
+	}, func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Unexpected request to the primary provider")
+	})
+
+	rec := postMessagesWithHeaders(t, handler, simpleRequest(), map[string]string{"anthropic-beta": "prompt-caching-2024-07-31,token-efficient-tools-2025-02-19"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if upstreamBeta != "prompt-caching-2024-07-31,token-efficient-tools-2025-02-19" {
+		t.Errorf("Expected the anthropic-beta flags to be forwarded, got %q", upstreamBeta)
+	}
+}
+
+func TestAnthropicBeta_PromptCachingTranslatesCacheControl(t *testing.T) {
+	var upstreamBody []byte
+	var upstreamBeta string
+	handler := newTestHandler(t, func(cfg *config.Config) {
+		cfg.DefaultModel = "anthropic/claude-3.5-sonnet"
+	}, func(w http.ResponseWriter, r *http.Request) {
+		upstreamBeta = r.Header.Get("anthropic-beta")
+		var raw json.RawMessage
+		_ = json.NewDecoder(r.Body).Decode(&raw)
+		upstreamBody = raw
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(chatCompletionOK))
+	})
+
+	req := simpleRequest()
+	req.Messages = []models.AnthropicMessage{{
+		Role: "user",
+		Content: []interface{}{
+			map[string]interface{}{"type": "text", "text": "Summarize this file.", "cache_control": map[string]interface{}{"type": "ephemeral"}},
+		},
+	}}
+
+	if rec := postMessages(t, handler, req); rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if strings.Contains(string(upstreamBody), "cache_control") {
+		t.Errorf("Expected cache_control to be stripped without the beta, got %s", upstreamBody)
+	}
+
+	rec := postMessagesWithHeaders(t, handler, req, map[string]string{"anthropic-beta": "prompt-caching-2024-07-31"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if !strings.Contains(string(upstreamBody), `"cache_control":{"type":"ephemeral"}`) {
+		t.Errorf("Expected cache_control to be translated with the beta, got %s", upstreamBody)
+	}
+	if upstreamBeta != "" {
+		t.Errorf("Expected anthropic-beta not to be sent to a translated provider, got %q", upstreamBeta)
+	}
+}
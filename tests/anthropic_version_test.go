@@ -0,0 +1,108 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jedarden/clasp/internal/config"
+	"github.com/jedarden/clasp/internal/proxy"
+)
+
+// newVersionedPassthroughHandler creates a handler that passes sonnet requests
+// through to a mock Anthropic API. It returns the handler and the
+// anthropic-version header of the last upstream request.
+func newVersionedPassthroughHandler(t *testing.T, configure func(*config.Config)) (*proxy.Handler, *string) {
+	t.Helper()
+	var upstreamVersion string
+	anthropic := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamVersion = r.Header.Get("anthropic-version")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"msg_01","type":"message","role":"assistant","content":[{"type":"text","text":"Hi"}],"model":"claude-3-7-sonnet-20250219","stop_reason":"end_turn","usage":{"input_tokens":10,"output_tokens":2}}`))
+	}))
+	t.Cleanup(anthropic.Close)
+
+	handler := newTestHandler(t, func(cfg *config.Config) {
+		cfg.MultiProviderEnabled = true
+		cfg.TierSonnet = &config.TierConfig{Provider: config.ProviderAnthropic, BaseURL: anthropic.URL, APIKey: "test-key", Model: "claude-3-7-sonnet-20250219"}
+		if configure != nil {
+			configure(cfg)
+		}
+	}, func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Unexpected request to the primary provider")
+	})
+	return handler, &upstreamVersion
+}
+
+func TestAnthropicVersion_DefaultInjected(t *testing.T) {
+	handler, upstreamVersion := newVersionedPassthroughHandler(t, func(cfg *config.Config) {
+		cfg.AnthropicVersion = "2023-01-01"
+	})
+
+	if rec := postMessages(t, handler, simpleRequest()); rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if *upstreamVersion != "2023-01-01" {
+		t.Errorf("Expected the configured default anthropic-version, got %q", *upstreamVersion)
+	}
+}
+
+func TestAnthropicVersion_ForwardedInPassthrough(t *testing.T) {
+	handler, upstreamVersion := newVersionedPassthroughHandler(t, nil)
+
+	rec := postMessagesWithHeaders(t, handler, simpleRequest(), map[string]string{"anthropic-version": "2024-10-22"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if *upstreamVersion != "2024-10-22" {
+		t.Errorf("Expected the client's anthropic-version to be forwarded, got %q", *upstreamVersion)
+	}
+}
+
+func TestAnthropicVersion_StrictRejectsUnsupported(t *testing.T) {
+	handler, upstreamVersion := newVersionedPassthroughHandler(t, func(cfg *config.Config) {
+		cfg.StrictVersion = true
+	})
+
+	rec := postMessagesWithHeaders(t, handler, simpleRequest(), map[string]string{"anthropic-version": "2099-01-01"})
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status 400, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if !strings.Contains(rec.Body.String(), "2099-01-01") || !strings.Contains(rec.Body.String(), "2023-06-01") {
+		t.Errorf("Expected the error to name the version and the supported ones, got %s", rec.Body.String())
+	}
+	if *upstreamVersion != "" {
+		t.Error("Expected the request not to reach the upstream")
+	}
+
+	rec = postMessagesWithHeaders(t, handler, simpleRequest(), map[string]string{"anthropic-version": "2023-06-01"})
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected a supported version to pass, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestAnthropicVersion_RecordedInAuditLog(t *testing.T) {
+	handler, _ := newVersionedPassthroughHandler(t, nil)
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	auditLog, err := proxy.NewAuditLogger(path, false)
+	if err != nil {
+		t.Fatalf("Failed to create audit logger: %v", err)
+	}
+	t.Cleanup(func() { auditLog.Close() })
+	handler.SetAuditLogger(auditLog)
+
+	postMessagesWithHeaders(t, handler, simpleRequest(), map[string]string{"anthropic-version": "2023-06-01"})
+	postMessages(t, handler, simpleRequest())
+
+	entries := readAuditLog(t, path)
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 audit entries, got %d", len(entries))
+	}
+	for i, entry := range entries {
+		if entry["anthropic_version"] != "2023-06-01" {
+			t.Errorf("Entry %d: expected anthropic_version 2023-06-01, got %v", i, entry["anthropic_version"])
+		}
+	}
+}
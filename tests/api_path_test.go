@@ -0,0 +1,124 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jedarden/clasp/internal/config"
+	"github.com/jedarden/clasp/internal/proxy"
+)
+
+// newRoutedServer returns the routes of a server for a mock upstream.
+func newRoutedServer(t *testing.T, configure func(*config.Config)) http.Handler {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	cfg, _ := newMockUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(chatCompletionOK))
+	})
+	configure(cfg)
+	server, err := proxy.NewServer(cfg)
+	if err != nil {
+		t.Fatalf("NewServer failed: %v", err)
+	}
+	return server.Routes()
+}
+
+// postPath sends simpleRequest to path and returns the status code, or 404
+// when the path isn't served by the messages handler.
+func postPath(t *testing.T, routes http.Handler, path string) int {
+	t.Helper()
+	body, _ := json.Marshal(simpleRequest())
+	req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	routes.ServeHTTP(rec, req)
+	if rec.Code == http.StatusOK && !strings.Contains(rec.Body.String(), `"type":"message"`) {
+		return http.StatusNotFound // Fell through to the root handler
+	}
+	return rec.Code
+}
+
+func TestAPIPathPrefix_Default(t *testing.T) {
+	routes := newRoutedServer(t, func(cfg *config.Config) {})
+
+	if code := postPath(t, routes, "/v1/messages"); code != http.StatusOK {
+		t.Errorf("Expected 200 at /v1/messages, got %d", code)
+	}
+	if code := postPath(t, routes, "/messages"); code != http.StatusNotFound {
+		t.Error("Expected /messages not to be served by default")
+	}
+}
+
+func TestAPIPathPrefix_Custom(t *testing.T) {
+	routes := newRoutedServer(t, func(cfg *config.Config) {
+		cfg.APIPathPrefix = "/anthropic/v1"
+	})
+
+	if code := postPath(t, routes, "/anthropic/v1/messages"); code != http.StatusOK {
+		t.Errorf("Expected 200 at /anthropic/v1/messages, got %d", code)
+	}
+	if code := postPath(t, routes, "/v1/messages"); code != http.StatusNotFound {
+		t.Error("Expected /v1/messages not to be served under a custom prefix")
+	}
+
+	// Non-API endpoints keep their paths
+	rec := httptest.NewRecorder()
+	routes.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected 200 at /health, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	routes.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	var root struct {
+		Endpoints map[string]string `json:"endpoints"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &root); err != nil {
+		t.Fatalf("Failed to decode root response: %v", err)
+	}
+	if root.Endpoints["messages"] != "/anthropic/v1/messages" || root.Endpoints["metrics"] != "/metrics" {
+		t.Errorf("Unexpected endpoints: %v", root.Endpoints)
+	}
+}
+
+func TestAPIPathPrefix_Unprefixed(t *testing.T) {
+	routes := newRoutedServer(t, func(cfg *config.Config) {
+		cfg.APIPathUnprefixed = true
+	})
+
+	for _, path := range []string{"/v1/messages", "/messages"} {
+		if code := postPath(t, routes, path); code != http.StatusOK {
+			t.Errorf("Expected 200 at %s, got %d", path, code)
+		}
+	}
+}
+
+func TestAPIPathPrefix_None(t *testing.T) {
+	routes := newRoutedServer(t, func(cfg *config.Config) {
+		cfg.APIPathPrefix = ""
+	})
+
+	if code := postPath(t, routes, "/messages"); code != http.StatusOK {
+		t.Errorf("Expected 200 at /messages, got %d", code)
+	}
+}
+
+func TestRateLimitMiddleware_CustomPaths(t *testing.T) {
+	limiter := proxy.NewRateLimiter(1, 1, 1)
+	routes := newRoutedServer(t, func(cfg *config.Config) {
+		cfg.APIPathPrefix = "/api"
+	})
+	limited := proxy.RateLimitMiddleware(limiter, "/api/messages")(routes)
+
+	if code := postPath(t, limited, "/api/messages"); code != http.StatusOK {
+		t.Fatalf("Expected the first request to pass, got %d", code)
+	}
+	if code := postPath(t, limited, "/api/messages"); code != http.StatusTooManyRequests {
+		t.Errorf("Expected the second request to be rate limited, got %d", code)
+	}
+}
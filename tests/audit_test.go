@@ -0,0 +1,168 @@
+package tests
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jedarden/clasp/internal/proxy"
+	"github.com/jedarden/clasp/pkg/models"
+)
+
+// readAuditLog returns the decoded lines of a JSONL audit log.
+func readAuditLog(t *testing.T, path string) []map[string]interface{} {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open audit log: %v", err)
+	}
+	defer f.Close()
+
+	var entries []map[string]interface{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		var entry map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("Audit log line is not JSON: %v\n%s", err, scanner.Text())
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// newAuditedHandler creates a handler for a mock upstream that writes its
+// audit log to a temporary file.
+func newAuditedHandler(t *testing.T, includeContent bool, upstream http.HandlerFunc) (*proxy.Handler, string) {
+	t.Helper()
+	handler := newTestHandler(t, nil, upstream)
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	auditLog, err := proxy.NewAuditLogger(path, includeContent)
+	if err != nil {
+		t.Fatalf("Failed to create audit logger: %v", err)
+	}
+	t.Cleanup(func() { auditLog.Close() })
+	handler.SetAuditLogger(auditLog)
+	return handler, path
+}
+
+func TestAuditLog_OneLinePerRequest(t *testing.T) {
+	handler, path := newAuditedHandler(t, false, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"chatcmpl-1","choices":[{"message":{"role":"assistant","content":"Hi there"},"finish_reason":"stop"}],"usage":{"prompt_tokens":12,"completion_tokens":4}}`))
+	})
+
+	rec := postMessagesWithHeaders(t, handler, simpleRequest(), map[string]string{
+		"x-api-key":    "clasp-secret-key-1234",
+		"X-Request-ID": "req-audit-1",
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if got := rec.Header().Get("X-Request-ID"); got != "req-audit-1" {
+		t.Errorf("Expected X-Request-ID to be echoed, got %q", got)
+	}
+	rec = postMessages(t, handler, simpleRequest())
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	generatedID := rec.Header().Get("X-Request-ID")
+	if generatedID == "" {
+		t.Error("Expected a generated X-Request-ID")
+	}
+
+	entries := readAuditLog(t, path)
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 audit entries, got %d", len(entries))
+	}
+
+	entry := entries[0]
+	expected := map[string]interface{}{
+		"request_id":    "req-audit-1",
+		"key_label":     "clas...1234",
+		"model":         "claude-3-5-sonnet-20241022",
+		"target_model":  "gpt-4o",
+		"provider":      "custom",
+		"stream":        false,
+		"status":        float64(200),
+		"input_tokens":  float64(12),
+		"output_tokens": float64(4),
+		"cache":         "MISS",
+		"fallback":      false,
+	}
+	for key, want := range expected {
+		if entry[key] != want {
+			t.Errorf("Expected %s=%v, got %v", key, want, entry[key])
+		}
+	}
+	for _, key := range []string{"timestamp", "latency_ms", "cost_usd"} {
+		if _, ok := entry[key]; !ok {
+			t.Errorf("Expected field %s in audit entry", key)
+		}
+	}
+	if cost, _ := entry["cost_usd"].(float64); cost <= 0 {
+		t.Errorf("Expected a positive cost, got %v", entry["cost_usd"])
+	}
+	for _, key := range []string{"request", "response"} {
+		if _, ok := entry[key]; ok {
+			t.Errorf("Expected no %s content without CLASP_AUDIT_INCLUDE_CONTENT", key)
+		}
+	}
+	if entries[1]["request_id"] != generatedID {
+		t.Errorf("Expected request_id %q, got %v", generatedID, entries[1]["request_id"])
+	}
+}
+
+func TestAuditLog_ErrorStatus(t *testing.T) {
+	handler, path := newAuditedHandler(t, false, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"error":{"message":"bad request","type":"invalid_request_error"}}`))
+	})
+
+	postMessages(t, handler, simpleRequest())
+
+	entries := readAuditLog(t, path)
+	if len(entries) != 1 {
+		t.Fatalf("Expected 1 audit entry, got %d", len(entries))
+	}
+	if entries[0]["status"] != float64(http.StatusBadRequest) {
+		t.Errorf("Expected status 400, got %v", entries[0]["status"])
+	}
+}
+
+func TestAuditLog_IncludeContentMasksSecrets(t *testing.T) {
+	handler, path := newAuditedHandler(t, true, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"chatcmpl-1","choices":[{"message":{"role":"assistant","content":"Your key is sk-abcdefghijklmnopqrstuvwxyz"},"finish_reason":"stop"}],"usage":{"prompt_tokens":12,"completion_tokens":4}}`))
+	})
+
+	req := &models.AnthropicRequest{
+		Model:     "claude-3-5-sonnet-20241022",
+		MaxTokens: 100,
+		Messages: []models.AnthropicMessage{
+			{Role: "user", Content: "Remember sk-ant-REDACTED please"},
+		},
+	}
+	if rec := postMessages(t, handler, req); rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	entries := readAuditLog(t, path)
+	if len(entries) != 1 {
+		t.Fatalf("Expected 1 audit entry, got %d", len(entries))
+	}
+	request, _ := entries[0]["request"].(string)
+	response, _ := entries[0]["response"].(string)
+	if !strings.Contains(request, "Remember") || !strings.Contains(response, "Your key is") {
+		t.Fatalf("Expected request and response content, got request=%q response=%q", request, response)
+	}
+	for _, secret := range []string{"sk-ant-REDACTED", "sk-abcdefghijklmnopqrstuvwxyz"} {
+		if strings.Contains(request, secret) || strings.Contains(response, secret) {
+			t.Errorf("Expected %s to be masked in the audit log", secret)
+		}
+	}
+}
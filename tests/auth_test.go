@@ -317,3 +317,146 @@ func TestAuthMiddleware_RawAuthorizationHeader(t *testing.T) {
 		t.Errorf("Expected status 200, got %d", rec.Code)
 	}
 }
+
+func TestAuthMiddleware_AnonymousEndpoints(t *testing.T) {
+	testCases := []struct {
+		name   string
+		config proxy.AuthConfig
+		method string
+		path   string
+		want   int
+	}{
+		{
+			name:   "listed endpoint is anonymous",
+			config: proxy.AuthConfig{AnonymousEndpoints: []string{"/metrics/prometheus"}},
+			method: http.MethodGet,
+			path:   "/metrics/prometheus",
+			want:   http.StatusOK,
+		},
+		{
+			name:   "unlisted endpoint needs a key",
+			config: proxy.AuthConfig{AnonymousEndpoints: []string{"/metrics/prometheus"}},
+			method: http.MethodGet,
+			path:   "/costs",
+			want:   http.StatusUnauthorized,
+		},
+		{
+			name:   "sibling of a listed endpoint needs a key",
+			config: proxy.AuthConfig{AnonymousEndpoints: []string{"/metrics/prometheus"}},
+			method: http.MethodGet,
+			path:   "/metrics",
+			want:   http.StatusUnauthorized,
+		},
+		{
+			name:   "writes to a listed endpoint need a key",
+			config: proxy.AuthConfig{AnonymousEndpoints: []string{"/costs"}},
+			method: http.MethodPost,
+			path:   "/costs",
+			want:   http.StatusUnauthorized,
+		},
+		{
+			name:   "root needs a key when left out of the list",
+			config: proxy.AuthConfig{AnonymousEndpoints: []string{"/health"}},
+			method: http.MethodGet,
+			path:   "/",
+			want:   http.StatusUnauthorized,
+		},
+		{
+			name:   "root is anonymous without a list",
+			config: proxy.AuthConfig{},
+			method: http.MethodGet,
+			path:   "/",
+			want:   http.StatusOK,
+		},
+		{
+			name:   "health flag adds the probes to the list",
+			config: proxy.AuthConfig{AnonymousEndpoints: []string{"/metrics/prometheus"}, AllowAnonymousHealth: true},
+			method: http.MethodGet,
+			path:   "/readyz",
+			want:   http.StatusOK,
+		},
+		{
+			name:   "metrics flag adds the metrics endpoints to the list",
+			config: proxy.AuthConfig{AnonymousEndpoints: []string{"/health"}, AllowAnonymousMetrics: true},
+			method: http.MethodGet,
+			path:   "/metrics",
+			want:   http.StatusOK,
+		},
+		{
+			name:   "empty list makes every endpoint need a key",
+			config: proxy.AuthConfig{AnonymousEndpoints: []string{}},
+			method: http.MethodGet,
+			path:   "/",
+			want:   http.StatusUnauthorized,
+		},
+	}
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			config := tc.config
+			config.Enabled = true
+			config.APIKey = "test-key"
+			middleware := proxy.AuthMiddleware(&config)(next)
+
+			rec := httptest.NewRecorder()
+			middleware.ServeHTTP(rec, httptest.NewRequest(tc.method, tc.path, http.NoBody))
+			if rec.Code != tc.want {
+				t.Errorf("%s %s: expected status %d, got %d", tc.method, tc.path, tc.want, rec.Code)
+			}
+
+			// A valid key is always accepted
+			req := httptest.NewRequest(tc.method, tc.path, http.NoBody)
+			req.Header.Set("x-api-key", "test-key")
+			rec = httptest.NewRecorder()
+			middleware.ServeHTTP(rec, req)
+			if rec.Code != http.StatusOK {
+				t.Errorf("%s %s with key: expected status 200, got %d", tc.method, tc.path, rec.Code)
+			}
+		})
+	}
+}
+
+func TestAuthMiddleware_CredentialPrecedence(t *testing.T) {
+	testCases := []struct {
+		name   string
+		apiKey string
+		bearer string
+		want   int
+	}{
+		{name: "bearer only", bearer: "Bearer test-secret-key", want: http.StatusOK},
+		{name: "lowercase bearer scheme", bearer: "bearer test-secret-key", want: http.StatusOK},
+		{name: "x-api-key only", apiKey: "test-secret-key", want: http.StatusOK},
+		{name: "both valid", apiKey: "test-secret-key", bearer: "Bearer test-secret-key", want: http.StatusOK},
+		{name: "x-api-key wins over a wrong bearer", apiKey: "test-secret-key", bearer: "Bearer wrong-key", want: http.StatusOK},
+		{name: "wrong x-api-key is not rescued by bearer", apiKey: "wrong-key", bearer: "Bearer test-secret-key", want: http.StatusUnauthorized},
+		{name: "wrong bearer only", bearer: "Bearer wrong-key", want: http.StatusUnauthorized},
+	}
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	middleware := proxy.AuthMiddleware(&proxy.AuthConfig{
+		Enabled: true,
+		APIKey:  "test-secret-key",
+	})(next)
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/v1/messages", http.NoBody)
+			if tc.apiKey != "" {
+				req.Header.Set("x-api-key", tc.apiKey)
+			}
+			if tc.bearer != "" {
+				req.Header.Set("Authorization", tc.bearer)
+			}
+			rec := httptest.NewRecorder()
+			middleware.ServeHTTP(rec, req)
+			if rec.Code != tc.want {
+				t.Errorf("Expected status %d, got %d", tc.want, rec.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package tests
+
+import (
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jedarden/clasp/internal/config"
+	"github.com/jedarden/clasp/internal/proxy"
+	"github.com/jedarden/clasp/pkg/models"
+)
+
+// cacheKeyRequest returns a request whose only variable parts are the
+// temperature, max_tokens and prompt text.
+func cacheKeyRequest(temperature float64, maxTokens int, text string) *models.AnthropicRequest {
+	return &models.AnthropicRequest{
+		Model:       "claude-3-5-sonnet-20241022",
+		MaxTokens:   maxTokens,
+		Temperature: &temperature,
+		Messages: []models.AnthropicMessage{
+			{Role: "user", Content: text},
+		},
+	}
+}
+
+func TestGenerateCacheKeyWithStrategy_MessagesOnlyIgnoresSamplingParams(t *testing.T) {
+	key1, ok1 := proxy.GenerateCacheKeyWithStrategy(cacheKeyRequest(0, 1024, "Hello"), proxy.CacheKeyMessagesOnly)
+	half := 0.5
+	topP := cacheKeyRequest(0, 1024, "Hello")
+	topP.TopP = &half
+	key2, ok2 := proxy.GenerateCacheKeyWithStrategy(topP, proxy.CacheKeyMessagesOnly)
+	if !ok1 || !ok2 {
+		t.Fatalf("Expected both requests to be cacheable, got %v and %v", ok1, ok2)
+	}
+	if key1 != key2 {
+		t.Error("Expected requests differing only in top_p to share a key")
+	}
+
+	key3, _ := proxy.GenerateCacheKeyWithStrategy(cacheKeyRequest(0, 64, "Hello"), proxy.CacheKeyMessagesOnly)
+	if key3 != key1 {
+		t.Error("Expected requests differing only in max_tokens to share a key")
+	}
+
+	key4, _ := proxy.GenerateCacheKeyWithStrategy(cacheKeyRequest(0, 1024, "Goodbye"), proxy.CacheKeyMessagesOnly)
+	if key4 == key1 {
+		t.Error("Expected different messages to produce different keys")
+	}
+}
+
+func TestGenerateCacheKeyWithStrategy_TemperatureNeverCached(t *testing.T) {
+	for _, strategy := range []string{proxy.CacheKeyFull, proxy.CacheKeyMessagesOnly, proxy.CacheKeyNormalized} {
+		if _, ok := proxy.GenerateCacheKeyWithStrategy(cacheKeyRequest(0.7, 1024, "Hello"), strategy); ok {
+			t.Errorf("Expected a temperature above zero to be uncacheable under %s", strategy)
+		}
+	}
+}
+
+func TestGenerateCacheKeyWithStrategy_ResponseAffectingFields(t *testing.T) {
+	half, seed, topK, off := 0.5, 42, 40, false
+	tests := []struct {
+		name   string
+		modify func(req *models.AnthropicRequest)
+		// Whether messages_only and normalized also key on the field
+		allStrategies bool
+	}{
+		{"output_format", func(req *models.AnthropicRequest) { req.OutputFormat = &models.OutputFormat{Type: "json"} }, true},
+		{"n", func(req *models.AnthropicRequest) { req.N = 2 }, true},
+		{"thinking", func(req *models.AnthropicRequest) {
+			req.Thinking = &models.ThinkingConfig{Type: "enabled", BudgetTokens: 1024}
+		}, true},
+		{"parallel_tool_calls", func(req *models.AnthropicRequest) { req.ParallelToolCalls = &off }, true},
+		{"betas", func(req *models.AnthropicRequest) { req.Betas = []string{"output-128k-2025-02-19"} }, true},
+		{"seed", func(req *models.AnthropicRequest) { req.Seed = &seed }, false},
+		{"presence_penalty", func(req *models.AnthropicRequest) { req.PresencePenalty = &half }, false},
+		{"frequency_penalty", func(req *models.AnthropicRequest) { req.FrequencyPenalty = &half }, false},
+		{"stop_sequences", func(req *models.AnthropicRequest) { req.StopSequences = []string{"END"} }, false},
+		{"top_p", func(req *models.AnthropicRequest) { req.TopP = &half }, false},
+		{"top_k", func(req *models.AnthropicRequest) { req.TopK = &topK }, false},
+		{"service_tier", func(req *models.AnthropicRequest) { req.ServiceTier = "standard_only" }, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, strategy := range []string{proxy.CacheKeyFull, proxy.CacheKeyMessagesOnly, proxy.CacheKeyNormalized} {
+				base, _ := proxy.GenerateCacheKeyWithStrategy(cacheKeyRequest(0, 1024, "Hello"), strategy)
+				req := cacheKeyRequest(0, 1024, "Hello")
+				tt.modify(req)
+				key, ok := proxy.GenerateCacheKeyWithStrategy(req, strategy)
+				if !ok {
+					t.Fatalf("Expected the request to be cacheable under %s", strategy)
+				}
+				differs := strategy == proxy.CacheKeyFull || tt.allStrategies
+				if (key != base) != differs {
+					t.Errorf("Under %s: key differs = %v, want %v", strategy, key != base, differs)
+				}
+			}
+		})
+	}
+}
+
+func TestGenerateCacheKeyWithStrategy_FullKeepsSamplingParams(t *testing.T) {
+	if _, ok := proxy.GenerateCacheKeyWithStrategy(cacheKeyRequest(0.7, 1024, "Hello"), proxy.CacheKeyFull); ok {
+		t.Error("Expected a temperature above zero to be uncacheable under full")
+	}
+	key1, _ := proxy.GenerateCacheKeyWithStrategy(cacheKeyRequest(0, 1024, "Hello"), proxy.CacheKeyFull)
+	key2, _ := proxy.GenerateCacheKeyWithStrategy(cacheKeyRequest(0, 64, "Hello"), proxy.CacheKeyFull)
+	if key1 == key2 {
+		t.Error("Expected different max_tokens to produce different keys under full")
+	}
+	if key, _ := proxy.GenerateCacheKey(cacheKeyRequest(0, 1024, "Hello")); key != key1 {
+		t.Error("Expected GenerateCacheKey to use the full strategy")
+	}
+}
+
+func TestGenerateCacheKeyWithStrategy_NormalizedWhitespace(t *testing.T) {
+	blocks := cacheKeyRequest(0, 1024, "")
+	blocks.System = "  Be   concise.\n"
+	blocks.Messages[0].Content = []interface{}{
+		map[string]interface{}{"type": "text", "text": "What  is\n\tGo?"},
+	}
+	plain := cacheKeyRequest(0, 1024, "")
+	plain.System = "Be concise."
+	plain.Messages[0].Content = []interface{}{
+		map[string]interface{}{"type": "text", "text": "What is Go?"},
+	}
+
+	key1, _ := proxy.GenerateCacheKeyWithStrategy(blocks, proxy.CacheKeyNormalized)
+	key2, _ := proxy.GenerateCacheKeyWithStrategy(plain, proxy.CacheKeyNormalized)
+	if key1 != key2 {
+		t.Error("Expected prompts differing only in whitespace to share a key under normalized")
+	}
+
+	key3, _ := proxy.GenerateCacheKeyWithStrategy(blocks, proxy.CacheKeyMessagesOnly)
+	key4, _ := proxy.GenerateCacheKeyWithStrategy(plain, proxy.CacheKeyMessagesOnly)
+	if key3 == key4 {
+		t.Error("Expected whitespace to matter under messages_only")
+	}
+
+	if blocks.System != "  Be   concise.\n" {
+		t.Errorf("Expected the request to be left unchanged, got system %q", blocks.System)
+	}
+}
+
+func TestCacheKey_MessagesOnlyServesHitAcrossSamplingParams(t *testing.T) {
+	var upstreamCalls int32
+	handler := newTestHandler(t, func(cfg *config.Config) {
+		cfg.CacheKey = proxy.CacheKeyMessagesOnly
+	}, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&upstreamCalls, 1)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(chatCompletionOK))
+	})
+	handler.SetCache(proxy.NewRequestCache(100, time.Hour))
+
+	postMessages(t, handler, cacheKeyRequest(0, 1024, "Hello"))
+	req := cacheKeyRequest(0, 512, "Hello")
+	topP := 0.9
+	req.TopP = &topP
+	rec := postMessages(t, handler, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec.Header().Get("X-CLASP-Cache") != "HIT" {
+		t.Errorf("Expected a cache HIT, got %q", rec.Header().Get("X-CLASP-Cache"))
+	}
+	if n := atomic.LoadInt32(&upstreamCalls); n != 1 {
+		t.Errorf("Expected 1 upstream call, got %d", n)
+	}
+}
@@ -0,0 +1,181 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/jedarden/clasp/internal/config"
+	"github.com/jedarden/clasp/internal/proxy"
+	"github.com/jedarden/clasp/pkg/models"
+)
+
+// Recorded usage from providers that report prompt cache hits.
+const (
+	openRouterCachedResponse = `{"id":"gen-1","object":"chat.completion","model":"anthropic/claude-3.5-sonnet","choices":[{"index":0,"message":{"role":"assistant","content":"Hello!"},"finish_reason":"stop"}],"usage":{"prompt_tokens":1500,"completion_tokens":20,"total_tokens":1520,"prompt_tokens_details":{"cached_tokens":1000,"cache_write_tokens":300}}}`
+
+	deepSeekCachedResponse = `{"id":"ds-1","object":"chat.completion","model":"deepseek-chat","choices":[{"index":0,"message":{"role":"assistant","content":"Hello!"},"finish_reason":"stop"}],"usage":{"prompt_tokens":1200,"completion_tokens":15,"total_tokens":1215,"prompt_cache_hit_tokens":1024,"prompt_cache_miss_tokens":176}}`
+
+	cachedStreamResponse = "data: {\"id\":\"gen-2\",\"object\":\"chat.completion.chunk\",\"model\":\"gpt-4o\",\"choices\":[{\"index\":0,\"delta\":{\"role\":\"assistant\",\"content\":\"Hello!\"},\"finish_reason\":null}]}\n\n" +
+		"data: {\"id\":\"gen-2\",\"object\":\"chat.completion.chunk\",\"model\":\"gpt-4o\",\"choices\":[{\"index\":0,\"delta\":{},\"finish_reason\":\"stop\"}]}\n\n" +
+		"data: {\"id\":\"gen-2\",\"object\":\"chat.completion.chunk\",\"model\":\"gpt-4o\",\"choices\":[],\"usage\":{\"prompt_tokens\":2048,\"completion_tokens\":3,\"total_tokens\":2051,\"prompt_tokens_details\":{\"cached_tokens\":1920}}}\n\n" +
+		"data: [DONE]\n\n"
+)
+
+func TestCacheUsage_NonStreaming(t *testing.T) {
+	tests := []struct {
+		name                          string
+		body                          string
+		input, output, read, creation int
+	}{
+		{"openrouter cached and cache write tokens", openRouterCachedResponse, 200, 20, 1000, 300},
+		{"deepseek prompt cache hits", deepSeekCachedResponse, 176, 15, 1024, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := newTestHandler(t, nil, func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write([]byte(tt.body))
+			})
+			rec := postMessages(t, handler, simpleRequest())
+			if rec.Code != http.StatusOK {
+				t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+			}
+
+			var resp models.AnthropicResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			want := models.AnthropicUsage{
+				InputTokens:              tt.input,
+				OutputTokens:             tt.output,
+				CacheReadInputTokens:     tt.read,
+				CacheCreationInputTokens: tt.creation,
+			}
+			if resp.Usage == nil || *resp.Usage != want {
+				t.Errorf("Expected usage %+v, got %+v", want, resp.Usage)
+			}
+
+			summary := handler.GetCostTracker().GetSummary()
+			if summary.TotalInputTokens != int64(tt.input) || summary.CacheReadTokens != int64(tt.read) || summary.CacheCreateTokens != int64(tt.creation) {
+				t.Errorf("Expected cost tracking of %d input, %d cache read and %d cache write tokens, got %+v", tt.input, tt.read, tt.creation, summary)
+			}
+			if summary.CacheCostUSD <= 0 {
+				t.Errorf("Expected a cache cost, got %v", summary.CacheCostUSD)
+			}
+		})
+	}
+}
+
+func TestCacheUsage_NoCacheFieldsWithoutCacheHits(t *testing.T) {
+	handler := newTestHandler(t, nil, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(chatCompletionOK))
+	})
+	rec := postMessages(t, handler, simpleRequest())
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if strings.Contains(rec.Body.String(), "cache_read_input_tokens") || strings.Contains(rec.Body.String(), "cache_creation_input_tokens") {
+		t.Errorf("Expected no cache fields without cache usage, got %s", rec.Body.String())
+	}
+}
+
+func TestCacheUsage_Streaming(t *testing.T) {
+	handler := newTestHandler(t, nil, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte(cachedStreamResponse))
+	})
+	req := simpleRequest()
+	req.Stream = true
+	rec := postMessages(t, handler, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var delta *models.MessageDeltaUsage
+	for _, line := range strings.Split(rec.Body.String(), "\n") {
+		if !strings.HasPrefix(line, "data: ") || !strings.Contains(line, `"message_delta"`) {
+			continue
+		}
+		var event models.MessageDeltaEvent
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event); err != nil {
+			t.Fatalf("Failed to decode message_delta: %v", err)
+		}
+		delta = event.Usage
+	}
+	if delta == nil || delta.CacheReadInputTokens != 1920 || delta.OutputTokens != 3 {
+		t.Fatalf("Expected message_delta usage with 1920 cache read tokens, got %+v", delta)
+	}
+
+	summary := handler.GetCostTracker().GetSummary()
+	if summary.TotalInputTokens != 128 || summary.CacheReadTokens != 1920 {
+		t.Errorf("Expected 128 input and 1920 cache read tokens tracked, got %d and %d", summary.TotalInputTokens, summary.CacheReadTokens)
+	}
+}
+
+// newCacheUsageUpstream starts an Anthropic mock that streams body and
+// returns its URL.
+func newCacheUsageUpstream(t *testing.T, body string) string {
+	t.Helper()
+	_, server := newMockUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte(body))
+	})
+	return server.URL
+}
+
+func TestCacheUsage_Passthrough(t *testing.T) {
+	stream := strings.Replace(recordedThinkingStream, `"cache_creation_input_tokens":0,"cache_read_input_tokens":0`, `"cache_creation_input_tokens":400,"cache_read_input_tokens":9000`, 1)
+	anthropic := newCacheUsageUpstream(t, stream)
+
+	handler := newTestHandler(t, func(cfg *config.Config) {
+		cfg.MultiProviderEnabled = true
+		cfg.TierSonnet = &config.TierConfig{Provider: config.ProviderAnthropic, BaseURL: anthropic, APIKey: "test-key", Model: "claude-3-7-sonnet-20250219"}
+	}, func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Unexpected request to the primary provider")
+	})
+
+	req := simpleRequest()
+	req.Stream = true
+	if rec := postMessages(t, handler, req); rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	summary := handler.GetCostTracker().GetSummary()
+	if summary.TotalInputTokens != 1250 || summary.CacheReadTokens != 9000 || summary.CacheCreateTokens != 400 {
+		t.Errorf("Expected 1250 input, 9000 cache read and 400 cache write tokens, got %+v", summary)
+	}
+}
+
+func TestCostTracker_CacheReadsCheaperThanInput(t *testing.T) {
+	uncached := proxy.NewCostTracker()
+	uncached.RecordUsage("openai", "gpt-4o", 10000, 100)
+
+	cached := proxy.NewCostTracker()
+	cached.RecordAnthropicUsage("openai", "gpt-4o", &models.AnthropicUsage{
+		InputTokens:          1000,
+		OutputTokens:         100,
+		CacheReadInputTokens: 9000,
+	})
+
+	if cached.GetTotalCostUSD() >= uncached.GetTotalCostUSD() {
+		t.Errorf("Expected cache reads to cost less than input: cached $%f, uncached $%f", cached.GetTotalCostUSD(), uncached.GetTotalCostUSD())
+	}
+	summary := cached.GetSummary()
+	model := summary.ByModel["gpt-4o"]
+	if model.CacheReadTokens != 9000 || model.CacheCostUSD <= 0 {
+		t.Errorf("Expected cache reads in the model breakdown, got %+v", model)
+	}
+	if got := model.InputCostUSD + model.OutputCostUSD + model.CacheCostUSD; got != model.TotalCostUSD {
+		t.Errorf("Expected the total to include the cache cost, got %f vs %f", model.TotalCostUSD, got)
+	}
+
+	cached.SetCustomPricing("gpt-4o", proxy.ModelPricing{InputPer1M: 250, OutputPer1M: 1000, CacheReadPer1M: 125})
+	cached.Reset()
+	cached.RecordAnthropicUsage("openai", "gpt-4o", &models.AnthropicUsage{CacheReadInputTokens: 1000000})
+	if got := cached.GetSummary().CacheCostUSD; got < 1.2499 || got > 1.2501 {
+		t.Errorf("Expected custom cache read pricing of $1.25 per 1M, got $%f", got)
+	}
+}
@@ -0,0 +1,148 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jedarden/clasp/internal/config"
+	"github.com/jedarden/clasp/internal/proxy"
+)
+
+func TestCircuitBreakers_PerProvider(t *testing.T) {
+	// Opus goes to a failing OpenAI endpoint; other tiers use the healthy primary
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte(`{"error":{"message":"upstream down","type":"server_error"}}`))
+	}))
+	t.Cleanup(failing.Close)
+	handler := newTestHandler(t, func(cfg *config.Config) {
+		cfg.RetryMax = 1
+		cfg.MultiProviderEnabled = true
+		cfg.TierOpus = &config.TierConfig{Provider: config.ProviderOpenAI, BaseURL: failing.URL, APIKey: "sk-test", Model: "gpt-4o"}
+	}, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"chatcmpl-1","choices":[{"index":0,"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}]}`))
+	})
+	breakers := proxy.NewCircuitBreakers(2, 1, time.Minute)
+	handler.SetCircuitBreakers(breakers)
+
+	opus := simpleRequest()
+	opus.Model = "claude-3-opus-20240229"
+	for i := 0; i < 2; i++ {
+		if rec := postMessages(t, handler, opus); rec.Code != http.StatusBadGateway {
+			t.Fatalf("Opus request %d: expected status 502, got %d", i+1, rec.Code)
+		}
+	}
+	if !breakers.Get("openai").IsOpen() {
+		t.Fatal("Expected the openai circuit breaker to be open")
+	}
+
+	// Opus is now rejected without reaching the provider
+	rec := postMessages(t, handler, opus)
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("X-CLASP-Circuit-Breaker") != "open" {
+		t.Errorf("Expected Opus to be rejected by the open breaker, got %d", rec.Code)
+	}
+
+	// Haiku uses a different provider and keeps working
+	haiku := simpleRequest()
+	haiku.Model = "claude-3-haiku-20240307"
+	if rec := postMessages(t, handler, haiku); rec.Code != http.StatusOK {
+		t.Errorf("Haiku: expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if state := breakers.Get("custom").State(); state != "closed" {
+		t.Errorf("Expected the custom circuit breaker to stay closed, got %s", state)
+	}
+
+	// Each breaker is reported with a provider label
+	rec = httptest.NewRecorder()
+	handler.HandleMetricsPrometheus(rec, httptest.NewRequest(http.MethodGet, "/metrics/prometheus", http.NoBody))
+	for _, want := range []string{
+		`clasp_circuit_breaker_state{provider="openai"} 2`,
+		`clasp_circuit_breaker_state{provider="custom"} 0`,
+		`clasp_circuit_breaker_open{provider="openai"} 1`,
+		`clasp_circuit_breaker_open{provider="custom"} 0`,
+	} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("Expected %q in Prometheus metrics", want)
+		}
+	}
+	if n := strings.Count(rec.Body.String(), "# TYPE clasp_circuit_breaker_state"); n != 1 {
+		t.Errorf("Expected one TYPE line for clasp_circuit_breaker_state, got %d", n)
+	}
+}
+
+func TestCircuitBreakers_HalfOpenProbeLimit(t *testing.T) {
+	var upstreamCalls int32
+	probing := make(chan struct{}, 5)
+	release := make(chan struct{})
+	handler := newTestHandler(t, func(cfg *config.Config) {
+		cfg.RetryMax = 1
+	}, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&upstreamCalls, 1)
+		probing <- struct{}{}
+		<-release
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"chatcmpl-1","choices":[{"index":0,"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}]}`))
+	})
+	breakers := proxy.NewCircuitBreakers(1, 1, 50*time.Millisecond)
+	breakers.SetHalfOpenMax(1)
+	handler.SetCircuitBreakers(breakers)
+
+	// Force the breaker open, then wait until it will go half-open
+	cb := breakers.Get("custom")
+	cb.RecordFailure()
+	time.Sleep(60 * time.Millisecond)
+
+	var wg sync.WaitGroup
+	codes := make(chan int, 5)
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rec := postMessages(t, handler, simpleRequest())
+			if rec.Code == http.StatusServiceUnavailable && rec.Header().Get("X-CLASP-Circuit-Breaker") != "half-open" {
+				t.Errorf("Expected X-CLASP-Circuit-Breaker: half-open, got %q", rec.Header().Get("X-CLASP-Circuit-Breaker"))
+			}
+			codes <- rec.Code
+		}()
+	}
+
+	// The four requests beyond the probe limit fail fast while the probe is held
+	rejected := 0
+	for rejected < 4 {
+		select {
+		case code := <-codes:
+			if code != http.StatusServiceUnavailable {
+				t.Fatalf("Expected status 503 while probing, got %d", code)
+			}
+			rejected++
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out waiting for fast-failed requests, got %d", rejected)
+		}
+	}
+	select {
+	case <-probing:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the probe to reach the upstream")
+	}
+	if calls := atomic.LoadInt32(&upstreamCalls); calls != 1 {
+		t.Errorf("Expected 1 probe to reach the upstream, got %d", calls)
+	}
+
+	close(release)
+	wg.Wait()
+	if code := <-codes; code != http.StatusOK {
+		t.Errorf("Expected the probe to succeed, got %d", code)
+	}
+	if cb.State() != "closed" {
+		t.Errorf("Expected the breaker to close after the probe succeeded, got %s", cb.State())
+	}
+	if cb.HalfOpenProbes() != 1 || cb.HalfOpenRejected() != 4 {
+		t.Errorf("Expected 1 probe and 4 rejected, got %d and %d", cb.HalfOpenProbes(), cb.HalfOpenRejected())
+	}
+}
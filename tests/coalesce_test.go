@@ -0,0 +1,101 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jedarden/clasp/internal/proxy"
+)
+
+func TestCoalescing_DuplicateRequestsShareUpstreamCall(t *testing.T) {
+	const clients = 5
+	var upstreamCalls int32
+	arrived := make(chan struct{}, clients)
+	release := make(chan struct{})
+	handler := newTestHandler(t, nil, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&upstreamCalls, 1)
+		arrived <- struct{}{}
+		<-release
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(chatCompletionOK))
+	})
+	handler.SetCache(proxy.NewRequestCache(100, time.Hour))
+
+	recs := make([]*httptest.ResponseRecorder, clients)
+	var wg sync.WaitGroup
+	send := func(i int) {
+		defer wg.Done()
+		recs[i] = postMessages(t, handler, simpleRequest())
+	}
+
+	// Hold the first request at the upstream while the duplicates arrive
+	wg.Add(1)
+	go send(0)
+	<-arrived
+	for i := 1; i < clients; i++ {
+		wg.Add(1)
+		go send(i)
+	}
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := atomic.LoadInt32(&upstreamCalls); n != 1 {
+		t.Errorf("Expected 1 upstream call, got %d", n)
+	}
+	coalesced := 0
+	for i, rec := range recs {
+		if rec.Code != http.StatusOK {
+			t.Errorf("Request %d: expected status 200, got %d: %s", i, rec.Code, rec.Body.String())
+		}
+		if rec.Body.String() != recs[0].Body.String() {
+			t.Errorf("Request %d: expected the shared response, got %s", i, rec.Body.String())
+		}
+		if rec.Header().Get("X-CLASP-Coalesced") == "true" {
+			coalesced++
+		}
+	}
+	if coalesced != clients-1 {
+		t.Errorf("Expected %d coalesced responses, got %d", clients-1, coalesced)
+	}
+
+	cacheStats, _ := getMetrics(t, handler)["cache"].(map[string]interface{})
+	if cacheStats["coalesced"] != float64(clients-1) {
+		t.Errorf("Expected %d coalesced requests in metrics, got %v", clients-1, cacheStats["coalesced"])
+	}
+}
+
+func TestCoalescing_OnlyWithCaching(t *testing.T) {
+	var upstreamCalls int32
+	arrived := make(chan struct{}, 2)
+	release := make(chan struct{})
+	handler := newTestHandler(t, nil, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&upstreamCalls, 1)
+		arrived <- struct{}{}
+		<-release
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(chatCompletionOK))
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			postMessages(t, handler, simpleRequest())
+		}()
+	}
+	// Both requests reach the upstream without a cache
+	<-arrived
+	<-arrived
+	close(release)
+	wg.Wait()
+
+	if n := atomic.LoadInt32(&upstreamCalls); n != 2 {
+		t.Errorf("Expected 2 upstream calls without caching, got %d", n)
+	}
+}
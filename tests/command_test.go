@@ -87,3 +87,34 @@ func TestBuildAndRun(t *testing.T) {
 		t.Errorf("version output should contain 'CLASP', got: %s", output)
 	}
 }
+
+// TestCommandCompletion tests that completion scripts cover the subcommands and flags
+func TestCommandCompletion(t *testing.T) {
+	expected := []string{"profile", "status", "logs", "use", "port", "provider", "proxy-only", "skip-permissions"}
+
+	for _, shell := range []string{"bash", "zsh", "fish"} {
+		t.Run(shell, func(t *testing.T) {
+			cmd := exec.Command("go", "run", "../cmd/clasp", "completion", shell)
+			output, err := cmd.Output()
+			if err != nil {
+				t.Fatalf("completion %s failed: %v", shell, err)
+			}
+
+			script := string(output)
+			for _, name := range expected {
+				if !strings.Contains(script, name) {
+					t.Errorf("%s completion should contain '%s'", shell, name)
+				}
+			}
+			if !strings.Contains(script, "clasp") {
+				t.Errorf("%s completion should register for 'clasp'", shell)
+			}
+		})
+	}
+
+	// Unknown shells are rejected
+	cmd := exec.Command("go", "run", "../cmd/clasp", "completion", "tcsh")
+	if err := cmd.Run(); err == nil {
+		t.Error("completion for an unsupported shell should fail")
+	}
+}
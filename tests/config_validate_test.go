@@ -0,0 +1,124 @@
+package tests
+
+import (
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jedarden/clasp/internal/setup"
+)
+
+// reportContains returns true if any message contains substr.
+func reportContains(messages []string, substr string) bool {
+	for _, msg := range messages {
+		if strings.Contains(msg, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestValidateConfig_Valid(t *testing.T) {
+	clearConfigEnv(t)
+	t.Setenv("HOME", writeHomeConfig(t, "config.json", `{
+  "provider": "openai",
+  "api_key": "sk-test",
+  "model": "gpt-4o",
+  "model_aliases": {"fast": "gpt-4o-mini"}
+}`))
+
+	report := setup.ValidateConfig("")
+	if !report.OK() {
+		t.Fatalf("Expected a valid config, got errors: %v", report.Errors)
+	}
+	if len(report.Warnings) > 0 {
+		t.Errorf("Expected no warnings, got %v", report.Warnings)
+	}
+	if !strings.HasSuffix(report.Source, filepath.Join(".clasp", "config.json")) {
+		t.Errorf("Source = %q, want ~/.clasp/config.json", report.Source)
+	}
+}
+
+func TestValidateConfig_MissingKey(t *testing.T) {
+	clearConfigEnv(t)
+	t.Setenv("HOME", t.TempDir())
+	path := filepath.Join(t.TempDir(), "ci.json")
+	writeProjectFile(t, filepath.Dir(path), filepath.Base(path), `{"provider": "openai", "model": "gpt-4o"}`)
+
+	report := setup.ValidateConfig(path)
+	if report.OK() {
+		t.Fatal("Expected errors for a config without an API key")
+	}
+	if !reportContains(report.Errors, "api_key") || !reportContains(report.Errors, "OPENAI_API_KEY") {
+		t.Errorf("Expected errors naming the missing key, got %v", report.Errors)
+	}
+}
+
+func TestValidateConfig_MalformedAlias(t *testing.T) {
+	clearConfigEnv(t)
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("CLASP_MODEL_ALIASES", "fast:gpt-4o-mini,smart")
+	path := filepath.Join(t.TempDir(), "clasp.yaml")
+	writeProjectFile(t, filepath.Dir(path), filepath.Base(path), `provider: openai
+api_keys:
+  openai: sk-test
+aliases:
+  "my alias": gpt-4o
+  empty: ""
+`)
+
+	report := setup.ValidateConfig(path)
+	if report.OK() {
+		t.Fatal("Expected errors for malformed aliases")
+	}
+	for _, want := range []string{`"smart" must be in the form alias:model`, `"my alias" must not contain`, `"empty" has no target`} {
+		if !reportContains(report.Errors, want) {
+			t.Errorf("Expected an error containing %q, got %v", want, report.Errors)
+		}
+	}
+}
+
+func TestValidateConfig_Warnings(t *testing.T) {
+	clearConfigEnv(t)
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("PROVIDER", "openai")
+	t.Setenv("OPENAI_API_KEY", "sk-test")
+	t.Setenv("CLASP_MODEL", "gpt-unknown")
+	t.Setenv("CLASP_MULTI_PROVIDER", "true")
+
+	report := setup.ValidateConfig("")
+	if !report.OK() {
+		t.Fatalf("Expected warnings only, got errors: %v", report.Errors)
+	}
+	if !reportContains(report.Warnings, `"gpt-unknown" is not a known openai model`) {
+		t.Errorf("Expected an unknown model warning, got %v", report.Warnings)
+	}
+	if !reportContains(report.Warnings, "no tier providers are set") {
+		t.Errorf("Expected a multi-provider warning, got %v", report.Warnings)
+	}
+}
+
+// TestCommandConfigValidate checks the exit status of 'clasp config validate'.
+func TestCommandConfigValidate(t *testing.T) {
+	clearConfigEnv(t)
+	dir := t.TempDir()
+	writeProjectFile(t, dir, "valid.json", `{"provider": "openai", "api_key": "sk-test", "model": "gpt-4o"}`)
+	writeProjectFile(t, dir, "missing-key.json", `{"provider": "openai", "model": "gpt-4o"}`)
+
+	cmd := exec.Command("go", "run", "../cmd/clasp", "config", "validate", "--file", filepath.Join(dir, "valid.json"))
+	cmd.Env = commandEnvWithHome(t, t.TempDir())
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("Expected valid config to pass: %v, output: %s", err, output)
+	}
+
+	cmd = exec.Command("go", "run", "../cmd/clasp", "config", "validate", "--file", filepath.Join(dir, "missing-key.json"))
+	cmd.Env = commandEnvWithHome(t, t.TempDir())
+	output, err := cmd.CombinedOutput()
+	if err == nil {
+		t.Fatalf("Expected a non-zero exit for a missing key, output: %s", output)
+	}
+	if !strings.Contains(string(output), "api_key") {
+		t.Errorf("Expected output to name the missing field, got: %s", output)
+	}
+}
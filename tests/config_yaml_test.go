@@ -0,0 +1,141 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/jedarden/clasp/internal/config"
+	"github.com/jedarden/clasp/internal/setup"
+)
+
+// configEnvVars are the variables the config files map onto; ApplyConfigToEnv sets them.
+var configEnvVars = []string{
+	"PROVIDER", "OPENAI_API_KEY", "CLASP_MODEL", "CLASP_MODEL_ALIASES", "CLASP_CONFIG_FILE",
+	"CLASP_MULTI_PROVIDER", "CLASP_MODEL_OPUS", "CLASP_MODEL_SONNET", "CLASP_MODEL_HAIKU",
+}
+
+// clearConfigEnv empties the config variables for the rest of the test.
+func clearConfigEnv(t *testing.T) {
+	t.Helper()
+	for _, name := range configEnvVars {
+		t.Setenv(name, "")
+	}
+}
+
+// writeHomeConfig writes ~/.clasp/<name> under a new temp HOME and returns the HOME.
+func writeHomeConfig(t *testing.T, name, content string) string {
+	t.Helper()
+	home := t.TempDir()
+	dir := filepath.Join(home, ".clasp")
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	return home
+}
+
+// TestConfigYAMLMatchesJSON loads equivalent config.json and config.yaml files
+// and checks they produce the same configuration.
+func TestConfigYAMLMatchesJSON(t *testing.T) {
+	clearConfigEnv(t)
+	t.Setenv("HOME", writeHomeConfig(t, "config.json", `{
+  "provider": "openai",
+  "api_key": "sk-test",
+  "model": "gpt-4o",
+  "model_aliases": {"fast": "gpt-4o-mini", "smart": "gpt-4o"}
+}`))
+	if err := setup.ApplyConfigToEnv(); err != nil {
+		t.Fatalf("ApplyConfigToEnv failed: %v", err)
+	}
+	fromJSON, err := config.LoadWithFile()
+	if err != nil {
+		t.Fatalf("LoadWithFile (JSON) failed: %v", err)
+	}
+
+	clearConfigEnv(t)
+	t.Setenv("HOME", writeHomeConfig(t, "config.yaml", `# Same settings as the JSON file
+provider: openai
+api_keys:
+  openai: sk-test
+models:
+  default: gpt-4o
+aliases:
+  fast: gpt-4o-mini
+  smart: gpt-4o
+`))
+	fromYAML, err := config.LoadWithFile()
+	if err != nil {
+		t.Fatalf("LoadWithFile (YAML) failed: %v", err)
+	}
+
+	jsonValue := reflect.ValueOf(fromJSON).Elem()
+	yamlValue := reflect.ValueOf(fromYAML).Elem()
+	for i := 0; i < jsonValue.NumField(); i++ {
+		if !reflect.DeepEqual(jsonValue.Field(i).Interface(), yamlValue.Field(i).Interface()) {
+			t.Errorf("%s differs: JSON %#v, YAML %#v", jsonValue.Type().Field(i).Name,
+				jsonValue.Field(i).Interface(), yamlValue.Field(i).Interface())
+		}
+	}
+}
+
+// TestConfigYAML_MultiProvider checks tier routing and aliases in ~/.clasp/config.yml.
+func TestConfigYAML_MultiProvider(t *testing.T) {
+	clearConfigEnv(t)
+	t.Setenv("OPENROUTER_API_KEY", "")
+	t.Setenv("HOME", writeHomeConfig(t, "config.yml", `provider: openai
+api_keys:
+  openai: sk-test
+  openrouter: ${TEST_OPENROUTER_KEY:-sk-or-test}
+multi_provider:
+  enabled: true
+  opus:
+    provider: openrouter
+    model: anthropic/claude-3-opus
+  haiku:
+    model: gpt-4o-mini
+aliases:
+  fast: gpt-4o-mini
+`))
+
+	cfg, err := config.LoadWithFile()
+	if err != nil {
+		t.Fatalf("LoadWithFile failed: %v", err)
+	}
+	if !cfg.MultiProviderEnabled {
+		t.Fatal("Expected multi-provider routing to be enabled")
+	}
+	if cfg.TierOpus == nil || cfg.TierOpus.Provider != config.ProviderOpenRouter || cfg.TierOpus.Model != "anthropic/claude-3-opus" {
+		t.Errorf("Unexpected opus tier: %+v", cfg.TierOpus)
+	}
+	if cfg.TierOpus != nil && cfg.TierOpus.APIKey != "sk-or-test" {
+		t.Errorf("Expected opus tier to use the OpenRouter key, got %q", cfg.TierOpus.APIKey)
+	}
+	if cfg.TierHaiku == nil || cfg.TierHaiku.Model != "gpt-4o-mini" {
+		t.Errorf("Unexpected haiku tier: %+v", cfg.TierHaiku)
+	}
+	if cfg.ModelAliases["fast"] != "gpt-4o-mini" {
+		t.Errorf("Expected alias fast -> gpt-4o-mini, got %v", cfg.ModelAliases)
+	}
+}
+
+// TestNeedsSetup_YAMLConfig checks that a YAML config file counts as configuration.
+func TestNeedsSetup_YAMLConfig(t *testing.T) {
+	clearConfigEnv(t)
+	for _, name := range []string{"AZURE_API_KEY", "OPENROUTER_API_KEY", "ANTHROPIC_API_KEY", "GEMINI_API_KEY", "DEEPSEEK_API_KEY", "CUSTOM_API_KEY"} {
+		t.Setenv(name, "")
+	}
+
+	t.Setenv("HOME", t.TempDir())
+	if !setup.NeedsSetup() {
+		t.Skip("a config file outside HOME is present")
+	}
+
+	t.Setenv("HOME", writeHomeConfig(t, "config.yaml", "provider: openai\n"))
+	if setup.NeedsSetup() {
+		t.Error("Expected no setup with ~/.clasp/config.yaml present")
+	}
+}
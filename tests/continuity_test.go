@@ -0,0 +1,152 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jedarden/clasp/internal/config"
+	"github.com/jedarden/clasp/internal/proxy"
+	"github.com/jedarden/clasp/internal/session"
+	"github.com/jedarden/clasp/pkg/models"
+)
+
+// responsesUpstream returns a Responses API upstream that records each request
+// body and answers with resp_1, resp_2, ... Requests continuing from an ID in
+// rejected get a 400 previous_response_not_found error.
+func responsesUpstream(t *testing.T, rejected map[string]bool) (*config.Config, *[]map[string]interface{}) {
+	t.Helper()
+	var mu sync.Mutex
+	var bodies []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := io.ReadAll(r.Body)
+		var body map[string]interface{}
+		if err := json.Unmarshal(raw, &body); err != nil {
+			t.Errorf("Invalid upstream request body: %v", err)
+		}
+		mu.Lock()
+		bodies = append(bodies, body)
+		n := len(bodies)
+		mu.Unlock()
+
+		if id, _ := body["previous_response_id"].(string); rejected[id] {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, `{"error":{"message":"Previous response with id '%s' not found.","type":"invalid_request_error","param":"previous_response_id","code":"previous_response_not_found"}}`, id)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"id":"resp_%d","object":"response","status":"completed","output":[{"type":"message","role":"assistant","content":[{"type":"output_text","text":"Turn %d"}]}],"usage":{"input_tokens":10,"output_tokens":2}}`, n, n)
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := config.DefaultConfig()
+	cfg.Provider = config.ProviderOpenAI
+	cfg.OpenAIBaseURL = server.URL
+	cfg.OpenAIAPIKey = "test-key"
+	cfg.DefaultModel = "gpt-5"
+	cfg.CompactionEnabled = true
+	return cfg, &bodies
+}
+
+// conversationTurns returns the first and second turn of the same conversation.
+func conversationTurns() (*models.AnthropicRequest, *models.AnthropicRequest) {
+	first := simpleRequest()
+	second := simpleRequest()
+	second.Messages = append(second.Messages,
+		models.AnthropicMessage{Role: "assistant", Content: "Turn 1"},
+		models.AnthropicMessage{Role: "user", Content: "And the next step?"},
+	)
+	return first, second
+}
+
+func TestResponsesContinuity_SecondTurnUsesStoredID(t *testing.T) {
+	cfg, bodies := responsesUpstream(t, nil)
+	handler, err := proxy.NewHandler(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create handler: %v", err)
+	}
+	tracker := session.NewTracker(time.Hour)
+	defer tracker.Stop()
+	handler.SetSessionTracker(tracker)
+
+	first, second := conversationTurns()
+	for i, req := range []*models.AnthropicRequest{first, second} {
+		if rec := postMessages(t, handler, req); rec.Code != http.StatusOK {
+			t.Fatalf("turn %d: expected status 200, got %d: %s", i+1, rec.Code, rec.Body.String())
+		}
+	}
+
+	if len(*bodies) != 2 {
+		t.Fatalf("Expected 2 upstream requests, got %d", len(*bodies))
+	}
+	if _, ok := (*bodies)[0]["previous_response_id"]; ok {
+		t.Error("First turn should not send previous_response_id")
+	}
+	if got := (*bodies)[1]["previous_response_id"]; got != "resp_1" {
+		t.Errorf("Second turn previous_response_id = %v, want resp_1", got)
+	}
+	input, _ := json.Marshal((*bodies)[1]["input"])
+	if strings.Contains(string(input), "Hello") {
+		t.Errorf("Second turn should only send the new messages, got input %s", input)
+	}
+	if !strings.Contains(string(input), "And the next step?") {
+		t.Errorf("Second turn should send the new user message, got input %s", input)
+	}
+	if hits := handler.GetMetrics().CompactionHits; hits != 1 {
+		t.Errorf("CompactionHits = %d, want 1", hits)
+	}
+}
+
+func TestResponsesContinuity_RejectedIDFallsBackToFullContext(t *testing.T) {
+	cfg, bodies := responsesUpstream(t, map[string]bool{"resp_1": true})
+	handler, err := proxy.NewHandler(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create handler: %v", err)
+	}
+	tracker := session.NewTracker(time.Hour)
+	defer tracker.Stop()
+	handler.SetSessionTracker(tracker)
+
+	first, second := conversationTurns()
+	if rec := postMessages(t, handler, first); rec.Code != http.StatusOK {
+		t.Fatalf("turn 1: expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec := postMessages(t, handler, second)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("turn 2: expected status 200 after the full-context retry, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	if len(*bodies) != 3 {
+		t.Fatalf("Expected 3 upstream requests, got %d", len(*bodies))
+	}
+	retry := (*bodies)[2]
+	if _, ok := retry["previous_response_id"]; ok {
+		t.Error("Retry should not send previous_response_id")
+	}
+	input, _ := json.Marshal(retry["input"])
+	if !strings.Contains(string(input), "Hello") || !strings.Contains(string(input), "And the next step?") {
+		t.Errorf("Retry should send the full conversation, got input %s", input)
+	}
+	if resets := handler.GetMetrics().CompactionResets; resets != 1 {
+		t.Errorf("CompactionResets = %d, want 1", resets)
+	}
+
+	// The retry's response starts a new chain for the conversation
+	third := *second
+	third.Messages = append(append([]models.AnthropicMessage{}, second.Messages...),
+		models.AnthropicMessage{Role: "assistant", Content: "Turn 3"},
+		models.AnthropicMessage{Role: "user", Content: "Thanks"},
+	)
+	if rec := postMessages(t, handler, &third); rec.Code != http.StatusOK {
+		t.Fatalf("turn 3: expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if got := (*bodies)[3]["previous_response_id"]; got != "resp_3" {
+		t.Errorf("Third turn previous_response_id = %v, want resp_3", got)
+	}
+}
@@ -2,6 +2,10 @@ package tests
 
 import (
 	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -379,3 +383,221 @@ func TestCostTracker_ZeroDivision(t *testing.T) {
 		t.Errorf("Expected zero cost per request, got %f", summary.CostPerRequest)
 	}
 }
+
+// simulatedClock is a settable clock for the cost tracker.
+type simulatedClock struct{ now time.Time }
+
+func (c *simulatedClock) Now() time.Time { return c.now }
+
+// TestCostTracker_GetWindow tests hourly bucketing of usage over a window.
+func TestCostTracker_GetWindow(t *testing.T) {
+	tracker := proxy.NewCostTracker()
+	clock := &simulatedClock{now: time.Date(2025, 3, 10, 12, 30, 0, 0, time.UTC)}
+	tracker.SetClock(clock.Now)
+
+	// Two requests 3 hours ago, one an hour ago, two in the current hour
+	clock.now = clock.now.Add(-3 * time.Hour)
+	tracker.RecordUsage("openai", "gpt-4o", 1000, 500)
+	tracker.RecordUsage("openai", "gpt-4o", 1000, 500)
+	clock.now = clock.now.Add(2 * time.Hour)
+	tracker.RecordUsage("openai", "gpt-4o-mini", 2000, 1000)
+	clock.now = clock.now.Add(time.Hour + 10*time.Minute)
+	tracker.RecordUsage("openai", "gpt-4o", 100, 50)
+	tracker.RecordEmbeddingUsage("text-embedding-3-small", 500)
+
+	window := tracker.GetWindow(4 * time.Hour)
+	if window.Window != "4h0m0s" || window.Bucket != "1h0m0s" {
+		t.Errorf("Expected a 4h window of 1h buckets, got %q of %q", window.Window, window.Bucket)
+	}
+	if len(window.Buckets) != 4 {
+		t.Fatalf("Expected 4 buckets, got %d", len(window.Buckets))
+	}
+
+	wantStart := time.Date(2025, 3, 10, 9, 0, 0, 0, time.UTC)
+	wantRequests := []int64{2, 0, 1, 2}
+	wantInput := []int64{2000, 0, 2000, 600}
+	for i, b := range window.Buckets {
+		if !b.Start.Equal(wantStart.Add(time.Duration(i) * time.Hour)) {
+			t.Errorf("Bucket %d: expected start %v, got %v", i, wantStart.Add(time.Duration(i)*time.Hour), b.Start)
+		}
+		if b.Requests != wantRequests[i] || b.InputTokens != wantInput[i] {
+			t.Errorf("Bucket %d: expected %d requests and %d input tokens, got %d and %d", i, wantRequests[i], wantInput[i], b.Requests, b.InputTokens)
+		}
+	}
+
+	// 2 x gpt-4o (1000/500) = $0.015
+	if cost := window.Buckets[0].CostUSD; cost < 0.0149 || cost > 0.0151 {
+		t.Errorf("Expected ~$0.015 in the first bucket, got %f", cost)
+	}
+	if window.TotalRequests != 5 || window.TotalOutputTokens != 2050 {
+		t.Errorf("Expected 5 requests and 2050 output tokens in total, got %d and %d", window.TotalRequests, window.TotalOutputTokens)
+	}
+	if diff := window.TotalCostUSD - tracker.GetTotalCostUSD(); diff < -1e-9 || diff > 1e-9 {
+		t.Errorf("Expected the window total %f to match the overall total %f", window.TotalCostUSD, tracker.GetTotalCostUSD())
+	}
+
+	// A shorter window only sees the latest hours
+	if recent := tracker.GetWindow(time.Hour); recent.TotalRequests != 2 || len(recent.Buckets) != 1 {
+		t.Errorf("Expected 2 requests in one bucket for 1h, got %d in %d", recent.TotalRequests, len(recent.Buckets))
+	}
+}
+
+// TestCostTracker_GetWindowDropsOldHours tests that the history is bounded to
+// 30 days.
+func TestCostTracker_GetWindowDropsOldHours(t *testing.T) {
+	tracker := proxy.NewCostTracker()
+	clock := &simulatedClock{now: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+	tracker.SetClock(clock.Now)
+
+	tracker.RecordUsage("openai", "gpt-4o", 1000, 500)
+	// Exactly 30 days later the same ring slot is reused
+	clock.now = clock.now.Add(30 * 24 * time.Hour)
+	tracker.RecordUsage("openai", "gpt-4o", 10, 5)
+
+	window := tracker.GetWindow(1000 * time.Hour)
+	if len(window.Buckets) != 720 {
+		t.Errorf("Expected the window to be capped at 720 buckets, got %d", len(window.Buckets))
+	}
+	if window.TotalRequests != 1 || window.TotalInputTokens != 10 {
+		t.Errorf("Expected only the recent request, got %d requests and %d input tokens", window.TotalRequests, window.TotalInputTokens)
+	}
+
+	tracker.Reset()
+	if window := tracker.GetWindow(24 * time.Hour); window.TotalRequests != 0 {
+		t.Errorf("Expected reset to clear the history, got %d requests", window.TotalRequests)
+	}
+}
+
+// TestHandleCosts_Window tests the /costs?window= endpoint.
+func TestHandleCosts_Window(t *testing.T) {
+	handler := newTestHandler(t, nil, func(w http.ResponseWriter, r *http.Request) {})
+	tracker := handler.GetCostTracker()
+	clock := &simulatedClock{now: time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)}
+	tracker.SetClock(clock.Now)
+	tracker.RecordUsage("openai", "gpt-4o", 1000, 500)
+	clock.now = clock.now.Add(25 * time.Hour)
+	tracker.RecordUsage("openai", "gpt-4o", 200, 100)
+
+	for _, tc := range []struct {
+		window       string
+		wantBuckets  int
+		wantRequests int64
+	}{
+		{"24h", 24, 1},
+		{"2d", 48, 2},
+		{"90m", 2, 1},
+	} {
+		rec := httptest.NewRecorder()
+		handler.HandleCosts(rec, httptest.NewRequest(http.MethodGet, "/costs?window="+tc.window, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("window=%s: expected status 200, got %d: %s", tc.window, rec.Code, rec.Body.String())
+		}
+		var window proxy.CostWindow
+		if err := json.Unmarshal(rec.Body.Bytes(), &window); err != nil {
+			t.Fatalf("window=%s: failed to decode response: %v", tc.window, err)
+		}
+		if len(window.Buckets) != tc.wantBuckets || window.TotalRequests != tc.wantRequests {
+			t.Errorf("window=%s: expected %d buckets and %d requests, got %d and %d", tc.window, tc.wantBuckets, tc.wantRequests, len(window.Buckets), window.TotalRequests)
+		}
+	}
+
+	for _, window := range []string{"yesterday", "10m", "31d"} {
+		rec := httptest.NewRecorder()
+		handler.HandleCosts(rec, httptest.NewRequest(http.MethodGet, "/costs?window="+window, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("window=%s: expected status 400, got %d", window, rec.Code)
+		}
+	}
+}
+
+func TestHandleCostModels_SortAndShare(t *testing.T) {
+	handler := newTestHandler(t, nil, func(w http.ResponseWriter, r *http.Request) {})
+	tracker := handler.GetCostTracker()
+	tracker.SetCustomPricing("model-a", proxy.ModelPricing{InputPer1M: 300})
+	tracker.SetCustomPricing("model-b", proxy.ModelPricing{InputPer1M: 25})
+	tracker.SetCustomPricing("model-c", proxy.ModelPricing{InputPer1M: 200})
+
+	// model-a: $3.00, 1M tokens, 1 request
+	tracker.RecordUsage("custom", "model-a", 1000000, 0)
+	// model-b: $1.00, 4M tokens, 2 requests
+	tracker.RecordUsage("custom", "model-b", 2000000, 0)
+	tracker.RecordUsage("custom", "model-b", 2000000, 0)
+	// model-c: $1.00, 0.5M tokens, 5 requests
+	for i := 0; i < 5; i++ {
+		tracker.RecordUsage("custom", "model-c", 100000, 0)
+	}
+
+	for _, tc := range []struct {
+		sort string
+		want []string
+	}{
+		{"", []string{"model-a", "model-b", "model-c"}}, // model-b and model-c tie on cost
+		{"cost", []string{"model-a", "model-b", "model-c"}},
+		{"tokens", []string{"model-b", "model-a", "model-c"}},
+		{"requests", []string{"model-c", "model-b", "model-a"}},
+	} {
+		rec := httptest.NewRecorder()
+		handler.HandleCostModels(rec, httptest.NewRequest(http.MethodGet, "/costs/models?sort="+tc.sort, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("sort=%s: expected status 200, got %d: %s", tc.sort, rec.Code, rec.Body.String())
+		}
+		var board proxy.ModelLeaderboard
+		if err := json.Unmarshal(rec.Body.Bytes(), &board); err != nil {
+			t.Fatalf("sort=%s: failed to decode response: %v", tc.sort, err)
+		}
+		var got []string
+		for _, m := range board.Models {
+			got = append(got, m.Model)
+		}
+		if strings.Join(got, ",") != strings.Join(tc.want, ",") {
+			t.Errorf("sort=%s: expected order %v, got %v", tc.sort, tc.want, got)
+		}
+		if math.Abs(board.TotalCostUSD-5.0) > 0.001 {
+			t.Errorf("sort=%s: expected total cost $5.00, got %f", tc.sort, board.TotalCostUSD)
+		}
+	}
+
+	board, err := proxy.NewModelLeaderboard(tracker.GetSummary(), "cost")
+	if err != nil {
+		t.Fatalf("NewModelLeaderboard failed: %v", err)
+	}
+	wantShares := map[string]float64{"model-a": 60, "model-b": 20, "model-c": 20}
+	wantTokens := map[string]int64{"model-a": 1000000, "model-b": 4000000, "model-c": 500000}
+	wantRequests := map[string]int64{"model-a": 1, "model-b": 2, "model-c": 5}
+	var totalShare float64
+	for _, m := range board.Models {
+		if math.Abs(m.CostSharePct-wantShares[m.Model]) > 0.01 {
+			t.Errorf("%s: expected %.0f%% of spend, got %f", m.Model, wantShares[m.Model], m.CostSharePct)
+		}
+		if m.TotalTokens != wantTokens[m.Model] || m.Requests != wantRequests[m.Model] {
+			t.Errorf("%s: expected %d tokens in %d requests, got %d in %d", m.Model, wantTokens[m.Model], wantRequests[m.Model], m.TotalTokens, m.Requests)
+		}
+		totalShare += m.CostSharePct
+	}
+	if math.Abs(totalShare-100) > 0.01 {
+		t.Errorf("Expected shares to add up to 100%%, got %f", totalShare)
+	}
+
+	rec := httptest.NewRecorder()
+	handler.HandleCostModels(rec, httptest.NewRequest(http.MethodGet, "/costs/models?sort=latency", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("sort=latency: expected status 400, got %d", rec.Code)
+	}
+}
+
+func TestModelLeaderboard_NoSpend(t *testing.T) {
+	tracker := proxy.NewCostTracker()
+	tracker.SetCustomPricing("free-model", proxy.ModelPricing{})
+	tracker.RecordUsage("custom", "free-model", 1000, 500)
+
+	board, err := proxy.NewModelLeaderboard(tracker.GetSummary(), "")
+	if err != nil {
+		t.Fatalf("NewModelLeaderboard failed: %v", err)
+	}
+	if board.Sort != proxy.CostSortCost {
+		t.Errorf("Expected the default sort to be cost, got %q", board.Sort)
+	}
+	if len(board.Models) != 1 || board.Models[0].CostSharePct != 0 {
+		t.Errorf("Expected one model with a 0%% share, got %+v", board.Models)
+	}
+}
@@ -0,0 +1,126 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/jedarden/clasp/internal/setup"
+)
+
+func TestFetchModels_CustomModelsPath(t *testing.T) {
+	var gotPath, gotAuth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotAuth = r.URL.Path, r.Header.Get("Authorization")
+		if r.URL.Path != "/v1/api/v2/list-models" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"models":[{"name":"llama-3-70b"},{"name":"mixtral-8x7b"}]}`))
+	}))
+	t.Cleanup(server.Close)
+
+	t.Setenv("CUSTOM_MODELS_PATH", "api/v2/list-models")
+	models, err := setup.NewWizard().FetchModelsPublic("custom", "sk-gateway", server.URL+"/v1", "")
+	if err != nil {
+		t.Fatalf("FetchModelsPublic failed: %v", err)
+	}
+	if gotPath != "/v1/api/v2/list-models" {
+		t.Errorf("Expected the path under the base URL, got %q", gotPath)
+	}
+	if gotAuth != "Bearer sk-gateway" {
+		t.Errorf("Expected the API key to be sent, got %q", gotAuth)
+	}
+	want := []string{"llama-3-70b", "mixtral-8x7b"}
+	if !reflect.DeepEqual(models, want) {
+		t.Errorf("Expected %v, got %v", want, models)
+	}
+}
+
+func TestFetchModels_CustomModelsPathFullURL(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/catalog" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(`["qwen-2.5-coder","deepseek-v3"]`))
+	}))
+	t.Cleanup(server.Close)
+
+	t.Setenv("CUSTOM_MODELS_PATH", server.URL+"/catalog")
+	models, err := setup.NewWizard().FetchModelsPublic("custom", "", "http://gateway.invalid/v1", "")
+	if err != nil {
+		t.Fatalf("FetchModelsPublic failed: %v", err)
+	}
+	want := []string{"deepseek-v3", "qwen-2.5-coder"}
+	if !reflect.DeepEqual(models, want) {
+		t.Errorf("Expected %v, got %v", want, models)
+	}
+}
+
+func TestFetchModels_CustomDefaultPath(t *testing.T) {
+	var gotPath string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		_, _ = w.Write([]byte(`{"data":[{"id":"gpt-4o"},{"id":"text-embedding-3-small"}]}`))
+	}))
+	t.Cleanup(server.Close)
+
+	t.Setenv("CUSTOM_MODELS_PATH", "")
+	models, err := setup.NewWizard().FetchModelsPublic("custom", "", server.URL, "")
+	if err != nil {
+		t.Fatalf("FetchModelsPublic failed: %v", err)
+	}
+	if gotPath != "/models" {
+		t.Errorf("Expected /models, got %q", gotPath)
+	}
+	if !reflect.DeepEqual(models, []string{"gpt-4o"}) {
+		t.Errorf("Expected chat models only, got %v", models)
+	}
+}
+
+func TestFetchModels_AzureDeployments(t *testing.T) {
+	var gotPath, gotAuth, gotVersion string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotAuth, gotVersion = r.URL.Path, r.Header.Get("Authorization"), r.URL.Query().Get("api-version")
+		_, _ = w.Write([]byte(`{"value":[{"name":"prod-gpt4o","properties":{"model":{"name":"gpt-4o"}}},{"name":"mini","properties":{"model":{"name":"gpt-4o-mini"}}}]}`))
+	}))
+	t.Cleanup(server.Close)
+
+	t.Setenv("AZURE_MGMT_URL", server.URL)
+	t.Setenv("AZURE_MGMT_TOKEN", "mgmt-token")
+	t.Setenv("AZURE_MGMT_SUBSCRIPTION_ID", "sub-123")
+	t.Setenv("AZURE_MGMT_RESOURCE_GROUP", "rg-ai")
+	t.Setenv("AZURE_MGMT_ACCOUNT", "")
+
+	models, err := setup.NewWizard().FetchModelsPublic("azure", "azure-key", "", "https://my-openai.openai.azure.com")
+	if err != nil {
+		t.Fatalf("FetchModelsPublic failed: %v", err)
+	}
+	wantPath := "/subscriptions/sub-123/resourceGroups/rg-ai/providers/Microsoft.CognitiveServices/accounts/my-openai/deployments"
+	if gotPath != wantPath {
+		t.Errorf("Expected %s, got %s", wantPath, gotPath)
+	}
+	if gotAuth != "Bearer mgmt-token" {
+		t.Errorf("Expected the management token, got %q", gotAuth)
+	}
+	if gotVersion == "" {
+		t.Error("Expected an api-version query parameter")
+	}
+	if want := []string{"mini", "prod-gpt4o"}; !reflect.DeepEqual(models, want) {
+		t.Errorf("Expected %v, got %v", want, models)
+	}
+}
+
+func TestFetchModels_AzureWithoutManagementCredentials(t *testing.T) {
+	t.Setenv("AZURE_MGMT_TOKEN", "")
+	models, err := setup.NewWizard().FetchModelsPublic("azure", "azure-key", "", "https://my-openai.openai.azure.com")
+	if err != nil {
+		t.Fatalf("FetchModelsPublic failed: %v", err)
+	}
+	if len(models) == 0 || models[0] != "gpt-4" {
+		t.Errorf("Expected the known Azure models, got %v", models)
+	}
+}
@@ -0,0 +1,76 @@
+package tests
+
+import (
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/jedarden/clasp/internal/config"
+	"github.com/jedarden/clasp/internal/logging"
+)
+
+func TestDebugSampling_LogsConfiguredFraction(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	if err := logging.EnableDebugSampling(); err != nil {
+		t.Fatalf("Failed to enable debug sampling: %v", err)
+	}
+	t.Cleanup(logging.DisableDebugLogging)
+
+	handler := newTestHandler(t, func(cfg *config.Config) {
+		cfg.DebugSampleRate = 0.25
+	}, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"chatcmpl-1","choices":[{"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}],"usage":{"prompt_tokens":10,"completion_tokens":5}}`))
+	})
+
+	const requests = 400
+	for i := 0; i < requests; i++ {
+		if rec := postMessages(t, handler, simpleRequest()); rec.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+	}
+
+	data, err := os.ReadFile(logging.GetDebugLogFilePath())
+	if err != nil {
+		t.Fatalf("Failed to read debug log: %v", err)
+	}
+	debugLog := string(data)
+	incoming := strings.Count(debugLog, "[INCOMING] /v1/messages")
+	outgoing := strings.Count(debugLog, "[OUTGOING] /v1/chat/completions")
+	responses := strings.Count(debugLog, "[RESPONSE] /v1/messages (transformed)")
+
+	// 25% of 400 is 100; allow for random variation (about 5 standard deviations)
+	if incoming < 60 || incoming > 140 {
+		t.Errorf("Expected about 100 of %d requests to be sampled, got %d", requests, incoming)
+	}
+	// A sampled request logs its request, upstream request, and response
+	if outgoing != incoming || responses != incoming {
+		t.Errorf("Expected every sampled request to log all payloads, got %d incoming, %d outgoing, %d responses",
+			incoming, outgoing, responses)
+	}
+}
+
+func TestDebugSampling_Disabled(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	if err := logging.EnableDebugSampling(); err != nil {
+		t.Fatalf("Failed to enable debug sampling: %v", err)
+	}
+	t.Cleanup(logging.DisableDebugLogging)
+
+	handler := newTestHandler(t, nil, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"chatcmpl-1","choices":[{"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}],"usage":{"prompt_tokens":10,"completion_tokens":5}}`))
+	})
+	for i := 0; i < 20; i++ {
+		postMessages(t, handler, simpleRequest())
+	}
+
+	data, err := os.ReadFile(logging.GetDebugLogFilePath())
+	if err != nil {
+		t.Fatalf("Failed to read debug log: %v", err)
+	}
+	if strings.Contains(string(data), "/v1/messages") {
+		t.Error("Expected no captures with a sample rate of 0")
+	}
+}
@@ -0,0 +1,260 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jedarden/clasp/internal/config"
+	"github.com/jedarden/clasp/internal/proxy"
+)
+
+// newDLQQueue creates a queue with a dead-letter queue in a temporary directory.
+func newDLQQueue(t *testing.T, maxRetries int) (*proxy.RequestQueue, *proxy.DeadLetterQueue, string) {
+	t.Helper()
+	queue := proxy.NewRequestQueue(&proxy.QueueConfig{
+		Enabled:    true,
+		MaxSize:    10,
+		MaxWait:    5 * time.Second,
+		MaxRetries: maxRetries,
+	})
+	t.Cleanup(queue.Close)
+
+	dir := t.TempDir()
+	dlq, err := proxy.NewDeadLetterQueue(dir)
+	if err != nil {
+		t.Fatalf("Failed to create dead-letter queue: %v", err)
+	}
+	queue.SetDeadLetterQueue(dlq)
+	return queue, dlq, dir
+}
+
+func TestRequestQueue_DeadLetterAfterMaxRetries(t *testing.T) {
+	queue, dlq, dir := newDLQQueue(t, 2)
+
+	body := `{"model":"claude-3-5-sonnet","api_key":"sk-abcdefghijklmnopqrstuvwxyz","messages":[{"role":"user","content":"hi"}]}`
+	resultCh, err := queue.EnqueueWithPriority([]byte(body), proxy.PriorityHigh)
+	if err != nil {
+		t.Fatalf("Failed to enqueue: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	attempts := 0
+	for {
+		req, err := queue.Dequeue(ctx)
+		if err != nil {
+			t.Fatalf("Failed to dequeue: %v", err)
+		}
+		attempts++
+		if !queue.Retry(req, errors.New("upstream returned status 503")) {
+			break
+		}
+	}
+	if attempts != 3 {
+		t.Errorf("Expected 3 attempts (1 + 2 retries), got %d", attempts)
+	}
+
+	select {
+	case result := <-resultCh:
+		if result.Error == nil || !strings.Contains(result.Error.Error(), "503") {
+			t.Errorf("Expected the last error to be returned, got %v", result.Error)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for the failed result")
+	}
+
+	letters, err := dlq.List()
+	if err != nil {
+		t.Fatalf("Failed to list dead letters: %v", err)
+	}
+	if len(letters) != 1 {
+		t.Fatalf("Expected 1 dead letter, got %d", len(letters))
+	}
+	letter := letters[0]
+	if letter.Retries != 2 || letter.LastError != "upstream returned status 503" || letter.Priority != "high" {
+		t.Errorf("Unexpected dead letter: %+v", letter)
+	}
+	if strings.Contains(letter.Body, "sk-abcdefghijklmnopqrstuvwxyz") {
+		t.Errorf("Expected the API key to be masked, got %s", letter.Body)
+	}
+	if !strings.Contains(letter.Body, "claude-3-5-sonnet") {
+		t.Errorf("Expected the rest of the body to be kept, got %s", letter.Body)
+	}
+
+	info, err := os.Stat(filepath.Join(dir, letter.ID+".json"))
+	if err != nil {
+		t.Fatalf("Expected the dead letter on disk: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Errorf("Expected mode 0600, got %o", perm)
+	}
+	if stats := queue.Stats(); stats.DeadLettered != 1 || stats.Retried != 2 {
+		t.Errorf("Expected 1 dead-lettered and 2 retried, got %d and %d", stats.DeadLettered, stats.Retried)
+	}
+}
+
+func TestHandleDLQ_ListAndReplay(t *testing.T) {
+	handler := newTestHandler(t, func(cfg *config.Config) {
+		cfg.AuthEnabled = true
+		cfg.AuthAPIKey = "proxy-key"
+	}, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	queue, _, _ := newDLQQueue(t, 0)
+	handler.SetQueue(queue)
+
+	if _, err := queue.EnqueueWithPriority([]byte(`{"n":1}`), proxy.PriorityLow); err != nil {
+		t.Fatalf("Failed to enqueue: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	req, err := queue.Dequeue(ctx)
+	if err != nil {
+		t.Fatalf("Failed to dequeue: %v", err)
+	}
+	if queue.Retry(req, errors.New("connection refused")) {
+		t.Fatal("Expected no retries with MaxRetries 0")
+	}
+
+	dlqRequest := func(method, target string) (*httptest.ResponseRecorder, map[string]interface{}) {
+		rec := httptest.NewRecorder()
+		handler.HandleDLQ(rec, httptest.NewRequest(method, target, http.NoBody))
+		var body map[string]interface{}
+		_ = json.Unmarshal(rec.Body.Bytes(), &body)
+		return rec, body
+	}
+
+	rec, body := dlqRequest(http.MethodGet, "/queue/dlq")
+	if rec.Code != http.StatusOK || body["count"] != float64(1) {
+		t.Fatalf("Expected 1 dead letter, got %d: %v", rec.Code, body)
+	}
+
+	// Without queue workers nothing would send replayed requests upstream
+	if rec, _ := dlqRequest(http.MethodPost, "/queue/dlq?action=replay"); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 replaying without queue workers, got %d", rec.Code)
+	}
+	if _, body := dlqRequest(http.MethodGet, "/queue/dlq"); body["count"] != float64(1) {
+		t.Errorf("Expected the dead letter to be kept, got %v", body)
+	}
+}
+
+func TestQueue_DeadLetterAndReplay(t *testing.T) {
+	var healthy int32
+	served := make(chan string, 10)
+	server := newQueuedServer(t, func(w http.ResponseWriter, r *http.Request) {
+		prompt := upstreamPrompt(r)
+		if atomic.LoadInt32(&healthy) == 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte(`{"error":{"message":"upstream down"}}`))
+			return
+		}
+		served <- prompt
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(chatCompletionOK))
+	}, func(cfg *config.Config) {
+		cfg.AuthEnabled = true
+		cfg.AuthAPIKey = "proxy-key"
+		cfg.RetryMax = 1
+		cfg.QueueMaxRetries = 1
+		cfg.QueueRetryDelayMs = 0
+	})
+	routes := server.Routes()
+
+	// The request fails its attempt and its retry, then is dead-lettered
+	// The client gets the response of the last attempt
+	rec := postQueued(t, routes, "during outage", "high")
+	if rec.Code != http.StatusBadGateway || !strings.Contains(rec.Body.String(), `"api_error"`) {
+		t.Fatalf("Expected the upstream error, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if stats := queueMetrics(t, server); stats["retried"] != float64(1) || stats["dead_lettered"] != float64(1) {
+		t.Errorf("Expected 1 retry and 1 dead letter, got %v", stats)
+	}
+
+	dlqRequest := func(method, target string) (*httptest.ResponseRecorder, map[string]interface{}) {
+		rec := httptest.NewRecorder()
+		routes.ServeHTTP(rec, httptest.NewRequest(method, target, http.NoBody))
+		var body map[string]interface{}
+		_ = json.Unmarshal(rec.Body.Bytes(), &body)
+		return rec, body
+	}
+	if _, body := dlqRequest(http.MethodGet, "/queue/dlq"); body["count"] != float64(1) {
+		t.Fatalf("Expected 1 dead letter, got %v", body)
+	}
+
+	if rec, _ := dlqRequest(http.MethodPost, "/queue/dlq?action=replay&id=missing"); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 replaying an unknown id, got %d", rec.Code)
+	}
+
+	// Once the upstream recovers, replaying sends the request again
+	atomic.StoreInt32(&healthy, 1)
+	rec, body := dlqRequest(http.MethodPost, "/queue/dlq?action=replay")
+	if rec.Code != http.StatusOK || body["replayed"] != float64(1) {
+		t.Fatalf("Expected 1 replayed request, got %d: %v", rec.Code, body)
+	}
+	select {
+	case prompt := <-served:
+		if prompt != "during outage" {
+			t.Errorf("Expected the dead-lettered request upstream, got %q", prompt)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Replayed request was not sent upstream")
+	}
+	if _, body := dlqRequest(http.MethodGet, "/queue/dlq"); body["count"] != float64(0) {
+		t.Errorf("Expected the dead-letter queue to be empty after replay, got %v", body)
+	}
+}
+
+func TestHandleDLQ_QueueDisabled(t *testing.T) {
+	handler := newTestHandler(t, nil, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	rec := httptest.NewRecorder()
+	handler.HandleDLQ(rec, httptest.NewRequest(http.MethodGet, "/queue/dlq", http.NoBody))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 without a queue, got %d", rec.Code)
+	}
+}
+
+func TestHandleDLQ_RequiresAuth(t *testing.T) {
+	handler := newTestHandler(t, nil, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	queue, dlq, _ := newDLQQueue(t, 0)
+	handler.SetQueue(queue)
+
+	secret := `{"messages":[{"role":"user","content":"confidential prompt"}]}`
+	if _, err := queue.Enqueue([]byte(secret)); err != nil {
+		t.Fatalf("Failed to enqueue: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	req, err := queue.Dequeue(ctx)
+	if err != nil {
+		t.Fatalf("Failed to dequeue: %v", err)
+	}
+	queue.Retry(req, errors.New("connection refused"))
+
+	for _, method := range []string{http.MethodGet, http.MethodPost} {
+		rec := httptest.NewRecorder()
+		handler.HandleDLQ(rec, httptest.NewRequest(method, "/queue/dlq?action=replay", http.NoBody))
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("%s: expected 403 with authentication disabled, got %d", method, rec.Code)
+		}
+		if strings.Contains(rec.Body.String(), "confidential prompt") {
+			t.Errorf("%s: dead-lettered request leaked without authentication: %s", method, rec.Body.String())
+		}
+	}
+	if letters, _ := dlq.List(); len(letters) != 1 {
+		t.Errorf("Expected the dead letter to be kept, got %d", len(letters))
+	}
+}
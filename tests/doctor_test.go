@@ -0,0 +1,100 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jedarden/clasp/internal/setup"
+)
+
+// newFakeProvider starts an OpenAI-compatible server that lists models for the given key.
+func newFakeProvider(t *testing.T, validKey string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/models" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer "+validKey {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error":{"message":"Incorrect API key provided"}}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"data":[{"id":"gpt-4o"},{"id":"gpt-4o-mini"}]}`))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestDoctor_ProviderReachable(t *testing.T) {
+	server := newFakeProvider(t, "good-key")
+
+	result := setup.NewDoctor(false).CheckProviderReachability(&setup.ConfigFile{
+		Provider: "custom",
+		APIKey:   "good-key",
+		BaseURL:  server.URL,
+	})
+
+	if result.Status != "ok" {
+		t.Fatalf("Expected ok, got %s: %s", result.Status, result.Message)
+	}
+	if !strings.Contains(result.Message, "2 model(s)") {
+		t.Errorf("Expected model count in message, got %q", result.Message)
+	}
+}
+
+func TestDoctor_ProviderBadKey(t *testing.T) {
+	server := newFakeProvider(t, "good-key")
+
+	result := setup.NewDoctor(false).CheckProviderReachability(&setup.ConfigFile{
+		Provider: "custom",
+		APIKey:   "bad-key",
+		BaseURL:  server.URL,
+	})
+
+	if result.Status != "error" {
+		t.Fatalf("Expected error, got %s: %s", result.Status, result.Message)
+	}
+	if !strings.Contains(result.Message, "API key was rejected") {
+		t.Errorf("Expected rejected key message, got %q", result.Message)
+	}
+	if result.Fix == "" {
+		t.Error("Expected a remediation hint")
+	}
+}
+
+func TestDoctor_ProviderWrongBaseURL(t *testing.T) {
+	server := newFakeProvider(t, "good-key")
+
+	result := setup.NewDoctor(false).CheckProviderReachability(&setup.ConfigFile{
+		Provider: "custom",
+		APIKey:   "good-key",
+		BaseURL:  server.URL + "/v2",
+	})
+
+	if result.Status != "error" || !strings.Contains(result.Message, "404") {
+		t.Errorf("Expected 404 error, got %s: %s", result.Status, result.Message)
+	}
+}
+
+func TestDoctor_ProviderUnreachable(t *testing.T) {
+	server := newFakeProvider(t, "good-key")
+	baseURL := server.URL
+	server.Close()
+
+	result := setup.NewDoctor(false).CheckProviderReachability(&setup.ConfigFile{
+		Provider: "custom",
+		APIKey:   "good-key",
+		BaseURL:  baseURL,
+	})
+
+	if result.Status != "error" {
+		t.Fatalf("Expected error, got %s: %s", result.Status, result.Message)
+	}
+	if !strings.Contains(result.Message, "Cannot reach provider") {
+		t.Errorf("Expected unreachable message, got %q", result.Message)
+	}
+}
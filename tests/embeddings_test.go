@@ -0,0 +1,134 @@
+package tests
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jedarden/clasp/internal/config"
+	"github.com/jedarden/clasp/internal/proxy"
+)
+
+const embeddingsResponse = `{"object":"list","data":[{"object":"embedding","index":0,"embedding":[0.1,-0.2,0.3]}],"model":"text-embedding-3-small","usage":{"prompt_tokens":500000,"total_tokens":500000}}`
+
+// postEmbeddings sends an embeddings request to the handler and returns the recorder.
+func postEmbeddings(t *testing.T, handler *proxy.Handler, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/v1/embeddings", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	handler.HandleEmbeddings(rec, req)
+	return rec
+}
+
+func TestEmbeddings_Passthrough(t *testing.T) {
+	var gotPath string
+	var gotBody map[string]interface{}
+	handler := newTestHandler(t, func(cfg *config.Config) {
+		cfg.ModelAliases = map[string]string{"embed": "text-embedding-3-small"}
+	}, func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		data, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(data, &gotBody)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(embeddingsResponse))
+	})
+
+	rec := postEmbeddings(t, handler, `{"model":"embed","input":["hello"],"dimensions":256}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec.Body.String() != embeddingsResponse {
+		t.Errorf("Expected the upstream response unchanged, got %s", rec.Body.String())
+	}
+
+	if gotPath != "/embeddings" {
+		t.Errorf("Upstream path = %q, want /embeddings", gotPath)
+	}
+	// The alias is resolved, the default chat model is not applied, and other fields pass through
+	if gotBody["model"] != "text-embedding-3-small" {
+		t.Errorf("Upstream model = %v, want text-embedding-3-small", gotBody["model"])
+	}
+	if gotBody["dimensions"] != float64(256) {
+		t.Errorf("Expected dimensions to pass through, got %v", gotBody)
+	}
+
+	// Usage is recorded as embeddings, separately from messages
+	summary := handler.GetCostTracker().GetSummary()
+	if summary.TotalRequests != 0 || summary.TotalInputTokens != 0 {
+		t.Errorf("Expected no message usage, got %d requests, %d tokens", summary.TotalRequests, summary.TotalInputTokens)
+	}
+	embeddings := summary.Embeddings
+	if embeddings.Requests != 1 || embeddings.InputTokens != 500000 {
+		t.Errorf("Expected 1 request with 500000 tokens, got %+v", embeddings)
+	}
+	// text-embedding-3-small is $0.02 per 1M tokens
+	if embeddings.TotalCostUSD < 0.0099 || embeddings.TotalCostUSD > 0.0101 {
+		t.Errorf("Expected embeddings cost $0.01, got $%f", embeddings.TotalCostUSD)
+	}
+	if _, ok := embeddings.ByModel["text-embedding-3-small"]; !ok {
+		t.Errorf("Expected a by_model entry for text-embedding-3-small, got %v", embeddings.ByModel)
+	}
+	if summary.TotalCostUSD != embeddings.TotalCostUSD || handler.GetCostTracker().GetTotalCostUSD() != embeddings.TotalCostUSD {
+		t.Errorf("Expected total cost to include embeddings, got $%f", summary.TotalCostUSD)
+	}
+}
+
+func TestEmbeddings_UpstreamError(t *testing.T) {
+	handler := newTestHandler(t, nil, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"error":{"message":"unknown model","type":"invalid_request_error"}}`))
+	})
+
+	rec := postEmbeddings(t, handler, `{"model":"nope","input":"hello"}`)
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "unknown model") {
+		t.Errorf("Expected the upstream 400 to pass through, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if got := handler.GetCostTracker().GetSummary().Embeddings.Requests; got != 0 {
+		t.Errorf("Expected no usage recorded for a failed request, got %d", got)
+	}
+}
+
+func TestEmbeddings_Unsupported(t *testing.T) {
+	called := false
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	t.Cleanup(upstream.Close)
+
+	cfg := config.DefaultConfig()
+	cfg.Provider = config.ProviderDeepSeek
+	cfg.DeepSeekAPIKey = "test-key"
+	cfg.DeepSeekBaseURL = upstream.URL
+	handler, err := proxy.NewHandler(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create handler: %v", err)
+	}
+
+	rec := postEmbeddings(t, handler, `{"model":"text-embedding-3-small","input":"hello"}`)
+	if rec.Code != http.StatusNotImplemented {
+		t.Fatalf("Expected status 501, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if !strings.Contains(rec.Body.String(), "does not support embeddings") {
+		t.Errorf("Expected a clear error message, got %s", rec.Body.String())
+	}
+	if called {
+		t.Error("Expected no upstream request")
+	}
+}
+
+func TestEmbeddings_InvalidRequest(t *testing.T) {
+	handler := newTestHandler(t, nil, func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Unexpected upstream request")
+	})
+
+	for _, body := range []string{`{"input":"hello"}`, `{"model":"text-embedding-3-small"}`, `not json`} {
+		if rec := postEmbeddings(t, handler, body); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", body, rec.Code)
+		}
+	}
+}
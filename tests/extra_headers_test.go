@@ -0,0 +1,90 @@
+package tests
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/jedarden/clasp/internal/config"
+)
+
+// headerRecorder returns an upstream that answers with chatCompletionOK and
+// the headers of the last request it received.
+func headerRecorder() (http.HandlerFunc, func() http.Header) {
+	var mu sync.Mutex
+	var last http.Header
+	upstream := func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		last = r.Header.Clone()
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(chatCompletionOK))
+	}
+	return upstream, func() http.Header {
+		mu.Lock()
+		defer mu.Unlock()
+		return last
+	}
+}
+
+func TestExtraHeaders_AppliedToUpstream(t *testing.T) {
+	upstream, lastHeaders := headerRecorder()
+	handler := newTestHandler(t, func(cfg *config.Config) {
+		cfg.ExtraHeaders = map[string]string{
+			"X-Gateway-Team": "platform",
+			"Authorization":  "Bearer should-not-win",
+		}
+	}, upstream)
+
+	if rec := postMessages(t, handler, simpleRequest()); rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	headers := lastHeaders()
+	if got := headers.Get("X-Gateway-Team"); got != "platform" {
+		t.Errorf("X-Gateway-Team = %q, want platform", got)
+	}
+	if got := headers.Get("Authorization"); got != "Bearer test-key" {
+		t.Errorf("Authorization = %q, extra headers must not replace the provider key", got)
+	}
+}
+
+func TestExtraHeaders_TierOverridesGlobal(t *testing.T) {
+	upstream, lastHeaders := headerRecorder()
+	handler := newTestHandler(t, func(cfg *config.Config) {
+		cfg.ExtraHeaders = map[string]string{"X-Title": "Global", "X-Gateway-Team": "platform"}
+		cfg.MultiProviderEnabled = true
+		cfg.TierOpus = &config.TierConfig{
+			Provider:     config.ProviderCustom,
+			BaseURL:      cfg.CustomBaseURL,
+			APIKey:       "tier-key",
+			Model:        "gpt-4o",
+			ExtraHeaders: map[string]string{"X-Title": "Opus"},
+		}
+	}, upstream)
+
+	opus := simpleRequest()
+	opus.Model = "claude-3-opus-20240229"
+	if rec := postMessages(t, handler, opus); rec.Code != http.StatusOK {
+		t.Fatalf("Opus: expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	headers := lastHeaders()
+	if got := headers.Get("X-Title"); got != "Opus" {
+		t.Errorf("Opus X-Title = %q, want the tier override", got)
+	}
+	if got := headers.Get("X-Gateway-Team"); got != "platform" {
+		t.Errorf("Opus X-Gateway-Team = %q, want the global header", got)
+	}
+	if got := headers.Get("Authorization"); got != "Bearer tier-key" {
+		t.Errorf("Opus Authorization = %q, want the tier key", got)
+	}
+
+	// Other tiers only get the global headers
+	haiku := simpleRequest()
+	haiku.Model = "claude-3-haiku-20240307"
+	if rec := postMessages(t, handler, haiku); rec.Code != http.StatusOK {
+		t.Fatalf("Haiku: expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if got := lastHeaders().Get("X-Title"); got != "Global" {
+		t.Errorf("Haiku X-Title = %q, want the global header", got)
+	}
+}
@@ -0,0 +1,92 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/jedarden/clasp/internal/config"
+	"github.com/jedarden/clasp/pkg/models"
+)
+
+// failingUpstream returns a server that always answers 529, which is not
+// retried, and counts its requests.
+func failingUpstream(t *testing.T, hits *atomic.Int64) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(529)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestFallbackChain_ThirdHopSucceeds(t *testing.T) {
+	var primaryHits, firstHits, secondHits atomic.Int64
+	var secondModel string
+
+	first := failingUpstream(t, &firstHits)
+	second := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		secondHits.Add(1)
+		var req models.OpenAIRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		secondModel = req.Model
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(chatCompletionOK))
+	}))
+	t.Cleanup(second.Close)
+
+	handler := newTestHandler(t, func(cfg *config.Config) {
+		// Hop 1 is the global fallback, hop 2 comes from the chain
+		cfg.FallbackEnabled = true
+		cfg.FallbackProvider = config.ProviderOpenAI
+		cfg.FallbackBaseURL = first.URL
+		cfg.FallbackAPIKey = "fallback-key"
+		cfg.OpenRouterAPIKey = "openrouter-key"
+		cfg.OpenRouterBaseURL = second.URL
+		cfg.FallbackChain = []config.TierConfig{{Provider: config.ProviderOpenRouter, Model: "meta-llama/llama-3.1-70b"}}
+	}, func(w http.ResponseWriter, r *http.Request) {
+		primaryHits.Add(1)
+		w.WriteHeader(529)
+	})
+
+	rec := postMessages(t, handler, simpleRequest())
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if got := rec.Header().Get("X-CLASP-Fallback"); got != "fallback:2" {
+		t.Errorf("Expected X-CLASP-Fallback fallback:2, got %q", got)
+	}
+	if primaryHits.Load() != 1 || firstHits.Load() != 1 || secondHits.Load() != 1 {
+		t.Errorf("Expected one request per hop, got primary=%d first=%d second=%d", primaryHits.Load(), firstHits.Load(), secondHits.Load())
+	}
+	if secondModel != "meta-llama/llama-3.1-70b" {
+		t.Errorf("Expected the chain hop's model, got %q", secondModel)
+	}
+}
+
+func TestFallbackChain_Exhausted(t *testing.T) {
+	var primaryHits, hopHits atomic.Int64
+	hop := failingUpstream(t, &hopHits)
+	handler := newTestHandler(t, func(cfg *config.Config) {
+		cfg.OpenAIAPIKey = "openai-key"
+		cfg.OpenAIBaseURL = hop.URL
+		cfg.FallbackChain = []config.TierConfig{{Provider: config.ProviderOpenAI, Model: "gpt-4o-mini"}, {Provider: config.ProviderOpenAI}}
+	}, func(w http.ResponseWriter, r *http.Request) {
+		primaryHits.Add(1)
+		w.WriteHeader(529)
+	})
+
+	rec := postMessages(t, handler, simpleRequest())
+	if rec.Code != 529 {
+		t.Errorf("Expected the last hop's 529, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec.Header().Get("X-CLASP-Fallback") != "" {
+		t.Errorf("Expected no fallback header, got %q", rec.Header().Get("X-CLASP-Fallback"))
+	}
+	if hopHits.Load() != 2 {
+		t.Errorf("Expected both chain hops to be tried, got %d requests", hopHits.Load())
+	}
+}
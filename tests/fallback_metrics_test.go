@@ -0,0 +1,118 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/jedarden/clasp/internal/config"
+	"github.com/jedarden/clasp/pkg/models"
+)
+
+func TestFallbackMetrics_ByTierAndProvider(t *testing.T) {
+	var opusHits atomic.Int64
+	opusPrimary := failingUpstream(t, &opusHits)
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(chatCompletionOK))
+	}))
+	t.Cleanup(healthy.Close)
+
+	handler := newTestHandler(t, func(cfg *config.Config) {
+		// Opus requests go to OpenRouter and fall back to the tier's fallback;
+		// other requests go to the default provider and use the global fallback
+		cfg.MultiProviderEnabled = true
+		cfg.TierOpus = &config.TierConfig{
+			Provider:         config.ProviderOpenRouter,
+			BaseURL:          opusPrimary.URL,
+			APIKey:           "openrouter-key",
+			Model:            "anthropic/claude-3-opus",
+			FallbackProvider: config.ProviderCustom,
+			FallbackBaseURL:  healthy.URL,
+			FallbackAPIKey:   "fallback-key",
+			FallbackModel:    "gpt-4o",
+		}
+		cfg.FallbackEnabled = true
+		cfg.FallbackProvider = config.ProviderOpenAI
+		cfg.FallbackBaseURL = healthy.URL
+		cfg.FallbackAPIKey = "fallback-key"
+	}, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(529)
+	})
+
+	opusReq := simpleRequest()
+	opusReq.Model = "claude-3-opus-20240229"
+	for _, req := range []*models.AnthropicRequest{opusReq, opusReq, simpleRequest()} {
+		rec := postMessages(t, handler, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: expected status 200, got %d: %s", req.Model, rec.Code, rec.Body.String())
+		}
+		if got := rec.Header().Get("X-CLASP-Fallback"); got != "fallback:1" {
+			t.Errorf("%s: expected X-CLASP-Fallback fallback:1, got %q", req.Model, got)
+		}
+	}
+	if opusHits.Load() != 2 {
+		t.Errorf("Expected 2 requests to the Opus tier provider, got %d", opusHits.Load())
+	}
+
+	fallback, _ := getMetrics(t, handler)["fallback"].(map[string]interface{})
+	byTier, _ := fallback["by_tier"].(map[string]interface{})
+	for _, tc := range []struct {
+		tier, provider string
+		want           float64
+	}{
+		{"opus", "openrouter", 2},
+		{"default", "custom", 1},
+	} {
+		providers, _ := byTier[tc.tier].(map[string]interface{})
+		counts, _ := providers[tc.provider].(map[string]interface{})
+		if counts["attempts"] != tc.want || counts["successes"] != tc.want {
+			t.Errorf("by_tier[%s][%s] = %v, want %v attempts and successes", tc.tier, tc.provider, counts, tc.want)
+		}
+	}
+	if len(byTier) != 2 {
+		t.Errorf("Expected fallbacks from 2 tiers, got %v", byTier)
+	}
+
+	rec := httptest.NewRecorder()
+	handler.HandleMetricsPrometheus(rec, httptest.NewRequest(http.MethodGet, "/metrics/prometheus", http.NoBody))
+	body := rec.Body.String()
+	for _, want := range []string{
+		`clasp_fallback_attempts{provider="openrouter",tier="opus"} 2`,
+		`clasp_fallback_successes{provider="openrouter",tier="opus"} 2`,
+		`clasp_fallback_attempts{provider="custom",tier="default"} 1`,
+		`clasp_fallback_successes{provider="custom",tier="default"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected %s in Prometheus output", want)
+		}
+	}
+}
+
+func TestFallbackMetrics_FailedHopsCountAsAttempts(t *testing.T) {
+	var fallbackHits atomic.Int64
+	fallback := failingUpstream(t, &fallbackHits)
+	handler := newTestHandler(t, func(cfg *config.Config) {
+		cfg.FallbackEnabled = true
+		cfg.FallbackProvider = config.ProviderOpenAI
+		cfg.FallbackBaseURL = fallback.URL
+		cfg.FallbackAPIKey = "fallback-key"
+	}, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(529)
+	})
+	postMessages(t, handler, simpleRequest())
+
+	rec := httptest.NewRecorder()
+	handler.HandleMetricsPrometheus(rec, httptest.NewRequest(http.MethodGet, "/metrics/prometheus", http.NoBody))
+	body := rec.Body.String()
+	for _, want := range []string{
+		`clasp_fallback_attempts{provider="custom",tier="default"} 1`,
+		`clasp_fallback_successes{provider="custom",tier="default"} 0`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected %s in Prometheus output", want)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/jedarden/clasp/internal/config"
+	"github.com/jedarden/clasp/internal/proxy"
+	"github.com/jedarden/clasp/pkg/models"
+)
+
+// newGeminiHandler creates a handler for the Gemini provider pointed at a mock
+// of the native generateContent API.
+func newGeminiHandler(t *testing.T, upstream http.HandlerFunc) *proxy.Handler {
+	t.Helper()
+	cfg, server := newMockUpstream(t, upstream)
+	cfg.Provider = config.ProviderGemini
+	cfg.GeminiBaseURL = server.URL
+	cfg.GeminiAPIKey = "test-gemini-key"
+	cfg.DefaultModel = "gemini-2.5-flash"
+	handler, err := proxy.NewHandler(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create handler: %v", err)
+	}
+	return handler
+}
+
+func TestGeminiNative_ToolCall(t *testing.T) {
+	var upstreamReq models.GeminiRequest
+	handler := newGeminiHandler(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/models/gemini-2.5-flash:generateContent" {
+			t.Errorf("Expected the native generateContent endpoint, got %s", r.URL.Path)
+		}
+		if got := r.Header.Get("x-goog-api-key"); got != "test-gemini-key" {
+			t.Errorf("Expected the API key in x-goog-api-key, got %q", got)
+		}
+		if r.URL.Query().Get("key") != "" {
+			t.Error("The API key must not be sent in the URL")
+		}
+		_ = json.NewDecoder(r.Body).Decode(&upstreamReq)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"candidates":[{"content":{"role":"model","parts":[{"functionCall":{"name":"get_weather","args":{"city":"Paris"}}}]},"finishReason":"STOP"}],"usageMetadata":{"promptTokenCount":30,"candidatesTokenCount":7,"totalTokenCount":37}}`))
+	})
+
+	req := simpleRequest()
+	req.Tools = []models.AnthropicTool{{
+		Name:        "get_weather",
+		InputSchema: map[string]interface{}{"type": "object", "properties": map[string]interface{}{"city": map[string]interface{}{"type": "string"}}},
+	}}
+	rec := postMessages(t, handler, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	if len(upstreamReq.Tools) != 1 || upstreamReq.Tools[0].FunctionDeclarations[0].Name != "get_weather" {
+		t.Errorf("Expected get_weather as a function declaration, got %+v", upstreamReq.Tools)
+	}
+
+	var resp models.AnthropicResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.StopReason != "tool_use" {
+		t.Errorf("Expected stop_reason tool_use, got %q", resp.StopReason)
+	}
+	if len(resp.Content) != 1 || resp.Content[0].Type != "tool_use" || resp.Content[0].Name != "get_weather" {
+		t.Fatalf("Expected a get_weather tool_use block, got %+v", resp.Content)
+	}
+	if resp.Usage.InputTokens != 30 || resp.Usage.OutputTokens != 7 {
+		t.Errorf("Expected usage 30/7, got %+v", resp.Usage)
+	}
+
+	summary := handler.GetCostTracker().GetSummary()
+	if summary.TotalInputTokens != 30 || summary.TotalOutputTokens != 7 {
+		t.Errorf("Expected 30/7 tokens tracked, got %d/%d", summary.TotalInputTokens, summary.TotalOutputTokens)
+	}
+}
+
+func TestGeminiNative_Streaming(t *testing.T) {
+	handler := newGeminiHandler(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/models/gemini-2.5-flash:streamGenerateContent" || r.URL.Query().Get("alt") != "sse" {
+			t.Errorf("Expected the SSE streamGenerateContent endpoint, got %s", r.URL.String())
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte("data: {\"candidates\":[{\"content\":{\"role\":\"model\",\"parts\":[{\"text\":\"Hello\"}]}}]}\n\n" +
+			"data: {\"candidates\":[{\"content\":{\"role\":\"model\",\"parts\":[{\"text\":\" there\"}]},\"finishReason\":\"MAX_TOKENS\"}],\"usageMetadata\":{\"promptTokenCount\":12,\"candidatesTokenCount\":2}}\n\n"))
+	})
+
+	rec := postMessages(t, handler, streamingRequest())
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var text strings.Builder
+	var stopReason string
+	for _, event := range parseSSEEvents(t, rec.Body.String()) {
+		delta, _ := event.Data["delta"].(map[string]interface{})
+		switch event.Type {
+		case models.EventContentBlockDelta:
+			text.WriteString(delta["text"].(string))
+		case models.EventMessageDelta:
+			stopReason, _ = delta["stop_reason"].(string)
+		}
+	}
+	if text.String() != "Hello there" {
+		t.Errorf("Expected streamed text %q, got %q", "Hello there", text.String())
+	}
+	if stopReason != "max_tokens" {
+		t.Errorf("Expected stop_reason max_tokens, got %q", stopReason)
+	}
+}
+
+func TestGeminiNative_UpstreamError(t *testing.T) {
+	handler := newGeminiHandler(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"error":{"code":400,"message":"Invalid argument","status":"INVALID_ARGUMENT"}}`))
+	})
+
+	rec := postMessages(t, handler, simpleRequest())
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected the upstream 400 to be returned, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,107 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jedarden/clasp/internal/config"
+	"github.com/jedarden/clasp/internal/proxy"
+	"github.com/jedarden/clasp/pkg/models"
+)
+
+// newHookedHandler creates a handler with the given hooks.json content and
+// returns it with the last Chat Completions request the upstream received.
+// configure, if set, adjusts the config before the handler is created.
+func newHookedHandler(t *testing.T, hooks string, configure func(*config.Config)) (*proxy.Handler, *models.OpenAIRequest) {
+	t.Helper()
+	var upstreamReq models.OpenAIRequest
+	hooksFile := filepath.Join(t.TempDir(), "hooks.json")
+	if err := os.WriteFile(hooksFile, []byte(hooks), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	handler := newTestHandler(t, func(cfg *config.Config) {
+		cfg.HooksFile = hooksFile
+		if configure != nil {
+			configure(cfg)
+		}
+	}, func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&upstreamReq)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"chatcmpl-1","choices":[{"message":{"role":"assistant","content":"Hi"},"finish_reason":"stop"}],"usage":{"prompt_tokens":10,"completion_tokens":2}}`))
+	})
+	return handler, &upstreamReq
+}
+
+func TestHooks_PrependSystem(t *testing.T) {
+	handler, upstreamReq := newHookedHandler(t, `{"hooks":[{"type":"prepend-system","text":"Always answer in French."}]}`, nil)
+
+	req := simpleRequest()
+	req.System = "You are a helpful assistant."
+	if rec := postMessages(t, handler, req); rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	if len(upstreamReq.Messages) == 0 || upstreamReq.Messages[0].Role != "system" {
+		t.Fatalf("Expected a system message, got %+v", upstreamReq.Messages)
+	}
+	system, _ := upstreamReq.Messages[0].Content.(string)
+	if !strings.Contains(system, "Always answer in French.\n\nYou are a helpful assistant.") {
+		t.Errorf("Expected the hook text before the system prompt, got %q", system)
+	}
+}
+
+func TestHooks_DropTool(t *testing.T) {
+	handler, upstreamReq := newHookedHandler(t, `{"hooks":[{"type":"drop-tool","tools":["Bash"]}]}`, nil)
+
+	req := simpleRequest()
+	req.Tools = []models.AnthropicTool{
+		{Name: "Bash", InputSchema: map[string]interface{}{"type": "object"}},
+		{Name: "Read", InputSchema: map[string]interface{}{"type": "object"}},
+	}
+	req.ToolChoice = map[string]interface{}{"type": "tool", "name": "Bash"}
+	if rec := postMessages(t, handler, req); rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	if len(upstreamReq.Tools) != 1 || upstreamReq.Tools[0].Function.Name != "Read" {
+		t.Errorf("Expected only the Read tool, got %+v", upstreamReq.Tools)
+	}
+	if upstreamReq.ToolChoice != nil {
+		t.Errorf("Expected the tool_choice forcing the dropped tool to be cleared, got %v", upstreamReq.ToolChoice)
+	}
+}
+
+func TestHooks_RenameModelChain(t *testing.T) {
+	handler, upstreamReq := newHookedHandler(t, `{"hooks":[
+  {"type":"rename-model","from":"claude-3-5-sonnet-20241022","to":"claude-3-5-haiku-20241022"},
+  {"type":"prepend-system","text":"Be brief."}
+]}`, func(cfg *config.Config) {
+		cfg.ModelHaiku = "gpt-4o-mini"
+	})
+
+	if rec := postMessages(t, handler, simpleRequest()); rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	if upstreamReq.Model != "gpt-4o-mini" {
+		t.Errorf("Expected the renamed haiku model to map to gpt-4o-mini, got %q", upstreamReq.Model)
+	}
+	if len(upstreamReq.Messages) == 0 || upstreamReq.Messages[0].Role != "system" {
+		t.Errorf("Expected the second hook to add a system message, got %+v", upstreamReq.Messages)
+	}
+}
+
+func TestHooks_InvalidConfig(t *testing.T) {
+	cfg, _ := newMockUpstream(t, func(w http.ResponseWriter, r *http.Request) {})
+	cfg.HooksFile = filepath.Join(t.TempDir(), "hooks.json")
+	if err := os.WriteFile(cfg.HooksFile, []byte(`{"hooks":[{"type":"uppercase-everything"}]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := proxy.NewHandler(cfg); err == nil {
+		t.Error("Expected an error for an unknown hook type")
+	}
+}
@@ -0,0 +1,171 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jedarden/clasp/internal/proxy"
+)
+
+func newIPFilterHandler(t *testing.T, config *proxy.IPFilterConfig) http.Handler {
+	t.Helper()
+	filter, err := proxy.NewIPFilter(config)
+	if err != nil {
+		t.Fatalf("NewIPFilter failed: %v", err)
+	}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	return proxy.IPFilterMiddleware(filter)(handler)
+}
+
+func serveFrom(handler http.Handler, path, remoteAddr string, headers map[string]string) *httptest.ResponseRecorder {
+	return serveMethodFrom(handler, http.MethodPost, path, remoteAddr, headers)
+}
+
+func serveMethodFrom(handler http.Handler, method, path, remoteAddr string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, http.NoBody)
+	req.RemoteAddr = remoteAddr
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestIPFilterMiddleware_Allowlist(t *testing.T) {
+	handler := newIPFilterHandler(t, &proxy.IPFilterConfig{
+		Allowlist: []string{"10.0.0.0/8", "192.168.1.5/32", "fd00::/8"},
+	})
+
+	tests := []struct {
+		remoteAddr string
+		want       int
+	}{
+		{"10.1.2.3:5000", http.StatusOK},
+		{"10.255.255.255:5000", http.StatusOK},
+		{"192.168.1.5:5000", http.StatusOK},
+		{"192.168.1.6:5000", http.StatusForbidden},
+		{"[fd00::1]:5000", http.StatusOK},
+		{"[2001:db8::1]:5000", http.StatusForbidden},
+		{"11.0.0.1:5000", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.remoteAddr, func(t *testing.T) {
+			rec := serveFrom(handler, "/v1/messages", tt.remoteAddr, nil)
+			if rec.Code != tt.want {
+				t.Errorf("Expected status %d, got %d", tt.want, rec.Code)
+			}
+		})
+	}
+}
+
+func TestIPFilterMiddleware_Denylist(t *testing.T) {
+	handler := newIPFilterHandler(t, &proxy.IPFilterConfig{
+		Allowlist: []string{"10.0.0.0/8"},
+		Denylist:  []string{"10.1.0.0/16"},
+	})
+
+	if rec := serveFrom(handler, "/v1/messages", "10.2.0.1:5000", nil); rec.Code != http.StatusOK {
+		t.Errorf("Expected status 200 for allowed IP, got %d", rec.Code)
+	}
+
+	// Denylist takes precedence over the allowlist
+	rec := serveFrom(handler, "/v1/messages", "10.1.0.1:5000", nil)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("Expected status 403 for denied IP, got %d", rec.Code)
+	}
+
+	var resp map[string]interface{}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp["type"] != "error" {
+		t.Errorf("Expected type 'error', got %v", resp["type"])
+	}
+	errObj := resp["error"].(map[string]interface{})
+	if errObj["type"] != "permission_error" {
+		t.Errorf("Expected error type 'permission_error', got %v", errObj["type"])
+	}
+}
+
+func TestIPFilterMiddleware_ForwardedFor(t *testing.T) {
+	config := &proxy.IPFilterConfig{Allowlist: []string{"203.0.113.0/24"}}
+	headers := map[string]string{"X-Forwarded-For": "203.0.113.7"}
+
+	// Untrusted: the header is ignored and the proxy's address is checked
+	handler := newIPFilterHandler(t, config)
+	if rec := serveFrom(handler, "/v1/messages", "10.0.0.1:5000", headers); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 with untrusted X-Forwarded-For, got %d", rec.Code)
+	}
+
+	// Trusted: the client address added by the reverse proxy is checked
+	config.TrustProxyHeaders = true
+	handler = newIPFilterHandler(t, config)
+	if rec := serveFrom(handler, "/v1/messages", "10.0.0.1:5000", headers); rec.Code != http.StatusOK {
+		t.Errorf("Expected status 200 with trusted X-Forwarded-For, got %d", rec.Code)
+	}
+	if rec := serveFrom(handler, "/v1/messages", "203.0.113.7:5000", map[string]string{"X-Forwarded-For": "198.51.100.1"}); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 for forwarded client outside allowlist, got %d", rec.Code)
+	}
+
+	// Entries before the last one come from the client and are ignored
+	if rec := serveFrom(handler, "/v1/messages", "10.0.0.1:5000", map[string]string{"X-Forwarded-For": "203.0.113.7, 198.51.100.1"}); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 with a spoofed leftmost X-Forwarded-For entry, got %d", rec.Code)
+	}
+	if rec := serveFrom(handler, "/v1/messages", "10.0.0.1:5000", map[string]string{"X-Forwarded-For": "unknown, 203.0.113.9"}); rec.Code != http.StatusOK {
+		t.Errorf("Expected status 200 for an allowed last entry, got %d", rec.Code)
+	}
+
+	// X-Real-IP is used when X-Forwarded-For is absent
+	if rec := serveFrom(handler, "/v1/messages", "10.0.0.1:5000", map[string]string{"X-Real-IP": "203.0.113.8"}); rec.Code != http.StatusOK {
+		t.Errorf("Expected status 200 with trusted X-Real-IP, got %d", rec.Code)
+	}
+}
+
+func TestIPFilterMiddleware_AnonymousEndpoints(t *testing.T) {
+	config := &proxy.IPFilterConfig{
+		Allowlist:             []string{"10.0.0.0/8"},
+		AllowAnonymousHealth:  true,
+		AllowAnonymousMetrics: false,
+	}
+	handler := newIPFilterHandler(t, config)
+
+	if rec := serveMethodFrom(handler, http.MethodGet, "/health", "192.0.2.1:5000", nil); rec.Code != http.StatusOK {
+		t.Errorf("Expected status 200 for anonymous health, got %d", rec.Code)
+	}
+	if rec := serveMethodFrom(handler, http.MethodGet, "/metrics", "192.0.2.1:5000", nil); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 for metrics, got %d", rec.Code)
+	}
+	if rec := serveMethodFrom(handler, http.MethodGet, "/", "192.0.2.1:5000", nil); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 for / without anonymous endpoints, got %d", rec.Code)
+	}
+}
+
+func TestIPFilterMiddleware_AnonymousEndpointsReadOnly(t *testing.T) {
+	handler := newIPFilterHandler(t, &proxy.IPFilterConfig{
+		Allowlist:             []string{"10.0.0.0/8"},
+		AllowAnonymousMetrics: true,
+		AnonymousEndpoints:    []string{"/v1/messages"},
+	})
+
+	for _, path := range []string{"/metrics", "/v1/messages"} {
+		if rec := serveMethodFrom(handler, http.MethodGet, path, "192.0.2.1:5000", nil); rec.Code != http.StatusOK {
+			t.Errorf("GET %s: expected status 200 for an anonymous endpoint, got %d", path, rec.Code)
+		}
+	}
+	for _, path := range []string{"/metrics?action=reset", "/v1/messages"} {
+		if rec := serveMethodFrom(handler, http.MethodPost, path, "192.0.2.1:5000", nil); rec.Code != http.StatusForbidden {
+			t.Errorf("POST %s: expected status 403 from a blocked IP, got %d", path, rec.Code)
+		}
+	}
+}
+
+func TestNewIPFilter_InvalidCIDR(t *testing.T) {
+	if _, err := proxy.NewIPFilter(&proxy.IPFilterConfig{Denylist: []string{"not-a-cidr"}}); err == nil {
+		t.Error("Expected error for invalid CIDR")
+	}
+}
@@ -0,0 +1,139 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jedarden/clasp/internal/config"
+	"github.com/jedarden/clasp/internal/proxy"
+)
+
+// getMetrics returns the decoded /metrics response.
+func getMetrics(t *testing.T, handler *proxy.Handler) map[string]interface{} {
+	t.Helper()
+	_, body := getProbe(t, handler.HandleMetrics, "/metrics")
+	return body
+}
+
+// postMetricsReset sends POST /metrics with the given query.
+func postMetricsReset(t *testing.T, handler *proxy.Handler, query string) (*httptest.ResponseRecorder, map[string]interface{}) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	handler.HandleMetrics(rec, httptest.NewRequest(http.MethodPost, "/metrics?"+query, http.NoBody))
+	var body map[string]interface{}
+	_ = json.Unmarshal(rec.Body.Bytes(), &body)
+	return rec, body
+}
+
+func TestMetrics_Reset(t *testing.T) {
+	handler := newTestHandler(t, func(cfg *config.Config) {
+		cfg.AuthEnabled = true
+		cfg.AuthAPIKey = "proxy-key"
+	}, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"chatcmpl-1","choices":[{"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}],"usage":{"prompt_tokens":10,"completion_tokens":5}}`))
+	})
+	limiter := proxy.NewRateLimiter(60, 60, 10)
+	handler.SetRateLimiter(limiter)
+	limiter.Allow()
+
+	for i := 0; i < 3; i++ {
+		if rec := postMessages(t, handler, simpleRequest()); rec.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+	}
+	before := getMetrics(t, handler)
+	if total := before["requests"].(map[string]interface{})["total"]; total != float64(3) {
+		t.Fatalf("Expected 3 requests before reset, got %v", total)
+	}
+	uptimeBefore, _ := time.ParseDuration(before["uptime"].(string))
+
+	rec, body := postMetricsReset(t, handler, "action=reset")
+	if rec.Code != http.StatusOK || body["status"] != "ok" {
+		t.Fatalf("Expected reset to succeed, got %d: %v", rec.Code, body)
+	}
+
+	after := getMetrics(t, handler)
+	requests := after["requests"].(map[string]interface{})
+	for _, key := range []string{"total", "successful", "errors", "streaming", "tool_calls"} {
+		if requests[key] != float64(0) {
+			t.Errorf("Expected requests.%s to be 0 after reset, got %v", key, requests[key])
+		}
+	}
+	if allowed := after["rate_limit"].(map[string]interface{})["allowed"]; allowed != float64(0) {
+		t.Errorf("Expected rate limiter stats to be reset, got allowed=%v", allowed)
+	}
+	uptimeAfter, _ := time.ParseDuration(after["uptime"].(string))
+	if uptimeAfter >= uptimeBefore {
+		t.Errorf("Expected uptime to restart, got %v (was %v)", uptimeAfter, uptimeBefore)
+	}
+	if summary := handler.GetCostTracker().GetSummary(); summary.TotalRequests != 0 {
+		t.Errorf("Expected cost data to be reset, got %d requests", summary.TotalRequests)
+	}
+}
+
+func TestMetrics_ResetKeepCosts(t *testing.T) {
+	handler := newTestHandler(t, func(cfg *config.Config) {
+		cfg.AuthEnabled = true
+		cfg.AuthAPIKey = "proxy-key"
+	}, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"chatcmpl-1","choices":[{"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}],"usage":{"prompt_tokens":10,"completion_tokens":5}}`))
+	})
+	postMessages(t, handler, simpleRequest())
+
+	if rec, body := postMetricsReset(t, handler, "action=reset&keep_costs=true"); rec.Code != http.StatusOK {
+		t.Fatalf("Expected reset to succeed, got %d: %v", rec.Code, body)
+	}
+	if summary := handler.GetCostTracker().GetSummary(); summary.TotalRequests != 1 {
+		t.Errorf("Expected cost data to be kept, got %d requests", summary.TotalRequests)
+	}
+
+	if rec, _ := postMetricsReset(t, handler, "action=clear"); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown action, got %d", rec.Code)
+	}
+}
+
+func TestMetrics_ResetRequiresAuth(t *testing.T) {
+	handler := newTestHandler(t, nil, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(chatCompletionOK))
+	})
+	postMessages(t, handler, simpleRequest())
+
+	if rec, body := postMetricsReset(t, handler, "action=reset"); rec.Code != http.StatusForbidden {
+		t.Fatalf("Expected 403 with authentication disabled, got %d: %v", rec.Code, body)
+	}
+	if total := getMetrics(t, handler)["requests"].(map[string]interface{})["total"]; total != float64(1) {
+		t.Errorf("Expected the metrics to be kept, got %v requests", total)
+	}
+	if summary := handler.GetCostTracker().GetSummary(); summary.TotalRequests != 1 {
+		t.Errorf("Expected cost data to be kept, got %d requests", summary.TotalRequests)
+	}
+}
+
+func TestAuthMiddleware_AnonymousMetricsReadOnly(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	middleware := proxy.AuthMiddleware(&proxy.AuthConfig{
+		Enabled:               true,
+		APIKey:                "test-key",
+		AllowAnonymousMetrics: true,
+	})(next)
+
+	rec := httptest.NewRecorder()
+	middleware.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", http.NoBody))
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected anonymous GET /metrics to be allowed, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	middleware.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/metrics?action=reset", http.NoBody))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected anonymous reset to be rejected, got %d", rec.Code)
+	}
+}
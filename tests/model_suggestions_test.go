@@ -0,0 +1,80 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/jedarden/clasp/internal/config"
+)
+
+// modelNotFoundError is the error body of a response to a request for a model
+// the upstream doesn't know.
+type modelNotFoundError struct {
+	Type  string `json:"type"`
+	Error struct {
+		Type            string   `json:"type"`
+		Message         string   `json:"message"`
+		Suggestions     []string `json:"suggestions"`
+		AvailableModels []string `json:"available_models"`
+	} `json:"error"`
+}
+
+func TestModelNotFound_SuggestsClosestModels(t *testing.T) {
+	handler := newTestHandler(t, func(cfg *config.Config) {
+		cfg.Provider = config.ProviderOpenAI
+		cfg.OpenAIBaseURL = cfg.CustomBaseURL
+		cfg.OpenAIAPIKey = "sk-test"
+		cfg.DefaultModel = "gpt-4o-mni"
+		cfg.ModelAliases = map[string]string{"fast": "gpt-4o-mini"}
+	}, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"error":{"message":"The model 'gpt-4o-mni' does not exist or you do not have access to it.","type":"invalid_request_error","code":"model_not_found"}}`))
+	})
+
+	rec := postMessages(t, handler, simpleRequest())
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("Expected status 404, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp modelNotFoundError
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode error: %v", err)
+	}
+	if resp.Type != "error" || resp.Error.Type != "not_found_error" {
+		t.Errorf("Expected an Anthropic not_found_error, got %s", rec.Body.String())
+	}
+	if len(resp.Error.Suggestions) == 0 || resp.Error.Suggestions[0] != "gpt-4o-mini" {
+		t.Errorf("Expected gpt-4o-mini as the first suggestion, got %v", resp.Error.Suggestions)
+	}
+	if !strings.Contains(resp.Error.Message, "Did you mean: gpt-4o-mini") || !strings.Contains(resp.Error.Message, "does not exist") {
+		t.Errorf("Expected the suggestions and the upstream message, got %q", resp.Error.Message)
+	}
+	found := false
+	for _, m := range resp.Error.AvailableModels {
+		if m == "fast" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected the configured alias among the available models, got %v", resp.Error.AvailableModels)
+	}
+}
+
+func TestModelNotFound_OtherErrorsRelayed(t *testing.T) {
+	upstreamBody := `{"error":{"message":"max_tokens is too large","type":"invalid_request_error"}}`
+	handler := newTestHandler(t, nil, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(upstreamBody))
+	})
+
+	rec := postMessages(t, handler, simpleRequest())
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status 400, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec.Body.String() != upstreamBody {
+		t.Errorf("Expected the upstream error unchanged, got %s", rec.Body.String())
+	}
+}
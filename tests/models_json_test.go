@@ -0,0 +1,94 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"testing"
+)
+
+// modelsJSONOutput mirrors the output of 'clasp -models -json'.
+type modelsJSONOutput struct {
+	Provider string `json:"provider"`
+	Count    int    `json:"count"`
+	Models   []struct {
+		ID                    string  `json:"id"`
+		Name                  string  `json:"name"`
+		Description           string  `json:"description"`
+		ContextWindow         int     `json:"context_window"`
+		InputPricePerMillion  float64 `json:"input_price_per_million"`
+		OutputPricePerMillion float64 `json:"output_price_per_million"`
+		Recommended           bool    `json:"recommended"`
+	} `json:"models"`
+}
+
+// runModelsJSON runs clasp with args and the extra environment and decodes its
+// JSON model list.
+func runModelsJSON(t *testing.T, env []string, args ...string) modelsJSONOutput {
+	t.Helper()
+	cmd := exec.Command("go", append([]string{"run", "../cmd/clasp"}, args...)...)
+	cmd.Env = append(commandEnvWithHome(t, t.TempDir()), env...)
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("clasp %v failed: %v, output: %s", args, err, output)
+	}
+	var listing modelsJSONOutput
+	if err := json.Unmarshal(output, &listing); err != nil {
+		t.Fatalf("models output is not valid JSON: %v\n%s", err, output)
+	}
+	return listing
+}
+
+func TestCommandModelsJSON_KnownMetadata(t *testing.T) {
+	listing := runModelsJSON(t, []string{
+		"PROVIDER=azure",
+		"AZURE_API_KEY=test-key",
+		"AZURE_OPENAI_ENDPOINT=https://my-openai.openai.azure.com",
+		"AZURE_DEPLOYMENT_NAME=gpt-4o",
+		"AZURE_MGMT_TOKEN=",
+	}, "models", "-json")
+
+	if listing.Provider != "azure" {
+		t.Errorf("provider = %q, want azure", listing.Provider)
+	}
+	if listing.Count != len(listing.Models) || listing.Count == 0 {
+		t.Fatalf("count = %d with %d models", listing.Count, len(listing.Models))
+	}
+	found := false
+	for _, m := range listing.Models {
+		if m.ID == "" {
+			t.Errorf("Model without an id: %+v", m)
+		}
+		if m.ID == "gpt-4o" {
+			found = true
+			if m.ContextWindow != 128000 || m.Name != "GPT-4o" || !m.Recommended {
+				t.Errorf("Expected known metadata for gpt-4o, got %+v", m)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("Expected gpt-4o in %+v", listing.Models)
+	}
+}
+
+func TestCommandModelsJSON_FetchedModels(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"data":[{"id":"gpt-4o"},{"id":"gpt-4o-mini"}]}`))
+	}))
+	t.Cleanup(server.Close)
+
+	listing := runModelsJSON(t, []string{
+		"PROVIDER=custom",
+		"CUSTOM_BASE_URL=" + server.URL,
+		"CUSTOM_API_KEY=test-key",
+		"CUSTOM_MODELS_PATH=",
+	}, "models", "--json")
+
+	if listing.Provider != "custom" || listing.Count != 2 {
+		t.Fatalf("Expected 2 custom models, got %+v", listing)
+	}
+	if listing.Models[0].ID != "gpt-4o" || listing.Models[1].ID != "gpt-4o-mini" {
+		t.Errorf("Unexpected models %+v", listing.Models)
+	}
+}
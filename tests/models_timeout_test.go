@@ -0,0 +1,75 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jedarden/clasp/internal/setup"
+)
+
+func TestModelsTimeout_FromEnv(t *testing.T) {
+	t.Setenv("CLASP_MODELS_TIMEOUT", "")
+	if got := setup.NewWizard().ModelsTimeout(); got != setup.DefaultModelsTimeout {
+		t.Errorf("Expected the default timeout %v, got %v", setup.DefaultModelsTimeout, got)
+	}
+
+	t.Setenv("CLASP_MODELS_TIMEOUT", "45")
+	if got := setup.NewWizard().ModelsTimeout(); got != 45*time.Second {
+		t.Errorf("Expected CLASP_MODELS_TIMEOUT to set 45s, got %v", got)
+	}
+
+	t.Setenv("CLASP_MODELS_TIMEOUT", "soon")
+	if _, err := setup.ModelsTimeoutFromEnv(); err == nil || !strings.Contains(err.Error(), "CLASP_MODELS_TIMEOUT") {
+		t.Errorf("Expected an error naming CLASP_MODELS_TIMEOUT, got %v", err)
+	}
+	if got := setup.NewWizard().ModelsTimeout(); got != setup.DefaultModelsTimeout {
+		t.Errorf("Expected an invalid value to fall back to %v, got %v", setup.DefaultModelsTimeout, got)
+	}
+}
+
+func TestModelsTimeout_SlowServer(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { close(release) })
+
+	wizard := setup.NewWizard()
+	wizard.SetModelsTimeout(100 * time.Millisecond)
+	start := time.Now()
+	_, err := wizard.FetchModelsPublic("custom", "", server.URL, "")
+	if err == nil {
+		t.Fatal("Expected a timeout error")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected the configured timeout to apply, took %v", elapsed)
+	}
+	if !strings.Contains(err.Error(), "timed out after 100ms") || !strings.Contains(err.Error(), "CLASP_MODELS_TIMEOUT") {
+		t.Errorf("Expected a clear timeout error, got %v", err)
+	}
+}
+
+func TestModelsTimeout_FastServer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"data":[{"id":"gpt-4o"},{"id":"gpt-4o-mini"}]}`))
+	}))
+	t.Cleanup(server.Close)
+
+	wizard := setup.NewWizard()
+	wizard.SetModelsTimeout(5 * time.Second)
+	models, err := wizard.FetchModelsPublic("custom", "", server.URL, "")
+	if err != nil {
+		t.Fatalf("FetchModelsPublic failed: %v", err)
+	}
+	if len(models) != 2 {
+		t.Errorf("Expected 2 models, got %v", models)
+	}
+}
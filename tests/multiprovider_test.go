@@ -199,3 +199,28 @@ func TestMultiProviderConfig_TierSpecificAPIKey(t *testing.T) {
 		t.Errorf("Expected tier-specific API key 'tier-specific-key', got '%s'", opusTier.APIKey)
 	}
 }
+
+func TestMultiProviderConfig_TierHTTPTimeout(t *testing.T) {
+	t.Setenv("OPENAI_API_KEY", "test-key")
+	t.Setenv("CLASP_MULTI_PROVIDER", "true")
+	t.Setenv("CLASP_OPUS_PROVIDER", "openai")
+	t.Setenv("CLASP_OPUS_MODEL", "o1")
+	t.Setenv("CLASP_OPUS_HTTP_TIMEOUT", "900")
+	t.Setenv("CLASP_HAIKU_HTTP_TIMEOUT", "30") // Timeout alone, main provider
+
+	cfg, err := config.LoadFromEnv()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if tier := cfg.GetTierConfig("claude-3-opus-20240229"); tier == nil || tier.HTTPTimeoutSec != 900 {
+		t.Errorf("Expected Opus tier timeout 900, got %+v", tier)
+	}
+	if tier := cfg.GetTierConfig("claude-3-haiku-20240307"); tier == nil || tier.HTTPTimeoutSec != 30 || tier.Provider != "" {
+		t.Errorf("Expected Haiku tier with only a 30s timeout, got %+v", tier)
+	}
+
+	t.Setenv("CLASP_HAIKU_HTTP_TIMEOUT", "soon")
+	if _, err := config.LoadFromEnv(); err == nil {
+		t.Error("Expected an error for an invalid CLASP_HAIKU_HTTP_TIMEOUT")
+	}
+}
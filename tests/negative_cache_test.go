@@ -0,0 +1,98 @@
+package tests
+
+import (
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jedarden/clasp/internal/proxy"
+)
+
+// statusUpstream returns a mock upstream that answers every request with
+// status and counts its requests.
+func statusUpstream(status int, calls *int32) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(calls, 1)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(`{"error":{"message":"bad request","type":"invalid_request_error"}}`))
+	}
+}
+
+func TestNegativeCache_ServesRepeated400(t *testing.T) {
+	var calls int32
+	handler := newTestHandler(t, nil, statusUpstream(http.StatusBadRequest, &calls))
+	handler.SetNegativeCache(proxy.NewNegativeCache(time.Minute))
+
+	first := postMessages(t, handler, simpleRequest())
+	if first.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status 400, got %d: %s", first.Code, first.Body.String())
+	}
+	if first.Header().Get("X-CLASP-Negative-Cache") != "" {
+		t.Error("Expected the first request to reach the upstream")
+	}
+
+	second := postMessages(t, handler, simpleRequest())
+	if second.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status 400, got %d: %s", second.Code, second.Body.String())
+	}
+	if second.Header().Get("X-CLASP-Negative-Cache") != "HIT" {
+		t.Errorf("Expected X-CLASP-Negative-Cache HIT, got %q", second.Header().Get("X-CLASP-Negative-Cache"))
+	}
+	if second.Body.String() != first.Body.String() {
+		t.Errorf("Expected the cached error body %q, got %q", first.Body.String(), second.Body.String())
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("Expected 1 upstream call, got %d", n)
+	}
+
+	// A different request is not affected
+	other := simpleRequest()
+	other.MaxTokens++
+	postMessages(t, handler, other)
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Errorf("Expected a different request to reach the upstream, got %d calls", n)
+	}
+
+	negative, _ := getMetrics(t, handler)["negative_cache"].(map[string]interface{})
+	if negative["hits"] != float64(1) || negative["size"] != float64(2) {
+		t.Errorf("Expected 1 hit and 2 entries in negative_cache metrics, got %v", negative)
+	}
+}
+
+func TestNegativeCache_DoesNotCacheTransientErrors(t *testing.T) {
+	for _, status := range []int{http.StatusInternalServerError, http.StatusTooManyRequests} {
+		var calls int32
+		handler := newTestHandler(t, nil, statusUpstream(status, &calls))
+		handler.SetNegativeCache(proxy.NewNegativeCache(time.Minute))
+
+		before := atomic.LoadInt32(&calls)
+		postMessages(t, handler, simpleRequest())
+		afterFirst := atomic.LoadInt32(&calls)
+		rec := postMessages(t, handler, simpleRequest())
+		if rec.Header().Get("X-CLASP-Negative-Cache") != "" {
+			t.Errorf("status %d: expected the error not to be cached", status)
+		}
+		if atomic.LoadInt32(&calls)-afterFirst != afterFirst-before {
+			t.Errorf("status %d: expected the second request to reach the upstream like the first", status)
+		}
+	}
+}
+
+func TestNegativeCache_EntriesExpire(t *testing.T) {
+	nc := proxy.NewNegativeCache(20 * time.Millisecond)
+	if !nc.Set("key", http.StatusBadRequest, []byte("{}")) {
+		t.Fatal("Expected a 400 to be cached")
+	}
+	if nc.Set("other", http.StatusServiceUnavailable, []byte("{}")) {
+		t.Error("Expected a 503 not to be cached")
+	}
+	if status, _, ok := nc.Get("key"); !ok || status != http.StatusBadRequest {
+		t.Fatalf("Expected a cached 400, got %d (found %v)", status, ok)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if _, _, ok := nc.Get("key"); ok {
+		t.Error("Expected the entry to expire after its TTL")
+	}
+}
@@ -0,0 +1,130 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/jedarden/clasp/internal/config"
+	"github.com/jedarden/clasp/internal/proxy"
+	"github.com/jedarden/clasp/pkg/models"
+)
+
+// Recorded responses from Ollama's OpenAI-compatible endpoint for a model
+// that reports no usage.
+const (
+	ollamaChatResponse = `{"id":"chatcmpl-629","object":"chat.completion","created":1735689600,"model":"llama3.2","system_fingerprint":"fp_ollama","choices":[{"index":0,"message":{"role":"assistant","content":"The capital of France is Paris."},"finish_reason":"stop"}]}`
+
+	ollamaToolCallResponse = `{"id":"chatcmpl-630","object":"chat.completion","created":1735689601,"model":"llama3.2","system_fingerprint":"fp_ollama","choices":[{"index":0,"message":{"role":"assistant","content":"","tool_calls":[{"id":"call_x1","type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"Paris\"}"}}]},"finish_reason":"stop"}]}`
+
+	ollamaStreamResponse = "data: {\"id\":\"chatcmpl-631\",\"object\":\"chat.completion.chunk\",\"created\":1735689602,\"model\":\"llama3.2\",\"system_fingerprint\":\"fp_ollama\",\"choices\":[{\"index\":0,\"delta\":{\"role\":\"assistant\",\"content\":\"The capital \"},\"finish_reason\":null}]}\n\n" +
+		"data: {\"id\":\"chatcmpl-631\",\"object\":\"chat.completion.chunk\",\"created\":1735689602,\"model\":\"llama3.2\",\"system_fingerprint\":\"fp_ollama\",\"choices\":[{\"index\":0,\"delta\":{\"role\":\"assistant\",\"content\":\"is Paris.\"},\"finish_reason\":null}]}\n\n" +
+		"data: {\"id\":\"chatcmpl-631\",\"object\":\"chat.completion.chunk\",\"created\":1735689602,\"model\":\"llama3.2\",\"system_fingerprint\":\"fp_ollama\",\"choices\":[{\"index\":0,\"delta\":{\"role\":\"assistant\",\"content\":\"\"},\"finish_reason\":\"stop\"}]}\n\n" +
+		"data: [DONE]\n\n"
+)
+
+// newOllamaHandler creates a handler for an Ollama mock that replies with body.
+func newOllamaHandler(t *testing.T, contentType, body string) *proxy.Handler {
+	t.Helper()
+	cfg, server := newMockUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			t.Errorf("Expected Ollama's OpenAI-compatible endpoint, got %s", r.URL.Path)
+		}
+		w.Header().Set("Content-Type", contentType)
+		_, _ = w.Write([]byte(body))
+	})
+	cfg.Provider = config.ProviderOllama
+	cfg.OllamaBaseURL = server.URL
+	cfg.DefaultModel = "llama3.2"
+	handler, err := proxy.NewHandler(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create handler: %v", err)
+	}
+	return handler
+}
+
+func TestOllama_EstimatesMissingUsage(t *testing.T) {
+	handler := newOllamaHandler(t, "application/json", ollamaChatResponse)
+
+	req := simpleRequest()
+	req.Messages = []models.AnthropicMessage{{Role: "user", Content: "What is the capital of France? Answer in one sentence."}}
+	rec := postMessages(t, handler, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var resp models.AnthropicResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.StopReason != "end_turn" {
+		t.Errorf("Expected stop_reason end_turn, got %q", resp.StopReason)
+	}
+	if resp.Usage == nil || resp.Usage.InputTokens == 0 || resp.Usage.OutputTokens == 0 {
+		t.Fatalf("Expected estimated nonzero usage, got %+v", resp.Usage)
+	}
+	// "The capital of France is Paris." is 31 characters, about 8 tokens
+	if resp.Usage.OutputTokens != 8 {
+		t.Errorf("Expected 8 estimated output tokens, got %d", resp.Usage.OutputTokens)
+	}
+
+	if summary := handler.GetCostTracker().GetSummary(); summary.TotalInputTokens == 0 || summary.TotalOutputTokens != 8 {
+		t.Errorf("Expected estimated usage in cost tracking, got %d input and %d output tokens", summary.TotalInputTokens, summary.TotalOutputTokens)
+	}
+}
+
+func TestOllama_ToolCallFinishedWithStop(t *testing.T) {
+	handler := newOllamaHandler(t, "application/json", ollamaToolCallResponse)
+
+	rec := postMessages(t, handler, simpleRequest())
+	var resp models.AnthropicResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.StopReason != "tool_use" {
+		t.Errorf("Expected a tool call finished with stop to map to tool_use, got %q", resp.StopReason)
+	}
+	if len(resp.Content) != 1 || resp.Content[0].Type != "tool_use" || resp.Content[0].Name != "get_weather" {
+		t.Errorf("Expected a get_weather tool_use block, got %+v", resp.Content)
+	}
+}
+
+func TestOllama_EstimatesMissingStreamUsage(t *testing.T) {
+	handler := newOllamaHandler(t, "text/event-stream", ollamaStreamResponse)
+
+	rec := postMessages(t, handler, streamingRequest())
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var start, delta map[string]interface{}
+	for _, event := range parseSSEEvents(t, rec.Body.String()) {
+		switch event.Type {
+		case "message_start":
+			start = event.Data["message"].(map[string]interface{})
+		case "message_delta":
+			delta = event.Data
+		}
+	}
+	if start == nil || delta == nil {
+		t.Fatalf("Expected message_start and message_delta events, got %s", rec.Body.String())
+	}
+	if tokens := start["usage"].(map[string]interface{})["input_tokens"]; tokens == float64(0) || tokens == float64(100) {
+		t.Errorf("Expected estimated input_tokens in message_start, got %v", tokens)
+	}
+	// "The capital is Paris." is 21 characters, about 6 tokens
+	if tokens := delta["usage"].(map[string]interface{})["output_tokens"]; tokens != float64(6) {
+		t.Errorf("Expected 6 estimated output_tokens, got %v", tokens)
+	}
+	if reason := delta["delta"].(map[string]interface{})["stop_reason"]; reason != "end_turn" {
+		t.Errorf("Expected stop_reason end_turn, got %v", reason)
+	}
+	if !strings.Contains(rec.Body.String(), "is Paris.") {
+		t.Errorf("Expected streamed text, got %s", rec.Body.String())
+	}
+
+	if summary := handler.GetCostTracker().GetSummary(); summary.TotalOutputTokens != 6 {
+		t.Errorf("Expected 6 output tokens in cost tracking, got %d", summary.TotalOutputTokens)
+	}
+}
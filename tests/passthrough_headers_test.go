@@ -0,0 +1,128 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jedarden/clasp/internal/config"
+	"github.com/jedarden/clasp/internal/proxy"
+)
+
+// newPassthroughCapture returns a handler that routes sonnet requests to an
+// Anthropic passthrough upstream, and the headers of the last upstream request.
+func newPassthroughCapture(t *testing.T, configure func(*config.Config)) (*proxy.Handler, *http.Header) {
+	t.Helper()
+	upstreamHeaders := new(http.Header)
+	anthropic := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*upstreamHeaders = r.Header.Clone()
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"msg_01","type":"message","role":"assistant","content":[{"type":"text","text":"Hi"}],"model":"claude-3-7-sonnet-20250219","stop_reason":"end_turn","usage":{"input_tokens":10,"output_tokens":2}}`))
+	}))
+	t.Cleanup(anthropic.Close)
+
+	handler := newTestHandler(t, func(cfg *config.Config) {
+		cfg.MultiProviderEnabled = true
+		cfg.TierSonnet = &config.TierConfig{Provider: config.ProviderAnthropic, BaseURL: anthropic.URL, APIKey: "test-key", Model: "claude-3-7-sonnet-20250219"}
+		if configure != nil {
+			configure(cfg)
+		}
+	}, func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Unexpected request to the primary provider")
+	})
+	return handler, upstreamHeaders
+}
+
+func TestPassthroughHeaders_Defaults(t *testing.T) {
+	handler, upstream := newPassthroughCapture(t, nil)
+
+	rec := postMessagesWithHeaders(t, handler, simpleRequest(), map[string]string{
+		"anthropic-version":   "2023-06-01",
+		"anthropic-beta":      "prompt-caching-2024-07-31",
+		"Proxy-Authorization": "Basic c2VjcmV0",
+		"X-Request-Id":        "req-123",
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if got := upstream.Get("anthropic-version"); got != "2023-06-01" {
+		t.Errorf("Expected anthropic-version to be forwarded, got %q", got)
+	}
+	if got := upstream.Get("anthropic-beta"); got != "prompt-caching-2024-07-31" {
+		t.Errorf("Expected anthropic-beta to be forwarded, got %q", got)
+	}
+	if got := upstream.Get("Proxy-Authorization"); got != "" {
+		t.Errorf("Expected Proxy-Authorization to be stripped, got %q", got)
+	}
+	if got := upstream.Get("X-Request-Id"); got != "" {
+		t.Errorf("Expected X-Request-Id not to be forwarded by default, got %q", got)
+	}
+}
+
+func TestPassthroughHeaders_Configured(t *testing.T) {
+	handler, upstream := newPassthroughCapture(t, func(cfg *config.Config) {
+		cfg.PassthroughForwardHeaders = []string{"anthropic-version", "x-request-id", "x-internal-trace"}
+		cfg.PassthroughStripHeaders = []string{"x-internal-trace", "anthropic-beta"}
+	})
+
+	rec := postMessagesWithHeaders(t, handler, simpleRequest(), map[string]string{
+		"anthropic-beta":   "prompt-caching-2024-07-31",
+		"X-Request-Id":     "req-123",
+		"X-Internal-Trace": "trace-456",
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if got := upstream.Get("X-Request-Id"); got != "req-123" {
+		t.Errorf("Expected X-Request-Id to be forwarded, got %q", got)
+	}
+	if got := upstream.Get("X-Internal-Trace"); got != "" {
+		t.Errorf("Expected the strip list to override the forward list, got %q", got)
+	}
+	if got := upstream.Get("anthropic-beta"); got != "" {
+		t.Errorf("Expected anthropic-beta to be stripped, got %q", got)
+	}
+	if got := upstream.Get("anthropic-version"); got != "2023-06-01" {
+		t.Errorf("Expected the default anthropic-version, got %q", got)
+	}
+	if got := upstream.Get("x-api-key"); got != "test-key" {
+		t.Errorf("Expected the provider API key, got %q", got)
+	}
+}
+
+func TestPassthroughHeaders_ConnectionListedHeadersStripped(t *testing.T) {
+	handler, upstream := newPassthroughCapture(t, func(cfg *config.Config) {
+		cfg.PassthroughForwardHeaders = []string{"anthropic-version", "x-request-id"}
+	})
+
+	rec := postMessagesWithHeaders(t, handler, simpleRequest(), map[string]string{
+		"Connection":   "X-Request-Id",
+		"X-Request-Id": "req-123",
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if got := upstream.Get("X-Request-Id"); got != "" {
+		t.Errorf("Expected a header named by Connection to be stripped, got %q", got)
+	}
+}
+
+func TestPassthroughHeaders_CredentialHeadersNotForwarded(t *testing.T) {
+	handler, upstream := newPassthroughCapture(t, func(cfg *config.Config) {
+		cfg.PassthroughForwardHeaders = []string{"anthropic-version", "authorization", "x-api-key"}
+	})
+
+	rec := postMessagesWithHeaders(t, handler, simpleRequest(), map[string]string{
+		"Authorization": "Bearer client-token",
+		"x-api-key":     "client-key",
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if got := upstream.Get("Authorization"); got != "" {
+		t.Errorf("Expected Authorization not to be forwarded, got %q", got)
+	}
+	if got := upstream.Get("x-api-key"); got != "test-key" {
+		t.Errorf("Expected the provider API key, got %q", got)
+	}
+}
@@ -0,0 +1,98 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jedarden/clasp/internal/config"
+)
+
+// recordedThinkingStream is an Anthropic SSE stream with extended thinking.
+const recordedThinkingStream = "event: message_start\n" +
+	`data: {"type":"message_start","message":{"id":"msg_01","type":"message","role":"assistant","content":[],"model":"claude-3-7-sonnet-20250219","stop_reason":null,"usage":{"input_tokens":1250,"cache_creation_input_tokens":0,"cache_read_input_tokens":0,"output_tokens":2}}}` + "\n\n" +
+	"event: content_block_start\n" +
+	`data: {"type":"content_block_start","index":0,"content_block":{"type":"thinking","thinking":""}}` + "\n\n" +
+	"event: ping\n" +
+	`data: {"type": "ping"}` + "\n\n" +
+	"event: content_block_delta\n" +
+	`data: {"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"Let me work out 27 * 453 step by step."}}` + "\n\n" +
+	"event: content_block_delta\n" +
+	`data: {"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":" 27 * 453 = 12231."}}` + "\n\n" +
+	"event: content_block_delta\n" +
+	`data: {"type":"content_block_delta","index":0,"delta":{"type":"signature_delta","signature":"EqQBCgIYAhIM1gbcDa9GJwZA2b3hGgxBdjrkzLoky3dl1pkiMOYds"}}` + "\n\n" +
+	"event: content_block_stop\n" +
+	`data: {"type":"content_block_stop","index":0}` + "\n\n" +
+	"event: content_block_start\n" +
+	`data: {"type":"content_block_start","index":1,"content_block":{"type":"text","text":""}}` + "\n\n" +
+	"event: content_block_delta\n" +
+	`data: {"type":"content_block_delta","index":1,"delta":{"type":"text_delta","text":"27 * 453 = 12,231"}}` + "\n\n" +
+	"event: content_block_stop\n" +
+	`data: {"type":"content_block_stop","index":1}` + "\n\n" +
+	"event: message_delta\n" +
+	`data: {"type":"message_delta","delta":{"stop_reason":"end_turn","stop_sequence":null},"usage":{"output_tokens":187}}` + "\n\n" +
+	"event: message_stop\n" +
+	`data: {"type":"message_stop"}` + "\n\n"
+
+func TestPassthroughStreaming_UsageTee(t *testing.T) {
+	anthropic := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		flusher := w.(http.Flusher)
+		// Send odd-sized chunks so events and lines are split across reads
+		stream := []byte(recordedThinkingStream)
+		for len(stream) > 0 {
+			n := 37
+			if n > len(stream) {
+				n = len(stream)
+			}
+			_, _ = w.Write(stream[:n])
+			flusher.Flush()
+			stream = stream[n:]
+		}
+	}))
+	t.Cleanup(anthropic.Close)
+
+	handler := newTestHandler(t, func(cfg *config.Config) {
+		cfg.MultiProviderEnabled = true
+		cfg.TierSonnet = &config.TierConfig{Provider: config.ProviderAnthropic, BaseURL: anthropic.URL, APIKey: "test-key", Model: "claude-3-7-sonnet-20250219"}
+	}, func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Unexpected request to the primary provider")
+	})
+
+	req := simpleRequest()
+	req.Stream = true
+	rec := postMessages(t, handler, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec.Body.String() != recordedThinkingStream {
+		t.Errorf("Expected the stream to be forwarded byte for byte, got:\n%s", rec.Body.String())
+	}
+
+	summary := handler.GetCostTracker().GetSummary()
+	if summary.TotalInputTokens != 1250 || summary.TotalOutputTokens != 187 {
+		t.Errorf("Expected 1250 input and 187 output tokens, got %d and %d", summary.TotalInputTokens, summary.TotalOutputTokens)
+	}
+	if _, ok := summary.ByModel["claude-3-7-sonnet-20250219"]; !ok {
+		t.Errorf("Expected usage recorded for the streamed model, got %v", summary.ByModel)
+	}
+
+	metricsRec := httptest.NewRecorder()
+	handler.HandleMetrics(metricsRec, httptest.NewRequest(http.MethodGet, "/metrics", http.NoBody))
+	var metrics map[string]interface{}
+	if err := json.Unmarshal(metricsRec.Body.Bytes(), &metrics); err != nil {
+		t.Fatalf("Failed to decode metrics: %v", err)
+	}
+	// 56 characters of thinking text at ~4 characters per token
+	if metrics["thinking_tokens"] != float64(14) {
+		t.Errorf("Expected 14 thinking tokens, got %v", metrics["thinking_tokens"])
+	}
+
+	promRec := httptest.NewRecorder()
+	handler.HandleMetricsPrometheus(promRec, httptest.NewRequest(http.MethodGet, "/metrics/prometheus", http.NoBody))
+	if !strings.Contains(promRec.Body.String(), `clasp_tokens_thinking_total{provider="custom"} 14`) {
+		t.Errorf("Expected clasp_tokens_thinking_total in Prometheus output")
+	}
+}
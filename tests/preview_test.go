@@ -0,0 +1,155 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jedarden/clasp/internal/config"
+	"github.com/jedarden/clasp/internal/proxy"
+	"github.com/jedarden/clasp/pkg/models"
+)
+
+// previewResponse mirrors the body of /v1/messages/preview.
+type previewResponse struct {
+	Provider     string                 `json:"provider"`
+	Model        string                 `json:"model"`
+	TargetModel  string                 `json:"target_model"`
+	EndpointURL  string                 `json:"endpoint_url"`
+	EndpointType string                 `json:"endpoint_type"`
+	Request      map[string]interface{} `json:"request"`
+}
+
+// newPreviewHandler creates a handler for a mock upstream that fails the test
+// if it is ever called.
+func newPreviewHandler(t *testing.T, configure func(cfg *config.Config)) (*proxy.Handler, string) {
+	t.Helper()
+	cfg, server := newMockUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("Preview must not call upstream, got %s %s", r.Method, r.URL.Path)
+	})
+	if configure != nil {
+		configure(cfg)
+	}
+	handler, err := proxy.NewHandler(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create handler: %v", err)
+	}
+	return handler, server.URL
+}
+
+func postPreview(t *testing.T, handler *proxy.Handler, req *models.AnthropicRequest) previewResponse {
+	t.Helper()
+	body, err := json.Marshal(req)
+	if err != nil {
+		t.Fatalf("Failed to marshal request: %v", err)
+	}
+	httpReq := httptest.NewRequest(http.MethodPost, "/v1/messages/preview", bytes.NewReader(body))
+	rec := httptest.NewRecorder()
+	handler.HandleMessagesPreview(rec, httpReq)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var preview previewResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &preview); err != nil {
+		t.Fatalf("Failed to decode preview: %v", err)
+	}
+	return preview
+}
+
+func TestMessagesPreview_ChatCompletions(t *testing.T) {
+	handler, upstreamURL := newPreviewHandler(t, nil)
+
+	preview := postPreview(t, handler, simpleRequest())
+	if preview.Provider != "custom" {
+		t.Errorf("Expected provider custom, got %q", preview.Provider)
+	}
+	if preview.TargetModel != "gpt-4o" {
+		t.Errorf("Expected target model gpt-4o, got %q", preview.TargetModel)
+	}
+	if preview.EndpointType != "chat_completions" {
+		t.Errorf("Expected endpoint type chat_completions, got %q", preview.EndpointType)
+	}
+	if preview.EndpointURL != upstreamURL+"/chat/completions" {
+		t.Errorf("Expected endpoint URL %s/chat/completions, got %q", upstreamURL, preview.EndpointURL)
+	}
+	if preview.Request["model"] != "gpt-4o" {
+		t.Errorf("Expected the translated request to target gpt-4o, got %v", preview.Request["model"])
+	}
+	if _, ok := preview.Request["messages"].([]interface{}); !ok {
+		t.Errorf("Expected OpenAI messages in the translated request, got %v", preview.Request)
+	}
+}
+
+func TestMessagesPreview_AliasResolution(t *testing.T) {
+	handler, _ := newPreviewHandler(t, func(cfg *config.Config) {
+		cfg.ModelAliases = map[string]string{"fast": "claude-3-haiku-20240307"}
+		cfg.ModelHaiku = "gpt-4o-mini"
+	})
+
+	req := simpleRequest()
+	req.Model = "fast"
+	preview := postPreview(t, handler, req)
+	if preview.Model != "claude-3-haiku-20240307" {
+		t.Errorf("Expected the alias to resolve to claude-3-haiku-20240307, got %q", preview.Model)
+	}
+	if preview.TargetModel != "gpt-4o-mini" {
+		t.Errorf("Expected target model gpt-4o-mini, got %q", preview.TargetModel)
+	}
+}
+
+func TestMessagesPreview_TierRouting(t *testing.T) {
+	handler, _ := newPreviewHandler(t, func(cfg *config.Config) {
+		cfg.MultiProviderEnabled = true
+		cfg.TierOpus = &config.TierConfig{Provider: config.ProviderOpenAI, BaseURL: "https://tier.example.com/v1", APIKey: "sk-tier-secret", Model: "gpt-5"}
+	})
+
+	req := simpleRequest()
+	req.Model = "claude-3-opus-20240229"
+	preview := postPreview(t, handler, req)
+	if preview.Provider != "openai" {
+		t.Errorf("Expected the opus tier provider openai, got %q", preview.Provider)
+	}
+	if preview.TargetModel != "gpt-5" {
+		t.Errorf("Expected target model gpt-5, got %q", preview.TargetModel)
+	}
+	if preview.EndpointType != "responses" {
+		t.Errorf("Expected endpoint type responses, got %q", preview.EndpointType)
+	}
+	if preview.EndpointURL != "https://tier.example.com/v1/responses" {
+		t.Errorf("Expected the tier's responses URL, got %q", preview.EndpointURL)
+	}
+	if _, ok := preview.Request["input"]; !ok {
+		t.Errorf("Expected a Responses API request with input, got %v", preview.Request)
+	}
+}
+
+func TestMessagesPreview_ThinkingMapping(t *testing.T) {
+	handler, _ := newPreviewHandler(t, func(cfg *config.Config) {
+		cfg.DefaultModel = "o3-mini"
+	})
+
+	req := simpleRequest()
+	req.Thinking = &models.ThinkingConfig{Type: "enabled", BudgetTokens: 20000}
+	preview := postPreview(t, handler, req)
+	if preview.Request["reasoning_effort"] != "medium" {
+		t.Errorf("Expected a 20000 token budget to map to reasoning_effort medium, got %v", preview.Request["reasoning_effort"])
+	}
+	if _, ok := preview.Request["thinking"]; ok {
+		t.Error("Expected the Anthropic thinking parameter to be translated, not forwarded")
+	}
+}
+
+func TestMessagesPreview_RejectsGet(t *testing.T) {
+	handler, _ := newPreviewHandler(t, nil)
+
+	rec := httptest.NewRecorder()
+	handler.HandleMessagesPreview(rec, httptest.NewRequest(http.MethodGet, "/v1/messages/preview", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status 405, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Allow"); got != "POST" {
+		t.Errorf("Expected Allow: POST, got %q", got)
+	}
+}
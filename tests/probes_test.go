@@ -0,0 +1,215 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jedarden/clasp/internal/config"
+	"github.com/jedarden/clasp/internal/proxy"
+)
+
+// getProbe calls a probe handler and returns the recorder and decoded body.
+func getProbe(t *testing.T, probe http.HandlerFunc, path string) (*httptest.ResponseRecorder, map[string]interface{}) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	probe(rec, httptest.NewRequest(http.MethodGet, path, http.NoBody))
+	var body map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Failed to decode %s response: %v", path, err)
+	}
+	return rec, body
+}
+
+func TestReadyz_CircuitBreakerOpen(t *testing.T) {
+	handler := newTestHandler(t, nil, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	breakers := proxy.NewCircuitBreakers(2, 1, time.Minute)
+	handler.SetCircuitBreakers(breakers)
+	cb := breakers.Get("custom")
+
+	rec, body := getProbe(t, handler.HandleReadyz, "/readyz")
+	if rec.Code != http.StatusOK || body["status"] != "ready" {
+		t.Fatalf("Expected ready with a closed circuit, got %d: %v", rec.Code, body)
+	}
+
+	// Force the circuit open
+	cb.RecordFailure()
+	cb.RecordFailure()
+	if !cb.IsOpen() {
+		t.Fatal("Expected the circuit breaker to be open")
+	}
+
+	rec, body = getProbe(t, handler.HandleReadyz, "/readyz")
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503 with an open circuit, got %d", rec.Code)
+	}
+	if body["reason"] != "circuit_breaker_open" {
+		t.Errorf("Expected reason circuit_breaker_open, got %v", body["reason"])
+	}
+
+	// Liveness doesn't depend on the circuit
+	if rec, body := getProbe(t, handler.HandleLivez, "/livez"); rec.Code != http.StatusOK || body["status"] != "alive" {
+		t.Errorf("Expected /livez to stay 200, got %d: %v", rec.Code, body)
+	}
+}
+
+func TestReadyz_Draining(t *testing.T) {
+	handler := newTestHandler(t, nil, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	handler.SetDraining()
+	rec, body := getProbe(t, handler.HandleReadyz, "/readyz")
+	if rec.Code != http.StatusServiceUnavailable || body["reason"] != "draining" {
+		t.Errorf("Expected 503 draining, got %d: %v", rec.Code, body)
+	}
+}
+
+func TestReadyz_UpstreamCheckCached(t *testing.T) {
+	probes := 0
+	status := http.StatusServiceUnavailable
+	handler := newTestHandler(t, nil, func(w http.ResponseWriter, r *http.Request) {
+		probes++
+		w.WriteHeader(status)
+	})
+
+	rec, body := getProbe(t, handler.HandleReadyz, "/readyz")
+	if rec.Code != http.StatusServiceUnavailable || body["reason"] != "upstream_unreachable" {
+		t.Errorf("Expected 503 for a failing upstream, got %d: %v", rec.Code, body)
+	}
+
+	// The result is reused rather than probing on every request
+	status = http.StatusOK
+	if rec, _ := getProbe(t, handler.HandleReadyz, "/readyz"); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected the cached result, got %d", rec.Code)
+	}
+	if probes != 1 {
+		t.Errorf("Expected 1 upstream probe, got %d", probes)
+	}
+}
+
+func TestAuthMiddleware_AnonymousProbes(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	for _, allow := range []bool{true, false} {
+		middleware := proxy.AuthMiddleware(&proxy.AuthConfig{
+			Enabled:              true,
+			APIKey:               "test-key",
+			AllowAnonymousHealth: allow,
+		})(handler)
+
+		want := http.StatusUnauthorized
+		if allow {
+			want = http.StatusOK
+		}
+		for _, path := range []string{"/livez", "/readyz"} {
+			rec := httptest.NewRecorder()
+			middleware.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, http.NoBody))
+			if rec.Code != want {
+				t.Errorf("%s with anonymous health %v: expected status %d, got %d", path, allow, want, rec.Code)
+			}
+		}
+	}
+}
+
+func TestHealth_UpstreamCheck(t *testing.T) {
+	var probes int32
+	var status int32 = http.StatusOK
+	handler := newTestHandler(t, func(cfg *config.Config) {
+		cfg.HealthCheckUpstream = true
+		cfg.HealthCheckUpstreamTTLSec = 1
+	}, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&probes, 1)
+		w.WriteHeader(int(atomic.LoadInt32(&status)))
+	})
+
+	rec, body := getProbe(t, handler.HandleHealth, "/health")
+	if rec.Code != http.StatusOK || body["upstream"] != "reachable" || body["status"] != "healthy" {
+		t.Fatalf("Expected a healthy reachable upstream, got %d: %v", rec.Code, body)
+	}
+	if _, ok := body["upstream_checked_at"]; !ok {
+		t.Error("Expected upstream_checked_at in the response")
+	}
+
+	// Within the cache interval the earlier result is reused
+	atomic.StoreInt32(&status, http.StatusServiceUnavailable)
+	if rec, body := getProbe(t, handler.HandleHealth, "/health"); rec.Code != http.StatusOK || body["upstream"] != "reachable" {
+		t.Errorf("Expected the cached result, got %d: %v", rec.Code, body)
+	}
+	if got := atomic.LoadInt32(&probes); got != 1 {
+		t.Errorf("Expected 1 upstream probe, got %d", got)
+	}
+
+	time.Sleep(1100 * time.Millisecond)
+	rec, body = getProbe(t, handler.HandleHealth, "/health")
+	if rec.Code != http.StatusServiceUnavailable || body["upstream"] != "unreachable" || body["status"] != "unhealthy" {
+		t.Errorf("Expected 503 for an unreachable upstream, got %d: %v", rec.Code, body)
+	}
+	if got := atomic.LoadInt32(&probes); got != 2 {
+		t.Errorf("Expected 2 upstream probes, got %d", got)
+	}
+}
+
+func TestHealth_UpstreamCheckRejectedKey(t *testing.T) {
+	handler := newTestHandler(t, func(cfg *config.Config) {
+		cfg.HealthCheckUpstream = true
+	}, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+
+	rec, body := getProbe(t, handler.HandleHealth, "/health")
+	if rec.Code != http.StatusServiceUnavailable || body["upstream"] != "unreachable" {
+		t.Errorf("Expected 503 for a rejected API key, got %d: %v", rec.Code, body)
+	}
+	if msg, _ := body["upstream_error"].(string); !strings.Contains(msg, "API key") {
+		t.Errorf("Expected an error naming the API key, got %v", body["upstream_error"])
+	}
+}
+
+func TestHealth_UpstreamCheckDisabled(t *testing.T) {
+	handler := newTestHandler(t, nil, func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Unexpected upstream request")
+	})
+
+	rec, body := getProbe(t, handler.HandleHealth, "/health")
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", rec.Code)
+	}
+	if _, ok := body["upstream"]; ok {
+		t.Errorf("Expected no upstream status by default, got %v", body)
+	}
+}
+
+func TestUpstreamHealthy(t *testing.T) {
+	handler := newTestHandler(t, nil, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	breakers := proxy.NewCircuitBreakers(2, 1, time.Minute)
+	handler.SetCircuitBreakers(breakers)
+
+	if healthy := handler.UpstreamHealthy(); healthy != nil {
+		t.Fatalf("Expected unknown health before any check, got %v", *healthy)
+	}
+
+	// A readiness check records the upstream as reachable
+	getProbe(t, handler.HandleReadyz, "/readyz")
+	if healthy := handler.UpstreamHealthy(); healthy == nil || !*healthy {
+		t.Fatalf("Expected healthy after a successful check, got %v", healthy)
+	}
+
+	// An open circuit overrides the last check
+	cb := breakers.Get("custom")
+	cb.RecordFailure()
+	cb.RecordFailure()
+	if healthy := handler.UpstreamHealthy(); healthy == nil || *healthy {
+		t.Errorf("Expected unhealthy with an open circuit, got %v", healthy)
+	}
+}
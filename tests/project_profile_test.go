@@ -0,0 +1,115 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jedarden/clasp/internal/setup"
+)
+
+// writeProjectFile writes content to a path relative to root, creating parents.
+func writeProjectFile(t *testing.T, root, rel, content string) {
+	t.Helper()
+	path := filepath.Join(root, rel)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+}
+
+func TestFindProjectProfile_NearestWins(t *testing.T) {
+	root := t.TempDir()
+	writeProjectFile(t, root, ".clasp/profile", "outer\n")
+	writeProjectFile(t, root, "repo/.clasp/profile", "inner\n")
+	deep := filepath.Join(root, "repo", "src", "pkg")
+	if err := os.MkdirAll(deep, 0o755); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+
+	project, err := setup.FindProjectProfile(deep)
+	if err != nil {
+		t.Fatalf("FindProjectProfile failed: %v", err)
+	}
+	if project == nil || project.Profile != "inner" {
+		t.Fatalf("Expected nearest profile 'inner', got %+v", project)
+	}
+	if project.Dir != filepath.Join(root, "repo") {
+		t.Errorf("Dir = %s, want %s", project.Dir, filepath.Join(root, "repo"))
+	}
+
+	// Outside the inner repo, the outer profile applies
+	project, err = setup.FindProjectProfile(root)
+	if err != nil || project == nil || project.Profile != "outer" {
+		t.Errorf("Expected 'outer' at the root, got %+v, %v", project, err)
+	}
+}
+
+func TestFindProjectProfile_JSONOverrides(t *testing.T) {
+	root := t.TempDir()
+	writeProjectFile(t, root, ".clasp/profile", "work")
+	writeProjectFile(t, root, ".clasp.json", `{"provider":"openrouter","model":"openai/gpt-4o"}`)
+
+	project, err := setup.FindProjectProfile(root)
+	if err != nil {
+		t.Fatalf("FindProjectProfile failed: %v", err)
+	}
+	if project.Profile != "work" || project.Provider != "openrouter" || project.Model != "openai/gpt-4o" {
+		t.Errorf("Unexpected project profile: %+v", project)
+	}
+
+	// A profile named in .clasp.json wins over .clasp/profile
+	writeProjectFile(t, root, ".clasp.json", `{"profile":"personal"}`)
+	project, err = setup.FindProjectProfile(root)
+	if err != nil || project.Profile != "personal" {
+		t.Errorf("Expected 'personal', got %+v, %v", project, err)
+	}
+
+	writeProjectFile(t, root, ".clasp.json", `{not json`)
+	if _, err := setup.FindProjectProfile(root); err == nil {
+		t.Error("Expected an error for malformed .clasp.json")
+	}
+}
+
+func TestResolveProjectProfile(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	pm := setup.NewProfileManager()
+	for _, p := range []*setup.Profile{
+		{Name: "work", Provider: "openai", APIKey: "sk-work", DefaultModel: "gpt-4o"},
+		{Name: "personal", Provider: "deepseek", APIKey: "ds-key", DefaultModel: "deepseek-chat"},
+	} {
+		if err := pm.CreateProfile(p); err != nil {
+			t.Fatalf("CreateProfile failed: %v", err)
+		}
+	}
+	if err := pm.SetActiveProfile("personal"); err != nil {
+		t.Fatalf("SetActiveProfile failed: %v", err)
+	}
+
+	// Named profile with a model override
+	profile, err := pm.ResolveProjectProfile(&setup.ProjectProfile{Profile: "work", Model: "gpt-4o-mini"})
+	if err != nil {
+		t.Fatalf("ResolveProjectProfile failed: %v", err)
+	}
+	if profile.Name != "work" || profile.DefaultModel != "gpt-4o-mini" || profile.APIKey != "sk-work" {
+		t.Errorf("Unexpected profile: %+v", profile)
+	}
+
+	// No name falls back to the active profile; a different provider drops its key
+	profile, err = pm.ResolveProjectProfile(&setup.ProjectProfile{Provider: "openrouter"})
+	if err != nil {
+		t.Fatalf("ResolveProjectProfile failed: %v", err)
+	}
+	if profile.Name != "personal" || profile.Provider != "openrouter" {
+		t.Errorf("Unexpected profile: %+v", profile)
+	}
+	if profile.APIKey != "" || profile.APIKeyEnv != "OPENROUTER_API_KEY" {
+		t.Errorf("Expected key from OPENROUTER_API_KEY, got key=%q env=%q", profile.APIKey, profile.APIKeyEnv)
+	}
+
+	if _, err := pm.ResolveProjectProfile(&setup.ProjectProfile{Profile: "missing"}); err == nil {
+		t.Error("Expected an error for a missing profile")
+	}
+}
@@ -0,0 +1,184 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jedarden/clasp/internal/config"
+	"github.com/jedarden/clasp/internal/proxy"
+	"github.com/jedarden/clasp/pkg/models"
+)
+
+// newQueuedServer creates a server with request queuing for a mock upstream.
+func newQueuedServer(t *testing.T, upstream http.HandlerFunc, configure func(*config.Config)) *proxy.Server {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	cfg, _ := newMockUpstream(t, upstream)
+	cfg.QueueEnabled = true
+	cfg.QueueDLQDir = t.TempDir()
+	configure(cfg)
+	server, err := proxy.NewServer(cfg)
+	if err != nil {
+		t.Fatalf("NewServer failed: %v", err)
+	}
+	t.Cleanup(func() { _ = server.Shutdown() })
+	return server
+}
+
+// upstreamPrompt returns the last user message of a Chat Completions request.
+func upstreamPrompt(r *http.Request) string {
+	var body struct {
+		Messages []struct {
+			Content string `json:"content"`
+		} `json:"messages"`
+	}
+	_ = json.NewDecoder(r.Body).Decode(&body)
+	if len(body.Messages) == 0 {
+		return ""
+	}
+	return body.Messages[len(body.Messages)-1].Content
+}
+
+// postQueued sends a request with the given prompt and priority to routes.
+func postQueued(t *testing.T, routes http.Handler, prompt, priority string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := simpleRequest()
+	req.Messages = []models.AnthropicMessage{{Role: "user", Content: prompt}}
+	body, _ := json.Marshal(req)
+	httpReq := httptest.NewRequest(http.MethodPost, "/v1/messages", bytes.NewReader(body))
+	httpReq.Header.Set("Content-Type", "application/json")
+	if priority != "" {
+		httpReq.Header.Set(proxy.PriorityHeader, priority)
+	}
+	rec := httptest.NewRecorder()
+	routes.ServeHTTP(rec, httpReq)
+	return rec
+}
+
+// queueMetrics returns the queue section of the server's /metrics.
+func queueMetrics(t *testing.T, server *proxy.Server) map[string]interface{} {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	server.GetHandler().HandleMetrics(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	var metrics map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &metrics); err != nil {
+		t.Fatalf("Failed to parse metrics: %v", err)
+	}
+	stats, _ := metrics["queue"].(map[string]interface{})
+	return stats
+}
+
+// waitForQueueLength waits until n requests are waiting in the queue.
+func waitForQueueLength(t *testing.T, server *proxy.Server, n int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for queueMetrics(t, server)["length"] != float64(n) {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %d queued requests, got %v", n, queueMetrics(t, server))
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestQueue_ServesHighPriorityFirst(t *testing.T) {
+	served := make(chan string, 3)
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	server := newQueuedServer(t, func(w http.ResponseWriter, r *http.Request) {
+		prompt := upstreamPrompt(r)
+		if prompt == "first" {
+			close(entered)
+			<-release
+		}
+		served <- prompt
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(chatCompletionOK))
+	}, func(cfg *config.Config) {
+		cfg.MaxConcurrent = 1
+	})
+	routes := server.Routes()
+
+	codes := make(chan int, 3)
+	send := func(prompt, priority string) {
+		go func() { codes <- postQueued(t, routes, prompt, priority).Code }()
+	}
+
+	// The only worker is busy with the first request while the others queue up
+	send("first", "")
+	<-entered
+	send("batch", "low")
+	waitForQueueLength(t, server, 1)
+	send("interactive", "high")
+	waitForQueueLength(t, server, 2)
+	close(release)
+
+	for i := 0; i < 3; i++ {
+		if code := <-codes; code != http.StatusOK {
+			t.Errorf("Expected status 200, got %d", code)
+		}
+	}
+	close(served)
+	var order []string
+	for prompt := range served {
+		order = append(order, prompt)
+	}
+	if len(order) != 3 || order[0] != "first" || order[1] != "interactive" || order[2] != "batch" {
+		t.Errorf("Expected the high priority request before the low priority one, got %v", order)
+	}
+}
+
+func TestQueue_ReplaysPersistedRequestsOnRestart(t *testing.T) {
+	dir := t.TempDir()
+
+	// A previous run queued a request and stopped before serving it
+	previous := proxy.NewRequestQueue(&proxy.QueueConfig{
+		Enabled:    true,
+		MaxSize:    10,
+		MaxWait:    time.Minute,
+		PersistDir: dir,
+	})
+	req := simpleRequest()
+	req.Messages = []models.AnthropicMessage{{Role: "user", Content: "queued before restart"}}
+	body, _ := json.Marshal(req)
+	if _, err := previous.EnqueueWithPriority(body, proxy.PriorityHigh); err != nil {
+		t.Fatalf("Failed to enqueue: %v", err)
+	}
+	previous.Close()
+
+	served := make(chan string, 1)
+	newQueuedServer(t, func(w http.ResponseWriter, r *http.Request) {
+		served <- upstreamPrompt(r)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(chatCompletionOK))
+	}, func(cfg *config.Config) {
+		cfg.QueuePersist = true
+		cfg.QueuePersistDir = dir
+		cfg.QueueMaxWaitSeconds = 60
+	})
+
+	select {
+	case prompt := <-served:
+		if prompt != "queued before restart" {
+			t.Errorf("Expected the persisted request upstream, got %q", prompt)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Persisted request was not replayed")
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+		if len(files) == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the replayed request to be removed from disk, got %v", files)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -3,6 +3,8 @@ package tests
 import (
 	"bytes"
 	"context"
+	"os"
+	"path/filepath"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -70,6 +72,178 @@ func TestRequestQueue_EnqueueDequeue(t *testing.T) {
 	}
 }
 
+func TestRequestQueue_Priority(t *testing.T) {
+	config := &proxy.QueueConfig{
+		Enabled: true,
+		MaxSize: 10,
+		MaxWait: 5 * time.Second,
+	}
+
+	queue := proxy.NewRequestQueue(config)
+	defer queue.Close()
+
+	enqueued := []struct {
+		body     string
+		priority proxy.QueuePriority
+	}{
+		{"low-1", proxy.PriorityLow},
+		{"normal-1", proxy.PriorityNormal},
+		{"high-1", proxy.PriorityHigh},
+		{"low-2", proxy.PriorityLow},
+		{"high-2", proxy.PriorityHigh},
+		{"normal-2", proxy.PriorityNormal},
+	}
+	for _, e := range enqueued {
+		if _, err := queue.EnqueueWithPriority([]byte(e.body), e.priority); err != nil {
+			t.Fatalf("Failed to enqueue %s: %v", e.body, err)
+		}
+	}
+	// Enqueue defaults to normal priority
+	if _, err := queue.Enqueue([]byte("normal-3")); err != nil {
+		t.Fatalf("Failed to enqueue: %v", err)
+	}
+
+	stats := queue.Stats()
+	for priority, want := range map[string]int{"high": 2, "normal": 3, "low": 2} {
+		if got := stats.LengthByPriority[priority]; got != want {
+			t.Errorf("Expected %d %s priority requests queued, got %d", want, priority, got)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	want := []string{"high-1", "high-2", "normal-1", "normal-2", "normal-3", "low-1", "low-2"}
+	for i, body := range want {
+		req, err := queue.Dequeue(ctx)
+		if err != nil {
+			t.Fatalf("Failed to dequeue request %d: %v", i, err)
+		}
+		if string(req.Body) != body {
+			t.Errorf("Dequeue %d: expected %s, got %s", i, body, req.Body)
+		}
+	}
+	if queue.Stats().LengthByPriority["normal"] != 0 {
+		t.Error("Expected the queue to be empty")
+	}
+}
+
+func TestParsePriority(t *testing.T) {
+	tests := map[string]proxy.QueuePriority{
+		"high":   proxy.PriorityHigh,
+		" HIGH ": proxy.PriorityHigh,
+		"low":    proxy.PriorityLow,
+		"normal": proxy.PriorityNormal,
+		"":       proxy.PriorityNormal,
+		"urgent": proxy.PriorityNormal,
+	}
+	for value, want := range tests {
+		if got := proxy.ParsePriority(value); got != want {
+			t.Errorf("ParsePriority(%q) = %s, want %s", value, got, want)
+		}
+	}
+}
+
+func TestRequestQueue_Persistence(t *testing.T) {
+	dir := t.TempDir()
+	config := &proxy.QueueConfig{
+		Enabled:    true,
+		MaxSize:    10,
+		MaxWait:    5 * time.Second,
+		PersistDir: dir,
+	}
+
+	queue := proxy.NewRequestQueue(config)
+	if _, err := queue.EnqueueWithPriority([]byte(`{"n":1}`), proxy.PriorityLow); err != nil {
+		t.Fatalf("Failed to enqueue: %v", err)
+	}
+	if _, err := queue.EnqueueWithPriority([]byte(`{"n":2}`), proxy.PriorityHigh); err != nil {
+		t.Fatalf("Failed to enqueue: %v", err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil || len(files) != 2 {
+		t.Fatalf("Expected 2 persisted requests, got %v (%v)", files, err)
+	}
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			t.Fatalf("Failed to stat %s: %v", file, err)
+		}
+		if perm := info.Mode().Perm(); perm != 0o600 {
+			t.Errorf("Expected %s to have mode 0600, got %o", file, perm)
+		}
+	}
+
+	// Simulate a restart
+	queue.Close()
+	restarted := proxy.NewRequestQueue(config)
+	defer restarted.Close()
+
+	if restarted.Len() != 2 {
+		t.Fatalf("Expected 2 replayed requests, got %d", restarted.Len())
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	for _, want := range []string{`{"n":2}`, `{"n":1}`} {
+		req, err := restarted.Dequeue(ctx)
+		if err != nil {
+			t.Fatalf("Failed to dequeue: %v", err)
+		}
+		if string(req.Body) != want {
+			t.Errorf("Expected %s, got %s", want, req.Body)
+		}
+		// Requests in progress stay persisted until they complete
+		if files, _ := filepath.Glob(filepath.Join(dir, "*.json")); len(files) == 0 {
+			t.Error("Expected a dequeued request to stay persisted until it completes")
+		}
+		restarted.Complete(req, proxy.QueueResult{})
+	}
+
+	// Completed requests are no longer persisted
+	if files, _ := filepath.Glob(filepath.Join(dir, "*.json")); len(files) != 0 {
+		t.Errorf("Expected no persisted requests after completion, got %v", files)
+	}
+}
+
+func TestRequestQueue_PersistenceExpiry(t *testing.T) {
+	dir := t.TempDir()
+	config := &proxy.QueueConfig{
+		Enabled:    true,
+		MaxSize:    10,
+		MaxWait:    100 * time.Millisecond,
+		PersistDir: dir,
+	}
+
+	queue := proxy.NewRequestQueue(config)
+	if _, err := queue.Enqueue([]byte("stale")); err != nil {
+		t.Fatalf("Failed to enqueue: %v", err)
+	}
+	queue.Close()
+
+	time.Sleep(150 * time.Millisecond)
+	restarted := proxy.NewRequestQueue(config)
+	if _, err := restarted.Enqueue([]byte("fresh")); err != nil {
+		t.Fatalf("Failed to enqueue: %v", err)
+	}
+	restarted.Close()
+
+	// Only the request that hasn't exceeded MaxWait is replayed
+	replayed := proxy.NewRequestQueue(config)
+	defer replayed.Close()
+	if replayed.Len() != 1 {
+		t.Fatalf("Expected 1 replayed request, got %d", replayed.Len())
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	req, err := replayed.Dequeue(ctx)
+	if err != nil {
+		t.Fatalf("Failed to dequeue: %v", err)
+	}
+	if string(req.Body) != "fresh" {
+		t.Errorf("Expected the fresh request, got %s", req.Body)
+	}
+}
+
 func TestRequestQueue_MaxSize(t *testing.T) {
 	config := &proxy.QueueConfig{
 		Enabled:    true,
@@ -420,3 +594,44 @@ func TestQueueStats(t *testing.T) {
 		t.Error("Expected paused=true after pause")
 	}
 }
+
+func TestCircuitBreaker_HalfOpenProbeLimit(t *testing.T) {
+	cb := proxy.NewCircuitBreaker(1, 3, 50*time.Millisecond)
+	cb.SetHalfOpenMax(2)
+
+	cb.RecordFailure()
+	time.Sleep(60 * time.Millisecond)
+
+	// A burst of requests arrives as the breaker goes half-open
+	var admitted int32
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if cb.Allow() {
+				atomic.AddInt32(&admitted, 1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if admitted != 2 {
+		t.Errorf("Expected 2 probes admitted, got %d", admitted)
+	}
+	if cb.State() != "half-open" {
+		t.Errorf("Expected half-open state, got %s", cb.State())
+	}
+	if cb.HalfOpenProbes() != 2 || cb.HalfOpenRejected() != 8 {
+		t.Errorf("Expected 2 probes and 8 rejected, got %d and %d", cb.HalfOpenProbes(), cb.HalfOpenRejected())
+	}
+
+	// A successful probe frees its slot for the next one
+	cb.RecordSuccess()
+	if !cb.Allow() {
+		t.Error("Expected a probe to be admitted after one succeeded")
+	}
+	if cb.Allow() {
+		t.Error("Expected the probe limit to apply again")
+	}
+}
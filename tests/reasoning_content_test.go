@@ -0,0 +1,77 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/jedarden/clasp/internal/config"
+	"github.com/jedarden/clasp/pkg/models"
+)
+
+// deepSeekReasoningResponse is a deepseek-reasoner chat completion with
+// reasoning_content alongside the answer.
+const deepSeekReasoningResponse = `{
+	"id": "chatcmpl-ds1",
+	"object": "chat.completion",
+	"model": "deepseek-reasoner",
+	"choices": [{
+		"index": 0,
+		"message": {
+			"role": "assistant",
+			"content": "The answer is 4.",
+			"reasoning_content": "2 + 2 means adding two and two, which is 4."
+		},
+		"finish_reason": "stop"
+	}],
+	"usage": {"prompt_tokens": 12, "completion_tokens": 30, "total_tokens": 42}
+}`
+
+func reasoningContentResponse(t *testing.T, model string, always bool) models.AnthropicResponse {
+	t.Helper()
+	handler := newTestHandler(t, func(cfg *config.Config) {
+		cfg.DefaultModel = model
+		cfg.ReasoningContent = always
+	}, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(deepSeekReasoningResponse))
+	})
+
+	rec := postMessages(t, handler, simpleRequest())
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp models.AnthropicResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	return resp
+}
+
+func TestNonStreaming_ReasoningContentAsThinking(t *testing.T) {
+	resp := reasoningContentResponse(t, "deepseek-reasoner", false)
+
+	if len(resp.Content) != 2 {
+		t.Fatalf("Expected thinking and text blocks, got %+v", resp.Content)
+	}
+	if resp.Content[0].Type != "thinking" || resp.Content[0].Thinking != "2 + 2 means adding two and two, which is 4." {
+		t.Errorf("Expected the thinking block first, got %+v", resp.Content[0])
+	}
+	if resp.Content[1].Type != "text" || resp.Content[1].Text != "The answer is 4." {
+		t.Errorf("Expected the text block second, got %+v", resp.Content[1])
+	}
+}
+
+func TestNonStreaming_ReasoningContentGatedByModel(t *testing.T) {
+	// deepseek-chat isn't a reasoning model, so reasoning_content is ignored
+	resp := reasoningContentResponse(t, "deepseek-chat", false)
+	if len(resp.Content) != 1 || resp.Content[0].Type != "text" {
+		t.Errorf("Expected only a text block, got %+v", resp.Content)
+	}
+
+	// CLASP_REASONING_CONTENT returns it for any model
+	resp = reasoningContentResponse(t, "deepseek-chat", true)
+	if len(resp.Content) != 2 || resp.Content[0].Type != "thinking" {
+		t.Errorf("Expected a leading thinking block, got %+v", resp.Content)
+	}
+}
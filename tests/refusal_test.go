@@ -0,0 +1,70 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/jedarden/clasp/pkg/models"
+)
+
+func TestNonStreaming_ContentFilterRefusal(t *testing.T) {
+	handler := newTestHandler(t, nil, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"chatcmpl-1","choices":[{"message":{"role":"assistant","content":"Here is"},"finish_reason":"content_filter"}],"usage":{"prompt_tokens":10,"completion_tokens":2}}`))
+	})
+
+	rec := postMessages(t, handler, simpleRequest())
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp models.AnthropicResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if resp.StopReason != "end_turn" {
+		t.Errorf("Expected stop_reason end_turn, got %q", resp.StopReason)
+	}
+	if len(resp.Content) != 2 || resp.Content[0].Type != "text" || resp.Content[1].Type != "refusal" {
+		t.Fatalf("Expected text and refusal blocks, got %+v", resp.Content)
+	}
+	if !strings.Contains(resp.Content[1].Text, "content filter") {
+		t.Errorf("Expected the refusal block to explain the stop, got %q", resp.Content[1].Text)
+	}
+}
+
+func TestStreaming_ContentFilterRefusal(t *testing.T) {
+	handler := newTestHandler(t, nil, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte("data: {\"choices\":[{\"delta\":{\"content\":\"Here is\"}}]}\n\n" +
+			"data: {\"choices\":[{\"delta\":{},\"finish_reason\":\"content_filter\"}]}\n\n" +
+			"data: [DONE]\n\n"))
+	})
+
+	rec := postMessages(t, handler, streamingRequest())
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var refusal, stopReason string
+	for _, event := range parseSSEEvents(t, rec.Body.String()) {
+		switch event.Type {
+		case "content_block_start":
+			if block, ok := event.Data["content_block"].(map[string]interface{}); ok && block["type"] == "refusal" {
+				refusal, _ = block["text"].(string)
+			}
+		case "message_delta":
+			if delta, ok := event.Data["delta"].(map[string]interface{}); ok {
+				stopReason, _ = delta["stop_reason"].(string)
+			}
+		}
+	}
+	if !strings.Contains(refusal, "content filter") {
+		t.Errorf("Expected a refusal block explaining the stop, got:\n%s", rec.Body.String())
+	}
+	if stopReason != "end_turn" {
+		t.Errorf("Expected stop_reason end_turn, got %q", stopReason)
+	}
+}
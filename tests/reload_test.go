@@ -0,0 +1,307 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/jedarden/clasp/internal/config"
+	"github.com/jedarden/clasp/internal/proxy"
+)
+
+// modelRecorder is a mock upstream that records the model of each request.
+type modelRecorder struct {
+	mu     sync.Mutex
+	models []string
+}
+
+func (m *modelRecorder) handle(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Model string `json:"model"`
+	}
+	_ = json.NewDecoder(r.Body).Decode(&body)
+	m.mu.Lock()
+	m.models = append(m.models, body.Model)
+	m.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintf(w, `{"id":"chatcmpl-1","object":"chat.completion","model":%q,"choices":[{"index":0,"message":{"role":"assistant","content":"Hi"},"finish_reason":"stop"}],"usage":{"prompt_tokens":5,"completion_tokens":1,"total_tokens":6}}`, body.Model)
+}
+
+func (m *modelRecorder) last() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.models) == 0 {
+		return ""
+	}
+	return m.models[len(m.models)-1]
+}
+
+// freePort returns a TCP port that is currently unused.
+func freePort(t *testing.T) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	defer ln.Close()
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+// signalSelf sends sig to the test process.
+func signalSelf(sig os.Signal) error {
+	process, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		return err
+	}
+	return process.Signal(sig)
+}
+
+func TestServer_ApplyConfig(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	upstream := &modelRecorder{}
+	cfg, _ := newMockUpstream(t, upstream.handle)
+	cfg.ModelSonnet = "gpt-4o"
+
+	server, err := proxy.NewServer(cfg)
+	if err != nil {
+		t.Fatalf("NewServer failed: %v", err)
+	}
+	oldHandler := server.GetHandler()
+	if rec := postMessages(t, oldHandler, simpleRequest()); rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if upstream.last() != "gpt-4o" {
+		t.Fatalf("Expected gpt-4o upstream, got %q", upstream.last())
+	}
+
+	newCfg := *cfg
+	newCfg.ModelSonnet = "gpt-4o-mini"
+	newCfg.AuthEnabled = true // Needs a restart, so it's ignored
+	if err := server.ApplyConfig(&newCfg); err != nil {
+		t.Fatalf("ApplyConfig failed: %v", err)
+	}
+	if newCfg.AuthEnabled {
+		t.Error("Expected restart-only setting to keep its current value")
+	}
+
+	handler := server.GetHandler()
+	if handler == oldHandler {
+		t.Fatal("Expected a new handler after reload")
+	}
+	if rec := postMessages(t, handler, simpleRequest()); rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if upstream.last() != "gpt-4o-mini" {
+		t.Errorf("Expected gpt-4o-mini upstream after reload, got %q", upstream.last())
+	}
+
+	// Metrics carry over across the reload
+	if total := handler.GetMetrics().TotalRequests; total != 2 {
+		t.Errorf("TotalRequests = %d, want 2", total)
+	}
+
+	// The old handler keeps its configuration for requests already in flight
+	postMessages(t, oldHandler, simpleRequest())
+	if upstream.last() != "gpt-4o" {
+		t.Errorf("Expected old handler to keep gpt-4o, got %q", upstream.last())
+	}
+}
+
+func TestServer_ApplyConfigFailureChangesNothing(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	var mu sync.Mutex
+	var jsonMode []bool
+	cfg, _ := newMockUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			ResponseFormat *json.RawMessage `json:"response_format"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		mu.Lock()
+		jsonMode = append(jsonMode, body.ResponseFormat != nil)
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(chatCompletionOK))
+	})
+
+	server, err := proxy.NewServer(cfg)
+	if err != nil {
+		t.Fatalf("NewServer failed: %v", err)
+	}
+	oldHandler := server.GetHandler()
+
+	// Valid translation settings followed by an invalid one
+	newCfg := *cfg
+	newCfg.ForceJSON = true
+	newCfg.FinishReasonMap = map[string]string{"eos": "done"}
+	if err := server.ApplyConfig(&newCfg); err == nil {
+		t.Fatal("Expected ApplyConfig to reject the invalid finish reason map")
+	}
+	if server.GetHandler() != oldHandler {
+		t.Fatal("Expected the current handler to stay after a failed reload")
+	}
+	postMessages(t, server.GetHandler(), simpleRequest())
+
+	// A successful reload only changes requests on the new handler
+	newCfg.FinishReasonMap = nil
+	if err := server.ApplyConfig(&newCfg); err != nil {
+		t.Fatalf("ApplyConfig failed: %v", err)
+	}
+	postMessages(t, oldHandler, simpleRequest())
+	postMessages(t, server.GetHandler(), simpleRequest())
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(jsonMode) != 3 || jsonMode[0] || jsonMode[1] || !jsonMode[2] {
+		t.Errorf("Expected JSON mode only on the reloaded handler, got %v", jsonMode)
+	}
+}
+
+func TestServer_ApplyConfigKeepsStickyPins(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	var primaryDown atomic.Bool
+	var primaryHits atomic.Int64
+	primaryDown.Store(true)
+
+	fallback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(chatCompletionOK))
+	}))
+	t.Cleanup(fallback.Close)
+
+	cfg, _ := newMockUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		primaryHits.Add(1)
+		if primaryDown.Load() {
+			w.WriteHeader(529)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(chatCompletionOK))
+	})
+	cfg.FallbackEnabled = true
+	cfg.FallbackProvider = config.ProviderCustom
+	cfg.FallbackBaseURL = fallback.URL
+	cfg.FallbackAPIKey = "fallback-key"
+	cfg.FallbackModel = "gpt-4o-mini"
+	cfg.StickyRoutingEnabled = true
+
+	server, err := proxy.NewServer(cfg)
+	if err != nil {
+		t.Fatalf("NewServer failed: %v", err)
+	}
+	conversation := map[string]string{"X-CLASP-Conversation-ID": "conv-1"}
+	if rec := postMessagesWithHeaders(t, server.GetHandler(), simpleRequest(), conversation); rec.Header().Get("X-CLASP-Fallback") == "" {
+		t.Fatalf("Expected the fallback to serve the first turn, got %d", rec.Code)
+	}
+
+	newCfg := *cfg
+	newCfg.ModelSonnet = "gpt-4o"
+	if err := server.ApplyConfig(&newCfg); err != nil {
+		t.Fatalf("ApplyConfig failed: %v", err)
+	}
+
+	// The primary has recovered, but the conversation stays pinned
+	primaryDown.Store(false)
+	primaryBefore := primaryHits.Load()
+	rec := postMessagesWithHeaders(t, server.GetHandler(), simpleRequest(), conversation)
+	if rec.Code != http.StatusOK || rec.Header().Get("X-CLASP-Fallback") == "" {
+		t.Errorf("Expected the pinned fallback after reload, got %d (fallback header %q)", rec.Code, rec.Header().Get("X-CLASP-Fallback"))
+	}
+	if primaryHits.Load() != primaryBefore {
+		t.Error("Expected the pinned conversation not to reach the primary after reload")
+	}
+}
+
+func TestServer_ReloadOnSIGHUP(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	upstream := &modelRecorder{}
+	cfg, _ := newMockUpstream(t, upstream.handle)
+	cfg.Port = freePort(t)
+	cfg.ModelSonnet = "gpt-4o"
+
+	server, err := proxy.NewServer(cfg)
+	if err != nil {
+		t.Fatalf("NewServer failed: %v", err)
+	}
+
+	var mu sync.Mutex
+	sonnetModel := "gpt-4o"
+	server.SetConfigLoader(func() (*config.Config, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		reloaded := *cfg
+		reloaded.ModelSonnet = sonnetModel
+		return &reloaded, nil
+	})
+
+	done := make(chan error, 1)
+	go func() { done <- server.Start() }()
+	t.Cleanup(func() {
+		select {
+		case <-done:
+			return // Already stopped; the signal would go unhandled
+		default:
+		}
+		_ = signalSelf(syscall.SIGTERM)
+		select {
+		case <-done:
+		case <-time.After(15 * time.Second):
+			t.Error("Server did not shut down")
+		}
+	})
+
+	baseURL := fmt.Sprintf("http://127.0.0.1:%d", server.GetPort())
+	// The server handles signals once it's serving
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		resp, err := http.Get(baseURL + "/health")
+		if err == nil {
+			resp.Body.Close()
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Server did not start: %v", err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	send := func() string {
+		body, _ := json.Marshal(simpleRequest())
+		resp, err := http.Post(baseURL+"/v1/messages", "application/json", strings.NewReader(string(body)))
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected 200, got %d", resp.StatusCode)
+		}
+		return upstream.last()
+	}
+	if model := send(); model != "gpt-4o" {
+		t.Fatalf("Expected gpt-4o before reload, got %q", model)
+	}
+
+	mu.Lock()
+	sonnetModel = "gpt-4o-mini"
+	mu.Unlock()
+	if err := signalSelf(syscall.SIGHUP); err != nil {
+		t.Fatalf("Sending SIGHUP failed: %v", err)
+	}
+
+	deadline = time.Now().Add(5 * time.Second)
+	for send() != "gpt-4o-mini" {
+		if time.Now().After(deadline) {
+			t.Fatal("Requests did not use the reloaded model mapping")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
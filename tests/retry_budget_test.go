@@ -0,0 +1,115 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jedarden/clasp/internal/config"
+	"github.com/jedarden/clasp/internal/proxy"
+)
+
+func TestRetryBudget_ThrottlesRetriesUnderOutage(t *testing.T) {
+	var attempts int32
+	handler := newTestHandler(t, func(cfg *config.Config) {
+		cfg.RetryMax = 3
+		cfg.RetryBaseDelayMs = 1
+		cfg.RetryMaxDelayMs = 1
+	}, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	handler.SetRetryBudget(proxy.NewRetryBudget(0.1))
+
+	const requests = 100
+	for i := 0; i < requests; i++ {
+		if rec := postMessages(t, handler, simpleRequest()); rec.Code == http.StatusOK {
+			t.Fatalf("Expected request %d to fail", i)
+		}
+	}
+
+	// Without a budget every request would make 3 attempts. With it, retries
+	// are capped at the initial burst of 10 plus 10% of requests.
+	retries := int(atomic.LoadInt32(&attempts)) - requests
+	if retries > 10+requests/10 {
+		t.Errorf("Expected at most %d retries, got %d", 10+requests/10, retries)
+	}
+	if retries < requests/10 {
+		t.Errorf("Expected the budget to allow some retries, got %d", retries)
+	}
+
+	budget, _ := getMetrics(t, handler)["retry_budget"].(map[string]interface{})
+	if budget == nil {
+		t.Fatal("Expected retry_budget in /metrics")
+	}
+	if got := budget["retries"]; got != float64(retries) {
+		t.Errorf("Expected retry_budget.retries=%d, got %v", retries, got)
+	}
+	if throttled, _ := budget["throttled"].(float64); throttled == 0 {
+		t.Error("Expected throttled retries once the budget was exhausted")
+	}
+	if got := budget["requests"]; got != float64(requests) {
+		t.Errorf("Expected retry_budget.requests=%d, got %v", requests, got)
+	}
+
+	// Once depleted, a failing request fails fast after a single attempt
+	before := atomic.LoadInt32(&attempts)
+	postMessages(t, handler, simpleRequest())
+	if got := atomic.LoadInt32(&attempts) - before; got != 1 {
+		t.Errorf("Expected 1 attempt with an exhausted budget, got %d", got)
+	}
+}
+
+func TestRetryBudget_Refills(t *testing.T) {
+	budget := proxy.NewRetryBudget(0.5)
+	for budget.AllowRetry() {
+	}
+	if budget.AllowRetry() {
+		t.Fatal("Expected an exhausted budget to refuse retries")
+	}
+
+	budget.RecordRequest()
+	budget.RecordRequest()
+	if !budget.AllowRetry() {
+		t.Error("Expected two requests at ratio 0.5 to fund one retry")
+	}
+	if budget.AllowRetry() {
+		t.Error("Expected only one retry to be funded")
+	}
+
+	stats := budget.Stats()
+	if stats.Retries != 11 || stats.Throttled != 3 || stats.Requests != 2 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestRetryBudget_LimitsQueueRetries(t *testing.T) {
+	queue, dlq, _ := newDLQQueue(t, 3)
+	budget := proxy.NewRetryBudget(0)
+	for budget.AllowRetry() {
+	}
+	queue.SetRetryBudget(budget)
+
+	if _, err := queue.EnqueueWithPriority([]byte(`{"n":1}`), proxy.PriorityNormal); err != nil {
+		t.Fatalf("Failed to enqueue: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	req, err := queue.Dequeue(ctx)
+	if err != nil {
+		t.Fatalf("Failed to dequeue: %v", err)
+	}
+	if queue.Retry(req, errors.New("upstream returned status 503")) {
+		t.Error("Expected the retry to be refused with an exhausted budget")
+	}
+	letters, err := dlq.List()
+	if err != nil {
+		t.Fatalf("Failed to list dead letters: %v", err)
+	}
+	if len(letters) != 1 {
+		t.Errorf("Expected the request to be dead-lettered, got %d dead letters", len(letters))
+	}
+}
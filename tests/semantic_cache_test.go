@@ -0,0 +1,177 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jedarden/clasp/internal/proxy"
+	"github.com/jedarden/clasp/pkg/models"
+)
+
+// stubEmbeddings maps prompts to fixed vectors. The paraphrase has a cosine
+// similarity of about 0.98 with the original and the unrelated prompt about 0.2.
+var stubEmbeddings = map[string][]float64{
+	"What is the capital of France?": {1, 0, 0},
+	"Tell me France's capital city":  {0.98, 0.2, 0},
+	"How do I bake sourdough bread?": {0.2, 0, 1},
+}
+
+func stubEmbedder(t *testing.T) proxy.Embedder {
+	return proxy.EmbedderFunc(func(ctx context.Context, text string) ([]float64, error) {
+		vector, ok := stubEmbeddings[text]
+		if !ok {
+			t.Errorf("Unexpected prompt embedded: %q", text)
+			return nil, fmt.Errorf("no stub embedding for %q", text)
+		}
+		return vector, nil
+	})
+}
+
+// newSemanticCacheHandler creates a handler with exact and semantic caches
+// for a mock upstream, counting upstream calls.
+func newSemanticCacheHandler(t *testing.T, threshold float64) (*proxy.Handler, *int32) {
+	t.Helper()
+	var calls int32
+	handler := newTestHandler(t, nil, func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"id":"chatcmpl-%d","choices":[{"message":{"role":"assistant","content":"Answer %d"},"finish_reason":"stop"}],"usage":{"prompt_tokens":10,"completion_tokens":2}}`, n, n)
+	})
+	handler.SetCache(proxy.NewRequestCache(100, time.Hour))
+	handler.SetSemanticCache(proxy.NewSemanticCache(100, time.Hour, threshold, stubEmbedder(t)))
+	return handler, &calls
+}
+
+func promptRequest(prompt string) *models.AnthropicRequest {
+	return &models.AnthropicRequest{
+		Model:     "claude-3-5-sonnet-20241022",
+		MaxTokens: 100,
+		Messages:  []models.AnthropicMessage{{Role: "user", Content: prompt}},
+	}
+}
+
+func TestSemanticCache_ParaphraseHitsAboveThreshold(t *testing.T) {
+	handler, calls := newSemanticCacheHandler(t, 0.95)
+
+	first := postMessages(t, handler, promptRequest("What is the capital of France?"))
+	if got := first.Header().Get("X-CLASP-Cache"); got != "MISS" {
+		t.Fatalf("Expected the first request to be a MISS, got %q", got)
+	}
+
+	rec := postMessages(t, handler, promptRequest("Tell me France's capital city"))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if got := rec.Header().Get("X-CLASP-Cache"); got != "HIT" {
+		t.Fatalf("Expected the paraphrase to be a HIT, got %q", got)
+	}
+	if got := rec.Header().Get("X-CLASP-Cache-Similarity"); got != "0.9798" {
+		t.Errorf("Expected X-CLASP-Cache-Similarity 0.9798, got %q", got)
+	}
+	if n := atomic.LoadInt32(calls); n != 1 {
+		t.Errorf("Expected 1 upstream call, got %d", n)
+	}
+
+	unrelated := postMessages(t, handler, promptRequest("How do I bake sourdough bread?"))
+	if got := unrelated.Header().Get("X-CLASP-Cache"); got != "MISS" {
+		t.Errorf("Expected an unrelated prompt to be a MISS, got %q", got)
+	}
+	if n := atomic.LoadInt32(calls); n != 2 {
+		t.Errorf("Expected 2 upstream calls, got %d", n)
+	}
+
+	semantic, _ := getMetrics(t, handler)["semantic_cache"].(map[string]interface{})
+	if semantic == nil {
+		t.Fatal("Expected semantic_cache in /metrics")
+	}
+	if semantic["hits"] != float64(1) || semantic["misses"] != float64(2) || semantic["size"] != float64(2) {
+		t.Errorf("Expected 1 semantic hit, 2 misses and 2 entries, got %v", semantic)
+	}
+	exact, _ := getMetrics(t, handler)["cache"].(map[string]interface{})
+	if exact["hits"] != float64(0) {
+		t.Errorf("Expected semantic hits to be counted apart from exact hits, got %v exact hits", exact["hits"])
+	}
+}
+
+func TestSemanticCache_ParaphraseMissesBelowThreshold(t *testing.T) {
+	handler, calls := newSemanticCacheHandler(t, 0.99)
+
+	postMessages(t, handler, promptRequest("What is the capital of France?"))
+	rec := postMessages(t, handler, promptRequest("Tell me France's capital city"))
+	if got := rec.Header().Get("X-CLASP-Cache"); got != "MISS" {
+		t.Errorf("Expected a MISS below the threshold, got %q", got)
+	}
+	if n := atomic.LoadInt32(calls); n != 2 {
+		t.Errorf("Expected 2 upstream calls, got %d", n)
+	}
+}
+
+func TestSemanticCache_ScopedToRestOfRequest(t *testing.T) {
+	handler, calls := newSemanticCacheHandler(t, 0.95)
+
+	postMessages(t, handler, promptRequest("What is the capital of France?"))
+	req := promptRequest("Tell me France's capital city")
+	req.System = "Answer in French."
+	if got := postMessages(t, handler, req).Header().Get("X-CLASP-Cache"); got != "MISS" {
+		t.Errorf("Expected a different system prompt to miss, got %q", got)
+	}
+	if n := atomic.LoadInt32(calls); n != 2 {
+		t.Errorf("Expected 2 upstream calls, got %d", n)
+	}
+}
+
+func TestSemanticCache_LRUEviction(t *testing.T) {
+	sc := proxy.NewSemanticCache(1, 0, 0.95, nil)
+	sc.Store("scope", []float64{1, 0}, &models.AnthropicResponse{ID: "first"})
+	sc.Store("scope", []float64{0, 1}, &models.AnthropicResponse{ID: "second"})
+
+	if _, _, found := sc.Lookup("scope", []float64{1, 0}); found {
+		t.Error("Expected the least recently used entry to be evicted")
+	}
+	if resp, _, found := sc.Lookup("scope", []float64{0, 2}); !found || resp.ID != "second" {
+		t.Errorf("Expected the newest entry to be kept, got %+v", resp)
+	}
+}
+
+func TestSemanticCache_ProviderEmbeddings(t *testing.T) {
+	var embeddingCalls, chatCalls int32
+	handler := newTestHandler(t, nil, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if strings.HasSuffix(r.URL.Path, "/embeddings") {
+			atomic.AddInt32(&embeddingCalls, 1)
+			var req struct {
+				Model string `json:"model"`
+				Input string `json:"input"`
+			}
+			_ = json.NewDecoder(r.Body).Decode(&req)
+			if req.Model != "text-embedding-3-small" {
+				t.Errorf("Expected the default embedding model, got %q", req.Model)
+			}
+			vector, _ := json.Marshal(stubEmbeddings[req.Input])
+			_, _ = fmt.Fprintf(w, `{"data":[{"embedding":%s}],"usage":{"prompt_tokens":7}}`, vector)
+			return
+		}
+		atomic.AddInt32(&chatCalls, 1)
+		_, _ = w.Write([]byte(`{"id":"chatcmpl-1","choices":[{"message":{"role":"assistant","content":"Paris"},"finish_reason":"stop"}],"usage":{"prompt_tokens":10,"completion_tokens":2}}`))
+	})
+	handler.SetCache(proxy.NewRequestCache(100, time.Hour))
+	handler.SetSemanticCache(proxy.NewSemanticCache(100, time.Hour, 0.95, nil))
+
+	postMessages(t, handler, promptRequest("What is the capital of France?"))
+	rec := postMessages(t, handler, promptRequest("Tell me France's capital city"))
+	if got := rec.Header().Get("X-CLASP-Cache"); got != "HIT" {
+		t.Fatalf("Expected the paraphrase to be a HIT, got %q", got)
+	}
+	if n := atomic.LoadInt32(&embeddingCalls); n != 2 {
+		t.Errorf("Expected 2 embeddings calls, got %d", n)
+	}
+	if n := atomic.LoadInt32(&chatCalls); n != 1 {
+		t.Errorf("Expected 1 chat call, got %d", n)
+	}
+}
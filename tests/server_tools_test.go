@@ -0,0 +1,93 @@
+package tests
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jedarden/clasp/internal/config"
+	"github.com/jedarden/clasp/internal/proxy"
+	"github.com/jedarden/clasp/pkg/models"
+)
+
+// serverToolsBody is a request with Anthropic server tools next to a regular
+// function tool.
+const serverToolsBody = `{
+	"model": "claude-3-5-sonnet-20241022",
+	"max_tokens": 1024,
+	"messages": [{"role": "user", "content": "What's new in Go?"}],
+	"mcp_servers": [{"type": "url", "url": "https://mcp.example.com/sse", "name": "docs"}],
+	"tools": [
+		{"type": "web_search_20250305", "name": "web_search", "max_uses": 5, "allowed_domains": ["go.dev"]},
+		{"type": "mcp_toolset", "name": "docs", "mcp_server_name": "docs"},
+		{"name": "get_weather", "description": "Get the weather", "input_schema": {"type": "object", "properties": {"city": {"type": "string"}}}}
+	]
+}`
+
+func postRawMessages(t *testing.T, handler *proxy.Handler, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	handler.HandleMessages(rec, req)
+	return rec
+}
+
+func TestServerTools_StrippedWhenTranslated(t *testing.T) {
+	var upstreamReq models.OpenAIRequest
+	handler := newTestHandler(t, nil, func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&upstreamReq)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(chatCompletionOK))
+	})
+
+	if rec := postRawMessages(t, handler, serverToolsBody); rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if len(upstreamReq.Tools) != 1 || upstreamReq.Tools[0].Function.Name != "get_weather" {
+		t.Errorf("Expected only the function tool upstream, got %+v", upstreamReq.Tools)
+	}
+}
+
+func TestServerTools_PreservedInPassthrough(t *testing.T) {
+	var upstreamBody map[string]interface{}
+	anthropic := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(data, &upstreamBody)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"msg_01","type":"message","role":"assistant","content":[{"type":"text","text":"Hi"}],"model":"claude-3-7-sonnet-20250219","stop_reason":"end_turn","usage":{"input_tokens":10,"output_tokens":2}}`))
+	}))
+	t.Cleanup(anthropic.Close)
+
+	handler := newTestHandler(t, func(cfg *config.Config) {
+		cfg.MultiProviderEnabled = true
+		cfg.TierSonnet = &config.TierConfig{Provider: config.ProviderAnthropic, BaseURL: anthropic.URL, APIKey: "test-key", Model: "claude-3-7-sonnet-20250219"}
+	}, func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Unexpected request to the primary provider")
+	})
+
+	if rec := postRawMessages(t, handler, serverToolsBody); rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var want struct {
+		MCPServers []interface{} `json:"mcp_servers"`
+		Tools      []interface{} `json:"tools"`
+	}
+	if err := json.Unmarshal([]byte(serverToolsBody), &want); err != nil {
+		t.Fatalf("Failed to decode request: %v", err)
+	}
+	got, _ := json.Marshal(upstreamBody["tools"])
+	wantTools, _ := json.Marshal(want.Tools)
+	if string(got) != string(wantTools) {
+		t.Errorf("Expected the tools unchanged\ngot:  %s\nwant: %s", got, wantTools)
+	}
+	gotServers, _ := json.Marshal(upstreamBody["mcp_servers"])
+	wantServers, _ := json.Marshal(want.MCPServers)
+	if string(gotServers) != string(wantServers) {
+		t.Errorf("Expected mcp_servers unchanged, got %s", gotServers)
+	}
+}
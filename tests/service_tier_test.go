@@ -0,0 +1,88 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jedarden/clasp/internal/config"
+	"github.com/jedarden/clasp/pkg/models"
+)
+
+func TestServiceTier_PassthroughRoundTrip(t *testing.T) {
+	var upstreamReq map[string]interface{}
+	anthropic := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&upstreamReq)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"msg_01","type":"message","role":"assistant","content":[{"type":"text","text":"Hi"}],"model":"claude-3-7-sonnet-20250219","stop_reason":"end_turn","usage":{"input_tokens":10,"output_tokens":2}}`))
+	}))
+	t.Cleanup(anthropic.Close)
+
+	handler := newTestHandler(t, func(cfg *config.Config) {
+		cfg.MultiProviderEnabled = true
+		cfg.TierSonnet = &config.TierConfig{Provider: config.ProviderAnthropic, BaseURL: anthropic.URL, APIKey: "test-key", Model: "claude-3-7-sonnet-20250219"}
+	}, func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Unexpected request to the primary provider")
+	})
+
+	req := simpleRequest()
+	req.Metadata = &models.Metadata{UserID: "user-123"}
+	req.ServiceTier = "standard_only"
+	if rec := postMessages(t, handler, req); rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	metadata, _ := upstreamReq["metadata"].(map[string]interface{})
+	if metadata["user_id"] != "user-123" {
+		t.Errorf("Expected metadata.user_id to be passed through, got %v", upstreamReq["metadata"])
+	}
+	if upstreamReq["service_tier"] != "standard_only" {
+		t.Errorf("Expected service_tier standard_only to be passed through, got %v", upstreamReq["service_tier"])
+	}
+}
+
+func TestServiceTier_TranslatedToOpenAI(t *testing.T) {
+	var upstreamReq map[string]interface{}
+	handler := newTestHandler(t, nil, func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&upstreamReq)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"chatcmpl-1","choices":[{"message":{"role":"assistant","content":"Hi"},"finish_reason":"stop"}],"usage":{"prompt_tokens":10,"completion_tokens":2}}`))
+	})
+
+	req := simpleRequest()
+	req.ServiceTier = "standard_only"
+	if rec := postMessages(t, handler, req); rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	if upstreamReq["service_tier"] != "default" {
+		t.Errorf("Expected service_tier default for gpt-4o, got %v", upstreamReq["service_tier"])
+	}
+	if _, ok := upstreamReq["metadata"]; ok {
+		t.Error("Expected metadata not to be sent to the translated provider")
+	}
+}
+
+func TestAuditLog_UserIDAndServiceTier(t *testing.T) {
+	handler, path := newAuditedHandler(t, false, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"chatcmpl-1","choices":[{"message":{"role":"assistant","content":"Hi"},"finish_reason":"stop"}],"usage":{"prompt_tokens":10,"completion_tokens":2}}`))
+	})
+
+	req := simpleRequest()
+	req.Metadata = &models.Metadata{UserID: "user-123"}
+	req.ServiceTier = "auto"
+	postMessages(t, handler, req)
+
+	entries := readAuditLog(t, path)
+	if len(entries) != 1 {
+		t.Fatalf("Expected 1 audit entry, got %d", len(entries))
+	}
+	if entries[0]["user_id"] != "user-123" {
+		t.Errorf("Expected user_id user-123, got %v", entries[0]["user_id"])
+	}
+	if entries[0]["service_tier"] != "auto" {
+		t.Errorf("Expected service_tier auto, got %v", entries[0]["service_tier"])
+	}
+}
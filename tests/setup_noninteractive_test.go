@@ -0,0 +1,172 @@
+package tests
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jedarden/clasp/internal/setup"
+)
+
+// readSavedConfig reads ~/.clasp/config.json under the given home directory.
+func readSavedConfig(t *testing.T, home string) *setup.ConfigFile {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(home, ".clasp", "config.json"))
+	if err != nil {
+		t.Fatalf("Failed to read config file: %v", err)
+	}
+	var cfg setup.ConfigFile
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		t.Fatalf("Failed to parse config file: %v", err)
+	}
+	return &cfg
+}
+
+func TestRunNonInteractive_Flags(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+
+	err := setup.NewWizard().RunNonInteractive(&setup.ConfigFile{
+		Provider: "openai",
+		APIKey:   "sk-test-key",
+		Model:    "gpt-4o",
+	})
+	if err != nil {
+		t.Fatalf("RunNonInteractive failed: %v", err)
+	}
+
+	cfg := readSavedConfig(t, tmpDir)
+	if cfg.Provider != "openai" || cfg.APIKey != "sk-test-key" || cfg.Model != "gpt-4o" {
+		t.Errorf("Unexpected config: %+v", cfg)
+	}
+	if cfg.CreatedAt == "" || cfg.UpdatedAt == "" {
+		t.Error("Expected timestamps to be set")
+	}
+	if cfg.ClaudeCodeConfig == nil || !cfg.ClaudeCodeConfig.SkipPermissions {
+		t.Error("Expected default Claude Code settings")
+	}
+
+	info, err := os.Stat(filepath.Join(tmpDir, ".clasp", "config.json"))
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("Config file permissions = %o, want 600", info.Mode().Perm())
+	}
+}
+
+func TestRunNonInteractive_DefaultModel(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+
+	if err := setup.NewWizard().RunNonInteractive(&setup.ConfigFile{Provider: "ollama"}); err != nil {
+		t.Fatalf("RunNonInteractive failed: %v", err)
+	}
+
+	cfg := readSavedConfig(t, tmpDir)
+	if cfg.Model == "" {
+		t.Error("Expected the provider's default model")
+	}
+	if cfg.BaseURL != "http://localhost:11434" {
+		t.Errorf("BaseURL = %q, want default Ollama URL", cfg.BaseURL)
+	}
+}
+
+func TestRunNonInteractive_FromJSON(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+
+	input := filepath.Join(tmpDir, "input.json")
+	content := `{"provider":"azure","api_key":"az-key","model":"gpt-4o","azure_endpoint":"https://res.openai.azure.com","azure_deployment":"gpt4o"}`
+	if err := os.WriteFile(input, []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to write input: %v", err)
+	}
+
+	cfg, err := setup.LoadConfigFileJSON(input)
+	if err != nil {
+		t.Fatalf("LoadConfigFileJSON failed: %v", err)
+	}
+	if err := setup.NewWizard().RunNonInteractive(cfg); err != nil {
+		t.Fatalf("RunNonInteractive failed: %v", err)
+	}
+
+	saved := readSavedConfig(t, tmpDir)
+	if saved.Provider != "azure" || saved.AzureEndpoint != "https://res.openai.azure.com" || saved.AzureDeployment != "gpt4o" {
+		t.Errorf("Unexpected config: %+v", saved)
+	}
+}
+
+func TestValidateConfigFile_MissingFields(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     setup.ConfigFile
+		wantErr string
+	}{
+		{"no provider", setup.ConfigFile{}, "provider is required"},
+		{"unknown provider", setup.ConfigFile{Provider: "nope"}, "unknown provider"},
+		{"openai without key", setup.ConfigFile{Provider: "openai"}, "api_key"},
+		{"azure without endpoint", setup.ConfigFile{Provider: "azure", APIKey: "k"}, "azure_endpoint, azure_deployment"},
+		{"custom without base url", setup.ConfigFile{Provider: "custom"}, "base_url"},
+		{"azure responses model", setup.ConfigFile{Provider: "azure", APIKey: "k", AzureEndpoint: "e", AzureDeployment: "d", Model: "gpt-5-codex"}, "Responses API"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := setup.ValidateConfigFile(&tt.cfg)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ValidateConfigFile() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	if err := setup.ValidateConfigFile(&setup.ConfigFile{Provider: "litellm"}); err != nil {
+		t.Errorf("litellm should not require an API key: %v", err)
+	}
+}
+
+// commandEnvWithHome returns the environment for running the CLI with a
+// different HOME while keeping Go's build and module caches.
+func commandEnvWithHome(t *testing.T, home string) []string {
+	t.Helper()
+	out, err := exec.Command("go", "env", "GOCACHE", "GOMODCACHE", "GOPATH").Output()
+	if err != nil {
+		t.Fatalf("go env failed: %v", err)
+	}
+	values := strings.Split(strings.TrimSpace(string(out)), "\n")
+	env := append(os.Environ(), "HOME="+home)
+	for i, name := range []string{"GOCACHE", "GOMODCACHE", "GOPATH"} {
+		if i < len(values) {
+			env = append(env, name+"="+values[i])
+		}
+	}
+	return env
+}
+
+// TestCommandSetupNonInteractive runs the setup subcommand end to end.
+func TestCommandSetupNonInteractive(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	cmd := exec.Command("go", "run", "../cmd/clasp", "setup", "--non-interactive",
+		"--provider", "deepseek", "--api-key", "ds-key", "--model", "deepseek-chat")
+	cmd.Env = commandEnvWithHome(t, tmpDir)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("setup command failed: %v, output: %s", err, output)
+	}
+	cfg := readSavedConfig(t, tmpDir)
+	if cfg.Provider != "deepseek" || cfg.APIKey != "ds-key" || cfg.Model != "deepseek-chat" {
+		t.Errorf("Unexpected config: %+v", cfg)
+	}
+
+	// Missing required fields exit non-zero with a clear message
+	cmd = exec.Command("go", "run", "../cmd/clasp", "setup", "--non-interactive", "--provider", "azure", "--api-key", "k")
+	cmd.Env = commandEnvWithHome(t, t.TempDir())
+	output, err := cmd.CombinedOutput()
+	if err == nil {
+		t.Fatal("Expected setup to fail without Azure endpoint/deployment")
+	}
+	if !strings.Contains(string(output), "azure_endpoint") {
+		t.Errorf("Expected missing-field message, got: %s", output)
+	}
+}
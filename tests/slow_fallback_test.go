@@ -0,0 +1,95 @@
+package tests
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jedarden/clasp/internal/config"
+	"github.com/jedarden/clasp/internal/proxy"
+)
+
+// newSlowFallbackHandler creates a handler whose fallback is raced after 50ms,
+// with a fallback that answers immediately.
+func newSlowFallbackHandler(t *testing.T, primary http.HandlerFunc) *proxy.Handler {
+	t.Helper()
+	fallback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"chatcmpl-fb","choices":[{"message":{"role":"assistant","content":"From fallback"},"finish_reason":"stop"}],"usage":{"prompt_tokens":3,"completion_tokens":2}}`))
+	}))
+	t.Cleanup(fallback.Close)
+
+	return newTestHandler(t, func(cfg *config.Config) {
+		cfg.FallbackEnabled = true
+		cfg.FallbackProvider = config.ProviderCustom
+		cfg.FallbackBaseURL = fallback.URL
+		cfg.FallbackAPIKey = "fallback-key"
+		cfg.FallbackModel = "gpt-4o-mini"
+		cfg.FallbackOnSlow = true
+		cfg.FallbackSlowMs = 50
+	}, primary)
+}
+
+func TestSlowFallback_FallbackWinsAndPrimaryCancelled(t *testing.T) {
+	primaryCancelled := make(chan struct{})
+	handler := newSlowFallbackHandler(t, func(w http.ResponseWriter, r *http.Request) {
+		// The server notices the client going away only once the body is read
+		_, _ = io.ReadAll(r.Body)
+		select {
+		case <-r.Context().Done():
+			close(primaryCancelled)
+		case <-time.After(5 * time.Second):
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(chatCompletionOK))
+		}
+	})
+
+	start := time.Now()
+	rec := postMessages(t, handler, simpleRequest())
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected the fallback to answer without waiting for the primary, took %v", elapsed)
+	}
+	if !strings.Contains(rec.Body.String(), "From fallback") {
+		t.Errorf("Expected the fallback response, got %s", rec.Body.String())
+	}
+	if rec.Header().Get("X-CLASP-Fallback") != "fallback:1" {
+		t.Error("Expected the response to be marked as served by the fallback")
+	}
+
+	select {
+	case <-primaryCancelled:
+	case <-time.After(2 * time.Second):
+		t.Error("Expected the primary request to be cancelled")
+	}
+
+	fallback, _ := getMetrics(t, handler)["fallback"].(map[string]interface{})
+	if fallback["slow_races"] != float64(1) || fallback["slow_wins"] != float64(1) {
+		t.Errorf("Expected 1 slow race won by the fallback, got %v", fallback)
+	}
+}
+
+func TestSlowFallback_FastPrimaryNotRaced(t *testing.T) {
+	handler := newSlowFallbackHandler(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(chatCompletionOK))
+	})
+
+	rec := postMessages(t, handler, simpleRequest())
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec.Header().Get("X-CLASP-Fallback") != "" {
+		t.Error("Expected a fast primary to serve the request")
+	}
+
+	fallback, _ := getMetrics(t, handler)["fallback"].(map[string]interface{})
+	if fallback["slow_races"] != float64(0) {
+		t.Errorf("Expected no slow races, got %v", fallback)
+	}
+}
@@ -0,0 +1,133 @@
+package tests
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jedarden/clasp/internal/setup"
+	"github.com/jedarden/clasp/internal/statusline"
+)
+
+// runStatusJSON runs 'clasp status' with the given args under home and decodes its JSON output.
+func runStatusJSON(t *testing.T, home string, v interface{}, args ...string) {
+	t.Helper()
+	cmd := exec.Command("go", append([]string{"run", "../cmd/clasp", "status"}, args...)...)
+	cmd.Env = commandEnvWithHome(t, home)
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("status command failed: %v, output: %s", err, output)
+	}
+	if err := json.Unmarshal(output, v); err != nil {
+		t.Fatalf("status output is not valid JSON: %v\n%s", err, output)
+	}
+}
+
+// TestCommandStatusJSON_NotRunning checks the report with a profile but no proxy.
+func TestCommandStatusJSON_NotRunning(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	pm := setup.NewProfileManager()
+	err := pm.CreateProfile(&setup.Profile{
+		Name:         "work",
+		Provider:     "openai",
+		DefaultModel: "gpt-4o",
+		TierMappings: map[string]setup.TierMapping{"opus": {Model: "gpt-4o"}, "haiku": {Model: "gpt-4o-mini"}},
+		CacheEnabled: true,
+	})
+	if err != nil {
+		t.Fatalf("CreateProfile failed: %v", err)
+	}
+	if err := pm.SetActiveProfile("work"); err != nil {
+		t.Fatalf("SetActiveProfile failed: %v", err)
+	}
+
+	var report map[string]interface{}
+	runStatusJSON(t, home, &report, "--json")
+
+	if report["running"] != false {
+		t.Errorf("running = %v, want false", report["running"])
+	}
+	if report["version"] == "" || report["version"] == nil {
+		t.Error("Expected version")
+	}
+	if report["provider"] != "openai" || report["model"] != "gpt-4o" {
+		t.Errorf("provider/model = %v/%v, want openai/gpt-4o", report["provider"], report["model"])
+	}
+
+	profile, ok := report["profile"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("Expected profile object, got %v", report["profile"])
+	}
+	if profile["name"] != "work" {
+		t.Errorf("profile.name = %v, want work", profile["name"])
+	}
+	tiers, _ := profile["tier_mappings"].(map[string]interface{})
+	if tiers["haiku"] != "gpt-4o-mini" {
+		t.Errorf("profile.tier_mappings = %v", profile["tier_mappings"])
+	}
+	features, _ := profile["features"].([]interface{})
+	if len(features) != 1 || features[0] != "cache" {
+		t.Errorf("profile.features = %v, want [cache]", profile["features"])
+	}
+}
+
+// TestCommandStatusJSON_Running checks the report and instance list for a running proxy.
+func TestCommandStatusJSON_Running(t *testing.T) {
+	home := t.TempDir()
+	statusDir := filepath.Join(home, ".clasp", "status")
+	if err := os.MkdirAll(statusDir, 0o700); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+
+	// The test process stands in for the proxy so the PID is alive
+	data, err := json.Marshal(statusline.Status{
+		Running:   true,
+		Port:      8090,
+		PID:       os.Getpid(),
+		Provider:  "openrouter",
+		Model:     "anthropic/claude-3.5-sonnet",
+		Requests:  12,
+		StartTime: time.Now().Add(-time.Minute),
+		Version:   "v0.49.0",
+	})
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(statusDir, "8090.json"), data, 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	var report map[string]interface{}
+	runStatusJSON(t, home, &report, "--json", "-p", "8090")
+
+	if report["running"] != true {
+		t.Fatalf("running = %v, want true", report["running"])
+	}
+	if report["pid"] != float64(os.Getpid()) {
+		t.Errorf("pid = %v, want %d", report["pid"], os.Getpid())
+	}
+	if report["uptime"] == nil || report["uptime"] == "" {
+		t.Error("Expected uptime")
+	}
+	if report["provider"] != "openrouter" || report["model"] != "anthropic/claude-3.5-sonnet" {
+		t.Errorf("provider/model = %v/%v", report["provider"], report["model"])
+	}
+	if report["profile"] != nil {
+		t.Errorf("profile = %v, want null without a profile", report["profile"])
+	}
+	proxy, _ := report["proxy"].(map[string]interface{})
+	if proxy["requests"] != float64(12) {
+		t.Errorf("proxy.requests = %v, want 12", proxy["requests"])
+	}
+
+	var instances []statusline.InstanceInfo
+	runStatusJSON(t, home, &instances, "--all", "--json")
+	if len(instances) != 1 || instances[0].Port != 8090 || !instances[0].IsRunning {
+		t.Errorf("Unexpected instances: %+v", instances)
+	}
+}
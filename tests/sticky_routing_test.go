@@ -0,0 +1,118 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/jedarden/clasp/internal/config"
+)
+
+const chatCompletionOK = `{"id":"chatcmpl-1","choices":[{"message":{"role":"assistant","content":"Hi"},"finish_reason":"stop"}],"usage":{"prompt_tokens":3,"completion_tokens":1}}`
+
+func TestStickyRouting_PinsConversationToFallback(t *testing.T) {
+	var primaryDown atomic.Bool
+	var primaryHits, fallbackHits atomic.Int64
+	primaryDown.Store(true)
+
+	fallback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fallbackHits.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(chatCompletionOK))
+	}))
+	t.Cleanup(fallback.Close)
+
+	handler := newTestHandler(t, func(cfg *config.Config) {
+		cfg.FallbackEnabled = true
+		cfg.FallbackProvider = config.ProviderCustom
+		cfg.FallbackBaseURL = fallback.URL
+		cfg.FallbackAPIKey = "fallback-key"
+		cfg.FallbackModel = "gpt-4o-mini"
+		cfg.StickyRoutingEnabled = true
+	}, func(w http.ResponseWriter, r *http.Request) {
+		primaryHits.Add(1)
+		if primaryDown.Load() {
+			// 529 is not retried, so the fallback is tried immediately
+			w.WriteHeader(529)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(chatCompletionOK))
+	})
+	conversation := map[string]string{"X-CLASP-Conversation-ID": "conv-1"}
+
+	// Turn 1: primary is down, the fallback serves the conversation
+	rec := postMessagesWithHeaders(t, handler, simpleRequest(), conversation)
+	if rec.Code != http.StatusOK || rec.Header().Get("X-CLASP-Fallback") != "fallback:1" {
+		t.Fatalf("turn 1: expected fallback success, got %d (fallback header %q)", rec.Code, rec.Header().Get("X-CLASP-Fallback"))
+	}
+
+	// Turn 2: primary has recovered, but the conversation stays on the fallback
+	primaryDown.Store(false)
+	primaryBefore := primaryHits.Load()
+	rec = postMessagesWithHeaders(t, handler, simpleRequest(), conversation)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("turn 2: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if primaryHits.Load() != primaryBefore {
+		t.Errorf("turn 2 should not reach the primary provider")
+	}
+	if fallbackHits.Load() != 2 {
+		t.Errorf("expected 2 fallback requests, got %d", fallbackHits.Load())
+	}
+	if rec.Header().Get("X-CLASP-Fallback") != "fallback:1" {
+		t.Error("turn 2 should be marked as served by the fallback")
+	}
+
+	// A different conversation uses the primary provider
+	rec = postMessagesWithHeaders(t, handler, simpleRequest(), map[string]string{"X-CLASP-Conversation-ID": "conv-2"})
+	if rec.Code != http.StatusOK || rec.Header().Get("X-CLASP-Fallback") != "" {
+		t.Errorf("new conversation should use the primary, got %d (fallback header %q)", rec.Code, rec.Header().Get("X-CLASP-Fallback"))
+	}
+	if fallbackHits.Load() != 2 {
+		t.Errorf("new conversation should not reach the fallback, got %d fallback requests", fallbackHits.Load())
+	}
+}
+
+func TestStickyRouting_PinnedFallbackDown(t *testing.T) {
+	var fallbackDown atomic.Bool
+	var primaryHits atomic.Int64
+
+	fallback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fallbackDown.Load() {
+			w.WriteHeader(529)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(chatCompletionOK))
+	}))
+	t.Cleanup(fallback.Close)
+
+	handler := newTestHandler(t, func(cfg *config.Config) {
+		cfg.FallbackEnabled = true
+		cfg.FallbackProvider = config.ProviderCustom
+		cfg.FallbackBaseURL = fallback.URL
+		cfg.FallbackAPIKey = "fallback-key"
+		cfg.StickyRoutingEnabled = true
+	}, func(w http.ResponseWriter, r *http.Request) {
+		if primaryHits.Add(1) == 1 {
+			w.WriteHeader(529)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(chatCompletionOK))
+	})
+	conversation := map[string]string{"X-CLASP-Conversation-ID": "conv-1"}
+
+	if rec := postMessagesWithHeaders(t, handler, simpleRequest(), conversation); rec.Header().Get("X-CLASP-Fallback") != "fallback:1" {
+		t.Fatalf("turn 1 should be served by the fallback")
+	}
+
+	// The pinned fallback is down, so the primary serves the turn
+	fallbackDown.Store(true)
+	rec := postMessagesWithHeaders(t, handler, simpleRequest(), conversation)
+	if rec.Code != http.StatusOK || rec.Header().Get("X-CLASP-Fallback") != "" {
+		t.Errorf("expected primary to serve turn 2, got %d (fallback header %q)", rec.Code, rec.Header().Get("X-CLASP-Fallback"))
+	}
+}
@@ -0,0 +1,59 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// dropConnectionMidStream returns an upstream that sends the start of a
+// Chat Completions stream and then closes the connection without finishing it.
+func dropConnectionMidStream(t *testing.T) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		hijacker, ok := w.(http.Hijacker)
+		if !ok {
+			t.Fatal("Upstream does not support hijacking")
+		}
+		conn, buf, err := hijacker.Hijack()
+		if err != nil {
+			t.Fatalf("Hijack failed: %v", err)
+		}
+		defer conn.Close()
+
+		// Chunked encoding without the terminating chunk makes the client's
+		// read fail with an unexpected EOF
+		chunk := "data: {\"choices\":[{\"delta\":{\"content\":\"Partial answer\"}}]}\n\n"
+		_, _ = fmt.Fprintf(buf, "HTTP/1.1 200 OK\r\nContent-Type: text/event-stream\r\nTransfer-Encoding: chunked\r\n\r\n%x\r\n%s\r\n", len(chunk), chunk)
+		_ = buf.Flush()
+	}
+}
+
+func TestStreaming_UpstreamDropTerminatesClientStream(t *testing.T) {
+	handler := newTestHandler(t, nil, dropConnectionMidStream(t))
+
+	rec := postMessages(t, handler, streamingRequest())
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	body := rec.Body.String()
+	if !strings.Contains(body, "Partial answer") {
+		t.Errorf("Expected the streamed text before the drop, got:\n%s", body)
+	}
+
+	var types []string
+	for _, event := range parseSSEEvents(t, body) {
+		types = append(types, event.Type)
+	}
+	want := []string{"message_start", "ping", "content_block_start", "content_block_delta", "content_block_stop", "message_delta", "message_stop"}
+	if strings.Join(types, ",") != strings.Join(want, ",") {
+		t.Errorf("events = %v, want %v", types, want)
+	}
+	if !strings.Contains(body, `"stop_reason":"end_turn"`) {
+		t.Errorf("Expected stop_reason end_turn, got:\n%s", body)
+	}
+	if !strings.HasSuffix(body, "data: [DONE]\n\n") {
+		t.Errorf("Expected the stream to end with [DONE], got:\n%s", body)
+	}
+}
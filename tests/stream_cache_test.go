@@ -0,0 +1,179 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jedarden/clasp/internal/config"
+	"github.com/jedarden/clasp/internal/proxy"
+	"github.com/jedarden/clasp/pkg/models"
+)
+
+// sseEvent is one event of an Anthropic SSE stream.
+type sseEvent struct {
+	Type string
+	Data map[string]interface{}
+}
+
+// parseSSEEvents splits an Anthropic SSE stream into its events, skipping
+// data-only lines such as the trailing [DONE].
+func parseSSEEvents(t *testing.T, body string) []sseEvent {
+	t.Helper()
+	var events []sseEvent
+	for _, block := range strings.Split(strings.TrimSpace(body), "\n\n") {
+		lines := strings.SplitN(block, "\n", 2)
+		if len(lines) != 2 || !strings.HasPrefix(lines[0], "event: ") {
+			continue
+		}
+		var data map[string]interface{}
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(lines[1], "data: ")), &data); err != nil {
+			t.Fatalf("Invalid event data %q: %v", lines[1], err)
+		}
+		events = append(events, sseEvent{Type: strings.TrimPrefix(lines[0], "event: "), Data: data})
+	}
+	return events
+}
+
+// newStreamCacheHandler creates a handler with streaming cache enabled for a
+// mock upstream that streams a fixed reply, counting upstream calls.
+func newStreamCacheHandler(t *testing.T, cacheStreaming bool) (*proxy.Handler, *int32) {
+	t.Helper()
+	var calls int32
+	handler := newTestHandler(t, func(cfg *config.Config) {
+		cfg.CacheStreaming = cacheStreaming
+	}, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		var req map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if req["stream"] != true {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"id":"chatcmpl-1","choices":[{"message":{"role":"assistant","content":"Hello from JSON"},"finish_reason":"stop"}],"usage":{"prompt_tokens":12,"completion_tokens":3}}`))
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte("data: {\"id\":\"1\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Hello from \"}}]}\n\n" +
+			"data: {\"id\":\"1\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"the stream\"}}]}\n\n" +
+			"data: {\"id\":\"1\",\"choices\":[{\"index\":0,\"delta\":{},\"finish_reason\":\"stop\"}],\"usage\":{\"prompt_tokens\":12,\"completion_tokens\":4}}\n\n" +
+			"data: [DONE]\n\n"))
+	})
+	handler.SetCache(proxy.NewRequestCache(100, time.Hour))
+	return handler, &calls
+}
+
+func streamingRequest() *models.AnthropicRequest {
+	req := simpleRequest()
+	req.Stream = true
+	return req
+}
+
+func TestStreamCache_ReplaysCachedStream(t *testing.T) {
+	handler, calls := newStreamCacheHandler(t, true)
+
+	first := postMessages(t, handler, streamingRequest())
+	if first.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", first.Code, first.Body.String())
+	}
+	if got := first.Header().Get("X-CLASP-Cache"); got != "MISS" {
+		t.Errorf("Expected first request to be a MISS, got %q", got)
+	}
+
+	second := postMessages(t, handler, streamingRequest())
+	if second.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", second.Code, second.Body.String())
+	}
+	if got := second.Header().Get("X-CLASP-Cache"); got != "HIT" {
+		t.Fatalf("Expected second request to be a HIT, got %q", got)
+	}
+	if got := second.Header().Get("Content-Type"); got != "text/event-stream" {
+		t.Errorf("Expected an SSE response, got Content-Type %q", got)
+	}
+	if n := atomic.LoadInt32(calls); n != 1 {
+		t.Errorf("Expected 1 upstream call, got %d", n)
+	}
+
+	events := parseSSEEvents(t, second.Body.String())
+	var types []string
+	var text string
+	for _, event := range events {
+		types = append(types, event.Type)
+		if delta, ok := event.Data["delta"].(map[string]interface{}); ok && event.Type == "content_block_delta" {
+			text += delta["text"].(string)
+		}
+	}
+	want := "message_start ping content_block_start content_block_delta content_block_stop message_delta message_stop"
+	if got := strings.Join(types, " "); got != want {
+		t.Errorf("events = %s, want %s", got, want)
+	}
+	if text != "Hello from the stream" {
+		t.Errorf("Expected replayed text %q, got %q", "Hello from the stream", text)
+	}
+
+	start := events[0].Data["message"].(map[string]interface{})
+	if usage := start["usage"].(map[string]interface{}); usage["input_tokens"] != float64(12) {
+		t.Errorf("Expected message_start input_tokens 12, got %v", usage["input_tokens"])
+	}
+	messageDelta := events[len(events)-2].Data
+	if reason := messageDelta["delta"].(map[string]interface{})["stop_reason"]; reason != "end_turn" {
+		t.Errorf("Expected stop_reason end_turn, got %v", reason)
+	}
+	if usage := messageDelta["usage"].(map[string]interface{}); usage["output_tokens"] != float64(4) {
+		t.Errorf("Expected output_tokens 4, got %v", usage["output_tokens"])
+	}
+}
+
+func TestStreamCache_SharesNonStreamingEntries(t *testing.T) {
+	handler, calls := newStreamCacheHandler(t, true)
+
+	postMessages(t, handler, simpleRequest())
+	rec := postMessages(t, handler, streamingRequest())
+	if got := rec.Header().Get("X-CLASP-Cache"); got != "HIT" {
+		t.Fatalf("Expected a streaming HIT for a cached non-streaming response, got %q", got)
+	}
+	if n := atomic.LoadInt32(calls); n != 1 {
+		t.Errorf("Expected 1 upstream call, got %d", n)
+	}
+	if !strings.Contains(rec.Body.String(), "Hello from JSON") {
+		t.Errorf("Expected the cached response to be replayed, got %s", rec.Body.String())
+	}
+}
+
+func TestStreamCache_Disabled(t *testing.T) {
+	handler, calls := newStreamCacheHandler(t, false)
+
+	for i := 0; i < 2; i++ {
+		rec := postMessages(t, handler, streamingRequest())
+		if got := rec.Header().Get("X-CLASP-Cache"); got != "" {
+			t.Errorf("Expected no cache header without CLASP_CACHE_STREAMING, got %q", got)
+		}
+	}
+	if n := atomic.LoadInt32(calls); n != 2 {
+		t.Errorf("Expected 2 upstream calls, got %d", n)
+	}
+}
+
+func TestStreamCache_SkipsStreamWithoutFinishReason(t *testing.T) {
+	var calls int32
+	handler := newTestHandler(t, func(cfg *config.Config) {
+		cfg.CacheStreaming = true
+	}, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		// The upstream closes the stream without a finish_reason or [DONE]
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte("data: {\"id\":\"1\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Hello from \"}}]}\n\n"))
+	})
+	handler.SetCache(proxy.NewRequestCache(100, time.Hour))
+
+	for i := 0; i < 2; i++ {
+		rec := postMessages(t, handler, streamingRequest())
+		if got := rec.Header().Get("X-CLASP-Cache"); got != "MISS" {
+			t.Errorf("Request %d: expected a MISS, got %q", i+1, got)
+		}
+	}
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Errorf("Expected 2 upstream calls, got %d", n)
+	}
+}
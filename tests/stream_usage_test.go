@@ -0,0 +1,100 @@
+package tests
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/jedarden/clasp/internal/config"
+	"github.com/jedarden/clasp/internal/proxy"
+)
+
+// streamWithoutUsage is a Chat Completions stream that reports no usage, as
+// sent by providers when stream_options.include_usage is not requested.
+const streamWithoutUsage = "data: {\"id\":\"chatcmpl-1\",\"choices\":[{\"index\":0,\"delta\":{\"role\":\"assistant\",\"content\":\"Hello there, friend.\"},\"finish_reason\":null}]}\n\n" +
+	"data: {\"id\":\"chatcmpl-1\",\"choices\":[{\"index\":0,\"delta\":{},\"finish_reason\":\"stop\"}]}\n\n" +
+	"data: [DONE]\n\n"
+
+// streamOptionsUpstream returns a mock upstream that records whether each
+// request carried stream_options and answers with a stream without usage.
+func streamOptionsUpstream(t *testing.T, sent *[]bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var req map[string]interface{}
+		if err := json.Unmarshal(body, &req); err != nil {
+			t.Errorf("Failed to decode upstream request: %v", err)
+		}
+		_, ok := req["stream_options"]
+		*sent = append(*sent, ok)
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte(streamWithoutUsage))
+	}
+}
+
+func TestStreamIncludeUsage_Modes(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		provider config.ProviderType
+		mode     string
+		wantSent bool
+	}{
+		{"custom auto", config.ProviderCustom, "auto", true},
+		{"custom off", config.ProviderCustom, "off", false},
+		{"ollama auto", config.ProviderOllama, "auto", false},
+		{"ollama on", config.ProviderOllama, "on", true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var sent []bool
+			cfg, server := newMockUpstream(t, streamOptionsUpstream(t, &sent))
+			if tc.provider == config.ProviderOllama {
+				cfg.Provider = config.ProviderOllama
+				cfg.OllamaBaseURL = server.URL
+			}
+			cfg.StreamIncludeUsage = tc.mode
+			handler, err := proxy.NewHandler(cfg)
+			if err != nil {
+				t.Fatalf("Failed to create handler: %v", err)
+			}
+
+			rec := postMessages(t, handler, streamingRequest())
+			if rec.Code != http.StatusOK {
+				t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+			}
+			if len(sent) != 1 || sent[0] != tc.wantSent {
+				t.Errorf("Expected stream_options sent=%v, got %v", tc.wantSent, sent)
+			}
+		})
+	}
+}
+
+func TestStreamIncludeUsage_OffEstimatesUsage(t *testing.T) {
+	var sent []bool
+	handler := newTestHandler(t, func(cfg *config.Config) {
+		cfg.StreamIncludeUsage = "off"
+	}, streamOptionsUpstream(t, &sent))
+
+	rec := postMessages(t, handler, streamingRequest())
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var delta map[string]interface{}
+	for _, event := range parseSSEEvents(t, rec.Body.String()) {
+		if event.Type == "message_delta" {
+			delta = event.Data
+		}
+	}
+	if delta == nil {
+		t.Fatalf("Expected a message_delta event, got %s", rec.Body.String())
+	}
+	// "Hello there, friend." is 20 characters, about 5 tokens
+	if tokens := delta["usage"].(map[string]interface{})["output_tokens"]; tokens != float64(5) {
+		t.Errorf("Expected 5 estimated output_tokens, got %v", tokens)
+	}
+
+	summary := handler.GetCostTracker().GetSummary()
+	if summary.TotalInputTokens == 0 || summary.TotalOutputTokens != 5 {
+		t.Errorf("Expected estimated usage in cost tracking, got %d input and %d output tokens", summary.TotalInputTokens, summary.TotalOutputTokens)
+	}
+}
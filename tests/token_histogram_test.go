@@ -0,0 +1,50 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestMetricsPrometheus_TokenHistograms(t *testing.T) {
+	// Each response reports a different prompt size
+	promptTokens := []int{50, 800, 20000}
+	var calls int32
+	handler := newTestHandler(t, nil, func(w http.ResponseWriter, r *http.Request) {
+		n := promptTokens[atomic.AddInt32(&calls, 1)-1]
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"id":"chatcmpl-1","choices":[{"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}],"usage":{"prompt_tokens":%d,"completion_tokens":300}}`, n)
+	})
+
+	for range promptTokens {
+		if rec := postMessages(t, handler, simpleRequest()); rec.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	handler.HandleMetricsPrometheus(rec, httptest.NewRequest(http.MethodGet, "/metrics/prometheus", http.NoBody))
+	body := rec.Body.String()
+
+	for _, line := range []string{
+		"# TYPE clasp_input_tokens histogram",
+		`clasp_input_tokens_bucket{provider="custom",model="gpt-4o",le="100"} 1`,
+		`clasp_input_tokens_bucket{provider="custom",model="gpt-4o",le="1000"} 2`,
+		`clasp_input_tokens_bucket{provider="custom",model="gpt-4o",le="16000"} 2`,
+		`clasp_input_tokens_bucket{provider="custom",model="gpt-4o",le="64000"} 3`,
+		`clasp_input_tokens_bucket{provider="custom",model="gpt-4o",le="+Inf"} 3`,
+		`clasp_input_tokens_sum{provider="custom",model="gpt-4o"} 20850`,
+		`clasp_input_tokens_count{provider="custom",model="gpt-4o"} 3`,
+		"# TYPE clasp_output_tokens histogram",
+		`clasp_output_tokens_bucket{provider="custom",model="gpt-4o",le="100"} 0`,
+		`clasp_output_tokens_bucket{provider="custom",model="gpt-4o",le="500"} 3`,
+		`clasp_output_tokens_count{provider="custom",model="gpt-4o"} 3`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("Expected %q in Prometheus output", line)
+		}
+	}
+}
@@ -0,0 +1,989 @@
+// Package tests provides handler tests against a mock OpenAI-compatible upstream.
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jedarden/clasp/internal/config"
+	"github.com/jedarden/clasp/internal/proxy"
+	"github.com/jedarden/clasp/pkg/models"
+)
+
+// newMockUpstream starts a mock upstream and returns a custom-provider config pointing at it.
+func newMockUpstream(t *testing.T, upstream http.HandlerFunc) (*config.Config, *httptest.Server) {
+	t.Helper()
+	server := httptest.NewServer(upstream)
+	t.Cleanup(server.Close)
+
+	cfg := config.DefaultConfig()
+	cfg.Provider = config.ProviderCustom
+	cfg.CustomBaseURL = server.URL
+	cfg.CustomAPIKey = "test-key"
+	cfg.DefaultModel = "gpt-4o"
+	return cfg, server
+}
+
+// newTestHandler creates a handler for a mock upstream. configure, if not nil,
+// adjusts the newMockUpstream config before the handler is created.
+func newTestHandler(t *testing.T, configure func(*config.Config), upstream http.HandlerFunc) *proxy.Handler {
+	t.Helper()
+	cfg, _ := newMockUpstream(t, upstream)
+	if configure != nil {
+		configure(cfg)
+	}
+	handler, err := proxy.NewHandler(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create handler: %v", err)
+	}
+	return handler
+}
+
+// postMessages sends an Anthropic request to the handler and returns the recorder.
+func postMessages(t *testing.T, handler *proxy.Handler, req *models.AnthropicRequest) *httptest.ResponseRecorder {
+	t.Helper()
+	return postMessagesWithHeaders(t, handler, req, nil)
+}
+
+// postMessagesWithHeaders is postMessages with additional request headers.
+func postMessagesWithHeaders(t *testing.T, handler *proxy.Handler, req *models.AnthropicRequest, headers map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+	body, err := json.Marshal(req)
+	if err != nil {
+		t.Fatalf("Failed to marshal request: %v", err)
+	}
+	httpReq := httptest.NewRequest(http.MethodPost, "/v1/messages", bytes.NewReader(body))
+	httpReq.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		httpReq.Header.Set(key, value)
+	}
+	rec := httptest.NewRecorder()
+	handler.HandleMessages(rec, httpReq)
+	return rec
+}
+
+// simpleRequest returns a minimal non-streaming Anthropic request.
+func simpleRequest() *models.AnthropicRequest {
+	return &models.AnthropicRequest{
+		Model:     "claude-3-5-sonnet-20241022",
+		MaxTokens: 100,
+		Messages: []models.AnthropicMessage{
+			{Role: "user", Content: "Hello"},
+		},
+	}
+}
+
+func TestUpstream_200WithErrorBody(t *testing.T) {
+	testCases := []struct {
+		name         string
+		body         string
+		expectStatus int
+		expectType   string
+		expectMsg    string
+	}{
+		{
+			name:         "rate limit error object",
+			body:         `{"error":{"message":"Rate limit reached","type":"rate_limit_exceeded","code":"rate_limit_exceeded"}}`,
+			expectStatus: http.StatusTooManyRequests,
+			expectType:   "rate_limit_error",
+			expectMsg:    "Rate limit reached",
+		},
+		{
+			name:         "numeric code",
+			body:         `{"error":{"message":"Model not found","code":404}}`,
+			expectStatus: http.StatusNotFound,
+			expectType:   "not_found_error",
+			expectMsg:    "Model not found",
+		},
+		{
+			name:         "string error",
+			body:         `{"error":"backend exploded"}`,
+			expectStatus: http.StatusBadGateway,
+			expectType:   "api_error",
+			expectMsg:    "backend exploded",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := newTestHandler(t, nil, func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusOK)
+				_, _ = w.Write([]byte(tc.body))
+			})
+
+			rec := postMessages(t, handler, simpleRequest())
+			if rec.Code != tc.expectStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tc.expectStatus, rec.Code, rec.Body.String())
+			}
+
+			var errResp struct {
+				Type  string `json:"type"`
+				Error struct {
+					Type    string `json:"type"`
+					Message string `json:"message"`
+				} `json:"error"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &errResp); err != nil {
+				t.Fatalf("Failed to parse error response: %v", err)
+			}
+			if errResp.Type != "error" {
+				t.Errorf("Expected type 'error', got %q", errResp.Type)
+			}
+			if errResp.Error.Type != tc.expectType {
+				t.Errorf("Expected error type %q, got %q", tc.expectType, errResp.Error.Type)
+			}
+			if errResp.Error.Message != tc.expectMsg {
+				t.Errorf("Expected message %q, got %q", tc.expectMsg, errResp.Error.Message)
+			}
+
+			metrics := handler.GetMetrics()
+			if metrics.ErrorRequests != 1 || metrics.SuccessRequests != 0 {
+				t.Errorf("Expected 1 error and 0 successes, got %d errors and %d successes",
+					metrics.ErrorRequests, metrics.SuccessRequests)
+			}
+		})
+	}
+}
+
+func TestUpstream_200WithNullErrorIsSuccess(t *testing.T) {
+	handler := newTestHandler(t, nil, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"chatcmpl-1","error":null,"choices":[{"message":{"role":"assistant","content":"Hi"},"finish_reason":"stop"}],"usage":{"prompt_tokens":3,"completion_tokens":1}}`))
+	})
+
+	rec := postMessages(t, handler, simpleRequest())
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var resp models.AnthropicResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if len(resp.Content) != 1 || resp.Content[0].Text != "Hi" {
+		t.Errorf("Unexpected content: %+v", resp.Content)
+	}
+}
+
+func TestUpstream_FencedToolArguments(t *testing.T) {
+	handler := newTestHandler(t, nil, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"id": "chatcmpl-1",
+			"choices": []interface{}{
+				map[string]interface{}{
+					"message": map[string]interface{}{
+						"role": "assistant",
+						"tool_calls": []interface{}{
+							map[string]interface{}{
+								"id":   "call_1",
+								"type": "function",
+								"function": map[string]interface{}{
+									"name":      "get_weather",
+									"arguments": "```json\n{\"city\": \"Paris\"}\n```",
+								},
+							},
+						},
+					},
+					"finish_reason": "tool_calls",
+				},
+			},
+		})
+	})
+
+	rec := postMessages(t, handler, simpleRequest())
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var resp models.AnthropicResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if len(resp.Content) != 1 || resp.Content[0].Type != "tool_use" {
+		t.Fatalf("Expected a single tool_use block, got %+v", resp.Content)
+	}
+	input, ok := resp.Content[0].Input.(map[string]interface{})
+	if !ok || input["city"] != "Paris" {
+		t.Errorf("Expected input {city: Paris}, got %#v", resp.Content[0].Input)
+	}
+}
+
+func TestUpstream_StreamedResponseDebugLogged(t *testing.T) {
+	handler := newTestHandler(t, func(cfg *config.Config) {
+		cfg.DebugResponses = true
+	}, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte("data: {\"id\":\"1\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Hello from the stream\"}}]}\n\n" +
+			"data: {\"id\":\"1\",\"choices\":[{\"index\":0,\"delta\":{},\"finish_reason\":\"stop\"}]}\n\n" +
+			"data: [DONE]\n\n"))
+	})
+
+	var logBuf bytes.Buffer
+	log.SetOutput(&logBuf)
+	defer log.SetOutput(os.Stderr)
+
+	req := simpleRequest()
+	req.Stream = true
+	rec := postMessages(t, handler, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	logged := logBuf.String()
+	if !strings.Contains(logged, "Streamed Anthropic response") {
+		t.Fatalf("expected streamed response to be debug-logged, got:\n%s", logged)
+	}
+	if !strings.Contains(logged, "Hello from the stream") || !strings.Contains(logged, `"stop_reason": "end_turn"`) {
+		t.Errorf("logged response is missing content or stop reason:\n%s", logged)
+	}
+}
+
+func TestUpstream_MultiChoice(t *testing.T) {
+	const twoChoices = `{"id":"chatcmpl-1","choices":[` +
+		`{"index":0,"message":{"role":"assistant","content":"First"},"finish_reason":"stop"},` +
+		`{"index":1,"message":{"role":"assistant","content":"Second"},"finish_reason":"length"}],` +
+		`"usage":{"prompt_tokens":3,"completion_tokens":2}}`
+
+	newHandler := func(t *testing.T, mode string, upstreamN *int) *proxy.Handler {
+		return newTestHandler(t, func(cfg *config.Config) {
+			cfg.MultiChoice = mode
+		}, func(w http.ResponseWriter, r *http.Request) {
+			var body struct {
+				N int `json:"n"`
+			}
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			*upstreamN = body.N
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(twoChoices))
+		})
+	}
+
+	multiRequest := func() *models.AnthropicRequest {
+		req := simpleRequest()
+		req.N = 2
+		return req
+	}
+
+	t.Run("reject", func(t *testing.T) {
+		upstreamN := -1
+		handler := newHandler(t, "reject", &upstreamN)
+
+		rec := postMessages(t, handler, multiRequest())
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("Expected status 400, got %d: %s", rec.Code, rec.Body.String())
+		}
+		if !strings.Contains(rec.Body.String(), "invalid_request_error") || !strings.Contains(rec.Body.String(), "n=2") {
+			t.Errorf("unexpected error body: %s", rec.Body.String())
+		}
+		if upstreamN != -1 {
+			t.Error("rejected request should not reach the upstream")
+		}
+	})
+
+	t.Run("warn", func(t *testing.T) {
+		upstreamN := 0
+		handler := newHandler(t, "warn", &upstreamN)
+
+		var logBuf bytes.Buffer
+		log.SetOutput(&logBuf)
+		defer log.SetOutput(os.Stderr)
+
+		rec := postMessages(t, handler, multiRequest())
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		if upstreamN != 2 {
+			t.Errorf("expected n=2 to be forwarded, got %d", upstreamN)
+		}
+
+		var resp models.AnthropicResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("Failed to parse response: %v", err)
+		}
+		if len(resp.Content) != 1 || resp.Content[0].Text != "First" || len(resp.Alternatives) != 0 {
+			t.Errorf("expected only the first choice, got %+v", resp)
+		}
+		if !strings.Contains(logBuf.String(), "discarding 1") {
+			t.Errorf("expected a warning about discarded choices, got:\n%s", logBuf.String())
+		}
+	})
+
+	t.Run("return", func(t *testing.T) {
+		upstreamN := 0
+		handler := newHandler(t, "return", &upstreamN)
+
+		rec := postMessages(t, handler, multiRequest())
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+
+		var resp models.AnthropicResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("Failed to parse response: %v", err)
+		}
+		if len(resp.Content) != 1 || resp.Content[0].Text != "First" {
+			t.Errorf("unexpected primary content: %+v", resp.Content)
+		}
+		if len(resp.Alternatives) != 1 {
+			t.Fatalf("expected 1 alternative, got %+v", resp.Alternatives)
+		}
+		alt := resp.Alternatives[0]
+		if len(alt.Content) != 1 || alt.Content[0].Text != "Second" || alt.StopReason != "max_tokens" {
+			t.Errorf("unexpected alternative: %+v", alt)
+		}
+	})
+}
+
+func TestUpstream_ClientProviderKey(t *testing.T) {
+	const clientKey = "sk-client-secret-abcdef123456"
+
+	newHandler := func(t *testing.T, allow bool, gotAuth *string) *proxy.Handler {
+		return newTestHandler(t, func(cfg *config.Config) {
+			cfg.AllowClientKeys = allow
+			cfg.DebugRequests = true
+		}, func(w http.ResponseWriter, r *http.Request) {
+			*gotAuth = r.Header.Get("Authorization")
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"id":"chatcmpl-1","choices":[{"index":0,"message":{"role":"assistant","content":"Hi"},"finish_reason":"stop"}]}`))
+		})
+	}
+
+	t.Run("allowed", func(t *testing.T) {
+		var gotAuth string
+		handler := newHandler(t, true, &gotAuth)
+
+		var logBuf bytes.Buffer
+		log.SetOutput(&logBuf)
+		defer log.SetOutput(os.Stderr)
+
+		rec := postMessagesWithHeaders(t, handler, simpleRequest(), map[string]string{proxy.ClientKeyHeader: clientKey})
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		if gotAuth != "Bearer "+clientKey {
+			t.Errorf("upstream Authorization = %q, want the client key", gotAuth)
+		}
+		if strings.Contains(logBuf.String(), clientKey) {
+			t.Errorf("client key leaked into logs:\n%s", logBuf.String())
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		var gotAuth string
+		handler := newHandler(t, false, &gotAuth)
+
+		rec := postMessagesWithHeaders(t, handler, simpleRequest(), map[string]string{proxy.ClientKeyHeader: clientKey})
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		if gotAuth != "Bearer test-key" {
+			t.Errorf("upstream Authorization = %q, want the configured key", gotAuth)
+		}
+	})
+}
+
+func TestUpstream_ClientProviderKeyBypassesCache(t *testing.T) {
+	var upstreamAuth []string
+	handler := newTestHandler(t, func(cfg *config.Config) {
+		cfg.AllowClientKeys = true
+	}, func(w http.ResponseWriter, r *http.Request) {
+		upstreamAuth = append(upstreamAuth, r.Header.Get("Authorization"))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(chatCompletionOK))
+	})
+	handler.SetCache(proxy.NewRequestCache(100, time.Hour))
+
+	// Two clients send the same request, each with its own key
+	for _, key := range []string{"sk-client-a-0123456789", "sk-client-b-0123456789"} {
+		rec := postMessagesWithHeaders(t, handler, simpleRequest(), map[string]string{proxy.ClientKeyHeader: key})
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		if rec.Header().Get("X-CLASP-Cache") == "HIT" {
+			t.Errorf("Request with key %s was served from the cache", key)
+		}
+	}
+	// A request on the proxy's key doesn't get a client's response either
+	if rec := postMessages(t, handler, simpleRequest()); rec.Header().Get("X-CLASP-Cache") == "HIT" {
+		t.Error("Request without a client key was served a client's cached response")
+	}
+
+	want := []string{"Bearer sk-client-a-0123456789", "Bearer sk-client-b-0123456789", "Bearer test-key"}
+	if strings.Join(upstreamAuth, "|") != strings.Join(want, "|") {
+		t.Errorf("Expected each request upstream on its own key, got %v", upstreamAuth)
+	}
+}
+
+func TestUpstream_ClientProviderKeySkipsFallback(t *testing.T) {
+	var fallbackHits atomic.Int64
+	fallback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fallbackHits.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(chatCompletionOK))
+	}))
+	t.Cleanup(fallback.Close)
+
+	handler := newTestHandler(t, func(cfg *config.Config) {
+		cfg.AllowClientKeys = true
+		cfg.FallbackEnabled = true
+		cfg.FallbackProvider = config.ProviderOpenAI
+		cfg.FallbackBaseURL = fallback.URL
+		cfg.FallbackAPIKey = "fallback-key"
+	}, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(529)
+	})
+
+	// The client's own key failed; the proxy's fallback credentials aren't used instead
+	rec := postMessagesWithHeaders(t, handler, simpleRequest(), map[string]string{proxy.ClientKeyHeader: "sk-client-0123456789"})
+	if rec.Code < http.StatusInternalServerError {
+		t.Errorf("Expected the primary provider's error, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if n := fallbackHits.Load(); n != 0 {
+		t.Errorf("Expected no fallback requests, got %d", n)
+	}
+
+	// Requests on the proxy's key still fall back
+	if rec := postMessages(t, handler, simpleRequest()); rec.Code != http.StatusOK {
+		t.Errorf("Expected status 200 from the fallback, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if n := fallbackHits.Load(); n != 1 {
+		t.Errorf("Expected 1 fallback request, got %d", n)
+	}
+}
+
+func TestUpstream_CacheTinyRequest(t *testing.T) {
+	handler := newTestHandler(t, nil, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"chatcmpl-1","choices":[{"index":0,"message":{"role":"assistant","content":"k"},"finish_reason":"stop"}]}`))
+	})
+	handler.SetCache(proxy.NewRequestCache(10, 0))
+
+	req := &models.AnthropicRequest{
+		Model:     "x",
+		MaxTokens: 1,
+		Messages:  []models.AnthropicMessage{{Role: "user", Content: "a"}},
+	}
+
+	// Second request is served from the cache
+	for i, wantCache := range []string{"MISS", "HIT"} {
+		rec := postMessages(t, handler, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("request %d: expected status 200, got %d: %s", i+1, rec.Code, rec.Body.String())
+		}
+		if got := rec.Header().Get("X-CLASP-Cache"); got != wantCache {
+			t.Errorf("request %d: X-CLASP-Cache = %q, want %q", i+1, got, wantCache)
+		}
+	}
+}
+
+func TestUpstream_EndpointFallback(t *testing.T) {
+	newHandler := func(t *testing.T, enabled bool, paths *[]string) *proxy.Handler {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			*paths = append(*paths, r.URL.Path)
+			if strings.HasSuffix(r.URL.Path, "/responses") {
+				w.WriteHeader(http.StatusNotFound)
+				_, _ = w.Write([]byte(`{"error":{"message":"Not found","type":"invalid_request_error"}}`))
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"id":"chatcmpl-1","choices":[{"index":0,"message":{"role":"assistant","content":"via chat"},"finish_reason":"stop"}]}`))
+		}))
+		t.Cleanup(server.Close)
+
+		cfg := config.DefaultConfig()
+		cfg.Provider = config.ProviderOpenAI
+		cfg.OpenAIBaseURL = server.URL
+		cfg.OpenAIAPIKey = "test-key"
+		cfg.DefaultModel = "gpt-5"
+		cfg.EndpointFallback = enabled
+		handler, err := proxy.NewHandler(cfg)
+		if err != nil {
+			t.Fatalf("Failed to create handler: %v", err)
+		}
+		return handler
+	}
+
+	t.Run("enabled", func(t *testing.T) {
+		var paths []string
+		handler := newHandler(t, true, &paths)
+
+		rec := postMessages(t, handler, simpleRequest())
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		if len(paths) != 2 || paths[0] != "/responses" || paths[1] != "/chat/completions" {
+			t.Errorf("upstream paths = %v, want [/responses /chat/completions]", paths)
+		}
+		if rec.Header().Get("X-CLASP-Responses-API") != "" {
+			t.Error("response should not be marked as served by the Responses API")
+		}
+
+		var resp models.AnthropicResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("Failed to parse response: %v", err)
+		}
+		if len(resp.Content) != 1 || resp.Content[0].Text != "via chat" {
+			t.Errorf("unexpected content: %+v", resp.Content)
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		var paths []string
+		handler := newHandler(t, false, &paths)
+
+		rec := postMessages(t, handler, simpleRequest())
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("Expected status 404, got %d: %s", rec.Code, rec.Body.String())
+		}
+		if len(paths) != 1 {
+			t.Errorf("upstream paths = %v, want a single request", paths)
+		}
+	})
+}
+
+func TestUpstream_RetryAttempts(t *testing.T) {
+	testCases := []struct {
+		name         string
+		retryMax     int
+		failures     int
+		wantAttempts int
+		wantStatus   int
+	}{
+		{"recovers on last attempt", 4, 3, 4, http.StatusOK},
+		{"gives up after max attempts", 2, 5, 2, http.StatusBadGateway},
+		{"single attempt", 1, 1, 1, http.StatusBadGateway},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var attempts int32
+			handler := newTestHandler(t, func(cfg *config.Config) {
+				cfg.RetryMax = tc.retryMax
+				cfg.RetryBaseDelayMs = 1
+				cfg.RetryMaxDelayMs = 2
+			}, func(w http.ResponseWriter, r *http.Request) {
+				if int(atomic.AddInt32(&attempts, 1)) <= tc.failures {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write([]byte(`{"id":"chatcmpl-1","choices":[{"index":0,"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}]}`))
+			})
+
+			rec := postMessages(t, handler, simpleRequest())
+			if rec.Code != tc.wantStatus {
+				t.Errorf("Expected status %d, got %d: %s", tc.wantStatus, rec.Code, rec.Body.String())
+			}
+			if got := int(atomic.LoadInt32(&attempts)); got != tc.wantAttempts {
+				t.Errorf("upstream attempts = %d, want %d", got, tc.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestUpstream_MultiRegionEndpoints(t *testing.T) {
+	var downHits, upHits int32
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&downHits, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(down.Close)
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&upHits, 1)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"chatcmpl-1","choices":[{"index":0,"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}]}`))
+	}))
+	t.Cleanup(up.Close)
+
+	handler := newTestHandler(t, func(cfg *config.Config) {
+		cfg.ProviderEndpoints = map[config.ProviderType][]string{
+			config.ProviderCustom: {down.URL, up.URL},
+		}
+		cfg.RetryBaseDelayMs = 1
+	}, func(w http.ResponseWriter, r *http.Request) {
+		t.Error("configured base URL should not be used when endpoints are set")
+	})
+
+	for i := 0; i < 3; i++ {
+		rec := postMessages(t, handler, simpleRequest())
+		if rec.Code != http.StatusOK {
+			t.Fatalf("request %d: expected status 200, got %d: %s", i+1, rec.Code, rec.Body.String())
+		}
+	}
+	// The failing region is tried once, then avoided
+	if got := atomic.LoadInt32(&downHits); got != 1 {
+		t.Errorf("unhealthy endpoint hits = %d, want 1", got)
+	}
+	if got := atomic.LoadInt32(&upHits); got != 3 {
+		t.Errorf("healthy endpoint hits = %d, want 3", got)
+	}
+}
+
+func TestUpstream_TimeoutHeader(t *testing.T) {
+	newSlowHandler := func(t *testing.T, delay time.Duration, minTimeoutSec, maxTimeoutSec int) *proxy.Handler {
+		return newTestHandler(t, func(cfg *config.Config) {
+			cfg.RequestTimeoutMinSec = minTimeoutSec
+			cfg.RequestTimeoutMaxSec = maxTimeoutSec
+		}, func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-time.After(delay):
+			case <-r.Context().Done():
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"id":"chatcmpl-1","choices":[{"index":0,"message":{"role":"assistant","content":"slow"},"finish_reason":"stop"}]}`))
+		})
+	}
+
+	testCases := []struct {
+		name        string
+		header      string
+		minTimeout  int
+		maxTimeout  int
+		wantStatus  int
+		maxDuration time.Duration
+	}{
+		{"short header times out", "0.2", 0, 3600, http.StatusGatewayTimeout, time.Second},
+		{"overlong header clamped to max", "3600", 0, 1, http.StatusGatewayTimeout, 1400 * time.Millisecond},
+		{"invalid header uses global timeout", "soon", 0, 3600, http.StatusOK, 4 * time.Second},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := newSlowHandler(t, 1500*time.Millisecond, tc.minTimeout, tc.maxTimeout)
+
+			start := time.Now()
+			rec := postMessagesWithHeaders(t, handler, simpleRequest(), map[string]string{proxy.TimeoutHeader: tc.header})
+			elapsed := time.Since(start)
+
+			if rec.Code != tc.wantStatus {
+				t.Errorf("Expected status %d, got %d: %s", tc.wantStatus, rec.Code, rec.Body.String())
+			}
+			if elapsed > tc.maxDuration {
+				t.Errorf("request took %v, want under %v", elapsed, tc.maxDuration)
+			}
+			if tc.wantStatus == http.StatusGatewayTimeout && !strings.Contains(rec.Body.String(), "timeout_error") {
+				t.Errorf("expected timeout_error, got %s", rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestUpstream_TierHTTPTimeout(t *testing.T) {
+	handler := newTestHandler(t, func(cfg *config.Config) {
+		cfg.RetryMax = 1
+		cfg.MultiProviderEnabled = true
+		cfg.TierOpus = &config.TierConfig{HTTPTimeoutSec: 5}
+		cfg.TierHaiku = &config.TierConfig{HTTPTimeoutSec: 1}
+	}, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(1500 * time.Millisecond):
+		case <-r.Context().Done():
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"chatcmpl-1","choices":[{"index":0,"message":{"role":"assistant","content":"slow"},"finish_reason":"stop"}]}`))
+	})
+
+	// Opus waits out the slow upstream; Haiku gives up after its 1s deadline
+	opus := simpleRequest()
+	opus.Model = "claude-3-opus-20240229"
+	if rec := postMessages(t, handler, opus); rec.Code != http.StatusOK {
+		t.Errorf("Opus: expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	haiku := simpleRequest()
+	haiku.Model = "claude-3-haiku-20240307"
+	start := time.Now()
+	rec := postMessages(t, handler, haiku)
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Errorf("Haiku: expected status 504, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if elapsed := time.Since(start); elapsed > 1400*time.Millisecond {
+		t.Errorf("Haiku request took %v, want about 1s", elapsed)
+	}
+
+	// An explicit X-CLASP-Timeout still wins over the tier timeout
+	rec = postMessagesWithHeaders(t, handler, haiku, map[string]string{proxy.TimeoutHeader: "3"})
+	if rec.Code != http.StatusOK {
+		t.Errorf("Haiku with %s: expected status 200, got %d: %s", proxy.TimeoutHeader, rec.Code, rec.Body.String())
+	}
+}
+
+func TestUpstream_BodySizeLimits(t *testing.T) {
+	t.Run("oversized request", func(t *testing.T) {
+		called := false
+		handler := newTestHandler(t, func(cfg *config.Config) {
+			cfg.MaxRequestBytes = 1024
+		}, func(w http.ResponseWriter, r *http.Request) {
+			called = true
+		})
+
+		req := simpleRequest()
+		req.Messages[0].Content = strings.Repeat("x", 4096)
+		rec := postMessages(t, handler, req)
+		if rec.Code != http.StatusRequestEntityTooLarge {
+			t.Fatalf("Expected status 413, got %d: %s", rec.Code, rec.Body.String())
+		}
+
+		var errResp struct {
+			Type  string `json:"type"`
+			Error struct {
+				Type    string `json:"type"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &errResp); err != nil {
+			t.Fatalf("Failed to parse error response: %v", err)
+		}
+		if errResp.Type != "error" || errResp.Error.Type != "request_too_large" {
+			t.Errorf("unexpected error response: %s", rec.Body.String())
+		}
+		if called {
+			t.Error("oversized request should not reach the upstream")
+		}
+	})
+
+	t.Run("request within limit", func(t *testing.T) {
+		handler := newTestHandler(t, func(cfg *config.Config) {
+			cfg.MaxRequestBytes = 1024
+		}, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"id":"chatcmpl-1","choices":[{"index":0,"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}]}`))
+		})
+
+		if rec := postMessages(t, handler, simpleRequest()); rec.Code != http.StatusOK {
+			t.Errorf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+	})
+
+	t.Run("oversized upstream response", func(t *testing.T) {
+		handler := newTestHandler(t, func(cfg *config.Config) {
+			cfg.MaxResponseBytes = 1024
+		}, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"id":"chatcmpl-1","choices":[{"index":0,"message":{"role":"assistant","content":"` + strings.Repeat("x", 4096) + `"},"finish_reason":"stop"}]}`))
+		})
+
+		if rec := postMessages(t, handler, simpleRequest()); rec.Code != http.StatusBadGateway {
+			t.Errorf("Expected status 502, got %d: %s", rec.Code, rec.Body.String())
+		}
+	})
+}
+
+func TestUpstream_MaxConcurrent(t *testing.T) {
+	const limit, extra = 2, 3
+
+	newBlockingHandler := func(t *testing.T, queue bool) (*proxy.Handler, chan struct{}, chan struct{}) {
+		entered := make(chan struct{}, limit+extra)
+		release := make(chan struct{})
+		handler := newTestHandler(t, func(cfg *config.Config) {
+			cfg.MaxConcurrent = limit
+			cfg.QueueEnabled = queue
+			cfg.QueueMaxWaitSeconds = 10
+		}, func(w http.ResponseWriter, r *http.Request) {
+			entered <- struct{}{}
+			<-release
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"id":"chatcmpl-1","choices":[{"index":0,"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}]}`))
+		})
+		return handler, entered, release
+	}
+
+	concurrencyMetrics := func(t *testing.T, handler *proxy.Handler) map[string]interface{} {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		handler.HandleMetrics(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		var metrics map[string]interface{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &metrics); err != nil {
+			t.Fatalf("Failed to parse metrics: %v", err)
+		}
+		stats, ok := metrics["concurrency"].(map[string]interface{})
+		if !ok {
+			t.Fatalf("metrics missing concurrency section: %s", rec.Body.String())
+		}
+		return stats
+	}
+
+	// fire sends a request in the background and reports its status code.
+	fire := func(handler *proxy.Handler, codes chan<- int) {
+		go func() {
+			codes <- postMessages(t, handler, simpleRequest()).Code
+		}()
+	}
+
+	t.Run("rejects excess", func(t *testing.T) {
+		handler, entered, release := newBlockingHandler(t, false)
+		codes := make(chan int, limit+extra)
+
+		for i := 0; i < limit; i++ {
+			fire(handler, codes)
+			<-entered
+		}
+		for i := 0; i < extra; i++ {
+			if code := postMessages(t, handler, simpleRequest()).Code; code != http.StatusServiceUnavailable {
+				t.Errorf("excess request: expected status 503, got %d", code)
+			}
+		}
+
+		stats := concurrencyMetrics(t, handler)
+		if stats["in_flight"] != float64(limit) || stats["rejected"] != float64(extra) {
+			t.Errorf("unexpected concurrency metrics: %v", stats)
+		}
+
+		close(release)
+		for i := 0; i < limit; i++ {
+			if code := <-codes; code != http.StatusOK {
+				t.Errorf("in-flight request: expected status 200, got %d", code)
+			}
+		}
+		if stats := concurrencyMetrics(t, handler); stats["in_flight"] != float64(0) {
+			t.Errorf("in_flight = %v after completion, want 0", stats["in_flight"])
+		}
+	})
+
+	t.Run("queues excess when queue enabled", func(t *testing.T) {
+		handler, entered, release := newBlockingHandler(t, true)
+		codes := make(chan int, limit+extra)
+
+		for i := 0; i < limit; i++ {
+			fire(handler, codes)
+			<-entered
+		}
+		for i := 0; i < extra; i++ {
+			fire(handler, codes)
+		}
+
+		deadline := time.Now().Add(2 * time.Second)
+		for concurrencyMetrics(t, handler)["queued"] != float64(extra) {
+			if time.Now().After(deadline) {
+				t.Fatalf("excess requests were not queued: %v", concurrencyMetrics(t, handler))
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+
+		close(release)
+		for i := 0; i < limit+extra; i++ {
+			if code := <-codes; code != http.StatusOK {
+				t.Errorf("expected status 200, got %d", code)
+			}
+		}
+		if stats := concurrencyMetrics(t, handler); stats["rejected"] != float64(0) {
+			t.Errorf("rejected = %v, want 0 with queuing", stats["rejected"])
+		}
+	})
+}
+
+func TestUpstream_SSEHeartbeat(t *testing.T) {
+	handler := newTestHandler(t, func(cfg *config.Config) {
+		cfg.SSEHeartbeatSeconds = 1
+	}, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		flusher := w.(http.Flusher)
+		_, _ = w.Write([]byte("data: {\"id\":\"1\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Thinking\"}}]}\n\n"))
+		flusher.Flush()
+
+		// Long gap between tokens
+		time.Sleep(2500 * time.Millisecond)
+
+		_, _ = w.Write([]byte("data: {\"id\":\"1\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\" done\"}}]}\n\n" +
+			"data: {\"id\":\"1\",\"choices\":[{\"index\":0,\"delta\":{},\"finish_reason\":\"stop\"}]}\n\n" +
+			"data: [DONE]\n\n"))
+	})
+
+	req := simpleRequest()
+	req.Stream = true
+	rec := postMessages(t, handler, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	// Split the stream into SSE blocks; heartbeats must be standalone comment blocks
+	var events []string
+	pings := 0
+	for _, block := range strings.Split(strings.TrimSpace(rec.Body.String()), "\n\n") {
+		if block == ": ping" {
+			pings++
+			if len(events) > 0 && events[len(events)-1] == "message_stop" {
+				t.Error("heartbeat sent after message_stop")
+			}
+			continue
+		}
+		if !strings.HasPrefix(block, "event: ") {
+			continue // e.g. the trailing data: [DONE]
+		}
+		events = append(events, strings.TrimPrefix(strings.SplitN(block, "\n", 2)[0], "event: "))
+	}
+
+	if pings < 1 {
+		t.Errorf("expected heartbeats during the idle gap, got none:\n%s", rec.Body.String())
+	}
+	if len(events) < 2 || events[0] != "message_start" || events[len(events)-1] != "message_stop" {
+		t.Errorf("invalid event sequence: %v", events)
+	}
+}
+
+func TestUpstream_StreamClientDisconnect(t *testing.T) {
+	firstChunkSent := make(chan struct{})
+	upstreamClosed := make(chan struct{})
+	handler := newTestHandler(t, nil, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte("data: {\"id\":\"1\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Hello\"}}]}\n\n"))
+		w.(http.Flusher).Flush()
+		close(firstChunkSent)
+
+		// Keep the stream open until CLASP hangs up
+		select {
+		case <-r.Context().Done():
+			close(upstreamClosed)
+		case <-time.After(5 * time.Second):
+		}
+	})
+
+	req := simpleRequest()
+	req.Stream = true
+	body, _ := json.Marshal(req)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	httpReq := httptest.NewRequest(http.MethodPost, "/v1/messages", bytes.NewReader(body)).WithContext(ctx)
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		handler.HandleMessages(httptest.NewRecorder(), httpReq)
+	}()
+
+	select {
+	case <-firstChunkSent:
+	case <-time.After(2 * time.Second):
+		t.Fatal("upstream never started streaming")
+	}
+	cancel() // client disconnects mid-stream
+
+	select {
+	case <-upstreamClosed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("upstream body was not closed after the client disconnected")
+	}
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("handler did not return after the client disconnected")
+	}
+
+	if aborts := atomic.LoadInt64(&handler.GetMetrics().StreamClientAborts); aborts != 1 {
+		t.Errorf("expected 1 stream client abort, got %d", aborts)
+	}
+}
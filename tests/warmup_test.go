@@ -0,0 +1,73 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/jedarden/clasp/internal/config"
+	"github.com/jedarden/clasp/internal/proxy"
+)
+
+// countingServer returns a server that answers every request with status and
+// counts them.
+func countingServer(t *testing.T, status int, count *atomic.Int64) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count.Add(1)
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestWarmup_ProbesEachConfiguredProviderOnce(t *testing.T) {
+	var primary, sonnet, haiku atomic.Int64
+	sonnetServer := countingServer(t, http.StatusOK, &sonnet)
+	haikuServer := countingServer(t, http.StatusUnauthorized, &haiku)
+
+	handler := newTestHandler(t, func(cfg *config.Config) {
+		cfg.MultiProviderEnabled = true
+		cfg.TierSonnet = &config.TierConfig{Provider: config.ProviderCustom, BaseURL: sonnetServer.URL, APIKey: "sonnet-key", Model: "gpt-4o"}
+		cfg.TierHaiku = &config.TierConfig{Provider: config.ProviderCustom, BaseURL: haikuServer.URL, APIKey: "bad-key", Model: "gpt-4o-mini"}
+	}, func(w http.ResponseWriter, r *http.Request) {
+		primary.Add(1)
+		w.WriteHeader(http.StatusOK)
+	})
+
+	results := handler.Warmup(context.Background())
+
+	primaryCalls, sonnetCalls, haikuCalls := primary.Load(), sonnet.Load(), haiku.Load()
+	if primaryCalls != 1 || sonnetCalls != 1 || haikuCalls != 1 {
+		t.Errorf("Expected one warmup request per endpoint, got primary=%d sonnet=%d haiku=%d", primaryCalls, sonnetCalls, haikuCalls)
+	}
+	if len(results) != 3 {
+		t.Fatalf("Expected 3 results, got %+v", results)
+	}
+	statuses := make(map[string]int)
+	for _, r := range results {
+		if r.Err != nil {
+			t.Errorf("Unexpected error warming up %s: %v", r.Name, r.Err)
+		}
+		statuses[r.Name] = r.Status
+	}
+	if statuses["primary"] != http.StatusOK || statuses["sonnet"] != http.StatusOK || statuses["haiku"] != http.StatusUnauthorized {
+		t.Errorf("Unexpected warmup statuses: %v", statuses)
+	}
+}
+
+func TestWarmup_UnreachableProviderDoesNotFail(t *testing.T) {
+	cfg, server := newMockUpstream(t, func(w http.ResponseWriter, r *http.Request) {})
+	server.Close()
+	handler, err := proxy.NewHandler(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create handler: %v", err)
+	}
+
+	results := handler.Warmup(context.Background())
+	if len(results) != 1 || results[0].Err == nil {
+		t.Errorf("Expected an error result for the unreachable provider, got %+v", results)
+	}
+}